@@ -29,6 +29,7 @@ require (
 	//our dependecies
 	github.com/sirupsen/logrus v1.9.3
 	github.com/syndtr/gocapability v0.0.0-20200815063812-42c35b437635
+	github.com/tetratelabs/wazero v1.5.0
 	github.com/vishvananda/netlink v1.1.1-0.20211118161826-650dca95af54
 	golang.org/x/mod v0.13.0
 	golang.org/x/sync v0.4.0
@@ -18,6 +18,10 @@ package linux
 const (
 	CSIGNAL = 0xff
 
+	// Only passable via clone3(2) and unshare(2), since it overlaps with
+	// CSIGNAL.
+	CLONE_NEWTIME = 0x80
+
 	CLONE_VM             = 0x100
 	CLONE_FS             = 0x200
 	CLONE_FILES          = 0x400
@@ -53,6 +57,11 @@ const (
 	CLONE_ARGS_SIZE_VER2 = 88
 )
 
+// Flags of pidfd_open(2), from include/uapi/linux/pidfd.h.
+const (
+	PIDFD_NONBLOCK = O_NONBLOCK
+)
+
 // CloneArgs is struct clone_args, from include/uapi/linux/sched.h.
 //
 // +marshal
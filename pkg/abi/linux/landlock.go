@@ -0,0 +1,63 @@
+package linux
+
+// Flags of landlock_create_ruleset(2), from include/uapi/linux/landlock.h.
+const (
+	LANDLOCK_CREATE_RULESET_VERSION = 1 << 0
+)
+
+// Rule types of landlock_add_rule(2).
+const (
+	LANDLOCK_RULE_PATH_BENEATH = 1
+	LANDLOCK_RULE_NET_PORT     = 2
+)
+
+// Filesystem access rights.
+const (
+	LANDLOCK_ACCESS_FS_EXECUTE     = 1 << 0
+	LANDLOCK_ACCESS_FS_WRITE_FILE  = 1 << 1
+	LANDLOCK_ACCESS_FS_READ_FILE   = 1 << 2
+	LANDLOCK_ACCESS_FS_READ_DIR    = 1 << 3
+	LANDLOCK_ACCESS_FS_REMOVE_DIR  = 1 << 4
+	LANDLOCK_ACCESS_FS_REMOVE_FILE = 1 << 5
+	LANDLOCK_ACCESS_FS_MAKE_CHAR   = 1 << 6
+	LANDLOCK_ACCESS_FS_MAKE_DIR    = 1 << 7
+	LANDLOCK_ACCESS_FS_MAKE_REG    = 1 << 8
+	LANDLOCK_ACCESS_FS_MAKE_SOCK   = 1 << 9
+	LANDLOCK_ACCESS_FS_MAKE_FIFO   = 1 << 10
+	LANDLOCK_ACCESS_FS_MAKE_BLOCK  = 1 << 11
+	LANDLOCK_ACCESS_FS_MAKE_SYM    = 1 << 12
+	LANDLOCK_ACCESS_FS_REFER       = 1 << 13
+	LANDLOCK_ACCESS_FS_TRUNCATE    = 1 << 14
+)
+
+// Network access rights.
+const (
+	LANDLOCK_ACCESS_NET_BIND_TCP    = 1 << 0
+	LANDLOCK_ACCESS_NET_CONNECT_TCP = 1 << 1
+)
+
+// LandlockRulesetAttr is struct landlock_ruleset_attr, from
+// include/uapi/linux/landlock.h.
+//
+// +marshal
+type LandlockRulesetAttr struct {
+	HandledAccessFS  uint64
+	HandledAccessNet uint64
+}
+
+// LandlockPathBeneathAttr is struct landlock_path_beneath_attr. Linux makes
+// it __attribute__((packed)), so it has no trailing padding.
+//
+// +marshal
+type LandlockPathBeneathAttr struct {
+	AllowedAccess uint64
+	ParentFD      int32
+}
+
+// LandlockNetPortAttr is struct landlock_net_port_attr.
+//
+// +marshal
+type LandlockNetPortAttr struct {
+	AllowedAccess uint64
+	Port          uint64
+}
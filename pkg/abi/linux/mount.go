@@ -0,0 +1,82 @@
+package linux
+
+// Flags of fsopen(2), from include/uapi/linux/mount.h.
+const (
+	FSOPEN_CLOEXEC = 0x1
+)
+
+// Flags of fspick(2).
+const (
+	FSPICK_CLOEXEC          = 0x1
+	FSPICK_SYMLINK_NOFOLLOW = 0x2
+	FSPICK_NO_AUTOMOUNT     = 0x4
+	FSPICK_EMPTY_PATH       = 0x8
+)
+
+// Commands of fsconfig(2).
+const (
+	FSCONFIG_SET_FLAG        = 0
+	FSCONFIG_SET_STRING      = 1
+	FSCONFIG_SET_BINARY      = 2
+	FSCONFIG_SET_PATH        = 3
+	FSCONFIG_SET_PATH_EMPTY  = 4
+	FSCONFIG_SET_FD          = 5
+	FSCONFIG_CMD_CREATE      = 6
+	FSCONFIG_CMD_RECONFIGURE = 7
+	FSCONFIG_CMD_CREATE_EXCL = 8
+)
+
+// Flags of fsmount(2).
+const (
+	FSMOUNT_CLOEXEC = 0x1
+)
+
+// Flags of open_tree(2).
+const (
+	OPEN_TREE_CLONE   = 0x1
+	OPEN_TREE_CLOEXEC = O_CLOEXEC
+)
+
+// Flags of move_mount(2).
+const (
+	MOVE_MOUNT_F_SYMLINKS   = 0x1
+	MOVE_MOUNT_F_AUTOMOUNTS = 0x2
+	MOVE_MOUNT_F_EMPTY_PATH = 0x4
+	MOVE_MOUNT_T_SYMLINKS   = 0x10
+	MOVE_MOUNT_T_AUTOMOUNTS = 0x20
+	MOVE_MOUNT_T_EMPTY_PATH = 0x40
+	MOVE_MOUNT_SET_GROUP    = 0x100
+	MOVE_MOUNT_BENEATH      = 0x200
+)
+
+// Mount attributes, for fsmount(2) and mount_setattr(2).
+const (
+	MOUNT_ATTR_RDONLY      = 0x1
+	MOUNT_ATTR_NOSUID      = 0x2
+	MOUNT_ATTR_NODEV       = 0x4
+	MOUNT_ATTR_NOEXEC      = 0x8
+	MOUNT_ATTR__ATIME      = 0x70
+	MOUNT_ATTR_RELATIME    = 0x0
+	MOUNT_ATTR_NOATIME     = 0x10
+	MOUNT_ATTR_STRICTATIME = 0x20
+	MOUNT_ATTR_NODIRATIME  = 0x80
+	MOUNT_ATTR_IDMAP       = 0x100000
+	MOUNT_ATTR_NOSYMFOLLOW = 0x200000
+)
+
+// AT_RECURSIVE applies an operation to an entire subtree, for open_tree(2)
+// and mount_setattr(2).
+const AT_RECURSIVE = 0x8000
+
+// MOUNT_ATTR_SIZE_VER0 is the size of the first published struct mount_attr.
+const MOUNT_ATTR_SIZE_VER0 = 32
+
+// MountAttr is struct mount_attr, from include/uapi/linux/mount.h.
+//
+// +marshal
+type MountAttr struct {
+	AttrSet     uint64
+	AttrClr     uint64
+	Propagation uint64
+	UsernsFD    uint64
+}
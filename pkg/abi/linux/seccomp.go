@@ -28,6 +28,32 @@ const (
 	SECCOMP_SET_MODE_FILTER   = 1
 	SECCOMP_FILTER_FLAG_TSYNC = 1
 	SECCOMP_GET_ACTION_AVAIL  = 2
+	SECCOMP_GET_NOTIF_SIZES   = 3
+
+	SECCOMP_FILTER_FLAG_NEW_LISTENER = 1 << 3
+)
+
+// Seccomp user notification constants taken from <linux/seccomp.h>.
+const (
+	SECCOMP_USER_NOTIF_FLAG_CONTINUE = 1
+
+	SECCOMP_ADDFD_FLAG_SETFD = 1 << 0
+	SECCOMP_ADDFD_FLAG_SEND  = 1 << 1
+
+	SECCOMP_IOC_MAGIC = '!'
+)
+
+// Seccomp user notification ioctls.
+var (
+	SECCOMP_IOCTL_NOTIF_RECV     = IOWR(SECCOMP_IOC_MAGIC, 0, SizeOfSeccompNotif)
+	SECCOMP_IOCTL_NOTIF_SEND     = IOWR(SECCOMP_IOC_MAGIC, 1, SizeOfSeccompNotifResp)
+	SECCOMP_IOCTL_NOTIF_ID_VALID = IOW(SECCOMP_IOC_MAGIC, 2, 8)
+	SECCOMP_IOCTL_NOTIF_ADDFD    = IOW(SECCOMP_IOC_MAGIC, 3, SizeOfSeccompNotifAddfd)
+
+	// SECCOMP_IOCTL_NOTIF_ID_VALID_WRONG_DIR is the value of
+	// SECCOMP_IOCTL_NOTIF_ID_VALID before Linux 5.17, which is still
+	// accepted.
+	SECCOMP_IOCTL_NOTIF_ID_VALID_WRONG_DIR = IOR(SECCOMP_IOC_MAGIC, 2, 8)
 )
 
 // BPFAction is an action for a BPF filter.
@@ -39,6 +65,7 @@ const (
 	SECCOMP_RET_KILL_THREAD  BPFAction = 0x00000000
 	SECCOMP_RET_TRAP         BPFAction = 0x00030000
 	SECCOMP_RET_ERRNO        BPFAction = 0x00050000
+	SECCOMP_RET_USER_NOTIF   BPFAction = 0x7fc00000
 	SECCOMP_RET_TRACE        BPFAction = 0x7ff00000
 	SECCOMP_RET_ALLOW        BPFAction = 0x7fff0000
 )
@@ -57,6 +84,8 @@ func (a BPFAction) String() string {
 		return fmt.Sprintf("trap (data=%#x)", data)
 	case SECCOMP_RET_ERRNO:
 		return fmt.Sprintf("return errno=%#x", a.Data())
+	case SECCOMP_RET_USER_NOTIF:
+		return "user notif"
 	case SECCOMP_RET_TRACE:
 		data := a.Data()
 		if data == 0 {
@@ -128,3 +157,50 @@ func (sd SeccompData) String() string {
 		sd.Args[5],
 	)
 }
+
+// Sizes of seccomp user notification structs.
+const (
+	SizeOfSeccompNotif      = 80
+	SizeOfSeccompNotifResp  = 24
+	SizeOfSeccompNotifAddfd = 24
+)
+
+// SeccompNotif is equivalent to struct seccomp_notif.
+//
+// +marshal
+type SeccompNotif struct {
+	ID    uint64
+	PID   uint32
+	Flags uint32
+	Data  SeccompData
+}
+
+// SeccompNotifResp is equivalent to struct seccomp_notif_resp.
+//
+// +marshal
+type SeccompNotifResp struct {
+	ID    uint64
+	Val   int64
+	Error int32
+	Flags uint32
+}
+
+// SeccompNotifAddfd is equivalent to struct seccomp_notif_addfd.
+//
+// +marshal
+type SeccompNotifAddfd struct {
+	ID         uint64
+	Flags      uint32
+	Srcfd      uint32
+	Newfd      uint32
+	NewfdFlags uint32
+}
+
+// SeccompNotifSizes is equivalent to struct seccomp_notif_sizes.
+//
+// +marshal
+type SeccompNotifSizes struct {
+	Notif     uint16
+	NotifResp uint16
+	Data      uint16
+}
@@ -0,0 +1,180 @@
+package linux
+
+// Flags for userfaultfd(2). Source: include/uapi/linux/userfaultfd.h
+const (
+	UFFD_USER_MODE_ONLY = 1
+)
+
+// UFFD_API is the API version of userfaultfd.
+const UFFD_API = 0xAA
+
+// Features of userfaultfd requested with UFFDIO_API.
+const (
+	UFFD_FEATURE_PAGEFAULT_FLAG_WP  = 1 << 0
+	UFFD_FEATURE_EVENT_FORK         = 1 << 1
+	UFFD_FEATURE_EVENT_REMAP        = 1 << 2
+	UFFD_FEATURE_EVENT_REMOVE       = 1 << 3
+	UFFD_FEATURE_MISSING_HUGETLBFS  = 1 << 4
+	UFFD_FEATURE_MISSING_SHMEM      = 1 << 5
+	UFFD_FEATURE_EVENT_UNMAP        = 1 << 6
+	UFFD_FEATURE_SIGBUS             = 1 << 7
+	UFFD_FEATURE_THREAD_ID          = 1 << 8
+	UFFD_FEATURE_MINOR_HUGETLBFS    = 1 << 9
+	UFFD_FEATURE_MINOR_SHMEM        = 1 << 10
+	UFFD_FEATURE_EXACT_ADDRESS      = 1 << 11
+	UFFD_FEATURE_WP_HUGETLBFS_SHMEM = 1 << 12
+	UFFD_FEATURE_WP_UNPOPULATED     = 1 << 13
+	UFFD_FEATURE_POISON             = 1 << 14
+	UFFD_FEATURE_WP_ASYNC           = 1 << 15
+)
+
+// Events reported in UffdMsg.Event.
+const (
+	UFFD_EVENT_PAGEFAULT = 0x12
+	UFFD_EVENT_FORK      = 0x13
+	UFFD_EVENT_REMAP     = 0x14
+	UFFD_EVENT_REMOVE    = 0x15
+	UFFD_EVENT_UNMAP     = 0x16
+)
+
+// Flags of UFFD_EVENT_PAGEFAULT.
+const (
+	UFFD_PAGEFAULT_FLAG_WRITE = 1 << 0
+	UFFD_PAGEFAULT_FLAG_WP    = 1 << 1
+	UFFD_PAGEFAULT_FLAG_MINOR = 1 << 2
+)
+
+// Numbers of userfaultfd ioctls, used in bitmasks of supported ioctls.
+const (
+	_UFFDIO_REGISTER     = 0x00
+	_UFFDIO_UNREGISTER   = 0x01
+	_UFFDIO_WAKE         = 0x02
+	_UFFDIO_COPY         = 0x03
+	_UFFDIO_ZEROPAGE     = 0x04
+	_UFFDIO_WRITEPROTECT = 0x06
+	_UFFDIO_CONTINUE     = 0x07
+	_UFFDIO_POISON       = 0x08
+	_UFFDIO_API          = 0x3F
+)
+
+// Bitmasks of ioctls reported by UFFDIO_API and UFFDIO_REGISTER.
+const (
+	UFFD_API_IOCTLS = 1<<_UFFDIO_REGISTER | 1<<_UFFDIO_UNREGISTER | 1<<_UFFDIO_API
+
+	UFFD_API_RANGE_IOCTLS = 1<<_UFFDIO_WAKE | 1<<_UFFDIO_COPY | 1<<_UFFDIO_ZEROPAGE |
+		1<<_UFFDIO_WRITEPROTECT
+
+	UFFD_API_RANGE_IOCTLS_WP = 1 << _UFFDIO_WRITEPROTECT
+)
+
+// Userfaultfd ioctls.
+var (
+	UFFDIO_API          = IOWR(UFFD_API, _UFFDIO_API, SizeOfUffdioAPI)
+	UFFDIO_REGISTER     = IOWR(UFFD_API, _UFFDIO_REGISTER, SizeOfUffdioRegister)
+	UFFDIO_UNREGISTER   = IOR(UFFD_API, _UFFDIO_UNREGISTER, SizeOfUffdioRange)
+	UFFDIO_WAKE         = IOR(UFFD_API, _UFFDIO_WAKE, SizeOfUffdioRange)
+	UFFDIO_COPY         = IOWR(UFFD_API, _UFFDIO_COPY, SizeOfUffdioCopy)
+	UFFDIO_ZEROPAGE     = IOWR(UFFD_API, _UFFDIO_ZEROPAGE, SizeOfUffdioZeropage)
+	UFFDIO_WRITEPROTECT = IOWR(UFFD_API, _UFFDIO_WRITEPROTECT, SizeOfUffdioWriteprotect)
+)
+
+// Modes of UFFDIO_REGISTER.
+const (
+	UFFDIO_REGISTER_MODE_MISSING = 1 << 0
+	UFFDIO_REGISTER_MODE_WP      = 1 << 1
+	UFFDIO_REGISTER_MODE_MINOR   = 1 << 2
+)
+
+// Modes of UFFDIO_COPY, UFFDIO_ZEROPAGE and UFFDIO_WRITEPROTECT.
+const (
+	UFFDIO_COPY_MODE_DONTWAKE         = 1 << 0
+	UFFDIO_COPY_MODE_WP               = 1 << 1
+	UFFDIO_ZEROPAGE_MODE_DONTWAKE     = 1 << 0
+	UFFDIO_WRITEPROTECT_MODE_WP       = 1 << 0
+	UFFDIO_WRITEPROTECT_MODE_DONTWAKE = 1 << 1
+)
+
+// Sizes of userfaultfd structs.
+const (
+	SizeOfUffdMsg            = 32
+	SizeOfUffdioAPI          = 24
+	SizeOfUffdioRange        = 16
+	SizeOfUffdioRegister     = 32
+	SizeOfUffdioCopy         = 40
+	SizeOfUffdioZeropage     = 32
+	SizeOfUffdioWriteprotect = 24
+)
+
+// UffdMsg is equivalent to struct uffd_msg. Arg holds the union of event
+// arguments:
+//
+//   - UFFD_EVENT_PAGEFAULT: flags, address and ptid.
+//   - UFFD_EVENT_FORK: ufd.
+//   - UFFD_EVENT_REMAP: from, to and len.
+//   - UFFD_EVENT_REMOVE and UFFD_EVENT_UNMAP: start and end.
+//
+// 32-bit members (ptid and ufd) are stored in the low bits of the 64-bit
+// words, which matches the layout on little-endian architectures.
+//
+// +marshal
+type UffdMsg struct {
+	Event     uint8
+	Reserved1 uint8
+	Reserved2 uint16
+	Reserved3 uint32
+	Arg       [3]uint64
+}
+
+// UffdioAPI is equivalent to struct uffdio_api.
+//
+// +marshal
+type UffdioAPI struct {
+	API      uint64
+	Features uint64
+	Ioctls   uint64
+}
+
+// UffdioRange is equivalent to struct uffdio_range.
+//
+// +marshal
+type UffdioRange struct {
+	Start uint64
+	Len   uint64
+}
+
+// UffdioRegister is equivalent to struct uffdio_register.
+//
+// +marshal
+type UffdioRegister struct {
+	Range  UffdioRange
+	Mode   uint64
+	Ioctls uint64
+}
+
+// UffdioCopy is equivalent to struct uffdio_copy.
+//
+// +marshal
+type UffdioCopy struct {
+	Dst  uint64
+	Src  uint64
+	Len  uint64
+	Mode uint64
+	Copy int64
+}
+
+// UffdioZeropage is equivalent to struct uffdio_zeropage.
+//
+// +marshal
+type UffdioZeropage struct {
+	Range    UffdioRange
+	Mode     uint64
+	Zeropage int64
+}
+
+// UffdioWriteprotect is equivalent to struct uffdio_writeprotect.
+//
+// +marshal
+type UffdioWriteprotect struct {
+	Range UffdioRange
+	Mode  uint64
+}
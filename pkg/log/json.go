@@ -82,19 +82,20 @@ type moreJSONLog struct {
 	Time  time.Time   `json:"time"`
 }
 
-// MoreJSONEmitter logs in json format. Message should also be int json format
+// MoreJSONEmitter logs in newline-delimited json format. Message should also be in json format
 type MoreJSONEmitter struct {
 	*Writer
 }
 
 // Emit implements Emitter.Emit.
 //
-// note that in this Emitter argument v is not supported
+// The message is formatted first, json message is written as is, other
+// messages are written as json strings.
 func (e MoreJSONEmitter) Emit(_ int, level Level, timestamp time.Time, format string, v ...any) {
-	var jsObj interface{}
-	err := json.Unmarshal([]byte(format), &jsObj)
-	if err != nil {
-		jsObj = fmt.Sprintf(format, v...)
+	msg := fmt.Sprintf(format, v...)
+	var jsObj interface{} = msg
+	if json.Valid([]byte(msg)) {
+		jsObj = json.RawMessage(msg)
 	}
 	j := moreJSONLog{
 		Msg:   jsObj,
@@ -105,7 +106,9 @@ func (e MoreJSONEmitter) Emit(_ int, level Level, timestamp time.Time, format st
 	if err != nil {
 		panic(err)
 	}
-	e.Writer.Write(b)
+	// Every record is written at once with trailing newline, so records are
+	// never interleaved and may be read as newline-delimited json.
+	e.Writer.Write(append(b, '\n'))
 }
 
 // JSONLog retrieves the global custom json logger.
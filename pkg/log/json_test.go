@@ -15,7 +15,9 @@
 package log
 
 import (
+	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -62,3 +64,24 @@ func TestUnmarshalFromInt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that messages logged with "%s" keep '%' and json messages are written as is.
+func TestMoreJSONEmitterKeepsPercent(t *testing.T) {
+	for _, tc := range []struct {
+		msg  string
+		want string
+	}{
+		{`{"path":"/tmp/100%d","n":9007199254740993}`, `"msg":{"path":"/tmp/100%d","n":9007199254740993}`},
+		{`50% done`, `"msg":"50% done"`},
+	} {
+		var buf bytes.Buffer
+		logger := &JSONLogger{Level: Info, Emitter: MoreJSONEmitter{&Writer{Next: &buf}}}
+		logger.Infof("%s", tc.msg)
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("logged %q, expected it to contain %q", buf.String(), tc.want)
+		}
+		if !json.Valid(bytes.TrimSpace(buf.Bytes())) {
+			t.Errorf("logged %q isn't valid json", buf.String())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// QueuedWriter is an io.Writer which passes written data to Next from a
+// separate goroutine through a bounded queue. Write never blocks: if the queue
+// is full, data is dropped and counted. When records were dropped, a
+// {"dropped":N} record is written before the next one that gets through.
+//
+// Each call of Write is passed to Next as a single Write, so callers should
+// write whole records at once.
+type QueuedWriter struct {
+	// Next is where output is written.
+	Next io.Writer
+
+	queue chan []byte
+
+	// dropped is the count of writes which were dropped since the last report.
+	dropped atomic.Uint64
+
+	// totalDropped is the count of writes which were dropped because the queue
+	// was full.
+	totalDropped atomic.Uint64
+
+	// failed is the count of writes to Next which returned an error.
+	failed atomic.Uint64
+}
+
+// NewQueuedWriter creates QueuedWriter with queue of size records and starts
+// the goroutine writing to next.
+func NewQueuedWriter(next io.Writer, size int) *QueuedWriter {
+	w := &QueuedWriter{
+		Next:  next,
+		queue: make(chan []byte, size),
+	}
+	go w.run()
+	return w
+}
+
+// Write implements io.Writer.Write.
+func (w *QueuedWriter) Write(data []byte) (int, error) {
+	record := make([]byte, len(data))
+	copy(record, data)
+
+	select {
+	case w.queue <- record:
+	default:
+		w.dropped.Add(1)
+		w.totalDropped.Add(1)
+	}
+	return len(data), nil
+}
+
+// Dropped returns the count of writes dropped because the queue was full.
+func (w *QueuedWriter) Dropped() uint64 {
+	return w.totalDropped.Load()
+}
+
+// Failed returns the count of writes to Next which failed.
+func (w *QueuedWriter) Failed() uint64 {
+	return w.failed.Load()
+}
+
+func (w *QueuedWriter) run() {
+	for record := range w.queue {
+		if dropped := w.dropped.Swap(0); dropped > 0 {
+			if _, err := fmt.Fprintf(w.Next, "{\"dropped\":%d}\n", dropped); err != nil {
+				w.dropped.Add(dropped)
+			}
+		}
+		if _, err := w.Next.Write(record); err != nil {
+			w.failed.Add(1)
+		}
+	}
+}
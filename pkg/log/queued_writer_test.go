@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks every Write until unblock is closed.
+type blockingWriter struct {
+	unblock chan struct{}
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *blockingWriter) Write(data []byte) (int, error) {
+	<-w.unblock
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(data)
+}
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func waitForOutput(t *testing.T, w *blockingWriter, want string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if w.String() == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("output got %q, want %q", w.String(), want)
+}
+
+func TestQueuedWriterKeepsOrder(t *testing.T) {
+	next := &blockingWriter{unblock: make(chan struct{})}
+	close(next.unblock)
+	w := NewQueuedWriter(next, 16)
+
+	var want strings.Builder
+	for _, record := range []string{"a\n", "b\n", "c\n"} {
+		if n, err := w.Write([]byte(record)); err != nil || n != len(record) {
+			t.Fatalf("Write(%q) got (%d, %v)", record, n, err)
+		}
+		want.WriteString(record)
+	}
+	waitForOutput(t, next, want.String())
+}
+
+func TestQueuedWriterDropsWhenFull(t *testing.T) {
+	next := &blockingWriter{unblock: make(chan struct{})}
+	w := NewQueuedWriter(next, 1)
+
+	// The first record may be taken by the writing goroutine, so up to two
+	// records fit before the queue is full.
+	for i := 0; i < 5; i++ {
+		w.Write([]byte("x\n"))
+	}
+	if w.Dropped() < 3 {
+		t.Fatalf("Dropped() got %d, want at least 3", w.Dropped())
+	}
+
+	// Writes may still be dropped until the queue is drained.
+	close(next.unblock)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasSuffix(next.String(), "y\n") && time.Now().Before(deadline) {
+		w.Write([]byte("y\n"))
+		time.Sleep(time.Millisecond)
+	}
+	if !strings.Contains(next.String(), "{\"dropped\":") {
+		t.Errorf("output %q doesn't report dropped records", next.String())
+	}
+}
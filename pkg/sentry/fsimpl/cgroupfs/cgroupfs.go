@@ -312,11 +312,19 @@ func (fsType FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 	if vfsfs != nil {
 		fs := vfsfs.Impl().(*filesystem)
 		ctx.Debugf("cgroupfs.FilesystemType.GetFilesystem: mounting new view to hierarchy %v", fs.hierarchyID)
-		fs.root.IncRef()
+		// Tasks in a cgroup namespace see the hierarchy from the root of
+		// their namespace.
+		root := fs.root
+		if t := kernel.TaskFromContext(ctx); t != nil {
+			if cg, ok := t.CgroupNamespace().Root(fs.hierarchyID); ok {
+				root = cg.Dentry
+			}
+		}
+		root.IncRef()
 		if fs.effectiveRoot != fs.root {
 			fs.effectiveRoot.IncRef()
 		}
-		return vfsfs, fs.root.VFSDentry(), nil
+		return vfsfs, root.VFSDentry(), nil
 	}
 
 	// No existing hierarchy with the exactly controllers found. Make a new
@@ -0,0 +1,663 @@
+// Package fanotify implements fanotify groups created by fanotify_init(2).
+package fanotify
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const (
+	// PermEvents are events which block until the listener responds.
+	PermEvents = linux.FAN_OPEN_PERM | linux.FAN_ACCESS_PERM | linux.FAN_OPEN_EXEC_PERM
+
+	// Events are all events which can be set in marks.
+	Events = linux.FAN_ACCESS | linux.FAN_MODIFY | linux.FAN_CLOSE | linux.FAN_OPEN |
+		linux.FAN_OPEN_EXEC | PermEvents
+
+	// MarkFlags are event flags which can be set in marks in addition to
+	// Events.
+	MarkFlags = linux.FAN_ONDIR | linux.FAN_EVENT_ON_CHILD
+)
+
+// Group implements vfs.FileDescriptionImpl for fanotify groups. It receives
+// file access events of the whole VirtualFilesystem as vfs.FanotifyListener
+// and reports events matching its marks.
+//
+// Marks on directories don't report events of their children, because
+// vfs.Dentry doesn't provide access to parents. FAN_EVENT_ON_CHILD is
+// accepted, but has no effect.
+//
+// +stateify savable
+type Group struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+
+	// vfsObj is the VirtualFilesystem the group listens to. vfsObj is
+	// immutable.
+	vfsObj *vfs.VirtualFilesystem
+
+	// flags are flags passed to fanotify_init(2). flags is immutable.
+	flags uint32
+
+	// eventFlags are the status flags of files opened for events. eventFlags
+	// is immutable.
+	eventFlags uint32
+
+	// queue is used to notify readers about events.
+	queue waiter.Queue
+
+	// mu protects the fields below.
+	mu sync.Mutex `state:"nosave"`
+
+	// released is set when the group is released, and it doesn't accept
+	// events anymore.
+	released bool
+
+	// Marks by their targets. The group holds a reference on each target.
+	inodeMarks map[*vfs.Dentry]*mark
+	mountMarks map[*vfs.Mount]*mark
+	fsMarks    map[*vfs.Filesystem]*mark
+
+	// events are events that haven't been read yet.
+	events []*event
+
+	// overflowed is true if FAN_Q_OVERFLOW is queued in events.
+	overflowed bool
+
+	// responses are read permission events waiting for response by the fd
+	// which was reported with the event.
+	responses map[int32]*event
+}
+
+var _ vfs.FileDescriptionImpl = (*Group)(nil)
+var _ vfs.FanotifyListener = (*Group)(nil)
+
+// mark holds events of a mark set by fanotify_mark(2).
+//
+// +stateify savable
+type mark struct {
+	// mask are the reported events.
+	mask uint64
+
+	// ignoredMask are the ignored events.
+	ignoredMask uint64
+
+	// surviveModify is true if ignoredMask isn't cleared by FAN_MODIFY.
+	surviveModify bool
+}
+
+// event is a queued fanotify event.
+//
+// +stateify savable
+type event struct {
+	// mask are the events.
+	mask uint64
+
+	// vd is the file of the event. Queued events hold a reference on vd,
+	// except FAN_Q_OVERFLOW, which has no file.
+	vd vfs.VirtualDentry
+
+	// task is the task which caused the event.
+	task *kernel.Task
+
+	// done is closed when the permission event is answered. It's nil for
+	// other events.
+	done chan struct{} `state:"nosave"`
+
+	// response is the response to the permission event. It's protected by
+	// Group.mu and valid after done is closed.
+	response uint32
+
+	// canceled is true if the permission event isn't awaited anymore. It's
+	// protected by Group.mu.
+	canceled bool
+}
+
+// New returns a new fanotify group.
+func New(ctx context.Context, vfsObj *vfs.VirtualFilesystem, flags, eventFlags uint32) (*vfs.FileDescription, error) {
+	vd := vfsObj.NewAnonVirtualDentry("[fanotify]")
+	defer vd.DecRef(ctx)
+	g := &Group{
+		vfsObj:     vfsObj,
+		flags:      flags,
+		eventFlags: eventFlags,
+		inodeMarks: make(map[*vfs.Dentry]*mark),
+		mountMarks: make(map[*vfs.Mount]*mark),
+		fsMarks:    make(map[*vfs.Filesystem]*mark),
+		responses:  make(map[int32]*event),
+	}
+	statusFlags := uint32(linux.O_RDWR)
+	if flags&linux.FAN_NONBLOCK != 0 {
+		statusFlags |= linux.O_NONBLOCK
+	}
+	if err := g.vfsfd.Init(g, statusFlags, vd.Mount(), vd.Dentry(), &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+		DenyPRead:         true,
+		DenyPWrite:        true,
+	}); err != nil {
+		return nil, err
+	}
+	vfsObj.AddFanotifyListener(g)
+	return &g.vfsfd, nil
+}
+
+// AllowsPermEvents returns true if the group was created with a class which
+// allows permission events.
+func (g *Group) AllowsPermEvents() bool {
+	return g.flags&linux.FAN_ALL_CLASS_BITS != 0
+}
+
+// Release implements vfs.FileDescriptionImpl.Release. Pending permission
+// events are allowed, like in Linux.
+func (g *Group) Release(ctx context.Context) {
+	g.vfsObj.RemoveFanotifyListener(g)
+
+	g.mu.Lock()
+	g.released = true
+	inodeMarks, mountMarks, fsMarks := g.inodeMarks, g.mountMarks, g.fsMarks
+	g.inodeMarks, g.mountMarks, g.fsMarks = nil, nil, nil
+	events := g.events
+	g.events = nil
+	for _, ev := range g.responses {
+		ev.respondLocked(linux.FAN_ALLOW)
+	}
+	g.responses = nil
+	for _, ev := range events {
+		if ev.done != nil {
+			ev.respondLocked(linux.FAN_ALLOW)
+		}
+	}
+	g.mu.Unlock()
+
+	for d := range inodeMarks {
+		d.DecRef(ctx)
+	}
+	for mnt := range mountMarks {
+		mnt.DecRef(ctx)
+	}
+	for fs := range fsMarks {
+		fs.DecRef(ctx)
+	}
+	for _, ev := range events {
+		// Permission events are released by the waiters.
+		if ev.done == nil {
+			ev.release(ctx)
+		}
+	}
+}
+
+// AddMark adds events in mask to the mark of the given type on vd, see
+// FAN_MARK_ADD. flags are fanotify_mark(2) flags.
+func (g *Group) AddMark(vd vfs.VirtualDentry, markType uint32, flags uint32, mask uint64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m := g.markLocked(vd, markType)
+	if m == nil {
+		if g.flags&linux.FAN_UNLIMITED_MARKS == 0 && g.markCountLocked() >= linux.FANOTIFY_DEFAULT_MAX_MARKS {
+			return linuxerr.ENOSPC
+		}
+		m = &mark{}
+		switch markType {
+		case linux.FAN_MARK_MOUNT:
+			vd.Mount().IncRef()
+			g.mountMarks[vd.Mount()] = m
+		case linux.FAN_MARK_FILESYSTEM:
+			vd.Mount().Filesystem().IncRef()
+			g.fsMarks[vd.Mount().Filesystem()] = m
+		default:
+			vd.Dentry().IncRef()
+			g.inodeMarks[vd.Dentry()] = m
+		}
+	}
+	if flags&linux.FAN_MARK_IGNORED_MASK != 0 {
+		m.ignoredMask |= mask
+		if flags&linux.FAN_MARK_IGNORED_SURV_MODIFY != 0 {
+			m.surviveModify = true
+		}
+	} else {
+		m.mask |= mask
+	}
+	return nil
+}
+
+// RemoveMark removes events in mask from the mark of the given type on vd, see
+// FAN_MARK_REMOVE. The mark is removed when it has no events.
+func (g *Group) RemoveMark(ctx context.Context, vd vfs.VirtualDentry, markType uint32, flags uint32, mask uint64) error {
+	g.mu.Lock()
+	m := g.markLocked(vd, markType)
+	if m == nil {
+		g.mu.Unlock()
+		return linuxerr.ENOENT
+	}
+	if flags&linux.FAN_MARK_IGNORED_MASK != 0 {
+		m.ignoredMask &^= mask
+	} else {
+		m.mask &^= mask
+	}
+	if m.mask != 0 || m.ignoredMask != 0 {
+		g.mu.Unlock()
+		return nil
+	}
+
+	var target interface{ DecRef(context.Context) }
+	switch markType {
+	case linux.FAN_MARK_MOUNT:
+		delete(g.mountMarks, vd.Mount())
+		target = vd.Mount()
+	case linux.FAN_MARK_FILESYSTEM:
+		delete(g.fsMarks, vd.Mount().Filesystem())
+		target = vd.Mount().Filesystem()
+	default:
+		delete(g.inodeMarks, vd.Dentry())
+		target = vd.Dentry()
+	}
+	g.mu.Unlock()
+
+	target.DecRef(ctx)
+	return nil
+}
+
+// FlushMarks removes all marks of the given type, see FAN_MARK_FLUSH.
+func (g *Group) FlushMarks(ctx context.Context, markType uint32) {
+	var targets []interface{ DecRef(context.Context) }
+	g.mu.Lock()
+	switch markType {
+	case linux.FAN_MARK_MOUNT:
+		for mnt := range g.mountMarks {
+			targets = append(targets, mnt)
+		}
+		g.mountMarks = make(map[*vfs.Mount]*mark)
+	case linux.FAN_MARK_FILESYSTEM:
+		for fs := range g.fsMarks {
+			targets = append(targets, fs)
+		}
+		g.fsMarks = make(map[*vfs.Filesystem]*mark)
+	default:
+		for d := range g.inodeMarks {
+			targets = append(targets, d)
+		}
+		g.inodeMarks = make(map[*vfs.Dentry]*mark)
+	}
+	g.mu.Unlock()
+
+	for _, target := range targets {
+		target.DecRef(ctx)
+	}
+}
+
+// Preconditions: g.mu is locked.
+func (g *Group) markLocked(vd vfs.VirtualDentry, markType uint32) *mark {
+	switch markType {
+	case linux.FAN_MARK_MOUNT:
+		return g.mountMarks[vd.Mount()]
+	case linux.FAN_MARK_FILESYSTEM:
+		return g.fsMarks[vd.Mount().Filesystem()]
+	default:
+		return g.inodeMarks[vd.Dentry()]
+	}
+}
+
+// Preconditions: g.mu is locked.
+func (g *Group) markCountLocked() int {
+	return len(g.inodeMarks) + len(g.mountMarks) + len(g.fsMarks)
+}
+
+// matchLocked returns the events in mask reported by marks of the file at vd,
+// and true if the marks report events on directories.
+//
+// Preconditions: g.mu is locked.
+func (g *Group) matchLocked(vd vfs.VirtualDentry, mask uint64) (uint64, bool) {
+	var marksMask, ignoredMask uint64
+	if m := g.inodeMarks[vd.Dentry()]; m != nil {
+		// "If this flag is not set, the ignore mask is cleared when a modify
+		//  event occurs on the marked object." - fanotify_mark(2)
+		if mask&linux.FAN_MODIFY != 0 && !m.surviveModify {
+			m.ignoredMask = 0
+		}
+		marksMask |= m.mask
+		ignoredMask |= m.ignoredMask
+	}
+	if m := g.mountMarks[vd.Mount()]; m != nil {
+		marksMask |= m.mask
+		ignoredMask |= m.ignoredMask
+	}
+	if m := g.fsMarks[vd.Mount().Filesystem()]; m != nil {
+		marksMask |= m.mask
+		ignoredMask |= m.ignoredMask
+	}
+	return mask & marksMask &^ ignoredMask, marksMask&linux.FAN_ONDIR != 0
+}
+
+// HandleFanotifyEvent implements vfs.FanotifyListener.HandleFanotifyEvent.
+func (g *Group) HandleFanotifyEvent(ctx context.Context, fd *vfs.FileDescription, mask uint64) error {
+	vd := fd.VirtualDentry()
+	g.mu.Lock()
+	if g.released {
+		g.mu.Unlock()
+		return nil
+	}
+	mask, onDir := g.matchLocked(vd, mask)
+	g.mu.Unlock()
+	if mask == 0 {
+		return nil
+	}
+	if !onDir {
+		// "Events for directories are created only if FAN_ONDIR is set."
+		// - fanotify_mark(2)
+		stat, err := fd.Stat(ctx, vfs.StatOptions{Mask: linux.STATX_TYPE})
+		if err == nil && stat.Mode&linux.S_IFMT == linux.S_IFDIR {
+			return nil
+		}
+	}
+
+	ev := &event{
+		mask: mask,
+		vd:   vd,
+		task: kernel.TaskFromContext(ctx),
+	}
+	if mask&PermEvents == 0 {
+		vd.IncRef()
+		if !g.enqueue(ev) {
+			ev.release(ctx)
+		}
+		return nil
+	}
+	return g.waitResponse(ctx, ev)
+}
+
+// waitResponse queues the permission event ev and blocks until it's answered.
+func (g *Group) waitResponse(ctx context.Context, ev *event) error {
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		// There is no task which can be blocked.
+		return nil
+	}
+	ev.vd.IncRef()
+	defer ev.release(ctx)
+	ev.done = make(chan struct{})
+	if !g.enqueue(ev) {
+		// The group is released or the queue overflowed.
+		return nil
+	}
+
+	err := t.Block(ev.done)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err != nil {
+		ev.canceled = true
+		for i, other := range g.events {
+			if other == ev {
+				g.events = append(g.events[:i], g.events[i+1:]...)
+				break
+			}
+		}
+		return linuxerr.ConvertIntr(err, linuxerr.ERESTARTSYS)
+	}
+	if ev.response&linux.FAN_DENY != 0 {
+		return linuxerr.EPERM
+	}
+	return nil
+}
+
+// enqueue queues ev. It returns false if the event is dropped.
+func (g *Group) enqueue(ev *event) bool {
+	g.mu.Lock()
+	if g.released {
+		g.mu.Unlock()
+		return false
+	}
+	if n := len(g.events); n > 0 && ev.done == nil {
+		// Merge the event with the last one, like Linux does with events on
+		// the same file by the same process.
+		if last := g.events[n-1]; last.done == nil && last.vd == ev.vd && last.task == ev.task {
+			last.mask |= ev.mask
+			g.mu.Unlock()
+			// Not queued, so the reference isn't needed.
+			return false
+		}
+	}
+	if g.flags&linux.FAN_UNLIMITED_QUEUE == 0 && len(g.events) >= linux.FANOTIFY_DEFAULT_MAX_EVENTS {
+		if !g.overflowed {
+			g.overflowed = true
+			g.events = append(g.events, &event{mask: linux.FAN_Q_OVERFLOW})
+		}
+		g.mu.Unlock()
+		g.queue.Notify(waiter.ReadableEvents)
+		return false
+	}
+	g.events = append(g.events, ev)
+	g.mu.Unlock()
+
+	g.queue.Notify(waiter.ReadableEvents)
+	return true
+}
+
+// release drops the reference on the file of ev.
+func (ev *event) release(ctx context.Context) {
+	if ev.vd.Ok() {
+		ev.vd.DecRef(ctx)
+	}
+}
+
+// respondLocked answers the permission event ev.
+//
+// Preconditions: Group.mu is locked.
+func (ev *event) respondLocked(response uint32) {
+	if ev.canceled {
+		return
+	}
+	ev.canceled = true
+	ev.response = response
+	close(ev.done)
+}
+
+// Read implements vfs.FileDescriptionImpl.Read. Each event is reported with a
+// new file descriptor of the event's file.
+func (g *Group) Read(ctx context.Context, dst usermem.IOSequence, opts vfs.ReadOptions) (int64, error) {
+	if dst.NumBytes() < linux.SizeOfFanotifyEventMetadata {
+		return 0, linuxerr.EINVAL
+	}
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		return 0, linuxerr.EINVAL
+	}
+
+	var n int64
+	for dst.NumBytes() >= linux.SizeOfFanotifyEventMetadata {
+		g.mu.Lock()
+		if len(g.events) == 0 {
+			g.mu.Unlock()
+			break
+		}
+		ev := g.events[0]
+		g.events = g.events[1:]
+		if ev.mask&linux.FAN_Q_OVERFLOW != 0 {
+			g.overflowed = false
+		}
+		// Permission events can be canceled concurrently, so the file is
+		// opened with a separate reference.
+		vd := ev.vd
+		if vd.Ok() {
+			vd.IncRef()
+		}
+		g.mu.Unlock()
+
+		fd, err := g.installFD(t, vd)
+		if vd.Ok() {
+			vd.DecRef(ctx)
+		}
+		if err == nil {
+			meta := linux.FanotifyEventMetadata{
+				EventLen:    linux.SizeOfFanotifyEventMetadata,
+				Vers:        linux.FANOTIFY_METADATA_VERSION,
+				MetadataLen: linux.SizeOfFanotifyEventMetadata,
+				Mask:        ev.mask,
+				FD:          fd,
+				PID:         g.pid(t, ev.task),
+			}
+			buf := t.CopyScratchBuffer(linux.SizeOfFanotifyEventMetadata)
+			meta.MarshalUnsafe(buf)
+			if _, err = dst.CopyOut(ctx, buf); err != nil && fd >= 0 {
+				if file := t.FDTable().Remove(t, fd); file != nil {
+					file.DecRef(t)
+				}
+			}
+		}
+
+		g.mu.Lock()
+		if ev.done != nil {
+			if err != nil {
+				// Like in Linux, events which can't be reported are denied.
+				ev.respondLocked(linux.FAN_DENY)
+			} else if !ev.canceled {
+				g.responses[fd] = ev
+			}
+		}
+		g.mu.Unlock()
+		if ev.done == nil {
+			ev.release(ctx)
+		}
+
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		n += linux.SizeOfFanotifyEventMetadata
+		dst = dst.DropFirst(linux.SizeOfFanotifyEventMetadata)
+	}
+	if n == 0 {
+		return 0, linuxerr.ErrWouldBlock
+	}
+	return n, nil
+}
+
+// installFD opens the file at vd and installs it into the fd table of t. It
+// returns FAN_NOFD for events without a file.
+func (g *Group) installFD(t *kernel.Task, vd vfs.VirtualDentry) (int32, error) {
+	if !vd.Ok() {
+		return linux.FAN_NOFD, nil
+	}
+	file, err := g.vfsObj.OpenAt(t, t.Credentials(), &vfs.PathOperation{
+		Root:  vd,
+		Start: vd,
+	}, &vfs.OpenOptions{
+		Flags:    g.eventFlags &^ linux.O_CLOEXEC,
+		NoNotify: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer file.DecRef(t)
+	return t.NewFDFrom(0, file, kernel.FDFlags{
+		CloseOnExec: g.eventFlags&linux.O_CLOEXEC != 0,
+	})
+}
+
+// pid returns the ID of the thread group of the task which caused the event in
+// the PID namespace of t, or the ID of the task if FAN_REPORT_TID is set.
+func (g *Group) pid(t *kernel.Task, task *kernel.Task) int32 {
+	if task == nil {
+		return 0
+	}
+	if g.flags&linux.FAN_REPORT_TID != 0 {
+		return int32(t.PIDNamespace().IDOfTask(task))
+	}
+	return int32(t.PIDNamespace().IDOfThreadGroup(task.ThreadGroup()))
+}
+
+// Write implements vfs.FileDescriptionImpl.Write. It's used to answer
+// permission events with struct fanotify_response.
+func (g *Group) Write(ctx context.Context, src usermem.IOSequence, opts vfs.WriteOptions) (int64, error) {
+	var resp linux.FanotifyResponse
+	if src.NumBytes() < int64(resp.SizeBytes()) {
+		return 0, linuxerr.EINVAL
+	}
+	buf := make([]byte, resp.SizeBytes())
+	if _, err := src.CopyIn(ctx, buf); err != nil {
+		return 0, err
+	}
+	resp.UnmarshalUnsafe(buf)
+
+	if resp.FD < 0 {
+		return 0, linuxerr.EINVAL
+	}
+	if resp.Response&^(linux.FAN_ALLOW|linux.FAN_DENY|linux.FAN_AUDIT) != 0 {
+		return 0, linuxerr.EINVAL
+	}
+	switch resp.Response &^ linux.FAN_AUDIT {
+	case linux.FAN_ALLOW, linux.FAN_DENY:
+	default:
+		return 0, linuxerr.EINVAL
+	}
+	if resp.Response&linux.FAN_AUDIT != 0 && g.flags&linux.FAN_ENABLE_AUDIT == 0 {
+		return 0, linuxerr.EINVAL
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ev, ok := g.responses[resp.FD]
+	if !ok {
+		return 0, linuxerr.ENOENT
+	}
+	delete(g.responses, resp.FD)
+	ev.respondLocked(resp.Response &^ linux.FAN_AUDIT)
+	return int64(resp.SizeBytes()), nil
+}
+
+// Readiness implements waiter.Waitable.Readiness.
+func (g *Group) Readiness(mask waiter.EventMask) waiter.EventMask {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.events) > 0 {
+		return mask & waiter.ReadableEvents
+	}
+	return 0
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (g *Group) EventRegister(e *waiter.Entry) error {
+	g.queue.EventRegister(e)
+	return nil
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (g *Group) EventUnregister(e *waiter.Entry) {
+	g.queue.EventUnregister(e)
+}
+
+// Epollable implements vfs.FileDescriptionImpl.Epollable.
+func (g *Group) Epollable() bool {
+	return true
+}
+
+// Ioctl implements vfs.FileDescriptionImpl.Ioctl.
+func (g *Group) Ioctl(ctx context.Context, uio usermem.IO, sysno uintptr, args arch.SyscallArguments) (uintptr, error) {
+	switch args[1].Int() {
+	case linux.FIONREAD:
+		g.mu.Lock()
+		n := uint32(len(g.events) * linux.SizeOfFanotifyEventMetadata)
+		g.mu.Unlock()
+		var buf [4]byte
+		hostarch.ByteOrder.PutUint32(buf[:], n)
+		_, err := uio.CopyOut(ctx, args[2].Pointer(), buf[:], usermem.IOOpts{})
+		return 0, err
+	default:
+		log.Debugf("Unsupported fanotify ioctl: %#x", args[1].Int())
+		return 0, linuxerr.ENOTTY
+	}
+}
@@ -0,0 +1,171 @@
+// Package jsfs implements synthetic files, which content is generated by js functions
+// (see kernel.VirtualFile). Each file is a filesystem with the single file, which is
+// mounted at the path of the file
+package jsfs
+
+import (
+	"bytes"
+	"fmt"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+// Name is the filesystem name.
+const Name = "jsfs"
+
+// maxWriteSize limits the size of data passed to js by single write, longer writes are short
+const maxWriteSize = 1 << 20
+
+type filesystemType struct{}
+
+// Name implements vfs.FilesystemType.Name.
+func (filesystemType) Name() string {
+	return Name
+}
+
+// Release implements vfs.FilesystemType.Release.
+func (filesystemType) Release(ctx context.Context) {}
+
+// GetFilesystem implements vfs.FilesystemType.GetFilesystem.
+func (filesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials, source string, opts vfs.GetFilesystemOptions) (*vfs.Filesystem, *vfs.Dentry, error) {
+	panic("jsfs.filesystemType.GetFilesystem should never be called")
+}
+
+type filesystem struct {
+	kernfs.Filesystem
+
+	devMinor uint32
+}
+
+// Release implements vfs.FilesystemImpl.Release.
+func (fs *filesystem) Release(ctx context.Context) {
+	fs.Filesystem.VFSFilesystem().VirtualFilesystem().PutAnonBlockDevMinor(fs.devMinor)
+	fs.Filesystem.Release(ctx)
+}
+
+// MountOptions implements vfs.FilesystemImpl.MountOptions.
+func (fs *filesystem) MountOptions() string {
+	return ""
+}
+
+// virtualFile implements vfs.WritableDynamicBytesSource for kernel.VirtualFile
+type virtualFile struct {
+	kernfs.DynamicBytesFile
+
+	file *kernel.VirtualFile
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (f *virtualFile) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		return linuxerr.EIO
+	}
+
+	data, err := f.file.Read(t)
+	if err != nil {
+		log.Warningf("read function of virtual file %s failed: %v", f.file.Path(), err)
+		return linuxerr.EIO
+	}
+	buf.Write(data)
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (f *virtualFile) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	if !f.file.Writable() {
+		return 0, linuxerr.EACCES
+	}
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		return 0, linuxerr.EIO
+	}
+
+	size := src.NumBytes()
+	if size > maxWriteSize {
+		size = maxWriteSize
+	}
+	data := make([]byte, size)
+	n, err := src.CopyIn(ctx, data)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := f.file.Write(t, data[:n], offset); err != nil {
+		log.Warningf("write function of virtual file %s failed: %v", f.file.Path(), err)
+		return 0, linuxerr.EIO
+	}
+	return int64(n), nil
+}
+
+// newFilesystem returns the filesystem with the single file and the dentry of the file
+func newFilesystem(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials,
+	file *kernel.VirtualFile) (*vfs.Filesystem, *vfs.Dentry, error) {
+
+	devMinor, err := vfsObj.GetAnonBlockDevMinor()
+	if err != nil {
+		return nil, nil, err
+	}
+	fs := &filesystem{devMinor: devMinor}
+	fs.VFSFilesystem().Init(vfsObj, filesystemType{}, fs)
+
+	inode := &virtualFile{file: file}
+	inode.DynamicBytesFile.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), inode, file.Mode())
+
+	var root kernfs.Dentry
+	root.InitRoot(&fs.Filesystem, inode)
+	return fs.VFSFilesystem(), root.VFSDentry(), nil
+}
+
+// MountVirtualFiles mounts virtual files from the init config into the mount namespace.
+// Mountpoints of missing files are created like mountpoints of submounts
+func MountVirtualFiles(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials, mntns *vfs.MountNamespace) error {
+	files := kernel.GetJsRuntime().VirtualFiles()
+	if len(files) == 0 {
+		return nil
+	}
+
+	root := mntns.Root(ctx)
+	defer root.DecRef(ctx)
+	for _, file := range files {
+		if err := mountVirtualFile(ctx, vfsObj, creds, root, file); err != nil {
+			return fmt.Errorf("mounting virtual file %q: %w", file.Path(), err)
+		}
+		log.Infof("Mounted virtual file %q", file.Path())
+	}
+	return nil
+}
+
+func mountVirtualFile(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials,
+	root vfs.VirtualDentry, file *kernel.VirtualFile) error {
+
+	target := &vfs.PathOperation{
+		Root:  root,
+		Start: root,
+		Path:  fspath.Parse(file.Path()),
+	}
+	if vd, err := vfsObj.GetDentryAt(ctx, creds, target, &vfs.GetDentryOptions{}); err == nil {
+		vd.DecRef(ctx)
+	} else if err := vfsObj.MakeSyntheticMountpoint(ctx, file.Path(), root, creds); err != nil {
+		return err
+	}
+
+	fs, d, err := newFilesystem(ctx, vfsObj, creds, file)
+	if err != nil {
+		return err
+	}
+	mnt := vfsObj.NewDisconnectedMount(fs, d, &vfs.MountOptions{})
+	fs.DecRef(ctx)
+	d.DecRef(ctx)
+	defer mnt.DecRef(ctx)
+
+	return vfsObj.ConnectMountAt(ctx, creds, mnt, target)
+}
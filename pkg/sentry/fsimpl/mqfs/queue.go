@@ -30,6 +30,7 @@ import (
 //
 // +stateify savable
 type queueInode struct {
+	queueInodeRefs
 	kernfs.DynamicBytesFile
 
 	// queue is the message queue backing this inode.
@@ -42,9 +43,17 @@ var _ kernfs.Inode = (*queueInode)(nil)
 func (fs *filesystem) newQueueInode(ctx context.Context, creds *auth.Credentials, q *mq.Queue, perm linux.FileMode) kernfs.Inode {
 	inode := &queueInode{queue: q}
 	inode.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), q, perm)
+	inode.InitRefs()
 	return inode
 }
 
+// DecRef implements kernfs.Inode.DecRef. The queue is released with the last
+// reference, which is dropped when the queue is unlinked and all its FDs are
+// closed.
+func (q *queueInode) DecRef(ctx context.Context) {
+	q.queueInodeRefs.DecRef(func() { q.queue.Release(ctx) })
+}
+
 // Keep implements kernfs.Inode.Keep.
 func (q *queueInode) Keep() bool {
 	// Return true so that the fs keeps newly created dentries. This is done
@@ -53,6 +62,40 @@ func (q *queueInode) Keep() bool {
 	return true
 }
 
+// Open implements kernfs.Inode.Open. Queues opened as files can be used with
+// mq_timedsend(2) and mq_timedreceive(2), like in Linux.
+func (q *queueInode) Open(ctx context.Context, rp *vfs.ResolvingPath, d *kernfs.Dentry, opts vfs.OpenOptions) (*vfs.FileDescription, error) {
+	var access mq.AccessType
+	switch opts.Flags & linux.O_ACCMODE {
+	case linux.O_RDONLY:
+		access = mq.ReadOnly
+	case linux.O_WRONLY:
+		access = mq.WriteOnly
+	default:
+		access = mq.ReadWrite
+	}
+	view, err := mq.NewView(q.queue, access, opts.Flags&linux.O_NONBLOCK == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := &queueFD{queue: view}
+	if err := fd.Init(rp.Mount(), d, q.queue, q.Locks(), opts.Flags); err != nil {
+		return nil, err
+	}
+	return &fd.vfsfd, nil
+}
+
+// QueueView returns the view into the message queue backing file, or false if
+// file isn't a message queue FD.
+func QueueView(file *vfs.FileDescription) (mq.View, bool) {
+	fd, ok := file.Impl().(*queueFD)
+	if !ok {
+		return nil, false
+	}
+	return fd.queue, true
+}
+
 // queueFD implements vfs.FileDescriptionImpl for FD backed by a POSIX message
 // queue. It's mostly similar to DynamicBytesFD, but implements more operations.
 //
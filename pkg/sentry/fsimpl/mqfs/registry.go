@@ -93,6 +93,7 @@ func (r *RegistryImpl) Get(ctx context.Context, name string, access mq.AccessTyp
 
 	qInode := inode.(*queueInode)
 	if !qInode.queue.HasPermissions(auth.CredentialsFromContext(ctx), perm(access)) {
+		qInode.DecRef(ctx)
 		// "The queue exists, but the caller does not have permission to
 		//  open it in the specified mode."
 		return nil, false, linuxerr.EACCES
@@ -111,8 +112,12 @@ func (r *RegistryImpl) New(ctx context.Context, name string, q *mq.Queue, access
 	qInode := r.fs.newQueueInode(ctx, auth.CredentialsFromContext(ctx), q, perm).(*queueInode)
 	err := root.Insert(name, qInode)
 	if err != nil {
+		qInode.DecRef(ctx)
 		return nil, err
 	}
+	// The initial reference is held by root until the queue is unlinked, the
+	// FD gets its own.
+	qInode.IncRef()
 	return r.newFD(q, qInode, access, block, flags)
 }
 
@@ -128,6 +133,7 @@ func (r *RegistryImpl) Unlink(ctx context.Context, name string) error {
 	if err != nil {
 		return err
 	}
+	defer inode.DecRef(ctx)
 	return root.Unlink(ctx, name, inode)
 }
 
@@ -62,9 +62,25 @@ func (i *rootInode) Open(ctx context.Context, rp *vfs.ResolvingPath, d *kernfs.D
 	return fd.VFSFileDescription(), nil
 }
 
-// DecRef implements kernfs.Inode.DecRef.
+// DecRef implements kernfs.Inode.DecRef. Queues which are still linked are
+// released with the root.
 func (i *rootInode) DecRef(ctx context.Context) {
-	i.rootInodeRefs.DecRef(func() { i.Destroy(ctx) })
+	i.rootInodeRefs.DecRef(func() {
+		i.ForEachChild(func(_ string, child kernfs.Inode) {
+			child.DecRef(ctx)
+		})
+		i.Destroy(ctx)
+	})
+}
+
+// Unlink implements kernfs.Inode.Unlink and overrides OrderedChildren.Unlink.
+// The root holds a reference on every linked queue, which is dropped here.
+func (i *rootInode) Unlink(ctx context.Context, name string, child kernfs.Inode) error {
+	if err := i.OrderedChildren.Unlink(ctx, name, child); err != nil {
+		return err
+	}
+	child.DecRef(ctx)
+	return nil
 }
 
 // Rename implements Inode.Rename and overrides OrderedChildren.Rename. mqueue
@@ -345,6 +345,14 @@ func (d *fdInfoData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	// See https://www.kernel.org/doc/Documentation/filesystems/proc.txt
 	flags := uint(file.StatusFlags()) | descriptorFlags.ToLinuxFileFlags()
 	fmt.Fprintf(buf, "flags:\t0%o\n", flags)
+	if pidfd, ok := file.Impl().(*kernel.PIDFD); ok {
+		// The ID is shown in the PID namespace of the reader.
+		pidns := kernel.PIDNamespaceFromContext(ctx)
+		if pidns == nil {
+			pidns = d.task.PIDNamespace()
+		}
+		fmt.Fprintf(buf, "Pid:\t%d\n", pidfd.PID(pidns))
+	}
 	return nil
 }
 
@@ -18,9 +18,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
@@ -971,6 +973,102 @@ func (o *oomScoreAdj) Write(ctx context.Context, _ *vfs.FileDescription, src use
 	return src.NumBytes(), nil
 }
 
+// timensOffsetsData implements vfs.WritableDynamicBytesSource for
+// /proc/[pid]/timens_offsets, which holds the clock offsets of the time
+// namespace of the task's future children.
+//
+// +stateify savable
+type timensOffsetsData struct {
+	kernfs.DynamicBytesFile
+
+	task *kernel.Task
+}
+
+var _ vfs.WritableDynamicBytesSource = (*timensOffsetsData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *timensOffsetsData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	ns := d.task.GetTimeNamespaceForChildren()
+	if ns == nil {
+		return linuxerr.ESRCH
+	}
+	defer ns.DecRef(ctx)
+	monotonic, boottime := ns.Offsets()
+	for _, off := range []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"monotonic", monotonic},
+		{"boottime", boottime},
+	} {
+		// Like struct timespec64, nanoseconds are never negative.
+		ts := linux.NsecToTimespec(off.offset.Nanoseconds())
+		if ts.Nsec < 0 {
+			ts.Sec--
+			ts.Nsec += int64(time.Second)
+		}
+		fmt.Fprintf(buf, "%-10s %10d %9d\n", off.name, ts.Sec, ts.Nsec)
+	}
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (d *timensOffsetsData) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	// Limit input size so as not to impact performance if input size is large.
+	src = src.TakeFirst(hostarch.PageSize - 1)
+	buf := make([]byte, src.NumBytes())
+	n, err := src.CopyIn(ctx, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	// Linux: kernel/time/namespace.c:proc_timens_set_offset()
+	var offsets []kernel.TimeOffset
+	for _, line := range strings.Split(strings.TrimRight(string(buf[:n]), "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || len(offsets) == 2 {
+			return 0, linuxerr.EINVAL
+		}
+		var clockID int32
+		switch fields[0] {
+		case "monotonic", strconv.Itoa(linux.CLOCK_MONOTONIC):
+			clockID = linux.CLOCK_MONOTONIC
+		case "boottime", strconv.Itoa(linux.CLOCK_BOOTTIME):
+			clockID = linux.CLOCK_BOOTTIME
+		default:
+			return 0, linuxerr.EINVAL
+		}
+		sec, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, linuxerr.EINVAL
+		}
+		nsec, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil || nsec >= uint64(time.Second) {
+			return 0, linuxerr.EINVAL
+		}
+		if sec >= math.MaxInt64/int64(time.Second) || sec <= math.MinInt64/int64(time.Second) {
+			return 0, linuxerr.ERANGE
+		}
+		offsets = append(offsets, kernel.TimeOffset{
+			ClockID: clockID,
+			Offset:  time.Duration(sec)*time.Second + time.Duration(nsec),
+		})
+	}
+
+	ns := d.task.GetTimeNamespaceForChildren()
+	if ns == nil {
+		return 0, linuxerr.ESRCH
+	}
+	defer ns.DecRef(ctx)
+	if !auth.CredentialsFromContext(ctx).HasCapabilityIn(linux.CAP_SYS_TIME, ns.UserNamespace()) {
+		return 0, linuxerr.EPERM
+	}
+	if err := ns.SetOffsets(offsets); err != nil {
+		return 0, err
+	}
+	return int64(n), nil
+}
+
 // exeSymlink is an symlink for the /proc/[pid]/exe file.
 //
 // +stateify savable
@@ -1238,6 +1336,10 @@ type namespaceSymlink struct {
 
 	task   *kernel.Task
 	nsType int
+
+	// forChildren is set if the symlink refers to the time namespace of the
+	// task's future children rather than to the task's own.
+	forChildren bool
 }
 
 func (fs *filesystem) newNamespaceSymlink(ctx context.Context, task *kernel.Task, ino uint64, nsType int) kernfs.Inode {
@@ -1250,6 +1352,16 @@ func (fs *filesystem) newNamespaceSymlink(ctx context.Context, task *kernel.Task
 	return taskInode
 }
 
+func (fs *filesystem) newTimeForChildrenSymlink(ctx context.Context, task *kernel.Task, ino uint64) kernfs.Inode {
+	inode := &namespaceSymlink{task: task, nsType: linux.CLONE_NEWTIME, forChildren: true}
+
+	// Note: credentials are overridden by taskOwnedInode.
+	inode.Init(ctx, task.Credentials(), linux.UNNAMED_MAJOR, fs.devMinor, ino, "")
+
+	taskInode := &taskOwnedInode{Inode: inode, owner: task}
+	return taskInode
+}
+
 func (fs *filesystem) newPIDNamespaceSymlink(ctx context.Context, task *kernel.Task, ino uint64) kernfs.Inode {
 	target := fmt.Sprintf("pid:[%d]", task.PIDNamespace().ID())
 
@@ -1293,6 +1405,22 @@ func (s *namespaceSymlink) getInode(t *kernel.Task) *nsfs.Inode {
 			return utsns.GetInode()
 		}
 		return nil
+	case linux.CLONE_NEWCGROUP:
+		if cgroupns := t.GetCgroupNamespace(); cgroupns != nil {
+			return cgroupns.GetInode()
+		}
+		return nil
+	case linux.CLONE_NEWTIME:
+		var timens *kernel.TimeNamespace
+		if s.forChildren {
+			timens = t.GetTimeNamespaceForChildren()
+		} else {
+			timens = t.GetTimeNamespace()
+		}
+		if timens != nil {
+			return timens.GetInode()
+		}
+		return nil
 	case linux.CLONE_NEWNS:
 		mntns := t.GetMountNamespace()
 		if mntns == nil {
@@ -1442,7 +1570,12 @@ func (d *taskCgroupData) Generate(ctx context.Context, buf *bytes.Buffer) error
 		return linuxerr.ESRCH
 	}
 
-	d.task.GenerateProcTaskCgroup(buf)
+	// Paths are relative to the cgroup namespace of the reader.
+	var cgroupns *kernel.CgroupNamespace
+	if t := kernel.TaskFromContext(ctx); t != nil {
+		cgroupns = t.CgroupNamespace()
+	}
+	d.task.GenerateProcTaskCgroup(buf, cgroupns)
 	return nil
 }
 
@@ -324,8 +324,15 @@ func (*uptimeData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	k := kernel.KernelFromContext(ctx)
 	now := time.NowFromContext(ctx)
 
+	uptime := now.Sub(k.Timekeeper().BootTime())
+	// Uptime is reported in the time namespace of the reader.
+	if t := kernel.TaskFromContext(ctx); t != nil {
+		_, boottime := t.TimeNamespace().Offsets()
+		uptime += boottime
+	}
+
 	// Pretend that we've spent zero time sleeping (second number).
-	fmt.Fprintf(buf, "%.2f 0.00\n", now.Sub(k.Timekeeper().BootTime()).Seconds())
+	fmt.Fprintf(buf, "%.2f 0.00\n", uptime.Seconds())
 	return nil
 }
 
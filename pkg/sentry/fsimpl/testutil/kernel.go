@@ -147,6 +147,9 @@ func CreateTask(ctx context.Context, name string, tc *kernel.ThreadGroup, mntns
 		FDTable:          k.NewFDTable(),
 		UserCounters:     k.GetUserCounters(creds.RealKUID),
 	}
+	config.TimeNamespace = k.RootTimeNamespace()
+	config.TimeNamespaceForChildren = k.RootTimeNamespace()
+	config.CgroupNamespace = k.RootCgroupNamespace()
 	config.NetworkNamespace.IncRef()
 	t, err := k.TaskSet().NewTask(ctx, config)
 	if err != nil {
@@ -0,0 +1,731 @@
+// Package userfaultfd implements userfaultfd file descriptions created by
+// userfaultfd(2).
+package userfaultfd
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// Features are the UFFD_FEATURE_* that can be enabled with UFFDIO_API. Only
+// private anonymous memory can be registered, so features for shared memory
+// and hugetlbfs aren't supported.
+const Features = linux.UFFD_FEATURE_PAGEFAULT_FLAG_WP | linux.UFFD_FEATURE_EVENT_FORK |
+	linux.UFFD_FEATURE_EVENT_REMAP | linux.UFFD_FEATURE_EVENT_REMOVE |
+	linux.UFFD_FEATURE_EVENT_UNMAP | linux.UFFD_FEATURE_SIGBUS |
+	linux.UFFD_FEATURE_THREAD_ID | linux.UFFD_FEATURE_EXACT_ADDRESS
+
+// UserfaultFileDescription implements vfs.FileDescriptionImpl for
+// userfaultfds. It implements mm.Userfaultfd for the vmas of its
+// MemoryManager registered with UFFDIO_REGISTER.
+//
+// +stateify savable
+type UserfaultFileDescription struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+
+	// vfsObj is used to create file descriptions of userfaultfds reported
+	// by UFFD_EVENT_FORK. vfsObj is immutable.
+	vfsObj *vfs.VirtualFilesystem
+
+	// mm is the MemoryManager whose faults are handled by the userfaultfd.
+	// The userfaultfd doesn't hold a user of mm. mm is immutable.
+	mm *mm.MemoryManager
+
+	// flags are flags passed to userfaultfd(2). flags is immutable.
+	flags uint32
+
+	// queue is used to notify readers about messages.
+	queue waiter.Queue
+
+	// mu protects the fields below.
+	mu sync.Mutex `state:"nosave"`
+
+	// api is true after UFFDIO_API succeeded.
+	api bool
+
+	// features are the features enabled by UFFDIO_API.
+	features uint64
+
+	// released is set when the userfaultfd is released, after which faults
+	// aren't handled anymore.
+	released bool
+
+	// msgs are messages that haven't been read yet.
+	msgs []*message
+
+	// faults are faults that haven't been resolved yet.
+	faults []*fault
+}
+
+var _ vfs.FileDescriptionImpl = (*UserfaultFileDescription)(nil)
+var _ mm.Userfaultfd = (*UserfaultFileDescription)(nil)
+
+// message is a queued userfaultfd message.
+//
+// +stateify savable
+type message struct {
+	msg linux.UffdMsg
+
+	// child is the userfaultfd reported by UFFD_EVENT_FORK.
+	child *UserfaultFileDescription
+
+	// done is closed when the message is read. It's nil for page faults,
+	// which wait until the fault is resolved instead.
+	done chan struct{} `state:"nosave"`
+}
+
+// fault is a page fault waiting to be resolved.
+//
+// +stateify savable
+type fault struct {
+	// addr is the address of the faulting page.
+	addr hostarch.Addr
+
+	// msg is the message that reports the fault.
+	msg *message
+
+	// done is closed when the fault is resolved.
+	done chan struct{} `state:"nosave"`
+}
+
+// New returns a new userfaultfd handling faults of mm.
+func New(ctx context.Context, vfsObj *vfs.VirtualFilesystem, mm *mm.MemoryManager, flags uint32) (*vfs.FileDescription, error) {
+	fd := &UserfaultFileDescription{
+		vfsObj: vfsObj,
+		mm:     mm,
+		flags:  flags,
+	}
+	if err := fd.init(ctx); err != nil {
+		return nil, err
+	}
+	return &fd.vfsfd, nil
+}
+
+// init initializes the file description of fd.
+func (fd *UserfaultFileDescription) init(ctx context.Context) error {
+	vd := fd.vfsObj.NewAnonVirtualDentry("[userfaultfd]")
+	defer vd.DecRef(ctx)
+	return fd.vfsfd.Init(fd, linux.O_RDWR|fd.flags&linux.O_NONBLOCK, vd.Mount(), vd.Dentry(), &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+		DenyPRead:         true,
+		DenyPWrite:        true,
+	})
+}
+
+// Release implements vfs.FileDescriptionImpl.Release. Vmas are unregistered
+// and waiting faults are woken up, so they are retried without the
+// userfaultfd.
+func (fd *UserfaultFileDescription) Release(ctx context.Context) {
+	fd.mm.ReleaseUserfaultfd(fd)
+
+	fd.mu.Lock()
+	fd.released = true
+	msgs := fd.msgs
+	fd.msgs = nil
+	for _, m := range msgs {
+		if m.done != nil {
+			close(m.done)
+		}
+	}
+	for _, f := range fd.faults {
+		close(f.done)
+	}
+	fd.faults = nil
+	fd.mu.Unlock()
+
+	// Userfaultfds of unread fork events will never be installed, so the
+	// memory of the children isn't handled by them.
+	for _, m := range msgs {
+		if m.child == nil {
+			continue
+		}
+		if m.child.vfsfd.Impl() != nil {
+			// Drop the reference kept by installChild.
+			m.child.vfsfd.DecRef(ctx)
+		} else {
+			m.child.mm.ReleaseUserfaultfd(m.child)
+		}
+	}
+}
+
+// Features implements mm.Userfaultfd.Features.
+func (fd *UserfaultFileDescription) Features() uint64 {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return fd.features
+}
+
+// HandleFault implements mm.Userfaultfd.HandleFault.
+func (fd *UserfaultFileDescription) HandleFault(ctx context.Context, addr hostarch.Addr, flags uint64, user bool) error {
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		// There is no task which can be blocked.
+		return linuxerr.EFAULT
+	}
+	if !user && fd.flags&linux.UFFD_USER_MODE_ONLY != 0 {
+		return linuxerr.EFAULT
+	}
+
+	fd.mu.Lock()
+	if fd.released {
+		fd.mu.Unlock()
+		return nil
+	}
+	if fd.features&linux.UFFD_FEATURE_SIGBUS != 0 {
+		fd.mu.Unlock()
+		return &memmap.BusError{Err: linuxerr.EFAULT}
+	}
+	msgAddr := addr
+	if fd.features&linux.UFFD_FEATURE_EXACT_ADDRESS == 0 {
+		msgAddr = addr.RoundDown()
+	}
+	var ptid uint64
+	if fd.features&linux.UFFD_FEATURE_THREAD_ID != 0 {
+		ptid = uint64(uint32(t.ThreadID()))
+	}
+	f := &fault{
+		addr: addr.RoundDown(),
+		msg: &message{
+			msg: linux.UffdMsg{
+				Event: linux.UFFD_EVENT_PAGEFAULT,
+				Arg:   [3]uint64{flags, uint64(msgAddr), ptid},
+			},
+		},
+		done: make(chan struct{}),
+	}
+	fd.faults = append(fd.faults, f)
+	fd.msgs = append(fd.msgs, f.msg)
+	fd.mu.Unlock()
+	fd.queue.Notify(waiter.ReadableEvents)
+
+	err := t.Block(f.done)
+	if err == nil {
+		return nil
+	}
+
+	fd.mu.Lock()
+	fd.removeFaultLocked(f)
+	fd.mu.Unlock()
+	if user {
+		// The access is retried after the signal is handled.
+		return nil
+	}
+	return linuxerr.ConvertIntr(err, linuxerr.ERESTARTSYS)
+}
+
+// removeFaultLocked removes f and its message if it hasn't been read.
+//
+// Preconditions: fd.mu is locked.
+func (fd *UserfaultFileDescription) removeFaultLocked(f *fault) {
+	for i, other := range fd.faults {
+		if other == f {
+			fd.faults = append(fd.faults[:i], fd.faults[i+1:]...)
+			break
+		}
+	}
+	fd.removeMessageLocked(f.msg)
+}
+
+// removeMessageLocked removes m if it hasn't been read.
+//
+// Preconditions: fd.mu is locked.
+func (fd *UserfaultFileDescription) removeMessageLocked(m *message) {
+	for i, other := range fd.msgs {
+		if other == m {
+			fd.msgs = append(fd.msgs[:i], fd.msgs[i+1:]...)
+			return
+		}
+	}
+}
+
+// wakeLocked resolves faults in ar.
+//
+// Preconditions: fd.mu is locked.
+func (fd *UserfaultFileDescription) wakeLocked(ar hostarch.AddrRange) {
+	faults := fd.faults[:0]
+	for _, f := range fd.faults {
+		if ar.Contains(f.addr) {
+			close(f.done)
+			fd.removeMessageLocked(f.msg)
+			continue
+		}
+		faults = append(faults, f)
+	}
+	fd.faults = faults
+}
+
+// wake resolves faults in ar.
+func (fd *UserfaultFileDescription) wake(ar hostarch.AddrRange) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	fd.wakeLocked(ar)
+}
+
+// Fork implements mm.Userfaultfd.Fork.
+func (fd *UserfaultFileDescription) Fork(child *mm.MemoryManager) mm.Userfaultfd {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	// The file description is initialized when the fork event is read.
+	return &UserfaultFileDescription{
+		vfsObj:   fd.vfsObj,
+		mm:       child,
+		flags:    fd.flags,
+		api:      true,
+		features: fd.features,
+	}
+}
+
+// NotifyFork implements mm.Userfaultfd.NotifyFork.
+func (fd *UserfaultFileDescription) NotifyFork(ctx context.Context, child mm.Userfaultfd) {
+	c := child.(*UserfaultFileDescription)
+	if !fd.notify(ctx, &message{
+		msg:   linux.UffdMsg{Event: linux.UFFD_EVENT_FORK},
+		child: c,
+	}) {
+		c.mm.ReleaseUserfaultfd(c)
+	}
+}
+
+// NotifyRemap implements mm.Userfaultfd.NotifyRemap.
+func (fd *UserfaultFileDescription) NotifyRemap(ctx context.Context, from, to hostarch.Addr, length uint64) {
+	fd.notify(ctx, &message{
+		msg: linux.UffdMsg{
+			Event: linux.UFFD_EVENT_REMAP,
+			Arg:   [3]uint64{uint64(from), uint64(to), length},
+		},
+	})
+}
+
+// NotifyRemove implements mm.Userfaultfd.NotifyRemove.
+func (fd *UserfaultFileDescription) NotifyRemove(ctx context.Context, ar hostarch.AddrRange) {
+	fd.notify(ctx, &message{
+		msg: linux.UffdMsg{
+			Event: linux.UFFD_EVENT_REMOVE,
+			Arg:   [3]uint64{uint64(ar.Start), uint64(ar.End)},
+		},
+	})
+}
+
+// NotifyUnmap implements mm.Userfaultfd.NotifyUnmap.
+func (fd *UserfaultFileDescription) NotifyUnmap(ctx context.Context, ar hostarch.AddrRange) {
+	fd.notify(ctx, &message{
+		msg: linux.UffdMsg{
+			Event: linux.UFFD_EVENT_UNMAP,
+			Arg:   [3]uint64{uint64(ar.Start), uint64(ar.End)},
+		},
+	})
+}
+
+// notify queues the event m and blocks until it's read, like Linux's
+// fs/userfaultfd.c:userfaultfd_event_wait_completion(). It returns false if
+// the event wasn't read.
+func (fd *UserfaultFileDescription) notify(ctx context.Context, m *message) bool {
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		// There is no task which can be blocked.
+		return false
+	}
+	m.done = make(chan struct{})
+	fd.mu.Lock()
+	if fd.released {
+		fd.mu.Unlock()
+		return false
+	}
+	fd.msgs = append(fd.msgs, m)
+	fd.mu.Unlock()
+	fd.queue.Notify(waiter.ReadableEvents)
+
+	err := t.Block(m.done)
+
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	if err != nil {
+		fd.removeMessageLocked(m)
+	}
+	// Messages closed by Release weren't read.
+	return err == nil && !fd.released
+}
+
+// Read implements vfs.FileDescriptionImpl.Read.
+func (fd *UserfaultFileDescription) Read(ctx context.Context, dst usermem.IOSequence, opts vfs.ReadOptions) (int64, error) {
+	if dst.NumBytes() < linux.SizeOfUffdMsg {
+		return 0, linuxerr.EINVAL
+	}
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		return 0, linuxerr.EINVAL
+	}
+	fd.mu.Lock()
+	api := fd.api
+	fd.mu.Unlock()
+	if !api {
+		return 0, linuxerr.EINVAL
+	}
+
+	var n int64
+	for dst.NumBytes() >= linux.SizeOfUffdMsg {
+		fd.mu.Lock()
+		if len(fd.msgs) == 0 {
+			fd.mu.Unlock()
+			break
+		}
+		m := fd.msgs[0]
+		fd.msgs = fd.msgs[1:]
+		fd.mu.Unlock()
+
+		msg := m.msg
+		var err error
+		if m.child != nil {
+			var childFD int32
+			if childFD, err = fd.installChild(t, m.child); err == nil {
+				msg.Arg[0] = uint64(uint32(childFD))
+			}
+		}
+		if err == nil {
+			buf := t.CopyScratchBuffer(linux.SizeOfUffdMsg)
+			msg.MarshalUnsafe(buf)
+			_, err = dst.CopyOut(ctx, buf)
+		}
+		if err != nil {
+			// Requeue the message, so that it isn't lost.
+			fd.mu.Lock()
+			fd.msgs = append([]*message{m}, fd.msgs...)
+			fd.mu.Unlock()
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if m.done != nil {
+			close(m.done)
+		}
+		n += linux.SizeOfUffdMsg
+		dst = dst.DropFirst(linux.SizeOfUffdMsg)
+	}
+	if n == 0 {
+		return 0, linuxerr.ErrWouldBlock
+	}
+	return n, nil
+}
+
+// installChild installs the userfaultfd reported by UFFD_EVENT_FORK into the
+// fd table of t.
+func (fd *UserfaultFileDescription) installChild(t *kernel.Task, child *UserfaultFileDescription) (int32, error) {
+	if child.vfsfd.Impl() == nil {
+		if err := child.init(t); err != nil {
+			return 0, err
+		}
+	} else {
+		child.vfsfd.IncRef()
+	}
+	defer child.vfsfd.DecRef(t)
+	childFD, err := t.NewFDFrom(0, &child.vfsfd, kernel.FDFlags{
+		CloseOnExec: fd.flags&linux.O_CLOEXEC != 0,
+	})
+	if err != nil {
+		// The file description is kept for the retried read.
+		child.vfsfd.IncRef()
+	}
+	return childFD, err
+}
+
+// Readiness implements waiter.Waitable.Readiness.
+func (fd *UserfaultFileDescription) Readiness(mask waiter.EventMask) waiter.EventMask {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	if len(fd.msgs) > 0 {
+		return mask & waiter.ReadableEvents
+	}
+	return 0
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (fd *UserfaultFileDescription) EventRegister(e *waiter.Entry) error {
+	fd.queue.EventRegister(e)
+	return nil
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (fd *UserfaultFileDescription) EventUnregister(e *waiter.Entry) {
+	fd.queue.EventUnregister(e)
+}
+
+// Epollable implements vfs.FileDescriptionImpl.Epollable.
+func (fd *UserfaultFileDescription) Epollable() bool {
+	return true
+}
+
+// Ioctl implements vfs.FileDescriptionImpl.Ioctl.
+func (fd *UserfaultFileDescription) Ioctl(ctx context.Context, uio usermem.IO, sysno uintptr, args arch.SyscallArguments) (uintptr, error) {
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		return 0, linuxerr.EINVAL
+	}
+	cmd := args[1].Uint()
+	addr := args[2].Pointer()
+	if cmd == linux.UFFDIO_API {
+		return 0, fd.negotiateAPI(t, addr)
+	}
+
+	fd.mu.Lock()
+	api := fd.api
+	fd.mu.Unlock()
+	if !api {
+		return 0, linuxerr.EINVAL
+	}
+	// Like Linux, fail if the process of the userfaultfd has exited.
+	if !fd.mm.IncUsers() {
+		return 0, linuxerr.ESRCH
+	}
+	defer fd.mm.DecUsers(ctx)
+
+	switch cmd {
+	case linux.UFFDIO_REGISTER:
+		return 0, fd.register(t, addr)
+	case linux.UFFDIO_UNREGISTER:
+		return 0, fd.unregister(t, addr)
+	case linux.UFFDIO_WAKE:
+		var r linux.UffdioRange
+		if _, err := r.CopyIn(t, addr); err != nil {
+			return 0, err
+		}
+		ar, err := addrRange(r)
+		if err != nil {
+			return 0, err
+		}
+		fd.wake(ar)
+		return 0, nil
+	case linux.UFFDIO_COPY:
+		return 0, fd.copy(t, addr)
+	case linux.UFFDIO_ZEROPAGE:
+		return 0, fd.zeropage(t, addr)
+	case linux.UFFDIO_WRITEPROTECT:
+		return 0, fd.writeProtect(t, addr)
+	default:
+		log.Debugf("Unsupported userfaultfd ioctl: %#x", cmd)
+		return 0, linuxerr.EINVAL
+	}
+}
+
+// addrRange returns the page-aligned range r.
+func addrRange(r linux.UffdioRange) (hostarch.AddrRange, error) {
+	start := hostarch.Addr(r.Start)
+	if r.Len == 0 || !start.IsPageAligned() || !hostarch.Addr(r.Len).IsPageAligned() {
+		return hostarch.AddrRange{}, linuxerr.EINVAL
+	}
+	ar, ok := start.ToRange(r.Len)
+	if !ok {
+		return hostarch.AddrRange{}, linuxerr.EINVAL
+	}
+	return ar, nil
+}
+
+// negotiateAPI implements UFFDIO_API.
+func (fd *UserfaultFileDescription) negotiateAPI(t *kernel.Task, addr hostarch.Addr) error {
+	var api linux.UffdioAPI
+	if _, err := api.CopyIn(t, addr); err != nil {
+		return err
+	}
+
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	var err error
+	switch {
+	case fd.api:
+		err = linuxerr.EINVAL
+	case api.API != linux.UFFD_API:
+		err = linuxerr.EINVAL
+	case api.Features&^Features != 0:
+		err = linuxerr.EINVAL
+	}
+	if err != nil {
+		// Linux clears the result on failure.
+		api = linux.UffdioAPI{}
+		if _, cerr := api.CopyOut(t, addr); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	// Only the requested features are enabled, all supported ones are
+	// reported back.
+	requested := api.Features
+	api.Features = Features
+	api.Ioctls = linux.UFFD_API_IOCTLS
+	if _, err := api.CopyOut(t, addr); err != nil {
+		return err
+	}
+	fd.api = true
+	fd.features = requested
+	return nil
+}
+
+// register implements UFFDIO_REGISTER.
+func (fd *UserfaultFileDescription) register(t *kernel.Task, addr hostarch.Addr) error {
+	var reg linux.UffdioRegister
+	if _, err := reg.CopyIn(t, addr); err != nil {
+		return err
+	}
+	if reg.Mode == 0 || reg.Mode&^(linux.UFFDIO_REGISTER_MODE_MISSING|linux.UFFDIO_REGISTER_MODE_WP) != 0 {
+		return linuxerr.EINVAL
+	}
+	ar, err := addrRange(reg.Range)
+	if err != nil {
+		return err
+	}
+	if err := fd.mm.RegisterUserfaultfd(ar, fd, reg.Mode); err != nil {
+		return err
+	}
+	reg.Ioctls = linux.UFFD_API_RANGE_IOCTLS
+	if reg.Mode&linux.UFFDIO_REGISTER_MODE_WP == 0 {
+		reg.Ioctls &^= linux.UFFD_API_RANGE_IOCTLS_WP
+	}
+	_, err = reg.CopyOut(t, addr)
+	return err
+}
+
+// unregister implements UFFDIO_UNREGISTER.
+func (fd *UserfaultFileDescription) unregister(t *kernel.Task, addr hostarch.Addr) error {
+	var r linux.UffdioRange
+	if _, err := r.CopyIn(t, addr); err != nil {
+		return err
+	}
+	ar, err := addrRange(r)
+	if err != nil {
+		return err
+	}
+	if err := fd.mm.UnregisterUserfaultfd(ar, fd); err != nil {
+		return err
+	}
+	// Faults in ar are retried without the userfaultfd.
+	fd.wake(ar)
+	return nil
+}
+
+// copy implements UFFDIO_COPY.
+func (fd *UserfaultFileDescription) copy(t *kernel.Task, addr hostarch.Addr) error {
+	var c linux.UffdioCopy
+	if _, err := c.CopyIn(t, addr); err != nil {
+		return err
+	}
+	if c.Mode&^(linux.UFFDIO_COPY_MODE_DONTWAKE|linux.UFFDIO_COPY_MODE_WP) != 0 {
+		return linuxerr.EINVAL
+	}
+	ar, err := addrRange(linux.UffdioRange{Start: c.Dst, Len: c.Len})
+	if err != nil {
+		return err
+	}
+	if _, ok := hostarch.Addr(c.Src).AddLength(c.Len); !ok {
+		return linuxerr.EINVAL
+	}
+
+	// The source is in the memory of the caller, which isn't necessarily the
+	// process of the userfaultfd.
+	buf := make([]byte, hostarch.PageSize)
+	var copied uint64
+	for copied < c.Len {
+		if _, err = t.CopyInBytes(hostarch.Addr(c.Src+copied), buf); err != nil {
+			break
+		}
+		if err = fd.mm.UserfaultfdFill(t, fd, ar.Start+hostarch.Addr(copied), buf, c.Mode&linux.UFFDIO_COPY_MODE_WP != 0); err != nil {
+			break
+		}
+		copied += hostarch.PageSize
+	}
+	return fd.finishFill(t, addr, ar, copied, err, c.Mode&linux.UFFDIO_COPY_MODE_DONTWAKE == 0, func(res int64) error {
+		c.Copy = res
+		_, err := c.CopyOut(t, addr)
+		return err
+	})
+}
+
+// zeropage implements UFFDIO_ZEROPAGE.
+func (fd *UserfaultFileDescription) zeropage(t *kernel.Task, addr hostarch.Addr) error {
+	var z linux.UffdioZeropage
+	if _, err := z.CopyIn(t, addr); err != nil {
+		return err
+	}
+	if z.Mode&^linux.UFFDIO_ZEROPAGE_MODE_DONTWAKE != 0 {
+		return linuxerr.EINVAL
+	}
+	ar, err := addrRange(z.Range)
+	if err != nil {
+		return err
+	}
+
+	var zeroed uint64
+	for zeroed < z.Range.Len {
+		if err = fd.mm.UserfaultfdFill(t, fd, ar.Start+hostarch.Addr(zeroed), nil, false); err != nil {
+			break
+		}
+		zeroed += hostarch.PageSize
+	}
+	return fd.finishFill(t, addr, ar, zeroed, err, z.Mode&linux.UFFDIO_ZEROPAGE_MODE_DONTWAKE == 0, func(res int64) error {
+		z.Zeropage = res
+		_, err := z.CopyOut(t, addr)
+		return err
+	})
+}
+
+// finishFill reports the result of UFFDIO_COPY or UFFDIO_ZEROPAGE, which
+// filled n bytes of ar before failing with err, with setResult, and wakes
+// faults in the filled pages if wake is true.
+func (fd *UserfaultFileDescription) finishFill(t *kernel.Task, addr hostarch.Addr, ar hostarch.AddrRange, n uint64, err error, wake bool, setResult func(int64) error) error {
+	if n > 0 && wake {
+		fd.wake(hostarch.AddrRange{ar.Start, ar.Start + hostarch.Addr(n)})
+	}
+	if n == 0 && err != nil {
+		if cerr := setResult(-int64(kernel.ExtractErrno(err, -1))); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	if cerr := setResult(int64(n)); cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		// Partial fills are retried by the caller for the rest of the range.
+		return linuxerr.EAGAIN
+	}
+	return nil
+}
+
+// writeProtect implements UFFDIO_WRITEPROTECT.
+func (fd *UserfaultFileDescription) writeProtect(t *kernel.Task, addr hostarch.Addr) error {
+	var wp linux.UffdioWriteprotect
+	if _, err := wp.CopyIn(t, addr); err != nil {
+		return err
+	}
+	if wp.Mode&^(linux.UFFDIO_WRITEPROTECT_MODE_WP|linux.UFFDIO_WRITEPROTECT_MODE_DONTWAKE) != 0 {
+		return linuxerr.EINVAL
+	}
+	protect := wp.Mode&linux.UFFDIO_WRITEPROTECT_MODE_WP != 0
+	// Linux: fs/userfaultfd.c:userfaultfd_writeprotect()
+	if protect && wp.Mode&linux.UFFDIO_WRITEPROTECT_MODE_DONTWAKE != 0 {
+		return linuxerr.EINVAL
+	}
+	ar, err := addrRange(wp.Range)
+	if err != nil {
+		return err
+	}
+	if err := fd.mm.UserfaultfdWriteProtect(fd, ar, protect); err != nil {
+		return err
+	}
+	if !protect && wp.Mode&linux.UFFDIO_WRITEPROTECT_MODE_DONTWAKE == 0 {
+		fd.wake(ar)
+	}
+	return nil
+}
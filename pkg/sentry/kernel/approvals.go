@@ -0,0 +1,332 @@
+package kernel
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/abi/linux/errno"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Decisions of operator on deferred syscall
+const (
+	ApprovalApprove = "approve"
+	ApprovalDeny    = "deny"
+	ApprovalModify  = "modify"
+)
+
+const (
+	// JsDefer is the key of object returned by callback before syscall, which defers the syscall
+	// until operator decides whether it proceeds
+	JsDefer = "defer"
+
+	// JsDeferMessage is the key of message for operator in object returned by callback
+	JsDeferMessage = "message"
+
+	// JsDeferTimeout is the key of timeout (in milliseconds) in object returned by callback
+	JsDeferTimeout = "timeout"
+
+	// JsDeferOnTimeout is the key of decision applied on timeout in object returned by callback
+	JsDeferOnTimeout = "onTimeout"
+)
+
+// defaultApprovalTimeout is used if callback doesn't specify timeout
+const defaultApprovalTimeout = time.Minute
+
+// approvalSubscriberQueueSize is the count of requests which may wait to be sent to a subscriber
+const approvalSubscriberQueueSize = 64
+
+// maxApprovalErrno is MAX_ERRNO of Linux, greater values can't be returned as syscall errors
+const maxApprovalErrno = 4095
+
+// ApprovalRequestDto is pushed to runtime socket subscribers when the syscall is deferred
+type ApprovalRequestDto struct {
+	ID        uint64   `json:"id"`
+	Container string   `json:"container"`
+	Tid       ThreadID `json:"tid"`
+	Sysno     uintptr  `json:"sysno"`
+	Name      string   `json:"name"`
+	Message   string   `json:"message"`
+	Callback  string   `json:"callback"`
+
+	// Args are syscall arguments, path and sockaddr arguments of known syscalls are decoded
+	Args []ApprovalArgDto `json:"args"`
+}
+
+// ApprovalArgDto is the syscall argument of deferred syscall
+type ApprovalArgDto struct {
+	Value uint64 `json:"value"`
+
+	// Path is the path argument resolved with cwd or dirfd like for syscall rules, if the argument is path
+	Path string `json:"path,omitempty"`
+
+	// Sockaddr is the decoded address, if the argument is pointer to struct sockaddr
+	Sockaddr *SockaddrDto `json:"sockaddr,omitempty"`
+}
+
+// ApprovalReplyDto is the decision of operator on deferred syscall
+type ApprovalReplyDto struct {
+	ID       uint64 `json:"id"`
+	Decision string `json:"decision"`
+
+	// Args maps indexes of syscall arguments to new values, is used with modify decision
+	Args map[string]uint64 `json:"args,omitempty"`
+
+	// Errno is returned by the syscall with deny decision, EPERM if not set.
+	// Should be in [1, maxApprovalErrno]
+	Errno uintptr `json:"errno,omitempty"`
+}
+
+// approvalRequest is set on the task by callback, which deferred the syscall
+type approvalRequest struct {
+	message          string
+	callback         string
+	timeout          time.Duration
+	approveOnTimeout bool
+}
+
+type pendingApproval struct {
+	dto ApprovalRequestDto
+
+	// done is closed when reply is set
+	done  chan struct{}
+	reply ApprovalReplyDto
+}
+
+// ApprovalBroker passes deferred syscalls to runtime socket subscribers and decisions back to tasks
+type ApprovalBroker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	pending     map[uint64]*pendingApproval
+	subscribers map[chan ApprovalRequestDto]struct{}
+}
+
+func newApprovalBroker() *ApprovalBroker {
+	return &ApprovalBroker{
+		pending:     make(map[uint64]*pendingApproval),
+		subscribers: make(map[chan ApprovalRequestDto]struct{}),
+	}
+}
+
+// submit registers the request and pushes it to subscribers. Subscribers which are
+// too slow miss the request, but still may get it with pending-approvals command
+func (b *ApprovalBroker) submit(dto ApprovalRequestDto) *pendingApproval {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	dto.ID = b.nextID
+	pending := &pendingApproval{dto: dto, done: make(chan struct{})}
+	b.pending[dto.ID] = pending
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- dto:
+		default:
+		}
+	}
+	return pending
+}
+
+// cancel removes the request, which is no longer waited by the task
+func (b *ApprovalBroker) cancel(id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.pending, id)
+}
+
+// reply passes the decision to the waiting task
+func (b *ApprovalBroker) reply(reply ApprovalReplyDto) error {
+	switch reply.Decision {
+	case ApprovalApprove:
+	case ApprovalDeny:
+		if reply.Errno > maxApprovalErrno {
+			return fmt.Errorf("invalid errno %d", reply.Errno)
+		}
+	case ApprovalModify:
+		for key := range reply.Args {
+			if ind, err := strconv.Atoi(key); err != nil || ind < 0 || ind >= len(arch.SyscallArguments{}) {
+				return fmt.Errorf("invalid index of syscall argument %q", key)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown decision [%s]", reply.Decision)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending, ok := b.pending[reply.ID]
+	if !ok {
+		return fmt.Errorf("no pending approval with id %d", reply.ID)
+	}
+	delete(b.pending, reply.ID)
+	pending.reply = reply
+	close(pending.done)
+	return nil
+}
+
+// pendingRequests returns requests which are waiting for decision
+func (b *ApprovalBroker) pendingRequests() []ApprovalRequestDto {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	requests := make([]ApprovalRequestDto, 0, len(b.pending))
+	for _, pending := range b.pending {
+		requests = append(requests, pending.dto)
+	}
+	slices.SortFunc(requests, func(a, b ApprovalRequestDto) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return requests
+}
+
+func (b *ApprovalBroker) subscribe() chan ApprovalRequestDto {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscriber := make(chan ApprovalRequestDto, approvalSubscriberQueueSize)
+	b.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (b *ApprovalBroker) unsubscribe(subscriber chan ApprovalRequestDto) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, subscriber)
+}
+
+// extractApprovalRequestFromRetJsValue returns approvalRequest if callback returned {defer: true, ...}
+func extractApprovalRequestFromRetJsValue(vm *goja.Runtime, value goja.Value,
+	info *callbacks.JsCallbackInfo) (*approvalRequest, error) {
+
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, nil
+	}
+	obj := value.ToObject(vm)
+	if !slices.Contains(obj.Keys(), JsDefer) || !obj.Get(JsDefer).ToBoolean() {
+		return nil, nil
+	}
+
+	request, err := newApprovalRequest(info)
+	if err != nil {
+		return nil, err
+	}
+
+	if message := obj.Get(JsDeferMessage); message != nil && !goja.IsUndefined(message) {
+		request.message = message.String()
+	}
+	if timeout := obj.Get(JsDeferTimeout); timeout != nil && !goja.IsUndefined(timeout) {
+		ms, err := callbacks.ExtractInt64FromValue(vm, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if ms <= 0 {
+			return nil, errors.New("timeout of deferred syscall should be positive")
+		}
+		request.timeout = time.Duration(ms) * time.Millisecond
+	}
+	if onTimeout := obj.Get(JsDeferOnTimeout); onTimeout != nil && !goja.IsUndefined(onTimeout) {
+		switch onTimeout.String() {
+		case ApprovalApprove:
+			request.approveOnTimeout = true
+		case ApprovalDeny:
+		default:
+			return nil, fmt.Errorf("%s should be %s or %s", JsDeferOnTimeout, ApprovalApprove, ApprovalDeny)
+		}
+	}
+	return request, nil
+}
+
+// newApprovalRequest returns approvalRequest with default settings. Only callbacks before syscall can defer it
+func newApprovalRequest(info *callbacks.JsCallbackInfo) (*approvalRequest, error) {
+	if info == nil || info.Type != JsCallbackTypeBefore {
+		return nil, errors.New("syscall may be deferred only by callbacks executed before syscall")
+	}
+	return &approvalRequest{callback: info.EntryPoint, timeout: defaultApprovalTimeout}, nil
+}
+
+// waitForApproval parks t until operator decides on the deferred syscall or the timeout expires.
+// The wait is interruptible: if t is interrupted, the syscall is restarted and deferred again.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) waitForApproval(sysno uintptr,
+	args *arch.SyscallArguments) (*arch.SyscallArguments, *SyscallReturnValue, error) {
+
+	request := t.approvalRequest
+	t.approvalRequest = nil
+
+	dto := ApprovalRequestDto{
+		Container: t.ContainerID(),
+		Tid:       t.ThreadID(),
+		Sysno:     sysno,
+		Name:      t.SyscallTable().LookupName(sysno),
+		Message:   request.message,
+		Callback:  request.callback,
+		Args:      decodeApprovalArgs(t, sysno, args),
+	}
+
+	broker := GetJsRuntime().approvals
+	pending := broker.submit(dto)
+	if _, err := t.BlockWithTimeout(pending.done, true, request.timeout); err != nil {
+		broker.cancel(pending.dto.ID)
+		if !linuxerr.Equals(linuxerr.ETIMEDOUT, err) {
+			return args, nil, linuxerr.ERESTARTSYS
+		}
+		if request.approveOnTimeout {
+			return args, nil, nil
+		}
+		return args, &SyscallReturnValue{errno: uintptr(errno.EPERM)}, nil
+	}
+
+	reply := pending.reply
+	switch reply.Decision {
+	case ApprovalDeny:
+		if reply.Errno == 0 {
+			reply.Errno = uintptr(errno.EPERM)
+		}
+		return args, &SyscallReturnValue{errno: reply.Errno}, nil
+
+	case ApprovalModify:
+		newArgs := *args
+		for key, val := range reply.Args {
+			// Indexes are validated by ApprovalBroker.reply
+			ind, _ := strconv.Atoi(key)
+			newArgs[ind].Value = uintptr(val)
+		}
+		return &newArgs, nil, nil
+	}
+
+	return args, nil, nil
+}
+
+// decodeApprovalArgs returns args of the syscall with path and sockaddr arguments decoded
+// like for syscall rules (see rulePathArgs and ruleSockaddrArgs)
+func decodeApprovalArgs(t *Task, sysno uintptr, args *arch.SyscallArguments) []ApprovalArgDto {
+	decoded := make([]ApprovalArgDto, len(args))
+	for i, arg := range args {
+		decoded[i].Value = arg.Uint64()
+	}
+
+	s := &ruleSyscall{t: t, sysno: sysno, args: args}
+	if arg, ok := s.pathArg(); ok {
+		if path, ok := s.decodedPath(); ok {
+			decoded[arg.path].Path = path
+		}
+	}
+	if arg, ok := ruleSockaddrArgs[t.SyscallTable().LookupName(sysno)]; ok {
+		if sockaddr, ok := s.decodedSockaddr(); ok {
+			decoded[arg.addr].Sockaddr = &sockaddr
+		}
+	}
+	return decoded
+}
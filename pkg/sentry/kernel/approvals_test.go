@@ -0,0 +1,119 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+	"time"
+)
+
+func TestApprovalBroker_reply(t *testing.T) {
+	broker := newApprovalBroker()
+	subscriber := broker.subscribe()
+	defer broker.unsubscribe(subscriber)
+
+	pending := broker.submit(ApprovalRequestDto{Sysno: 2, Message: "open"})
+	pushed := <-subscriber
+	if pushed.ID != pending.dto.ID || pushed.Message != "open" {
+		t.Fatalf("bad request pushed to subscriber: %v", pushed)
+	}
+	if requests := broker.pendingRequests(); len(requests) != 1 || requests[0].ID != pending.dto.ID {
+		t.Fatalf("bad pending requests: %v", requests)
+	}
+
+	err := broker.reply(ApprovalReplyDto{ID: pending.dto.ID, Decision: ApprovalModify, Args: map[string]uint64{"1": 7}})
+	if err != nil {
+		t.Fatalf("unexpected error while replying: %v", err)
+	}
+	select {
+	case <-pending.done:
+	default:
+		t.Fatalf("pending approval isn't done after reply")
+	}
+	if pending.reply.Args["1"] != 7 {
+		t.Fatalf("bad reply: %v", pending.reply)
+	}
+	if requests := broker.pendingRequests(); len(requests) != 0 {
+		t.Fatalf("replied request is still pending: %v", requests)
+	}
+
+	err = broker.reply(ApprovalReplyDto{ID: pending.dto.ID, Decision: ApprovalApprove})
+	if err == nil {
+		t.Fatalf("no error while replying twice")
+	}
+}
+
+func TestApprovalBroker_reply_invalid(t *testing.T) {
+	broker := newApprovalBroker()
+	pending := broker.submit(ApprovalRequestDto{})
+
+	if err := broker.reply(ApprovalReplyDto{ID: pending.dto.ID, Decision: "maybe"}); err == nil {
+		t.Fatalf("no error for unknown decision")
+	}
+	err := broker.reply(ApprovalReplyDto{ID: pending.dto.ID, Decision: ApprovalModify, Args: map[string]uint64{"6": 0}})
+	if err == nil {
+		t.Fatalf("no error for invalid index of syscall argument")
+	}
+
+	for _, errno := range []uintptr{maxApprovalErrno + 1, 1 << 40} {
+		if err := broker.reply(ApprovalReplyDto{ID: pending.dto.ID, Decision: ApprovalDeny, Errno: errno}); err == nil {
+			t.Fatalf("no error for invalid errno %d", errno)
+		}
+	}
+
+	broker.cancel(pending.dto.ID)
+	if err := broker.reply(ApprovalReplyDto{ID: pending.dto.ID, Decision: ApprovalApprove}); err == nil {
+		t.Fatalf("no error for cancelled request")
+	}
+}
+
+var deferringCallback = `
+	function cb() {
+		return {defer: true, message: "check it", timeout: 500, onTimeout: "approve"}
+	}
+`
+
+func testRunCallbackOfType(task *Task, source string, cbType string) error {
+	args := arch.SyscallArguments{}
+	cb := JsCallbackBefore{info: callbacks.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: source,
+		CallbackBody:   source,
+		CallbackArgs:   []string{},
+		Type:           cbType,
+		EntryPoint:     "cb",
+	}}
+
+	_, _, err := RunAbstractCallback(task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	return err
+}
+
+func TestRunAbstractCallback_defersSyscall(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	if err := testRunCallbackOfType(&task, deferringCallback, JsCallbackTypeBefore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request := task.approvalRequest
+	if request == nil {
+		t.Fatalf("syscall isn't deferred")
+	}
+	if request.message != "check it" || request.timeout != 500*time.Millisecond || !request.approveOnTimeout {
+		t.Fatalf("bad approval request: %+v", *request)
+	}
+	if request.callback != "cb" {
+		t.Fatalf("bad callback of approval request: %s", request.callback)
+	}
+}
+
+func TestRunAbstractCallback_defersSyscallInCallbackAfter_Fails(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	if err := testRunCallbackOfType(&task, deferringCallback, JsCallbackTypeAfter); err == nil {
+		t.Fatalf("no error when syscall is deferred by callback after")
+	}
+}
@@ -0,0 +1,170 @@
+package kernel
+
+import (
+	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JsRateLimited is the key of args object which contains count of invocations
+// skipped before the current one due to sample-rate and max-per-second settings
+const JsRateLimited = "rate-limited"
+
+// callbackLimiter decides which invocations of the callback should be skipped according to
+// sample-rate and max-per-second settings of the callback. It is checked before entering js runtime
+type callbackLimiter struct {
+	// every is N for sampling one of every N invocations, 0 if not used
+	every uint64
+
+	// probability of invocation to be sampled, 0 if not used
+	probability float64
+
+	// maxPerSecond is the max count of invocations per second, 0 if not limited
+	maxPerSecond int
+
+	// calls is the count of all invocations
+	calls atomic.Uint64
+
+	// skipped is the count of all skipped invocations
+	skipped atomic.Uint64
+
+	// pending is the count of invocations skipped since the last executed one
+	pending atomic.Uint64
+
+	// mu protects window and windowCount
+	mu          sync.Mutex
+	window      int64
+	windowCount int
+}
+
+// newCallbackLimiter returns nil if info has no limits. Sample rate 1 executes every invocation.
+//
+// Preconditions: info.ValidateLimits() succeeds.
+func newCallbackLimiter(info *callbacks.JsCallbackInfo) *callbackLimiter {
+	if (info.SampleRate == 0 || info.SampleRate == 1) && info.MaxPerSecond == 0 {
+		return nil
+	}
+
+	limiter := &callbackLimiter{maxPerSecond: info.MaxPerSecond}
+	if info.SampleRate > 1 {
+		limiter.every = uint64(info.SampleRate)
+	} else if info.SampleRate > 0 && info.SampleRate < 1 {
+		limiter.probability = info.SampleRate
+	}
+	return limiter
+}
+
+// allow returns true if the invocation should be executed and counts it as skipped otherwise
+func (l *callbackLimiter) allow() bool {
+	n := l.calls.Add(1)
+
+	sampled := true
+	if l.every > 1 {
+		sampled = (n-1)%l.every == 0
+	} else if l.probability > 0 {
+		sampled = rand.Float64() < l.probability
+	}
+	if sampled && l.maxPerSecond > 0 {
+		sampled = l.allowInWindow(time.Now().Unix())
+	}
+
+	if !sampled {
+		l.skipped.Add(1)
+		l.pending.Add(1)
+	}
+	return sampled
+}
+
+func (l *callbackLimiter) allowInWindow(now int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now != l.window {
+		l.window, l.windowCount = now, 0
+	}
+	if l.windowCount >= l.maxPerSecond {
+		return false
+	}
+	l.windowCount++
+	return true
+}
+
+// skip counts the invocation, which was allowed, but couldn't be executed
+func (l *callbackLimiter) skip() {
+	l.skipped.Add(1)
+	l.pending.Add(1)
+}
+
+// takePending returns count of invocations skipped since the last call of takePending
+func (l *callbackLimiter) takePending() uint64 {
+	return l.pending.Swap(0)
+}
+
+// rateLimitedMarker adds to args count of invocations skipped before the current one
+type rateLimitedMarker uint64
+
+func (m rateLimitedMarker) addSelfToContextObject(object *goja.Object) error {
+	return object.Set(JsRateLimited, int64(m))
+}
+
+// limitedCallbackBefore is CallbackBefore, which invocations may be skipped by limiter
+type limitedCallbackBefore struct {
+	CallbackBefore
+	limiter *callbackLimiter
+}
+
+func (l *limitedCallbackBefore) CallbackBeforeFunc(t *Task, sysno uintptr,
+	args *arch.SyscallArguments) (*arch.SyscallArguments, *SyscallReturnValue, error) {
+
+	t.skippedCallbacks = l.limiter.takePending()
+	defer func() { t.skippedCallbacks = 0 }()
+	return l.CallbackBefore.CallbackBeforeFunc(t, sysno, args)
+}
+
+func (l *limitedCallbackBefore) Info() callbacks.JsCallbackInfo {
+	info := l.CallbackBefore.Info()
+	info.Skipped = l.limiter.skipped.Load()
+	return info
+}
+
+// limitedCallbackAfter is CallbackAfter, which invocations may be skipped by limiter
+type limitedCallbackAfter struct {
+	CallbackAfter
+	limiter *callbackLimiter
+}
+
+func (l *limitedCallbackAfter) CallbackAfterFunc(t *Task, sysno uintptr, args *arch.SyscallArguments,
+	ret uintptr, err error) (*arch.SyscallArguments, *SyscallReturnValue, error) {
+
+	t.skippedCallbacks = l.limiter.takePending()
+	defer func() { t.skippedCallbacks = 0 }()
+	return l.CallbackAfter.CallbackAfterFunc(t, sysno, args, ret, err)
+}
+
+func (l *limitedCallbackAfter) Info() callbacks.JsCallbackInfo {
+	info := l.CallbackAfter.Info()
+	info.Skipped = l.limiter.skipped.Load()
+	return info
+}
+
+// withLimiterBefore wraps f into limitedCallbackBefore if f has limits
+func withLimiterBefore(f CallbackBefore) CallbackBefore {
+	info := f.Info()
+	if limiter := newCallbackLimiter(&info); limiter != nil {
+		return &limitedCallbackBefore{CallbackBefore: f, limiter: limiter}
+	}
+	return f
+}
+
+// withLimiterAfter wraps f into limitedCallbackAfter if f has limits
+func withLimiterAfter(f CallbackAfter) CallbackAfter {
+	info := f.Info()
+	if limiter := newCallbackLimiter(&info); limiter != nil {
+		return &limitedCallbackAfter{CallbackAfter: f, limiter: limiter}
+	}
+	return f
+}
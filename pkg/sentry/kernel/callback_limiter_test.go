@@ -0,0 +1,143 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"math"
+	"testing"
+)
+
+func TestCallbackLimiter_withoutLimits(t *testing.T) {
+	if limiter := newCallbackLimiter(&callbacks.JsCallbackInfo{}); limiter != nil {
+		t.Fatalf("limiter is created for callback without limits")
+	}
+}
+
+func TestCallbackLimiter_sampleEveryN(t *testing.T) {
+	limiter := newCallbackLimiter(&callbacks.JsCallbackInfo{SampleRate: 3})
+
+	var allowed []bool
+	for i := 0; i < 6; i++ {
+		allowed = append(allowed, limiter.allow())
+	}
+	expected := []bool{true, false, false, true, false, false}
+	for i := range expected {
+		if allowed[i] != expected[i] {
+			t.Fatalf("bad sampling: got %v, expected %v", allowed, expected)
+		}
+	}
+
+	if skipped := limiter.skipped.Load(); skipped != 4 {
+		t.Fatalf("bad count of skipped invocations: got %d, expected 4", skipped)
+	}
+	if pending := limiter.takePending(); pending != 4 {
+		t.Fatalf("bad count of pending skipped invocations: got %d, expected 4", pending)
+	}
+	if pending := limiter.takePending(); pending != 0 {
+		t.Fatalf("pending skipped invocations are not reset: got %d", pending)
+	}
+}
+
+func TestCallbackLimiter_maxPerSecond(t *testing.T) {
+	limiter := newCallbackLimiter(&callbacks.JsCallbackInfo{MaxPerSecond: 2})
+
+	if !limiter.allowInWindow(10) || !limiter.allowInWindow(10) {
+		t.Fatalf("invocations within limit are skipped")
+	}
+	if limiter.allowInWindow(10) {
+		t.Fatalf("invocation over limit is allowed")
+	}
+	if !limiter.allowInWindow(11) {
+		t.Fatalf("invocation in the next second is skipped")
+	}
+}
+
+type testLimitedCbBefore struct {
+	testCbBefore
+}
+
+func (testLimitedCbBefore) Info() callbacks.JsCallbackInfo {
+	return callbacks.JsCallbackInfo{SampleRate: 2}
+}
+
+func TestCallbackTable_getCallbackBefore_skipsSampledOut(t *testing.T) {
+	cbt := initCallbackTable()
+	_ = cbt.registerCallbackBefore(1, testLimitedCbBefore{})
+
+	if cbt.getCallbackBefore(1) == nil {
+		t.Fatalf("first invocation is skipped")
+	}
+	if cbt.getCallbackBefore(1) != nil {
+		t.Fatalf("second invocation isn't skipped")
+	}
+
+	cb := cbt.getCallbackBefore(1)
+	if cb == nil {
+		t.Fatalf("third invocation is skipped")
+	}
+	if skipped := cb.Info().Skipped; skipped != 1 {
+		t.Fatalf("bad count of skipped invocations in info: got %d, expected 1", skipped)
+	}
+
+	task := testCreateEmptyTask()
+	args := arch.SyscallArguments{}
+	_, _, _ = cb.CallbackBeforeFunc(&task, 1, &args)
+	if limited := cb.(*limitedCallbackBefore); limited.limiter.pending.Load() != 0 {
+		t.Fatalf("pending skipped invocations are not passed to the callback")
+	}
+	if task.skippedCallbacks != 0 {
+		t.Fatalf("skipped invocations marker is not reset after the callback")
+	}
+}
+
+var rateLimitedMarkerSource = `
+	function cb() {
+		return {ret: args["rate-limited"], errno: 0}
+	}
+`
+
+func TestRunAbstractCallback_passesRateLimitedMarker(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+	task.skippedCallbacks = 3
+	args := arch.SyscallArguments{}
+	cb := JsCallbackBefore{info: callbacks.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: rateLimitedMarkerSource,
+		CallbackBody:   rateLimitedMarkerSource,
+		CallbackArgs:   []string{},
+		Type:           JsCallbackTypeBefore,
+		EntryPoint:     "cb",
+	}}
+
+	_, sub, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sub == nil || sub.returnValue != 3 {
+		t.Fatalf("bad rate-limited marker: got %v, expected 3", sub)
+	}
+}
+
+func TestCallbackLimiter_sampleRateOne(t *testing.T) {
+	if limiter := newCallbackLimiter(&callbacks.JsCallbackInfo{SampleRate: 1}); limiter != nil {
+		t.Fatalf("limiter is created for sample rate 1")
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	for _, rate := range []float64{0, 0.5, 1, 2, 1000} {
+		if err := (&callbacks.JsCallbackInfo{SampleRate: rate}).ValidateLimits(); err != nil {
+			t.Errorf("sample rate %v is rejected: %v", rate, err)
+		}
+	}
+	for _, rate := range []float64{-1, 1.5, 2.5, math.Inf(1), math.NaN()} {
+		if err := (&callbacks.JsCallbackInfo{SampleRate: rate}).ValidateLimits(); err == nil {
+			t.Errorf("no error for sample rate %v", rate)
+		}
+	}
+	if err := (&callbacks.JsCallbackInfo{MaxPerSecond: -1}).ValidateLimits(); err == nil {
+		t.Errorf("no error for negative max-per-second")
+	}
+}
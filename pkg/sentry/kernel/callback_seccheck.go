@@ -0,0 +1,73 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"gvisor.dev/gvisor/pkg/sentry/seccheck"
+	pb "gvisor.dev/gvisor/pkg/sentry/seccheck/points/points_go_proto"
+)
+
+// callbackWithInfo is implemented by CallbackBefore, CallbackAfter and CallbackEmulate
+type callbackWithInfo interface {
+	Info() callbacks.JsCallbackInfo
+}
+
+// newJsCallbackPoint returns the message of sentry/js_callback point for the executed callback.
+// newArgs and sub are values returned by the callback, cbErr is its error
+func newJsCallbackPoint(info callbacks.JsCallbackInfo, sysno uintptr, args *arch.SyscallArguments,
+	newArgs *arch.SyscallArguments, sub *SyscallReturnValue, cbErr error) *pb.JsCallback {
+
+	msg := &pb.JsCallback{
+		Sysno:      uint64(sysno),
+		EntryPoint: info.EntryPoint,
+		Type:       info.Type,
+		Engine:     info.Engine,
+	}
+	if msg.Engine == "" {
+		msg.Engine = callbacks.EngineJs
+	}
+
+	for _, arg := range args {
+		msg.Args = append(msg.Args, arg.Uint64())
+	}
+	if cbErr != nil {
+		msg.Error = cbErr.Error()
+		return msg
+	}
+
+	if newArgs != nil && *newArgs != *args {
+		for _, arg := range newArgs {
+			msg.ModifiedArgs = append(msg.ModifiedArgs, arg.Uint64())
+		}
+	}
+	if sub != nil {
+		msg.Substituted = true
+		msg.ReturnValue = int64(sub.returnValue)
+		msg.Errorno = int64(sub.errno)
+	}
+	return msg
+}
+
+// emitJsCallbackPoint sends the decision of the executed callback to seccheck sinks,
+// so syscall tampering can be audited together with other trace points
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) emitJsCallbackPoint(cb callbackWithInfo, sysno uintptr, args *arch.SyscallArguments,
+	newArgs *arch.SyscallArguments, sub *SyscallReturnValue, cbErr error) {
+
+	if !seccheck.Global.Enabled(seccheck.PointJsCallback) {
+		return
+	}
+
+	info := newJsCallbackPoint(cb.Info(), sysno, args, newArgs, sub, cbErr)
+	info.Deferred = cbErr == nil && t.approvalRequest != nil
+
+	fields := seccheck.Global.GetFieldSet(seccheck.PointJsCallback)
+	if !fields.Context.Empty() {
+		info.ContextData = &pb.ContextData{}
+		LoadSeccheckData(t, fields.Context, info.ContextData)
+	}
+	seccheck.Global.SentToSinks(func(c seccheck.Sink) error {
+		return c.JsCallback(t, fields, info)
+	})
+}
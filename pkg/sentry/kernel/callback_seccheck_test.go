@@ -0,0 +1,50 @@
+package kernel
+
+import (
+	"errors"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+)
+
+func TestNewJsCallbackPoint_ModifiedArgsAndSubstitution(t *testing.T) {
+	info := callbacks.JsCallbackInfo{EntryPoint: "cb", Type: JsCallbackTypeBefore}
+	args := arch.SyscallArguments{{Value: 1}, {Value: 2}}
+	newArgs := args
+	newArgs[1].Value = 42
+
+	msg := newJsCallbackPoint(info, 2, &args, &newArgs, &SyscallReturnValue{returnValue: 3, errno: 13}, nil)
+	if msg.Sysno != 2 || msg.EntryPoint != "cb" || msg.Type != JsCallbackTypeBefore || msg.Engine != callbacks.EngineJs {
+		t.Fatalf("bad callback info in message: %v", msg)
+	}
+	if len(msg.Args) != len(args) || msg.Args[1] != 2 {
+		t.Fatalf("bad original args: %v", msg.Args)
+	}
+	if len(msg.ModifiedArgs) != len(args) || msg.ModifiedArgs[1] != 42 {
+		t.Fatalf("bad modified args: %v", msg.ModifiedArgs)
+	}
+	if !msg.Substituted || msg.ReturnValue != 3 || msg.Errorno != 13 {
+		t.Fatalf("bad substitution: %v", msg)
+	}
+}
+
+func TestNewJsCallbackPoint_UnchangedArgs(t *testing.T) {
+	info := callbacks.JsCallbackInfo{EntryPoint: "cb", Type: JsCallbackTypeAfter, Engine: callbacks.EngineWasm}
+	args := arch.SyscallArguments{{Value: 1}}
+	newArgs := args
+
+	msg := newJsCallbackPoint(info, 0, &args, &newArgs, nil, nil)
+	if len(msg.ModifiedArgs) != 0 || msg.Substituted || msg.Engine != callbacks.EngineWasm {
+		t.Fatalf("callback which didn't change syscall is reported as changing: %v", msg)
+	}
+}
+
+func TestNewJsCallbackPoint_Error(t *testing.T) {
+	info := callbacks.JsCallbackInfo{EntryPoint: "cb", Type: JsCallbackTypeEmulate}
+	args := arch.SyscallArguments{}
+
+	msg := newJsCallbackPoint(info, 0, &args, nil, nil, errors.New("failed"))
+	if msg.Error != "failed" || msg.Substituted || len(msg.ModifiedArgs) != 0 {
+		t.Fatalf("bad message for failed callback: %v", msg)
+	}
+}
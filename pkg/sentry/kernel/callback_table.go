@@ -24,6 +24,16 @@ type CallbackTable struct {
 
 	// mutexAfter is sync.Mutex used to sync callbackAfter
 	mutexAfter sync.Mutex
+
+	// callbackEmulate is a map of:
+	//	key - sysno (uintptr)
+	//	val - CallbackEmulate
+	//
+	// emulate callbacks are used only for syscalls without implementation in gVisor
+	callbackEmulate map[uintptr]CallbackEmulate
+
+	// mutexEmulate is sync.Mutex used to sync callbackEmulate
+	mutexEmulate sync.Mutex
 }
 
 func (ct *CallbackTable) registerCallbackBefore(sysno uintptr, f CallbackBefore) error {
@@ -33,7 +43,7 @@ func (ct *CallbackTable) registerCallbackBefore(sysno uintptr, f CallbackBefore)
 	ct.mutexBefore.Lock()
 	defer ct.mutexBefore.Unlock()
 
-	ct.callbackBefore[sysno] = f
+	ct.callbackBefore[sysno] = withLimiterBefore(f)
 	return nil
 }
 
@@ -44,19 +54,33 @@ func (ct *CallbackTable) registerCallbackAfter(sysno uintptr, f CallbackAfter) e
 	ct.mutexAfter.Lock()
 	defer ct.mutexAfter.Unlock()
 
-	ct.callbackAfter[sysno] = f
+	ct.callbackAfter[sysno] = withLimiterAfter(f)
+	return nil
+}
+
+func (ct *CallbackTable) registerCallbackEmulate(sysno uintptr, f CallbackEmulate) error {
+	if f == nil {
+		return errors.New("callback func is nil")
+	}
+	ct.mutexEmulate.Lock()
+	defer ct.mutexEmulate.Unlock()
+
+	ct.callbackEmulate[sysno] = f
 	return nil
 }
 
 func (ct *CallbackTable) UnregisterAll() {
 	ct.mutexBefore.Lock()
 	ct.mutexAfter.Lock()
+	ct.mutexEmulate.Lock()
 
+	defer ct.mutexEmulate.Unlock()
 	defer ct.mutexAfter.Unlock()
 	defer ct.mutexBefore.Unlock()
 
 	ct.callbackAfter = map[uintptr]CallbackAfter{}
 	ct.callbackBefore = map[uintptr]CallbackBefore{}
+	ct.callbackEmulate = map[uintptr]CallbackEmulate{}
 }
 
 func (ct *CallbackTable) registerCallbackBeforeNoLock(sysno uintptr, f CallbackBefore) error {
@@ -64,7 +88,7 @@ func (ct *CallbackTable) registerCallbackBeforeNoLock(sysno uintptr, f CallbackB
 		return errors.New("callback func is nil")
 	}
 
-	ct.callbackBefore[sysno] = f
+	ct.callbackBefore[sysno] = withLimiterBefore(f)
 	return nil
 }
 
@@ -73,7 +97,7 @@ func (ct *CallbackTable) registerCallbackAfterNoLock(sysno uintptr, f CallbackAf
 		return errors.New("callback func is nil")
 	}
 
-	ct.callbackAfter[sysno] = f
+	ct.callbackAfter[sysno] = withLimiterAfter(f)
 	return nil
 }
 
@@ -103,12 +127,29 @@ func (ct *CallbackTable) unregisterCallbackAfter(sysno uintptr) error {
 	return nil
 }
 
+func (ct *CallbackTable) unregisterCallbackEmulate(sysno uintptr) error {
+	ct.mutexEmulate.Lock()
+	defer ct.mutexEmulate.Unlock()
+
+	_, ok := ct.callbackEmulate[sysno]
+	if !ok {
+		return errors.New(fmt.Sprintf("emulate-callback with sysno %v not exist", sysno))
+	}
+
+	delete(ct.callbackEmulate, sysno)
+	return nil
+}
+
 func (ct *CallbackTable) getCallbackBefore(sysno uintptr) CallbackBefore {
 	ct.mutexBefore.Lock()
 
 	f, ok := ct.callbackBefore[sysno]
 	ct.mutexBefore.Unlock()
 	if ok && f != nil {
+		// Skipped invocations don't enter js runtime
+		if limited, isLimited := f.(*limitedCallbackBefore); isLimited && !limited.limiter.allow() {
+			return nil
+		}
 		return f
 	} else {
 		return nil
@@ -120,6 +161,22 @@ func (ct *CallbackTable) getCallbackAfter(sysno uintptr) CallbackAfter {
 
 	f, ok := ct.callbackAfter[sysno]
 	ct.mutexAfter.Unlock()
+	if ok && f != nil {
+		// Skipped invocations don't enter js runtime
+		if limited, isLimited := f.(*limitedCallbackAfter); isLimited && !limited.limiter.allow() {
+			return nil
+		}
+		return f
+	} else {
+		return nil
+	}
+}
+
+func (ct *CallbackTable) getCallbackEmulate(sysno uintptr) CallbackEmulate {
+	ct.mutexEmulate.Lock()
+
+	f, ok := ct.callbackEmulate[sysno]
+	ct.mutexEmulate.Unlock()
 	if ok && f != nil {
 		return f
 	} else {
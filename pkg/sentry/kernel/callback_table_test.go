@@ -61,10 +61,25 @@ func (testCbAfter) Info() callbacks.JsCallbackInfo {
 	return callbacks.JsCallbackInfo{}
 }
 
+type testCbEmulate struct{}
+
+func (testCbEmulate) CallbackEmulateFunc(
+	t *Task,
+	sysno uintptr,
+	args *arch.SyscallArguments,
+) (*SyscallReturnValue, error) {
+	return &SyscallReturnValue{}, nil
+}
+
+func (testCbEmulate) Info() callbacks.JsCallbackInfo {
+	return callbacks.JsCallbackInfo{}
+}
+
 func initCallbackTable() CallbackTable {
 	return CallbackTable{
-		callbackBefore: make(map[uintptr]CallbackBefore),
-		callbackAfter:  make(map[uintptr]CallbackAfter),
+		callbackBefore:  make(map[uintptr]CallbackBefore),
+		callbackAfter:   make(map[uintptr]CallbackAfter),
+		callbackEmulate: make(map[uintptr]CallbackEmulate),
 	}
 }
 
@@ -194,3 +209,38 @@ func TestCallbackTable_getCallbackAfter(t *testing.T) {
 		t.Fatalf("registered and got callbacks differs")
 	}
 }
+
+func TestCallbackTable_registerCallbackEmulate(t *testing.T) {
+	cbt := initCallbackTable()
+
+	f := testCbEmulate{}
+
+	err := cbt.registerCallbackEmulate(1, f)
+	if err != nil {
+		t.Fatalf("unexpected error while adding non nil callbackEmulate")
+	}
+
+	cb := cbt.getCallbackEmulate(1)
+	if cb != f {
+		t.Fatalf("registered and got callbacks differs")
+	}
+
+	err = cbt.registerCallbackEmulate(2, nil)
+	if err == nil {
+		t.Fatalf("registering nil callbackEmulate")
+	}
+
+	err = cbt.unregisterCallbackEmulate(1)
+	if err != nil {
+		t.Fatalf("unexpected failure of unregistering callbackEmulate")
+	}
+
+	if cbt.getCallbackEmulate(1) != nil {
+		t.Fatalf("Bad unregistering. CallbackEmulate is still there.")
+	}
+
+	err = cbt.unregisterCallbackEmulate(1)
+	if err == nil {
+		t.Fatalf("unregistered not existed callbackEmulate")
+	}
+}
@@ -44,6 +44,22 @@ type CallbackAfter interface {
 	Info() callbacks.JsCallbackInfo
 }
 
+// CallbackEmulate - interface which is used to implement syscalls which have no implementation in gVisor
+type CallbackEmulate interface {
+	// CallbackEmulateFunc accepts:
+	//	- Task
+	//	- sysno
+	//	- syscall arguments
+	//
+	// returns
+	//	- SyscallReturnValue (nil if the callback doesn't emulate this invocation)
+	//	- error if something went wrong
+	CallbackEmulateFunc(t *Task, sysno uintptr, args *arch.SyscallArguments) (*SyscallReturnValue, error)
+
+	// Info about this callback
+	Info() callbacks.JsCallbackInfo
+}
+
 type SyscallReturnValue struct {
 	returnValue uintptr
 	errno       uintptr
@@ -5,6 +5,10 @@ package callbacks
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
 	"syscall"
 )
 
@@ -25,6 +29,172 @@ type JsCallbackInfo struct {
 
 	// Type is the callback executed before or after syscall
 	Type string `json:"type"`
+
+	// Capabilities is the list of capability classes the callback needs.
+	// Hooks which require not listed capability are replaced with stubs that fail.
+	// Empty list means that all capabilities are granted
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// SampleRate makes the callback executed only for part of invocations. Integer value N >= 1 means
+	// one of every N invocations (1 executes all of them), value in (0, 1) is the probability of execution.
+	// 0 disables sampling, other values are invalid (see ValidateLimits)
+	SampleRate float64 `json:"sample-rate,omitempty"`
+
+	// MaxPerSecond limits the count of callback executions per second. 0 means no limit
+	MaxPerSecond int `json:"max-per-second,omitempty"`
+
+	// Skipped is the count of invocations skipped due to SampleRate and MaxPerSecond.
+	// It is filled only in the list of current callbacks
+	Skipped uint64 `json:"skipped,omitempty"`
+
+	// Engine executes the callback: EngineJs (default) or EngineWasm
+	Engine string `json:"engine,omitempty"`
+
+	// Module is the path to WebAssembly module on the host, is used with EngineWasm
+	Module string `json:"module,omitempty"`
+
+	// ModuleBytes is the content of WebAssembly module. It is read from Module by LoadModules,
+	// because the sandbox can't access files of the host. It's accepted as base64 in "module-bytes"
+	// (see UnmarshalJSON), but isn't sent back in lists of callbacks
+	ModuleBytes []byte `json:"-"`
+
+	// TimeoutMs limits the time of WebAssembly callback execution, 0 means the default limit
+	TimeoutMs int `json:"timeout-ms,omitempty"`
+
+	// Fuel limits the count of function calls and loop iterations of WebAssembly callback
+	// per invocation, 0 means the default limit
+	Fuel int64 `json:"fuel,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It reads ModuleBytes from "module-bytes"
+func (info *JsCallbackInfo) UnmarshalJSON(data []byte) error {
+	// jsCallbackInfo has no methods, so it's unmarshaled by default
+	type jsCallbackInfo JsCallbackInfo
+	var dto struct {
+		jsCallbackInfo
+		ModuleBytes []byte `json:"module-bytes,omitempty"`
+	}
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return err
+	}
+	*info = JsCallbackInfo(dto.jsCallbackInfo)
+	info.ModuleBytes = dto.ModuleBytes
+	return nil
+}
+
+// Engines of callbacks
+const (
+	EngineJs   = "js"
+	EngineWasm = "wasm"
+)
+
+// IsWasm returns true if the callback is WebAssembly module
+func (info *JsCallbackInfo) IsWasm() bool {
+	return info.Engine == EngineWasm
+}
+
+// Capability classes of hooks
+const (
+	// CapabilityObserve allows to read data of the task. Always granted
+	CapabilityObserve = "observe"
+
+	// CapabilityModifyMemory allows to write to and map / unmap memory of the task
+	CapabilityModifyMemory = "modify-memory"
+
+	// CapabilityModifyProcess allows to send signals, stop and resume threads
+	CapabilityModifyProcess = "modify-process"
+
+	// CapabilityRegisterCallbacks allows to register new callbacks
+	CapabilityRegisterCallbacks = "register-callbacks"
+)
+
+var capabilityBits = map[string]CapabilitySet{
+	CapabilityObserve:           1 << 0,
+	CapabilityModifyMemory:      1 << 1,
+	CapabilityModifyProcess:     1 << 2,
+	CapabilityRegisterCallbacks: 1 << 3,
+}
+
+// CapabilitySet is a bitmask of granted capabilities
+type CapabilitySet uint32
+
+// AllCapabilities grants every capability class
+const AllCapabilities = CapabilitySet(1<<4 - 1)
+
+// Has returns true if capability with given name is in the set
+func (set CapabilitySet) Has(capability string) bool {
+	bit, ok := capabilityBits[capability]
+	return ok && set&bit != 0
+}
+
+// CapabilitySetOf returns the set of capabilities with given names, unknown names are ignored
+func CapabilitySetOf(names ...string) CapabilitySet {
+	var set CapabilitySet
+	for _, name := range names {
+		set |= capabilityBits[name]
+	}
+	return set
+}
+
+// HasAll returns true if every capability of other is in the set
+func (set CapabilitySet) HasAll(other CapabilitySet) bool {
+	return set&other == other
+}
+
+// String returns comma separated names of capabilities in the set
+func (set CapabilitySet) String() string {
+	return strings.Join(set.Names(), ",")
+}
+
+// Names returns names of capabilities in the set
+func (set CapabilitySet) Names() []string {
+	names := make([]string, 0, len(capabilityBits))
+	for _, name := range []string{
+		CapabilityObserve, CapabilityModifyMemory, CapabilityModifyProcess, CapabilityRegisterCallbacks} {
+		if set.Has(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ParseCapabilities converts list of capability names to CapabilitySet.
+// Observe capability is always added, empty list gives AllCapabilities
+func ParseCapabilities(names []string) (CapabilitySet, error) {
+	if len(names) == 0 {
+		return AllCapabilities, nil
+	}
+
+	set := capabilityBits[CapabilityObserve]
+	for _, name := range names {
+		bit, ok := capabilityBits[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown capability %q", name)
+		}
+		set |= bit
+	}
+	return set, nil
+}
+
+// maxSampleRate is the max N of sampling one of every N invocations
+const maxSampleRate = 1 << 53
+
+// ValidateLimits checks that SampleRate is 0, a probability in (0, 1) or an integer N >= 1
+// and MaxPerSecond isn't negative
+func (info *JsCallbackInfo) ValidateLimits() error {
+	rate := info.SampleRate
+	if math.IsNaN(rate) || rate < 0 || rate > maxSampleRate || (rate >= 1 && rate != math.Trunc(rate)) {
+		return fmt.Errorf("sample-rate should be a probability in (0, 1) or an integer N >= 1, got %v", rate)
+	}
+	if info.MaxPerSecond < 0 {
+		return errors.New("max-per-second can't be negative")
+	}
+	return nil
+}
+
+// GrantedCapabilities returns the set of capabilities declared by callback
+func (info *JsCallbackInfo) GrantedCapabilities() (CapabilitySet, error) {
+	return ParseCapabilities(info.Capabilities)
 }
 
 func JsCallbackInfoFromStr(str string) (*JsCallbackInfo, error) {
@@ -44,6 +214,111 @@ type CallbackConfigDto struct {
 	LogSocket string `json:"log-socket"`
 
 	CallbackDtos []JsCallbackInfo `json:"callbacks"`
+
+	// Rules are evaluated natively before callbacks, without entering js runtime
+	Rules []SyscallRuleDto `json:"rules,omitempty"`
+
+	// VirtualFiles are synthetic files mounted into the sandbox filesystem, their content is provided by js
+	VirtualFiles []VirtualFileDto `json:"virtual-files,omitempty"`
+
+	// Modules maps paths of WebAssembly modules of callbacks to their content. It's filled by
+	// LoadModules on the host and is used by Parse in the sandbox, which can't access files of the host
+	Modules map[string][]byte `json:"modules,omitempty"`
+}
+
+// VirtualFileDto describes a synthetic file, which content is generated by js function
+type VirtualFileDto struct {
+	// Path is the absolute path of the file in the sandbox. The file is mounted over the existing one
+	Path string `json:"path"`
+
+	// Source is js code, which defines Read and Write functions
+	Source string `json:"source"`
+
+	// Read is the name of the function, which is called with the path of the file
+	// when the file is read and returns its content (string or ArrayBuffer)
+	Read string `json:"read"`
+
+	// Write is the name of the function, which is called with the path, written data and offset.
+	// Empty name makes the file read-only
+	Write string `json:"write,omitempty"`
+
+	// Mode is the octal permissions of the file, "0444" ("0644" for writable file) if not set
+	Mode string `json:"mode,omitempty"`
+
+	// Capabilities is the list of capability classes the functions need, empty list grants all
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Actions of syscall rules
+const (
+	// RuleActionAllow executes the syscall and stops evaluation of rules
+	RuleActionAllow = "allow"
+
+	// RuleActionDeny returns errno without executing the syscall
+	RuleActionDeny = "deny"
+
+	// RuleActionLog logs the syscall to json log and continues evaluation of rules
+	RuleActionLog = "log"
+
+	// RuleActionRewritePath replaces the path argument and executes the syscall
+	RuleActionRewritePath = "rewrite-path"
+)
+
+// SyscallRuleDto is a declarative policy for syscalls. The rule matches the syscall
+// if all specified matchers match, the action of the first matching rule is applied
+type SyscallRuleDto struct {
+	// Name identifies the rule in logs
+	Name string `json:"name,omitempty"`
+
+	// Sysno is the list of syscall numbers, empty list matches any syscall
+	Sysno []int `json:"sysno,omitempty"`
+
+	// Path is the glob (see path.Match) for the path argument of syscall
+	Path string `json:"path,omitempty"`
+
+	// Addr is the CIDR or IP address for the sockaddr argument of syscall
+	Addr string `json:"addr,omitempty"`
+
+	// Port is the port in the sockaddr argument of syscall
+	Port int `json:"port,omitempty"`
+
+	// UID is the effective user id of the task
+	UID *uint32 `json:"uid,omitempty"`
+
+	// Exe is the glob for the path of the executable of the task
+	Exe string `json:"exe,omitempty"`
+
+	Action string `json:"action"`
+
+	// Errno is returned by the syscall with deny action, EPERM if not set
+	Errno int `json:"errno,omitempty"`
+
+	// RewritePath is the new path with rewrite-path action
+	RewritePath string `json:"rewrite-path,omitempty"`
+}
+
+// LoadModules reads WebAssembly modules of callbacks into ModuleBytes and Modules.
+// Returns true if at least one module was loaded
+func LoadModules(configDto *CallbackConfigDto) (bool, error) {
+	loaded := false
+	for i := range configDto.CallbackDtos {
+		info := &configDto.CallbackDtos[i]
+		if !info.IsWasm() || info.Module == "" {
+			continue
+		}
+
+		bytes, err := os.ReadFile(info.Module)
+		if err != nil {
+			return false, fmt.Errorf("failed to read module of callback %s: %w", info.EntryPoint, err)
+		}
+		if configDto.Modules == nil {
+			configDto.Modules = make(map[string][]byte)
+		}
+		configDto.Modules[info.Module] = bytes
+		info.ModuleBytes = bytes
+		loaded = true
+	}
+	return loaded, nil
 }
 
 func readAllBytes(fd int, data *[]byte) error {
@@ -86,5 +361,12 @@ func Parse(configFD int) (*CallbackConfigDto, error) {
 		return nil, err
 	}
 
+	// Modules loaded on the host by LoadModules
+	for i := range configDto.CallbackDtos {
+		info := &configDto.CallbackDtos[i]
+		if bytes, ok := configDto.Modules[info.Module]; ok && info.IsWasm() && info.ModuleBytes == nil {
+			info.ModuleBytes = bytes
+		}
+	}
 	return &configDto, nil
 }
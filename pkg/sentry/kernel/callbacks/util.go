@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/dop251/goja"
 	"github.com/dop251/goja/parser"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -101,6 +102,36 @@ func ExtractStringFromValue(vm *goja.Runtime, value goja.Value) (string, error)
 	return ret, nil
 }
 
+// maxSafeInteger is Number.MAX_SAFE_INTEGER, integers beyond it can't be
+// represented by JS numbers precisely
+const maxSafeInteger = 1<<53 - 1
+
+// ExtractIntegerFromValue returns decimal representation of integer, which is
+// given either as a number (it must be a safe integer) or as a string (this way
+// all 64-bit values can be passed)
+func ExtractIntegerFromValue(vm *goja.Runtime, value goja.Value) (string, error) {
+	if goja.IsUndefined(value) || goja.IsNull(value) {
+		return "", ErrNullOrUndefined
+	}
+
+	switch exported := value.Export().(type) {
+	case string:
+		return exported, nil
+	case int64:
+		if exported > maxSafeInteger || exported < -maxSafeInteger {
+			return "", fmt.Errorf("%d is not a safe integer, pass it as a string", exported)
+		}
+		return strconv.FormatInt(exported, 10), nil
+	case float64:
+		if exported != math.Trunc(exported) || math.Abs(exported) > maxSafeInteger {
+			return "", fmt.Errorf("%v is not a safe integer, pass it as a string", exported)
+		}
+		return strconv.FormatInt(int64(exported), 10), nil
+	default:
+		return "", fmt.Errorf("%v is neither a number nor a string", value)
+	}
+}
+
 func ExtractStatementsFromScript(scriptSrs string) ([]string, error) {
 	program, err := parser.ParseFile(nil, "", scriptSrs, 0)
 	if err != nil {
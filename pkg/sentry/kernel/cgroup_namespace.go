@@ -0,0 +1,219 @@
+package kernel
+
+import (
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// CgroupNamespace represents a cgroup namespace, which virtualizes the view of
+// cgroup hierarchies: the cgroups of the task that created the namespace
+// appear as the roots of their hierarchies, in /proc/[pid]/cgroup and in
+// cgroupfs mounts.
+//
+// +stateify savable
+type CgroupNamespace struct {
+	// userns is the user namespace associated with the CgroupNamespace.
+	// Privileged operations on this CgroupNamespace must have appropriate
+	// capabilities in userns.
+	//
+	// userns is immutable.
+	userns *auth.UserNamespace
+
+	// roots are the root cgroups of the namespace, keyed by hierarchy ID. The
+	// namespace holds a reference on each of them. Hierarchies without an
+	// entry are seen from their root. roots is immutable.
+	roots map[uint32]Cgroup
+
+	// mu protects inode.
+	mu sync.Mutex `state:"nosave"`
+
+	inode *nsfs.Inode
+}
+
+// NewRootCgroupNamespace returns a cgroup namespace in which all hierarchies
+// are seen from their root.
+func NewRootCgroupNamespace(userns *auth.UserNamespace) *CgroupNamespace {
+	return &CgroupNamespace{
+		userns: userns,
+	}
+}
+
+// newCgroupNamespace returns a new cgroup namespace rooted at cgroups. It
+// takes a reference on each cgroup.
+func newCgroupNamespace(userns *auth.UserNamespace, cgroups map[Cgroup]struct{}) *CgroupNamespace {
+	ns := &CgroupNamespace{
+		userns: userns,
+		roots:  make(map[uint32]Cgroup, len(cgroups)),
+	}
+	for c := range cgroups {
+		c.IncRef()
+		ns.roots[c.HierarchyID()] = c
+	}
+	return ns
+}
+
+// UserNamespace returns the user namespace associated with this cgroup
+// namespace.
+func (ns *CgroupNamespace) UserNamespace() *auth.UserNamespace {
+	return ns.userns
+}
+
+// Root returns the root cgroup of the given hierarchy in ns. It returns false
+// if the hierarchy is seen from its actual root.
+func (ns *CgroupNamespace) Root(hid uint32) (Cgroup, bool) {
+	c, ok := ns.roots[hid]
+	return c, ok
+}
+
+// Path returns the path of c as seen from ns.
+func (ns *CgroupNamespace) Path(c Cgroup) string {
+	p := c.Path()
+	root, ok := ns.roots[c.HierarchyID()]
+	if !ok {
+		return p
+	}
+	return relativeCgroupPath(root.Path(), p)
+}
+
+// relativeCgroupPath returns the absolute path p relative to root, as an
+// absolute path. Cgroups outside of root are reached through ".."
+// components, like Linux's kernfs_path_from_node().
+func relativeCgroupPath(root, p string) string {
+	rootComps := cgroupPathComponents(root)
+	comps := cgroupPathComponents(p)
+	common := 0
+	for common < len(rootComps) && common < len(comps) && rootComps[common] == comps[common] {
+		common++
+	}
+	var rel []string
+	for range rootComps[common:] {
+		rel = append(rel, "..")
+	}
+	rel = append(rel, comps[common:]...)
+	return "/" + strings.Join(rel, "/")
+}
+
+func cgroupPathComponents(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// Type implements nsfs.Namespace.Type.
+func (ns *CgroupNamespace) Type() string {
+	return "cgroup"
+}
+
+// Destroy implements nsfs.Namespace.Destroy.
+func (ns *CgroupNamespace) Destroy(ctx context.Context) {
+	for _, c := range ns.roots {
+		c.DecRef(ctx)
+	}
+}
+
+// SetInode sets the nsfs `inode` to the cgroup namespace.
+func (ns *CgroupNamespace) SetInode(inode *nsfs.Inode) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode = inode
+}
+
+// GetInode returns the nsfs inode associated with the cgroup namespace.
+func (ns *CgroupNamespace) GetInode() *nsfs.Inode {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.inode
+}
+
+// IncRef increments the Namespace's refcount.
+func (ns *CgroupNamespace) IncRef() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode.IncRef()
+}
+
+// DecRef decrements the namespace's refcount.
+func (ns *CgroupNamespace) DecRef(ctx context.Context) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode.DecRef(ctx)
+}
+
+// CgroupNamespace returns the task's cgroup namespace.
+func (t *Task) CgroupNamespace() *CgroupNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cgroupns
+}
+
+// GetCgroupNamespace takes a reference on the task cgroup namespace and
+// returns it. It will return nil if the task isn't alive.
+func (t *Task) GetCgroupNamespace() *CgroupNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cgroupns != nil {
+		t.cgroupns.IncRef()
+	}
+	return t.cgroupns
+}
+
+// newCgroupNamespaceLocked returns a new cgroup namespace rooted at the
+// cgroups of t.
+//
+// +checklocks:t.mu
+func (t *Task) newCgroupNamespaceLocked(userns *auth.UserNamespace) *CgroupNamespace {
+	ns := newCgroupNamespace(userns, t.cgroups)
+	ns.SetInode(nsfs.NewInode(t, t.k.nsfsMount, ns))
+	return ns
+}
+
+// cgroupFromFD returns the cgroup of the cgroupfs directory referred to by fd,
+// for CLONE_INTO_CGROUP. The caller must hold a reference on the returned
+// file until it's done with the cgroup.
+func (t *Task) cgroupFromFD(fd int32) (Cgroup, *vfs.FileDescription, error) {
+	file := t.GetFile(fd)
+	if file == nil {
+		return Cgroup{}, nil, linuxerr.EBADF
+	}
+	d, ok := file.Dentry().Impl().(*kernfs.Dentry)
+	if !ok {
+		file.DecRef(t)
+		return Cgroup{}, nil, linuxerr.EBADF
+	}
+	impl, ok := d.Inode().(CgroupImpl)
+	if !ok {
+		file.DecRef(t)
+		return Cgroup{}, nil, linuxerr.EBADF
+	}
+	// Moving a task into a cgroup requires write access to it, as for
+	// writing to cgroup.procs.
+	if err := d.Inode().CheckPermissions(t, t.Credentials(), vfs.MayWrite); err != nil {
+		file.DecRef(t)
+		return Cgroup{}, nil, err
+	}
+	return Cgroup{Dentry: d, CgroupImpl: impl}, file, nil
+}
+
+// cgroupsWith returns the cgroups of t, with c replacing the cgroup of t in
+// the hierarchy of c.
+func (t *Task) cgroupsWith(c Cgroup) map[Cgroup]struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cgroups := make(map[Cgroup]struct{}, len(t.cgroups)+1)
+	for tc := range t.cgroups {
+		if tc.HierarchyID() != c.HierarchyID() {
+			cgroups[tc] = struct{}{}
+		}
+	}
+	cgroups[c] = struct{}{}
+	return cgroups
+}
@@ -30,7 +30,7 @@ func (d *DynamicJsCallbackBefore) CallbackBeforeFunc(t *Task, _ uintptr,
 	context := ScriptContextsBuilderOf().AddContext3("__callback__",
 		&ObjectAddableAdapter{name: "invoke", object: d.Holder}).Build()
 
-	return RunAbstractCallback(t, dynamicJsCallbackEntryPoint(), args, context)
+	return RunAbstractCallback(t, &d.CallbackInfo, dynamicJsCallbackEntryPoint(), args, context)
 }
 
 func (d *DynamicJsCallbackBefore) Info() callbacks.JsCallbackInfo {
@@ -50,13 +50,33 @@ func (d *DynamicJsCallbackAfter) CallbackAfterFunc(t *Task, _ uintptr,
 	builder = builder.AddContext3(ArgsJsName, SyscallReturnValueWithError{returnValue: ret, errno: inputErr})
 	builder = builder.AddContext3("__callback__", &ObjectAddableAdapter{name: "invoke", object: d.Holder})
 
-	return RunAbstractCallback(t, dynamicJsCallbackEntryPoint(), args, builder.Build())
+	return RunAbstractCallback(t, &d.CallbackInfo, dynamicJsCallbackEntryPoint(), args, builder.Build())
 }
 
 func (d *DynamicJsCallbackAfter) Info() callbacks.JsCallbackInfo {
 	return d.CallbackInfo
 }
 
+// DynamicJsCallbackEmulate implements CallbackEmulate
+type DynamicJsCallbackEmulate struct {
+	CallbackInfo callbacks.JsCallbackInfo
+	Holder       *goja.Object
+}
+
+func (d *DynamicJsCallbackEmulate) CallbackEmulateFunc(t *Task, _ uintptr,
+	args *arch.SyscallArguments) (*SyscallReturnValue, error) {
+
+	context := ScriptContextsBuilderOf().AddContext3("__callback__",
+		&ObjectAddableAdapter{name: "invoke", object: d.Holder}).Build()
+
+	_, sub, err := RunAbstractCallback(t, &d.CallbackInfo, dynamicJsCallbackEntryPoint(), args, context)
+	return sub, err
+}
+
+func (d *DynamicJsCallbackEmulate) Info() callbacks.JsCallbackInfo {
+	return d.CallbackInfo
+}
+
 func fillJsCallbackInfoForDynamicCallback(info callbacks.JsCallbackInfo, body string) callbacks.JsCallbackInfo {
 	info.CallbackBody = body
 	info.CallbackSource = body
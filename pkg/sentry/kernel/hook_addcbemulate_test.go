@@ -0,0 +1,107 @@
+package kernel
+
+import (
+	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+)
+
+var simpleAddCbEmulate = `
+	function cb() {
+		return {ret: 7, errno: 0}
+	}
+
+	hooks.AddCbEmulate(1000, cb)
+`
+
+func TestAddCbEmulateHook_registersCallback(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	contexts := testBuildContexts()
+
+	val, err := RunJsScript(jsRuntime.JsVM, simpleAddCbEmulate, contexts)
+	if err != nil {
+		t.Fatalf("unexpected error while registering callback: %v", err)
+	}
+	if !goja.IsNull(val) {
+		t.Fatalf("unexpected return value")
+	}
+	cb := jsRuntime.callbackTable.getCallbackEmulate(1000)
+	if cb == nil {
+		t.Fatalf("callback wasn't registered")
+	}
+	info := cb.Info()
+	if info.Sysno != 1000 {
+		t.Fatalf("bad sysno in info: got %v expected 1000", info.Sysno)
+	}
+	if info.EntryPoint != "cb" {
+		t.Fatalf("bad entry point: got '%v', expected 'cb'", info.EntryPoint)
+	}
+	if info.Type != JsCallbackTypeEmulate {
+		t.Fatalf("wrong cb type: got %s, expected %s", info.Type, JsCallbackTypeEmulate)
+	}
+}
+
+var addCbEmulateWith1arg = `
+	hooks.AddCbEmulate(1)
+`
+
+func TestAddCbEmulateHook_fails_with1arg(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	contexts := testBuildContexts()
+
+	_, err := RunJsScript(jsRuntime.JsVM, addCbEmulateWith1arg, contexts)
+	if err == nil {
+		t.Fatalf("no error in callback which 1 argument was given instead of 2")
+	}
+}
+
+var addCbEmulateWithNullCb = `
+	hooks.AddCbEmulate(1, null)
+`
+
+func TestAddCbEmulateHook_fails_withNullCb(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	contexts := testBuildContexts()
+
+	_, err := RunJsScript(jsRuntime.JsVM, addCbEmulateWithNullCb, contexts)
+	if err == nil {
+		t.Fatalf("no error in callback which 2 argument is null")
+	}
+}
+
+var emulateCallbackSource = `
+	function cb(a0) {
+		return {ret: a0 + 1, errno: 0}
+	}
+`
+
+func TestJsCallbackEmulate_returnsSubstitution(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+	args := arch.SyscallArguments{arch.SyscallArgument{Value: 41}}
+
+	jsCallback, err := JsCallbackByInfo(callbacks.JsCallbackInfo{
+		Sysno:          1000,
+		CallbackSource: emulateCallbackSource,
+		CallbackBody:   emulateCallbackSource,
+		CallbackArgs:   []string{"a0"},
+		Type:           JsCallbackTypeEmulate,
+		EntryPoint:     "cb",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error while creating emulate callback: %v", err)
+	}
+
+	sub, err := jsCallback.(*JsCallbackEmulate).CallbackEmulateFunc(&task, 1000, &args)
+	if err != nil {
+		t.Fatalf("unexpected error while running emulate callback: %v", err)
+	}
+	if sub == nil || sub.returnValue != 42 || sub.errno != 0 {
+		t.Fatalf("bad emulated return value: %v", sub)
+	}
+}
@@ -0,0 +1,58 @@
+package kernel
+
+import (
+	"testing"
+)
+
+var askOperatorWithNoArgs = `
+	function cb() {
+		hooks.askOperator()
+	}
+`
+
+func TestAskOperatorHook_withNoArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, askOperatorWithNoArgs,
+		"no error for hook, which requires 1 or 2 args, when given no args")
+}
+
+var askOperatorWithNullArg = `
+	function cb() {
+		hooks.askOperator(null)
+	}
+`
+
+func TestAskOperatorHook_withNullArg_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, askOperatorWithNullArg,
+		"no error for hook when arg is null")
+}
+
+var askOperatorInCallbackAfter = `
+	function cb() {
+		hooks.askOperator("proceed?")
+	}
+`
+
+func TestAskOperatorHook_inCallbackAfter_Fails(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	if err := testRunCallbackOfType(&task, askOperatorInCallbackAfter, JsCallbackTypeAfter); err == nil {
+		t.Fatalf("no error when asking operator in callback after")
+	}
+}
+
+func TestAskOperatorHook_defersSyscall(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	if err := testRunCallbackOfType(&task, askOperatorInCallbackAfter, JsCallbackTypeBefore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if task.approvalRequest == nil || task.approvalRequest.message != "proceed?" {
+		t.Fatalf("syscall isn't deferred by hook")
+	}
+}
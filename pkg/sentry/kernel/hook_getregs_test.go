@@ -0,0 +1,15 @@
+package kernel
+
+import "testing"
+
+var getRegsWithArgs = `
+	function cb() {
+		hooks.getRegs(1)
+	}
+`
+
+func TestGetRegsHook_withArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, getRegsWithArgs,
+		"no error for hook, which requires no args, when given 1 arg")
+}
@@ -0,0 +1,134 @@
+package kernel
+
+import (
+	"errors"
+	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"testing"
+)
+
+var simpleOnPacket = `
+	function cb(packet) {
+		return packet.proto == "udp" ? "drop" : "accept"
+	}
+
+	hooks.onPacket("input", cb, {"sample-rate": 2, "timeout-ms": 5})
+`
+
+func TestOnPacketHook_registersCallback(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	contexts := testBuildContexts()
+
+	if _, err := RunJsScript(jsRuntime.JsVM, simpleOnPacket, contexts); err != nil {
+		t.Fatalf("unexpected error while registering callback: %v", err)
+	}
+	cb := jsRuntime.packetCallbacks.callback(stack.Input)
+	if cb == nil {
+		t.Fatalf("callback wasn't registered")
+	}
+	if cb.info.SampleRate != 2 || cb.info.TimeoutMs != 5 {
+		t.Fatalf("options aren't applied: %+v", cb.info)
+	}
+	if cb.limiter == nil {
+		t.Fatalf("limiter isn't created for sampled callback")
+	}
+	if cb.failVerdict != stack.PacketFilterAccept {
+		t.Fatalf("callback isn't fail-open by default")
+	}
+
+	if _, err := RunJsScript(jsRuntime.JsVM, `hooks.onPacket("input", null)`, contexts); err != nil {
+		t.Fatalf("unexpected error while removing callback: %v", err)
+	}
+	if jsRuntime.packetCallbacks.callback(stack.Input) != nil {
+		t.Fatalf("callback wasn't removed")
+	}
+}
+
+func TestFilterPacket_busyRuntime_AcceptsAndCountsSkipped(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	holder := jsRuntime.JsVM.NewObject()
+	table := &PacketCallbackTable{}
+	table.callbacks[stack.Input] = newPacketCallback(callbacks.JsCallbackInfo{}, holder, callbacks.AllCapabilities, false)
+
+	jsRuntime.Mutex.Lock()
+	verdict := table.FilterPacket(stack.Input, nil)
+	jsRuntime.Mutex.Unlock()
+
+	if verdict != stack.PacketFilterAccept {
+		t.Fatalf("packet isn't accepted while js runtime is busy")
+	}
+	limiter := table.callbacks[stack.Input].limiter
+	if limiter.skipped.Load() != 1 || limiter.takePending() != 1 {
+		t.Fatalf("packet isn't counted as skipped")
+	}
+}
+
+func TestFilterPacket_failClosedBusyRuntime_Drops(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	if _, err := RunJsScript(jsRuntime.JsVM, `hooks.onPacket("input", function (packet) {}, {"fail-closed": true})`, testBuildContexts()); err != nil {
+		t.Fatalf("unexpected error while registering callback: %v", err)
+	}
+	table := jsRuntime.packetCallbacks
+
+	jsRuntime.Mutex.Lock()
+	verdict := table.FilterPacket(stack.Input, nil)
+	jsRuntime.Mutex.Unlock()
+
+	if verdict != stack.PacketFilterDrop {
+		t.Fatalf("packet of fail-closed callback isn't dropped while js runtime is busy")
+	}
+	if table.callback(stack.Input).limiter.skipped.Load() != 1 {
+		t.Fatalf("packet isn't counted as skipped")
+	}
+}
+
+func TestPacketInterrupt_afterFinish_DoesNotInterrupt(t *testing.T) {
+	vm := goja.New()
+	invocation := &packetInterrupt{}
+	invocation.finish(vm)
+	invocation.interrupt(vm, errors.New("late timer"))
+
+	if _, err := vm.RunString("1 + 1"); err != nil {
+		t.Fatalf("the next script is interrupted by the timer of finished invocation: %v", err)
+	}
+}
+
+func TestOnPacketHook_unknownHook_Fails(t *testing.T) {
+	testThatCbFailsWithErr(t, `function cb() { hooks.onPacket("forward", function(p) {}) }`,
+		"unknown packet hook isn't rejected")
+}
+
+func TestOnPacketHook_withMoreArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(t, `function cb() { hooks.onPacket("input", function(p) {}, {}, 1) }`,
+		"callback should fail with more args")
+}
+
+func TestApplyPacketResult(t *testing.T) {
+	vm := goja.New()
+	for _, test := range []struct {
+		result  goja.Value
+		verdict stack.PacketFilterVerdict
+		fails   bool
+	}{
+		{goja.Undefined(), stack.PacketFilterAccept, false},
+		{goja.Null(), stack.PacketFilterAccept, false},
+		{vm.ToValue("accept"), stack.PacketFilterAccept, false},
+		{vm.ToValue("drop"), stack.PacketFilterDrop, false},
+		{vm.ToValue(map[string]interface{}{"verdict": "drop"}), stack.PacketFilterDrop, false},
+		{vm.ToValue("reject"), stack.PacketFilterAccept, true},
+	} {
+		verdict, err := applyPacketResult(vm, test.result, nil)
+		if (err != nil) != test.fails {
+			t.Fatalf("unexpected error for %v: %v", test.result, err)
+		}
+		if verdict != test.verdict {
+			t.Fatalf("verdict for %v is %v, but %v expected", test.result, verdict, test.verdict)
+		}
+	}
+}
@@ -40,7 +40,7 @@ func TestPrintHook_Works(t *testing.T) {
 		_ = os.Remove(fileName)
 	}()
 
-	_, _, err = RunAbstractCallback(&task, jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	_, _, err = RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
 	if err != nil {
 		t.Fatalf("unexpected error while executing callback")
 	}
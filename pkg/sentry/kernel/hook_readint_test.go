@@ -0,0 +1,208 @@
+package kernel
+
+import (
+	"encoding/binary"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"testing"
+)
+
+var readIntHookWithLessArgs = `
+	function cb() {
+		hooks.readInt(1)
+	}
+`
+
+func TestReadIntHook_withLessArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readIntHookWithLessArgs,
+		"no error for hook, which requires 2 args, when given less then 2")
+}
+
+var readIntHookWithNull1Arg = `
+	function cb() {
+		hooks.readInt(null, "u32")
+	}
+`
+
+func TestReadIntHook_withNull1Arg_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readIntHookWithNull1Arg,
+		"no error for hook when 1 arg is null")
+}
+
+var readIntHookWithUnknownType = `
+	function cb() {
+		hooks.readInt(1, "u24")
+	}
+`
+
+func TestReadIntHook_withUnknownType_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readIntHookWithUnknownType,
+		"no error for hook when type is unknown")
+}
+
+var readIntHookWithBytesType = `
+	function cb() {
+		hooks.readInt(1, "bytes:4")
+	}
+`
+
+func TestReadIntHook_withBytesType_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readIntHookWithBytesType,
+		"no error for hook when type is not an integer")
+}
+
+func TestParseMemoryType(t *testing.T) {
+	mt, err := ParseMemoryType("i16be")
+	if err != nil {
+		t.Fatalf("unexpected error while parsing type: %s", err)
+	}
+	if mt.Size != 2 || !mt.Signed || mt.Order != binary.BigEndian {
+		t.Fatalf("wrong type parsed: %+v", mt)
+	}
+
+	mt, err = ParseMemoryType("bytes:3")
+	if err != nil || !mt.Raw || mt.Size != 3 || mt.Align() != 1 {
+		t.Fatalf("wrong bytes type parsed: %+v (err: %v)", mt, err)
+	}
+
+	for _, name := range []string{"", "u", "u12", "f32", "bytes:0", "bytes:x"} {
+		if _, err := ParseMemoryType(name); err == nil {
+			t.Fatalf("no error for bad type %q", name)
+		}
+	}
+}
+
+func TestDecodeMemoryValue(t *testing.T) {
+	mt, _ := ParseMemoryType("i16le")
+	if val := decodeMemoryValue(mt, []byte{0xfe, 0xff}); val != int64(-2) {
+		t.Fatalf("wrong decoded value: got %v, expected -2", val)
+	}
+
+	mt, _ = ParseMemoryType("u32be")
+	if val := decodeMemoryValue(mt, []byte{0, 0, 1, 2}); val != uint64(0x102) {
+		t.Fatalf("wrong decoded value: got %v, expected 258", val)
+	}
+
+	buf, err := encodeMemoryValue(mt, "0x102")
+	if err != nil {
+		t.Fatalf("unexpected error while encoding value: %s", err)
+	}
+	if val := decodeMemoryValue(mt, buf); val != uint64(0x102) {
+		t.Fatalf("encoded and decoded values mismatch: got %v", val)
+	}
+}
+
+// fakeMemory is marshal.CopyContext backed by a byte slice mapped at address 0
+type fakeMemory []byte
+
+func (m fakeMemory) CopyScratchBuffer(size int) []byte {
+	return make([]byte, size)
+}
+
+func (m fakeMemory) CopyInBytes(addr hostarch.Addr, b []byte) (int, error) {
+	if int(addr) > len(m) {
+		return 0, linuxerr.EFAULT
+	}
+	n := copy(b, m[addr:])
+	if n < len(b) {
+		return n, linuxerr.EFAULT
+	}
+	return n, nil
+}
+
+func (m fakeMemory) CopyOutBytes(addr hostarch.Addr, b []byte) (int, error) {
+	if int(addr) > len(m) {
+		return 0, linuxerr.EFAULT
+	}
+	n := copy(m[addr:], b)
+	if n < len(b) {
+		return n, linuxerr.EFAULT
+	}
+	return n, nil
+}
+
+func TestWriteReadTyped_roundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		typeName string
+		val      string
+		expected interface{}
+	}{
+		{"u8", "255", uint64(255)},
+		{"i8", "-128", int64(-128)},
+		{"u16be", "0x102", uint64(0x102)},
+		{"i16le", "-2", int64(-2)},
+		{"u32", "4294967295", uint64(4294967295)},
+		{"i32be", "-2147483648", int64(-2147483648)},
+		{"u64", "18446744073709551615", "18446744073709551615"},
+		{"u64be", "9007199254740993", "9007199254740993"},
+		{"i64", "-9223372036854775808", "-9223372036854775808"},
+		{"i64le", "0x7fffffffffffffff", "9223372036854775807"},
+		{"ptr", "0x7fff12345678", int64(0x7fff12345678)},
+	} {
+		mt, err := ParseMemoryType(tc.typeName)
+		if err != nil {
+			t.Fatalf("unexpected error while parsing type %q: %s", tc.typeName, err)
+		}
+		mem := make(fakeMemory, 16)
+		if n, err := WriteTyped(mem, 4, mt, tc.val); err != nil || n != mt.Size {
+			t.Fatalf("writing %v of type %q: got (%v, %v), expected (%v, nil)", tc.val, tc.typeName, n, err, mt.Size)
+		}
+		val, err := ReadTyped(mem, 4, mt)
+		if err != nil {
+			t.Fatalf("unexpected error while reading type %q: %s", tc.typeName, err)
+		}
+		if val != tc.expected {
+			t.Fatalf("wrong value of type %q read: got %#v, expected %#v", tc.typeName, val, tc.expected)
+		}
+	}
+}
+
+func TestWriteTyped_byteOrder(t *testing.T) {
+	mt, _ := ParseMemoryType("u32be")
+	mem := make(fakeMemory, 4)
+	if _, err := WriteTyped(mem, 0, mt, "0x01020304"); err != nil {
+		t.Fatalf("unexpected error while writing value: %s", err)
+	}
+	if mem[0] != 1 || mem[1] != 2 || mem[2] != 3 || mem[3] != 4 {
+		t.Fatalf("wrong bytes written: %v", []byte(mem))
+	}
+}
+
+func TestWriteTyped_withOutOfRangeValue_Fails(t *testing.T) {
+	for _, tc := range []struct {
+		typeName string
+		val      string
+	}{
+		{"u8", "256"},
+		{"i8", "128"},
+		{"i8", "-129"},
+		{"u16", "-1"},
+		{"u32", "4294967296"},
+		{"i64", "9223372036854775808"},
+		{"u64", "18446744073709551616"},
+		{"ptr", "-1"},
+		{"u32", "1.5"},
+	} {
+		mt, _ := ParseMemoryType(tc.typeName)
+		mem := make(fakeMemory, 8)
+		if _, err := WriteTyped(mem, 0, mt, tc.val); err == nil {
+			t.Fatalf("no error when writing %v of type %q", tc.val, tc.typeName)
+		}
+		for _, b := range mem {
+			if b != 0 {
+				t.Fatalf("memory modified when writing %v of type %q: %v", tc.val, tc.typeName, []byte(mem))
+			}
+		}
+	}
+}
+
+func TestReadTyped_withFault_Fails(t *testing.T) {
+	mt, _ := ParseMemoryType("u64")
+	if _, err := ReadTyped(make(fakeMemory, 8), 4, mt); err == nil {
+		t.Fatalf("no error when reading beyond the end of memory")
+	}
+}
@@ -0,0 +1,27 @@
+package kernel
+
+import "testing"
+
+var readIovecsHookWithNull2Arg = `
+	function cb() {
+		hooks.readIovecs(1, null)
+	}
+`
+
+func TestReadIovecsHook_withNull2Arg_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readIovecsHookWithNull2Arg,
+		"no error for hook when 2 arg is null")
+}
+
+var readIovecsHookWithNegativeCount = `
+	function cb() {
+		hooks.readIovecs(1, -1)
+	}
+`
+
+func TestReadIovecsHook_withNegativeCount_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readIovecsHookWithNegativeCount,
+		"no error for hook when count is negative")
+}
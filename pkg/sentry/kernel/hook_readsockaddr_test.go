@@ -0,0 +1,27 @@
+package kernel
+
+import "testing"
+
+var readSockaddrHookWithLessArgs = `
+	function cb() {
+		hooks.readSockaddr(1)
+	}
+`
+
+func TestReadSockaddrHook_withLessArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readSockaddrHookWithLessArgs,
+		"no error for hook, which requires 2 args, when given less then 2")
+}
+
+var readSockaddrHookWithTooLongAddr = `
+	function cb() {
+		hooks.readSockaddr(1, 4096)
+	}
+`
+
+func TestReadSockaddrHook_withTooLongAddr_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readSockaddrHookWithTooLongAddr,
+		"no error for hook when addrlen is bigger than sockaddr")
+}
@@ -0,0 +1,27 @@
+package kernel
+
+import "testing"
+
+var readStringArrayHookWithNoArgs = `
+	function cb() {
+		hooks.readStringArray()
+	}
+`
+
+func TestReadStringArrayHook_withNoArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readStringArrayHookWithNoArgs,
+		"no error for hook, which requires 1 arg, when given no args")
+}
+
+var readStringArrayHookWithUndefinedArg = `
+	function cb() {
+		hooks.readStringArray(undefined)
+	}
+`
+
+func TestReadStringArrayHook_withUndefinedArg_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readStringArrayHookWithUndefinedArg,
+		"no error for hook when arg is undefined")
+}
@@ -0,0 +1,89 @@
+package kernel
+
+import (
+	"bytes"
+	"testing"
+)
+
+var readStructHookWithNullLayout = `
+	function cb() {
+		hooks.readStruct(1, null)
+	}
+`
+
+func TestReadStructHook_withNullLayout_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readStructHookWithNullLayout,
+		"no error for hook when layout is null")
+}
+
+var readStructHookWithBadLayout = `
+	function cb() {
+		hooks.readStruct(1, {a: "u32", b: "float"})
+	}
+`
+
+func TestReadStructHook_withBadLayout_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, readStructHookWithBadLayout,
+		"no error for hook when layout contains unknown type")
+}
+
+func TestStructOffsets(t *testing.T) {
+	u8, _ := ParseMemoryType("u8")
+	u32, _ := ParseMemoryType("u32")
+	ptr, _ := ParseMemoryType("ptr")
+	raw, _ := ParseMemoryType("bytes:3")
+
+	offsets, size := structOffsets([]StructField{
+		{Name: "a", Type: u8},
+		{Name: "b", Type: u32},
+		{Name: "c", Type: raw},
+		{Name: "d", Type: ptr},
+	})
+	expected := []int{0, 4, 8, 16}
+	for i := range expected {
+		if offsets[i] != expected[i] {
+			t.Fatalf("wrong offset of field %v: got %v, expected %v", i, offsets[i], expected[i])
+		}
+	}
+	if size != 24 {
+		t.Fatalf("wrong struct size: got %v, expected 24", size)
+	}
+}
+
+func TestReadStruct_roundTrip(t *testing.T) {
+	u8, _ := ParseMemoryType("u8")
+	u16be, _ := ParseMemoryType("u16be")
+	i64, _ := ParseMemoryType("i64")
+	raw, _ := ParseMemoryType("bytes:3")
+	fields := []StructField{
+		{Name: "a", Type: u8},
+		{Name: "b", Type: u16be},
+		{Name: "c", Type: raw},
+		{Name: "d", Type: i64},
+	}
+	offsets, size := structOffsets(fields)
+
+	mem := make(fakeMemory, 8+size)
+	for i, val := range []string{"7", "0xabcd", "", "-9223372036854775807"} {
+		if fields[i].Type.Raw {
+			copy(mem[8+offsets[i]:], "xyz")
+			continue
+		}
+		if _, err := WriteTyped(mem, uintptr(8+offsets[i]), fields[i].Type, val); err != nil {
+			t.Fatalf("unexpected error while writing field %q: %s", fields[i].Name, err)
+		}
+	}
+
+	values, err := ReadStruct(mem, 8, fields)
+	if err != nil {
+		t.Fatalf("unexpected error while reading struct: %s", err)
+	}
+	if values["a"] != uint64(7) || values["b"] != uint64(0xabcd) || values["d"] != "-9223372036854775807" {
+		t.Fatalf("wrong struct read: %v", values)
+	}
+	if c, ok := values["c"].([]byte); !ok || !bytes.Equal(c, []byte("xyz")) {
+		t.Fatalf("wrong raw field read: %#v", values["c"])
+	}
+}
@@ -0,0 +1,57 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	util "gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+)
+
+var setRegsWithNullArg = `
+	function cb() {
+		hooks.setRegs(null)
+	}
+`
+
+func TestSetRegsHook_withNullArg_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, setRegsWithNullArg,
+		"no error for hook when arg is null")
+}
+
+var setRegsWithNoArgs = `
+	function cb() {
+		hooks.setRegs()
+	}
+`
+
+func TestSetRegsHook_withNoArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, setRegsWithNoArgs,
+		"no error for hook, which requires 1 arg, when given no args")
+}
+
+var setRegsInCallbackAfter = `
+	function cb() {
+		hooks.setRegs({})
+	}
+`
+
+func TestSetRegsHook_inCallbackAfter_Fails(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+	args := arch.SyscallArguments{}
+	cb := JsCallbackAfter{info: util.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: setRegsInCallbackAfter,
+		CallbackBody:   setRegsInCallbackAfter,
+		CallbackArgs:   []string{},
+		Type:           JsCallbackTypeAfter,
+		EntryPoint:     "cb",
+	}}
+
+	_, _, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	if err == nil {
+		t.Fatalf("no error when setting registers in callback after")
+	}
+}
@@ -90,7 +90,7 @@ func TestSignalMaskToSignalNamesHook_WorksExpected(t *testing.T) {
 		EntryPoint:     "cb",
 	}}
 
-	newArgs, rval, err := RunAbstractCallback(&task, jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	newArgs, rval, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
 	if err != nil {
 		t.Fatalf("error when calling hook")
 	}
@@ -77,7 +77,7 @@ func TestSignalByNameHook_Works(t *testing.T) {
 		EntryPoint:     "cb",
 	}}
 
-	_, rval, err := RunAbstractCallback(&task, jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	_, rval, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
 	if err != nil {
 		t.Fatalf("unexpected error while running callback")
 	}
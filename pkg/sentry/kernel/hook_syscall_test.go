@@ -0,0 +1,168 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	util "gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"strings"
+	"testing"
+)
+
+var syscallWithNoArgs = `
+	function cb() {
+		hooks.syscall()
+	}
+`
+
+func TestSyscallHook_withNoArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, syscallWithNoArgs,
+		"no error for hook, which requires at least 1 arg, when given no args")
+}
+
+var syscallWithNullArg = `
+	function cb() {
+		hooks.syscall(null)
+	}
+`
+
+func TestSyscallHook_withNullArg_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, syscallWithNullArg,
+		"no error for hook when arg is null")
+}
+
+var syscallWithTooManyArgs = `
+	function cb() {
+		hooks.syscall(0, 1, 2, 3, 4, 5, 6, 7)
+	}
+`
+
+func TestSyscallHook_withTooManyArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, syscallWithTooManyArgs,
+		"no error for hook when given more than 6 syscall args")
+}
+
+var syscallNested = `
+	function cb() {
+		hooks.syscall(39)
+	}
+`
+
+func TestSyscallHook_whenNested_Fails(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+	task.injectingSyscall = true
+	args := arch.SyscallArguments{}
+	cb := JsCallbackBefore{info: util.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: syscallNested,
+		CallbackBody:   syscallNested,
+		CallbackArgs:   []string{},
+		Type:           JsCallbackTypeBefore,
+		EntryPoint:     "cb",
+	}}
+
+	_, _, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	if err == nil {
+		t.Fatalf("no error when invoking syscall from injected syscall")
+	}
+}
+
+func TestSyscallHook_withModifyProcessCapabilityOnly_Fails(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+	args := arch.SyscallArguments{}
+	cb := JsCallbackBefore{info: util.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: syscallNested,
+		CallbackBody:   syscallNested,
+		CallbackArgs:   []string{},
+		Type:           JsCallbackTypeBefore,
+		EntryPoint:     "cb",
+		Capabilities:   []string{util.CapabilityModifyProcess},
+	}}
+
+	_, _, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	if err == nil || !strings.Contains(err.Error(), "requires capabilities") {
+		t.Fatalf("no capability error when invoking syscall without modify-memory capability, got %v", err)
+	}
+}
+
+var syscallSleeping = `
+	function cb() {
+		hooks.syscall("nanosleep", 0, 0)
+	}
+`
+
+var syscallLocksRuntime = `
+	function cb() {
+		hooks.syscall("getpid")
+	}
+`
+
+func testRunSyscallCallback(t *testing.T, source string, table *SyscallTable) error {
+	t.Helper()
+	task := testCreateEmptyTask()
+	task.image.st = table
+	args := arch.SyscallArguments{}
+	cb := JsCallbackBefore{info: util.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: source,
+		CallbackBody:   source,
+		CallbackArgs:   []string{},
+		Type:           JsCallbackTypeBefore,
+		EntryPoint:     "cb",
+	}}
+
+	_, _, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	return err
+}
+
+func TestSyscallHook_withSleepingSyscall_Fails(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	invoked := false
+	table := &SyscallTable{Table: map[uintptr]Syscall{35: {Name: "nanosleep"}}}
+	table.lookup[35] = func(*Task, uintptr, arch.SyscallArguments) (uintptr, *SyscallControl, error) {
+		invoked = true
+		return 0, nil, nil
+	}
+
+	err := testRunSyscallCallback(t, syscallSleeping, table)
+	if err == nil || !strings.Contains(err.Error(), "can't be invoked from callback") {
+		t.Fatalf("no error when invoking nanosleep from callback, got %v", err)
+	}
+	if invoked {
+		t.Errorf("denied syscall was invoked")
+	}
+}
+
+func TestSyscallHook_keepsRuntimeLocked(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	locked := false
+	var fileErr error
+	table := &SyscallTable{Table: map[uintptr]Syscall{39: {Name: "getpid"}}}
+	table.lookup[39] = func(t *Task, _ uintptr, _ arch.SyscallArguments) (uintptr, *SyscallControl, error) {
+		if GetJsRuntime().Mutex.TryLock() {
+			GetJsRuntime().Mutex.Unlock()
+		} else {
+			locked = true
+		}
+		_, fileErr = (&VirtualFile{}).Read(t)
+		return 1, nil, nil
+	}
+
+	if err := testRunSyscallCallback(t, syscallLocksRuntime, table); err != nil {
+		t.Fatalf("callback failed: %v", err)
+	}
+	if !locked {
+		t.Errorf("js runtime was unlocked while injected syscall was executed")
+	}
+	if fileErr != errInjectedSyscallVirtualFile {
+		t.Errorf("virtual file is accessible from injected syscall: %v", fileErr)
+	}
+}
@@ -0,0 +1,188 @@
+package kernel
+
+import (
+	"fmt"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	ktime "gvisor.dev/gvisor/pkg/sentry/kernel/time"
+	"gvisor.dev/gvisor/pkg/sentry/limits"
+	"reflect"
+	"testing"
+)
+
+// testTaskInfoHooks are hooks, which return information about the task and don't take args
+var testTaskInfoHooks = []string{
+	"getCgroups",
+	"getCredentials",
+	"getNamespaces",
+	"getProcessInfo",
+	"getProcPaths",
+	"getRlimits",
+}
+
+func TestTaskInfoHooks_withMoreArgs_Fails(t *testing.T) {
+	for _, name := range testTaskInfoHooks {
+		t.Run(name, func(t *testing.T) {
+			src := fmt.Sprintf(`
+	function cb() {
+		hooks.%s(10)
+	}
+`, name)
+			testThatCbFailsWithErr(
+				t, src,
+				"no error for hook, which does not require args, when given more then 0")
+		})
+	}
+}
+
+// testCreateInfoTask creates task with uid 1000, gid 1001, supplementary group 27,
+// tgid 42 in a root pid namespace, RLIMIT_NOFILE 256/1024, name "app"
+// and start time 5s after boot
+func testCreateInfoTask() *Task {
+	userns := auth.NewRootUserNamespace()
+	creds := auth.NewUserCredentials(1000, 1001, []auth.KGID{27}, nil, userns)
+
+	pidns := NewRootPIDNamespace(userns)
+	newTaskSet(pidns)
+	tg := &ThreadGroup{pidns: pidns, limits: limits.NewLimitSet()}
+	pidns.tgids[tg] = 42
+	tg.limits.SetUnchecked(limits.NumberOfFiles, limits.Limit{Cur: 256, Max: 1024})
+
+	task := &Task{
+		k:         &Kernel{timekeeper: &Timekeeper{}},
+		tg:        tg,
+		image:     TaskImage{Name: "app"},
+		startTime: ktime.FromSeconds(5),
+	}
+	task.creds.Store(creds)
+	return task
+}
+
+func TestCredentialsHook_returnsTaskIDs(t *testing.T) {
+	task := testCreateInfoTask()
+
+	res, err := (&CredentialsHook{}).createCallback(task)()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	creds := res.(CredentialsDto)
+	if want := (IDsDto{Real: 1000, Effective: 1000, Saved: 1000, FS: 1000}); creds.UID != want {
+		t.Errorf("uid: got %+v, want %+v", creds.UID, want)
+	}
+	if want := (IDsDto{Real: 1001, Effective: 1001, Saved: 1001, FS: 1001}); creds.GID != want {
+		t.Errorf("gid: got %+v, want %+v", creds.GID, want)
+	}
+	if !reflect.DeepEqual(creds.Groups, []uint32{27}) {
+		t.Errorf("groups: got %v, want [27]", creds.Groups)
+	}
+	if creds.Capabilities.Effective != 0 {
+		t.Errorf("effective capabilities of non root user: got %#x, want 0", creds.Capabilities.Effective)
+	}
+}
+
+func TestRlimitsHook_returnsTaskLimits(t *testing.T) {
+	task := testCreateInfoTask()
+
+	res, err := (&RlimitsHook{}).createCallback(task)()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rlimits := res.(map[string]RlimitDto)
+	if want := (RlimitDto{Soft: 256, Hard: 1024, Units: "files"}); rlimits["RLIMIT_NOFILE"] != want {
+		t.Errorf("RLIMIT_NOFILE: got %+v, want %+v", rlimits["RLIMIT_NOFILE"], want)
+	}
+	if got := rlimits["RLIMIT_STACK"]; got.Soft != -1 || got.Hard != -1 {
+		t.Errorf("unset RLIMIT_STACK: got %+v, want unlimited (-1)", got)
+	}
+}
+
+func TestProcessInfoHook_returnsTaskInfo(t *testing.T) {
+	task := testCreateInfoTask()
+
+	res, err := (&ProcessInfoHook{}).createCallback(task)()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := ProcessInfoDto{
+		PID:            42,
+		PPID:           0,
+		Name:           "app",
+		StartTime:      5_000_000_000,
+		StartTimeTicks: 500,
+	}
+	if got := res.(ProcessInfoDto); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNamespacesHook_returnsTaskNamespaces(t *testing.T) {
+	task := testCreateInfoTask()
+
+	res, err := (&NamespacesHook{}).createCallback(task)()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := NamespacesDto{PID: task.PIDNamespace().ID(), InitUser: true}
+	if got := res.(NamespacesDto); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestProcPathsHook_returnsTaskPaths(t *testing.T) {
+	task := testCreateInfoTask()
+	_, mntns, root, tmp := testCreateSymlinkTree(t, &task.k.vfs)
+	task.mountNamespace = mntns
+	task.fsContext = NewFSContext(root, tmp, 0022)
+	defer task.fsContext.DecRef(task)
+
+	res, err := (&ProcPathsHook{}).createCallback(task)()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// task without memory manager has no executable
+	want := ProcPathsDto{Exe: "", Cwd: "/tmp", Root: "/"}
+	if got := res.(ProcPathsDto); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+type testCgroupInode struct {
+	kernfs.Inode
+}
+
+func (*testCgroupInode) Mode() linux.FileMode {
+	return linux.ModeDirectory | 0755
+}
+
+type testCgroupImpl struct {
+	CgroupImpl
+}
+
+func (*testCgroupImpl) HierarchyID() uint32 {
+	return 3
+}
+
+func (*testCgroupImpl) Name() string {
+	return "test"
+}
+
+func (*testCgroupImpl) Controllers() []CgroupController {
+	return nil
+}
+
+func TestCgroupsHook_returnsTaskCgroups(t *testing.T) {
+	task := testCreateInfoTask()
+	d := &kernfs.Dentry{}
+	d.Init(&kernfs.Filesystem{}, &testCgroupInode{})
+	task.cgroups = map[Cgroup]struct{}{{Dentry: d, CgroupImpl: &testCgroupImpl{}}: {}}
+
+	res, err := (&CgroupsHook{}).createCallback(task)()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []TaskCgroupEntry{{HierarchyID: 3, Controllers: "name=test", Path: "/"}}
+	if got := res.([]TaskCgroupEntry); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+package kernel
+
+import (
+	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+)
+
+var writeIntHookWithLessArgs = `
+	function cb() {
+		hooks.writeInt(1, "u8")
+	}
+`
+
+func TestWriteIntHook_withLessArgs_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, writeIntHookWithLessArgs,
+		"no error for hook, which requires 3 args, when given less then 3")
+}
+
+var writeIntHookWithUndefined3Arg = `
+	function cb() {
+		hooks.writeInt(1, "u8", undefined)
+	}
+`
+
+func TestWriteIntHook_withUndefined3Arg_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, writeIntHookWithUndefined3Arg,
+		"no error for hook when 3 arg is undefined")
+}
+
+var writeIntHookWithBytesType = `
+	function cb() {
+		hooks.writeInt(1, "bytes:4", 1)
+	}
+`
+
+func TestWriteIntHook_withBytesType_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, writeIntHookWithBytesType,
+		"no error for hook when type is not an integer")
+}
+
+var writeIntHookWithUnsafeInteger = `
+	function cb() {
+		hooks.writeInt(1, "u64", 9007199254740993)
+	}
+`
+
+func TestWriteIntHook_withUnsafeInteger_Fails(t *testing.T) {
+	testThatCbFailsWithErr(
+		t, writeIntHookWithUnsafeInteger,
+		"no error for hook when value is not a safe integer")
+}
+
+func TestExtractIntegerFromValue(t *testing.T) {
+	vm := goja.New()
+	for _, tc := range []struct {
+		val      goja.Value
+		expected string
+	}{
+		{vm.ToValue(-5), "-5"},
+		{vm.ToValue(float64(1 << 40)), "1099511627776"},
+		{vm.ToValue("18446744073709551615"), "18446744073709551615"},
+	} {
+		got, err := callbacks.ExtractIntegerFromValue(vm, tc.val)
+		if err != nil || got != tc.expected {
+			t.Fatalf("extracting %v: got (%q, %v), expected %q", tc.val, got, err, tc.expected)
+		}
+	}
+
+	for _, val := range []goja.Value{vm.ToValue(float64(1<<53 + 2)), vm.ToValue(1.5), vm.ToValue(true), goja.Null()} {
+		if _, err := callbacks.ExtractIntegerFromValue(vm, val); err == nil {
+			t.Fatalf("no error when extracting %v", val)
+		}
+	}
+}
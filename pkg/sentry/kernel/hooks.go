@@ -3,7 +3,9 @@ package kernel
 import (
 	"cmp"
 	"errors"
+	"fmt"
 	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
 	"slices"
 	"strings"
 	"sync"
@@ -26,6 +28,9 @@ type HookInfoDto struct {
 
 	// ReturnValue - description of the return value
 	ReturnValue string `json:"return-value"`
+
+	// Capability - comma separated capability classes required to call the hook
+	Capability string `json:"capability"`
 }
 
 // GoHook is an interface for dependentHooks, that user can call from js callback
@@ -35,6 +40,10 @@ type GoHook interface {
 
 	// jsName - with this name the hook will be called from js
 	jsName() string
+
+	// capabilities - the capability classes (see callbacks.Capability...) which callback
+	// should be granted to call this hook
+	capabilities() callbacks.CapabilitySet
 }
 
 // TaskIndependentGoHook is an interface for hooks, that user can call from js callback when cb run with/without task
@@ -62,22 +71,18 @@ func disposableDecorator(callback HookCallback) HookCallback {
 	}
 }
 
-// GoHookDecorator added for future restrictions of dependentHooks
-type GoHookDecorator struct {
-	wrapped TaskDependentGoHook
-}
-
-func (decorator *GoHookDecorator) description() HookInfoDto {
-	return decorator.wrapped.description()
-}
-
-func (decorator *GoHookDecorator) jsName() string {
-	return decorator.wrapped.jsName()
-}
+// capabilityDecorator returns the callback of the hook if all the hook capabilities are granted,
+// otherwise returns stub that always fails
+func capabilityDecorator(hook GoHook, granted callbacks.CapabilitySet, callback func() HookCallback) HookCallback {
+	required := hook.capabilities()
+	if granted.HasAll(required) {
+		return callback()
+	}
 
-func (decorator *GoHookDecorator) createCallback(t *Task) HookCallback {
-	cb := decorator.wrapped.createCallback(t)
-	return disposableDecorator(cb)
+	name := hook.jsName()
+	return func(...goja.Value) (interface{}, error) {
+		return nil, fmt.Errorf("hook %s requires capabilities %s", name, required)
+	}
 }
 
 // HooksTable user`s js callback takes Dependent (and/or Independent) Hooks from this table before execution.
@@ -96,7 +101,7 @@ func (ht *HooksTable) registerDependentHook(hook TaskDependentGoHook) error {
 	ht.mutex.Lock()
 	defer ht.mutex.Unlock()
 
-	ht.dependentHooks[hook.jsName()] = hook //&GoHookDecorator{wrapped: hook}
+	ht.dependentHooks[hook.jsName()] = hook
 	return nil
 }
 
@@ -108,7 +113,7 @@ func (ht *HooksTable) registerIndependentHook(hook TaskIndependentGoHook) error
 	ht.mutex.Lock()
 	defer ht.mutex.Unlock()
 
-	ht.independentHooks[hook.jsName()] = hook //&GoHookDecorator{wrapped: hook}
+	ht.independentHooks[hook.jsName()] = hook
 	return nil
 }
 
@@ -128,6 +133,21 @@ func (ht *HooksTable) getDependentHook(hookName string) TaskDependentGoHook {
 	}
 }
 
+// hookCallback returns callback of the hook with given name or nil if there is no such hook.
+// If the hook requires not granted capability, the callback is stub that fails
+func (ht *HooksTable) hookCallback(hookName string, task *Task, granted callbacks.CapabilitySet) HookCallback {
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+
+	if hook, ok := ht.dependentHooks[hookName]; ok {
+		return capabilityDecorator(hook, granted, func() HookCallback { return hook.createCallback(task) })
+	}
+	if hook, ok := ht.independentHooks[hookName]; ok {
+		return capabilityDecorator(hook, granted, hook.createCallback)
+	}
+	return nil
+}
+
 func (ht *HooksTable) getCurrentHooks() []GoHook {
 	if ht == nil {
 		panic("table is nil")
@@ -154,13 +174,15 @@ func (ht *HooksTable) getCurrentHooks() []GoHook {
 	return hooks
 }
 
-// addDependentHooksToContextObject from this context object user`s callback will take dependentHooks
-func (ht *HooksTable) addDependentHooksToContextObject(object *goja.Object, task *Task) error {
+// addDependentHooksToContextObject from this context object user`s callback will take dependentHooks.
+// Hooks which require not granted capabilities are replaced with failing stubs
+func (ht *HooksTable) addDependentHooksToContextObject(object *goja.Object, task *Task, granted callbacks.CapabilitySet) error {
 	ht.mutex.Lock()
 	defer ht.mutex.Unlock()
 
 	for name, hook := range ht.dependentHooks {
-		callback := hook.createCallback(task)
+		hook := hook
+		callback := capabilityDecorator(hook, granted, func() HookCallback { return hook.createCallback(task) })
 		err := object.Set(name, callback)
 		if err != nil {
 			return err
@@ -170,13 +192,14 @@ func (ht *HooksTable) addDependentHooksToContextObject(object *goja.Object, task
 	return nil
 }
 
-// addIndependentHooksToContextObject from this context object user`s callback will take independentHooks
-func (ht *HooksTable) addIndependentHooksToContextObject(object *goja.Object) error {
+// addIndependentHooksToContextObject from this context object user`s callback will take independentHooks.
+// Hooks which require not granted capabilities are replaced with failing stubs
+func (ht *HooksTable) addIndependentHooksToContextObject(object *goja.Object, granted callbacks.CapabilitySet) error {
 	ht.mutex.Lock()
 	defer ht.mutex.Unlock()
 
 	for name, hook := range ht.independentHooks {
-		callback := hook.createCallback()
+		callback := capabilityDecorator(hook, granted, hook.createCallback)
 		err := object.Set(name, callback)
 		if err != nil {
 			return err
@@ -191,28 +214,46 @@ func (ht *HooksTable) addIndependentHooksToContextObject(object *goja.Object) er
 func RegisterHooks(cb *HooksTable) error {
 	dependentGoHooks := []TaskDependentGoHook{
 		&AnonMmapHook{},
+		&AskOperatorHook{},
+		&CgroupsHook{},
+		&CredentialsHook{},
 		&FDHook{},
 		&FDsHook{},
 		&ArgvHook{},
+		&GetRegsHook{},
 		&EnvvGetterHook{},
 		&MmapGetterHook{},
 		&MunmapHook{},
+		&NamespacesHook{},
 		&PidInfoHook{},
+		&ProcessInfoHook{},
+		&ProcPathsHook{},
 		&ReadBytesHook{},
+		&ReadIntHook{},
+		&ReadIovecsHook{},
+		&ReadSockaddrHook{},
 		&ReadStringHook{},
+		&ReadStringArrayHook{},
+		&ReadStructHook{},
+		&RlimitsHook{},
 		&ThreadsResumingHook{},
 		&SignalSendingHook{},
 		&SignalInfoHook{},
+		&SetRegsHook{},
+		&SyscallHook{},
 		&ThreadsStoppingHook{},
 		&ThreadInfoHook{},
 		&UserJSONLogHook{}, // now there is no test file
 		&WriteBytesHook{},
+		&WriteIntHook{},
 		&WriteStringHook{},
 	}
 
 	independentGoHooks := []TaskIndependentGoHook{
 		&AddCbAfterHook{},
 		&AddCbBeforeHook{},
+		&AddCbEmulateHook{},
+		&OnPacketHook{},
 		&PrintHook{},
 		&SignalMaskToSignalNamesHook{},
 		&SignalByNameHook{},
@@ -237,19 +278,21 @@ func RegisterHooks(cb *HooksTable) error {
 
 // DependentHookAddableAdapter implement ContextAddable
 type DependentHookAddableAdapter struct {
-	ht   *HooksTable
-	task *Task
+	ht      *HooksTable
+	task    *Task
+	granted callbacks.CapabilitySet
 }
 
 func (d *DependentHookAddableAdapter) addSelfToContextObject(object *goja.Object) error {
-	return d.ht.addDependentHooksToContextObject(object, d.task)
+	return d.ht.addDependentHooksToContextObject(object, d.task, d.granted)
 }
 
 // IndependentHookAddableAdapter implement ContextAddable
 type IndependentHookAddableAdapter struct {
-	ht *HooksTable
+	ht      *HooksTable
+	granted callbacks.CapabilitySet
 }
 
 func (d *IndependentHookAddableAdapter) addSelfToContextObject(object *goja.Object) error {
-	return d.ht.addIndependentHooksToContextObject(object)
+	return d.ht.addIndependentHooksToContextObject(object, d.granted)
 }
@@ -1,12 +1,22 @@
 package kernel
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/marshal"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
+	"gvisor.dev/gvisor/pkg/sentry/limits"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"net"
+	"strconv"
+	"strings"
 )
 
 func ReadBytes(t *Task, addr uintptr, dst []byte) (int, error) {
@@ -225,6 +235,232 @@ func findPath(t *Task, fd int32) string {
 	return name
 }
 
+// FilePath returns the path of the file with descriptor fd, false if there is no such file
+func FilePath(t *Task, fd int32) (string, bool) {
+	file := t.GetFile(fd)
+	if file == nil {
+		return "", false
+	}
+	defer file.DecRef(t)
+
+	root := t.FSContext().RootDirectory()
+	defer root.DecRef(t)
+
+	name, err := t.Kernel().VFS().PathnameWithDeleted(t, root, file.VirtualDentry())
+	return name, err == nil
+}
+
+// WorkingDirectory returns the path of the current working directory of the task
+func WorkingDirectory(t *Task) string {
+	fsContext := t.FSContext()
+	if fsContext == nil {
+		return ""
+	}
+	root := fsContext.RootDirectory()
+	defer root.DecRef(t)
+	cwd := fsContext.WorkingDirectory()
+	defer cwd.DecRef(t)
+
+	name, _ := t.Kernel().VFS().PathnameWithDeleted(t, root, cwd)
+	return name
+}
+
+// ExecutablePath returns the path of the executable of the task, empty string if it's unknown
+func ExecutablePath(t *Task) string {
+	mm := t.MemoryManager()
+	if mm == nil {
+		return ""
+	}
+	exe := mm.Executable()
+	if exe == nil {
+		return ""
+	}
+	defer exe.DecRef(t)
+
+	root := t.FSContext().RootDirectory()
+	defer root.DecRef(t)
+
+	name, _ := t.Kernel().VFS().PathnameWithDeleted(t, root, exe.VirtualDentry())
+	return name
+}
+
+// RootDirectoryPath returns the path of the root directory of the task (changed by chroot)
+// relative to the root of its mount namespace
+func RootDirectoryPath(t *Task) string {
+	fsContext := t.FSContext()
+	mntns := t.MountNamespace()
+	if fsContext == nil || mntns == nil {
+		return ""
+	}
+	nsRoot := mntns.Root(t)
+	defer nsRoot.DecRef(t)
+	root := fsContext.RootDirectory()
+	defer root.DecRef(t)
+
+	name, _ := t.Kernel().VFS().PathnameWithDeleted(t, nsRoot, root)
+	return name
+}
+
+type IDsDto struct {
+	Real      uint32 `json:"real"`
+	Effective uint32 `json:"effective"`
+	Saved     uint32 `json:"saved"`
+	FS        uint32 `json:"fs"`
+}
+
+type CapabilitiesDto struct {
+	Inheritable uint64 `json:"inheritable"`
+	Permitted   uint64 `json:"permitted"`
+	Effective   uint64 `json:"effective"`
+	Bounding    uint64 `json:"bounding"`
+}
+
+type CredentialsDto struct {
+	UID          IDsDto          `json:"uid"`
+	GID          IDsDto          `json:"gid"`
+	Groups       []uint32        `json:"groups"`
+	Capabilities CapabilitiesDto `json:"capabilities"`
+	KeepCaps     bool            `json:"keepCaps"`
+}
+
+// CredentialsGetter returns credentials of the task like /proc/[pid]/status does.
+// Filesystem IDs aren't implemented in gVisor, so effective IDs are used instead
+func CredentialsGetter(t *Task) CredentialsDto {
+	creds := t.Credentials()
+	userns := t.UserNamespace()
+
+	euid := uint32(creds.EffectiveKUID.In(userns).OrOverflow())
+	egid := uint32(creds.EffectiveKGID.In(userns).OrOverflow())
+	dto := CredentialsDto{
+		UID: IDsDto{
+			Real:      uint32(creds.RealKUID.In(userns).OrOverflow()),
+			Effective: euid,
+			Saved:     uint32(creds.SavedKUID.In(userns).OrOverflow()),
+			FS:        euid,
+		},
+		GID: IDsDto{
+			Real:      uint32(creds.RealKGID.In(userns).OrOverflow()),
+			Effective: egid,
+			Saved:     uint32(creds.SavedKGID.In(userns).OrOverflow()),
+			FS:        egid,
+		},
+		Groups: make([]uint32, 0, len(creds.ExtraKGIDs)),
+		Capabilities: CapabilitiesDto{
+			Inheritable: uint64(creds.InheritableCaps),
+			Permitted:   uint64(creds.PermittedCaps),
+			Effective:   uint64(creds.EffectiveCaps),
+			Bounding:    uint64(creds.BoundingCaps),
+		},
+		KeepCaps: creds.KeepCaps,
+	}
+	for _, kgid := range creds.ExtraKGIDs {
+		dto.Groups = append(dto.Groups, uint32(kgid.In(userns).OrOverflow()))
+	}
+	return dto
+}
+
+type NamespacesDto struct {
+	PID  uint64 `json:"pid"`
+	Net  uint64 `json:"net"`
+	Mnt  uint64 `json:"mnt"`
+	UTS  uint64 `json:"uts"`
+	IPC  uint64 `json:"ipc"`
+	User uint64 `json:"user"`
+	// InitUser is true if the task is in the root user namespace
+	InitUser bool `json:"initUser"`
+}
+
+// NamespacesGetter returns IDs of task's namespaces, they are the same as inode numbers
+// in /proc/[pid]/ns links. gVisor doesn't assign IDs to user namespaces, so User is always 0
+func NamespacesGetter(t *Task) NamespacesDto {
+	dto := NamespacesDto{}
+	if pidns := t.PIDNamespace(); pidns != nil {
+		dto.PID = pidns.ID()
+	}
+	if netns := t.GetNetworkNamespace(); netns != nil {
+		if inode := netns.GetInode(); inode != nil {
+			dto.Net = inode.Ino()
+		}
+		netns.DecRef(t)
+	}
+	if mntns := t.MountNamespace(); mntns != nil {
+		if inode, ok := mntns.Refs.(*nsfs.Inode); ok {
+			dto.Mnt = inode.Ino()
+		}
+	}
+	if utsns := t.UTSNamespace(); utsns != nil {
+		if inode := utsns.GetInode(); inode != nil {
+			dto.UTS = inode.Ino()
+		}
+	}
+	if ipcns := t.IPCNamespace(); ipcns != nil {
+		if inode := ipcns.GetInode(); inode != nil {
+			dto.IPC = inode.Ino()
+		}
+	}
+	if userns := t.UserNamespace(); userns != nil {
+		dto.InitUser = userns == userns.Root()
+	}
+	return dto
+}
+
+type RlimitDto struct {
+	// Soft and Hard are -1 for unlimited resources
+	Soft  int64  `json:"soft"`
+	Hard  int64  `json:"hard"`
+	Units string `json:"units,omitempty"`
+}
+
+func rlimitValue(val uint64) int64 {
+	if val == limits.Infinity {
+		return -1
+	}
+	return int64(val)
+}
+
+// RlimitsGetter returns resource limits of the task (like /proc/[pid]/limits) by their names (RLIMIT_NOFILE, ...)
+func RlimitsGetter(t *Task) map[string]RlimitDto {
+	taskLimits := t.Limits()
+	dto := make(map[string]RlimitDto, len(limits.FromLinuxResourceName))
+	for name, lt := range limits.FromLinuxResourceName {
+		l := taskLimits.Get(lt)
+		dto[name] = RlimitDto{
+			Soft:  rlimitValue(l.Cur),
+			Hard:  rlimitValue(l.Max),
+			Units: lt.Unit(),
+		}
+	}
+	return dto
+}
+
+type ProcessInfoDto struct {
+	PID  int32  `json:"PID"`
+	PPID int32  `json:"PPID"`
+	Name string `json:"name"`
+	// StartTime is the time when the task started in nanoseconds since Unix epoch
+	StartTime int64 `json:"startTime"`
+	// StartTimeTicks is the time when the task started in clock ticks since boot (like in /proc/[pid]/stat)
+	StartTimeTicks int64 `json:"startTimeTicks"`
+}
+
+// ProcessInfoGetter returns PID and parent PID of the task in its PID namespace, its name and start time
+func ProcessInfoGetter(t *Task) ProcessInfoDto {
+	pidns := t.PIDNamespace()
+	ppid := ThreadID(0)
+	if parent := t.Parent(); parent != nil {
+		ppid = pidns.IDOfThreadGroup(parent.ThreadGroup())
+	}
+
+	startTime := t.StartTime()
+	return ProcessInfoDto{
+		PID:            int32(pidns.IDOfThreadGroup(t.ThreadGroup())),
+		PPID:           int32(ppid),
+		Name:           t.Name(),
+		StartTime:      startTime.Nanoseconds(),
+		StartTimeTicks: int64(linux.ClockTFromDuration(startTime.Sub(t.Kernel().Timekeeper().BootTime()))),
+	}
+}
+
 // parseMask parses fd's mask into readable format
 // Example: rwx---r--
 func parseMask(mask uint16) string {
@@ -339,3 +575,480 @@ func fillThreadInfoDto(t *Task) ThreadInfoDto {
 
 	return dto
 }
+
+// pointerSize is the size of pointer in the task memory (gVisor supports only 64-bit architectures)
+const pointerSize = 8
+
+// MemoryType describes how the value is stored in the memory of the task
+type MemoryType struct {
+	// Name is the name of type like u32be
+	Name string
+
+	// Size is amount of bytes occupied by the value
+	Size int
+
+	// Signed is true for signed integers
+	Signed bool
+
+	// Raw is true for byte arrays (bytes:N), such values are not decoded
+	Raw bool
+
+	// Order is byte order of integer value
+	Order binary.ByteOrder
+}
+
+// Align returns the alignment of the type in C struct
+func (mt MemoryType) Align() int {
+	if mt.Raw {
+		return 1
+	}
+	return mt.Size
+}
+
+// ParseMemoryType parses type name. Supported types:
+//
+// - u8, i8, u16, i16, u32, i32, u64, i64 optionally followed by be or le (native byte order is used by default)
+//
+// - ptr (native pointer)
+//
+// - bytes:N (array of N bytes)
+func ParseMemoryType(name string) (MemoryType, error) {
+	mt := MemoryType{Name: name, Order: hostarch.ByteOrder}
+
+	if count, ok := strings.CutPrefix(name, "bytes:"); ok {
+		size, err := strconv.Atoi(count)
+		if err != nil || size <= 0 {
+			return MemoryType{}, fmt.Errorf("bad size of bytes type %q", name)
+		}
+		mt.Size = size
+		mt.Raw = true
+		return mt, nil
+	}
+	if name == "ptr" {
+		mt.Size = pointerSize
+		return mt, nil
+	}
+
+	base := name
+	if trimmed, ok := strings.CutSuffix(name, "be"); ok {
+		base, mt.Order = trimmed, binary.BigEndian
+	} else if trimmed, ok := strings.CutSuffix(name, "le"); ok {
+		base, mt.Order = trimmed, binary.LittleEndian
+	}
+	if len(base) < 2 || (base[0] != 'u' && base[0] != 'i') {
+		return MemoryType{}, fmt.Errorf("unknown type %q", name)
+	}
+	bits, err := strconv.Atoi(base[1:])
+	if err != nil || (bits != 8 && bits != 16 && bits != 32 && bits != 64) {
+		return MemoryType{}, fmt.Errorf("unknown type %q", name)
+	}
+	mt.Size = bits / 8
+	mt.Signed = base[0] == 'i'
+	return mt, nil
+}
+
+// nativeOrder returns bytes of the integer value of type mt in native byte order
+func nativeOrder(mt MemoryType, buf []byte) []byte {
+	if mt.Order == hostarch.ByteOrder {
+		return buf
+	}
+	swapped := make([]byte, len(buf))
+	for i := range buf {
+		swapped[len(buf)-1-i] = buf[i]
+	}
+	return swapped
+}
+
+// decodeMemoryValue converts bytes of the value to go value: int64 for pointers,
+// int64 and uint64 for signed and unsigned integers up to 32 bits, decimal
+// string for 64-bit integers (they can't be represented by JS numbers
+// precisely) and []byte for raw arrays
+func decodeMemoryValue(mt MemoryType, buf []byte) interface{} {
+	if mt.Raw {
+		return buf
+	}
+
+	buf = nativeOrder(mt, buf)
+	var val uint64
+	switch mt.Size {
+	case 1:
+		var v primitive.Uint8
+		v.UnmarshalBytes(buf)
+		val = uint64(v)
+	case 2:
+		var v primitive.Uint16
+		v.UnmarshalBytes(buf)
+		val = uint64(v)
+	case 4:
+		var v primitive.Uint32
+		v.UnmarshalBytes(buf)
+		val = uint64(v)
+	case 8:
+		var v primitive.Uint64
+		v.UnmarshalBytes(buf)
+		val = uint64(v)
+	}
+
+	switch {
+	case mt.Name == "ptr":
+		return int64(val)
+	case mt.Size == 8 && mt.Signed:
+		return strconv.FormatInt(int64(val), 10)
+	case mt.Size == 8:
+		return strconv.FormatUint(val, 10)
+	case mt.Signed:
+		shift := 64 - 8*mt.Size
+		return int64(val<<shift) >> shift
+	default:
+		return val
+	}
+}
+
+// encodeMemoryValue converts integer to bytes of the value. val is decimal or
+// 0x-prefixed hexadecimal representation of the integer, it must fit in the type
+func encodeMemoryValue(mt MemoryType, val string) ([]byte, error) {
+	if mt.Raw {
+		return nil, fmt.Errorf("type %q is not an integer", mt.Name)
+	}
+
+	var raw uint64
+	if mt.Signed {
+		v, err := strconv.ParseInt(val, 0, 8*mt.Size)
+		if err != nil {
+			return nil, fmt.Errorf("bad value for type %q: %w", mt.Name, err)
+		}
+		raw = uint64(v)
+	} else {
+		v, err := strconv.ParseUint(val, 0, 8*mt.Size)
+		if err != nil {
+			return nil, fmt.Errorf("bad value for type %q: %w", mt.Name, err)
+		}
+		raw = v
+	}
+
+	var m marshal.Marshallable
+	switch mt.Size {
+	case 1:
+		m = primitive.AllocateUint8(uint8(raw))
+	case 2:
+		m = primitive.AllocateUint16(uint16(raw))
+	case 4:
+		m = primitive.AllocateUint32(uint32(raw))
+	case 8:
+		m = primitive.AllocateUint64(raw)
+	}
+	buf := make([]byte, m.SizeBytes())
+	m.MarshalBytes(buf)
+	return nativeOrder(mt, buf), nil
+}
+
+// readFull reads exactly len(dst) bytes from the task memory
+func readFull(t *Task, addr uintptr, dst []byte) error {
+	n, err := ReadBytes(t, addr, dst)
+	if err != nil {
+		return err
+	}
+	if n != len(dst) {
+		return linuxerr.EFAULT
+	}
+	return nil
+}
+
+// ReadTyped reads value of the given type by addr
+func ReadTyped(cc marshal.CopyContext, addr uintptr, mt MemoryType) (interface{}, error) {
+	buf := make(primitive.ByteSlice, mt.Size)
+	if _, err := buf.CopyIn(cc, hostarch.Addr(addr)); err != nil {
+		return nil, err
+	}
+	return decodeMemoryValue(mt, buf), nil
+}
+
+// WriteTyped writes value of the given type by addr, see encodeMemoryValue for
+// the format of val
+func WriteTyped(cc marshal.CopyContext, addr uintptr, mt MemoryType, val string) (int, error) {
+	buf, err := encodeMemoryValue(mt, val)
+	if err != nil {
+		return 0, err
+	}
+	return primitive.CopyByteSliceOut(cc, hostarch.Addr(addr), buf)
+}
+
+// StructField is a named field of struct layout
+type StructField struct {
+	Name string
+	Type MemoryType
+}
+
+// structOffsets returns offsets of fields and the size of struct, fields are aligned like in C
+func structOffsets(fields []StructField) ([]int, int) {
+	offsets := make([]int, len(fields))
+	offset := 0
+	for i, field := range fields {
+		align := field.Type.Align()
+		offset = (offset + align - 1) / align * align
+		offsets[i] = offset
+		offset += field.Type.Size
+	}
+	return offsets, offset
+}
+
+// ReadStruct reads struct described by fields by addr and returns values of fields by their names
+func ReadStruct(cc marshal.CopyContext, addr uintptr, fields []StructField) (map[string]interface{}, error) {
+	offsets, size := structOffsets(fields)
+	buf := make(primitive.ByteSlice, size)
+	if _, err := buf.CopyIn(cc, hostarch.Addr(addr)); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for i, field := range fields {
+		values[field.Name] = decodeMemoryValue(field.Type, buf[offsets[i]:offsets[i]+field.Type.Size])
+	}
+	return values, nil
+}
+
+// execMaxElemSize and execMaxTotalSize limit string arrays same as in execve
+const (
+	execMaxElemSize  = 32 * hostarch.PageSize
+	execMaxTotalSize = 2 * 1024 * 1024
+)
+
+// ReadStringArray reads NULL-terminated array of pointers to NUL-terminated strings (like argv or envp)
+func ReadStringArray(t *Task, addr uintptr) ([]string, error) {
+	return t.CopyInVector(hostarch.Addr(addr), execMaxElemSize, execMaxTotalSize)
+}
+
+type IovecDto struct {
+	Base int64 `json:"base"`
+	Len  int64 `json:"len"`
+}
+
+// ReadIovecs reads array of struct iovec with count elements by addr
+func ReadIovecs(t *Task, addr uintptr, count int) ([]IovecDto, error) {
+	if count < 0 || count > linux.UIO_MAXIOV {
+		return nil, linuxerr.EINVAL
+	}
+
+	ranges, err := t.CopyInIovecsAsSlice(hostarch.Addr(addr), count)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]IovecDto, 0, len(ranges))
+	for _, ar := range ranges {
+		dtos = append(dtos, IovecDto{Base: int64(ar.Start), Len: int64(ar.Length())})
+	}
+	return dtos, nil
+}
+
+type SockaddrDto struct {
+	Family   uint16 `json:"family"`
+	Addr     string `json:"addr,omitempty"`
+	Port     uint16 `json:"port,omitempty"`
+	FlowInfo uint32 `json:"flowinfo,omitempty"`
+	ScopeID  uint32 `json:"scopeID,omitempty"`
+	Path     string `json:"path,omitempty"`
+}
+
+// ReadSockaddr reads struct sockaddr of length addrlen by addr and decodes
+// AF_INET, AF_INET6 and AF_UNIX addresses. For other families only family is filled
+func ReadSockaddr(t *Task, addr uintptr, addrlen int) (SockaddrDto, error) {
+	if addrlen < 2 || addrlen > linux.SockAddrMax {
+		return SockaddrDto{}, linuxerr.EINVAL
+	}
+	buf := make([]byte, addrlen)
+	if err := readFull(t, addr, buf); err != nil {
+		return SockaddrDto{}, err
+	}
+
+	dto := SockaddrDto{Family: hostarch.ByteOrder.Uint16(buf)}
+	switch dto.Family {
+	case linux.AF_INET:
+		if addrlen < 8 {
+			return SockaddrDto{}, linuxerr.EINVAL
+		}
+		dto.Port = binary.BigEndian.Uint16(buf[2:])
+		dto.Addr = net.IP(buf[4:8]).String()
+
+	case linux.AF_INET6:
+		if addrlen < 24 {
+			return SockaddrDto{}, linuxerr.EINVAL
+		}
+		dto.Port = binary.BigEndian.Uint16(buf[2:])
+		dto.FlowInfo = binary.BigEndian.Uint32(buf[4:])
+		dto.Addr = net.IP(buf[8:24]).String()
+		if addrlen >= 28 {
+			dto.ScopeID = hostarch.ByteOrder.Uint32(buf[24:])
+		}
+
+	case linux.AF_UNIX:
+		path := buf[2:min(addrlen, 2+linux.UnixPathMax)]
+		if len(path) > 0 && path[0] == 0 {
+			// Abstract socket address is shown with @ like in /proc/net/unix.
+			dto.Path = "@" + string(path[1:])
+		} else {
+			if end := bytes.IndexByte(path, 0); end >= 0 {
+				path = path[:end]
+			}
+			dto.Path = string(path)
+		}
+	}
+	return dto, nil
+}
+
+// RegistersGetter returns user registers of the task as decimal strings, because js numbers
+// can't hold 64-bit values. Names of registers are architecture-specific and the same as
+// in arch.Context.RegisterMap
+func RegistersGetter(t *Task) (map[string]string, error) {
+	regs, err := t.Arch().RegisterMap()
+	if err != nil {
+		return nil, err
+	}
+
+	dto := make(map[string]string, len(regs))
+	for name, val := range regs {
+		dto[name] = strconv.FormatUint(uint64(val), 10)
+	}
+	return dto, nil
+}
+
+// RegistersSetter sets user registers of the task by names (same as in RegistersGetter).
+// Registers missing in values keep their current values. New values are validated
+// like in ptrace(PTRACE_SETREGS), registers aren't changed if any of them is invalid
+func RegistersSetter(t *Task, values map[string]uint64) error {
+	ac := t.Arch()
+
+	var current bytes.Buffer
+	if _, err := ac.PtraceGetRegs(&current); err != nil {
+		return err
+	}
+	var regs linux.PtraceRegs
+	regs.UnmarshalBytes(current.Bytes()[:regs.SizeBytes()])
+	fields := ptraceRegisterFields(&regs)
+
+	currentMap, err := ac.RegisterMap()
+	if err != nil {
+		return err
+	}
+	archSpecific := make(map[string]uint64, len(archSpecificRegisters))
+	for name := range archSpecificRegisters {
+		archSpecific[name] = uint64(currentMap[name])
+	}
+
+	for name, val := range values {
+		if field, ok := fields[name]; ok {
+			*field = val
+		} else if _, ok := archSpecificRegisters[name]; ok {
+			archSpecific[name] = val
+		} else {
+			return fmt.Errorf("unknown register %s", name)
+		}
+	}
+
+	// Values are applied to a copy of the registers first, so nothing is
+	// changed if any of them is rejected.
+	var staged arch.Context64
+	staged.Regs = ac.Regs
+	buf := make([]byte, regs.SizeBytes())
+	regs.MarshalBytes(buf)
+	if _, err := staged.PtraceSetRegs(bytes.NewReader(buf)); err != nil {
+		return err
+	}
+	// PtraceSetRegs resets registers which are not part of linux.PtraceRegs.
+	for name, val := range archSpecific {
+		if !setArchSpecificRegister(&staged, name, val) {
+			return fmt.Errorf("bad value of register %s", name)
+		}
+	}
+	ac.Regs = staged.Regs
+	return nil
+}
+
+// deniedInjectedSyscalls can't be invoked from callbacks: they replace the task image,
+// terminate or fork it, or rewrite its registers, so they can't be nested into another syscall.
+// Syscalls which sleep or wait for other tasks are denied too, because js runtime stays
+// locked while the injected syscall is executed
+var deniedInjectedSyscalls = map[string]struct{}{
+	"execve":          {},
+	"execveat":        {},
+	"exit":            {},
+	"exit_group":      {},
+	"clone":           {},
+	"clone3":          {},
+	"fork":            {},
+	"vfork":           {},
+	"rt_sigreturn":    {},
+	"restart_syscall": {},
+
+	"pause":           {},
+	"nanosleep":       {},
+	"clock_nanosleep": {},
+	"wait4":           {},
+	"waitid":          {},
+	"futex":           {},
+	"futex_waitv":     {},
+	"rt_sigsuspend":   {},
+	"rt_sigtimedwait": {},
+	"select":          {},
+	"pselect6":        {},
+	"poll":            {},
+	"ppoll":           {},
+	"epoll_wait":      {},
+	"epoll_pwait":     {},
+	"epoll_pwait2":    {},
+	"accept":          {},
+	"accept4":         {},
+	"connect":         {},
+	"msgrcv":          {},
+	"msgsnd":          {},
+	"semop":           {},
+	"semtimedop":      {},
+	"mq_timedreceive": {},
+	"mq_timedsend":    {},
+	"io_getevents":    {},
+	"io_pgetevents":   {},
+	"flock":           {},
+}
+
+// InvokeSyscall executes implementation of syscall sysno from the SyscallTable of t on behalf of t.
+// Injected syscalls don't trigger callbacks and can't be nested. Restart errors are reported as EINTR.
+// The runtime stays locked while the syscall is executed, so virtual files can't be accessed from it
+// and packets filtered by it are handled like packets arriving while js runtime is busy.
+// Returned map contains ret (-1 on error) and errno (0 on success)
+//
+// Preconditions: runtime.Mutex is locked.
+func InvokeSyscall(t *Task, sysno uintptr, args arch.SyscallArguments) (map[string]int64, error) {
+	if t.injectingSyscall {
+		return nil, errors.New("nested injected syscalls are not allowed")
+	}
+
+	table := t.SyscallTable()
+	name := table.LookupName(sysno)
+	if _, denied := deniedInjectedSyscalls[name]; denied {
+		return nil, fmt.Errorf("syscall %s can't be invoked from callback", name)
+	}
+	t.injectingSyscall = true
+	defer func() { t.injectingSyscall = false }()
+
+	var rval uintptr
+	var ctrl *SyscallControl
+	var err error
+	if fn := table.Lookup(sysno); fn != nil {
+		rval, ctrl, err = fn(t, sysno, args)
+	} else {
+		rval, err = table.Missing(t, sysno, args)
+	}
+
+	// Syscalls switching the task to another state are denied above, the switch
+	// can't be done from a callback.
+	if ctrl != nil && (ctrl.next != nil || ctrl.ignoreReturn) {
+		return nil, fmt.Errorf("syscall %s can't be completed from callback", name)
+	}
+	if linuxerr.IsRestartError(err) {
+		err = linuxerr.EINTR
+	}
+
+	if err != nil {
+		return map[string]int64{"ret": -1, "errno": int64(ExtractErrno(err, int(sysno)))}, nil
+	}
+	return map[string]int64{"ret": int64(rval), "errno": 0}, nil
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"github.com/dop251/goja"
 	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
 	util "gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // dependentHooks impls
@@ -17,6 +20,7 @@ type PrintHook struct{}
 func (ph *PrintHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        ph.jsName(),
+		Capability:  ph.capabilities().String(),
 		Description: "Prints all passed args",
 		Args:        "\nmsgs\t...any\t(values to be printed);\n",
 		ReturnValue: "null\n",
@@ -27,6 +31,10 @@ func (ph *PrintHook) jsName() string {
 	return "print"
 }
 
+func (ph *PrintHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (ph *PrintHook) createCallback() HookCallback {
 	return func(args ...goja.Value) (_ interface{}, err error) {
 		strs := make([]string, len(args))
@@ -59,6 +67,7 @@ type WriteBytesHook struct{}
 func (hook *WriteBytesHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Write bytes from provided buffer by provided addr. Always tries to write all bytes from buffer",
 		Args: "\naddr\tnumber\t(data from buffer will be written starting from this addr);\n" +
 			"buffer\tArrayBuffer\t(buffer which contains data to be written);\n",
@@ -70,6 +79,10 @@ func (hook *WriteBytesHook) jsName() string {
 	return "writeBytes"
 }
 
+func (hook *WriteBytesHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyMemory)
+}
+
 func (hook *WriteBytesHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -104,6 +117,7 @@ type ReadBytesHook struct{}
 func (hook *ReadBytesHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Read bytes to provided buffer by provided addr. Always tries to read count bytes",
 		Args: "\naddr\tnumber\t(data from address space will be read starting from this addr);\n" +
 			"count\tnumber\t(amount of bytes to read from address space);\n",
@@ -115,6 +129,10 @@ func (hook *ReadBytesHook) jsName() string {
 	return "readBytes"
 }
 
+func (hook *ReadBytesHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *ReadBytesHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -150,6 +168,7 @@ type WriteStringHook struct{}
 func (hook *WriteStringHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Write provided string by provided addr",
 		Args: "\naddr\tnumber\t(string will be written starting from this addr);\n" +
 			"str\tstring\t(string to be written);\n",
@@ -161,6 +180,10 @@ func (hook *WriteStringHook) jsName() string {
 	return "writeString"
 }
 
+func (hook *WriteStringHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyMemory)
+}
+
 func (hook *WriteStringHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -195,6 +218,7 @@ type ReadStringHook struct{}
 func (hook *ReadStringHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Read string str by provided addr",
 		Args: "\naddr\tnumber\t(string will be read starting from this addr);\n" +
 			"count\tnumber\t(amount of bytes to read from address space);\n",
@@ -206,6 +230,10 @@ func (hook *ReadStringHook) jsName() string {
 	return "readString"
 }
 
+func (hook *ReadStringHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *ReadStringHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -235,11 +263,318 @@ func (hook *ReadStringHook) createCallback(t *Task) HookCallback {
 	}
 }
 
+type ReadIntHook struct{}
+
+func (hook *ReadIntHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Read integer of provided type by provided addr",
+		Args: "\naddr\tnumber\t(integer will be read starting from this addr);\n" +
+			"type\tstring\t(u8, i8, u16, i16, u32, i32, u64, i64 with optional be/le suffix or ptr);\n",
+		ReturnValue: "val\tnumber|string\t(read integer, 64-bit integers except ptr are returned as decimal strings)\n",
+	}
+}
+
+func (hook *ReadIntHook) jsName() string {
+	return "readInt"
+}
+
+func (hook *ReadIntHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *ReadIntHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+
+		runtime := GetJsRuntime()
+		if len(args) != 2 {
+			return nil, util.ArgsCountMismatchError(2, len(args))
+		}
+
+		addr, err := util.ExtractPtrFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		var typeName string
+		typeName, err = util.ExtractStringFromValue(runtime.JsVM, args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		var mt MemoryType
+		mt, err = ParseMemoryType(typeName)
+		if err != nil {
+			return nil, err
+		}
+		if mt.Raw {
+			return nil, fmt.Errorf("type %q is not an integer", typeName)
+		}
+
+		return ReadTyped(t, addr, mt)
+	}
+}
+
+type WriteIntHook struct{}
+
+func (hook *WriteIntHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Write integer of provided type by provided addr",
+		Args: "\naddr\tnumber\t(integer will be written starting from this addr);\n" +
+			"type\tstring\t(u8, i8, u16, i16, u32, i32, u64, i64 with optional be/le suffix or ptr);\n" +
+			"val\tnumber|string\t(integer to be written, it must fit in the type; values beyond 2^53 must be passed as decimal or 0x-prefixed strings);\n",
+		ReturnValue: "count\tnumber\t(amount of bytes really written)\n",
+	}
+}
+
+func (hook *WriteIntHook) jsName() string {
+	return "writeInt"
+}
+
+func (hook *WriteIntHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyMemory)
+}
+
+func (hook *WriteIntHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+
+		runtime := GetJsRuntime()
+		if len(args) != 3 {
+			return nil, util.ArgsCountMismatchError(3, len(args))
+		}
+
+		addr, err := util.ExtractPtrFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		var typeName string
+		typeName, err = util.ExtractStringFromValue(runtime.JsVM, args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		var val string
+		val, err = util.ExtractIntegerFromValue(runtime.JsVM, args[2])
+		if err != nil {
+			return nil, err
+		}
+
+		var mt MemoryType
+		mt, err = ParseMemoryType(typeName)
+		if err != nil {
+			return nil, err
+		}
+
+		return WriteTyped(t, addr, mt, val)
+	}
+}
+
+type ReadStringArrayHook struct{}
+
+func (hook *ReadStringArrayHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Read NULL-terminated array of pointers to strings (like argv or envp of execve) by provided addr",
+		Args:        "\naddr\tnumber\t(address of the first pointer);\n",
+		ReturnValue: "strs\t[]string\t(read strings)\n",
+	}
+}
+
+func (hook *ReadStringArrayHook) jsName() string {
+	return "readStringArray"
+}
+
+func (hook *ReadStringArrayHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *ReadStringArrayHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+
+		runtime := GetJsRuntime()
+		if len(args) != 1 {
+			return nil, util.ArgsCountMismatchError(1, len(args))
+		}
+
+		addr, err := util.ExtractPtrFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return ReadStringArray(t, addr)
+	}
+}
+
+type ReadIovecsHook struct{}
+
+func (hook *ReadIovecsHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Read array of struct iovec by provided addr",
+		Args: "\naddr\tnumber\t(address of the array);\n" +
+			"count\tnumber\t(amount of iovecs in the array);\n",
+		ReturnValue: "iovecs []object (array of iovec dtos)\n" +
+			"{\n" +
+			"\tbase number,\n" +
+			"\tlen number\n" +
+			"};\n",
+	}
+}
+
+func (hook *ReadIovecsHook) jsName() string {
+	return "readIovecs"
+}
+
+func (hook *ReadIovecsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *ReadIovecsHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+
+		runtime := GetJsRuntime()
+		if len(args) != 2 {
+			return nil, util.ArgsCountMismatchError(2, len(args))
+		}
+
+		addr, err := util.ExtractPtrFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		var count int64
+		count, err = util.ExtractInt64FromValue(runtime.JsVM, args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return ReadIovecs(t, addr, int(count))
+	}
+}
+
+type ReadSockaddrHook struct{}
+
+func (hook *ReadSockaddrHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Read and decode struct sockaddr (AF_INET, AF_INET6 and AF_UNIX) by provided addr",
+		Args: "\naddr\tnumber\t(address of the sockaddr);\n" +
+			"addrlen\tnumber\t(length of the sockaddr);\n",
+		ReturnValue: "SockaddrDto json \n" +
+			"{\n" +
+			"\tfamily number,\n" +
+			"\taddr string (ip address for AF_INET and AF_INET6),\n" +
+			"\tport number (for AF_INET and AF_INET6),\n" +
+			"\tflowinfo number (for AF_INET6),\n" +
+			"\tscopeID number (for AF_INET6),\n" +
+			"\tpath string (for AF_UNIX, abstract addresses start with @)\n" +
+			"};\n",
+	}
+}
+
+func (hook *ReadSockaddrHook) jsName() string {
+	return "readSockaddr"
+}
+
+func (hook *ReadSockaddrHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *ReadSockaddrHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+
+		runtime := GetJsRuntime()
+		if len(args) != 2 {
+			return nil, util.ArgsCountMismatchError(2, len(args))
+		}
+
+		addr, err := util.ExtractPtrFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		var addrlen int64
+		addrlen, err = util.ExtractInt64FromValue(runtime.JsVM, args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return ReadSockaddr(t, addr, int(addrlen))
+	}
+}
+
+type ReadStructHook struct{}
+
+func (hook *ReadStructHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Read struct by provided addr according to layout. Fields are placed in order of layout keys and aligned like in C",
+		Args: "\naddr\tnumber\t(address of the struct);\n" +
+			"layout\tobject\t(maps field names to types: u8, i8, u16, i16, u32, i32, u64, i64 with optional be/le suffix, ptr or bytes:N);\n",
+		ReturnValue: "struct\tobject\t(maps field names to read values)\n",
+	}
+}
+
+func (hook *ReadStructHook) jsName() string {
+	return "readStruct"
+}
+
+func (hook *ReadStructHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *ReadStructHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+
+		runtime := GetJsRuntime()
+		if len(args) != 2 {
+			return nil, util.ArgsCountMismatchError(2, len(args))
+		}
+
+		addr, err := util.ExtractPtrFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if goja.IsNull(args[1]) || goja.IsUndefined(args[1]) {
+			return nil, util.ErrNullOrUndefined
+		}
+		layout := args[1].ToObject(runtime.JsVM)
+
+		var fields []StructField
+		for _, key := range layout.Keys() {
+			var typeName string
+			typeName, err = util.ExtractStringFromValue(runtime.JsVM, layout.Get(key))
+			if err != nil {
+				return nil, err
+			}
+
+			var mt MemoryType
+			mt, err = ParseMemoryType(typeName)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, StructField{Name: key, Type: mt})
+		}
+
+		return ReadStruct(t, addr, fields)
+	}
+}
+
 type EnvvGetterHook struct{}
 
 func (hook *EnvvGetterHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Provides environment variables of the Task",
 		Args:        "\nno args;\n",
 		ReturnValue: "envs\t[]string\t(array of strings, each string has the format ENV_NAME=env_val)\n",
@@ -250,6 +585,10 @@ func (hook *EnvvGetterHook) jsName() string {
 	return "getEnvs"
 }
 
+func (hook *EnvvGetterHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *EnvvGetterHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -273,6 +612,7 @@ func (hook *MmapGetterHook) description() HookInfoDto {
 	//return "Provides mapping info like in procfs"
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Provides mapping info like in procfs",
 		Args:        "\nno args;\n",
 		ReturnValue: "str\tstring\t(mappings like in procfs)\n",
@@ -283,6 +623,10 @@ func (hook *MmapGetterHook) jsName() string {
 	return "getMmaps"
 }
 
+func (hook *MmapGetterHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *MmapGetterHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -300,6 +644,7 @@ type ArgvHook struct{}
 func (hook *ArgvHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Provides argv of the Task",
 		Args:        "\nno args;\n",
 		ReturnValue: "argv\t[]string\t(array of strings)\n",
@@ -310,6 +655,10 @@ func (hook *ArgvHook) jsName() string {
 	return "getArgv"
 }
 
+func (hook *ArgvHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *ArgvHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -332,6 +681,7 @@ type SignalInfoHook struct{}
 func (hook *SignalInfoHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Provides signal masks and sigactions of the Task",
 		Args:        "\nno args;\n",
 		ReturnValue: "SignalMaskDto json \n" +
@@ -354,6 +704,10 @@ func (hook *SignalInfoHook) jsName() string {
 	return "getSignalInfo"
 }
 
+func (hook *SignalInfoHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 type SignalMaskDto struct {
 	SignalMask      int64                `json:"signalMask"`
 	SignalWaitMask  int64                `json:"signalWaitMask"`
@@ -368,66 +722,299 @@ func (hook *SignalInfoHook) createCallback(t *Task) HookCallback {
 			return nil, util.ArgsCountMismatchError(0, len(args))
 		}
 
-		dto := SignalMaskDto{
-			SignalMask:      int64(SignalMaskGetter(t)),
-			SignalWaitMask:  int64(SigWaitMaskGetter(t)),
-			SavedSignalMask: int64(SavedSignalMaskGetter(t)),
-			SigActions:      SigactionGetter(t),
-		}
-
-		return dto, nil
+		dto := SignalMaskDto{
+			SignalMask:      int64(SignalMaskGetter(t)),
+			SignalWaitMask:  int64(SigWaitMaskGetter(t)),
+			SavedSignalMask: int64(SavedSignalMaskGetter(t)),
+			SigActions:      SigactionGetter(t),
+		}
+
+		return dto, nil
+	}
+}
+
+type PidInfoHook struct{}
+
+func (hook *PidInfoHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Provides PID, GID, UID and session info of Task",
+		Args:        "\nno args;\n",
+		ReturnValue: "PidDto json \n" +
+			"{\n" +
+			"\tPID number,\n" +
+			"\tGID number,\n" +
+			"\tUID number,\n" +
+			"\tsession json\n" +
+			"\t{\n" +
+			"\t\tsessionID number,\n" +
+			"\t\tPGID number,\n" +
+			"\t\tforegroundID number,\n" +
+			"\t\totherPGIDs []number (array of other PGIDS in session)\n" +
+			"\t}\n" +
+			"};\n",
+	}
+}
+
+func (hook *PidInfoHook) jsName() string {
+	return "getPidInfo"
+}
+
+func (hook *PidInfoHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+type PidDto struct {
+	PID     int32
+	GID     int32
+	UID     int32
+	Session SessionDTO `json:"session"`
+}
+
+func (hook *PidInfoHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+
+		if len(args) != 0 {
+			return nil, util.ArgsCountMismatchError(0, len(args))
+		}
+
+		dto := PidDto{
+			PID:     PIDGetter(t),
+			GID:     int32(GIDGetter(t)),
+			UID:     int32(UIDGetter(t)),
+			Session: *SessionGetter(t),
+		}
+
+		return dto, nil
+	}
+}
+
+type CredentialsHook struct{}
+
+func (hook *CredentialsHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Provides real, effective, saved and filesystem UID and GID, supplementary groups and capability sets of Task",
+		Args:        "\nno args;\n",
+		ReturnValue: "CredentialsDto json \n" +
+			"{\n" +
+			"\tuid json {real number, effective number, saved number, fs number},\n" +
+			"\tgid json {real number, effective number, saved number, fs number},\n" +
+			"\tgroups []number (supplementary groups),\n" +
+			"\tcapabilities json {inheritable number, permitted number, effective number, bounding number},\n" +
+			"\tkeepCaps bool\n" +
+			"};\n",
+	}
+}
+
+func (hook *CredentialsHook) jsName() string {
+	return "getCredentials"
+}
+
+func (hook *CredentialsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *CredentialsHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, util.ArgsCountMismatchError(0, len(args))
+		}
+
+		return CredentialsGetter(t), nil
+	}
+}
+
+type NamespacesHook struct{}
+
+func (hook *NamespacesHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Provides IDs of namespaces of Task (same as inode numbers of /proc/[pid]/ns links)",
+		Args:        "\nno args;\n",
+		ReturnValue: "NamespacesDto json \n" +
+			"{\n" +
+			"\tpid number,\n" +
+			"\tnet number,\n" +
+			"\tmnt number,\n" +
+			"\tuts number,\n" +
+			"\tipc number,\n" +
+			"\tuser number (always 0),\n" +
+			"\tinitUser bool (true if task is in the root user namespace)\n" +
+			"};\n",
+	}
+}
+
+func (hook *NamespacesHook) jsName() string {
+	return "getNamespaces"
+}
+
+func (hook *NamespacesHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *NamespacesHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, util.ArgsCountMismatchError(0, len(args))
+		}
+
+		return NamespacesGetter(t), nil
+	}
+}
+
+type CgroupsHook struct{}
+
+func (hook *CgroupsHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Provides cgroups of Task (same as /proc/[pid]/cgroup)",
+		Args:        "\nno args;\n",
+		ReturnValue: "[]TaskCgroupEntry json \n" +
+			"{\n" +
+			"\thierarchy_id number,\n" +
+			"\tcontrollers string,\n" +
+			"\tpath string\n" +
+			"};\n",
+	}
+}
+
+func (hook *CgroupsHook) jsName() string {
+	return "getCgroups"
+}
+
+func (hook *CgroupsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *CgroupsHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, util.ArgsCountMismatchError(0, len(args))
+		}
+
+		return t.GetCgroupEntries(), nil
+	}
+}
+
+type RlimitsHook struct{}
+
+func (hook *RlimitsHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Provides resource limits of Task by their names (RLIMIT_NOFILE, ...)",
+		Args:        "\nno args;\n",
+		ReturnValue: "map of RlimitDto json \n" +
+			"{\n" +
+			"\tsoft number (-1 if unlimited),\n" +
+			"\thard number (-1 if unlimited),\n" +
+			"\tunits string\n" +
+			"};\n",
+	}
+}
+
+func (hook *RlimitsHook) jsName() string {
+	return "getRlimits"
+}
+
+func (hook *RlimitsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *RlimitsHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, util.ArgsCountMismatchError(0, len(args))
+		}
+
+		return RlimitsGetter(t), nil
+	}
+}
+
+type ProcPathsHook struct{}
+
+func (hook *ProcPathsHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Provides paths of executable, working directory and root directory of Task",
+		Args:        "\nno args;\n",
+		ReturnValue: "ProcPathsDto json \n" +
+			"{\n" +
+			"\texe string,\n" +
+			"\tcwd string,\n" +
+			"\troot string (relative to the root of mount namespace)\n" +
+			"};\n",
+	}
+}
+
+func (hook *ProcPathsHook) jsName() string {
+	return "getProcPaths"
+}
+
+func (hook *ProcPathsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+type ProcPathsDto struct {
+	Exe  string `json:"exe"`
+	Cwd  string `json:"cwd"`
+	Root string `json:"root"`
+}
+
+func (hook *ProcPathsHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, util.ArgsCountMismatchError(0, len(args))
+		}
+
+		return ProcPathsDto{
+			Exe:  ExecutablePath(t),
+			Cwd:  WorkingDirectory(t),
+			Root: RootDirectoryPath(t),
+		}, nil
 	}
 }
 
-type PidInfoHook struct{}
+type ProcessInfoHook struct{}
 
-func (hook *PidInfoHook) description() HookInfoDto {
+func (hook *ProcessInfoHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
-		Description: "Provides PID, GID, UID and session info of Task",
+		Capability:  hook.capabilities().String(),
+		Description: "Provides PID, parent PID, name and start time of Task",
 		Args:        "\nno args;\n",
-		ReturnValue: "PidDto json \n" +
+		ReturnValue: "ProcessInfoDto json \n" +
 			"{\n" +
 			"\tPID number,\n" +
-			"\tGID number,\n" +
-			"\tUID number,\n" +
-			"\tsession json\n" +
-			"\t{\n" +
-			"\t\tsessionID number,\n" +
-			"\t\tPGID number,\n" +
-			"\t\tforegroundID number,\n" +
-			"\t\totherPGIDs []number (array of other PGIDS in session)\n" +
-			"\t}\n" +
+			"\tPPID number,\n" +
+			"\tname string,\n" +
+			"\tstartTime number (nanoseconds since Unix epoch),\n" +
+			"\tstartTimeTicks number (clock ticks since boot)\n" +
 			"};\n",
 	}
 }
 
-func (hook *PidInfoHook) jsName() string {
-	return "getPidInfo"
+func (hook *ProcessInfoHook) jsName() string {
+	return "getProcessInfo"
 }
 
-type PidDto struct {
-	PID     int32
-	GID     int32
-	UID     int32
-	Session SessionDTO `json:"session"`
+func (hook *ProcessInfoHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
 }
 
-func (hook *PidInfoHook) createCallback(t *Task) HookCallback {
+func (hook *ProcessInfoHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
-
 		if len(args) != 0 {
 			return nil, util.ArgsCountMismatchError(0, len(args))
 		}
 
-		dto := PidDto{
-			PID:     PIDGetter(t),
-			GID:     int32(GIDGetter(t)),
-			UID:     int32(UIDGetter(t)),
-			Session: *SessionGetter(t),
-		}
-
-		return dto, nil
+		return ProcessInfoGetter(t), nil
 	}
 }
 
@@ -437,9 +1024,14 @@ func (hook *UserJSONLogHook) jsName() string {
 	return "logJson"
 }
 
+func (hook *UserJSONLogHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *UserJSONLogHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Logs the given message",
 		Args:        "\nmsg\tany\t(message to be logged);\n",
 		ReturnValue: "null\n",
@@ -471,7 +1063,11 @@ func (hook *UserJSONLogHook) createCallback(t *Task) HookCallback {
 			str = valueStr.String()
 		}
 
-		t.JSONInfof(str)
+		var callback string
+		if runtime.current != nil {
+			callback = runtime.current.EntryPoint
+		}
+		t.JSONCallbackInfo(callback, str)
 		return nil, nil
 	}
 }
@@ -481,6 +1077,7 @@ type FDsHook struct{}
 func (hook *FDsHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Provides information about all fds of Task",
 		Args:        "\nno args;\n",
 		ReturnValue: "dtos []object (array of file description dtos)\n" +
@@ -500,6 +1097,10 @@ func (hook *FDsHook) jsName() string {
 	return "getFdsInfo"
 }
 
+func (hook *FDsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *FDsHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		if len(args) != 0 {
@@ -520,6 +1121,7 @@ type FDHook struct{}
 func (hook *FDHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Provides information about one specific fd of Task",
 		Args:        "\nfd\tnumber\t(fd to get info about);\n",
 		ReturnValue: "dto object (file description dto (format see below))\n" +
@@ -539,6 +1141,10 @@ func (hook *FDHook) jsName() string {
 	return "getFdInfo"
 }
 
+func (hook *FDHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (hook *FDHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		if len(args) != 1 {
@@ -567,6 +1173,7 @@ type AnonMmapHook struct{}
 func (m AnonMmapHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        m.jsName(),
+		Capability:  m.capabilities().String(),
 		Description: "Creates new anonymous mapping in the virtual address space of the calling process",
 		Args:        "\nlength\tnumber\t(amount of bytes to allocate);\n",
 		ReturnValue: "addr\tnumber\t(memory region start address)\n",
@@ -577,6 +1184,10 @@ func (m AnonMmapHook) jsName() string {
 	return "anonMmap"
 }
 
+func (m AnonMmapHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyMemory)
+}
+
 func (m AnonMmapHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		runtime := GetJsRuntime()
@@ -603,6 +1214,7 @@ type MunmapHook struct{}
 func (m MunmapHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        m.jsName(),
+		Capability:  m.capabilities().String(),
 		Description: "Delete the mappings from the specified address range",
 		Args: "\naddr\tnumber\t(start address, must be a multiple of the page size);\n" +
 			"length\tnumber\t(amount of bytes to set range);\n",
@@ -614,6 +1226,10 @@ func (m MunmapHook) jsName() string {
 	return "munmap"
 }
 
+func (m MunmapHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyMemory)
+}
+
 func (m MunmapHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		runtime := GetJsRuntime()
@@ -642,6 +1258,7 @@ type SignalByNameHook struct{}
 func (s SignalByNameHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        s.jsName(),
+		Capability:  s.capabilities().String(),
 		Description: "Returns number of signal by given signal name",
 		Args:        "\nname\tstring\t(name of signal to get value);\n",
 		ReturnValue: "sig\tnumber\t(the number of the signal or -1 if signal with such name doesn't exist)\n",
@@ -652,6 +1269,10 @@ func (s SignalByNameHook) jsName() string {
 	return "nameToSignal"
 }
 
+func (s SignalByNameHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (s SignalByNameHook) createCallback() HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		runtime := GetJsRuntime()
@@ -678,6 +1299,7 @@ type SignalMaskToSignalNamesHook struct{}
 func (s SignalMaskToSignalNamesHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        s.jsName(),
+		Capability:  s.capabilities().String(),
 		Description: "Returns array of names of signals in mask",
 		Args:        "\nmask\tnumber\t(signal mask);\n",
 		ReturnValue: "names\t[]string\t(names of signals in mask)\n",
@@ -688,6 +1310,10 @@ func (s SignalMaskToSignalNamesHook) jsName() string {
 	return "signalMaskToNames"
 }
 
+func (s SignalMaskToSignalNamesHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 func (s SignalMaskToSignalNamesHook) createCallback() HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		runtime := GetJsRuntime()
@@ -710,6 +1336,7 @@ type SignalSendingHook struct{}
 func (s SignalSendingHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        s.jsName(),
+		Capability:  s.capabilities().String(),
 		Description: "Sends the given signal to task with given tid",
 		Args: "\ntid\tnumber\t(id of the task to send signal);\n" +
 			"signo\tnumber\t(the number of the signal to send);\n",
@@ -721,6 +1348,10 @@ func (s SignalSendingHook) jsName() string {
 	return "sendSignal"
 }
 
+func (s SignalSendingHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyProcess)
+}
+
 func (s SignalSendingHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 
@@ -757,6 +1388,7 @@ type ThreadsStoppingHook struct{}
 func (hook *ThreadsStoppingHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Stops all threads except the caller. May be useful for preventing TOCTOU attack.",
 		Args:        "\nno args;\n",
 		ReturnValue: "null\n",
@@ -767,6 +1399,10 @@ func (hook *ThreadsStoppingHook) jsName() string {
 	return "stopThreads"
 }
 
+func (hook *ThreadsStoppingHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyProcess)
+}
+
 func (hook *ThreadsStoppingHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		if len(args) != 0 {
@@ -784,6 +1420,7 @@ type ThreadsResumingHook struct{}
 func (hook *ThreadsResumingHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Resume threads stopped by `stopThreads`.",
 		Args:        "\nno args;\n",
 		ReturnValue: "null\n",
@@ -794,6 +1431,10 @@ func (hook *ThreadsResumingHook) jsName() string {
 	return "resumeThreads"
 }
 
+func (hook *ThreadsResumingHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyProcess)
+}
+
 func (hook *ThreadsResumingHook) createCallback(t *Task) HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		if len(args) != 0 {
@@ -810,6 +1451,7 @@ type ThreadInfoHook struct{}
 func (hook *ThreadInfoHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
 		Description: "Provides TID, TGID (PID) and list of other TIDs in thread group.",
 		Args: "\nno args;\n" +
 			"or\n" +
@@ -827,6 +1469,10 @@ func (hook *ThreadInfoHook) jsName() string {
 	return "getThreadInfo"
 }
 
+func (hook *ThreadInfoHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
 type ThreadInfoDto struct {
 	TID      int32
 	TGID     int32
@@ -856,6 +1502,213 @@ func (hook *ThreadInfoHook) createCallback(t *Task) HookCallback {
 	}
 }
 
+type GetRegsHook struct{}
+
+func (hook *GetRegsHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:        hook.jsName(),
+		Capability:  hook.capabilities().String(),
+		Description: "Provides user registers of the Task. Names of registers are architecture-specific",
+		Args:        "\nno args;\n",
+		ReturnValue: "regs\tobject\t(maps register names to decimal strings of values, e.g. Rip, Rsp, Fs_base on amd64 or Pc, Sp, Tls on arm64)\n",
+	}
+}
+
+func (hook *GetRegsHook) jsName() string {
+	return "getRegs"
+}
+
+func (hook *GetRegsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityObserve)
+}
+
+func (hook *GetRegsHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, util.ArgsCountMismatchError(0, len(args))
+		}
+
+		return RegistersGetter(t)
+	}
+}
+
+type SetRegsHook struct{}
+
+func (hook *SetRegsHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:       hook.jsName(),
+		Capability: hook.capabilities().String(),
+		Description: "Sets user registers of the Task. May be called only from callbacks executed before syscall. " +
+			"Syscall arguments are already read, so use return value of callback to change them",
+		Args:        "\nregs\tobject\t(maps register names (same as in getRegs) to new values, numbers or strings);\n",
+		ReturnValue: "null\n",
+	}
+}
+
+func (hook *SetRegsHook) jsName() string {
+	return "setRegs"
+}
+
+func (hook *SetRegsHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyProcess)
+}
+
+func (hook *SetRegsHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		runtime := GetJsRuntime()
+		if len(args) != 1 {
+			return nil, util.ArgsCountMismatchError(1, len(args))
+		}
+
+		if runtime.current == nil || runtime.current.Type != JsCallbackTypeBefore {
+			return nil, errors.New("registers may be set only in callbacks executed before syscall")
+		}
+
+		if goja.IsNull(args[0]) || goja.IsUndefined(args[0]) {
+			return nil, util.ErrNullOrUndefined
+		}
+		obj := args[0].ToObject(runtime.JsVM)
+
+		values := make(map[string]uint64)
+		for _, name := range obj.Keys() {
+			str, err := util.ExtractIntegerFromValue(runtime.JsVM, obj.Get(name))
+			if err != nil {
+				return nil, err
+			}
+			val, err := strconv.ParseUint(str, 0, 64)
+			if err != nil {
+				// negative values are set in two's complement
+				signed, serr := strconv.ParseInt(str, 0, 64)
+				if serr != nil {
+					return nil, fmt.Errorf("bad value of register %s: %w", name, err)
+				}
+				val = uint64(signed)
+			}
+			values[name] = val
+		}
+
+		return nil, RegistersSetter(t, values)
+	}
+}
+
+type SyscallHook struct{}
+
+func (hook *SyscallHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:       hook.jsName(),
+		Capability: hook.capabilities().String(),
+		Description: "Invokes syscall on behalf of the Task. Callbacks aren't executed for invoked syscall. " +
+			"execve, exit, clone, syscalls which sleep or wait (nanosleep, wait4, futex, poll, accept...) " +
+			"and similar syscalls are not allowed. Other callbacks wait until the syscall is completed",
+		Args: "\nsyscall\tstring|number\t(syscall name or number);\n" +
+			"args\t...number\t(up to 6 syscall arguments, missing ones are 0);\n",
+		ReturnValue: "{ret, errno}\tobject\t(ret is -1 and errno is set if syscall failed)\n",
+	}
+}
+
+func (hook *SyscallHook) jsName() string {
+	return "syscall"
+}
+
+func (hook *SyscallHook) capabilities() util.CapabilitySet {
+	// injected syscall can change both the memory and the state of the task
+	return util.CapabilitySetOf(util.CapabilityModifyMemory, util.CapabilityModifyProcess)
+}
+
+func (hook *SyscallHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		runtime := GetJsRuntime()
+		if len(args) < 1 || len(args) > 7 {
+			return nil, fmt.Errorf("Incorrect count of args. Expected from 1 to 7, but provided %d", len(args))
+		}
+
+		if goja.IsNull(args[0]) || goja.IsUndefined(args[0]) {
+			return nil, util.ErrNullOrUndefined
+		}
+
+		var sysno uintptr
+		if name, ok := args[0].Export().(string); ok {
+			var err error
+			sysno, err = t.SyscallTable().LookupNo(name)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			var err error
+			sysno, err = util.ExtractPtrFromValue(runtime.JsVM, args[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var sysArgs arch.SyscallArguments
+		for i, arg := range args[1:] {
+			val, err := util.ExtractPtrFromValue(runtime.JsVM, arg)
+			if err != nil {
+				return nil, err
+			}
+			sysArgs[i].Value = val
+		}
+
+		return InvokeSyscall(t, sysno, sysArgs)
+	}
+}
+
+type AskOperatorHook struct{}
+
+func (hook *AskOperatorHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:       hook.jsName(),
+		Capability: hook.capabilities().String(),
+		Description: "Defers the syscall until operator (runtime socket client) approves, denies or modifies it. " +
+			"The task is parked after the callback returns. May be called only from callbacks executed before syscall",
+		Args: "\nmsg\tstring\t(message for operator);\n" +
+			"timeout\tnumber\t(optional, milliseconds to wait for decision, syscall is denied on timeout);\n",
+		ReturnValue: "null\n",
+	}
+}
+
+func (hook *AskOperatorHook) jsName() string {
+	return "askOperator"
+}
+
+func (hook *AskOperatorHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityModifyProcess)
+}
+
+func (hook *AskOperatorHook) createCallback(t *Task) HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		runtime := GetJsRuntime()
+		if len(args) != 1 && len(args) != 2 {
+			return nil, fmt.Errorf("Incorrect count of args. Expected 1 or 2, but provided %d", len(args))
+		}
+
+		request, err := newApprovalRequest(runtime.current)
+		if err != nil {
+			return nil, err
+		}
+
+		request.message, err = util.ExtractStringFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(args) == 2 {
+			ms, err := util.ExtractInt64FromValue(runtime.JsVM, args[1])
+			if err != nil {
+				return nil, err
+			}
+			if ms <= 0 {
+				return nil, errors.New("timeout should be positive")
+			}
+			request.timeout = time.Duration(ms) * time.Millisecond
+		}
+
+		t.approvalRequest = request
+		return nil, nil
+	}
+}
+
 // hooks for dynamic callback registration
 
 type AddCbBeforeHook struct{}
@@ -863,6 +1716,7 @@ type AddCbBeforeHook struct{}
 func (a AddCbBeforeHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        a.jsName(),
+		Capability:  a.capabilities().String(),
 		Description: "Is used for dynamic callback registration (callback will be executed before syscall)",
 		Args: "\nsysno\tnumber\t(syscall number, callback will be executed before syscall with this number);\n" +
 			"callback\tfunction\t(js function to call before syscall execution);\n",
@@ -874,6 +1728,10 @@ func (a AddCbBeforeHook) jsName() string {
 	return "AddCbBefore"
 }
 
+func (a AddCbBeforeHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityRegisterCallbacks)
+}
+
 func (a AddCbBeforeHook) createCallback() HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		if len(args) != 2 {
@@ -895,6 +1753,7 @@ func (a AddCbBeforeHook) createCallback() HookCallback {
 
 		info := *unknownCallback(sysno, JsCallbackTypeBefore)
 		info = fillJsCallbackInfoForDynamicCallback(info, cbObj.String())
+		info.Capabilities = runtime.granted.Names()
 
 		err = table.registerCallbackBefore(sysno, &DynamicJsCallbackBefore{CallbackInfo: info, Holder: cbObj})
 		return nil, err
@@ -906,6 +1765,7 @@ type AddCbAfterHook struct{}
 func (a AddCbAfterHook) description() HookInfoDto {
 	return HookInfoDto{
 		Name:        a.jsName(),
+		Capability:  a.capabilities().String(),
 		Description: "Is used for dynamic callback registration (callback will be executed after syscall)",
 		Args: "\nsysno\tnumber\t(syscall number, callback will be executed after syscall with this number);\n" +
 			"callback\tfunction\t(js function to call after syscall execution);\n",
@@ -917,6 +1777,10 @@ func (a AddCbAfterHook) jsName() string {
 	return "AddCbAfter"
 }
 
+func (a AddCbAfterHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityRegisterCallbacks)
+}
+
 func (a AddCbAfterHook) createCallback() HookCallback {
 	return func(args ...goja.Value) (interface{}, error) {
 		if len(args) != 2 {
@@ -938,8 +1802,138 @@ func (a AddCbAfterHook) createCallback() HookCallback {
 
 		info := *unknownCallback(sysno, JsCallbackTypeAfter)
 		info = fillJsCallbackInfoForDynamicCallback(info, cbObj.String())
+		info.Capabilities = runtime.granted.Names()
 
 		err = table.registerCallbackAfter(sysno, &DynamicJsCallbackAfter{CallbackInfo: info, Holder: cbObj})
 		return nil, err
 	}
 }
+
+type AddCbEmulateHook struct{}
+
+func (a AddCbEmulateHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:       a.jsName(),
+		Capability: a.capabilities().String(),
+		Description: "Is used for dynamic callback registration (callback will be executed instead of syscall " +
+			"which has no implementation in gVisor and should return {ret, errno})",
+		Args: "\nsysno\tnumber\t(syscall number, callback will emulate syscall with this number);\n" +
+			"callback\tfunction\t(js function to call instead of syscall execution);\n",
+		ReturnValue: "null\n",
+	}
+}
+
+func (a AddCbEmulateHook) jsName() string {
+	return "AddCbEmulate"
+}
+
+func (a AddCbEmulateHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityRegisterCallbacks)
+}
+
+func (a AddCbEmulateHook) createCallback() HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, util.ArgsCountMismatchError(2, len(args))
+		}
+
+		runtime := GetJsRuntime()
+		sysno, err := util.ExtractPtrFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if goja.IsNull(args[1]) || goja.IsUndefined(args[1]) {
+			return nil, util.ErrNullOrUndefined
+		}
+
+		cbObj := args[1].ToObject(runtime.JsVM)
+		table := runtime.callbackTable
+
+		info := *unknownCallback(sysno, JsCallbackTypeEmulate)
+		info = fillJsCallbackInfoForDynamicCallback(info, cbObj.String())
+		info.Capabilities = runtime.granted.Names()
+
+		err = table.registerCallbackEmulate(sysno, &DynamicJsCallbackEmulate{CallbackInfo: info, Holder: cbObj})
+		return nil, err
+	}
+}
+
+type OnPacketHook struct{}
+
+func (o OnPacketHook) description() HookInfoDto {
+	return HookInfoDto{
+		Name:       o.jsName(),
+		Capability: o.capabilities().String(),
+		Description: "Registers callback for network packets at netstack hook, " +
+			"the callback gets packet object and returns verdict",
+		Args: "\nhook\tstring\t(prerouting, input, output or postrouting);\n" +
+			"callback\tfunction\t(js function to call for every packet, null removes the callback);\n" +
+			"options\tobject\t(optional, may contain sample-rate, max-per-second, timeout-ms and fail-closed);\n",
+		ReturnValue: "null\n",
+	}
+}
+
+func (o OnPacketHook) jsName() string {
+	return "onPacket"
+}
+
+func (o OnPacketHook) capabilities() util.CapabilitySet {
+	return util.CapabilitySetOf(util.CapabilityRegisterCallbacks)
+}
+
+func (o OnPacketHook) createCallback() HookCallback {
+	return func(args ...goja.Value) (interface{}, error) {
+		if len(args) != 2 && len(args) != 3 {
+			return nil, util.ArgsCountMismatchError(3, len(args))
+		}
+
+		runtime := GetJsRuntime()
+		name, err := util.ExtractStringFromValue(runtime.JsVM, args[0])
+		if err != nil {
+			return nil, err
+		}
+		hook, ok := packetHooks[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown packet hook: %q", name)
+		}
+
+		if goja.IsNull(args[1]) || goja.IsUndefined(args[1]) {
+			runtime.packetCallbacks.register(hook, nil)
+			return nil, nil
+		}
+		cbObj := args[1].ToObject(runtime.JsVM)
+		if _, ok := goja.AssertFunction(cbObj); !ok {
+			return nil, errors.New("callback should be a function")
+		}
+
+		info := util.JsCallbackInfo{
+			EntryPoint:     name,
+			CallbackSource: cbObj.String(),
+			Type:           "packet",
+			Capabilities:   runtime.granted.Names(),
+		}
+		failClosed := false
+		if len(args) == 3 && !goja.IsNull(args[2]) && !goja.IsUndefined(args[2]) {
+			options := args[2].ToObject(runtime.JsVM)
+			if v := options.Get("sample-rate"); v != nil && !goja.IsUndefined(v) {
+				info.SampleRate = v.ToFloat()
+			}
+			if v := options.Get("max-per-second"); v != nil && !goja.IsUndefined(v) {
+				info.MaxPerSecond = int(v.ToInteger())
+			}
+			if v := options.Get("timeout-ms"); v != nil && !goja.IsUndefined(v) {
+				info.TimeoutMs = int(v.ToInteger())
+			}
+			if v := options.Get("fail-closed"); v != nil && !goja.IsUndefined(v) {
+				failClosed = v.ToBoolean()
+			}
+			if err := info.ValidateLimits(); err != nil {
+				return nil, err
+			}
+		}
+
+		runtime.packetCallbacks.register(hook, newPacketCallback(info, cbObj, runtime.granted, failClosed))
+		return nil, nil
+	}
+}
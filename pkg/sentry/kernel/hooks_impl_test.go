@@ -8,7 +8,7 @@ import (
 
 func testBuildContexts() ScriptContexts {
 	builder := ScriptContextsBuilderOf()
-	builder = builder.AddContext3(HooksJsName, &IndependentHookAddableAdapter{ht: jsRuntime.hooksTable})
+	builder = builder.AddContext3(HooksJsName, &IndependentHookAddableAdapter{ht: jsRuntime.hooksTable, granted: util.AllCapabilities})
 	builder = builder.AddContext3(JsPersistenceContextName,
 		&ObjectAddableAdapter{name: JsGlobalPersistenceObject, object: jsRuntime.Global})
 	return builder.Build()
@@ -44,7 +44,7 @@ func testThatCbFailsWithErr(t *testing.T, cbSource string, failMessage string) {
 		EntryPoint:     "cb",
 	}}
 
-	_, _, err := RunAbstractCallback(&task, jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	_, _, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
 	if err == nil {
 		t.Fatalf(failMessage)
 	}
@@ -0,0 +1,51 @@
+//go:build amd64
+// +build amd64
+
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+)
+
+// ptraceRegisterFields maps names of registers (same as in arch.Context.RegisterMap) to fields of regs
+func ptraceRegisterFields(regs *linux.PtraceRegs) map[string]*uint64 {
+	return map[string]*uint64{
+		"R15":      &regs.R15,
+		"R14":      &regs.R14,
+		"R13":      &regs.R13,
+		"R12":      &regs.R12,
+		"Rbp":      &regs.Rbp,
+		"Rbx":      &regs.Rbx,
+		"R11":      &regs.R11,
+		"R10":      &regs.R10,
+		"R9":       &regs.R9,
+		"R8":       &regs.R8,
+		"Rax":      &regs.Rax,
+		"Rcx":      &regs.Rcx,
+		"Rdx":      &regs.Rdx,
+		"Rsi":      &regs.Rsi,
+		"Rdi":      &regs.Rdi,
+		"Orig_rax": &regs.Orig_rax,
+		"Rip":      &regs.Rip,
+		"Cs":       &regs.Cs,
+		"Eflags":   &regs.Eflags,
+		"Rsp":      &regs.Rsp,
+		"Ss":       &regs.Ss,
+		"Fs_base":  &regs.Fs_base,
+		"Gs_base":  &regs.Gs_base,
+		"Ds":       &regs.Ds,
+		"Es":       &regs.Es,
+		"Fs":       &regs.Fs,
+		"Gs":       &regs.Gs,
+	}
+}
+
+// archSpecificRegisters are registers which are not part of linux.PtraceRegs.
+// All amd64 registers are in linux.PtraceRegs
+var archSpecificRegisters = map[string]struct{}{}
+
+// setArchSpecificRegister sets register from archSpecificRegisters
+func setArchSpecificRegister(_ *arch.Context64, _ string, _ uint64) bool {
+	return false
+}
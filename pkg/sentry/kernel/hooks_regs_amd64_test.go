@@ -0,0 +1,90 @@
+//go:build amd64
+// +build amd64
+
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+)
+
+func testCreateTaskWithRegs() Task {
+	task := testCreateEmptyTask()
+	task.image.Arch = &arch.Context64{}
+	// User code and stack segment selectors, PtraceSetRegs rejects others
+	task.image.Arch.Regs.Cs = 0x33
+	task.image.Arch.Regs.Ss = 0x2b
+	task.image.Arch.Regs.Rbx = 7
+	task.image.Arch.Regs.Fs_base = 0x1000
+	return task
+}
+
+func TestRegistersSetter_keepsOmittedRegisters(t *testing.T) {
+	task := testCreateTaskWithRegs()
+	if err := RegistersSetter(&task, map[string]uint64{"Rax": 5}); err != nil {
+		t.Fatalf("RegistersSetter failed: %v", err)
+	}
+	regs := task.Arch().Regs
+	if regs.Rax != 5 {
+		t.Errorf("Rax is %d, expected 5", regs.Rax)
+	}
+	if regs.Rbx != 7 || regs.Fs_base != 0x1000 {
+		t.Errorf("omitted registers changed: Rbx=%d, Fs_base=%#x", regs.Rbx, regs.Fs_base)
+	}
+}
+
+func TestRegistersSetter_withInvalidValue_ChangesNothing(t *testing.T) {
+	task := testCreateTaskWithRegs()
+	err := RegistersSetter(&task, map[string]uint64{"Rax": 5, "Fs_base": 1 << 63})
+	if err == nil {
+		t.Fatal("no error for non-canonical Fs_base")
+	}
+	if regs := task.Arch().Regs; regs.Rax != 0 || regs.Fs_base != 0x1000 {
+		t.Errorf("registers changed: Rax=%d, Fs_base=%#x", regs.Rax, regs.Fs_base)
+	}
+}
+
+func TestRegistersSetter_withUnknownRegister_Fails(t *testing.T) {
+	task := testCreateTaskWithRegs()
+	if err := RegistersSetter(&task, map[string]uint64{"Rax": 5, "Xyz": 1}); err == nil {
+		t.Fatal("no error for unknown register")
+	}
+	if regs := task.Arch().Regs; regs.Rax != 0 {
+		t.Errorf("Rax changed to %d", regs.Rax)
+	}
+}
+
+var regsRoundTrip = `
+	function cb() {
+		let regs = hooks.getRegs()
+		if (regs.Rbx !== "1152921504606846977") {
+			throw new Error("bad value of Rbx: " + regs.Rbx)
+		}
+		hooks.setRegs({Rax: regs.Rbx})
+	}
+`
+
+func TestRegsHooks_roundTripAbove2To53(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateTaskWithRegs()
+	task.image.Arch.Regs.Rbx = 1<<60 + 1
+	args := arch.SyscallArguments{}
+	cb := JsCallbackBefore{info: callbacks.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: regsRoundTrip,
+		CallbackBody:   regsRoundTrip,
+		CallbackArgs:   []string{},
+		Type:           JsCallbackTypeBefore,
+		EntryPoint:     "cb",
+	}}
+
+	_, _, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	if err != nil {
+		t.Fatalf("callback failed: %v", err)
+	}
+	if regs := task.Arch().Regs; regs.Rax != 1<<60+1 {
+		t.Errorf("Rax is %d, expected %d", regs.Rax, uint64(1<<60+1))
+	}
+}
@@ -0,0 +1,35 @@
+//go:build arm64
+// +build arm64
+
+package kernel
+
+import (
+	"fmt"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+)
+
+// ptraceRegisterFields maps names of registers (same as in arch.Context.RegisterMap) to fields of regs
+func ptraceRegisterFields(regs *linux.PtraceRegs) map[string]*uint64 {
+	fields := map[string]*uint64{
+		"Sp":     &regs.Sp,
+		"Pc":     &regs.Pc,
+		"Pstate": &regs.Pstate,
+	}
+	for i := range regs.Regs {
+		fields[fmt.Sprintf("R%d", i)] = &regs.Regs[i]
+	}
+	return fields
+}
+
+// archSpecificRegisters are registers which are not part of linux.PtraceRegs.
+// On arm64 it is the TLS register (TPIDR_EL0)
+var archSpecificRegisters = map[string]struct{}{"Tls": {}}
+
+// setArchSpecificRegister sets register from archSpecificRegisters
+func setArchSpecificRegister(ac *arch.Context64, name string, value uint64) bool {
+	if name != "Tls" {
+		return false
+	}
+	return ac.SetTLS(uintptr(value))
+}
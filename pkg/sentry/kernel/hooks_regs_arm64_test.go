@@ -0,0 +1,92 @@
+//go:build arm64
+// +build arm64
+
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+)
+
+func testCreateTaskWithRegs() Task {
+	task := testCreateEmptyTask()
+	task.image.Arch = &arch.Context64{}
+	task.image.Arch.Regs.Regs[1] = 7
+	task.image.Arch.Regs.TPIDR_EL0 = 0x1000
+	return task
+}
+
+func TestRegistersSetter_keepsOmittedRegisters(t *testing.T) {
+	task := testCreateTaskWithRegs()
+	if err := RegistersSetter(&task, map[string]uint64{"R0": 5}); err != nil {
+		t.Fatalf("RegistersSetter failed: %v", err)
+	}
+	regs := task.Arch().Regs
+	if regs.Regs[0] != 5 {
+		t.Errorf("R0 is %d, expected 5", regs.Regs[0])
+	}
+	if regs.Regs[1] != 7 || regs.TPIDR_EL0 != 0x1000 {
+		t.Errorf("omitted registers changed: R1=%d, TPIDR_EL0=%#x", regs.Regs[1], regs.TPIDR_EL0)
+	}
+}
+
+func TestRegistersSetter_setsTls(t *testing.T) {
+	task := testCreateTaskWithRegs()
+	if err := RegistersSetter(&task, map[string]uint64{"Tls": 0x2000}); err != nil {
+		t.Fatalf("RegistersSetter failed: %v", err)
+	}
+	if regs := task.Arch().Regs; regs.TPIDR_EL0 != 0x2000 {
+		t.Errorf("TPIDR_EL0 is %#x, expected 0x2000", regs.TPIDR_EL0)
+	}
+}
+
+func TestRegistersSetter_withInvalidValue_ChangesNothing(t *testing.T) {
+	task := testCreateTaskWithRegs()
+	err := RegistersSetter(&task, map[string]uint64{"R0": 5, "Pstate": linux.PSR_MODE32_BIT})
+	if err == nil {
+		t.Fatal("no error for 32-bit Pstate")
+	}
+	err = RegistersSetter(&task, map[string]uint64{"R0": 5, "Tls": 1 << 63})
+	if err == nil {
+		t.Fatal("no error for invalid Tls")
+	}
+	if regs := task.Arch().Regs; regs.Regs[0] != 0 || regs.TPIDR_EL0 != 0x1000 {
+		t.Errorf("registers changed: R0=%d, TPIDR_EL0=%#x", regs.Regs[0], regs.TPIDR_EL0)
+	}
+}
+
+var regsRoundTrip = `
+	function cb() {
+		let regs = hooks.getRegs()
+		if (regs.R1 !== "1152921504606846977") {
+			throw new Error("bad value of R1: " + regs.R1)
+		}
+		hooks.setRegs({R0: regs.R1})
+	}
+`
+
+func TestRegsHooks_roundTripAbove2To53(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateTaskWithRegs()
+	task.image.Arch.Regs.Regs[1] = 1<<60 + 1
+	args := arch.SyscallArguments{}
+	cb := JsCallbackBefore{info: callbacks.JsCallbackInfo{
+		Sysno:          1,
+		CallbackSource: regsRoundTrip,
+		CallbackBody:   regsRoundTrip,
+		CallbackArgs:   []string{},
+		Type:           JsCallbackTypeBefore,
+		EntryPoint:     "cb",
+	}}
+
+	_, _, err := RunAbstractCallback(&task, cb.callbackInfo(), jsCallbackInvocationTemplate(&cb), &args, ScriptContextsBuilderOf().Build())
+	if err != nil {
+		t.Fatalf("callback failed: %v", err)
+	}
+	if regs := task.Arch().Regs; regs.Regs[0] != 1<<60+1 {
+		t.Errorf("R0 is %d, expected %d", regs.Regs[0], uint64(1<<60+1))
+	}
+}
@@ -3,6 +3,7 @@ package kernel
 import (
 	"cmp"
 	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
 	"slices"
 	"strings"
 	"sync"
@@ -55,7 +56,7 @@ func TestHooksTable_addIndependentHooksToContextObject(t *testing.T) {
 	obj := jsRuntime.JsVM.NewObject()
 
 	_ = ht.registerIndependentHook(&h)
-	err := ht.addIndependentHooksToContextObject(obj)
+	err := ht.addIndependentHooksToContextObject(obj, callbacks.AllCapabilities)
 	if err != nil {
 		t.Fatalf("failed to add independent hooks to context object")
 	}
@@ -77,7 +78,7 @@ func TestHooksTable_addDependentHooksToContextObject(t *testing.T) {
 	obj := jsRuntime.JsVM.NewObject()
 
 	_ = ht.registerDependentHook(&h)
-	err := ht.addDependentHooksToContextObject(obj, &task)
+	err := ht.addDependentHooksToContextObject(obj, &task, callbacks.AllCapabilities)
 	if err != nil {
 		t.Fatalf("failed to add dependent hooks to context object")
 	}
@@ -90,6 +91,51 @@ func TestHooksTable_addDependentHooksToContextObject(t *testing.T) {
 	}
 }
 
+func TestHooksTable_addDependentHooksToContextObject_deniesNotGrantedCapability(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	ht := testInitHookTable()
+	task := testCreateEmptyTask()
+	h := stubDependentGoHook{}
+	obj := jsRuntime.JsVM.NewObject()
+
+	_ = ht.registerDependentHook(&h)
+	granted, _ := callbacks.ParseCapabilities([]string{callbacks.CapabilityModifyProcess})
+	err := ht.addDependentHooksToContextObject(obj, &task, granted)
+	if err != nil {
+		t.Fatalf("failed to add dependent hooks to context object")
+	}
+	if h.createCount != 0 {
+		t.Fatalf("hook was created without required capability")
+	}
+	fn, ok := goja.AssertFunction(obj.Get(h.jsName()))
+	if !ok {
+		t.Fatalf("no stub added to object")
+	}
+	if _, err := fn(goja.Undefined()); err == nil {
+		t.Fatalf("no error when calling hook without required capability")
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	all, err := callbacks.ParseCapabilities(nil)
+	if err != nil || all != callbacks.AllCapabilities {
+		t.Fatalf("empty capabilities list should grant all capabilities")
+	}
+
+	observe, err := callbacks.ParseCapabilities([]string{callbacks.CapabilityModifyProcess})
+	if err != nil {
+		t.Fatalf("unexpected error while parsing capabilities: %s", err)
+	}
+	if !observe.Has(callbacks.CapabilityObserve) || observe.Has(callbacks.CapabilityModifyMemory) {
+		t.Fatalf("wrong capabilities parsed: %v", observe.Names())
+	}
+
+	if _, err = callbacks.ParseCapabilities([]string{"root"}); err == nil {
+		t.Fatalf("no error for unknown capability")
+	}
+}
+
 // prepareSetOfDependentHooks should register same dependent hooks as RegisterHooks
 func prepareSetOfDependentHooks() map[string]struct{} {
 	set := make(map[string]struct{})
@@ -112,6 +158,22 @@ func prepareSetOfDependentHooks() map[string]struct{} {
 	set[(&ThreadsStoppingHook{}).jsName()] = struct{}{}
 	set[(&ThreadsResumingHook{}).jsName()] = struct{}{}
 	set[(&ThreadInfoHook{}).jsName()] = struct{}{}
+	set[(&ReadIntHook{}).jsName()] = struct{}{}
+	set[(&WriteIntHook{}).jsName()] = struct{}{}
+	set[(&ReadStringArrayHook{}).jsName()] = struct{}{}
+	set[(&ReadIovecsHook{}).jsName()] = struct{}{}
+	set[(&ReadSockaddrHook{}).jsName()] = struct{}{}
+	set[(&ReadStructHook{}).jsName()] = struct{}{}
+	set[(&GetRegsHook{}).jsName()] = struct{}{}
+	set[(&AskOperatorHook{}).jsName()] = struct{}{}
+	set[(&SyscallHook{}).jsName()] = struct{}{}
+	set[(&SetRegsHook{}).jsName()] = struct{}{}
+	set[(&CredentialsHook{}).jsName()] = struct{}{}
+	set[(&NamespacesHook{}).jsName()] = struct{}{}
+	set[(&CgroupsHook{}).jsName()] = struct{}{}
+	set[(&RlimitsHook{}).jsName()] = struct{}{}
+	set[(&ProcPathsHook{}).jsName()] = struct{}{}
+	set[(&ProcessInfoHook{}).jsName()] = struct{}{}
 
 	return set
 }
@@ -122,7 +184,9 @@ func prepareSetOfIndependentHooks() map[string]struct{} {
 
 	set[(&PrintHook{}).jsName()] = struct{}{}
 	set[(&AddCbBeforeHook{}).jsName()] = struct{}{}
+	set[(&AddCbEmulateHook{}).jsName()] = struct{}{}
 	set[(&AddCbAfterHook{}).jsName()] = struct{}{}
+	set[(&OnPacketHook{}).jsName()] = struct{}{}
 	set[(&SignalByNameHook{}).jsName()] = struct{}{}
 	set[(&SignalMaskToSignalNamesHook{}).jsName()] = struct{}{}
 
@@ -13,9 +13,17 @@ type JsCallback interface {
 	registerAtCallbackTable(ct *CallbackTable) error
 }
 
-// JsCallbackByInfo returns suitable JsCallback (JsCallbackAfter or JsCallbackBefore)
-// according to callbacks.JsCallbackInfo
+// JsCallbackByInfo returns suitable JsCallback (JsCallbackAfter, JsCallbackBefore or JsCallbackEmulate)
+// according to callbacks.JsCallbackInfo. Callbacks with wasm engine are compiled WebAssembly modules
 func JsCallbackByInfo(info callbacks.JsCallbackInfo) (JsCallback, error) {
+	if info.IsWasm() {
+		cb, err := wasmCallbackByInfo(info)
+		if err != nil {
+			return nil, err
+		}
+		return cb, checkJsCallback(cb)
+	}
+
 	if info.Type == JsCallbackTypeAfter {
 		cb := &JsCallbackAfter{info: info}
 		return cb, checkJsCallback(cb)
@@ -24,21 +32,40 @@ func JsCallbackByInfo(info callbacks.JsCallbackInfo) (JsCallback, error) {
 		cb := &JsCallbackBefore{info: info}
 		return cb, checkJsCallback(cb)
 	}
+	if info.Type == JsCallbackTypeEmulate {
+		cb := &JsCallbackEmulate{info: info}
+		return cb, checkJsCallback(cb)
+	}
 
 	return nil, errors.New("incorrect callback type " + info.Type)
 }
 
 func checkJsCallback(cb JsCallback) error {
 	info := cb.callbackInfo()
-	if info.CallbackSource == "" {
+	if info.Engine != "" && info.Engine != callbacks.EngineJs && info.Engine != callbacks.EngineWasm {
+		return errors.New(fmt.Sprintf("unknown callback engine: %s", info.Engine))
+	}
+	if info.CallbackSource == "" && !info.IsWasm() {
 		return errors.New("js callback source is empty")
 	}
 	if info.EntryPoint == "" {
 		return errors.New("js callback entry point is empty")
 	}
-	if info.Type != JsCallbackTypeBefore && info.Type != JsCallbackTypeAfter {
+	if info.Type != JsCallbackTypeBefore && info.Type != JsCallbackTypeAfter && info.Type != JsCallbackTypeEmulate {
 		return errors.New(fmt.Sprintf("incorrect js callback type: %s", info.Type))
 	}
+	if _, err := info.GrantedCapabilities(); err != nil {
+		return err
+	}
+	if err := info.ValidateLimits(); err != nil {
+		return err
+	}
+	if info.Fuel < 0 {
+		return errors.New("fuel can't be negative")
+	}
+	if info.Type == JsCallbackTypeEmulate && (info.SampleRate != 0 || info.MaxPerSecond != 0) {
+		return errors.New("emulate callbacks can't be sampled or rate limited")
+	}
 
 	return nil
 }
@@ -64,7 +91,7 @@ func (cb *JsCallbackBefore) registerAtCallbackTable(ct *CallbackTable) error {
 func (cb *JsCallbackBefore) CallbackBeforeFunc(t *Task, _ uintptr,
 	args *arch.SyscallArguments) (*arch.SyscallArguments, *SyscallReturnValue, error) {
 
-	return RunAbstractCallback(t, jsCallbackInvocationTemplate(cb), args, ScriptContextsBuilderOf().Build())
+	return RunAbstractCallback(t, &cb.info, jsCallbackInvocationTemplate(cb), args, ScriptContextsBuilderOf().Build())
 }
 
 // JsCallbackAfter implements CallbackAfter and JsCallback
@@ -90,5 +117,31 @@ func (cb *JsCallbackAfter) CallbackAfterFunc(t *Task, _ uintptr, args *arch.Sysc
 	context := ScriptContextsBuilderOf().AddContext3(ArgsJsName,
 		SyscallReturnValueWithError{returnValue: ret, errno: inputErr}).Build()
 
-	return RunAbstractCallback(t, jsCallbackInvocationTemplate(cb), args, context)
+	return RunAbstractCallback(t, &cb.info, jsCallbackInvocationTemplate(cb), args, context)
+}
+
+// JsCallbackEmulate implements CallbackEmulate and JsCallback
+type JsCallbackEmulate struct {
+	info callbacks.JsCallbackInfo
+}
+
+func (cb *JsCallbackEmulate) callbackInfo() *callbacks.JsCallbackInfo {
+	return &cb.info
+}
+
+func (cb *JsCallbackEmulate) Info() callbacks.JsCallbackInfo {
+	return cb.info
+}
+
+func (cb *JsCallbackEmulate) registerAtCallbackTable(ct *CallbackTable) error {
+	return ct.registerCallbackEmulate(uintptr(cb.info.Sysno), cb)
+}
+
+// CallbackEmulateFunc execution of user callback implementing syscall. The callback should return {ret, errno},
+// otherwise the syscall is handled as missing
+func (cb *JsCallbackEmulate) CallbackEmulateFunc(t *Task, _ uintptr,
+	args *arch.SyscallArguments) (*SyscallReturnValue, error) {
+
+	_, sub, err := RunAbstractCallback(t, &cb.info, jsCallbackInvocationTemplate(cb), args, ScriptContextsBuilderOf().Build())
+	return sub, err
 }
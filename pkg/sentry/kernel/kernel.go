@@ -80,6 +80,7 @@ import (
 	"gvisor.dev/gvisor/pkg/state/wire"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/tcpip"
+	"sync/atomic"
 
 	"github.com/dop251/goja"
 )
@@ -157,6 +158,8 @@ type Kernel struct {
 	vdso                 *loader.VDSO
 	rootUTSNamespace     *UTSNamespace
 	rootIPCNamespace     *IPCNamespace
+	rootTimeNamespace    *TimeNamespace
+	rootCgroupNamespace  *CgroupNamespace
 
 	// futexes is the "root" futex.Manager, from which all others are forked.
 	// This is necessary to ensure that shared futexes are coherent across all
@@ -349,6 +352,27 @@ type GojaRuntime struct {
 	hooksTable      *HooksTable
 	callbackTable   *CallbackTable
 	runtimeCmdTable *CommandTable
+
+	// approvals passes syscalls deferred by callbacks to runtime socket clients
+	approvals *ApprovalBroker
+
+	// syscallRules are evaluated before callbacks without entering js runtime, nil if there are no rules
+	syscallRules atomic.Pointer[SyscallRules]
+
+	// virtualFiles are mounted into the sandbox filesystem when containers are started.
+	// They are set once by Kernel.Init
+	virtualFiles []*VirtualFile
+
+	// packetCallbacks are js callbacks registered by hooks.onPacket
+	packetCallbacks *PacketCallbackTable
+
+	// granted is the set of capabilities of the currently running script.
+	// Callbacks registered dynamically by the script inherit it. Protected by Mutex
+	granted callbacks.CapabilitySet
+
+	// current is the info of the currently running callback or nil if
+	// script is not a callback. Protected by Mutex
+	current *callbacks.JsCallbackInfo
 }
 
 func initJsRuntime() *GojaRuntime {
@@ -376,8 +400,9 @@ func initJsRuntime() *GojaRuntime {
 
 	// init callback table
 	callbackTable := &CallbackTable{
-		callbackBefore: make(map[uintptr]CallbackBefore),
-		callbackAfter:  make(map[uintptr]CallbackAfter),
+		callbackBefore:  make(map[uintptr]CallbackBefore),
+		callbackAfter:   make(map[uintptr]CallbackAfter),
+		callbackEmulate: make(map[uintptr]CallbackEmulate),
 	}
 
 	return &GojaRuntime{
@@ -387,6 +412,9 @@ func initJsRuntime() *GojaRuntime {
 		hooksTable:      table,
 		callbackTable:   callbackTable,
 		runtimeCmdTable: runtimeCmdTable,
+		approvals:       newApprovalBroker(),
+		packetCallbacks: &PacketCallbackTable{},
+		granted:         callbacks.AllCapabilities,
 	}
 }
 
@@ -516,6 +544,23 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 				panic(err)
 			}
 		}
+
+		if len(configDto.Rules) != 0 {
+			// The sandbox doesn't start unenforced when its policy is broken
+			rules, err := CompileSyscallRules(configDto.Rules)
+			if err != nil {
+				return fmt.Errorf("incorrect rules in init config: %w", err)
+			}
+			GetJsRuntime().syscallRules.Store(rules)
+		}
+
+		for _, dto := range configDto.VirtualFiles {
+			file, err := NewVirtualFile(dto)
+			if err != nil {
+				return fmt.Errorf("incorrect virtual file in init config: %w", err)
+			}
+			GetJsRuntime().virtualFiles = append(GetJsRuntime().virtualFiles, file)
+		}
 	}
 
 	k.featureSet = args.FeatureSet
@@ -583,6 +628,13 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	k.rootNetworkNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootNetworkNamespace))
 	k.rootIPCNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootIPCNamespace))
 	k.rootUTSNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootUTSNamespace))
+	k.rootTimeNamespace = newTimeNamespace(k, k.rootUserNamespace)
+	k.rootTimeNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootTimeNamespace))
+	if err := k.rootTimeNamespace.freeze(); err != nil {
+		return fmt.Errorf("failed to create root time namespace: %v", err)
+	}
+	k.rootCgroupNamespace = NewRootCgroupNamespace(k.rootUserNamespace)
+	k.rootCgroupNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootCgroupNamespace))
 
 	tmpfsOpts := vfs.GetFilesystemOptions{
 		InternalData: tmpfs.FilesystemOpts{
@@ -1144,6 +1196,9 @@ func (k *Kernel) CreateProcess(args CreateProcessArgs) (*ThreadGroup, ThreadID,
 		// A task with no parent starts out with no session keyring.
 		SessionKeyring: nil,
 	}
+	config.TimeNamespace = k.RootTimeNamespace()
+	config.TimeNamespaceForChildren = k.RootTimeNamespace()
+	config.CgroupNamespace = k.RootCgroupNamespace()
 	config.NetworkNamespace.IncRef()
 	t, err := k.tasks.NewTask(ctx, config)
 	if err != nil {
@@ -1502,6 +1557,19 @@ func (k *Kernel) RootUTSNamespace() *UTSNamespace {
 	return k.rootUTSNamespace
 }
 
+// RootCgroupNamespace takes a reference and returns the root
+// CgroupNamespace.
+func (k *Kernel) RootCgroupNamespace() *CgroupNamespace {
+	k.rootCgroupNamespace.IncRef()
+	return k.rootCgroupNamespace
+}
+
+// RootTimeNamespace takes a reference and returns the root TimeNamespace.
+func (k *Kernel) RootTimeNamespace() *TimeNamespace {
+	k.rootTimeNamespace.IncRef()
+	return k.rootTimeNamespace
+}
+
 // RootIPCNamespace takes a reference and returns the root IPCNamespace.
 func (k *Kernel) RootIPCNamespace() *IPCNamespace {
 	k.rootIPCNamespace.IncRef()
@@ -147,7 +147,7 @@ func (r *Registry) FindOrCreate(ctx context.Context, opts OpenOpts, mode linux.F
 
 	// Construct status flags.
 	var flags uint32
-	if opts.Block {
+	if !opts.Block {
 		flags = linux.O_NONBLOCK
 	}
 	switch opts.Access {
@@ -277,6 +277,11 @@ type Queue struct {
 	// from this queue.
 	subscriber *Subscriber
 
+	// receivers is the number of tasks blocked in Receive. Notifications
+	// aren't sent while there are blocked receivers, because they take the
+	// message first.
+	receivers int `state:"nosave"`
+
 	// messageCount is the number of messages currently in the queue.
 	messageCount int64
 
@@ -291,12 +296,36 @@ type Queue struct {
 	byteCount uint64
 }
 
+// Blocker is used to block in Send and Receive. It's implemented by
+// kernel.Task, which can't be used here directly.
+type Blocker interface {
+	// BlockWithTimer blocks until an event is received from C or tchan, or
+	// the blocker is interrupted. It returns ETIMEDOUT if an event is
+	// received from tchan. A nil tchan never sends events.
+	BlockWithTimer(C <-chan struct{}, tchan <-chan struct{}) error
+}
+
 // View is a view into a message queue. Views should only be used in file
 // descriptions, but not inodes, because we use inodes to retrieve the actual
 // queue, and only FDs are responsible for providing user functionality.
 type View interface {
-	// TODO: Add Send and Receive when mq_timedsend(2) and mq_timedreceive(2)
-	// are implemented.
+	// Send adds msg to the queue, see mq_timedsend(2). If the queue is full
+	// and block is true, Send waits for free space using b until an event is
+	// received from tchan.
+	Send(ctx context.Context, msg *Message, b Blocker, block bool, tchan <-chan struct{}) error
+
+	// Receive removes the oldest message with the highest priority from the
+	// queue and returns it, see mq_timedreceive(2). size is the size of the
+	// caller's buffer. If the queue is empty and block is true, Receive waits
+	// for a message using b until an event is received from tchan.
+	Receive(ctx context.Context, b Blocker, block bool, size uint64, tchan <-chan struct{}) (*Message, error)
+
+	// SetNotification registers the calling process for notification, see
+	// Queue.SetNotification.
+	SetNotification(ctx context.Context, method, signo int32, n Notifier) error
+
+	// Attributes returns the attributes of the queue.
+	Attributes() linux.MqAttr
 
 	// Flush checks if the calling process has attached a notification request
 	// to this queue, if yes, then the request is removed, and another process
@@ -313,14 +342,14 @@ type ReaderWriter struct {
 	block bool
 }
 
-// Reader provides a send-only view into a queue.
+// Reader provides a receive-only view into a queue.
 type Reader struct {
 	*Queue
 
 	block bool
 }
 
-// Writer provides a receive-only view into a queue.
+// Writer provides a send-only view into a queue.
 type Writer struct {
 	*Queue
 
@@ -343,6 +372,40 @@ func NewView(q *Queue, access AccessType, block bool) (View, error) {
 	}
 }
 
+// Send implements View.Send.
+func (v ReaderWriter) Send(ctx context.Context, msg *Message, b Blocker, block bool, tchan <-chan struct{}) error {
+	return v.Queue.send(ctx, msg, b, block, tchan)
+}
+
+// Receive implements View.Receive.
+func (v ReaderWriter) Receive(ctx context.Context, b Blocker, block bool, size uint64, tchan <-chan struct{}) (*Message, error) {
+	return v.Queue.receive(ctx, b, block, size, tchan)
+}
+
+// Send implements View.Send.
+func (Reader) Send(context.Context, *Message, Blocker, bool, <-chan struct{}) error {
+	// "mqdes doesn't represent a valid message queue descriptor open for
+	//  writing." - mq_send(3)
+	return linuxerr.EBADF
+}
+
+// Receive implements View.Receive.
+func (v Reader) Receive(ctx context.Context, b Blocker, block bool, size uint64, tchan <-chan struct{}) (*Message, error) {
+	return v.Queue.receive(ctx, b, block, size, tchan)
+}
+
+// Send implements View.Send.
+func (v Writer) Send(ctx context.Context, msg *Message, b Blocker, block bool, tchan <-chan struct{}) error {
+	return v.Queue.send(ctx, msg, b, block, tchan)
+}
+
+// Receive implements View.Receive.
+func (Writer) Receive(context.Context, Blocker, bool, uint64, <-chan struct{}) (*Message, error) {
+	// "The descriptor specified in mqdes was invalid or not opened for
+	//  reading." - mq_receive(3)
+	return nil, linuxerr.EBADF
+}
+
 // Message holds a message exchanged through a Queue via mq_timedsend(2) and
 // mq_timedreceive(2), and additional info relating to the message.
 //
@@ -360,14 +423,208 @@ type Message struct {
 	Priority uint32
 }
 
+// Notifier delivers the notification requested by mq_notify(2). It's
+// implemented by the syscall layer, because notifications are delivered using
+// signals or netlink sockets.
+type Notifier interface {
+	// Notify delivers the notification about a message sent to an empty
+	// queue. ctx is the context of the sender.
+	Notify(ctx context.Context)
+
+	// Remove is called when the request is removed without notification.
+	Remove(ctx context.Context)
+}
+
 // Subscriber represents a task registered for async notification from a Queue.
 //
 // +stateify savable
 type Subscriber struct {
-	// TODO: Add fields when mq_notify(2) is implemented.
-
 	// pid is the PID of the registered task.
 	pid int32
+
+	// method is the notification method, aka sigev_notify.
+	method int32
+
+	// signo is the signal number of SIGEV_SIGNAL notifications.
+	signo int32
+
+	// notifier delivers the notification.
+	notifier Notifier
+}
+
+// send implements View.Send.
+func (q *Queue) send(ctx context.Context, msg *Message, b Blocker, block bool, tchan <-chan struct{}) error {
+	// "msg_len was greater than the mq_msgsize attribute of the message
+	//  queue." - mq_send(3)
+	if msg.Size > q.maxMessageSize {
+		return linuxerr.EMSGSIZE
+	}
+	if msg.Priority > maxPriority {
+		return linuxerr.EINVAL
+	}
+
+	var ch chan struct{}
+	for {
+		q.mu.Lock()
+		if q.messageCount < q.maxMessageCount {
+			q.insertLocked(msg)
+
+			// "Message notification occurs only when a new message arrives
+			//  and the queue was previously empty." - mq_notify(3)
+			var sub *Subscriber
+			if q.messageCount == 1 && q.receivers == 0 && q.subscriber != nil {
+				sub = q.subscriber
+				q.subscriber = nil
+			}
+			q.mu.Unlock()
+
+			q.queue.Notify(waiter.ReadableEvents)
+			if sub != nil {
+				sub.notifier.Notify(ctx)
+			}
+			return nil
+		}
+		if !block {
+			q.mu.Unlock()
+			return linuxerr.EAGAIN
+		}
+		if ch == nil {
+			// Register for events and check the queue again, so that events
+			// sent while we weren't registered aren't missed.
+			var e waiter.Entry
+			e, ch = waiter.NewChannelEntry(waiter.WritableEvents)
+			q.queue.EventRegister(&e)
+			defer q.queue.EventUnregister(&e)
+			q.mu.Unlock()
+			continue
+		}
+		q.mu.Unlock()
+
+		if err := b.BlockWithTimer(ch, tchan); err != nil {
+			return err
+		}
+	}
+}
+
+// insertLocked inserts msg after all messages with the same or higher
+// priority.
+//
+// Preconditions: q.mu is locked.
+func (q *Queue) insertLocked(msg *Message) {
+	prev := q.messages.Back()
+	for prev != nil && prev.Priority < msg.Priority {
+		prev = prev.Prev()
+	}
+	if prev == nil {
+		q.messages.PushFront(msg)
+	} else {
+		q.messages.InsertAfter(prev, msg)
+	}
+	q.messageCount++
+	q.byteCount += msg.Size
+}
+
+// receive implements View.Receive.
+func (q *Queue) receive(ctx context.Context, b Blocker, block bool, size uint64, tchan <-chan struct{}) (*Message, error) {
+	// "msg_len was less than the mq_msgsize attribute of the message queue."
+	//  - mq_receive(3)
+	if size < q.maxMessageSize {
+		return nil, linuxerr.EMSGSIZE
+	}
+
+	var ch chan struct{}
+	for {
+		q.mu.Lock()
+		if msg := q.messages.Front(); msg != nil {
+			q.messages.Remove(msg)
+			q.messageCount--
+			q.byteCount -= msg.Size
+			q.mu.Unlock()
+
+			q.queue.Notify(waiter.WritableEvents)
+			return msg, nil
+		}
+		if !block {
+			q.mu.Unlock()
+			return nil, linuxerr.EAGAIN
+		}
+		if ch == nil {
+			var e waiter.Entry
+			e, ch = waiter.NewChannelEntry(waiter.ReadableEvents)
+			q.queue.EventRegister(&e)
+			defer q.queue.EventUnregister(&e)
+			q.mu.Unlock()
+			continue
+		}
+		q.receivers++
+		q.mu.Unlock()
+
+		err := b.BlockWithTimer(ch, tchan)
+
+		q.mu.Lock()
+		q.receivers--
+		q.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// SetNotification registers the calling process for notification using n,
+// see mq_notify(2). method and signo are the notification method and signal
+// number shown in the queue's file. If n is nil, the registration of the
+// calling process is removed.
+func (q *Queue) SetNotification(ctx context.Context, method, signo int32, n Notifier) error {
+	if n == nil {
+		q.Flush(ctx)
+		return nil
+	}
+	pid, ok := auth.ThreadGroupIDFromContext(ctx)
+	if !ok {
+		return linuxerr.EINVAL
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// "Another process has already registered to receive notification for
+	//  this message queue." - mq_notify(3)
+	if q.subscriber != nil {
+		return linuxerr.EBUSY
+	}
+	q.subscriber = &Subscriber{
+		pid:      pid,
+		method:   method,
+		signo:    signo,
+		notifier: n,
+	}
+	return nil
+}
+
+// Release removes the notification request of the queue, releasing resources
+// held by its notifier, e.g. the netlink socket of SIGEV_THREAD. It's called
+// when the queue is destroyed.
+func (q *Queue) Release(ctx context.Context) {
+	q.mu.Lock()
+	sub := q.subscriber
+	q.subscriber = nil
+	q.mu.Unlock()
+
+	if sub != nil {
+		sub.notifier.Remove(ctx)
+	}
+}
+
+// Attributes returns the attributes of the queue. MqFlags is left zero,
+// because O_NONBLOCK is a property of the file description.
+func (q *Queue) Attributes() linux.MqAttr {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return linux.MqAttr{
+		MqMaxmsg:  q.maxMessageCount,
+		MqMsgsize: int64(q.maxMessageSize),
+		MqCurmsgs: q.messageCount,
+	}
 }
 
 // Generate implements vfs.DynamicBytesSource.Generate. Queue is used as a
@@ -383,7 +640,10 @@ func (q *Queue) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	)
 	if q.subscriber != nil {
 		pid = q.subscriber.pid
-		// TODO: add method and sigNumber when mq_notify(2) is implemented.
+		method = int(q.subscriber.method)
+		if q.subscriber.method == linux.SIGEV_SIGNAL {
+			sigNumber = int(q.subscriber.signo)
+		}
 	}
 
 	buf.WriteString(
@@ -395,14 +655,21 @@ func (q *Queue) Generate(ctx context.Context, buf *bytes.Buffer) error {
 
 // Flush implements View.Flush.
 func (q *Queue) Flush(ctx context.Context) {
+	pid, ok := auth.ThreadGroupIDFromContext(ctx)
+	if !ok {
+		return
+	}
+
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	var removed *Subscriber
+	if q.subscriber != nil && pid == q.subscriber.pid {
+		removed = q.subscriber
+		q.subscriber = nil
+	}
+	q.mu.Unlock()
 
-	pid, ok := auth.ThreadGroupIDFromContext(ctx)
-	if ok {
-		if q.subscriber != nil && pid == q.subscriber.pid {
-			q.subscriber = nil
-		}
+	if removed != nil {
+		removed.notifier.Remove(ctx)
 	}
 }
 
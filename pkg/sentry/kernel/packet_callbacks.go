@@ -0,0 +1,285 @@
+package kernel
+
+import (
+	"fmt"
+	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"time"
+)
+
+const (
+	// PacketJsName is the name of context with the packet passed to packet callback
+	PacketJsName = "packet"
+
+	// Verdicts returned by packet callbacks
+	packetVerdictAccept = "accept"
+	packetVerdictDrop   = "drop"
+
+	// defaultPacketTimeout limits the time of packet callback execution, because
+	// the packet processing is blocked until the callback returns
+	defaultPacketTimeout = 10 * time.Millisecond
+)
+
+// packetHooks maps names accepted by hooks.onPacket to netstack hooks
+var packetHooks = map[string]stack.Hook{
+	"prerouting":  stack.Prerouting,
+	"input":       stack.Input,
+	"output":      stack.Output,
+	"postrouting": stack.Postrouting,
+}
+
+// packetCallback is js function registered by hooks.onPacket
+type packetCallback struct {
+	info    callbacks.JsCallbackInfo
+	holder  *goja.Object
+	granted callbacks.CapabilitySet
+
+	// limiter is never nil, packets are also skipped when js runtime is busy
+	limiter *callbackLimiter
+
+	// failVerdict is applied to packets, which the callback couldn't decide: js runtime
+	// was busy or the callback failed. It is drop for fail-closed callbacks
+	failVerdict stack.PacketFilterVerdict
+}
+
+// newPacketCallback returns packetCallback with limiter, which counts skipped packets
+// even if info has no limits
+func newPacketCallback(info callbacks.JsCallbackInfo, holder *goja.Object, granted callbacks.CapabilitySet, failClosed bool) *packetCallback {
+	limiter := newCallbackLimiter(&info)
+	if limiter == nil {
+		limiter = &callbackLimiter{}
+	}
+	failVerdict := stack.PacketFilterAccept
+	if failClosed {
+		failVerdict = stack.PacketFilterDrop
+	}
+	return &packetCallback{info: info, holder: holder, granted: granted, limiter: limiter, failVerdict: failVerdict}
+}
+
+// PacketCallbackTable implements stack.PacketFilter, it passes packets of all network stacks
+// to js callbacks. Packets have no task, so callbacks can use only independent hooks and
+// the global persistence storage
+type PacketCallbackTable struct {
+	mu        sync.RWMutex
+	callbacks [stack.NumHooks]*packetCallback
+}
+
+// register sets cb for hook, nil cb removes the callback. The table is installed into
+// netstack with the first callback and removed with the last one
+func (pt *PacketCallbackTable) register(hook stack.Hook, cb *packetCallback) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.callbacks[hook] = cb
+	for _, cb := range pt.callbacks {
+		if cb != nil {
+			stack.SetPacketFilter(pt)
+			return
+		}
+	}
+	stack.SetPacketFilter(nil)
+}
+
+func (pt *PacketCallbackTable) callback(hook stack.Hook) *packetCallback {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	return pt.callbacks[hook]
+}
+
+// FilterPacket implements stack.PacketFilter.FilterPacket. Packets get the fail verdict
+// of the callback (accept unless it is fail-closed) if the callback fails, because netstack
+// can't report errors to js.
+//
+// Netstack calls it with endpoint locks held, which the holder of js runtime may wait
+// for (e.g. in a syscall injected by hooks.syscall), so it never blocks on js runtime:
+// packets arriving while js runtime is busy get the fail verdict and are counted as skipped
+func (pt *PacketCallbackTable) FilterPacket(hook stack.Hook, view *stack.PacketView) stack.PacketFilterVerdict {
+	cb := pt.callback(hook)
+	if cb == nil {
+		return stack.PacketFilterAccept
+	}
+	if !cb.limiter.allow() {
+		return stack.PacketFilterAccept
+	}
+
+	runtime := GetJsRuntime()
+	if !runtime.Mutex.TryLock() {
+		cb.limiter.skip()
+		return cb.failVerdict
+	}
+	defer runtime.Mutex.Unlock()
+
+	verdict, err := cb.runLocked(runtime, hook, view)
+	if err != nil {
+		log.Warningf("packet callback for %v failed: %v", hook, err)
+		return cb.failVerdict
+	}
+	return verdict
+}
+
+// packetInterrupt interrupts js runtime when a packet callback exceeds its time limit.
+// The timer may fire after the callback returned, so it interrupts js runtime only
+// if the invocation, which started it, is still running
+type packetInterrupt struct {
+	mu   sync.Mutex
+	done bool
+}
+
+// interrupt interrupts vm if the invocation isn't finished
+func (i *packetInterrupt) interrupt(vm *goja.Runtime, err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !i.done {
+		vm.Interrupt(err)
+	}
+}
+
+// finish marks the invocation finished and clears interrupt set by it
+func (i *packetInterrupt) finish(vm *goja.Runtime) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.done = true
+	vm.ClearInterrupt()
+}
+
+// runLocked executes the callback with the packet object and applies its result to view
+//
+// Preconditions: runtime.Mutex is locked.
+func (cb *packetCallback) runLocked(runtime *GojaRuntime, hook stack.Hook, view *stack.PacketView) (stack.PacketFilterVerdict, error) {
+	runtime.granted, runtime.current = cb.granted, &cb.info
+	defer func() { runtime.granted, runtime.current = callbacks.AllCapabilities, nil }()
+
+	vm := runtime.JsVM
+	packet := newPacketObject(vm, hook, view)
+	if pending := cb.limiter.takePending(); pending > 0 {
+		if err := packet.Set(JsRateLimited, int64(pending)); err != nil {
+			return stack.PacketFilterAccept, err
+		}
+	}
+
+	timeout := defaultPacketTimeout
+	if cb.info.TimeoutMs > 0 {
+		timeout = time.Duration(cb.info.TimeoutMs) * time.Millisecond
+	}
+	invocation := &packetInterrupt{}
+	timer := time.AfterFunc(timeout, func() {
+		invocation.interrupt(vm, fmt.Errorf("packet callback exceeded time limit of %v", timeout))
+	})
+	defer func() {
+		timer.Stop()
+		invocation.finish(vm)
+	}()
+
+	builder := ScriptContextsBuilderOf()
+	builder = builder.AddContext3(HooksJsName, &IndependentHookAddableAdapter{ht: runtime.hooksTable, granted: cb.granted})
+	builder = builder.AddContext3(JsPersistenceContextName,
+		&ObjectAddableAdapter{name: JsGlobalPersistenceObject, object: runtime.Global})
+	builder = builder.AddContext3(PacketJsName, &ObjectAddableAdapter{name: "value", object: packet})
+	builder = builder.AddContext3("__callback__", &ObjectAddableAdapter{name: "invoke", object: cb.holder})
+
+	val, err := RunJsScript(vm, fmt.Sprintf("__callback__.invoke(%s.value)", PacketJsName), builder.Build())
+	if err != nil {
+		return stack.PacketFilterAccept, err
+	}
+	return applyPacketResult(vm, val, view)
+}
+
+// newPacketObject returns js object with the parsed packet
+func newPacketObject(vm *goja.Runtime, hook stack.Hook, view *stack.PacketView) *goja.Object {
+	packet := vm.NewObject()
+	for name, value := range map[string]interface{}{
+		"hook":     packetHookName(hook),
+		"netProto": networkProtocolName(view.NetProto),
+		"proto":    transportProtocolName(view.TransProto),
+		"src":      view.SrcAddr.String(),
+		"dst":      view.DstAddr.String(),
+		"srcPort":  int64(view.SrcPort),
+		"dstPort":  int64(view.DstPort),
+		"nic":      view.NICName,
+		"payload":  vm.NewArrayBuffer(view.Payload()),
+	} {
+		// Setting properties of the new ordinary object doesn't fail.
+		_ = packet.Set(name, value)
+	}
+	return packet
+}
+
+// applyPacketResult interprets the value returned by packet callback. Null, undefined and
+// "accept" accept the packet, "drop" drops it. Object may contain verdict and payload,
+// which replaces the payload of the packet and must have the same length
+func applyPacketResult(vm *goja.Runtime, val goja.Value, view *stack.PacketView) (stack.PacketFilterVerdict, error) {
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return stack.PacketFilterAccept, nil
+	}
+	if verdict, ok := val.Export().(string); ok {
+		return parsePacketVerdict(verdict)
+	}
+
+	obj := val.ToObject(vm)
+	if payload := obj.Get("payload"); payload != nil && !goja.IsUndefined(payload) && !goja.IsNull(payload) {
+		buf, ok := payload.Export().(goja.ArrayBuffer)
+		if !ok {
+			return stack.PacketFilterAccept, fmt.Errorf("payload should be ArrayBuffer, but it is %v", payload.ExportType())
+		}
+		if err := view.SetPayload(buf.Bytes()); err != nil {
+			return stack.PacketFilterAccept, err
+		}
+	}
+	if verdict := obj.Get("verdict"); verdict != nil && !goja.IsUndefined(verdict) && !goja.IsNull(verdict) {
+		return parsePacketVerdict(verdict.String())
+	}
+	return stack.PacketFilterAccept, nil
+}
+
+func parsePacketVerdict(verdict string) (stack.PacketFilterVerdict, error) {
+	switch verdict {
+	case packetVerdictAccept:
+		return stack.PacketFilterAccept, nil
+	case packetVerdictDrop:
+		return stack.PacketFilterDrop, nil
+	default:
+		return stack.PacketFilterAccept, fmt.Errorf("unknown packet verdict: %q", verdict)
+	}
+}
+
+func packetHookName(hook stack.Hook) string {
+	for name, h := range packetHooks {
+		if h == hook {
+			return name
+		}
+	}
+	return hook.String()
+}
+
+func networkProtocolName(proto tcpip.NetworkProtocolNumber) string {
+	switch proto {
+	case header.IPv4ProtocolNumber:
+		return "ipv4"
+	case header.IPv6ProtocolNumber:
+		return "ipv6"
+	case header.ARPProtocolNumber:
+		return "arp"
+	default:
+		return fmt.Sprintf("%#04x", proto)
+	}
+}
+
+func transportProtocolName(proto tcpip.TransportProtocolNumber) string {
+	switch proto {
+	case header.TCPProtocolNumber:
+		return "tcp"
+	case header.UDPProtocolNumber:
+		return "udp"
+	case header.ICMPv4ProtocolNumber:
+		return "icmp"
+	case header.ICMPv6ProtocolNumber:
+		return "icmpv6"
+	default:
+		return fmt.Sprintf("%d", proto)
+	}
+}
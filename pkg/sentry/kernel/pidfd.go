@@ -0,0 +1,91 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// PIDFD implements vfs.FileDescriptionImpl for pidfds, which refer to thread
+// groups. The pidfd becomes readable when the thread group exits.
+//
+// +stateify savable
+type PIDFD struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+
+	// tg is the thread group referred by the pidfd. tg is immutable.
+	tg *ThreadGroup
+}
+
+var _ vfs.FileDescriptionImpl = (*PIDFD)(nil)
+
+// NewPIDFD returns a new pidfd referring to tg.
+func (k *Kernel) NewPIDFD(ctx context.Context, tg *ThreadGroup, flags uint32) (*vfs.FileDescription, error) {
+	vd := k.VFS().NewAnonVirtualDentry("[pidfd]")
+	defer vd.DecRef(ctx)
+	fd := &PIDFD{tg: tg}
+	if err := fd.vfsfd.Init(fd, linux.O_RDWR|flags, vd.Mount(), vd.Dentry(), &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+		DenyPRead:         true,
+		DenyPWrite:        true,
+	}); err != nil {
+		return nil, err
+	}
+	return &fd.vfsfd, nil
+}
+
+// ThreadGroup returns the thread group referred by the pidfd.
+func (fd *PIDFD) ThreadGroup() *ThreadGroup {
+	return fd.tg
+}
+
+// PID returns the ID of the thread group in pidns for /proc/[pid]/fdinfo:
+// 0 if the thread group isn't visible in pidns and -1 if it has been reaped.
+func (fd *PIDFD) PID(pidns *PIDNamespace) int64 {
+	pidns.owner.mu.RLock()
+	defer pidns.owner.mu.RUnlock()
+	if fd.tg.tasksCount == 0 {
+		return -1
+	}
+	return int64(pidns.tgids[fd.tg])
+}
+
+// exited returns true if all tasks of the thread group have exited.
+func (fd *PIDFD) exited() bool {
+	ts := fd.tg.pidns.owner
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return fd.tg.tasksCount == 0 ||
+		(fd.tg.tasksCount == 1 && fd.tg.leader.exitState >= TaskExitZombie)
+}
+
+// Release implements vfs.FileDescriptionImpl.Release.
+func (fd *PIDFD) Release(context.Context) {}
+
+// Readiness implements waiter.Waitable.Readiness.
+func (fd *PIDFD) Readiness(mask waiter.EventMask) waiter.EventMask {
+	if fd.exited() {
+		return mask & waiter.ReadableEvents
+	}
+	return 0
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (fd *PIDFD) EventRegister(e *waiter.Entry) error {
+	fd.tg.pidfdQueue.EventRegister(e)
+	return nil
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (fd *PIDFD) EventUnregister(e *waiter.Entry) {
+	fd.tg.pidfdQueue.EventUnregister(e)
+}
+
+// Epollable implements FileDescriptionImpl.Epollable.
+func (fd *PIDFD) Epollable() bool {
+	return true
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/dop251/goja"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
 	"log"
 	"net"
+	"reflect"
 	"sync"
 )
 
@@ -18,6 +20,15 @@ type Command interface {
 	execute(kernel *Kernel, raw []byte) (any, error)
 }
 
+// StreamingCommand is the Command which keeps the connection open and writes
+// responses to it until the client disconnects
+type StreamingCommand interface {
+	Command
+
+	// stream is called instead of execute
+	stream(kernel *Kernel, raw []byte, conn net.Conn) error
+}
+
 type CommandTable struct {
 	commands map[string]Command
 	mutex    sync.Mutex
@@ -74,6 +85,12 @@ func registerCommands(table *CommandTable) error {
 		&ChangeStateCommand{},
 		&CallbacksListCommand{},
 		&UnregisterCallbacksCommand{},
+		&ApprovalSubscribeCommand{},
+		&ApprovalReplyCommand{},
+		&PendingApprovalsCommand{},
+		&GetPersistenceCommand{},
+		&SetPersistenceCommand{},
+		&ClearPersistenceCommand{},
 	}
 
 	for _, command := range commands {
@@ -113,23 +130,27 @@ func extractTypeAndPayload(request *jsonRequest) (string, []byte, error) {
 	return typeString, payloadBytes, nil
 }
 
-func handleRequest(kernel *Kernel, jsonDecoder *json.Decoder) ([]byte, error) {
+func decodeRequest(jsonDecoder *json.Decoder) (Command, []byte, error) {
 	var request jsonRequest
 	err := jsonDecoder.Decode(&request)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	requestType, payloadBytes, err := extractTypeAndPayload(&request)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	table := GetJsRuntime().runtimeCmdTable
 	command, err := table.GetCommand(requestType)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	return command, payloadBytes, nil
+}
+
+func handleRequest(kernel *Kernel, command Command, payloadBytes []byte) ([]byte, error) {
 	responsePayload, err := command.execute(kernel, payloadBytes)
 	if err != nil {
 		return nil, err
@@ -165,7 +186,18 @@ func handleConnection(kernel *Kernel, conn net.Conn) {
 	}(conn)
 
 	jsonDecoder := json.NewDecoder(conn)
-	response, err := handleRequest(kernel, jsonDecoder)
+	command, payloadBytes, err := decodeRequest(jsonDecoder)
+	if streaming, ok := command.(StreamingCommand); err == nil && ok {
+		if err := streaming.stream(kernel, payloadBytes, conn); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	var response []byte
+	if err == nil {
+		response, err = handleRequest(kernel, command, payloadBytes)
+	}
 	if err != nil {
 		response = messageResponse(ResponseTypeError, err.Error())
 	}
@@ -231,7 +263,8 @@ func (c ChangeStateCommand) execute(_ *Kernel, raw []byte) (any, error) {
 	defer runtime.Mutex.Unlock()
 
 	builder := ScriptContextsBuilderOf()
-	builder = builder.AddContext3(HooksJsName, &IndependentHookAddableAdapter{ht: runtime.hooksTable})
+	builder = builder.AddContext3(HooksJsName,
+		&IndependentHookAddableAdapter{ht: runtime.hooksTable, granted: callbacks.AllCapabilities})
 	builder = builder.AddContext3(JsPersistenceContextName,
 		&ObjectAddableAdapter{name: JsGlobalPersistenceObject, object: runtime.Global})
 
@@ -271,7 +304,9 @@ func (c CallbacksListCommand) execute(_ *Kernel, _ []byte) (any, error) {
 	table := GetJsRuntime().callbackTable
 	table.mutexBefore.Lock()
 	table.mutexAfter.Lock()
+	table.mutexEmulate.Lock()
 
+	defer table.mutexEmulate.Unlock()
 	defer table.mutexAfter.Unlock()
 	defer table.mutexBefore.Unlock()
 
@@ -287,6 +322,11 @@ func (c CallbacksListCommand) execute(_ *Kernel, _ []byte) (any, error) {
 		infos = append(infos, info)
 	}
 
+	for _, cbEmulate := range table.callbackEmulate {
+		info := cbEmulate.Info()
+		infos = append(infos, info)
+	}
+
 	response := CallbackListResponse{JsCallbacks: infos}
 	return response, nil
 }
@@ -327,6 +367,12 @@ func executeListOption(table *CallbackTable, request *UnregisterCallbacksRequest
 				return err
 			}
 
+		case JsCallbackTypeEmulate:
+			err := table.unregisterCallbackEmulate(uintptr(dto.Sysno))
+			if err != nil {
+				return err
+			}
+
 		default:
 			return errors.New(fmt.Sprintf("unknown callback type [%s]", dto.Type))
 		}
@@ -367,3 +413,289 @@ func (u UnregisterCallbacksCommand) execute(_ *Kernel, raw []byte) (any, error)
 
 	return nil, nil
 }
+
+// approval commands
+
+type ApprovalSubscribeCommand struct{}
+
+func (a ApprovalSubscribeCommand) name() string {
+	return "approval-subscribe"
+}
+
+func (a ApprovalSubscribeCommand) execute(_ *Kernel, _ []byte) (any, error) {
+	return nil, errors.New("approval-subscribe requires connection")
+}
+
+// stream writes pending and then new deferred syscalls to conn as newline-delimited json
+// until the client disconnects
+func (a ApprovalSubscribeCommand) stream(_ *Kernel, _ []byte, conn net.Conn) error {
+	broker := GetJsRuntime().approvals
+	subscriber := broker.subscribe()
+	defer broker.unsubscribe(subscriber)
+
+	// The client isn't expected to send anything, so reading fails only when it disconnects
+	closed := make(chan struct{})
+	go func() {
+		_, _ = conn.Read(make([]byte, 1))
+		close(closed)
+	}()
+
+	write := func(request ApprovalRequestDto) error {
+		response, err := json.Marshal(&Response{Type: ResponseTypeOk, Message: "approval request", Payload: request})
+		if err != nil {
+			return err
+		}
+		return writeToConn(conn, append(response, '\n'))
+	}
+
+	for _, request := range broker.pendingRequests() {
+		if err := write(request); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case request := <-subscriber:
+			if err := write(request); err != nil {
+				return err
+			}
+		case <-closed:
+			return nil
+		}
+	}
+}
+
+type ApprovalReplyCommand struct{}
+
+func (a ApprovalReplyCommand) name() string {
+	return "approval-reply"
+}
+
+func (a ApprovalReplyCommand) execute(_ *Kernel, raw []byte) (any, error) {
+	var reply ApprovalReplyDto
+	err := json.Unmarshal(raw, &reply)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, GetJsRuntime().approvals.reply(reply)
+}
+
+type PendingApprovalsResponse struct {
+	Requests []ApprovalRequestDto `json:"requests"`
+}
+
+type PendingApprovalsCommand struct{}
+
+func (p PendingApprovalsCommand) name() string {
+	return "pending-approvals"
+}
+
+func (p PendingApprovalsCommand) execute(_ *Kernel, _ []byte) (any, error) {
+	return PendingApprovalsResponse{Requests: GetJsRuntime().approvals.pendingRequests()}, nil
+}
+
+// persistence commands
+
+// maxPersistenceSize limits JSON of the persistence store passed over the runtime socket
+const maxPersistenceSize = 1 << 20
+
+// PersistenceRequestDto selects the persistence store: persistence.glb if TID is 0,
+// persistence.local of the task otherwise. Value is used by set-persistence only,
+// its keys are assigned to the store, Replace removes other keys of the store
+type PersistenceRequestDto struct {
+	TID     int32           `json:"tid,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Replace bool            `json:"replace,omitempty"`
+}
+
+type PersistenceResponse struct {
+	TID   int32           `json:"tid,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+// persistenceStore returns the store selected by request. The task-local store is created
+// if the task has not run scripts yet. Precondition: runtime.Mutex is held
+func persistenceStore(kernel *Kernel, runtime *GojaRuntime, request *PersistenceRequestDto) (*goja.Object, error) {
+	if request.TID == 0 {
+		return runtime.Global, nil
+	}
+	if request.TID < 0 {
+		return nil, fmt.Errorf("invalid tid %d", request.TID)
+	}
+	if kernel == nil {
+		return nil, errors.New("tasks are not available")
+	}
+
+	t := kernel.TaskSet().Root.TaskWithID(ThreadID(request.TID))
+	if t == nil {
+		return nil, fmt.Errorf("task with tid %d not found", request.TID)
+	}
+	if t.taskLocalStorage == nil {
+		t.taskLocalStorage = runtime.JsVM.NewObject()
+	}
+	return t.taskLocalStorage, nil
+}
+
+func decodePersistenceRequest(raw []byte) (*PersistenceRequestDto, error) {
+	var request PersistenceRequestDto
+	if len(raw) == 0 || string(raw) == "null" {
+		return &request, nil
+	}
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// clearPersistenceStore removes all own keys of the store, the object itself is kept
+// because it may be referenced by js code
+func clearPersistenceStore(store *goja.Object) error {
+	for _, key := range store.Keys() {
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type GetPersistenceCommand struct{}
+
+func (g GetPersistenceCommand) name() string {
+	return "get-persistence"
+}
+
+func (g GetPersistenceCommand) execute(kernel *Kernel, raw []byte) (any, error) {
+	request, err := decodePersistenceRequest(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime := GetJsRuntime()
+	runtime.Mutex.Lock()
+	defer runtime.Mutex.Unlock()
+
+	store, err := persistenceStore(kernel, runtime, request)
+	if err != nil {
+		return nil, err
+	}
+	value, err := marshalPersistenceStore(runtime.JsVM, store)
+	if err != nil {
+		return nil, err
+	}
+	return PersistenceResponse{TID: request.TID, Value: value}, nil
+}
+
+// marshalPersistenceStore returns JSON of the store. The size of JSON is counted while the
+// store is serialized, so large stores fail before their whole JSON is built
+//
+// Preconditions: runtime.Mutex is held.
+func marshalPersistenceStore(vm *goja.Runtime, store *goja.Object) ([]byte, error) {
+	stringify, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("stringify"))
+	if !ok {
+		return nil, errors.New("JSON.stringify is not available")
+	}
+
+	errTooLarge := fmt.Errorf("persistence store has more than %d bytes of JSON", maxPersistenceSize)
+	size, tooLarge := 0, false
+	replacer := func(call goja.FunctionCall) goja.Value {
+		key, value := call.Argument(0), call.Argument(1)
+		// The key with quotes, colon and comma, the size of strings is known without encoding
+		size += len(key.String()) + 4
+		if value.ExportType() == reflect.TypeOf("") {
+			size += len(value.String())
+		}
+		if size > maxPersistenceSize {
+			tooLarge = true
+			panic(vm.NewGoError(errTooLarge))
+		}
+		return value
+	}
+
+	value, err := stringify(goja.Undefined(), store, vm.ToValue(replacer))
+	if tooLarge {
+		return nil, errTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := []byte(value.String())
+	if len(data) > maxPersistenceSize {
+		return nil, fmt.Errorf("persistence store has %d bytes of JSON, the limit is %d", len(data), maxPersistenceSize)
+	}
+	return data, nil
+}
+
+type SetPersistenceCommand struct{}
+
+func (s SetPersistenceCommand) name() string {
+	return "set-persistence"
+}
+
+func (s SetPersistenceCommand) execute(kernel *Kernel, raw []byte) (any, error) {
+	request, err := decodePersistenceRequest(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Value) > maxPersistenceSize {
+		return nil, fmt.Errorf("value has %d bytes, the limit is %d", len(request.Value), maxPersistenceSize)
+	}
+
+	runtime := GetJsRuntime()
+	runtime.Mutex.Lock()
+	defer runtime.Mutex.Unlock()
+
+	store, err := persistenceStore(kernel, runtime, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON.parse makes ordinary js objects and arrays, which scripts can modify like their own values
+	parse, ok := goja.AssertFunction(runtime.JsVM.Get("JSON").ToObject(runtime.JsVM).Get("parse"))
+	if !ok {
+		return nil, errors.New("JSON.parse is not available")
+	}
+	parsed, err := parse(goja.Undefined(), runtime.JsVM.ToValue(string(request.Value)))
+	if err != nil {
+		return nil, err
+	}
+	value, ok := parsed.(*goja.Object)
+	if !ok || value.ClassName() != "Object" {
+		return nil, errors.New("value should be JSON object")
+	}
+
+	if request.Replace {
+		if err := clearPersistenceStore(store); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range value.Keys() {
+		if err := store.Set(key, value.Get(key)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+type ClearPersistenceCommand struct{}
+
+func (c ClearPersistenceCommand) name() string {
+	return "clear-persistence"
+}
+
+func (c ClearPersistenceCommand) execute(kernel *Kernel, raw []byte) (any, error) {
+	request, err := decodePersistenceRequest(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime := GetJsRuntime()
+	runtime.Mutex.Lock()
+	defer runtime.Mutex.Unlock()
+
+	store, err := persistenceStore(kernel, runtime, request)
+	if err != nil {
+		return nil, err
+	}
+	return nil, clearPersistenceStore(store)
+}
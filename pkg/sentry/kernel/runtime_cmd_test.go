@@ -282,3 +282,72 @@ func TestUnregisterCallbacksCommand_name(t *testing.T) {
 		t.Fatalf("wrong command name: got '%s', expected '%s'", cmd.name(), wantName)
 	}
 }
+
+func TestPersistenceCommands_global(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	if _, err := (SetPersistenceCommand{}).execute(nil, []byte(`{"value": {"threshold": 10, "names": ["a"]}}`)); err != nil {
+		t.Fatalf("unexpected error while setting persistence: %s", err)
+	}
+	contexts := testBuildContexts()
+	val, err := RunJsScript(jsRuntime.JsVM, `persistence.glb.names.push("b"); persistence.glb.threshold + 1`, contexts)
+	if err != nil {
+		t.Fatalf("unexpected error while running script: %s", err)
+	}
+	if val.ToInteger() != 11 {
+		t.Fatalf("script got wrong value from persistence: %v", val)
+	}
+
+	res, err := (GetPersistenceCommand{}).execute(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error while getting persistence: %s", err)
+	}
+	rsp, ok := res.(PersistenceResponse)
+	if !ok {
+		t.Fatalf("bad return value, got %T expected %T", res, PersistenceResponse{})
+	}
+	if want := `{"threshold":10,"names":["a","b"]}`; string(rsp.Value) != want {
+		t.Fatalf("wrong persistence value: got %s, expected %s", rsp.Value, want)
+	}
+
+	if _, err := (SetPersistenceCommand{}).execute(nil, []byte(`{"value": {"mode": "strict"}, "replace": true}`)); err != nil {
+		t.Fatalf("unexpected error while replacing persistence: %s", err)
+	}
+	res, _ = (GetPersistenceCommand{}).execute(nil, []byte(`{}`))
+	if want := `{"mode":"strict"}`; string(res.(PersistenceResponse).Value) != want {
+		t.Fatalf("wrong persistence value: got %s, expected %s", res.(PersistenceResponse).Value, want)
+	}
+
+	if _, err := (ClearPersistenceCommand{}).execute(nil, nil); err != nil {
+		t.Fatalf("unexpected error while clearing persistence: %s", err)
+	}
+	res, _ = (GetPersistenceCommand{}).execute(nil, nil)
+	if want := `{}`; string(res.(PersistenceResponse).Value) != want {
+		t.Fatalf("wrong persistence value: got %s, expected %s", res.(PersistenceResponse).Value, want)
+	}
+}
+
+func TestSetPersistenceCommand_invalidValue(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	for _, payload := range []string{`{"value": [1, 2]}`, `{"value": 1}`, `{}`, `{"tid": -1, "value": {}}`} {
+		if _, err := (SetPersistenceCommand{}).execute(nil, []byte(payload)); err == nil {
+			t.Fatalf("no error for invalid request %s", payload)
+		}
+	}
+}
+
+func TestGetPersistenceCommand_tooLarge(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	contexts := testBuildContexts()
+	if _, err := RunJsScript(jsRuntime.JsVM, `for (var i = 0; i < 100; i++) persistence.glb["k" + i] = "v".repeat(20000)`, contexts); err != nil {
+		t.Fatalf("unexpected error while running script: %s", err)
+	}
+	if _, err := (GetPersistenceCommand{}).execute(nil, nil); err == nil {
+		t.Fatalf("no error for persistence store larger than %d bytes", maxPersistenceSize)
+	}
+}
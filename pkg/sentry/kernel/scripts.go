@@ -14,6 +14,7 @@ import (
 const (
 	JsCallbackTypeAfter          = "after"
 	JsCallbackTypeBefore         = "before"
+	JsCallbackTypeEmulate        = "emulate"
 	HooksJsName                  = "hooks"
 	ArgsJsName                   = "args"
 	JsSyscallReturnValue         = "ret"
@@ -192,28 +193,66 @@ func RunJsScript(vm *goja.Runtime, jsSource string, contexts []ScriptContext) (g
 	return val, nil
 }
 
-func RunAbstractCallback(t *Task, jsSource string,
+// extractCallbackResult returns args and substitution of the syscall returned by callback and saves
+// its approval request to the task. Args and substitution change the syscall, so they are ignored
+// unless modify-process capability is granted
+func extractCallbackResult(t *Task, info *callbacks.JsCallbackInfo, granted callbacks.CapabilitySet,
+	args *arch.SyscallArguments, vm *goja.Runtime, val goja.Value) (*arch.SyscallArguments, *SyscallReturnValue, error) {
+
+	request, err := extractApprovalRequestFromRetJsValue(vm, val, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	if request != nil {
+		t.approvalRequest = request
+	}
+
+	if !granted.Has(callbacks.CapabilityModifyProcess) {
+		return args, nil, nil
+	}
+
+	retArgs, err := extractArgsFromRetJsValue(args, vm, val)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	retSub, err := extractSubstitutionFromRetJsValue(vm, val)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return retArgs, retSub, nil
+}
+
+// grantedCapabilities returns capabilities declared in info. Nil info means the callback is unrestricted
+func grantedCapabilities(info *callbacks.JsCallbackInfo) (callbacks.CapabilitySet, error) {
+	if info == nil {
+		return callbacks.AllCapabilities, nil
+	}
+	return info.GrantedCapabilities()
+}
+
+// RunAbstractCallback runs jsSource with hooks bound for the task. Only hooks allowed
+// by capabilities declared in info are callable, nil info grants all capabilities
+func RunAbstractCallback(t *Task, info *callbacks.JsCallbackInfo, jsSource string,
 	args *arch.SyscallArguments, additionalContexts ScriptContexts) (*arch.SyscallArguments, *SyscallReturnValue, error) {
 
+	granted, err := grantedCapabilities(info)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	runtime := GetJsRuntime()
 	runtime.Mutex.Lock()
 	defer runtime.Mutex.Unlock()
 
 	builder := ScriptContextsBuilderOf().AddAll(additionalContexts)
 	builder = builder.AddContext3(ArgsJsName, &SyscallArgsAddableAdapter{args})
-	builder = builder.AddContext3(HooksJsName, &IndependentHookAddableAdapter{ht: runtime.hooksTable})
-	builder = builder.AddContext3(HooksJsName, &DependentHookAddableAdapter{ht: runtime.hooksTable, task: t})
-	builder = builder.AddContext3(JsPersistenceContextName,
-		&ObjectAddableAdapter{name: JsGlobalPersistenceObject, object: runtime.Global})
-
-	if t.taskLocalStorage == nil {
-		t.taskLocalStorage = runtime.JsVM.NewObject()
+	if t.skippedCallbacks > 0 {
+		builder = builder.AddContext3(ArgsJsName, rateLimitedMarker(t.skippedCallbacks))
 	}
-	builder = builder.AddContext3(JsPersistenceContextName,
-		&ObjectAddableAdapter{name: JsTaskLocalPersistenceObject, object: t.taskLocalStorage})
 
-	contexts := builder.Build()
-	val, err := RunJsScript(runtime.JsVM, jsSource, contexts)
+	val, err := runtime.runTaskScriptLocked(t, info, granted, jsSource, builder)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -222,15 +261,30 @@ func RunAbstractCallback(t *Task, jsSource string,
 		return args, nil, nil
 	}
 
-	retArgs, err := extractArgsFromRetJsValue(args, runtime.JsVM, val)
-	if err != nil {
-		return nil, nil, err
-	}
+	return extractCallbackResult(t, info, granted, args, runtime.JsVM, val)
+}
 
-	retSub, err := extractSubstitutionFromRetJsValue(runtime.JsVM, val)
-	if err != nil {
-		return nil, nil, err
+// runTaskScriptLocked runs jsSource with hooks bound for the task and persistence objects
+// added to contexts of builder. Only hooks allowed by granted capabilities are callable
+//
+// Preconditions: runtime.Mutex is locked.
+func (runtime *GojaRuntime) runTaskScriptLocked(t *Task, info *callbacks.JsCallbackInfo, granted callbacks.CapabilitySet,
+	jsSource string, builder *ScriptContextsBuilder) (goja.Value, error) {
+
+	runtime.granted, runtime.current = granted, info
+	defer func() { runtime.granted, runtime.current = callbacks.AllCapabilities, nil }()
+
+	builder = builder.AddContext3(HooksJsName, &IndependentHookAddableAdapter{ht: runtime.hooksTable, granted: granted})
+	builder = builder.AddContext3(HooksJsName,
+		&DependentHookAddableAdapter{ht: runtime.hooksTable, task: t, granted: granted})
+	builder = builder.AddContext3(JsPersistenceContextName,
+		&ObjectAddableAdapter{name: JsGlobalPersistenceObject, object: runtime.Global})
+
+	if t.taskLocalStorage == nil {
+		t.taskLocalStorage = runtime.JsVM.NewObject()
 	}
+	builder = builder.AddContext3(JsPersistenceContextName,
+		&ObjectAddableAdapter{name: JsTaskLocalPersistenceObject, object: t.taskLocalStorage})
 
-	return retArgs, retSub, nil
+	return RunJsScript(runtime.JsVM, jsSource, builder.Build())
 }
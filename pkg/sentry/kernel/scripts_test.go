@@ -5,6 +5,7 @@ import (
 	"github.com/dop251/goja"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
 	"testing"
 )
 
@@ -17,6 +18,7 @@ func testInitJsRuntime() {
 }
 
 func testDestroyJsRuntime() {
+	stack.SetPacketFilter(nil)
 	jsRuntime = nil
 }
 
@@ -91,6 +93,7 @@ func testRunAbstractCallbackRunsAndReturnsSyscallRetValue(t *testing.T, cb JsCal
 	args := arch.SyscallArguments{}
 	newArgs, rval, err := RunAbstractCallback(
 		&task,
+		cb.callbackInfo(),
 		jsCallbackInvocationTemplate(cb),
 		&args,
 		ScriptContextsBuilderOf().Build())
@@ -208,6 +211,7 @@ func testRunAbstractCallbackGetCorrectArguments(t *testing.T, cb JsCallback) {
 	}
 	newArgs, rval, err := RunAbstractCallback(
 		&task,
+		cb.callbackInfo(),
 		jsCallbackInvocationTemplate(cb),
 		&args,
 		ScriptContextsBuilderOf().Build())
@@ -272,6 +276,56 @@ func TestRunAbstractCallback_WithJsCallbackAfter_hasCorrectArgsAndReturnNewArgs(
 	testRunAbstractCallbackGetCorrectArguments(t, &cb)
 }
 
+var cbObserverReturnsNewArgsAndValue = `
+	function cb() {
+		return {
+			"0": 20,
+			"ret": 1,
+			"errno": 1
+		}
+	}
+`
+
+func TestRunAbstractCallback_withObserveCapability_ignoresReturnedArgsAndValue(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	cb := JsCallbackBefore{
+		info: callbacks.JsCallbackInfo{
+			Sysno:          1,
+			EntryPoint:     "cb",
+			CallbackSource: cbObserverReturnsNewArgsAndValue,
+			CallbackBody:   cbObserverReturnsNewArgsAndValue,
+			CallbackArgs:   make([]string, 0),
+			Type:           JsCallbackTypeBefore,
+			Capabilities:   []string{callbacks.CapabilityObserve},
+		},
+	}
+	task := testCreateEmptyTask()
+	args := arch.SyscallArguments{}
+	for i := 0; i < len(args); i++ {
+		args[i] = arch.SyscallArgument{Value: uintptr(i)}
+	}
+
+	newArgs, rval, err := RunAbstractCallback(
+		&task,
+		cb.callbackInfo(),
+		jsCallbackInvocationTemplate(&cb),
+		&args,
+		ScriptContextsBuilderOf().Build())
+	if err != nil {
+		t.Fatalf("failed to execute callback: %s", err)
+	}
+	if rval != nil {
+		t.Fatalf("return value of observer was applied: %v", rval.returnValue)
+	}
+	for i := 0; i < len(newArgs); i++ {
+		if newArgs[i].Value != uintptr(i) {
+			t.Fatalf("argument %v of observer was applied: got %v expected %v", i, newArgs[i].Value, i)
+		}
+	}
+}
+
 type stubDependentGoHook struct {
 	createCount int
 	callCount   int
@@ -285,6 +339,10 @@ func (*stubDependentGoHook) jsName() string {
 	return "stubD"
 }
 
+func (*stubDependentGoHook) capabilities() callbacks.CapabilitySet {
+	return callbacks.CapabilitySetOf(callbacks.CapabilityModifyMemory)
+}
+
 func (h *stubDependentGoHook) createCallback(t *Task) HookCallback {
 	h.createCount += 1
 	return func(args ...goja.Value) (interface{}, error) {
@@ -306,6 +364,10 @@ func (*stubIndependentGoHook) jsName() string {
 	return "stubI"
 }
 
+func (*stubIndependentGoHook) capabilities() callbacks.CapabilitySet {
+	return callbacks.CapabilitySetOf(callbacks.CapabilityObserve)
+}
+
 func (h *stubIndependentGoHook) createCallback() HookCallback {
 	h.createCount += 1
 	return func(args ...goja.Value) (interface{}, error) {
@@ -328,6 +390,7 @@ func testScriptRunsWithHookUsage(t *testing.T, cb JsCallback) {
 	args := arch.SyscallArguments{}
 	newArgs, rval, err := RunAbstractCallback(
 		&task,
+		cb.callbackInfo(),
 		jsCallbackInvocationTemplate(cb),
 		&args,
 		ScriptContextsBuilderOf().Build())
@@ -413,6 +476,7 @@ func TestRunAbstractCallback_withNotRegisteredHook(t *testing.T) {
 	args := arch.SyscallArguments{}
 	_, _, err := RunAbstractCallback(
 		&task,
+		cb.callbackInfo(),
 		jsCallbackInvocationTemplate(&cb),
 		&args,
 		ScriptContextsBuilderOf().Build())
@@ -449,6 +513,7 @@ func testStorage(t *testing.T, cbBefore JsCallback, cbAfter JsCallback, local bo
 	args := arch.SyscallArguments{}
 	beforeArgs, _, err := RunAbstractCallback(
 		&task,
+		cbBefore.callbackInfo(),
 		jsCallbackInvocationTemplate(cbBefore),
 		&args,
 		ScriptContextsBuilderOf().Build())
@@ -460,6 +525,7 @@ func testStorage(t *testing.T, cbBefore JsCallback, cbAfter JsCallback, local bo
 	}
 	_, afterRval, err := RunAbstractCallback(
 		&task,
+		cbAfter.callbackInfo(),
 		jsCallbackInvocationTemplate(cbAfter),
 		beforeArgs,
 		ScriptContextsBuilderOf().Build())
@@ -40,9 +40,9 @@ const (
 //
 // +stateify savable
 type taskSeccomp struct {
-	// filters is the list of seccomp programs that are applied to the task,
+	// filters is the list of seccomp filters that are applied to the task,
 	// in the order in which they were installed.
-	filters []bpf.Program
+	filters []seccompFilter
 
 	// cache maps syscall numbers to the action to take for that syscall number.
 	// It is only populated for syscalls where determining this action does not
@@ -57,10 +57,23 @@ type taskSeccomp struct {
 	cacheAuditNumber uint32
 }
 
+// seccompFilter is a seccomp filter installed by seccomp(2).
+//
+// +stateify savable
+type seccompFilter struct {
+	// program is the BPF program of the filter.
+	program bpf.Program
+
+	// listener receives SECCOMP_RET_USER_NOTIF notifications of the filter.
+	// listener is nil if the filter was installed without
+	// SECCOMP_FILTER_FLAG_NEW_LISTENER.
+	listener *SeccompListener
+}
+
 // copy returns a copy of this `taskSeccomp`.
 func (ts *taskSeccomp) copy() *taskSeccomp {
 	return &taskSeccomp{
-		filters:          append(([]bpf.Program)(nil), ts.filters...),
+		filters:          append(([]seccompFilter)(nil), ts.filters...),
 		cacheAuditNumber: ts.cacheAuditNumber,
 		cache:            ts.cache,
 	}
@@ -94,7 +107,8 @@ func seccompSiginfo(t *Task, errno, sysno int32, ip hostarch.Addr) *linux.Signal
 //
 // Preconditions: The caller must be running on the task goroutine.
 func (t *Task) checkSeccompSyscall(sysno int32, args arch.SyscallArguments, ip hostarch.Addr) linux.BPFAction {
-	result := linux.BPFAction(t.evaluateSyscallFilters(sysno, args, ip))
+	ret, listener := t.evaluateSyscallFilters(sysno, args, ip)
+	result := linux.BPFAction(ret)
 	action := result & linux.SECCOMP_RET_ACTION
 	switch action {
 	case linux.SECCOMP_RET_TRAP:
@@ -112,6 +126,27 @@ func (t *Task) checkSeccompSyscall(sysno int32, args arch.SyscallArguments, ip h
 		// userland as the errno without executing the system call."
 		t.Arch().SetReturn(-uintptr(result.Data()))
 
+	case linux.SECCOMP_RET_USER_NOTIF:
+		// "Forward the system call to an attached user-space supervisor
+		// process to allow that process to decide what to do with the system
+		// call. If there is no attached supervisor [...] then the filter
+		// returns ENOSYS." - seccomp(2)
+		if listener == nil {
+			tmp := uintptr(unix.ENOSYS)
+			t.Arch().SetReturn(-tmp)
+			return linux.SECCOMP_RET_ERRNO
+		}
+		val, cont := listener.notify(t, seccompDataOf(t, sysno, args, ip))
+		if cont {
+			// SECCOMP_USER_NOTIF_FLAG_CONTINUE executes the system call.
+			return linux.SECCOMP_RET_ALLOW
+		}
+		t.Arch().SetReturn(uintptr(val))
+		// Like other syscall returns, this is checked for restart errnos,
+		// which restart the syscall if the notification was interrupted.
+		t.haveSyscallReturn = true
+		return linux.SECCOMP_RET_ERRNO
+
 	case linux.SECCOMP_RET_TRACE:
 		// "When returned, this value will cause the kernel to attempt to
 		// notify a ptrace()-based tracer prior to executing the system call.
@@ -139,22 +174,12 @@ func (t *Task) checkSeccompSyscall(sysno int32, args arch.SyscallArguments, ip h
 	return action
 }
 
-func (t *Task) evaluateSyscallFilters(sysno int32, args arch.SyscallArguments, ip hostarch.Addr) uint32 {
-	ret := uint32(linux.SECCOMP_RET_ALLOW)
-	ts := t.seccomp.Load().(*taskSeccomp)
-	if ts == nil {
-		return ret
-	}
-	arch := t.image.st.AuditNumber
-	if arch == ts.cacheAuditNumber && sysno >= 0 && sysno <= sentry.MaxSyscallNum {
-		if cached := ts.cache[sysno]; cached != uncacheableBPFAction {
-			return uint32(cached)
-		}
-	}
-
+// seccompDataOf returns the input of seccomp filters for syscall sysno at
+// instruction pointer ip.
+func seccompDataOf(t *Task, sysno int32, args arch.SyscallArguments, ip hostarch.Addr) linux.SeccompData {
 	data := linux.SeccompData{
 		Nr:                 sysno,
-		Arch:               arch,
+		Arch:               t.image.st.AuditNumber,
 		InstructionPointer: uint64(ip),
 	}
 	// data.args is []uint64 and args is []arch.SyscallArgument (uintptr), so
@@ -165,12 +190,34 @@ func (t *Task) evaluateSyscallFilters(sysno int32, args arch.SyscallArguments, i
 		}
 		data.Args[i] = arg.Uint64()
 	}
+	return data
+}
+
+// evaluateSyscallFilters returns the result of the task's seccomp filters for
+// syscall sysno, and the listener of the filter that returned it, if any.
+func (t *Task) evaluateSyscallFilters(sysno int32, args arch.SyscallArguments, ip hostarch.Addr) (uint32, *SeccompListener) {
+	ret := uint32(linux.SECCOMP_RET_ALLOW)
+	ts := t.seccomp.Load().(*taskSeccomp)
+	if ts == nil {
+		return ret, nil
+	}
+	arch := t.image.st.AuditNumber
+	if arch == ts.cacheAuditNumber && sysno >= 0 && sysno <= sentry.MaxSyscallNum {
+		if cached := ts.cache[sysno]; cached != uncacheableBPFAction {
+			// SECCOMP_RET_USER_NOTIF results aren't cached, so cached
+			// results don't need a listener.
+			return uint32(cached), nil
+		}
+	}
+
+	data := seccompDataOf(t, sysno, args, ip)
 	input := dataAsBPFInput(t, &data)
+	var listener *SeccompListener
 
 	// "Every filter successfully installed will be evaluated (in reverse
 	// order) for each system call the task makes." - kernel/seccomp.c
 	for i := len(ts.filters) - 1; i >= 0; i-- {
-		thisRet, err := bpf.Exec[bpf.NativeEndian](ts.filters[i], input)
+		thisRet, err := bpf.Exec[bpf.NativeEndian](ts.filters[i].program, input)
 		if err != nil {
 			t.Debugf("seccomp-bpf filter %d returned error: %v", i, err)
 			thisRet = uint32(linux.SECCOMP_RET_KILL_THREAD)
@@ -188,10 +235,11 @@ func (t *Task) evaluateSyscallFilters(sysno int32, args arch.SyscallArguments, i
 		// include/uapi/linux/seccomp.h
 		if (thisRet & linux.SECCOMP_RET_ACTION) < (ret & linux.SECCOMP_RET_ACTION) {
 			ret = thisRet
+			listener = ts.filters[i].listener
 		}
 	}
 
-	return ret
+	return ret, listener
 }
 
 // checkFilterCacheability executes `program` on the given `input`, and
@@ -245,7 +293,7 @@ func (ts *taskSeccomp) populateCache(t *Task) {
 		// If any filter is not cacheable, then we cannot cache the result for
 		// this sysno.
 		for i := len(ts.filters) - 1; i >= 0; i-- {
-			result, cacheErr := checkFilterCacheability(ts.filters[i], input)
+			result, cacheErr := checkFilterCacheability(ts.filters[i].program, input)
 			if cacheErr != nil {
 				sysnoIsCacheable = false
 				break
@@ -254,6 +302,11 @@ func (ts *taskSeccomp) populateCache(t *Task) {
 				ret = linux.BPFAction(result)
 			}
 		}
+		// The listener of the filter that returned SECCOMP_RET_USER_NOTIF
+		// isn't cached.
+		if ret&linux.SECCOMP_RET_ACTION == linux.SECCOMP_RET_USER_NOTIF {
+			sysnoIsCacheable = false
+		}
 		if sysnoIsCacheable {
 			ts.cache[sysno] = ret
 		} else {
@@ -262,10 +315,11 @@ func (ts *taskSeccomp) populateCache(t *Task) {
 	}
 }
 
-// AppendSyscallFilter adds BPF program p as a system call filter.
+// AppendSyscallFilter adds BPF program p as a system call filter. If listener
+// is not nil, it receives SECCOMP_RET_USER_NOTIF notifications of the filter.
 //
 // Preconditions: The caller must be running on the task goroutine.
-func (t *Task) AppendSyscallFilter(p bpf.Program, syncAll bool) error {
+func (t *Task) AppendSyscallFilter(p bpf.Program, syncAll bool, listener *SeccompListener) error {
 	// While syscallFilters are an atomic.Value we must take the mutex to prevent
 	// our read-copy-update from happening while another task is syncing syscall
 	// filters to us, this keeps the filters in a consistent state.
@@ -280,7 +334,12 @@ func (t *Task) AppendSyscallFilter(p bpf.Program, syncAll bool) error {
 
 	if ts := t.seccomp.Load().(*taskSeccomp); ts != nil {
 		for _, f := range ts.filters {
-			totalLength += f.Length() + 4
+			totalLength += f.program.Length() + 4
+			// Filter chains can only have one listener. Linux:
+			// kernel/seccomp.c:has_duplicate_listener()
+			if listener != nil && f.listener != nil {
+				return linuxerr.EBUSY
+			}
 		}
 		newSeccomp.filters = append(newSeccomp.filters, ts.filters...)
 	}
@@ -289,7 +348,10 @@ func (t *Task) AppendSyscallFilter(p bpf.Program, syncAll bool) error {
 		return linuxerr.ENOMEM
 	}
 
-	newSeccomp.filters = append(newSeccomp.filters, p)
+	newSeccomp.filters = append(newSeccomp.filters, seccompFilter{
+		program:  p,
+		listener: listener,
+	})
 	newSeccomp.populateCache(t)
 	t.seccomp.Store(newSeccomp)
 
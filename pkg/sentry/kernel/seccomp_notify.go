@@ -0,0 +1,401 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// SeccompListener implements vfs.FileDescriptionImpl for seccomp user
+// notification listeners created by SECCOMP_FILTER_FLAG_NEW_LISTENER. Syscalls
+// for which the filter returns SECCOMP_RET_USER_NOTIF block until the
+// supervisor responds to their notifications.
+//
+// +stateify savable
+type SeccompListener struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+
+	// queue is used to notify the supervisor about notifications.
+	queue waiter.Queue
+
+	// mu protects the fields below.
+	mu sync.Mutex `state:"nosave"`
+
+	// released is set when the listener is released, after which syscalls
+	// fail with ENOSYS.
+	released bool
+
+	// nextID is the ID of the next notification.
+	nextID uint64
+
+	// notifs are notifications that haven't been responded to yet, in the
+	// order in which they were sent.
+	notifs []*seccompNotif
+}
+
+var _ vfs.FileDescriptionImpl = (*SeccompListener)(nil)
+
+// seccompNotif is a notification of a syscall waiting for the supervisor.
+//
+// +stateify savable
+type seccompNotif struct {
+	// id is the unique ID of the notification.
+	id uint64
+
+	// task is the task executing the syscall.
+	task *Task
+
+	// data is the input of the filter for the syscall.
+	data linux.SeccompData
+
+	// received is true if the supervisor has received the notification with
+	// SECCOMP_IOCTL_NOTIF_RECV.
+	received bool
+
+	// done is closed when the notification is responded to.
+	done chan struct{} `state:"nosave"`
+
+	// val is the return value of the syscall, valid after done is closed.
+	val int64
+
+	// cont is true if the syscall is executed after done is closed.
+	cont bool
+}
+
+// NewSeccompListener returns a new seccomp user notification listener.
+func (k *Kernel) NewSeccompListener(ctx context.Context) (*vfs.FileDescription, *SeccompListener, error) {
+	vd := k.VFS().NewAnonVirtualDentry("seccomp notify")
+	defer vd.DecRef(ctx)
+	l := &SeccompListener{}
+	if err := l.vfsfd.Init(l, linux.O_RDWR, vd.Mount(), vd.Dentry(), &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+		DenyPRead:         true,
+		DenyPWrite:        true,
+	}); err != nil {
+		return nil, nil, err
+	}
+	return &l.vfsfd, l, nil
+}
+
+// notify sends a notification of the syscall of t with the given filter input
+// and blocks until the supervisor responds. It returns the return value of the
+// syscall, or true if the syscall should be executed.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (l *SeccompListener) notify(t *Task, data linux.SeccompData) (int64, bool) {
+	n := &seccompNotif{
+		task: t,
+		data: data,
+		done: make(chan struct{}),
+	}
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		return -int64(linuxerr.ENOSYS.Errno()), false
+	}
+	l.nextID++
+	n.id = l.nextID
+	l.notifs = append(l.notifs, n)
+	l.mu.Unlock()
+	l.queue.Notify(waiter.ReadableEvents)
+
+	err := t.Block(n.done)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-n.done:
+		return n.val, n.cont
+	default:
+	}
+	// Interrupted before the response. Responses to this notification fail
+	// with ENOENT, and checkSeccompSyscall makes the syscall return
+	// ERESTARTSYS, so it's restarted with a new notification unless the
+	// signal handler doesn't have SA_RESTART.
+	l.removeLocked(n)
+	return -int64(ExtractErrno(linuxerr.ConvertIntr(err, linuxerr.ERESTARTSYS), int(data.Nr))), false
+}
+
+// findLocked returns the notification with the given ID.
+//
+// Preconditions: l.mu is locked.
+func (l *SeccompListener) findLocked(id uint64) *seccompNotif {
+	for _, n := range l.notifs {
+		if n.id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// removeLocked removes n from pending notifications.
+//
+// Preconditions: l.mu is locked.
+func (l *SeccompListener) removeLocked(n *seccompNotif) {
+	for i, other := range l.notifs {
+		if other == n {
+			l.notifs = append(l.notifs[:i], l.notifs[i+1:]...)
+			return
+		}
+	}
+}
+
+// respondLocked completes n.
+//
+// Preconditions: l.mu is locked.
+func (l *SeccompListener) respondLocked(n *seccompNotif, val int64, cont bool) {
+	n.val = val
+	n.cont = cont
+	l.removeLocked(n)
+	close(n.done)
+}
+
+// Release implements vfs.FileDescriptionImpl.Release. Waiting syscalls fail
+// with ENOSYS.
+func (l *SeccompListener) Release(context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = true
+	for _, n := range l.notifs {
+		n.val = -int64(linuxerr.ENOSYS.Errno())
+		close(n.done)
+	}
+	l.notifs = nil
+}
+
+// Readiness implements waiter.Waitable.Readiness. The listener is readable if
+// there are notifications to receive, and writable if there are received
+// notifications to respond to.
+func (l *SeccompListener) Readiness(mask waiter.EventMask) waiter.EventMask {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ready waiter.EventMask
+	for _, n := range l.notifs {
+		if n.received {
+			ready |= waiter.WritableEvents
+		} else {
+			ready |= waiter.ReadableEvents
+		}
+	}
+	return mask & ready
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (l *SeccompListener) EventRegister(e *waiter.Entry) error {
+	l.queue.EventRegister(e)
+	return nil
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (l *SeccompListener) EventUnregister(e *waiter.Entry) {
+	l.queue.EventUnregister(e)
+}
+
+// Epollable implements vfs.FileDescriptionImpl.Epollable.
+func (l *SeccompListener) Epollable() bool {
+	return true
+}
+
+// Ioctl implements vfs.FileDescriptionImpl.Ioctl.
+func (l *SeccompListener) Ioctl(ctx context.Context, uio usermem.IO, sysno uintptr, args arch.SyscallArguments) (uintptr, error) {
+	t := TaskFromContext(ctx)
+	if t == nil {
+		return 0, linuxerr.EINVAL
+	}
+	addr := args[2].Pointer()
+	switch cmd := args[1].Uint(); cmd {
+	case linux.SECCOMP_IOCTL_NOTIF_RECV:
+		return 0, l.recv(t, addr)
+	case linux.SECCOMP_IOCTL_NOTIF_SEND:
+		return 0, l.send(t, addr)
+	case linux.SECCOMP_IOCTL_NOTIF_ID_VALID, linux.SECCOMP_IOCTL_NOTIF_ID_VALID_WRONG_DIR:
+		var id primitive.Uint64
+		if _, err := id.CopyIn(t, addr); err != nil {
+			return 0, err
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.findLocked(uint64(id)) == nil {
+			return 0, linuxerr.ENOENT
+		}
+		return 0, nil
+	case linux.SECCOMP_IOCTL_NOTIF_ADDFD:
+		fd, err := l.addFD(t, addr)
+		return uintptr(fd), err
+	default:
+		log.Debugf("Unsupported seccomp listener ioctl: %#x", cmd)
+		return 0, linuxerr.EINVAL
+	}
+}
+
+// recv implements SECCOMP_IOCTL_NOTIF_RECV.
+func (l *SeccompListener) recv(t *Task, addr hostarch.Addr) error {
+	var notif linux.SeccompNotif
+	if _, err := notif.CopyIn(t, addr); err != nil {
+		return err
+	}
+	// The struct must be zeroed, so that it can be extended.
+	if notif != (linux.SeccompNotif{}) {
+		return linuxerr.EINVAL
+	}
+
+	e, ch := waiter.NewChannelEntry(waiter.ReadableEvents)
+	l.EventRegister(&e)
+	defer l.EventUnregister(&e)
+	for {
+		l.mu.Lock()
+		var n *seccompNotif
+		for _, other := range l.notifs {
+			if !other.received {
+				n = other
+				break
+			}
+		}
+		if n == nil {
+			l.mu.Unlock()
+			if err := t.Block(ch); err != nil {
+				return linuxerr.ConvertIntr(err, linuxerr.EINTR)
+			}
+			continue
+		}
+		n.received = true
+		l.mu.Unlock()
+		l.queue.Notify(waiter.WritableEvents)
+
+		notif = linux.SeccompNotif{
+			ID:   n.id,
+			PID:  uint32(t.PIDNamespace().IDOfTask(n.task)),
+			Data: n.data,
+		}
+		if _, err := notif.CopyOut(t, addr); err != nil {
+			// Let the notification be received again.
+			l.mu.Lock()
+			n.received = false
+			l.mu.Unlock()
+			return err
+		}
+		return nil
+	}
+}
+
+// send implements SECCOMP_IOCTL_NOTIF_SEND.
+func (l *SeccompListener) send(t *Task, addr hostarch.Addr) error {
+	var resp linux.SeccompNotifResp
+	if _, err := resp.CopyIn(t, addr); err != nil {
+		return err
+	}
+	if resp.Flags&^linux.SECCOMP_USER_NOTIF_FLAG_CONTINUE != 0 {
+		return linuxerr.EINVAL
+	}
+	cont := resp.Flags&linux.SECCOMP_USER_NOTIF_FLAG_CONTINUE != 0
+	if cont && (resp.Error != 0 || resp.Val != 0) {
+		return linuxerr.EINVAL
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.findLocked(resp.ID)
+	if n == nil {
+		return linuxerr.ENOENT
+	}
+	if !n.received {
+		return linuxerr.EINPROGRESS
+	}
+	// Linux: kernel/seccomp.c:seccomp_do_user_notification() =>
+	// syscall_set_return_value()
+	val := resp.Val
+	if resp.Error != 0 {
+		val = int64(resp.Error)
+	}
+	l.respondLocked(n, val, cont)
+	return nil
+}
+
+// addFD implements SECCOMP_IOCTL_NOTIF_ADDFD. It installs a file of the
+// supervisor into the fd table of the task of the notification.
+func (l *SeccompListener) addFD(t *Task, addr hostarch.Addr) (int32, error) {
+	var addfd linux.SeccompNotifAddfd
+	if _, err := addfd.CopyIn(t, addr); err != nil {
+		return 0, err
+	}
+	if addfd.Flags&^(linux.SECCOMP_ADDFD_FLAG_SETFD|linux.SECCOMP_ADDFD_FLAG_SEND) != 0 {
+		return 0, linuxerr.EINVAL
+	}
+	if addfd.NewfdFlags&^linux.O_CLOEXEC != 0 {
+		return 0, linuxerr.EINVAL
+	}
+	if addfd.Newfd != 0 && addfd.Flags&linux.SECCOMP_ADDFD_FLAG_SETFD == 0 {
+		return 0, linuxerr.EINVAL
+	}
+
+	l.mu.Lock()
+	n := l.findLocked(addfd.ID)
+	if n == nil {
+		l.mu.Unlock()
+		return 0, linuxerr.ENOENT
+	}
+	if !n.received {
+		l.mu.Unlock()
+		return 0, linuxerr.EINPROGRESS
+	}
+	target := n.task
+	l.mu.Unlock()
+
+	file := t.GetFile(int32(addfd.Srcfd))
+	if file == nil {
+		return 0, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+
+	var fdTable *FDTable
+	target.WithMuLocked(func(target *Task) {
+		if fdTable = target.FDTable(); fdTable != nil {
+			fdTable.IncRef()
+		}
+	})
+	if fdTable == nil {
+		return 0, linuxerr.ENOENT
+	}
+	defer fdTable.DecRef(t)
+
+	flags := FDFlags{CloseOnExec: addfd.NewfdFlags&linux.O_CLOEXEC != 0}
+	var fd int32
+	if addfd.Flags&linux.SECCOMP_ADDFD_FLAG_SETFD != 0 {
+		fd = int32(addfd.Newfd)
+		// Limits of the target apply to the new fd.
+		df, err := fdTable.NewFDAt(target, fd, file, flags)
+		if err != nil {
+			return 0, err
+		}
+		if df != nil {
+			df.DecRef(t)
+		}
+	} else {
+		var err error
+		if fd, err = fdTable.NewFD(target, 0, file, flags); err != nil {
+			return 0, err
+		}
+	}
+
+	if addfd.Flags&linux.SECCOMP_ADDFD_FLAG_SEND != 0 {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// The syscall may have been interrupted in the meantime, in which
+		// case the fd is installed without a response.
+		if n := l.findLocked(addfd.ID); n != nil {
+			l.respondLocked(n, int64(fd), false)
+		}
+	}
+	return fd, nil
+}
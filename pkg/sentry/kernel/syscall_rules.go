@@ -0,0 +1,655 @@
+package kernel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/abi/linux/errno"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"net"
+	"path"
+	"slices"
+	"strings"
+)
+
+// rulePathArg is the position of path argument of syscall and of its dirfd (-1 if there is no dirfd)
+type rulePathArg struct {
+	path  int
+	dirfd int
+}
+
+// rulePathArgs maps names of syscalls, which accept path, to positions of the path argument
+var rulePathArgs = map[string]rulePathArg{
+	"access":     {0, -1},
+	"chdir":      {0, -1},
+	"chmod":      {0, -1},
+	"chown":      {0, -1},
+	"chroot":     {0, -1},
+	"creat":      {0, -1},
+	"execve":     {0, -1},
+	"execveat":   {1, 0},
+	"faccessat":  {1, 0},
+	"faccessat2": {1, 0},
+	"fchmodat":   {1, 0},
+	"fchownat":   {1, 0},
+	"lchown":     {0, -1},
+	"link":       {0, -1},
+	"linkat":     {1, 0},
+	"lstat":      {0, -1},
+	"mkdir":      {0, -1},
+	"mkdirat":    {1, 0},
+	"mknod":      {0, -1},
+	"mknodat":    {1, 0},
+	"newfstatat": {1, 0},
+	"open":       {0, -1},
+	"openat":     {1, 0},
+	"openat2":    {1, 0},
+	"readlink":   {0, -1},
+	"readlinkat": {1, 0},
+	"rename":     {0, -1},
+	"renameat":   {1, 0},
+	"renameat2":  {1, 0},
+	"rmdir":      {0, -1},
+	"stat":       {0, -1},
+	"statx":      {1, 0},
+	"truncate":   {0, -1},
+	"unlink":     {0, -1},
+	"unlinkat":   {1, 0},
+	"utimensat":  {1, 0},
+}
+
+// ruleNoFollowSyscalls don't follow the final symlink of the path argument, so their path
+// is matched with symlinks resolved only in the parent directory
+var ruleNoFollowSyscalls = map[string]struct{}{
+	"lchown":     {},
+	"link":       {},
+	"linkat":     {},
+	"lstat":      {},
+	"mkdir":      {},
+	"mkdirat":    {},
+	"mknod":      {},
+	"mknodat":    {},
+	"readlink":   {},
+	"readlinkat": {},
+	"rename":     {},
+	"renameat":   {},
+	"renameat2":  {},
+	"rmdir":      {},
+	"unlink":     {},
+	"unlinkat":   {},
+}
+
+// ruleFlagsArg is the position of flags argument of syscall and the flag which disables
+// following of the final symlink
+type ruleFlagsArg struct {
+	flags    int
+	nofollow uint32
+}
+
+// ruleFlagsArgs maps names of syscalls, which follow the final symlink unless a flag is set,
+// to positions of their flags
+var ruleFlagsArgs = map[string]ruleFlagsArg{
+	"open":       {1, linux.O_NOFOLLOW},
+	"openat":     {2, linux.O_NOFOLLOW},
+	"execveat":   {4, linux.AT_SYMLINK_NOFOLLOW},
+	"faccessat2": {3, linux.AT_SYMLINK_NOFOLLOW},
+	"fchownat":   {4, linux.AT_SYMLINK_NOFOLLOW},
+	"newfstatat": {3, linux.AT_SYMLINK_NOFOLLOW},
+	"statx":      {2, linux.AT_SYMLINK_NOFOLLOW},
+	"utimensat":  {3, linux.AT_SYMLINK_NOFOLLOW},
+}
+
+// ruleSockaddrArg is the position of sockaddr argument of syscall and of its length
+type ruleSockaddrArg struct {
+	addr    int
+	addrlen int
+}
+
+// ruleSockaddrArgs maps names of syscalls, which accept sockaddr, to positions of the sockaddr argument
+var ruleSockaddrArgs = map[string]ruleSockaddrArg{
+	"bind":    {1, 2},
+	"connect": {1, 2},
+	"sendto":  {4, 5},
+}
+
+// rulePathHint names the page holding rewritten paths of the task in /proc/[pid]/maps
+const rulePathHint = "[gvisor_rules:%d]"
+
+// ruleSyscall is the syscall checked by rules. Path, sockaddr and executable
+// are decoded lazily, only if some rule needs them, and at most once
+type ruleSyscall struct {
+	t     *Task
+	sysno uintptr
+	args  *arch.SyscallArguments
+
+	// pathName is the path argument as read from memory, path is the decoded one
+	pathDecoded bool
+	pathName    string
+	path        string
+	pathOk      bool
+
+	// pathResolved is true if path is resolved by the filesystem, rather than only cleaned
+	pathResolved bool
+
+	sockaddrDecoded bool
+	sockaddr        SockaddrDto
+	sockaddrOk      bool
+
+	exeDecoded bool
+	exe        string
+}
+
+func (s *ruleSyscall) pathArg() (rulePathArg, bool) {
+	arg, ok := rulePathArgs[s.t.SyscallTable().LookupName(s.sysno)]
+	return arg, ok
+}
+
+// decodedPath returns the path argument made absolute with cwd or dirfd. Symlinks are resolved
+// like by the syscall, so the path names the file the syscall works with. If the path can't
+// be resolved (e.g. a directory isn't searchable), it's only cleaned
+func (s *ruleSyscall) decodedPath() (string, bool) {
+	if s.pathDecoded {
+		return s.path, s.pathOk
+	}
+	s.pathDecoded = true
+
+	arg, ok := s.pathArg()
+	if !ok {
+		return "", false
+	}
+	name, err := ReadString(s.t, s.args[arg.path].Value, linux.PATH_MAX)
+	if err != nil {
+		return "", false
+	}
+
+	s.pathName = name
+	if resolved, ok := s.resolvedPath(arg, name); ok {
+		s.path, s.pathOk, s.pathResolved = resolved, true, true
+		return s.path, s.pathOk
+	}
+
+	if !path.IsAbs(name) {
+		dir := WorkingDirectory(s.t)
+		if arg.dirfd >= 0 && s.args[arg.dirfd].Int() != linux.AT_FDCWD {
+			if dir, ok = FilePath(s.t, s.args[arg.dirfd].Int()); !ok {
+				return "", false
+			}
+		}
+		name = path.Join(dir, name)
+	}
+
+	s.path, s.pathOk = path.Clean(name), true
+	return s.path, s.pathOk
+}
+
+// follows returns true if the syscall follows the final symlink of its path
+func (s *ruleSyscall) follows() bool {
+	name := s.t.SyscallTable().LookupName(s.sysno)
+	if _, ok := ruleNoFollowSyscalls[name]; ok {
+		return false
+	}
+	if arg, ok := ruleFlagsArgs[name]; ok {
+		flags := s.args[arg.flags].Uint()
+		if flags&arg.nofollow != 0 {
+			return false
+		}
+		// Final symlink isn't followed when file is created exclusively
+		if (name == "open" || name == "openat") && flags&(linux.O_CREAT|linux.O_EXCL) == linux.O_CREAT|linux.O_EXCL {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvedPath resolves name relative to cwd or dirfd of the syscall
+func (s *ruleSyscall) resolvedPath(arg rulePathArg, name string) (string, bool) {
+	// Empty path (AT_EMPTY_PATH) refers to dirfd itself, it's cleaned like before.
+	if name == "" {
+		return "", false
+	}
+	fsContext := s.t.FSContext()
+	if fsContext == nil {
+		return "", false
+	}
+	root := fsContext.RootDirectory()
+	defer root.DecRef(s.t)
+
+	var start vfs.VirtualDentry
+	if arg.dirfd >= 0 && s.args[arg.dirfd].Int() != linux.AT_FDCWD && !path.IsAbs(name) {
+		file := s.t.GetFile(s.args[arg.dirfd].Int())
+		if file == nil {
+			return "", false
+		}
+		defer file.DecRef(s.t)
+		start = file.VirtualDentry()
+	} else {
+		start = fsContext.WorkingDirectory()
+		defer start.DecRef(s.t)
+	}
+
+	return resolveRulePath(s.t, s.t.Kernel().VFS(), s.t.Credentials(), root, start, name, s.follows())
+}
+
+// resolveRulePath resolves name relative to start in the filesystem of root and returns the
+// absolute path of the file. The final symlink is followed only if follow is true. If the file
+// doesn't exist (e.g. it's created by the syscall), only its parent directory is resolved.
+// Deleted files (e.g. opened by /proc/[pid]/fd links) and files without path aren't resolved
+func resolveRulePath(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials,
+	root, start vfs.VirtualDentry, name string, follow bool) (string, bool) {
+
+	pop := &vfs.PathOperation{Root: root, Start: start, Path: fspath.Parse(name), FollowFinalSymlink: follow}
+	vd, err := vfsObj.GetDentryAt(ctx, creds, pop, &vfs.GetDentryOptions{})
+	if err == nil {
+		defer vd.DecRef(ctx)
+		return rulePathname(ctx, vfsObj, root, vd)
+	}
+	if !linuxerr.Equals(linuxerr.ENOENT, err) {
+		return "", false
+	}
+
+	dir, base := path.Split(strings.TrimRight(name, "/"))
+	if base == "" || base == "." || base == ".." {
+		return "", false
+	}
+	if dir == "" {
+		dir = "."
+	}
+	pop = &vfs.PathOperation{Root: root, Start: start, Path: fspath.Parse(dir), FollowFinalSymlink: true}
+	vd, err = vfsObj.GetDentryAt(ctx, creds, pop, &vfs.GetDentryOptions{CheckSearchable: true})
+	if err != nil {
+		return "", false
+	}
+	defer vd.DecRef(ctx)
+	resolvedDir, ok := rulePathname(ctx, vfsObj, root, vd)
+	if !ok {
+		return "", false
+	}
+	return path.Join(resolvedDir, base), true
+}
+
+// rulePathname returns the absolute path of vd, if vd isn't deleted
+func rulePathname(ctx context.Context, vfsObj *vfs.VirtualFilesystem, root, vd vfs.VirtualDentry) (string, bool) {
+	if vd.Dentry().IsDead() {
+		return "", false
+	}
+	name, err := vfsObj.PathnameWithDeleted(ctx, root, vd)
+	if err != nil || !path.IsAbs(name) {
+		return "", false
+	}
+	return name, true
+}
+
+func (s *ruleSyscall) decodedSockaddr() (SockaddrDto, bool) {
+	if s.sockaddrDecoded {
+		return s.sockaddr, s.sockaddrOk
+	}
+	s.sockaddrDecoded = true
+
+	arg, ok := ruleSockaddrArgs[s.t.SyscallTable().LookupName(s.sysno)]
+	if !ok {
+		return SockaddrDto{}, false
+	}
+	sockaddr, err := ReadSockaddr(s.t, s.args[arg.addr].Value, int(s.args[arg.addrlen].Int()))
+	if err != nil {
+		return SockaddrDto{}, false
+	}
+
+	s.sockaddr, s.sockaddrOk = sockaddr, true
+	return s.sockaddr, s.sockaddrOk
+}
+
+func (s *ruleSyscall) executable() string {
+	if !s.exeDecoded {
+		s.exeDecoded = true
+		s.exe = ExecutablePath(s.t)
+	}
+	return s.exe
+}
+
+// ruleMatcher is compiled matcher of the rule
+type ruleMatcher func(s *ruleSyscall) bool
+
+// syscallRule is compiled callbacks.SyscallRuleDto
+type syscallRule struct {
+	name     string
+	matchers []ruleMatcher
+	action   string
+	errno    uintptr
+	newPath  string
+}
+
+func (r *syscallRule) matches(s *ruleSyscall) bool {
+	for _, matcher := range r.matchers {
+		if !matcher(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// SyscallRules is the compiled list of rules indexed by syscall number
+type SyscallRules struct {
+	bySysno map[uintptr][]*syscallRule
+
+	// anySysno are rules without sysno, they are also included into every list of bySysno
+	anySysno []*syscallRule
+}
+
+func (rules *SyscallRules) forSysno(sysno uintptr) []*syscallRule {
+	if list, ok := rules.bySysno[sysno]; ok {
+		return list
+	}
+	return rules.anySysno
+}
+
+// CompileSyscallRules validates rules and compiles them into Go predicates
+func CompileSyscallRules(dtos []callbacks.SyscallRuleDto) (*SyscallRules, error) {
+	rules := &SyscallRules{bySysno: make(map[uintptr][]*syscallRule)}
+
+	// Rules are evaluated in the order of config, so lists are created before any rule is added
+	for _, dto := range dtos {
+		for _, sysno := range dto.Sysno {
+			rules.bySysno[uintptr(sysno)] = nil
+		}
+	}
+
+	for i, dto := range dtos {
+		rule, err := compileSyscallRule(dto)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		if rule.name == "" {
+			rule.name = fmt.Sprintf("rule-%d", i)
+		}
+
+		if len(dto.Sysno) == 0 {
+			rules.anySysno = append(rules.anySysno, rule)
+			for sysno := range rules.bySysno {
+				rules.bySysno[sysno] = append(rules.bySysno[sysno], rule)
+			}
+			continue
+		}
+		for _, sysno := range dto.Sysno {
+			if !slices.Contains(rules.bySysno[uintptr(sysno)], rule) {
+				rules.bySysno[uintptr(sysno)] = append(rules.bySysno[uintptr(sysno)], rule)
+			}
+		}
+	}
+	return rules, nil
+}
+
+func compileSyscallRule(dto callbacks.SyscallRuleDto) (*syscallRule, error) {
+	rule := &syscallRule{name: dto.Name, action: dto.Action}
+
+	for _, sysno := range dto.Sysno {
+		if sysno < 0 {
+			return nil, fmt.Errorf("invalid sysno %d", sysno)
+		}
+	}
+
+	if dto.Path != "" {
+		if _, err := path.Match(dto.Path, ""); err != nil {
+			return nil, fmt.Errorf("invalid path glob %q: %w", dto.Path, err)
+		}
+		pattern := dto.Path
+		rule.matchers = append(rule.matchers, func(s *ruleSyscall) bool {
+			name, ok := s.decodedPath()
+			if !ok {
+				return false
+			}
+			matched, _ := path.Match(pattern, name)
+			return matched
+		})
+	}
+
+	if dto.Addr != "" {
+		network, err := parseRuleNetwork(dto.Addr)
+		if err != nil {
+			return nil, err
+		}
+		rule.matchers = append(rule.matchers, func(s *ruleSyscall) bool {
+			sockaddr, ok := s.decodedSockaddr()
+			if !ok {
+				return false
+			}
+			ip := net.ParseIP(sockaddr.Addr)
+			return ip != nil && network.Contains(ip)
+		})
+	}
+
+	if dto.Port != 0 {
+		if dto.Port < 0 || dto.Port > 0xffff {
+			return nil, fmt.Errorf("invalid port %d", dto.Port)
+		}
+		port := uint16(dto.Port)
+		rule.matchers = append(rule.matchers, func(s *ruleSyscall) bool {
+			sockaddr, ok := s.decodedSockaddr()
+			return ok && (sockaddr.Family == linux.AF_INET || sockaddr.Family == linux.AF_INET6) && sockaddr.Port == port
+		})
+	}
+
+	if dto.UID != nil {
+		uid := *dto.UID
+		rule.matchers = append(rule.matchers, func(s *ruleSyscall) bool {
+			return uint32(s.t.Credentials().EffectiveKUID.In(s.t.UserNamespace()).OrOverflow()) == uid
+		})
+	}
+
+	if dto.Exe != "" {
+		if _, err := path.Match(dto.Exe, ""); err != nil {
+			return nil, fmt.Errorf("invalid exe glob %q: %w", dto.Exe, err)
+		}
+		pattern := dto.Exe
+		rule.matchers = append(rule.matchers, func(s *ruleSyscall) bool {
+			matched, _ := path.Match(pattern, s.executable())
+			return matched
+		})
+	}
+
+	switch dto.Action {
+	case callbacks.RuleActionAllow, callbacks.RuleActionLog:
+	case callbacks.RuleActionDeny:
+		if dto.Errno < 0 || dto.Errno > int(errno.EHWPOISON) {
+			return nil, fmt.Errorf("invalid errno %d", dto.Errno)
+		}
+		rule.errno = uintptr(dto.Errno)
+		if rule.errno == 0 {
+			rule.errno = uintptr(errno.EPERM)
+		}
+	case callbacks.RuleActionRewritePath:
+		if dto.RewritePath == "" || strings.IndexByte(dto.RewritePath, 0) >= 0 || len(dto.RewritePath) >= linux.PATH_MAX {
+			return nil, errors.New("rewrite-path should be non-empty path")
+		}
+		rule.newPath = dto.RewritePath
+		// Only syscalls with known path argument can be rewritten
+		rule.matchers = append(rule.matchers, func(s *ruleSyscall) bool {
+			_, ok := s.pathArg()
+			return ok
+		})
+	default:
+		return nil, fmt.Errorf("unknown action %q", dto.Action)
+	}
+
+	return rule, nil
+}
+
+// parseRuleNetwork parses CIDR or single IP address
+func parseRuleNetwork(addr string) (*net.IPNet, error) {
+	if strings.Contains(addr, "/") {
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid addr %q: %w", addr, err)
+		}
+		return network, nil
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid addr %q", addr)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// applySyscallRules evaluates rules for the syscall. Returns new args if the path was
+// rewritten and return value if the syscall is denied.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) applySyscallRules(rules *SyscallRules, sysno uintptr,
+	args *arch.SyscallArguments) (*arch.SyscallArguments, *SyscallReturnValue) {
+
+	list := rules.forSysno(sysno)
+	if len(list) == 0 {
+		return args, nil
+	}
+
+	s := &ruleSyscall{t: t, sysno: sysno, args: args}
+	for _, rule := range list {
+		if !rule.matches(s) {
+			continue
+		}
+
+		switch rule.action {
+		case callbacks.RuleActionAllow:
+			return t.pinSyscallPath(s), nil
+
+		case callbacks.RuleActionDeny:
+			t.logSyscallRule(rule, s)
+			return args, &SyscallReturnValue{errno: rule.errno}
+
+		case callbacks.RuleActionLog:
+			t.logSyscallRule(rule, s)
+
+		case callbacks.RuleActionRewritePath:
+			newArgs, err := t.rewriteSyscallPath(s, rule.newPath)
+			if err != nil {
+				t.Debugf("{\"rule\": %q, \"error\": %q}", rule.name, err.Error())
+				return args, nil
+			}
+			t.logSyscallRule(rule, s)
+			return newArgs, nil
+		}
+	}
+	return t.pinSyscallPath(s), nil
+}
+
+// pinSyscallPath returns args with the path argument replaced by the path checked by rules,
+// so the syscall works with the same file even if another thread changes the path in its
+// memory. The path is pinned only if it was resolved, cleaned paths are kept as is, because
+// cleaning ".." isn't equivalent to its resolution
+func (t *Task) pinSyscallPath(s *ruleSyscall) *arch.SyscallArguments {
+	if !s.pathDecoded || !s.pathResolved || t.SyscallTable().LookupName(s.sysno) == "openat2" {
+		return s.args
+	}
+	pinned := s.path
+	// Trailing slash requires the file to be a directory
+	if strings.HasSuffix(s.pathName, "/") && pinned != "/" {
+		pinned += "/"
+	}
+	newArgs, err := t.rewriteSyscallPath(s, pinned)
+	if err != nil {
+		t.Debugf("{\"rule\": \"pin path\", \"error\": %q}", err.Error())
+		return s.args
+	}
+	return newArgs
+}
+
+// rewriteSyscallPath places newPath in the rule path page of the task and returns args
+// with the path argument pointing to it
+func (t *Task) rewriteSyscallPath(s *ruleSyscall, newPath string) (*arch.SyscallArguments, error) {
+	arg, ok := s.pathArg()
+	if !ok {
+		return nil, errors.New("syscall has no path argument")
+	}
+
+	addr, err := t.rulePathPage()
+	if err != nil {
+		return nil, err
+	}
+	data := append([]byte(newPath), 0)
+	if _, err := t.MemoryManager().CopyOut(t, addr, data, usermem.IOOpts{IgnorePermissions: true}); err != nil {
+		return nil, err
+	}
+
+	newArgs := *s.args
+	newArgs[arg.path].Value = uintptr(addr)
+	return &newArgs, nil
+}
+
+// rulePathPage returns the read-only page mapped by the sentry for paths rewritten by
+// rules. Every task has its own page, so concurrent syscalls of threads don't overwrite
+// each other's paths. The page is mapped again if the task unmapped or replaced it
+func (t *Task) rulePathPage() (hostarch.Addr, error) {
+	mm := t.MemoryManager()
+	hint := fmt.Sprintf(rulePathHint, t.ThreadID())
+	if t.rulePathAddr != 0 {
+		ar := hostarch.AddrRange{Start: t.rulePathAddr, End: t.rulePathAddr + hostarch.PageSize}
+		if start, _, err := mm.FindVMAByName(ar, hint); err == nil && start == t.rulePathAddr {
+			return t.rulePathAddr, nil
+		}
+		t.rulePathAddr = 0
+	}
+
+	addr, err := mm.MMap(t, memmap.MMapOpts{
+		Length:   hostarch.PageSize,
+		Private:  true,
+		Perms:    hostarch.Read,
+		MaxPerms: hostarch.Read,
+		Hint:     hint,
+	})
+	if err != nil {
+		return 0, err
+	}
+	t.rulePathAddr = addr
+	return addr, nil
+}
+
+// releaseRulePathPage unmaps the rule path page of the exiting task
+func (t *Task) releaseRulePathPage() {
+	if t.rulePathAddr == 0 {
+		return
+	}
+	mm := t.MemoryManager()
+	ar := hostarch.AddrRange{Start: t.rulePathAddr, End: t.rulePathAddr + hostarch.PageSize}
+	if start, _, err := mm.FindVMAByName(ar, fmt.Sprintf(rulePathHint, t.ThreadID())); err == nil && start == t.rulePathAddr {
+		mm.MUnmap(t, t.rulePathAddr, hostarch.PageSize)
+	}
+	t.rulePathAddr = 0
+}
+
+// syscallRuleLogRecord is logged by rules with log, deny and rewrite-path actions
+type syscallRuleLogRecord struct {
+	Rule     string       `json:"rule"`
+	Action   string       `json:"action"`
+	Name     string       `json:"name"`
+	Path     string       `json:"path,omitempty"`
+	Sockaddr *SockaddrDto `json:"sockaddr,omitempty"`
+}
+
+func (t *Task) logSyscallRule(rule *syscallRule, s *ruleSyscall) {
+	record := syscallRuleLogRecord{Rule: rule.name, Action: rule.action, Name: t.SyscallTable().LookupName(s.sysno)}
+	if s.pathOk {
+		record.Path = s.path
+	}
+	if s.sockaddrOk {
+		record.Sockaddr = &s.sockaddr
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	t.JSONInfo(string(data))
+}
@@ -0,0 +1,231 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/abi/linux/errno"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/contexttest"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/tmpfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"net"
+	"testing"
+)
+
+func testCreateTaskWithSyscallTable() Task {
+	task := testCreateEmptyTask()
+	task.image.st = &SyscallTable{Table: map[uintptr]Syscall{
+		2: {Name: "open"},
+		3: {Name: "close"},
+	}}
+	return task
+}
+
+func TestCompileSyscallRules_KeepsOrder(t *testing.T) {
+	rules, err := CompileSyscallRules([]callbacks.SyscallRuleDto{
+		{Name: "first", Sysno: []int{2}, Action: callbacks.RuleActionDeny},
+		{Name: "any", Action: callbacks.RuleActionAllow},
+		{Name: "last", Sysno: []int{2, 3}, Action: callbacks.RuleActionLog},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[uintptr][]string{
+		2: {"first", "any", "last"},
+		3: {"any", "last"},
+		4: {"any"},
+	}
+	for sysno, names := range expected {
+		list := rules.forSysno(sysno)
+		if len(list) != len(names) {
+			t.Fatalf("sysno %d: %d rules, but %d expected", sysno, len(list), len(names))
+		}
+		for i, rule := range list {
+			if rule.name != names[i] {
+				t.Fatalf("sysno %d: rule %s at %d, but %s expected", sysno, rule.name, i, names[i])
+			}
+		}
+	}
+}
+
+func TestCompileSyscallRules_Invalid(t *testing.T) {
+	invalid := []callbacks.SyscallRuleDto{
+		{Action: "drop"},
+		{Sysno: []int{-1}, Action: callbacks.RuleActionAllow},
+		{Path: "/etc/[", Action: callbacks.RuleActionDeny},
+		{Exe: "[", Action: callbacks.RuleActionDeny},
+		{Addr: "10.0.0.0/33", Action: callbacks.RuleActionDeny},
+		{Addr: "localhost", Action: callbacks.RuleActionDeny},
+		{Port: 70000, Action: callbacks.RuleActionDeny},
+		{Errno: -1, Action: callbacks.RuleActionDeny},
+		{Action: callbacks.RuleActionRewritePath},
+	}
+	for _, dto := range invalid {
+		if _, err := CompileSyscallRules([]callbacks.SyscallRuleDto{dto}); err == nil {
+			t.Fatalf("no error for invalid rule %+v", dto)
+		}
+	}
+}
+
+func TestParseRuleNetwork(t *testing.T) {
+	for _, test := range []struct {
+		addr     string
+		ip       string
+		contains bool
+	}{
+		{"10.0.0.0/8", "10.1.2.3", true},
+		{"10.0.0.0/8", "11.1.2.3", false},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"127.0.0.1", "127.0.0.2", false},
+		{"::1", "::1", true},
+		{"fd00::/8", "fd12::1", true},
+	} {
+		network, err := parseRuleNetwork(test.addr)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", test.addr, err)
+		}
+		if network.Contains(net.ParseIP(test.ip)) != test.contains {
+			t.Fatalf("%s contains %s should be %v", test.addr, test.ip, test.contains)
+		}
+	}
+}
+
+func TestApplySyscallRules_Deny(t *testing.T) {
+	task := testCreateTaskWithSyscallTable()
+	rules, err := CompileSyscallRules([]callbacks.SyscallRuleDto{
+		{Sysno: []int{3}, Action: callbacks.RuleActionAllow},
+		{Sysno: []int{2, 3}, Action: callbacks.RuleActionDeny, Errno: int(errno.EACCES)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	args := arch.SyscallArguments{}
+	if _, sub := task.applySyscallRules(rules, 2, &args); sub == nil || sub.errno != uintptr(errno.EACCES) {
+		t.Fatalf("syscall isn't denied with EACCES: %v", sub)
+	}
+	if _, sub := task.applySyscallRules(rules, 3, &args); sub != nil {
+		t.Fatalf("allowed syscall is denied: %v", *sub)
+	}
+	if newArgs, sub := task.applySyscallRules(rules, 4, &args); sub != nil || newArgs != &args {
+		t.Fatalf("syscall without rules is changed")
+	}
+}
+
+func TestApplySyscallRules_DeniesWithEPERMByDefault(t *testing.T) {
+	task := testCreateTaskWithSyscallTable()
+	rules, err := CompileSyscallRules([]callbacks.SyscallRuleDto{{Action: callbacks.RuleActionDeny}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	args := arch.SyscallArguments{}
+	if _, sub := task.applySyscallRules(rules, 3, &args); sub == nil || sub.errno != uintptr(errno.EPERM) {
+		t.Fatalf("syscall isn't denied with EPERM: %v", sub)
+	}
+}
+
+// testCreateSymlinkTree creates tmpfs with /etc/shadow, /tmp/link -> /etc/shadow,
+// /tmp/etc -> /etc and /tmp/dangling -> /etc/new in vfsObj
+func testCreateSymlinkTree(t *testing.T, vfsObj *vfs.VirtualFilesystem) (context.Context, *vfs.MountNamespace, vfs.VirtualDentry, vfs.VirtualDentry) {
+	ctx := contexttest.Context(t)
+	creds := auth.CredentialsFromContext(ctx)
+	if err := vfsObj.Init(ctx); err != nil {
+		t.Fatalf("VFS init: %v", err)
+	}
+	vfsObj.MustRegisterFilesystemType("tmpfs", tmpfs.FilesystemType{}, &vfs.RegisterFilesystemTypeOptions{
+		AllowUserMount: true,
+	})
+	mntns, err := vfsObj.NewMountNamespace(ctx, creds, "", "tmpfs", &vfs.MountOptions{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tmpfs root mount: %v", err)
+	}
+	t.Cleanup(func() { mntns.DecRef(ctx) })
+	root := mntns.Root(ctx)
+	t.Cleanup(func() { root.DecRef(ctx) })
+
+	pop := func(name string) *vfs.PathOperation {
+		return &vfs.PathOperation{Root: root, Start: root, Path: fspath.Parse(name)}
+	}
+	for _, dir := range []string{"/etc", "/tmp"} {
+		if err := vfsObj.MkdirAt(ctx, creds, pop(dir), &vfs.MkdirOptions{Mode: 0755}); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	fd, err := vfsObj.OpenAt(ctx, creds, pop("/etc/shadow"), &vfs.OpenOptions{
+		Flags: linux.O_RDWR | linux.O_CREAT | linux.O_EXCL,
+		Mode:  0644,
+	})
+	if err != nil {
+		t.Fatalf("failed to create /etc/shadow: %v", err)
+	}
+	fd.DecRef(ctx)
+	for link, target := range map[string]string{"/tmp/link": "/etc/shadow", "/tmp/etc": "/etc", "/tmp/dangling": "/etc/new"} {
+		if err := vfsObj.SymlinkAt(ctx, creds, pop(link), target); err != nil {
+			t.Fatalf("failed to create %s: %v", link, err)
+		}
+	}
+
+	tmp, err := vfsObj.GetDentryAt(ctx, creds, pop("/tmp"), &vfs.GetDentryOptions{})
+	if err != nil {
+		t.Fatalf("failed to get /tmp: %v", err)
+	}
+	t.Cleanup(func() { tmp.DecRef(ctx) })
+	return ctx, mntns, root, tmp
+}
+
+func TestResolveRulePath_ResolvesSymlinks(t *testing.T) {
+	vfsObj := &vfs.VirtualFilesystem{}
+	ctx, _, root, tmp := testCreateSymlinkTree(t, vfsObj)
+	creds := auth.CredentialsFromContext(ctx)
+
+	for _, test := range []struct {
+		name   string
+		follow bool
+		want   string
+		ok     bool
+	}{
+		{"/tmp/link", true, "/etc/shadow", true},
+		{"/tmp/link", false, "/tmp/link", true},
+		{"link", true, "/etc/shadow", true},
+		{"/tmp/etc/shadow", false, "/etc/shadow", true},
+		{"/tmp/etc/../tmp/link", true, "/etc/shadow", true},
+		{"/tmp/dangling", true, "/tmp/dangling", true},
+		{"etc/new", true, "/etc/new", true},
+		{"/missing/file", true, "", false},
+	} {
+		got, ok := resolveRulePath(ctx, vfsObj, creds, root, tmp, test.name, test.follow)
+		if ok != test.ok || got != test.want {
+			t.Errorf("resolveRulePath(%q, follow=%v) = %q, %v, expected %q, %v",
+				test.name, test.follow, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestSyscallRules_MatchSymlinkTarget(t *testing.T) {
+	vfsObj := &vfs.VirtualFilesystem{}
+	ctx, _, root, tmp := testCreateSymlinkTree(t, vfsObj)
+	creds := auth.CredentialsFromContext(ctx)
+	rules, err := CompileSyscallRules([]callbacks.SyscallRuleDto{
+		{Sysno: []int{2}, Path: "/etc/*", Action: callbacks.RuleActionDeny},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	task := testCreateTaskWithSyscallTable()
+	for _, name := range []string{"/tmp/link", "link", "/tmp/etc/shadow"} {
+		resolved, ok := resolveRulePath(ctx, vfsObj, creds, root, tmp, name, true)
+		if !ok {
+			t.Fatalf("failed to resolve %q", name)
+		}
+		s := &ruleSyscall{t: &task, sysno: 2, pathDecoded: true, pathName: name, path: resolved, pathOk: true}
+		if !rules.forSysno(2)[0].matches(s) {
+			t.Errorf("deny rule for /etc/* doesn't match %q resolved to %q", name, resolved)
+		}
+	}
+}
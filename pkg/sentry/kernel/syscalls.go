@@ -83,6 +83,9 @@ type Syscall struct {
 	// Callback functions must follow this naming convention:
 	//   PointSyscallNameInCamelCase, e.g. PointReadat, PointRtSigaction.
 	PointCallback SyscallToProto
+	// NotImplemented indicates that Fn always fails with ENOSYS, so the
+	// syscall may be emulated by callbacks.
+	NotImplemented bool
 }
 
 // SyscallFn is a syscall implementation.
@@ -442,6 +442,19 @@ type Task struct {
 	// ipcns is protected by mu. ipcns is owned by the task goroutine.
 	ipcns *IPCNamespace
 
+	// timens is the task's time namespace, and timensForChildren is the time
+	// namespace of its future children.
+	//
+	// timens and timensForChildren are protected by mu. They are owned by the
+	// task goroutine.
+	timens            *TimeNamespace
+	timensForChildren *TimeNamespace
+
+	// cgroupns is the task's cgroup namespace.
+	//
+	// cgroupns is protected by mu. cgroupns is owned by the task goroutine.
+	cgroupns *CgroupNamespace
+
 	// mountNamespace is the task's mount namespace.
 	//
 	// It is protected by mu. It is owned by the task goroutine.
@@ -452,6 +465,12 @@ type Task struct {
 	// parentDeathSignal is protected by mu.
 	parentDeathSignal linux.Signal
 
+	// landlock is the Landlock domain enforced on the task. A reference is
+	// held on it if it's not nil.
+	//
+	// landlock is protected by mu. It is owned by the task goroutine.
+	landlock *vfs.LandlockDomain
+
 	// seccomp contains all seccomp-bpf syscall filters applicable to the task.
 	// The type of the atomic is *taskSeccomp.
 	// Writing needs to be protected by the signal mutex. Note that due to
@@ -605,6 +624,30 @@ type Task struct {
 
 	vmFlag callbacks.Flag
 
+	// injectingSyscall is set while syscall invoked from js callback is executed.
+	// Js runtime is locked meanwhile, so nested injected syscalls and virtual files are denied.
+	//
+	// injectingSyscall is exclusive to the task goroutine.
+	injectingSyscall bool
+
+	// skippedCallbacks is the count of invocations of the running callback, which were skipped
+	// due to its sample-rate and max-per-second settings before the current invocation.
+	//
+	// skippedCallbacks is exclusive to the task goroutine.
+	skippedCallbacks uint64
+
+	// approvalRequest is set by callback which deferred the syscall until operator
+	// decides whether it proceeds.
+	//
+	// approvalRequest is exclusive to the task goroutine.
+	approvalRequest *approvalRequest
+
+	// rulePathAddr is the address of the page holding paths rewritten by rules,
+	// 0 if it isn't mapped.
+	//
+	// rulePathAddr is exclusive to the task goroutine.
+	rulePathAddr hostarch.Addr
+
 	taskLocalStorage *goja.Object
 }
 
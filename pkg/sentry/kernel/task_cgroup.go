@@ -225,6 +225,12 @@ type TaskCgroupEntry struct {
 // GetCgroupEntries generates the contents of /proc/<pid>/cgroup as
 // a TaskCgroupEntry array.
 func (t *Task) GetCgroupEntries() []TaskCgroupEntry {
+	return t.getCgroupEntries(nil)
+}
+
+// getCgroupEntries is GetCgroupEntries, with paths relative to cgroupns if it
+// isn't nil.
+func (t *Task) getCgroupEntries(cgroupns *CgroupNamespace) []TaskCgroupEntry {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -246,10 +252,14 @@ func (t *Task) GetCgroupEntries() []TaskCgroupEntry {
 			ctlNames = append(ctlNames, string(ctl.Type()))
 		}
 
+		path := c.Path()
+		if cgroupns != nil {
+			path = cgroupns.Path(c)
+		}
 		cgEntries = append(cgEntries, TaskCgroupEntry{
 			HierarchyID: c.HierarchyID(),
 			Controllers: strings.Join(ctlNames, ","),
-			Path:        c.Path(),
+			Path:        path,
 		})
 	}
 
@@ -257,9 +267,10 @@ func (t *Task) GetCgroupEntries() []TaskCgroupEntry {
 	return cgEntries
 }
 
-// GenerateProcTaskCgroup writes the contents of /proc/<pid>/cgroup for t to buf.
-func (t *Task) GenerateProcTaskCgroup(buf *bytes.Buffer) {
-	cgEntries := t.GetCgroupEntries()
+// GenerateProcTaskCgroup writes the contents of /proc/<pid>/cgroup for t to
+// buf, with paths relative to cgroupns if it isn't nil.
+func (t *Task) GenerateProcTaskCgroup(buf *bytes.Buffer, cgroupns *CgroupNamespace) {
+	cgEntries := t.getCgroupEntries(cgroupns)
 	for _, cgE := range cgEntries {
 		fmt.Fprintf(buf, "%d:%s:%s\n", cgE.HierarchyID, cgE.Controllers, cgE.Path)
 	}
@@ -287,3 +298,22 @@ func (t *Task) ChargeFor(other *Task, ctl CgroupControllerType, res CgroupResour
 	defer t.mu.Unlock()
 	return t.chargeLocked(other, ctl, res, value)
 }
+
+// chargeCgroups charges the cgroup of cgroups with the ctl controller on behalf
+// of target. Returns the cgroup that's charged if any. Returned cgroup has an
+// extra ref that's transferred to the caller.
+func chargeCgroups(cgroups map[Cgroup]struct{}, target *Task, ctl CgroupControllerType, res CgroupResourceType, value int64) (bool, Cgroup, error) {
+	for c := range cgroups {
+		for _, cc := range c.Controllers() {
+			if cc.Type() != ctl {
+				continue
+			}
+			if err := c.Charge(target, c.Dentry, ctl, res, value); err != nil {
+				return false, c, err
+			}
+			c.IncRef()
+			return true, c, nil
+		}
+	}
+	return false, Cgroup{}, nil
+}
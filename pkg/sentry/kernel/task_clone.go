@@ -15,11 +15,14 @@
 package kernel
 
 import (
+	"math"
+
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/atomicbitops"
 	"gvisor.dev/gvisor/pkg/cleanup"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
 	"gvisor.dev/gvisor/pkg/sentry/inet"
@@ -30,14 +33,13 @@ import (
 )
 
 // SupportedCloneFlags is the bitwise OR of all the supported flags for clone.
-// TODO(b/290826530): Implement CLONE_INTO_CGROUP when cgroups v2 is
-// implemented.
 const SupportedCloneFlags = linux.CLONE_VM | linux.CLONE_FS | linux.CLONE_FILES | linux.CLONE_SYSVSEM |
 	linux.CLONE_THREAD | linux.CLONE_SIGHAND | linux.CLONE_CHILD_SETTID | linux.CLONE_NEWPID |
 	linux.CLONE_CHILD_CLEARTID | linux.CLONE_CHILD_SETTID | linux.CLONE_PARENT |
 	linux.CLONE_PARENT_SETTID | linux.CLONE_SETTLS | linux.CLONE_NEWUSER | linux.CLONE_NEWUTS |
 	linux.CLONE_NEWIPC | linux.CLONE_NEWNET | linux.CLONE_PTRACE | linux.CLONE_UNTRACED |
-	linux.CLONE_IO | linux.CLONE_VFORK | linux.CLONE_DETACHED | linux.CLONE_NEWNS
+	linux.CLONE_IO | linux.CLONE_VFORK | linux.CLONE_DETACHED | linux.CLONE_NEWNS |
+	linux.CLONE_PIDFD | linux.CLONE_NEWTIME | linux.CLONE_NEWCGROUP | linux.CLONE_INTO_CGROUP
 
 // Clone implements the clone(2) syscall and returns the thread ID of the new
 // task in t's PID namespace. Clone may return both a non-zero thread ID and a
@@ -83,6 +85,19 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	if args.Flags&(linux.CLONE_FS|linux.CLONE_NEWNS) == linux.CLONE_FS|linux.CLONE_NEWNS {
 		return 0, nil, linuxerr.EINVAL
 	}
+	// pidfds refer to thread groups.
+	if args.Flags&linux.CLONE_PIDFD != 0 && args.Flags&(linux.CLONE_THREAD|linux.CLONE_DETACHED) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if args.Flags&linux.CLONE_INTO_CGROUP != 0 && args.Cgroup > math.MaxInt32 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	// Tasks sharing an address space must be in the same time namespace, so
+	// they can't be created after unshare(CLONE_NEWTIME) until the caller
+	// enters the new time namespace.
+	if args.Flags&(linux.CLONE_THREAD|linux.CLONE_VM) != 0 && t.timens != t.timensForChildren {
+		return 0, nil, linuxerr.EINVAL
+	}
 
 	// Pull task registers and FPU state, a cloned task will inherit the
 	// state of the current task.
@@ -115,7 +130,7 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 			return 0, nil, err
 		}
 	}
-	if args.Flags&(linux.CLONE_NEWPID|linux.CLONE_NEWNET|linux.CLONE_NEWUTS|linux.CLONE_NEWIPC) != 0 && !creds.HasCapabilityIn(linux.CAP_SYS_ADMIN, userns) {
+	if args.Flags&(linux.CLONE_NEWPID|linux.CLONE_NEWNET|linux.CLONE_NEWUTS|linux.CLONE_NEWIPC|linux.CLONE_NEWTIME|linux.CLONE_NEWCGROUP) != 0 && !creds.HasCapabilityIn(linux.CAP_SYS_ADMIN, userns) {
 		return 0, nil, linuxerr.EPERM
 	}
 
@@ -159,6 +174,53 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 		netns.DecRef(t)
 	})
 
+	cgroupns := t.cgroupns
+	if args.Flags&linux.CLONE_NEWCGROUP != 0 {
+		// The new namespace is rooted at the cgroups of t, even if the child
+		// is placed in another cgroup by CLONE_INTO_CGROUP.
+		t.mu.Lock()
+		cgroupns = t.newCgroupNamespaceLocked(userns)
+		t.mu.Unlock()
+	} else {
+		cgroupns.IncRef()
+	}
+	cu.Add(func() {
+		cgroupns.DecRef(t)
+	})
+
+	var initialCgroups map[Cgroup]struct{}
+	if args.Flags&linux.CLONE_INTO_CGROUP != 0 {
+		cg, file, err := t.cgroupFromFD(int32(args.Cgroup))
+		if err != nil {
+			return 0, nil, err
+		}
+		// The file holds a reference on cg until the child enters it.
+		defer file.DecRef(t)
+		initialCgroups = t.cgroupsWith(cg)
+	}
+
+	// The child enters the time namespace for children of t, unless it
+	// shares t's address space, in which case it must share t's time
+	// namespace.
+	timensForChildren := t.timensForChildren
+	if args.Flags&linux.CLONE_NEWTIME != 0 {
+		timensForChildren = timensForChildren.Clone(userns)
+		timensForChildren.SetInode(nsfs.NewInode(t, t.k.nsfsMount, timensForChildren))
+	} else {
+		timensForChildren.IncRef()
+	}
+	cu.Add(func() {
+		timensForChildren.DecRef(t)
+	})
+	timens := timensForChildren
+	if args.Flags&linux.CLONE_VM != 0 {
+		timens = t.timens
+	}
+	timens.IncRef()
+	cu.Add(func() {
+		timens.DecRef(t)
+	})
+
 	// We must hold t.mu to access t.image, but we can't hold it during Fork(),
 	// since TaskImage.Fork()=>mm.Fork() takes mm.addressSpaceMu, which is ordered
 	// above Task.mu. So we copy t.image with t.mu held and call Fork() on the copy.
@@ -173,6 +235,14 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	cu.Add(func() {
 		image.release(t)
 	})
+	if args.Flags&linux.CLONE_VM == 0 {
+		if err := timens.freeze(); err != nil {
+			return 0, nil, err
+		}
+		if err := switchVDSOParamPage(t, image.MemoryManager, t.timens, timens); err != nil {
+			return 0, nil, err
+		}
+	}
 
 	if args.Flags&linux.CLONE_NEWUSER != 0 {
 		// If the task is in a new user namespace, it cannot share keys.
@@ -266,6 +336,10 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 		UserCounters:     uc,
 		SessionKeyring:   sessionKeyring,
 	}
+	cfg.TimeNamespace = timens
+	cfg.TimeNamespaceForChildren = timensForChildren
+	cfg.CgroupNamespace = cgroupns
+	cfg.InitialCgroups = initialCgroups
 	if args.Flags&linux.CLONE_THREAD == 0 {
 		cfg.Parent = t
 	} else {
@@ -311,11 +385,15 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 			// for group signal delivery, had children reparented to it, etc.
 			// Thus we can't just drop it on the floor. Instead, instruct the
 			// task goroutine to exit immediately, as quietly as possible.
-			nt.exitTracerNotified = true
-			nt.exitTracerAcked = true
-			nt.exitParentNotified = true
-			nt.exitParentAcked = true
-			nt.runState = (*runExitMain)(nil)
+			nt.exitQuietly()
+			return 0, nil, err
+		}
+	}
+
+	if args.Flags&linux.CLONE_PIDFD != 0 {
+		if err := t.installClonePIDFD(nt, hostarch.Addr(args.Pidfd)); err != nil {
+			// As above, nt is already visible to the rest of the system.
+			nt.exitQuietly()
 			return 0, nil, err
 		}
 	}
@@ -330,6 +408,11 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	} else {
 		nt.seccomp.Store((*taskSeccomp)(nil))
 	}
+	// Landlock domains are inherited by children as well.
+	t.landlock.IncRef()
+	nt.mu.Lock()
+	nt.landlock = t.landlock
+	nt.mu.Unlock()
 	if args.Flags&linux.CLONE_VFORK != 0 {
 		nt.vforkParent = t
 	}
@@ -366,6 +449,40 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	return ntid, nil, nil
 }
 
+// exitQuietly makes the task goroutine of the new task exit immediately
+// without notifying the parent or the tracer.
+//
+// Preconditions: nt has not been started yet.
+func (nt *Task) exitQuietly() {
+	nt.exitTracerNotified = true
+	nt.exitTracerAcked = true
+	nt.exitParentNotified = true
+	nt.exitParentAcked = true
+	nt.runState = (*runExitMain)(nil)
+}
+
+// installClonePIDFD installs the pidfd referring to the thread group of nt
+// into t's FD table and copies its number to addr.
+func (t *Task) installClonePIDFD(nt *Task, addr hostarch.Addr) error {
+	file, err := t.k.NewPIDFD(t, nt.tg, 0)
+	if err != nil {
+		return err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, FDFlags{CloseOnExec: true})
+	if err != nil {
+		return err
+	}
+	if _, err := primitive.CopyInt32Out(t, addr, fd); err != nil {
+		if file := t.fdTable.Remove(t, fd); file != nil {
+			file.DecRef(t)
+		}
+		return err
+	}
+	return nil
+}
+
 func getCloneSeccheckInfo(t, nt *Task, flags uint64) (seccheck.FieldSet, *pb.CloneInfo) {
 	fields := seccheck.Global.GetFieldSet(seccheck.PointClone)
 	var cwd string
@@ -540,6 +657,52 @@ func (t *Task) Setns(fd *vfs.FileDescription, flags int32) error {
 		t.mu.Unlock()
 		oldNS.DecRef(t)
 		return nil
+	case *CgroupNamespace:
+		if flags != 0 && flags != linux.CLONE_NEWCGROUP {
+			return linuxerr.EINVAL
+		}
+		if !t.HasCapabilityIn(linux.CAP_SYS_ADMIN, ns.UserNamespace()) ||
+			!t.Credentials().HasCapability(linux.CAP_SYS_ADMIN) {
+			return linuxerr.EPERM
+		}
+		oldNS := t.CgroupNamespace()
+		ns.IncRef()
+		t.mu.Lock()
+		t.cgroupns = ns
+		t.mu.Unlock()
+		oldNS.DecRef(t)
+		return nil
+	case *TimeNamespace:
+		if flags != 0 && flags != linux.CLONE_NEWTIME {
+			return linuxerr.EINVAL
+		}
+		// Tasks sharing an address space must be in the same time namespace.
+		t.tg.signalHandlers.mu.Lock()
+		tasksCount := t.tg.tasksCount
+		t.tg.signalHandlers.mu.Unlock()
+		if tasksCount != 1 {
+			return linuxerr.EUSERS
+		}
+		if !t.HasCapabilityIn(linux.CAP_SYS_ADMIN, ns.UserNamespace()) ||
+			!t.Credentials().HasCapability(linux.CAP_SYS_ADMIN) {
+			return linuxerr.EPERM
+		}
+		if err := ns.freeze(); err != nil {
+			return err
+		}
+		if err := switchVDSOParamPage(t, t.MemoryManager(), t.timens, ns); err != nil {
+			return err
+		}
+		ns.IncRef()
+		ns.IncRef()
+		t.mu.Lock()
+		oldNS, oldNSForChildren := t.timens, t.timensForChildren
+		t.timens = ns
+		t.timensForChildren = ns
+		t.mu.Unlock()
+		oldNS.DecRef(t)
+		oldNSForChildren.DecRef(t)
+		return nil
 	default:
 		return linuxerr.EINVAL
 	}
@@ -650,6 +813,25 @@ func (t *Task) Unshare(flags int32) error {
 		t.ipcns.SetInode(nsfs.NewInode(t, t.k.nsfsMount, t.ipcns))
 		cu.Add(func() { oldIPCNS.DecRef(t) })
 	}
+	if flags&linux.CLONE_NEWCGROUP != 0 {
+		if !haveCapSysAdmin {
+			return linuxerr.EPERM
+		}
+		oldCgroupNS := t.cgroupns
+		t.cgroupns = t.newCgroupNamespaceLocked(creds.UserNamespace)
+		cu.Add(func() { oldCgroupNS.DecRef(t) })
+	}
+	if flags&linux.CLONE_NEWTIME != 0 {
+		if !haveCapSysAdmin {
+			return linuxerr.EPERM
+		}
+		// The caller doesn't enter the new time namespace, only its future
+		// children do.
+		oldTimeNS := t.timensForChildren
+		t.timensForChildren = oldTimeNS.Clone(creds.UserNamespace)
+		t.timensForChildren.SetInode(nsfs.NewInode(t, t.k.nsfsMount, t.timensForChildren))
+		cu.Add(func() { oldTimeNS.DecRef(t) })
+	}
 	if flags&linux.CLONE_FILES != 0 {
 		oldFDTable := t.fdTable
 		t.fdTable = oldFDTable.Fork(t, MaxFdLimit)
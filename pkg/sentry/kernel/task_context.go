@@ -97,6 +97,12 @@ func (t *Task) contextValue(key any, isTaskGoroutine bool) any {
 			defer t.mu.Unlock()
 		}
 		return t.fsContext.RootDirectory()
+	case vfs.CtxLandlockDomain:
+		if !isTaskGoroutine {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+		}
+		return t.landlock
 	case vfs.CtxMountNamespace:
 		if !isTaskGoroutine {
 			t.mu.Lock()
@@ -238,6 +238,7 @@ func (*runExitMain) execute(t *Task) taskRunState {
 	lastExiter := t.exitThreadGroup()
 
 	t.ResetKcov()
+	t.releaseRulePathPage()
 
 	// If the task has a cleartid, and the thread group wasn't killed by a
 	// signal, handle that before releasing the MM.
@@ -291,12 +292,24 @@ func (*runExitMain) execute(t *Task) taskRunState {
 	t.utsns = nil
 	ipcns := t.ipcns
 	t.ipcns = nil
+	timens := t.timens
+	t.timens = nil
+	timensForChildren := t.timensForChildren
+	t.timensForChildren = nil
+	cgroupns := t.cgroupns
+	t.cgroupns = nil
 	netns := t.netns
 	t.netns = nil
+	landlock := t.landlock
+	t.landlock = nil
 	t.mu.Unlock()
+	landlock.DecRef(t)
 	mntns.DecRef(t)
 	utsns.DecRef(t)
 	ipcns.DecRef(t)
+	timens.DecRef(t)
+	timensForChildren.DecRef(t)
+	cgroupns.DecRef(t)
 	netns.DecRef(t)
 
 	// If this is the last task to exit from the thread group, release the
@@ -620,6 +633,10 @@ func (t *Task) exitNotifyLocked(fromPtraceDetach bool) {
 	if t.exitState != TaskExitZombie {
 		return
 	}
+	if t == t.tg.leader && t.tg.tasksCount == 1 {
+		// All tasks of the thread group have exited, so pidfds become readable.
+		t.tg.pidfdQueue.Notify(waiter.ReadableEvents)
+	}
 	if !t.exitTracerNotified {
 		t.exitTracerNotified = true
 		tracer := t.Tracer()
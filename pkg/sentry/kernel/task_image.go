@@ -141,6 +141,10 @@ func (k *Kernel) LoadTaskImage(ctx context.Context, args loader.LoadArgs) (*Task
 	m := mm.NewMemoryManager(k, k, k.SleepForAddressSpaceActivation)
 	defer m.DecUsers(ctx)
 	args.MemoryManager = m
+	// Tasks map the VDSO parameter page of their time namespace.
+	if t := TaskFromContext(ctx); t != nil && args.VDSOParamPage == nil {
+		args.VDSOParamPage = t.TimeNamespace().vdsoParamPage()
+	}
 
 	os, ac, name, err := loader.Load(ctx, args, k.extraAuxv, k.vdso)
 	if err != nil {
@@ -0,0 +1,31 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+)
+
+// LandlockDomain returns the Landlock domain enforced on t, which is nil if t
+// isn't restricted by Landlock. No reference is taken on the returned
+// LandlockDomain.
+func (t *Task) LandlockDomain() *vfs.LandlockDomain {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.landlock
+}
+
+// RestrictLandlock enforces the Landlock ruleset rs on t, in addition to the
+// rulesets it's already restricted by. It implements landlock_restrict_self(2).
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) RestrictLandlock(rs *vfs.LandlockRuleset) error {
+	nd, err := t.landlock.Restrict(rs)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	old := t.landlock
+	t.landlock = nd
+	t.mu.Unlock()
+	old.DecRef(t)
+	return nil
+}
@@ -42,26 +42,67 @@ func (t *Task) Infof(fmt string, v ...any) {
 	}
 }
 
-func addLogPrefixToJSON(t *Task, strJSON string, prefix string) string {
-	var info map[string]interface{}
-	err := json.Unmarshal([]byte(strJSON), &info)
+// jsonLogEnvelope is a record sent to the json log. Record holds the logged
+// json value as is (so numbers keep their precision), or the logged string if
+// it isn't valid json.
+type jsonLogEnvelope struct {
+	Container string   `json:"container"`
+	Tid       ThreadID `json:"tid"`
+	Sysno     uintptr  `json:"sysno"`
+	Callback  string   `json:"callback,omitempty"`
+	LogPrefix string   `json:"LogPrefix"`
+	Record    any      `json:"record"`
+}
+
+// wrapJSONLog wraps strJSON logged by t into jsonLogEnvelope. callback is the
+// entry point of the js callback which logs the record, if any.
+func wrapJSONLog(t *Task, callback string, strJSON string) string {
+	var record any = strJSON
+	if json.Valid([]byte(strJSON)) {
+		record = json.RawMessage(strJSON)
+	}
+	envelope := jsonLogEnvelope{
+		Container: t.ContainerID(),
+		Tid:       t.ThreadID(),
+		Sysno:     t.Arch().SyscallNo(),
+		Callback:  callback,
+		LogPrefix: t.logPrefix.Load().(string),
+		Record:    record,
+	}
+	bytes, err := json.Marshal(envelope)
 	if err != nil {
-		return prefix + strJSON
+		return strJSON
 	}
-	info["LogPrefix"] = prefix
-	bytes, _ := json.Marshal(info)
 	return string(bytes)
 }
 
-// JSONInfof logs a formatted info message by calling log.Infof.
-func (t *Task) JSONInfof(fmt string, v ...any) {
+// JSONInfof logs a formatted info message to the json log.
+func (t *Task) JSONInfof(format string, v ...any) {
+	if log.IsLogging(log.Info) {
+		t.jsonInfoAtDepth(2, "", fmt.Sprintf(format, v...))
+	}
+}
+
+// JSONInfo logs the info message as is to the json log, so it may contain '%'.
+func (t *Task) JSONInfo(msg string) {
+	t.jsonInfoAtDepth(2, "", msg)
+}
+
+// JSONCallbackInfo logs the info message of js callback with the given entry
+// point as is to the json log.
+func (t *Task) JSONCallbackInfo(callback string, msg string) {
+	t.jsonInfoAtDepth(2, callback, msg)
+}
+
+// jsonInfoAtDepth wraps msg into jsonLogEnvelope and logs it to the json log.
+// msg is never used as a format, since it contains data of the task.
+func (t *Task) jsonInfoAtDepth(depth int, callback string, msg string) {
 	if log.IsLogging(log.Info) {
-		strJSON := addLogPrefixToJSON(t, fmt, t.logPrefix.Load().(string))
 		logger := log.JSONLog()
 		if logger == nil {
 			return
 		}
-		logger.InfofAtDepth(1, strJSON, v...)
+		logger.InfofAtDepth(depth, "%s", wrapJSONLog(t, callback, msg))
 	}
 }
 
@@ -73,26 +114,26 @@ func (t *Task) Warningf(fmt string, v ...any) {
 }
 
 // JSONWarningf logs a warning string by calling log.Warningf.
-func (t *Task) JSONWarningf(fmt string, v ...any) {
+func (t *Task) JSONWarningf(format string, v ...any) {
 	if log.IsLogging(log.Warning) {
-		strJSON := addLogPrefixToJSON(t, fmt, t.logPrefix.Load().(string))
 		logger := log.JSONLog()
 		if logger == nil {
 			return
 		}
-		logger.WarningfAtDepth(1, strJSON, v...)
+		strJSON := wrapJSONLog(t, "", fmt.Sprintf(format, v...))
+		logger.WarningfAtDepth(1, "%s", strJSON)
 	}
 }
 
 // JSONDebugf creates a debug string that includes the task ID.
-func (t *Task) JSONDebugf(fmt string, v ...any) {
+func (t *Task) JSONDebugf(format string, v ...any) {
 	if log.IsLogging(log.Debug) {
-		strJSON := addLogPrefixToJSON(t, fmt, t.logPrefix.Load().(string))
 		logger := log.JSONLog()
 		if logger == nil {
 			return
 		}
-		logger.DebugfAtDepth(1, strJSON, v...)
+		strJSON := wrapJSONLog(t, "", fmt.Sprintf(format, v...))
+		logger.DebugfAtDepth(1, "%s", strJSON)
 	}
 }
 
@@ -84,6 +84,16 @@ type TaskConfig struct {
 	// MountNamespace is the MountNamespace of the new task.
 	MountNamespace *vfs.MountNamespace
 
+	// TimeNamespace is the TimeNamespace of the new task.
+	TimeNamespace *TimeNamespace
+
+	// TimeNamespaceForChildren is the TimeNamespace of the children of the
+	// new task.
+	TimeNamespaceForChildren *TimeNamespace
+
+	// CgroupNamespace is the CgroupNamespace of the new task.
+	CgroupNamespace *CgroupNamespace
+
 	// RSeqAddr is a pointer to the the userspace linux.RSeq structure.
 	RSeqAddr hostarch.Addr
 
@@ -119,6 +129,9 @@ func (ts *TaskSet) NewTask(ctx context.Context, cfg *TaskConfig) (*Task, error)
 		cfg.FDTable.DecRef(ctx)
 		cfg.UTSNamespace.DecRef(ctx)
 		cfg.IPCNamespace.DecRef(ctx)
+		cfg.TimeNamespace.DecRef(ctx)
+		cfg.TimeNamespaceForChildren.DecRef(ctx)
+		cfg.CgroupNamespace.DecRef(ctx)
 		cfg.NetworkNamespace.DecRef(ctx)
 		if cfg.MountNamespace != nil {
 			cfg.MountNamespace.DecRef(ctx)
@@ -149,29 +162,32 @@ func (ts *TaskSet) newTask(ctx context.Context, cfg *TaskConfig) (*Task, error)
 			parent:   cfg.Parent,
 			children: make(map[*Task]struct{}),
 		},
-		runState:       (*runApp)(nil),
-		interruptChan:  make(chan struct{}, 1),
-		signalMask:     atomicbitops.FromUint64(uint64(cfg.SignalMask)),
-		signalStack:    linux.SignalStack{Flags: linux.SS_DISABLE},
-		image:          *image,
-		fsContext:      cfg.FSContext,
-		fdTable:        cfg.FDTable,
-		k:              cfg.Kernel,
-		ptraceTracees:  make(map[*Task]struct{}),
-		allowedCPUMask: cfg.AllowedCPUMask.Copy(),
-		ioUsage:        &usage.IO{},
-		niceness:       cfg.Niceness,
-		utsns:          cfg.UTSNamespace,
-		ipcns:          cfg.IPCNamespace,
-		mountNamespace: cfg.MountNamespace,
-		rseqCPU:        -1,
-		rseqAddr:       cfg.RSeqAddr,
-		rseqSignature:  cfg.RSeqSignature,
-		futexWaiter:    futex.NewWaiter(),
-		containerID:    cfg.ContainerID,
-		cgroups:        make(map[Cgroup]struct{}),
-		userCounters:   cfg.UserCounters,
-		sessionKeyring: cfg.SessionKeyring,
+		runState:          (*runApp)(nil),
+		interruptChan:     make(chan struct{}, 1),
+		signalMask:        atomicbitops.FromUint64(uint64(cfg.SignalMask)),
+		signalStack:       linux.SignalStack{Flags: linux.SS_DISABLE},
+		image:             *image,
+		fsContext:         cfg.FSContext,
+		fdTable:           cfg.FDTable,
+		k:                 cfg.Kernel,
+		ptraceTracees:     make(map[*Task]struct{}),
+		allowedCPUMask:    cfg.AllowedCPUMask.Copy(),
+		ioUsage:           &usage.IO{},
+		niceness:          cfg.Niceness,
+		utsns:             cfg.UTSNamespace,
+		ipcns:             cfg.IPCNamespace,
+		mountNamespace:    cfg.MountNamespace,
+		timens:            cfg.TimeNamespace,
+		timensForChildren: cfg.TimeNamespaceForChildren,
+		cgroupns:          cfg.CgroupNamespace,
+		rseqCPU:           -1,
+		rseqAddr:          cfg.RSeqAddr,
+		rseqSignature:     cfg.RSeqSignature,
+		futexWaiter:       futex.NewWaiter(),
+		containerID:       cfg.ContainerID,
+		cgroups:           make(map[Cgroup]struct{}),
+		userCounters:      cfg.UserCounters,
+		sessionKeyring:    cfg.SessionKeyring,
 	}
 	t.netns = cfg.NetworkNamespace
 	t.creds.Store(cfg.Credentials)
@@ -195,7 +211,14 @@ func (ts *TaskSet) newTask(ctx context.Context, cfg *TaskConfig) (*Task, error)
 	// bypasses pid limits.
 	if srcT != nil {
 		var err error
-		if charged, cg, err = srcT.ChargeFor(t, CgroupControllerPIDs, CgroupResourcePID, 1); err != nil {
+		if cfg.InitialCgroups != nil {
+			// The new task doesn't enter srcT's cgroups (CLONE_INTO_CGROUP),
+			// so charge the cgroups it enters instead.
+			charged, cg, err = chargeCgroups(cfg.InitialCgroups, t, CgroupControllerPIDs, CgroupResourcePID, 1)
+		} else {
+			charged, cg, err = srcT.ChargeFor(t, CgroupControllerPIDs, CgroupResourcePID, 1)
+		}
+		if err != nil {
 			return nil, err
 		}
 		if charged {
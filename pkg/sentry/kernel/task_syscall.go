@@ -140,26 +140,61 @@ func (t *Task) executeSyscall(sysno uintptr, args arch.SyscallArguments) (rval u
 
 		args_ := &args
 		var sub_ *SyscallReturnValue = nil
+
+		// Declarative rules are evaluated natively, denied syscalls don't reach callbacks.
+		if rules := GetJsRuntime().syscallRules.Load(); rules != nil {
+			args_, sub_ = t.applySyscallRules(rules, sysno, args_)
+		}
+
 		ct := GetJsRuntime().callbackTable
 		callbackBefore := ct.getCallbackBefore(sysno)
-		if callbackBefore != nil {
-			retArgs, retSub, err := callbackBefore.CallbackBeforeFunc(t, sysno, &args)
+		if callbackBefore != nil && sub_ == nil {
+			// The callback gets arguments modified by rules and changes them further
+			retArgs, retSub, err := callbackBefore.CallbackBeforeFunc(t, sysno, args_)
+			t.emitJsCallbackPoint(callbackBefore, sysno, args_, retArgs, retSub, err)
 			if err != nil {
 				fmt.Println(err)
+				t.approvalRequest = nil
 			} else {
 				args_ = retArgs
 				sub_ = retSub
 			}
 		}
 
-		if sub_ != nil {
+		// The callback may defer the syscall until operator decides whether it proceeds.
+		var approvalErr error
+		if t.approvalRequest != nil && sub_ == nil {
+			args_, sub_, approvalErr = t.waitForApproval(sysno, args_)
+		}
+		t.approvalRequest = nil
+
+		if approvalErr != nil {
+			err = approvalErr
+		} else if sub_ != nil {
 			rval = sub_.returnValue
 			err = linuxerr.ErrorFromUnix(syscall.Errno(sub_.errno))
 		} else {
-			if fn != nil {
+			var emulated bool
+			if fn == nil || s.Table[sysno].NotImplemented {
+				// Syscalls without implementation (missing or failing with ENOSYS) may be
+				// emulated by callbacks.
+				if callbackEmulate := ct.getCallbackEmulate(sysno); callbackEmulate != nil {
+					emulatedSub, error_ := callbackEmulate.CallbackEmulateFunc(t, sysno, args_)
+					t.emitJsCallbackPoint(callbackEmulate, sysno, args_, nil, emulatedSub, error_)
+					if error_ != nil {
+						t.Debugf("{\"callbackEmulate\": \"%v\"}", error_.Error())
+					} else if emulatedSub != nil {
+						emulated = true
+						rval = emulatedSub.returnValue
+						err = linuxerr.ErrorFromUnix(syscall.Errno(emulatedSub.errno))
+					}
+				}
+			}
+
+			if !emulated && fn != nil {
 				// Call our syscall implementation.
 				rval, ctrl, err = fn(t, sysno, *args_)
-			} else {
+			} else if !emulated {
 				// Use the missing function if not found.
 				rval, err = t.SyscallTable().Missing(t, sysno, *args_)
 			}
@@ -168,7 +203,9 @@ func (t *Task) executeSyscall(sysno uintptr, args arch.SyscallArguments) (rval u
 			if callbackAfter != nil {
 				var newArgs *arch.SyscallArguments
 				var error_ error
-				newArgs, sub_, error_ = callbackAfter.CallbackAfterFunc(t, sysno, &args, rval, err)
+				// Seccheck event and the callback see the arguments the syscall was executed with
+				newArgs, sub_, error_ = callbackAfter.CallbackAfterFunc(t, sysno, args_, rval, err)
+				t.emitJsCallbackPoint(callbackAfter, sysno, args_, newArgs, sub_, error_)
 				if error_ != nil {
 					t.Debugf("{\"callbackAfter\": \"%v\"}", error_.Error())
 				} else if sub_ != nil {
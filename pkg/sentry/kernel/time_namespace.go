@@ -0,0 +1,325 @@
+package kernel
+
+import (
+	"time"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	ktime "gvisor.dev/gvisor/pkg/sentry/kernel/time"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+	"gvisor.dev/gvisor/pkg/sentry/usage"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// maxTimeOffsetSeconds is the maximum absolute value of a time namespace
+// offset, and half of it is the maximum value of a clock with the offset
+// applied, in seconds.
+//
+// Linux: KTIME_SEC_MAX
+const maxTimeOffsetSeconds = (1<<63 - 1) / int64(time.Second)
+
+// TimeNamespace represents a time namespace, which holds the offsets of
+// CLOCK_MONOTONIC and CLOCK_BOOTTIME from the clocks of the root time
+// namespace.
+//
+// Offsets can only be changed until a task enters the namespace, at which
+// point the namespace is frozen.
+//
+// +stateify savable
+type TimeNamespace struct {
+	// k is the owning kernel. k is immutable.
+	k *Kernel
+
+	// userns is the user namespace associated with the TimeNamespace.
+	// Privileged operations on this TimeNamespace must have appropriate
+	// capabilities in userns.
+	//
+	// userns is immutable.
+	userns *auth.UserNamespace
+
+	// mu protects the fields below.
+	mu sync.Mutex `state:"nosave"`
+
+	// monotonicOffset and boottimeOffset are the offsets of CLOCK_MONOTONIC
+	// and CLOCK_BOOTTIME respectively, in nanoseconds. They are immutable
+	// once frozen is set.
+	monotonicOffset int64
+	boottimeOffset  int64
+
+	// frozen is set when the first task enters the namespace.
+	frozen bool
+
+	// monotonicClock and boottimeClock are the clocks of the namespace. They
+	// are set when the namespace is frozen.
+	monotonicClock ktime.Clock
+	boottimeClock  ktime.Clock
+
+	// paramPage is the VDSO parameter page mapped by tasks in the namespace,
+	// and params manages it. They are nil if the namespace has no offsets, in
+	// which case tasks map the parameter page of the kernel. They are set
+	// when the namespace is frozen.
+	paramPage *mm.SpecialMappable
+	params    *VDSOParamPage
+
+	inode *nsfs.Inode
+}
+
+// TimeOffset is the offset of a clock in a time namespace.
+type TimeOffset struct {
+	// ClockID is linux.CLOCK_MONOTONIC or linux.CLOCK_BOOTTIME.
+	ClockID int32
+
+	// Offset is the offset of the clock.
+	Offset time.Duration
+}
+
+// newTimeNamespace returns a new time namespace without offsets.
+func newTimeNamespace(k *Kernel, userns *auth.UserNamespace) *TimeNamespace {
+	return &TimeNamespace{
+		k:      k,
+		userns: userns,
+	}
+}
+
+// Clone returns a new time namespace with the offsets of ns, associating the
+// given user namespace.
+func (ns *TimeNamespace) Clone(userns *auth.UserNamespace) *TimeNamespace {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return &TimeNamespace{
+		k:               ns.k,
+		userns:          userns,
+		monotonicOffset: ns.monotonicOffset,
+		boottimeOffset:  ns.boottimeOffset,
+	}
+}
+
+// UserNamespace returns the user namespace associated with this time
+// namespace.
+func (ns *TimeNamespace) UserNamespace() *auth.UserNamespace {
+	return ns.userns
+}
+
+// Offsets returns the offsets of CLOCK_MONOTONIC and CLOCK_BOOTTIME in ns.
+func (ns *TimeNamespace) Offsets() (monotonic, boottime time.Duration) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return time.Duration(ns.monotonicOffset), time.Duration(ns.boottimeOffset)
+}
+
+// SetOffsets sets the offsets of the given clocks. Either all offsets are
+// set, or none is.
+func (ns *TimeNamespace) SetOffsets(offsets []TimeOffset) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.frozen {
+		return linuxerr.EACCES
+	}
+	monotonic, boottime := ns.monotonicOffset, ns.boottimeOffset
+	// Linux: kernel/time/namespace.c:proc_timens_set_offset()
+	now := ns.k.MonotonicClock().Now().Nanoseconds()
+	for _, off := range offsets {
+		secs := int64(off.Offset / time.Second)
+		if secs > maxTimeOffsetSeconds || secs < -maxTimeOffsetSeconds {
+			return linuxerr.ERANGE
+		}
+		if t := (now + off.Offset.Nanoseconds()) / int64(time.Second); t < 0 || t > maxTimeOffsetSeconds/2 {
+			return linuxerr.ERANGE
+		}
+		switch off.ClockID {
+		case linux.CLOCK_MONOTONIC:
+			monotonic = off.Offset.Nanoseconds()
+		case linux.CLOCK_BOOTTIME:
+			boottime = off.Offset.Nanoseconds()
+		default:
+			return linuxerr.EINVAL
+		}
+	}
+	ns.monotonicOffset, ns.boottimeOffset = monotonic, boottime
+	return nil
+}
+
+// freeze makes the offsets of ns immutable, and sets up its clocks and VDSO
+// parameter page. It must be called before a task enters ns.
+func (ns *TimeNamespace) freeze() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.frozen {
+		return nil
+	}
+	k := ns.k
+	if ns.monotonicOffset == 0 && ns.boottimeOffset == 0 {
+		ns.monotonicClock = k.MonotonicClock()
+		ns.boottimeClock = k.MonotonicClock()
+		ns.frozen = true
+		return nil
+	}
+
+	fr, err := k.mf.Allocate(hostarch.PageSize, pgalloc.AllocOpts{Kind: usage.System})
+	if err != nil {
+		return err
+	}
+	ns.paramPage = mm.NewSpecialMappable("[vvar]", k, fr)
+	ns.params = NewVDSOParamPage(k, fr)
+	ns.monotonicClock = &offsetClock{Clock: k.MonotonicClock(), offset: time.Duration(ns.monotonicOffset)}
+	ns.boottimeClock = &offsetClock{Clock: k.MonotonicClock(), offset: time.Duration(ns.boottimeOffset)}
+	ns.frozen = true
+	k.timekeeper.addTimeNamespace(ns)
+	return nil
+}
+
+// MonotonicClock returns the CLOCK_MONOTONIC clock of ns.
+//
+// Preconditions: A task has entered ns.
+func (ns *TimeNamespace) MonotonicClock() ktime.Clock {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.monotonicClock
+}
+
+// BoottimeClock returns the CLOCK_BOOTTIME clock of ns.
+//
+// Preconditions: A task has entered ns.
+func (ns *TimeNamespace) BoottimeClock() ktime.Clock {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.boottimeClock
+}
+
+// offsetParams returns the VDSO parameters of ns given those of the root time
+// namespace.
+//
+// Preconditions: ns is frozen.
+func (ns *TimeNamespace) offsetParams(p vdsoParams) vdsoParams {
+	p.monotonicBaseRef += ns.monotonicOffset
+	p.boottimeOffset = ns.boottimeOffset - ns.monotonicOffset
+	return p
+}
+
+// vdsoParamPage returns the VDSO parameter page of tasks in ns, or nil if the
+// kernel has no VDSO.
+//
+// Preconditions: ns is frozen.
+func (ns *TimeNamespace) vdsoParamPage() *mm.SpecialMappable {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.paramPage != nil {
+		return ns.paramPage
+	}
+	if ns.k.vdso == nil {
+		return nil
+	}
+	return ns.k.vdso.ParamPage
+}
+
+// switchVDSOParamPage replaces the VDSO parameter page of from by the one of
+// to in m.
+//
+// Preconditions: from and to are frozen.
+func switchVDSOParamPage(ctx context.Context, m *mm.MemoryManager, from, to *TimeNamespace) error {
+	oldPage, newPage := from.vdsoParamPage(), to.vdsoParamPage()
+	if m == nil || oldPage == newPage {
+		return nil
+	}
+	return m.ReplaceSpecialMappable(ctx, oldPage, newPage)
+}
+
+// Type implements nsfs.Namespace.Type.
+func (ns *TimeNamespace) Type() string {
+	return "time"
+}
+
+// Destroy implements nsfs.Namespace.Destroy.
+func (ns *TimeNamespace) Destroy(ctx context.Context) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.paramPage != nil {
+		ns.k.timekeeper.removeTimeNamespace(ns)
+		ns.paramPage.DecRef(ctx)
+		ns.paramPage = nil
+		ns.params = nil
+	}
+}
+
+// SetInode sets the nsfs `inode` to the time namespace.
+func (ns *TimeNamespace) SetInode(inode *nsfs.Inode) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode = inode
+}
+
+// GetInode returns the nsfs inode associated with the time namespace.
+func (ns *TimeNamespace) GetInode() *nsfs.Inode {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.inode
+}
+
+// IncRef increments the Namespace's refcount.
+func (ns *TimeNamespace) IncRef() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode.IncRef()
+}
+
+// DecRef decrements the namespace's refcount.
+func (ns *TimeNamespace) DecRef(ctx context.Context) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode.DecRef(ctx)
+}
+
+// TimeNamespace returns the task's time namespace.
+func (t *Task) TimeNamespace() *TimeNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timens
+}
+
+// GetTimeNamespace takes a reference on the task time namespace and returns
+// it. It will return nil if the task isn't alive.
+func (t *Task) GetTimeNamespace() *TimeNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timens != nil {
+		t.timens.IncRef()
+	}
+	return t.timens
+}
+
+// GetTimeNamespaceForChildren takes a reference on the time namespace of the
+// task's future children and returns it. It will return nil if the task isn't
+// alive.
+func (t *Task) GetTimeNamespaceForChildren() *TimeNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timensForChildren != nil {
+		t.timensForChildren.IncRef()
+	}
+	return t.timensForChildren
+}
+
+// offsetClock is a ktime.Clock that is offset from another clock by a
+// constant.
+//
+// +stateify savable
+type offsetClock struct {
+	ktime.Clock
+
+	offset time.Duration
+}
+
+// Now implements ktime.Clock.Now.
+func (c *offsetClock) Now() ktime.Time {
+	return c.Clock.Now().Add(c.offset)
+}
+
+// WallTimeUntil implements ktime.Clock.WallTimeUntil.
+func (c *offsetClock) WallTimeUntil(t, now ktime.Time) time.Duration {
+	return c.Clock.WallTimeUntil(t.Add(-c.offset), now.Add(-c.offset))
+}
@@ -83,6 +83,13 @@ type Timekeeper struct {
 	// params manages the parameter page.
 	params *VDSOParamPage
 
+	// timensMu protects timens.
+	timensMu sync.Mutex `state:"nosave"`
+
+	// timens are the time namespaces that have their own parameter pages,
+	// which are updated along with params.
+	timens map[*TimeNamespace]struct{}
+
 	// mu protects destruction with stop and wg.
 	mu sync.Mutex `state:"nosave"`
 
@@ -117,9 +124,12 @@ func (t *Timekeeper) SetClocks(c sentrytime.Clocks) {
 	// Update the params, marking them "not ready", as we may need to
 	// restart calibration on this new machine.
 	if t.restored != nil {
-		if err := t.params.Write(func() vdsoParams {
+		t.timensMu.Lock()
+		err := t.writeParams(func() vdsoParams {
 			return vdsoParams{}
-		}); err != nil {
+		})
+		t.timensMu.Unlock()
+		if err != nil {
 			panic("unable to reset VDSO params: " + err.Error())
 		}
 	}
@@ -230,7 +240,8 @@ func (t *Timekeeper) startUpdater() {
 			// Call Update within a Write block to prevent the VDSO
 			// from using the old params between Update and
 			// Write.
-			if err := t.params.Write(func() vdsoParams {
+			t.timensMu.Lock()
+			err := t.writeParams(func() vdsoParams {
 				monotonicParams, monotonicOk, realtimeParams, realtimeOk := t.clocks.Update()
 
 				var p vdsoParams
@@ -247,7 +258,9 @@ func (t *Timekeeper) startUpdater() {
 					p.realtimeFrequency = realtimeParams.Frequency
 				}
 				return p
-			}); err != nil {
+			})
+			t.timensMu.Unlock()
+			if err != nil {
 				log.Warningf("Unable to update VDSO parameter page: %v", err)
 			}
 
@@ -260,6 +273,58 @@ func (t *Timekeeper) startUpdater() {
 	}()
 }
 
+// writeParams writes the parameters returned by f to the parameter page of the
+// root time namespace, and to those of other time namespaces with their
+// offsets applied. f is called once, while all pages are being written.
+//
+// Preconditions: t.timensMu is locked.
+func (t *Timekeeper) writeParams(f func() vdsoParams) error {
+	nss := make([]*TimeNamespace, 0, len(t.timens))
+	for ns := range t.timens {
+		nss = append(nss, ns)
+	}
+	return writeParamPages(t.params, nss, f)
+}
+
+// writeParamPages writes root, and the pages of nss, with the parameters
+// returned by f. The pages are written in a nested fashion, such that the VDSO
+// can't use the previous parameters on any page after f is called.
+func writeParamPages(root *VDSOParamPage, nss []*TimeNamespace, f func() vdsoParams) error {
+	if len(nss) == 0 {
+		return root.Write(f)
+	}
+	ns := nss[0]
+	var err error
+	if nsErr := ns.params.Write(func() vdsoParams {
+		var p vdsoParams
+		err = writeParamPages(root, nss[1:], func() vdsoParams {
+			p = f()
+			return p
+		})
+		return ns.offsetParams(p)
+	}); nsErr != nil {
+		return nsErr
+	}
+	return err
+}
+
+// addTimeNamespace starts updating the parameter page of ns.
+func (t *Timekeeper) addTimeNamespace(ns *TimeNamespace) {
+	t.timensMu.Lock()
+	defer t.timensMu.Unlock()
+	if t.timens == nil {
+		t.timens = make(map[*TimeNamespace]struct{})
+	}
+	t.timens[ns] = struct{}{}
+}
+
+// removeTimeNamespace stops updating the parameter page of ns.
+func (t *Timekeeper) removeTimeNamespace(ns *TimeNamespace) {
+	t.timensMu.Lock()
+	defer t.timensMu.Unlock()
+	delete(t.timens, ns)
+}
+
 // stopUpdater stops the update goroutine, blocking until it exits.
 //
 // mu must be held.
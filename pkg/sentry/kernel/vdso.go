@@ -39,6 +39,11 @@ type vdsoParams struct {
 	realtimeBaseCycles int64
 	realtimeBaseRef    int64
 	realtimeFrequency  uint64
+
+	// boottimeOffset is the offset of CLOCK_BOOTTIME from CLOCK_MONOTONIC,
+	// which is non-zero in time namespaces with different offsets for the
+	// two clocks.
+	boottimeOffset int64
 }
 
 // VDSOParamPage manages a VDSO parameter page.
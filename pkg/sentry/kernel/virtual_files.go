@@ -0,0 +1,195 @@
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dop251/goja"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"path"
+	"strconv"
+)
+
+const (
+	// VirtualFileJsName is the name of context with path, data and offset of accessed virtual file
+	VirtualFileJsName   = "file"
+	virtualFileJsPath   = "path"
+	virtualFileJsData   = "data"
+	virtualFileJsOffset = "offset"
+
+	// virtualFileJsFunctions is the name of the object with read and write functions of the file
+	virtualFileJsFunctions = "functions"
+	virtualFileJsRead      = "read"
+	virtualFileJsWrite     = "write"
+)
+
+// VirtualFile is a synthetic file mounted into the sandbox filesystem. Its content is generated
+// by js function when the file is read, writes to the file are passed to js handler
+type VirtualFile struct {
+	info    callbacks.VirtualFileDto
+	mode    linux.FileMode
+	granted callbacks.CapabilitySet
+
+	// functions holds read and write functions defined by the source of the file
+	functions *goja.Object
+}
+
+// NewVirtualFile validates dto and returns VirtualFile for it
+func NewVirtualFile(dto callbacks.VirtualFileDto) (*VirtualFile, error) {
+	if !path.IsAbs(dto.Path) || path.Clean(dto.Path) == "/" {
+		return nil, fmt.Errorf("path of virtual file should be absolute path of file: %q", dto.Path)
+	}
+	if dto.Source == "" {
+		return nil, errors.New("js source of virtual file is empty")
+	}
+	if dto.Read == "" {
+		return nil, errors.New("read function of virtual file is empty")
+	}
+
+	mode := linux.FileMode(0444)
+	if dto.Write != "" {
+		mode = 0644
+	}
+	if dto.Mode != "" {
+		perm, err := strconv.ParseUint(dto.Mode, 8, 32)
+		if err != nil || perm&^uint64(linux.PermissionsMask) != 0 {
+			return nil, fmt.Errorf("invalid mode of virtual file: %q", dto.Mode)
+		}
+		mode = linux.FileMode(perm)
+	}
+
+	granted, err := callbacks.ParseCapabilities(dto.Capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	functions, err := evalVirtualFileSource(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	dto.Path = path.Clean(dto.Path)
+	return &VirtualFile{info: dto, mode: mode, granted: granted, functions: functions}, nil
+}
+
+// evalVirtualFileSource runs the source of the file once and returns the object with its read
+// and write functions. The source runs in its own scope, so files may define functions with
+// the same names. Hooks aren't available in the source, only in the functions
+func evalVirtualFileSource(dto callbacks.VirtualFileDto) (*goja.Object, error) {
+	write := "undefined"
+	if dto.Write != "" {
+		write = dto.Write
+	}
+	jsSource := fmt.Sprintf("(function() {\n%s\n;return {%s: %s, %s: %s}\n})()",
+		dto.Source, virtualFileJsRead, dto.Read, virtualFileJsWrite, write)
+
+	runtime := GetJsRuntime()
+	runtime.Mutex.Lock()
+	defer runtime.Mutex.Unlock()
+
+	val, err := runtime.JsVM.RunString(jsSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate source of virtual file %q: %w", dto.Path, err)
+	}
+	functions := val.ToObject(runtime.JsVM)
+	if _, ok := goja.AssertFunction(functions.Get(virtualFileJsRead)); !ok {
+		return nil, fmt.Errorf("read function %q of virtual file isn't a function", dto.Read)
+	}
+	if _, ok := goja.AssertFunction(functions.Get(virtualFileJsWrite)); dto.Write != "" && !ok {
+		return nil, fmt.Errorf("write function %q of virtual file isn't a function", dto.Write)
+	}
+	return functions, nil
+}
+
+// Path returns the absolute path of the file in the sandbox
+func (f *VirtualFile) Path() string {
+	return f.info.Path
+}
+
+// Mode returns the permissions of the file
+func (f *VirtualFile) Mode() linux.FileMode {
+	return f.mode
+}
+
+// Writable returns true if the file has write handler
+func (f *VirtualFile) Writable() bool {
+	return f.info.Write != ""
+}
+
+// virtualFileArg adds argument of read and write functions to the file context
+type virtualFileArg struct {
+	name  string
+	value interface{}
+}
+
+func (arg virtualFileArg) addSelfToContextObject(object *goja.Object) error {
+	return object.Set(arg.name, arg.value)
+}
+
+// errInjectedSyscallVirtualFile is returned when a virtual file is accessed by a syscall invoked
+// from js callback: js runtime is locked by the callback, so functions of the file can't run
+var errInjectedSyscallVirtualFile = errors.New("virtual files can't be accessed from syscalls invoked by callbacks")
+
+// Read calls the read function of the file and returns the content of the file.
+// Null or undefined is the empty file, ArrayBuffer is used as is, other values are converted to string
+func (f *VirtualFile) Read(t *Task) ([]byte, error) {
+	if t.injectingSyscall {
+		return nil, errInjectedSyscallVirtualFile
+	}
+
+	runtime := GetJsRuntime()
+	runtime.Mutex.Lock()
+	defer runtime.Mutex.Unlock()
+
+	builder := ScriptContextsBuilderOf().AddContext2(VirtualFileJsName, []ContextAddable{
+		virtualFileArg{virtualFileJsPath, f.info.Path},
+		&ObjectAddableAdapter{name: virtualFileJsFunctions, object: f.functions},
+	})
+	jsSource := fmt.Sprintf("%s.%s.%s(%s.%s)", VirtualFileJsName, virtualFileJsFunctions, virtualFileJsRead,
+		VirtualFileJsName, virtualFileJsPath)
+
+	val, err := runtime.runTaskScriptLocked(t, nil, f.granted, jsSource, builder)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return nil, nil
+	}
+	if buf, ok := val.Export().(goja.ArrayBuffer); ok {
+		// The buffer is owned by js runtime.
+		return append([]byte(nil), buf.Bytes()...), nil
+	}
+	return []byte(val.String()), nil
+}
+
+// Write calls the write function of the file with data written at offset. Data is passed
+// as ArrayBuffer, because written bytes may be not valid UTF-8
+func (f *VirtualFile) Write(t *Task, data []byte, offset int64) error {
+	if !f.Writable() {
+		return errors.New("virtual file has no write function")
+	}
+	if t.injectingSyscall {
+		return errInjectedSyscallVirtualFile
+	}
+
+	runtime := GetJsRuntime()
+	runtime.Mutex.Lock()
+	defer runtime.Mutex.Unlock()
+
+	builder := ScriptContextsBuilderOf().AddContext2(VirtualFileJsName, []ContextAddable{
+		virtualFileArg{virtualFileJsPath, f.info.Path},
+		virtualFileArg{virtualFileJsData, runtime.JsVM.NewArrayBuffer(append([]byte(nil), data...))},
+		virtualFileArg{virtualFileJsOffset, offset},
+		&ObjectAddableAdapter{name: virtualFileJsFunctions, object: f.functions},
+	})
+	jsSource := fmt.Sprintf("%s.%s.%s(%s.%s, %s.%s, %s.%s)", VirtualFileJsName, virtualFileJsFunctions, virtualFileJsWrite,
+		VirtualFileJsName, virtualFileJsPath, VirtualFileJsName, virtualFileJsData, VirtualFileJsName, virtualFileJsOffset)
+
+	_, err := runtime.runTaskScriptLocked(t, nil, f.granted, jsSource, builder)
+	return err
+}
+
+// VirtualFiles returns virtual files from the init config
+func (r *GojaRuntime) VirtualFiles() []*VirtualFile {
+	return r.virtualFiles
+}
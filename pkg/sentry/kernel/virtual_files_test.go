@@ -0,0 +1,180 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"testing"
+)
+
+var virtualFileSource = `
+	function read(path) {
+		return "nameserver 10.0.0.1 # " + path
+	}
+	function readBuffer(path) {
+		return new Uint8Array([1, 2, 3]).buffer
+	}
+	function write(path, data, offset) {
+		persistence.glb.written = path + ":" + String.fromCharCode.apply(null, new Uint8Array(data)) + ":" + offset
+	}
+	function writeBytes(path, data, offset) {
+		persistence.glb.bytes = Array.from(new Uint8Array(data)).join(",")
+	}
+`
+
+func TestNewVirtualFile_Invalid(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	for _, dto := range []callbacks.VirtualFileDto{
+		{Path: "/etc/resolv.conf", Source: "function read(", Read: "read"},
+		{Path: "/etc/resolv.conf", Source: virtualFileSource, Read: "missing"},
+		{Path: "/etc/resolv.conf", Source: "var read = 1", Read: "read"},
+		{Path: "/etc/resolv.conf", Source: virtualFileSource, Read: "read", Write: "missing"},
+		{Path: "etc/resolv.conf", Source: virtualFileSource, Read: "read"},
+		{Path: "/", Source: virtualFileSource, Read: "read"},
+		{Path: "/etc/resolv.conf", Read: "read"},
+		{Path: "/etc/resolv.conf", Source: virtualFileSource},
+		{Path: "/etc/resolv.conf", Source: virtualFileSource, Read: "read", Mode: "0999"},
+		{Path: "/etc/resolv.conf", Source: virtualFileSource, Read: "read", Mode: "04644"},
+		{Path: "/etc/resolv.conf", Source: virtualFileSource, Read: "read", Capabilities: []string{"unknown"}},
+	} {
+		if _, err := NewVirtualFile(dto); err == nil {
+			t.Fatalf("no error for invalid virtual file %+v", dto)
+		}
+	}
+}
+
+func TestNewVirtualFile_Mode(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	for _, test := range []struct {
+		dto  callbacks.VirtualFileDto
+		mode uint32
+	}{
+		{callbacks.VirtualFileDto{Path: "/a", Source: virtualFileSource, Read: "read"}, 0444},
+		{callbacks.VirtualFileDto{Path: "/a", Source: virtualFileSource, Read: "read", Write: "write"}, 0644},
+		{callbacks.VirtualFileDto{Path: "/a", Source: virtualFileSource, Read: "read", Mode: "0400"}, 0400},
+	} {
+		file, err := NewVirtualFile(test.dto)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if uint32(file.Mode()) != test.mode {
+			t.Fatalf("mode is %o, but %o expected", file.Mode(), test.mode)
+		}
+	}
+}
+
+func TestVirtualFile_Read(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	file, err := NewVirtualFile(callbacks.VirtualFileDto{Path: "/etc/resolv.conf", Source: virtualFileSource, Read: "read"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := file.Read(&task)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "nameserver 10.0.0.1 # /etc/resolv.conf" {
+		t.Fatalf("unexpected content of virtual file: %q", data)
+	}
+}
+
+func TestVirtualFile_ReadArrayBuffer(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	file, err := NewVirtualFile(callbacks.VirtualFileDto{Path: "/license", Source: virtualFileSource, Read: "readBuffer"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := file.Read(&task)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(data) != 3 || data[0] != 1 || data[2] != 3 {
+		t.Fatalf("unexpected content of virtual file: %v", data)
+	}
+}
+
+func TestVirtualFile_Write(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	file, err := NewVirtualFile(callbacks.VirtualFileDto{
+		Path: "/etc/app.conf", Source: virtualFileSource, Read: "read", Write: "write"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := file.Write(&task, []byte("debug=1"), 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written := GetJsRuntime().Global.Get("written"); written == nil || written.String() != "/etc/app.conf:debug=1:5" {
+		t.Fatalf("write function got unexpected args: %v", written)
+	}
+}
+
+func TestVirtualFile_WriteBinary(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	file, err := NewVirtualFile(callbacks.VirtualFileDto{
+		Path: "/etc/app.bin", Source: virtualFileSource, Read: "read", Write: "writeBytes"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := file.Write(&task, []byte{0xff, 0x00, 0xc3}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written := GetJsRuntime().Global.Get("bytes"); written == nil || written.String() != "255,0,195" {
+		t.Fatalf("write function got corrupted data: %v", written)
+	}
+}
+
+func TestVirtualFile_SourceIsEvaluatedOnce(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	source := `
+		var evaluated = (evaluated || 0) + 1
+		var reads = 0
+		function read(path) {
+			reads++
+			return evaluated + ":" + reads
+		}
+	`
+	file, err := NewVirtualFile(callbacks.VirtualFileDto{Path: "/counter", Source: source, Read: "read"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{"1:1", "1:2"} {
+		data, err := file.Read(&task)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != expected {
+			t.Fatalf("content of virtual file is %q, but %q expected", data, expected)
+		}
+	}
+}
+
+func TestVirtualFile_WriteReadOnly(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+
+	file, err := NewVirtualFile(callbacks.VirtualFileDto{Path: "/etc/resolv.conf", Source: virtualFileSource, Read: "read"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	task := testCreateEmptyTask()
+	if err := file.Write(&task, []byte("x"), 0); err == nil {
+		t.Fatalf("no error for write to read-only virtual file")
+	}
+}
@@ -0,0 +1,479 @@
+package kernel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"sync"
+	"time"
+)
+
+// WasmHostModule is the name of the module whose functions are imported by WebAssembly callbacks
+const WasmHostModule = "hooks"
+
+// defaultWasmTimeout is used if callback doesn't specify timeout-ms. The deadline of the context
+// closes the module at the next function call or loop iteration of the guest. It also limits
+// time spent in hooks, which isn't counted by fuel
+const defaultWasmTimeout = 100 * time.Millisecond
+
+// defaultWasmFuel is used if callback doesn't specify fuel. wazero doesn't meter execution,
+// so modules are instrumented by meterWasmModule
+const defaultWasmFuel = 1 << 20
+
+// wasmEngine holds wazero runtime shared by all WebAssembly callbacks
+type wasmEngine struct {
+	runtime wazero.Runtime
+}
+
+var (
+	wasmEngineOnce sync.Once
+	wasmEngineInst *wasmEngine
+	wasmEngineErr  error
+)
+
+// getWasmEngine creates wazero runtime with host module on first use.
+// Interpreter is used because the sentry doesn't allow to map executable memory
+func getWasmEngine() (*wasmEngine, error) {
+	wasmEngineOnce.Do(func() {
+		ctx := context.Background()
+		config := wazero.NewRuntimeConfigInterpreter().WithCloseOnContextDone(true)
+		runtime := wazero.NewRuntimeWithConfig(ctx, config)
+
+		// Modules built by common toolchains import wasi even if they don't use it.
+		// No files, env and args are configured, so wasi doesn't give access to anything
+		if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+			wasmEngineErr = err
+			return
+		}
+		if _, err := instantiateWasmHostModule(ctx, runtime); err != nil {
+			wasmEngineErr = err
+			return
+		}
+		wasmEngineInst = &wasmEngine{runtime: runtime}
+	})
+	return wasmEngineInst, wasmEngineErr
+}
+
+type wasmInvocationKey struct{}
+
+// wasmInvocation is the state of callback invocation available to host functions
+type wasmInvocation struct {
+	t       *Task
+	granted callbacks.CapabilitySet
+
+	// result is json object set by the guest, it has same format as object returned by js callback
+	result []byte
+
+	// lastErr is the error of the last failed host function
+	lastErr error
+}
+
+func wasmInvocationFrom(ctx context.Context) *wasmInvocation {
+	return ctx.Value(wasmInvocationKey{}).(*wasmInvocation)
+}
+
+// fail saves err for lastError and returns -1, which is the result of failed host functions
+func (inv *wasmInvocation) fail(err error) int64 {
+	inv.lastErr = err
+	return -1
+}
+
+// instantiateWasmHostModule exports hooks to WebAssembly callbacks:
+//
+//	call(namePtr, nameLen, argsPtr, argsLen, outPtr, outCap i32) i64
+//	  calls the hook with json array of args, writes json result to out if it fits
+//	  and returns its length
+//	readMemory(addr i64, ptr, len i32) i64
+//	  copies memory of the task to memory of the module, returns count of copied bytes
+//	writeMemory(addr i64, ptr, len i32) i64
+//	  copies memory of the module to memory of the task, returns count of copied bytes
+//	setResult(ptr, len i32)
+//	  sets json object, which is handled as object returned by js callback
+//	lastError(ptr, cap i32) i32
+//	  writes the error of the last failed function if it fits and returns its length
+//
+// Functions return -1 on failure
+func instantiateWasmHostModule(ctx context.Context, runtime wazero.Runtime) (api.Module, error) {
+	return runtime.NewHostModuleBuilder(WasmHostModule).
+		NewFunctionBuilder().WithFunc(wasmCallHook).Export("call").
+		NewFunctionBuilder().WithFunc(wasmReadMemory).Export("readMemory").
+		NewFunctionBuilder().WithFunc(wasmWriteMemory).Export("writeMemory").
+		NewFunctionBuilder().WithFunc(wasmSetResult).Export("setResult").
+		NewFunctionBuilder().WithFunc(wasmLastError).Export("lastError").
+		Instantiate(ctx)
+}
+
+func wasmCallHook(ctx context.Context, m api.Module, namePtr, nameLen, argsPtr, argsLen, outPtr, outCap uint32) int64 {
+	inv := wasmInvocationFrom(ctx)
+
+	name, ok := m.Memory().Read(namePtr, nameLen)
+	if !ok {
+		return inv.fail(errors.New("name of hook is out of memory range"))
+	}
+	rawArgs, ok := m.Memory().Read(argsPtr, argsLen)
+	if !ok {
+		return inv.fail(errors.New("args of hook are out of memory range"))
+	}
+
+	callback := GetJsRuntime().hooksTable.hookCallback(string(name), inv.t, inv.granted)
+	if callback == nil {
+		return inv.fail(fmt.Errorf("hook %s isn't found", name))
+	}
+
+	var args []interface{}
+	if len(rawArgs) > 0 {
+		decoded, err := decodeWasmJSON(rawArgs)
+		if err != nil {
+			return inv.fail(err)
+		}
+		if args, ok = decoded.([]interface{}); !ok {
+			return inv.fail(errors.New("args of hook should be json array"))
+		}
+	}
+
+	vm := GetJsRuntime().JsVM
+	jsArgs := make([]goja.Value, len(args))
+	for i, arg := range args {
+		jsArgs[i] = vm.ToValue(arg)
+	}
+
+	ret, err := callback(jsArgs...)
+	if err != nil {
+		return inv.fail(err)
+	}
+	if value, ok := ret.(goja.Value); ok {
+		ret = value.Export()
+	}
+	out, err := json.Marshal(ret)
+	if err != nil {
+		return inv.fail(err)
+	}
+
+	if uint32(len(out)) <= outCap && !m.Memory().Write(outPtr, out) {
+		return inv.fail(errors.New("result of hook is out of memory range"))
+	}
+	return int64(len(out))
+}
+
+func wasmReadMemory(ctx context.Context, m api.Module, addr uint64, ptr, length uint32) int64 {
+	inv := wasmInvocationFrom(ctx)
+
+	buff := make([]byte, length)
+	count, err := ReadBytes(inv.t, uintptr(addr), buff)
+	if err != nil && count == 0 {
+		return inv.fail(err)
+	}
+	if !m.Memory().Write(ptr, buff[:count]) {
+		return inv.fail(errors.New("buffer is out of memory range"))
+	}
+	return int64(count)
+}
+
+func wasmWriteMemory(ctx context.Context, m api.Module, addr uint64, ptr, length uint32) int64 {
+	inv := wasmInvocationFrom(ctx)
+	if !inv.granted.Has(callbacks.CapabilityModifyMemory) {
+		return inv.fail(fmt.Errorf("writeMemory requires capability %s", callbacks.CapabilityModifyMemory))
+	}
+
+	buff, ok := m.Memory().Read(ptr, length)
+	if !ok {
+		return inv.fail(errors.New("buffer is out of memory range"))
+	}
+	count, err := WriteBytes(inv.t, uintptr(addr), buff)
+	if err != nil && count == 0 {
+		return inv.fail(err)
+	}
+	return int64(count)
+}
+
+func wasmSetResult(ctx context.Context, m api.Module, ptr, length uint32) {
+	inv := wasmInvocationFrom(ctx)
+
+	result, ok := m.Memory().Read(ptr, length)
+	if !ok {
+		inv.fail(errors.New("result is out of memory range"))
+		return
+	}
+	// Memory of the module may be reused before result is handled
+	inv.result = bytes.Clone(result)
+}
+
+func wasmLastError(ctx context.Context, m api.Module, ptr, capacity uint32) int32 {
+	inv := wasmInvocationFrom(ctx)
+	if inv.lastErr == nil {
+		return 0
+	}
+
+	msg := []byte(inv.lastErr.Error())
+	if uint32(len(msg)) <= capacity && !m.Memory().Write(ptr, msg) {
+		return -1
+	}
+	return int32(len(msg))
+}
+
+// decodeWasmJSON decodes json keeping integers as int64, so 64-bit addresses don't lose precision
+func decodeWasmJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return convertWasmJSONNumbers(value), nil
+}
+
+func convertWasmJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = convertWasmJSONNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = convertWasmJSONNumbers(v[key])
+		}
+	}
+	return value
+}
+
+// wasmModule is compiled module of callback with lazily created instance. The instance is kept
+// between invocations, so the module may keep state in its memory. Protected by GojaRuntime.Mutex
+type wasmModule struct {
+	compiled wazero.CompiledModule
+	instance api.Module
+}
+
+// compileWasmModule compiles module of callback and checks that entry point accepts paramCount i64 values
+func compileWasmModule(info *callbacks.JsCallbackInfo, paramCount int) (*wasmModule, error) {
+	engine, err := getWasmEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	metered, err := meterWasmModule(info.ModuleBytes)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := engine.runtime.CompileModule(context.Background(), metered)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := compiled.ExportedFunctions()[info.EntryPoint]
+	if !ok {
+		return nil, fmt.Errorf("module doesn't export entry point %s", info.EntryPoint)
+	}
+	params := entry.ParamTypes()
+	valid := len(params) == paramCount && len(entry.ResultTypes()) == 0
+	for _, param := range params {
+		valid = valid && param == api.ValueTypeI64
+	}
+	if !valid {
+		return nil, fmt.Errorf("entry point %s should accept %d i64 values and return nothing", info.EntryPoint, paramCount)
+	}
+
+	return &wasmModule{compiled: compiled}, nil
+}
+
+func (m *wasmModule) getInstance(ctx context.Context) (api.Module, error) {
+	if m.instance != nil && !m.instance.IsClosed() {
+		return m.instance, nil
+	}
+
+	engine, err := getWasmEngine()
+	if err != nil {
+		return nil, err
+	}
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+	if m.instance, err = engine.runtime.InstantiateModule(ctx, m.compiled, config); err != nil {
+		return nil, err
+	}
+	return m.instance, nil
+}
+
+// RunWasmCallback calls entry point of the module with syscall args followed by extra values
+// and handles the result set by the module as object returned by js callback.
+// Only hooks allowed by capabilities declared in info are callable
+func RunWasmCallback(t *Task, info *callbacks.JsCallbackInfo, module *wasmModule,
+	args *arch.SyscallArguments, extra ...uint64) (*arch.SyscallArguments, *SyscallReturnValue, error) {
+
+	granted, err := grantedCapabilities(info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	runtime := GetJsRuntime()
+	runtime.Mutex.Lock()
+	defer runtime.Mutex.Unlock()
+
+	runtime.granted, runtime.current = granted, info
+	defer func() { runtime.granted, runtime.current = callbacks.AllCapabilities, nil }()
+
+	timeout := defaultWasmTimeout
+	if info.TimeoutMs > 0 {
+		timeout = time.Duration(info.TimeoutMs) * time.Millisecond
+	}
+	inv := &wasmInvocation{t: t, granted: granted}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), wasmInvocationKey{}, inv), timeout)
+	defer cancel()
+
+	instance, err := module.getInstance(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := make([]uint64, 0, len(args)+len(extra))
+	for _, arg := range args {
+		params = append(params, uint64(arg.Value))
+	}
+	params = append(params, extra...)
+	fuel := int64(defaultWasmFuel)
+	if info.Fuel > 0 {
+		fuel = info.Fuel
+	}
+	fuelGlobal := instance.ExportedGlobal(WasmFuelGlobal).(api.MutableGlobal)
+	fuelGlobal.Set(uint64(fuel))
+	if _, err := instance.ExportedFunction(info.EntryPoint).Call(ctx, params...); err != nil {
+		if int64(fuelGlobal.Get()) < 0 {
+			// The module is trapped in the middle of execution, its state may be inconsistent
+			_ = instance.Close(context.Background())
+			return nil, nil, fmt.Errorf("wasm callback %s exhausted fuel of %d", info.EntryPoint, fuel)
+		}
+		return nil, nil, err
+	}
+
+	if inv.result == nil {
+		return args, nil, nil
+	}
+	result, err := decodeWasmJSON(inv.result)
+	if err != nil {
+		return nil, nil, err
+	}
+	val := runtime.JsVM.ToValue(result)
+
+	return extractCallbackResult(t, info, granted, args, runtime.JsVM, val)
+}
+
+// WasmCallbackBefore implements CallbackBefore and JsCallback with WebAssembly module
+type WasmCallbackBefore struct {
+	info   callbacks.JsCallbackInfo
+	module *wasmModule
+}
+
+func (cb *WasmCallbackBefore) callbackInfo() *callbacks.JsCallbackInfo {
+	return &cb.info
+}
+
+func (cb *WasmCallbackBefore) Info() callbacks.JsCallbackInfo {
+	return cb.info
+}
+
+func (cb *WasmCallbackBefore) registerAtCallbackTable(ct *CallbackTable) error {
+	return ct.registerCallbackBefore(uintptr(cb.info.Sysno), cb)
+}
+
+// CallbackBeforeFunc calls entry point of the module with 6 syscall args
+func (cb *WasmCallbackBefore) CallbackBeforeFunc(t *Task, _ uintptr,
+	args *arch.SyscallArguments) (*arch.SyscallArguments, *SyscallReturnValue, error) {
+
+	return RunWasmCallback(t, &cb.info, cb.module, args)
+}
+
+// WasmCallbackAfter implements CallbackAfter and JsCallback with WebAssembly module
+type WasmCallbackAfter struct {
+	info   callbacks.JsCallbackInfo
+	module *wasmModule
+}
+
+func (cb *WasmCallbackAfter) callbackInfo() *callbacks.JsCallbackInfo {
+	return &cb.info
+}
+
+func (cb *WasmCallbackAfter) Info() callbacks.JsCallbackInfo {
+	return cb.info
+}
+
+func (cb *WasmCallbackAfter) registerAtCallbackTable(ct *CallbackTable) error {
+	return ct.registerCallbackAfter(uintptr(cb.info.Sysno), cb)
+}
+
+// CallbackAfterFunc calls entry point of the module with 6 syscall args, return value and errno of syscall
+func (cb *WasmCallbackAfter) CallbackAfterFunc(t *Task, sysno uintptr, args *arch.SyscallArguments,
+	ret uintptr, inputErr error) (*arch.SyscallArguments, *SyscallReturnValue, error) {
+
+	var errno int
+	if inputErr != nil {
+		errno = ExtractErrno(inputErr, int(sysno))
+	}
+	return RunWasmCallback(t, &cb.info, cb.module, args, uint64(ret), uint64(errno))
+}
+
+// WasmCallbackEmulate implements CallbackEmulate and JsCallback with WebAssembly module
+type WasmCallbackEmulate struct {
+	info   callbacks.JsCallbackInfo
+	module *wasmModule
+}
+
+func (cb *WasmCallbackEmulate) callbackInfo() *callbacks.JsCallbackInfo {
+	return &cb.info
+}
+
+func (cb *WasmCallbackEmulate) Info() callbacks.JsCallbackInfo {
+	return cb.info
+}
+
+func (cb *WasmCallbackEmulate) registerAtCallbackTable(ct *CallbackTable) error {
+	return ct.registerCallbackEmulate(uintptr(cb.info.Sysno), cb)
+}
+
+// CallbackEmulateFunc calls entry point of the module with 6 syscall args. The module should
+// set {ret, errno} result, otherwise the syscall is handled as missing
+func (cb *WasmCallbackEmulate) CallbackEmulateFunc(t *Task, _ uintptr,
+	args *arch.SyscallArguments) (*SyscallReturnValue, error) {
+
+	_, sub, err := RunWasmCallback(t, &cb.info, cb.module, args)
+	return sub, err
+}
+
+// wasmCallbackByInfo compiles module of callback and returns WasmCallbackBefore,
+// WasmCallbackAfter or WasmCallbackEmulate according to type of callback
+func wasmCallbackByInfo(info callbacks.JsCallbackInfo) (JsCallback, error) {
+	if len(info.ModuleBytes) == 0 {
+		return nil, errors.New("wasm callback module is empty")
+	}
+
+	paramCount := len(arch.SyscallArguments{})
+	if info.Type == JsCallbackTypeAfter {
+		// return value and errno of syscall
+		paramCount += 2
+	}
+	module, err := compileWasmModule(&info, paramCount)
+	if err != nil {
+		return nil, err
+	}
+
+	switch info.Type {
+	case JsCallbackTypeBefore:
+		return &WasmCallbackBefore{info: info, module: module}, nil
+	case JsCallbackTypeAfter:
+		return &WasmCallbackAfter{info: info, module: module}, nil
+	case JsCallbackTypeEmulate:
+		return &WasmCallbackEmulate{info: info, module: module}, nil
+	}
+	return nil, errors.New("incorrect callback type " + info.Type)
+}
@@ -0,0 +1,142 @@
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testWasmModule imports hooks.setResult and exports:
+//   - cb(i64 x 6), which sets result {"1":42}
+//   - loop(i64 x 6), which never returns
+var testWasmModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+
+	// types: (i32, i32) -> (), (i64 x 6) -> ()
+	0x01, 0x0f, 0x02,
+	0x60, 0x02, 0x7f, 0x7f, 0x00,
+	0x60, 0x06, 0x7e, 0x7e, 0x7e, 0x7e, 0x7e, 0x7e, 0x00,
+
+	// imports: hooks.setResult
+	0x02, 0x13, 0x01,
+	0x05, 'h', 'o', 'o', 'k', 's',
+	0x09, 's', 'e', 't', 'R', 'e', 's', 'u', 'l', 't',
+	0x00, 0x00,
+
+	// functions: cb, loop
+	0x03, 0x03, 0x02, 0x01, 0x01,
+
+	// memory: 1 page
+	0x05, 0x03, 0x01, 0x00, 0x01,
+
+	// exports: memory, cb, loop
+	0x07, 0x16, 0x03,
+	0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x02, 'c', 'b', 0x00, 0x01,
+	0x04, 'l', 'o', 'o', 'p', 0x00, 0x02,
+
+	// code: cb calls setResult(0, 8), loop is infinite loop
+	0x0a, 0x12, 0x02,
+	0x08, 0x00, 0x41, 0x00, 0x41, 0x08, 0x10, 0x00, 0x0b,
+	0x07, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b,
+
+	// data: {"1":42} at 0
+	0x0b, 0x0e, 0x01,
+	0x00, 0x41, 0x00, 0x0b, 0x08,
+	'{', '"', '1', '"', ':', '4', '2', '}',
+}
+
+func testWasmCallbackInfo(entryPoint string, cbType string) callbacks.JsCallbackInfo {
+	return callbacks.JsCallbackInfo{
+		Sysno:       1,
+		EntryPoint:  entryPoint,
+		Type:        cbType,
+		Engine:      callbacks.EngineWasm,
+		ModuleBytes: testWasmModule,
+	}
+}
+
+func TestWasmCallbackBefore_ChangesArgs(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	cb, err := JsCallbackByInfo(testWasmCallbackInfo("cb", JsCallbackTypeBefore))
+	if err != nil {
+		t.Fatalf("failed to create wasm callback: %v", err)
+	}
+
+	args := arch.SyscallArguments{}
+	newArgs, sub, err := cb.(CallbackBefore).CallbackBeforeFunc(&task, 1, &args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sub != nil {
+		t.Fatalf("unexpected substitution of syscall: %v", *sub)
+	}
+	if newArgs[1].Value != 42 {
+		t.Fatalf("arg1 is %d, but 42 expected", newArgs[1].Value)
+	}
+}
+
+func TestWasmCallback_Timeout(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	info := testWasmCallbackInfo("loop", JsCallbackTypeBefore)
+	info.TimeoutMs = 10
+	info.Fuel = math.MaxInt64
+	cb, err := JsCallbackByInfo(info)
+	if err != nil {
+		t.Fatalf("failed to create wasm callback: %v", err)
+	}
+
+	args := arch.SyscallArguments{}
+	if _, _, err := cb.(CallbackBefore).CallbackBeforeFunc(&task, 1, &args); err == nil {
+		t.Fatalf("no error when callback exceeds timeout")
+	}
+}
+
+func TestWasmCallback_ExhaustsFuel(t *testing.T) {
+	testInitJsRuntime()
+	defer testDestroyJsRuntime()
+	task := testCreateEmptyTask()
+
+	info := testWasmCallbackInfo("loop", JsCallbackTypeBefore)
+	info.TimeoutMs = 60000
+	info.Fuel = 1000
+	cb, err := JsCallbackByInfo(info)
+	if err != nil {
+		t.Fatalf("failed to create wasm callback: %v", err)
+	}
+
+	// The instance is recreated after it runs out of fuel, so the result is the same
+	for i := 0; i < 2; i++ {
+		args := arch.SyscallArguments{}
+		_, _, err := cb.(CallbackBefore).CallbackBeforeFunc(&task, 1, &args)
+		if err == nil || !strings.Contains(err.Error(), "exhausted fuel of 1000") {
+			t.Fatalf("no fuel error when callback loops forever: %v", err)
+		}
+	}
+}
+
+func TestJsCallbackByInfo_InvalidWasmCallback(t *testing.T) {
+	if _, err := JsCallbackByInfo(testWasmCallbackInfo("missing", JsCallbackTypeBefore)); err == nil {
+		t.Fatalf("no error for not exported entry point")
+	}
+
+	// callbacks after syscall accept return value and errno too
+	_, err := JsCallbackByInfo(testWasmCallbackInfo("cb", JsCallbackTypeAfter))
+	if err == nil || !strings.Contains(err.Error(), "8 i64 values") {
+		t.Fatalf("no error for entry point with wrong signature: %v", err)
+	}
+
+	info := testWasmCallbackInfo("cb", JsCallbackTypeBefore)
+	info.ModuleBytes = nil
+	if _, err := JsCallbackByInfo(info); err == nil {
+		t.Fatalf("no error for empty module")
+	}
+}
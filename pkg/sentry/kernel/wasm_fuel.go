@@ -0,0 +1,434 @@
+package kernel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// WasmFuelGlobal is the name of the global, which is added to modules of WebAssembly callbacks
+// by meterWasmModule and holds the remaining fuel
+const WasmFuelGlobal = "__gvisor_fuel"
+
+// Ids of sections of WebAssembly module, which are changed by meterWasmModule
+const (
+	wasmSectionImport = 2
+	wasmSectionGlobal = 6
+	wasmSectionExport = 7
+	wasmSectionCode   = 10
+)
+
+// wasmSectionOrder is the position of known sections in the module, custom sections (id 0) may be anywhere
+var wasmSectionOrder = map[byte]int{
+	1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9, 12: 10, 10: 11, 11: 12,
+}
+
+type wasmSection struct {
+	id      byte
+	content []byte
+}
+
+// meterWasmModule adds fuel metering to the module: an exported mutable i64 global WasmFuelGlobal
+// is decremented on every function call and loop iteration, and the module traps when it gets
+// negative. So fuel limits executed code deterministically: code executed per unit of fuel is
+// bounded by the size of function bodies. The global is initialized with max value, so start
+// functions aren't limited
+func meterWasmModule(module []byte) ([]byte, error) {
+	if len(module) < 8 || !bytes.Equal(module[:4], []byte("\x00asm")) {
+		return nil, errors.New("not a WebAssembly module")
+	}
+
+	r := &wasmReader{data: module, pos: 8}
+	var sections []wasmSection
+	for !r.done() {
+		id := r.byte()
+		size := r.u32()
+		content := r.bytes(int(size))
+		if r.err != nil {
+			return nil, fmt.Errorf("bad WebAssembly section %d: %w", id, r.err)
+		}
+		sections = append(sections, wasmSection{id: id, content: content})
+	}
+
+	fuel, err := wasmImportedGlobals(sections)
+	if err != nil {
+		return nil, err
+	}
+	// The fuel global is appended to defined globals, so indexes of other globals are kept
+	global := appendSLEB([]byte{0x7e, 0x01, 0x42}, math.MaxInt64)
+	global = append(global, 0x0b)
+	sections, defined, err := appendWasmVectorItem(sections, wasmSectionGlobal, global)
+	if err != nil {
+		return nil, err
+	}
+	fuel += defined
+
+	export := appendULEB(nil, uint64(len(WasmFuelGlobal)))
+	export = append(export, WasmFuelGlobal...)
+	export = appendULEB(append(export, 0x03), uint64(fuel))
+	sections, _, err = appendWasmVectorItem(sections, wasmSectionExport, export)
+	if err != nil {
+		return nil, err
+	}
+
+	check := wasmFuelCheck(fuel)
+	for i := range sections {
+		if sections[i].id != wasmSectionCode {
+			continue
+		}
+		if sections[i].content, err = meterWasmCode(sections[i].content, check); err != nil {
+			return nil, err
+		}
+	}
+
+	out := append([]byte{}, module[:8]...)
+	for _, section := range sections {
+		out = append(out, section.id)
+		out = appendULEB(out, uint64(len(section.content)))
+		out = append(out, section.content...)
+	}
+	return out, nil
+}
+
+// wasmFuelCheck is inserted at the start of functions and loops. It decrements the fuel global
+// and traps when the fuel is exhausted:
+//
+//	global.get $fuel; i64.const 1; i64.sub; global.set $fuel
+//	global.get $fuel; i64.const 0; i64.lt_s; if; unreachable; end
+func wasmFuelCheck(global uint32) []byte {
+	get := appendULEB([]byte{0x23}, uint64(global))
+	check := append([]byte{}, get...)
+	check = append(check, 0x42, 0x01, 0x7d)
+	check = appendULEB(append(check, 0x24), uint64(global))
+	check = append(check, get...)
+	return append(check, 0x42, 0x00, 0x53, 0x04, 0x40, 0x00, 0x0b)
+}
+
+// wasmImportedGlobals returns the count of imported globals, they precede defined globals in the index space
+func wasmImportedGlobals(sections []wasmSection) (uint32, error) {
+	var globals uint32
+	for _, section := range sections {
+		if section.id != wasmSectionImport {
+			continue
+		}
+		r := &wasmReader{data: section.content}
+		for count := r.u32(); count > 0 && r.err == nil; count-- {
+			r.name()
+			r.name()
+			switch kind := r.byte(); kind {
+			case 0x00: // function
+				r.u32()
+			case 0x01: // table
+				r.byte()
+				r.limits()
+			case 0x02: // memory
+				r.limits()
+			case 0x03: // global
+				r.byte()
+				r.byte()
+				globals++
+			default:
+				return 0, fmt.Errorf("bad kind of WebAssembly import %d", kind)
+			}
+		}
+		if r.err != nil {
+			return 0, fmt.Errorf("bad WebAssembly import section: %w", r.err)
+		}
+	}
+	return globals, nil
+}
+
+// appendWasmVectorItem appends item to the vector, which is the content of section with id.
+// The section is created if the module doesn't have it. Returns the count of items before the new one
+func appendWasmVectorItem(sections []wasmSection, id byte, item []byte) ([]wasmSection, uint32, error) {
+	for i := range sections {
+		if sections[i].id != id {
+			continue
+		}
+		r := &wasmReader{data: sections[i].content}
+		count := r.u32()
+		if r.err != nil {
+			return nil, 0, fmt.Errorf("bad WebAssembly section %d: %w", id, r.err)
+		}
+		if id == wasmSectionExport {
+			if err := checkWasmExportNames(count, r); err != nil {
+				return nil, 0, err
+			}
+		}
+		content := appendULEB(nil, uint64(count)+1)
+		content = append(content, r.rest()...)
+		sections[i].content = append(content, item...)
+		return sections, count, nil
+	}
+
+	// Insert the section before the first known section, which should follow it
+	pos := len(sections)
+	for i, section := range sections {
+		if order, ok := wasmSectionOrder[section.id]; ok && order > wasmSectionOrder[id] {
+			pos = i
+			break
+		}
+	}
+	content := append([]byte{0x01}, item...)
+	sections = append(sections[:pos], append([]wasmSection{{id: id, content: content}}, sections[pos:]...)...)
+	return sections, 0, nil
+}
+
+// checkWasmExportNames checks that count exports read by r don't use WasmFuelGlobal
+func checkWasmExportNames(count uint32, r *wasmReader) error {
+	exports := &wasmReader{data: r.rest()}
+	for ; count > 0 && exports.err == nil; count-- {
+		if name := exports.name(); name == WasmFuelGlobal {
+			return fmt.Errorf("module exports reserved name %s", WasmFuelGlobal)
+		}
+		exports.byte()
+		exports.u32()
+	}
+	if exports.err != nil {
+		return fmt.Errorf("bad WebAssembly export section: %w", exports.err)
+	}
+	return nil
+}
+
+// meterWasmCode inserts check at the start of every function body and loop of the code section
+func meterWasmCode(code []byte, check []byte) ([]byte, error) {
+	r := &wasmReader{data: code}
+	count := r.u32()
+	out := appendULEB(nil, uint64(count))
+	for ; count > 0 && r.err == nil; count-- {
+		size := r.u32()
+		body := &wasmReader{data: r.bytes(int(size))}
+		if r.err != nil {
+			break
+		}
+
+		// Local declarations are kept as is
+		for locals := body.u32(); locals > 0 && body.err == nil; locals-- {
+			body.u32()
+			body.byte()
+		}
+		metered := append([]byte{}, body.data[:body.pos]...)
+		metered = append(metered, check...)
+		for !body.done() && body.err == nil {
+			start := body.pos
+			op := body.byte()
+			body.skipImmediates(op)
+			metered = append(metered, body.data[start:body.pos]...)
+			if op == 0x03 { // loop
+				metered = append(metered, check...)
+			}
+		}
+		if body.err != nil {
+			return nil, fmt.Errorf("bad WebAssembly function body: %w", body.err)
+		}
+		out = appendULEB(out, uint64(len(metered)))
+		out = append(out, metered...)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("bad WebAssembly code section: %w", r.err)
+	}
+	return out, nil
+}
+
+// errWasmTruncated is returned when the module ends in the middle of a structure
+var errWasmTruncated = errors.New("unexpected end of WebAssembly module")
+
+// wasmReader decodes WebAssembly binary. The first error is kept in err,
+// methods return zero values after it
+type wasmReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *wasmReader) done() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *wasmReader) rest() []byte {
+	return r.data[r.pos:]
+}
+
+func (r *wasmReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.done() {
+		r.err = errWasmTruncated
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *wasmReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.err = errWasmTruncated
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// leb reads LEB128 number of at most maxBits bits, the value is returned without sign extension
+func (r *wasmReader) leb(maxBits uint) uint64 {
+	var val uint64
+	for shift := uint(0); r.err == nil; shift += 7 {
+		if shift >= maxBits {
+			r.err = errors.New("too long LEB128 number in WebAssembly module")
+			return 0
+		}
+		b := r.byte()
+		val |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return val
+		}
+	}
+	return 0
+}
+
+func (r *wasmReader) u32() uint32 {
+	return uint32(r.leb(32))
+}
+
+func (r *wasmReader) name() string {
+	return string(r.bytes(int(r.u32())))
+}
+
+func (r *wasmReader) limits() {
+	if flags := r.byte(); flags&0x01 != 0 {
+		r.u32()
+		r.u32()
+	} else {
+		r.u32()
+	}
+}
+
+func (r *wasmReader) memarg() {
+	r.u32()
+	r.u32()
+}
+
+// skipImmediates skips immediate arguments of instruction op. Instructions of WebAssembly 2.0
+// (including SIMD, bulk memory and reference types) supported by wazero are known
+func (r *wasmReader) skipImmediates(op byte) {
+	switch {
+	case op == 0x02 || op == 0x03 || op == 0x04: // block, loop, if
+		r.leb(35) // s33 block type
+	case op == 0x0c || op == 0x0d: // br, br_if
+		r.u32()
+	case op == 0x0e: // br_table
+		for n := r.u32(); n > 0 && r.err == nil; n-- {
+			r.u32()
+		}
+		r.u32()
+	case op == 0x10: // call
+		r.u32()
+	case op == 0x11: // call_indirect
+		r.u32()
+		r.u32()
+	case op == 0x1c: // select t*
+		r.bytes(int(r.u32()))
+	case op >= 0x20 && op <= 0x26: // local.*, global.*, table.get, table.set
+		r.u32()
+	case op >= 0x28 && op <= 0x3e: // loads and stores
+		r.memarg()
+	case op == 0x3f || op == 0x40: // memory.size, memory.grow
+		r.byte()
+	case op == 0x41: // i32.const
+		r.leb(35)
+	case op == 0x42: // i64.const
+		r.leb(70)
+	case op == 0x43: // f32.const
+		r.bytes(4)
+	case op == 0x44: // f64.const
+		r.bytes(8)
+	case op == 0xd0: // ref.null
+		r.byte()
+	case op == 0xd2: // ref.func
+		r.u32()
+	case op == 0xfc:
+		r.skipMiscImmediates(r.u32())
+	case op == 0xfd:
+		r.skipVectorImmediates(r.u32())
+	case op <= 0xd2:
+		// Other instructions have no immediates
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown WebAssembly instruction %#x", op)
+		}
+	}
+}
+
+// skipMiscImmediates skips immediates of instructions with 0xfc prefix
+func (r *wasmReader) skipMiscImmediates(op uint32) {
+	switch {
+	case op <= 7: // saturating truncations
+	case op == 8: // memory.init
+		r.u32()
+		r.byte()
+	case op == 10: // memory.copy
+		r.byte()
+		r.byte()
+	case op == 11: // memory.fill
+		r.byte()
+	case op == 12 || op == 14: // table.init, table.copy
+		r.u32()
+		r.u32()
+	case op == 9 || op == 13 || (op >= 15 && op <= 17): // data.drop, elem.drop, table.grow/size/fill
+		r.u32()
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown WebAssembly instruction 0xfc %d", op)
+		}
+	}
+}
+
+// skipVectorImmediates skips immediates of SIMD instructions with 0xfd prefix
+func (r *wasmReader) skipVectorImmediates(op uint32) {
+	switch {
+	case op <= 11 || op == 92 || op == 93: // loads and stores
+		r.memarg()
+	case op == 12 || op == 13: // v128.const, i8x16.shuffle
+		r.bytes(16)
+	case op >= 21 && op <= 34: // extract_lane, replace_lane
+		r.byte()
+	case op >= 84 && op <= 91: // load_lane, store_lane
+		r.memarg()
+		r.byte()
+	case op <= 255:
+		// Other instructions have no immediates
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown WebAssembly instruction 0xfd %d", op)
+		}
+	}
+}
+
+func appendULEB(buf []byte, val uint64) []byte {
+	for {
+		b := byte(val & 0x7f)
+		val >>= 7
+		if val == 0 {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
+
+func appendSLEB(buf []byte, val int64) []byte {
+	for {
+		b := byte(val & 0x7f)
+		val >>= 7
+		if (val == 0 && b&0x40 == 0) || (val == -1 && b&0x40 != 0) {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
@@ -0,0 +1,62 @@
+package kernel
+
+import (
+	"context"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"testing"
+)
+
+// testCountModule exports count(n i64), which loops until n reaches 0
+var testCountModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// type: (func (param i64))
+	0x01, 0x05, 0x01, 0x60, 0x01, 0x7e, 0x00,
+	// function
+	0x03, 0x02, 0x01, 0x00,
+	// export "count"
+	0x07, 0x09, 0x01, 0x05, 'c', 'o', 'u', 'n', 't', 0x00, 0x00,
+	// code: loop local.get 0 i64.const 1 i64.sub local.tee 0 i64.const 0 i64.gt_s br_if 0 end
+	0x0a, 0x13, 0x01, 0x11, 0x00,
+	0x03, 0x40,
+	0x20, 0x00, 0x42, 0x01, 0x7d, 0x22, 0x00, 0x42, 0x00, 0x55, 0x0d, 0x00,
+	0x0b, 0x0b,
+}
+
+func TestMeterWasmModule(t *testing.T) {
+	ctx := context.Background()
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer rt.Close(ctx)
+
+	metered, err := meterWasmModule(testCountModule)
+	if err != nil {
+		t.Fatalf("failed to meter module: %v", err)
+	}
+	mod, err := rt.Instantiate(ctx, metered)
+	if err != nil {
+		t.Fatalf("failed to instantiate metered module: %v", err)
+	}
+	fuel := mod.ExportedGlobal(WasmFuelGlobal).(api.MutableGlobal)
+	count := mod.ExportedFunction("count")
+
+	// One unit for the call and one for each loop iteration
+	fuel.Set(11)
+	if _, err := count.Call(ctx, 10); err != nil {
+		t.Fatalf("count(10) failed with fuel 11: %v", err)
+	}
+	if rest := int64(fuel.Get()); rest != 0 {
+		t.Fatalf("fuel left after count(10) is %d, expected 0", rest)
+	}
+
+	fuel.Set(11)
+	if _, err := count.Call(ctx, 11); err == nil {
+		t.Fatalf("count(11) succeeded with fuel 11")
+	}
+	if rest := int64(fuel.Get()); rest >= 0 {
+		t.Fatalf("fuel left after trap is %d, expected negative", rest)
+	}
+
+	if _, err := meterWasmModule(metered); err == nil {
+		t.Fatalf("metered module was metered twice")
+	}
+}
@@ -86,6 +86,10 @@ type LoadArgs struct {
 
 	// Features specifies the CPU feature set for the executable.
 	Features cpuid.FeatureSet
+
+	// VDSOParamPage is the VDSO parameter page to map. If VDSOParamPage is
+	// nil, the parameter page of the VDSO is mapped.
+	VDSOParamPage *mm.SpecialMappable
 }
 
 // openPath opens args.Filename and checks that it is valid for loading.
@@ -254,7 +258,11 @@ func Load(ctx context.Context, args LoadArgs, extraAuxv []arch.AuxEntry, vdso *V
 	defer file.DecRef(ctx)
 
 	// Load the VDSO.
-	vdsoAddr, err := loadVDSO(ctx, args.MemoryManager, vdso, loaded)
+	paramPage := args.VDSOParamPage
+	if paramPage == nil {
+		paramPage = vdso.ParamPage
+	}
+	vdsoAddr, err := loadVDSO(ctx, args.MemoryManager, vdso, paramPage, loaded)
 	if err != nil {
 		return 0, nil, "", syserr.NewDynamic(fmt.Sprintf("error loading VDSO: %v", err), syserr.FromError(err).ToLinux())
 	}
@@ -232,7 +232,8 @@ func PrepareVDSO(mfp pgalloc.MemoryFileProvider) (*VDSO, error) {
 	}, nil
 }
 
-// loadVDSO loads the VDSO into m.
+// loadVDSO loads the VDSO into m, along with paramPage, which is either
+// v.ParamPage or the parameter page of a time namespace.
 //
 // VDSOs are special.
 //
@@ -246,7 +247,7 @@ func PrepareVDSO(mfp pgalloc.MemoryFileProvider) (*VDSO, error) {
 // compatibility with such binaries, we load the VDSO much like Linux.
 //
 // loadVDSO takes a reference on the VDSO and parameter page FrameRegions.
-func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF) (hostarch.Addr, error) {
+func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, paramPage *mm.SpecialMappable, bin loadedELF) (hostarch.Addr, error) {
 	if v.os != bin.os {
 		ctx.Warningf("Binary ELF OS %v and VDSO ELF OS %v differ", bin.os, v.os)
 		return 0, linuxerr.ENOEXEC
@@ -258,7 +259,7 @@ func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF)
 
 	// Reserve address space for the VDSO and its parameter page, which is
 	// mapped just before the VDSO.
-	mapSize := v.vdso.Length() + v.ParamPage.Length()
+	mapSize := v.vdso.Length() + paramPage.Length()
 	addr, err := m.MMap(ctx, memmap.MMapOpts{
 		Length:  mapSize,
 		Private: true,
@@ -270,9 +271,9 @@ func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF)
 
 	// Now map the param page.
 	_, err = m.MMap(ctx, memmap.MMapOpts{
-		Length:          v.ParamPage.Length(),
-		MappingIdentity: v.ParamPage,
-		Mappable:        v.ParamPage,
+		Length:          paramPage.Length(),
+		MappingIdentity: paramPage,
+		Mappable:        paramPage,
 		Addr:            addr,
 		Fixed:           true,
 		Unmap:           true,
@@ -286,9 +287,9 @@ func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF)
 	}
 
 	// Now map the VDSO itself.
-	vdsoAddr, ok := addr.AddLength(v.ParamPage.Length())
+	vdsoAddr, ok := addr.AddLength(paramPage.Length())
 	if !ok {
-		panic(fmt.Sprintf("Part of mapped range overflows? %#x + %#x", addr, v.ParamPage.Length()))
+		panic(fmt.Sprintf("Part of mapped range overflows? %#x + %#x", addr, paramPage.Length()))
 	}
 	_, err = m.MMap(ctx, memmap.MMapOpts{
 		Length:          v.vdso.Length(),
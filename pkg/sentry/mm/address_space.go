@@ -201,7 +201,7 @@ func (mm *MemoryManager) mapASLocked(pseg pmaIterator, ar hostarch.AddrRange, pr
 		pmaAR := pseg.Range()
 		pmaMapAR := pmaAR.Intersect(mapAR)
 		perms := pma.effectivePerms
-		if pma.needCOW {
+		if pma.needCOW || pma.uffdWP {
 			perms.Write = false
 		}
 		if perms.Any() { // MapFile precondition
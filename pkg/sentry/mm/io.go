@@ -487,6 +487,11 @@ func (mm *MemoryManager) handleASIOFault(ctx context.Context, addr hostarch.Addr
 	mm.activeMu.Lock()
 	pseg, pend, err := mm.getPMAsLocked(ctx, vseg, ar, at)
 	mm.mappingMu.RUnlock()
+	if uerr, ok := err.(*userfaultError); ok && pend.Start() <= ar.Start {
+		// The I/O is retried after the fault is resolved.
+		mm.activeMu.Unlock()
+		return uerr.wait(ctx)
+	}
 	if pendaddr := pend.Start(); pendaddr < ar.End {
 		if pendaddr <= ar.Start {
 			mm.activeMu.Unlock()
@@ -515,6 +520,22 @@ func (mm *MemoryManager) handleASIOFault(ctx context.Context, addr hostarch.Addr
 //
 // Preconditions: 0 < ar.Length() <= math.MaxInt64.
 func (mm *MemoryManager) withInternalMappings(ctx context.Context, ar hostarch.AddrRange, at hostarch.AccessType, ignorePermissions bool, f func(safemem.BlockSeq) (uint64, error)) (int64, error) {
+	for {
+		n, err := mm.tryWithInternalMappings(ctx, ar, at, ignorePermissions, f)
+		uerr, ok := err.(*userfaultError)
+		if !ok {
+			return n, err
+		}
+		if err := uerr.wait(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// tryWithInternalMappings is withInternalMappings, except that it returns a
+// *userfaultError without calling f if a page in ar must be provided by a
+// userfaultfd handler first.
+func (mm *MemoryManager) tryWithInternalMappings(ctx context.Context, ar hostarch.AddrRange, at hostarch.AccessType, ignorePermissions bool, f func(safemem.BlockSeq) (uint64, error)) (int64, error) {
 	// If pmas are already available, we can do IO without touching mm.vmas or
 	// mm.mappingMu.
 	mm.activeMu.RLock()
@@ -541,6 +562,10 @@ func (mm *MemoryManager) withInternalMappings(ctx context.Context, ar hostarch.A
 	mm.activeMu.Lock()
 	pseg, pend, perr := mm.getPMAsLocked(ctx, vseg, ar, at)
 	mm.mappingMu.RUnlock()
+	if _, ok := perr.(*userfaultError); ok {
+		mm.activeMu.Unlock()
+		return 0, perr
+	}
 	if pendaddr := pend.Start(); pendaddr < ar.End {
 		if pendaddr <= ar.Start {
 			mm.activeMu.Unlock()
@@ -584,11 +609,27 @@ func (mm *MemoryManager) withInternalMappings(ctx context.Context, ar hostarch.A
 //
 // Preconditions: !ars.IsEmpty().
 func (mm *MemoryManager) withVecInternalMappings(ctx context.Context, ars hostarch.AddrRangeSeq, at hostarch.AccessType, ignorePermissions bool, f func(safemem.BlockSeq) (uint64, error)) (int64, error) {
+	for {
+		n, err := mm.tryWithVecInternalMappings(ctx, ars, at, ignorePermissions, f)
+		uerr, ok := err.(*userfaultError)
+		if !ok {
+			return n, err
+		}
+		if err := uerr.wait(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// tryWithVecInternalMappings is withVecInternalMappings, except that it
+// returns a *userfaultError without calling f if a page in ars must be
+// provided by a userfaultfd handler first.
+func (mm *MemoryManager) tryWithVecInternalMappings(ctx context.Context, ars hostarch.AddrRangeSeq, at hostarch.AccessType, ignorePermissions bool, f func(safemem.BlockSeq) (uint64, error)) (int64, error) {
 	// withInternalMappings is faster than withVecInternalMappings because of
 	// iterator plumbing (this isn't generally practical in the vector case due
 	// to iterator invalidation between AddrRanges). Use it if possible.
 	if ars.NumRanges() == 1 {
-		return mm.withInternalMappings(ctx, ars.Head(), at, ignorePermissions, f)
+		return mm.tryWithInternalMappings(ctx, ars.Head(), at, ignorePermissions, f)
 	}
 
 	// If pmas are already available, we can do IO without touching mm.vmas or
@@ -614,6 +655,10 @@ func (mm *MemoryManager) withVecInternalMappings(ctx context.Context, ars hostar
 	mm.activeMu.Lock()
 	pars, perr := mm.getVecPMAsLocked(ctx, vars, at)
 	mm.mappingMu.RUnlock()
+	if _, ok := perr.(*userfaultError); ok {
+		mm.activeMu.Unlock()
+		return 0, perr
+	}
 	if pars.NumBytes() == 0 {
 		mm.activeMu.Unlock()
 		return 0, translateIOError(ctx, perr)
@@ -17,6 +17,7 @@ package mm
 import (
 	"fmt"
 
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/atomicbitops"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/hostarch"
@@ -56,7 +57,21 @@ func (mm *MemoryManager) SetMmapLayout(ac *arch.Context64, r *limits.LimitSet) (
 
 // Fork creates a copy of mm with 1 user, as for Linux syscalls fork() or
 // clone() (without CLONE_VM).
-func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
+func (mm *MemoryManager) Fork(ctx context.Context) (_ *MemoryManager, retErr error) {
+	// uffdForks maps userfaultfds with UFFD_FEATURE_EVENT_FORK that vmas are
+	// registered with to the userfaultfds of the copied vmas. Fork events
+	// block until they are read, so they must be reported after all locks
+	// are released.
+	uffdForks := make(map[Userfaultfd]Userfaultfd)
+	defer func() {
+		if retErr != nil {
+			return
+		}
+		for uffd, child := range uffdForks {
+			uffd.NotifyFork(ctx, child)
+		}
+	}()
+
 	mm.AddressSpace().PreFork()
 	defer mm.AddressSpace().PostFork()
 	mm.metadataMu.Lock()
@@ -126,6 +141,22 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 			vma.id.IncRef()
 		}
 		vma.mlockMode = memmap.MLockNone
+		if vma.uffd != nil {
+			// "If [UFFD_FEATURE_EVENT_FORK] is not enabled, the child
+			// process's memory is not registered with any userfaultfd." -
+			// ioctl_userfaultfd(2)
+			if vma.uffd.Features()&linux.UFFD_FEATURE_EVENT_FORK != 0 {
+				child, ok := uffdForks[vma.uffd]
+				if !ok {
+					child = vma.uffd.Fork(mm2)
+					uffdForks[vma.uffd] = child
+				}
+				vma.uffd = child
+			} else {
+				vma.uffd = nil
+				vma.uffdMode = 0
+			}
+		}
 		dstvgap = mm2.vmas.Insert(dstvgap, vmaAR, vma).NextGap()
 		// We don't need to update mm2.usageAS since we copied it from mm
 		// above.
@@ -207,6 +238,13 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 	if unmapAR.Length() != 0 {
 		mm.unmapASLocked(unmapAR)
 	}
+	// Write protection of copied pmas is only kept in vmas that are still
+	// registered for it.
+	for dstvseg := mm2.vmas.FirstSegment(); dstvseg.Ok(); dstvseg = dstvseg.NextSegment() {
+		if dstvseg.ValuePtr().uffdMode&linux.UFFDIO_REGISTER_MODE_WP == 0 {
+			mm2.clearUserfaultfdWPLocked(dstvseg.Range())
+		}
+	}
 
 	// Between when we call memmap.Mappable.AddMapping while copying vmas and
 	// when we lock mm2.activeMu to copy pmas, calls to mm2.Invalidate() are
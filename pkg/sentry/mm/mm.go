@@ -314,6 +314,11 @@ type vma struct {
 	// /proc/[pid]/maps. hint takes priority over id.MappedName().
 	hint string
 
+	// If uffd is not nil, the vma is registered with the userfaultfd uffd in
+	// modes uffdMode, a combination of linux.UFFDIO_REGISTER_MODE_*.
+	uffd     Userfaultfd
+	uffdMode uint64
+
 	// lastFault records the last address that was paged faulted. It hints at
 	// which direction addresses in this vma are being accessed.
 	//
@@ -337,6 +342,8 @@ func (v *vma) copy() vma {
 		numaNodemask:   v.numaNodemask,
 		id:             v.id,
 		hint:           v.hint,
+		uffd:           v.uffd,
+		uffdMode:       v.uffdMode,
 		lastFault:      atomic.LoadUintptr(&v.lastFault),
 	}
 }
@@ -381,6 +388,11 @@ type pma struct {
 	// corresponding vma's memmap.Mappable.Translate.
 	private bool
 
+	// uffdWP is true if writes to the pma are reported to the userfaultfd of
+	// its vma, see UFFDIO_WRITEPROTECT. Writes to the pma aren't mapped into
+	// the AddressSpace while uffdWP is true.
+	uffdWP bool
+
 	// If internalMappings is not empty, it is the cached return value of
 	// file.MapInternal for the memmap.FileRange mapped by this pma.
 	internalMappings safemem.BlockSeq `state:"nosave"`
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"sync/atomic"
 
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/hostarch"
@@ -54,6 +55,9 @@ func (mm *MemoryManager) existingPMAsLocked(ar hostarch.AddrRange, at hostarch.A
 		if !perms.SupersetOf(at) {
 			return pmaIterator{}
 		}
+		if at.Write && pma.uffdWP {
+			return pmaIterator{}
+		}
 		if needInternalMappings && pma.internalMappings.IsEmpty() {
 			return pmaIterator{}
 		}
@@ -238,6 +242,11 @@ func (mm *MemoryManager) getPMAsInternalLocked(ctx context.Context, vseg vmaIter
 					}
 				}
 				if vma.mappable == nil {
+					if vma.uffdMode&linux.UFFDIO_REGISTER_MODE_MISSING != 0 {
+						// Missing pages are provided by the userfaultfd
+						// handler instead of allocating.
+						return pstart, pgap, newUserfaultError(vma, pgap.Range().Intersect(ar).Start, at, false /* wp */)
+					}
 					// Private anonymous mappings get pmas by allocating.
 					allocAR := optAR.Intersect(maskAR)
 					fr, err := mm.mf.Allocate(uint64(allocAR.Length()), opts)
@@ -325,6 +334,11 @@ func (mm *MemoryManager) getPMAsInternalLocked(ctx context.Context, vseg vmaIter
 
 			case pseg.Ok() && pseg.Start() < vsegAR.End:
 				oldpma := pseg.ValuePtr()
+				if at.Write && oldpma.uffdWP && vma.uffd != nil {
+					// Writes to write-protected pmas are resolved by the
+					// userfaultfd handler.
+					return pstart, pseg.PrevGap(), newUserfaultError(vma, pseg.Range().Intersect(ar).Start, at, true /* wp */)
+				}
 				if at.Write && mm.isPMACopyOnWriteLocked(vseg, pseg) {
 					// Break copy-on-write by copying.
 					if checkInvariants {
@@ -686,6 +700,17 @@ func (mm *MemoryManager) Pin(ctx context.Context, ar hostarch.AddrRange, at host
 	mm.activeMu.Lock()
 	pseg, pend, perr := mm.getPMAsLocked(ctx, vseg, ar, at)
 	mm.mappingMu.RUnlock()
+	if uerr, ok := perr.(*userfaultError); ok && pend.Start() <= ar.Start {
+		// Like get_user_pages(), wait for the fault to be resolved and try
+		// again.
+		mm.activeMu.Unlock()
+		if err := uerr.wait(ctx); err != nil {
+			return nil, err
+		}
+		return mm.Pin(ctx, ar, at, ignorePermissions)
+	} else if ok {
+		perr = linuxerr.EFAULT
+	}
 	if pendaddr := pend.Start(); pendaddr < ar.End {
 		if pendaddr <= ar.Start {
 			mm.activeMu.Unlock()
@@ -960,7 +985,8 @@ func (pmaSetFunctions) Merge(ar1 hostarch.AddrRange, pma1 pma, ar2 hostarch.Addr
 		pma1.effectivePerms != pma2.effectivePerms ||
 		pma1.maxPerms != pma2.maxPerms ||
 		pma1.needCOW != pma2.needCOW ||
-		pma1.private != pma2.private {
+		pma1.private != pma2.private ||
+		pma1.uffdWP != pma2.uffdWP {
 		return pma{}, false
 	}
 
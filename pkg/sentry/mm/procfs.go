@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/log"
@@ -267,5 +268,11 @@ func (mm *MemoryManager) vmaSmapsEntryIntoLocked(ctx context.Context, vseg vmaIt
 	if vma.private && vma.effectivePerms.Write { // VM_ACCOUNT
 		b.WriteString("ac ")
 	}
+	if vma.uffdMode&linux.UFFDIO_REGISTER_MODE_MISSING != 0 { // VM_UFFD_MISSING
+		b.WriteString("um ")
+	}
+	if vma.uffdMode&linux.UFFDIO_REGISTER_MODE_WP != 0 { // VM_UFFD_WP
+		b.WriteString("uw ")
+	}
 	b.WriteString("\n")
 }
@@ -131,3 +131,37 @@ func (m *SpecialMappable) FileRange() memmap.FileRange {
 func (m *SpecialMappable) Length() uint64 {
 	return m.fr.Length()
 }
+
+// ReplaceSpecialMappable maps to over every mapping of from in mm, with the
+// same permissions. This is used to switch the VDSO parameter page of an
+// address space that enters a time namespace.
+func (mm *MemoryManager) ReplaceSpecialMappable(ctx context.Context, from, to *SpecialMappable) error {
+	var opts []memmap.MMapOpts
+	mm.mappingMu.RLock()
+	for vseg := mm.vmas.FirstSegment(); vseg.Ok(); vseg = vseg.NextSegment() {
+		vma := vseg.ValuePtr()
+		if vma.mappable != memmap.Mappable(from) {
+			continue
+		}
+		opts = append(opts, memmap.MMapOpts{
+			Length:          uint64(vseg.Range().Length()),
+			MappingIdentity: to,
+			Mappable:        to,
+			Offset:          vma.off,
+			Addr:            vseg.Start(),
+			Fixed:           true,
+			Unmap:           true,
+			Private:         vma.private,
+			Perms:           vma.realPerms,
+			MaxPerms:        vma.maxPerms,
+		})
+	}
+	mm.mappingMu.RUnlock()
+
+	for i := range opts {
+		if _, err := mm.MMap(ctx, opts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -57,6 +57,11 @@ func (mm *MemoryManager) HandleUserFault(ctx context.Context, addr hostarch.Addr
 	mm.mappingMu.RUnlock()
 	if err != nil {
 		mm.activeMu.Unlock()
+		if uerr, ok := err.(*userfaultError); ok {
+			// The application retries the access after the fault is
+			// resolved.
+			return uerr.uffd.HandleFault(ctx, addr, uerr.flags, true /* user */)
+		}
 		return err
 	}
 
@@ -115,10 +120,18 @@ func (mm *MemoryManager) MMap(ctx context.Context, opts memmap.MMapOpts) (hostar
 
 	// Get the new vma.
 	var droppedIDs []memmap.MappingIdentity
+	var unmaps userfaultfdUnmaps
 	mm.mappingMu.Lock()
 	if opts.MLockMode < mm.defMLockMode {
 		opts.MLockMode = mm.defMLockMode
 	}
+	if opts.Unmap {
+		// opts.Unmap implies opts.Fixed, so createVMALocked replaces the
+		// mappings in exactly this range.
+		if unmapAR, ok := opts.Addr.ToRange(opts.Length); ok {
+			unmaps.addLocked(mm, unmapAR)
+		}
+	}
 	vseg, ar, droppedIDs, err := mm.createVMALocked(ctx, opts, droppedIDs)
 	if err != nil {
 		mm.mappingMu.Unlock()
@@ -151,6 +164,7 @@ func (mm *MemoryManager) MMap(ctx context.Context, opts memmap.MMapOpts) (hostar
 	for _, id := range droppedIDs {
 		id.DecRef(ctx)
 	}
+	unmaps.notify(ctx)
 
 	return ar.Start, nil
 }
@@ -307,13 +321,16 @@ func (mm *MemoryManager) MUnmap(ctx context.Context, addr hostarch.Addr, length
 	}
 
 	var droppedIDs []memmap.MappingIdentity
+	var unmaps userfaultfdUnmaps
 	mm.mappingMu.Lock()
+	unmaps.addLocked(mm, ar)
 	_, droppedIDs = mm.unmapLocked(ctx, ar, droppedIDs)
 	mm.mappingMu.Unlock()
 
 	for _, id := range droppedIDs {
 		id.DecRef(ctx)
 	}
+	unmaps.notify(ctx)
 
 	return nil
 }
@@ -374,6 +391,24 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 		}
 	}()
 
+	// If the moved vma is registered with a userfaultfd with
+	// UFFD_FEATURE_EVENT_REMAP, the move is reported after mm.mappingMu is
+	// unlocked, since reporting blocks until the event is read.
+	var remapUffd Userfaultfd
+	var remapFrom, remapTo hostarch.Addr
+	var remapLen uint64
+	defer func() {
+		if remapUffd != nil {
+			remapUffd.NotifyRemap(ctx, remapFrom, remapTo, remapLen)
+		}
+	}()
+
+	// Likewise for ranges unmapped with UFFD_FEATURE_EVENT_UNMAP.
+	var unmaps userfaultfdUnmaps
+	defer func() {
+		unmaps.notify(ctx)
+	}()
+
 	mm.mappingMu.Lock()
 	defer mm.mappingMu.Unlock()
 
@@ -418,6 +453,7 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 				// If oldAddr+oldSize didn't overflow, oldAddr+newSize can't
 				// either.
 				newEnd := oldAddr + hostarch.Addr(newSize)
+				unmaps.addLocked(mm, hostarch.AddrRange{newEnd, oldEnd})
 				_, droppedIDs = mm.unmapLocked(ctx, hostarch.AddrRange{newEnd, oldEnd}, droppedIDs)
 			}
 			return oldAddr, nil
@@ -500,6 +536,7 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 		}
 
 		// Unmap any mappings at the destination.
+		unmaps.addLocked(mm, newAR)
 		_, droppedIDs = mm.unmapLocked(ctx, newAR, droppedIDs)
 
 		// If the sizes specify shrinking, unmap everything between the new and
@@ -508,6 +545,7 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 		// vma_to_resize().
 		if newSize < oldSize {
 			oldNewEnd := oldAddr + hostarch.Addr(newSize)
+			unmaps.addLocked(mm, hostarch.AddrRange{oldNewEnd, oldEnd})
 			_, droppedIDs = mm.unmapLocked(ctx, hostarch.AddrRange{oldNewEnd, oldEnd}, droppedIDs)
 			oldEnd = oldNewEnd
 		}
@@ -596,6 +634,17 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 	// for private pmas.
 	mm.activeMu.Lock()
 	mm.movePMAsLocked(oldAR, newAR)
+	if vma.uffd != nil {
+		if vma.uffd.Features()&linux.UFFD_FEATURE_EVENT_REMAP != 0 {
+			remapUffd = vma.uffd
+			remapFrom, remapTo, remapLen = oldAR.Start, newAR.Start, uint64(oldAR.Length())
+		} else {
+			// Linux: mm/userfaultfd.c:mremap_userfaultfd_prep()
+			vma.uffd = nil
+			vma.uffdMode = 0
+			mm.clearUserfaultfdWPLocked(newAR)
+		}
+	}
 	mm.activeMu.Unlock()
 
 	// Now that pmas have been moved to newAR, we can notify vma.mappable that
@@ -766,11 +815,13 @@ func (mm *MemoryManager) Brk(ctx context.Context, addr hostarch.Addr) (hostarch.
 	var err error
 
 	var droppedIDs []memmap.MappingIdentity
+	var unmaps userfaultfdUnmaps
 	// This must run after mm.mappingMu.Unlock().
 	defer func() {
 		for _, id := range droppedIDs {
 			id.DecRef(ctx)
 		}
+		unmaps.notify(ctx)
 	}()
 
 	switch {
@@ -802,6 +853,7 @@ func (mm *MemoryManager) Brk(ctx context.Context, addr hostarch.Addr) (hostarch.
 		}
 
 	case newbrkpg < oldbrkpg:
+		unmaps.addLocked(mm, hostarch.AddrRange{newbrkpg, oldbrkpg})
 		_, droppedIDs = mm.unmapLocked(ctx, hostarch.AddrRange{newbrkpg, oldbrkpg}, droppedIDs)
 		fallthrough
 
@@ -891,6 +943,11 @@ func (mm *MemoryManager) MLock(ctx context.Context, addr hostarch.Addr, length u
 				return linuxerr.ENOMEM
 			}
 			_, _, err := mm.getPMAsLocked(ctx, vseg, vseg.Range().Intersect(ar), hostarch.NoAccess)
+			if _, ok := err.(*userfaultError); ok {
+				// Missing pages of vmas registered with a userfaultfd are
+				// populated by its handler when they are accessed.
+				continue
+			}
 			if err != nil {
 				mm.activeMu.Unlock()
 				mm.mappingMu.RUnlock()
@@ -1084,12 +1141,22 @@ func (mm *MemoryManager) SetDontFork(addr hostarch.Addr, length uint64, dontfork
 }
 
 // Decommit implements the semantics of Linux's madvise(MADV_DONTNEED).
-func (mm *MemoryManager) Decommit(addr hostarch.Addr, length uint64) error {
+func (mm *MemoryManager) Decommit(ctx context.Context, addr hostarch.Addr, length uint64) error {
 	ar, ok := addr.ToRange(length)
 	if !ok {
 		return linuxerr.EINVAL
 	}
 
+	// Report UFFD_EVENT_REMOVE before pages are removed, so that the handler
+	// doesn't resolve faults in ar with stale data. Linux:
+	// mm/madvise.c:madvise_dontneed_free() => userfaultfd_remove()
+	mm.mappingMu.RLock()
+	uffds := mm.userfaultfdsLocked(ar, linux.UFFD_FEATURE_EVENT_REMOVE)
+	mm.mappingMu.RUnlock()
+	for _, uffd := range uffds {
+		uffd.NotifyRemove(ctx, ar)
+	}
+
 	mm.mappingMu.RLock()
 	defer mm.mappingMu.RUnlock()
 	mm.activeMu.Lock()
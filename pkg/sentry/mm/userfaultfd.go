@@ -0,0 +1,365 @@
+package mm
+
+import (
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/safemem"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+	"gvisor.dev/gvisor/pkg/sentry/usage"
+)
+
+// Userfaultfd is a userfaultfd context that vmas can be registered with, see
+// userfaultfd(2). Only private anonymous vmas can be registered. Note that
+// MMap may populate small anonymous mappings eagerly, so their pages aren't
+// missing when they are registered.
+type Userfaultfd interface {
+	// Features returns the linux.UFFD_FEATURE_* enabled by UFFDIO_API.
+	Features() uint64
+
+	// HandleFault reports a fault at addr to the handler and blocks until the
+	// fault is resolved, after which the faulting access should be retried.
+	// flags are linux.UFFD_PAGEFAULT_FLAG_*. user is true if the fault was
+	// caused by the application rather than by the sentry accessing
+	// application memory.
+	//
+	// Preconditions: mm locks are not held.
+	HandleFault(ctx context.Context, addr hostarch.Addr, flags uint64, user bool) error
+
+	// Fork returns the context that vmas registered with the Userfaultfd are
+	// registered with in child, a copy of their MemoryManager created by
+	// fork. It's only called if UFFD_FEATURE_EVENT_FORK is enabled.
+	//
+	// Preconditions: mm locks are held, so Fork must not block.
+	Fork(child *MemoryManager) Userfaultfd
+
+	// NotifyFork reports UFFD_EVENT_FORK for a context returned by Fork.
+	NotifyFork(ctx context.Context, child Userfaultfd)
+
+	// NotifyRemap reports UFFD_EVENT_REMAP.
+	NotifyRemap(ctx context.Context, from, to hostarch.Addr, length uint64)
+
+	// NotifyRemove reports UFFD_EVENT_REMOVE.
+	NotifyRemove(ctx context.Context, ar hostarch.AddrRange)
+
+	// NotifyUnmap reports UFFD_EVENT_UNMAP.
+	NotifyUnmap(ctx context.Context, ar hostarch.AddrRange)
+}
+
+// userfaultError is returned by getPMAsLocked when an access must be
+// resolved by a userfaultfd handler.
+type userfaultError struct {
+	uffd  Userfaultfd
+	addr  hostarch.Addr
+	flags uint64
+}
+
+// newUserfaultError returns the userfaultError for an access of type at to
+// addr in vma.
+func newUserfaultError(vma *vma, addr hostarch.Addr, at hostarch.AccessType, wp bool) *userfaultError {
+	var flags uint64
+	if at.Write {
+		flags |= linux.UFFD_PAGEFAULT_FLAG_WRITE
+	}
+	if wp {
+		flags |= linux.UFFD_PAGEFAULT_FLAG_WP
+	}
+	return &userfaultError{
+		uffd:  vma.uffd,
+		addr:  addr,
+		flags: flags,
+	}
+}
+
+// Error implements error.Error.
+func (e *userfaultError) Error() string {
+	return fmt.Sprintf("userfaultfd fault at %#x (flags %#x)", e.addr, e.flags)
+}
+
+// wait waits until the fault is resolved for an access by the sentry. It
+// returns nil if the access should be retried.
+func (e *userfaultError) wait(ctx context.Context) error {
+	err := e.uffd.HandleFault(ctx, e.addr, e.flags, false /* user */)
+	if err == nil || linuxerr.Equals(linuxerr.ERESTARTSYS, err) {
+		return err
+	}
+	return translateIOError(ctx, err)
+}
+
+// canUserfault returns true if v can be registered with a userfaultfd.
+func (v *vma) canUserfault() bool {
+	// Shared memory and files are not supported, which is
+	// UFFD_FEATURE_MISSING_SHMEM and UFFD_FEATURE_MISSING_HUGETLBFS in Linux.
+	return v.mappable == nil
+}
+
+// RegisterUserfaultfd registers vmas in ar with uffd in mode, see
+// UFFDIO_REGISTER.
+func (mm *MemoryManager) RegisterUserfaultfd(ar hostarch.AddrRange, uffd Userfaultfd, mode uint64) error {
+	mm.mappingMu.Lock()
+	defer mm.mappingMu.Unlock()
+
+	vseg := mm.vmas.LowerBoundSegment(ar.Start)
+	if !vseg.Ok() {
+		return linuxerr.ENOMEM
+	}
+	if vseg.Start() >= ar.End {
+		return linuxerr.EINVAL
+	}
+	// Check all vmas before changing any of them.
+	for it := vseg; it.Ok() && it.Start() < ar.End; it = it.NextSegment() {
+		vma := it.ValuePtr()
+		if !vma.canUserfault() {
+			return linuxerr.EINVAL
+		}
+		if vma.uffd != nil && vma.uffd != uffd {
+			return linuxerr.EBUSY
+		}
+	}
+
+	defer func() {
+		mm.vmas.MergeInsideRange(ar)
+		mm.vmas.MergeOutsideRange(ar)
+	}()
+	for vseg.Ok() && vseg.Start() < ar.End {
+		vseg = mm.vmas.Isolate(vseg, ar)
+		vma := vseg.ValuePtr()
+		vma.uffd = uffd
+		vma.uffdMode = mode
+		vseg = vseg.NextSegment()
+	}
+	return nil
+}
+
+// UnregisterUserfaultfd unregisters vmas in ar from uffd, see
+// UFFDIO_UNREGISTER.
+func (mm *MemoryManager) UnregisterUserfaultfd(ar hostarch.AddrRange, uffd Userfaultfd) error {
+	mm.mappingMu.Lock()
+	defer mm.mappingMu.Unlock()
+
+	vseg := mm.vmas.LowerBoundSegment(ar.Start)
+	if !vseg.Ok() {
+		return linuxerr.ENOMEM
+	}
+	if vseg.Start() >= ar.End {
+		return linuxerr.EINVAL
+	}
+	for it := vseg; it.Ok() && it.Start() < ar.End; it = it.NextSegment() {
+		if !it.ValuePtr().canUserfault() {
+			return linuxerr.EINVAL
+		}
+	}
+
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+	defer func() {
+		mm.vmas.MergeInsideRange(ar)
+		mm.vmas.MergeOutsideRange(ar)
+	}()
+	for vseg.Ok() && vseg.Start() < ar.End {
+		if vseg.ValuePtr().uffd == uffd {
+			vseg = mm.vmas.Isolate(vseg, ar)
+			mm.unregisterUserfaultfdLocked(vseg)
+		}
+		vseg = vseg.NextSegment()
+	}
+	return nil
+}
+
+// ReleaseUserfaultfd unregisters all vmas registered with uffd, which is
+// being released.
+func (mm *MemoryManager) ReleaseUserfaultfd(uffd Userfaultfd) {
+	mm.mappingMu.Lock()
+	defer mm.mappingMu.Unlock()
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+
+	for vseg := mm.vmas.FirstSegment(); vseg.Ok(); vseg = vseg.NextSegment() {
+		if vseg.ValuePtr().uffd == uffd {
+			mm.unregisterUserfaultfdLocked(vseg)
+		}
+	}
+	ar := mm.applicationAddrRange()
+	mm.vmas.MergeInsideRange(ar)
+	mm.pmas.MergeInsideRange(ar)
+}
+
+// unregisterUserfaultfdLocked removes the userfaultfd registration of vseg.
+//
+// Preconditions:
+//   - mm.mappingMu must be locked for writing.
+//   - mm.activeMu must be locked for writing.
+func (mm *MemoryManager) unregisterUserfaultfdLocked(vseg vmaIterator) {
+	vma := vseg.ValuePtr()
+	vma.uffd = nil
+	vma.uffdMode = 0
+	mm.clearUserfaultfdWPLocked(vseg.Range())
+}
+
+// clearUserfaultfdWPLocked removes write protection from pmas in ar.
+//
+// Preconditions: mm.activeMu must be locked for writing.
+func (mm *MemoryManager) clearUserfaultfdWPLocked(ar hostarch.AddrRange) {
+	for pseg := mm.pmas.LowerBoundSegment(ar.Start); pseg.Ok() && pseg.Start() < ar.End; pseg = pseg.NextSegment() {
+		if pseg.ValuePtr().uffdWP {
+			pseg = mm.pmas.Isolate(pseg, ar)
+			pseg.ValuePtr().uffdWP = false
+		}
+	}
+	mm.pmas.MergeInsideRange(ar)
+	mm.pmas.MergeOutsideRange(ar)
+}
+
+// UserfaultfdFill resolves a missing page fault in the page at addr, which
+// must be in a vma registered with uffd, by filling it with data, or zeroes
+// if data is nil. The page is write-protected if wp is true. See UFFDIO_COPY
+// and UFFDIO_ZEROPAGE.
+//
+// Preconditions:
+//   - addr is page-aligned.
+//   - data is nil or len(data) == hostarch.PageSize.
+func (mm *MemoryManager) UserfaultfdFill(ctx context.Context, uffd Userfaultfd, addr hostarch.Addr, data []byte, wp bool) error {
+	ar, ok := addr.ToRange(hostarch.PageSize)
+	if !ok {
+		return linuxerr.EINVAL
+	}
+
+	mm.mappingMu.RLock()
+	defer mm.mappingMu.RUnlock()
+	vseg := mm.vmas.FindSegment(addr)
+	if !vseg.Ok() || vseg.ValuePtr().uffd != uffd {
+		return linuxerr.ENOENT
+	}
+	vma := vseg.ValuePtr()
+	if wp && vma.uffdMode&linux.UFFDIO_REGISTER_MODE_WP == 0 {
+		return linuxerr.EINVAL
+	}
+
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+	pgap := mm.pmas.FindGap(addr)
+	if !pgap.Ok() {
+		return linuxerr.EEXIST
+	}
+
+	opts := pgalloc.AllocOpts{
+		Kind:    usage.Anonymous,
+		MemCgID: pgalloc.MemoryCgroupIDFromContext(ctx),
+	}
+	if data != nil {
+		opts.Mode = pgalloc.AllocateAndWritePopulate
+		opts.Reader = &safemem.BlockSeqReader{safemem.BlockSeqOf(safemem.BlockFromSafeSlice(data))}
+	}
+	fr, err := mm.mf.Allocate(hostarch.PageSize, opts)
+	if err != nil {
+		return err
+	}
+	mm.addRSSLocked(ar)
+	mm.pmas.Insert(pgap, ar, pma{
+		file:           mm.mf,
+		off:            fr.Start,
+		translatePerms: hostarch.AnyAccess,
+		effectivePerms: vma.effectivePerms,
+		maxPerms:       vma.maxPerms,
+		private:        true,
+		uffdWP:         wp,
+	})
+	return nil
+}
+
+// UserfaultfdWriteProtect sets or clears write protection of pages in ar,
+// which must be in vmas registered with uffd in UFFDIO_REGISTER_MODE_WP. See
+// UFFDIO_WRITEPROTECT.
+func (mm *MemoryManager) UserfaultfdWriteProtect(uffd Userfaultfd, ar hostarch.AddrRange, protect bool) error {
+	mm.mappingMu.RLock()
+	defer mm.mappingMu.RUnlock()
+	// All of ar must be covered by vmas registered for write protection.
+	vseg := mm.vmas.FindSegment(ar.Start)
+	for {
+		if !vseg.Ok() {
+			return linuxerr.ENOENT
+		}
+		if vma := vseg.ValuePtr(); vma.uffd != uffd || vma.uffdMode&linux.UFFDIO_REGISTER_MODE_WP == 0 {
+			return linuxerr.ENOENT
+		}
+		if ar.End <= vseg.End() {
+			break
+		}
+		next := vseg.NextSegment()
+		if next.Ok() && next.Start() != vseg.End() {
+			return linuxerr.ENOENT
+		}
+		vseg = next
+	}
+
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+	if !protect {
+		mm.clearUserfaultfdWPLocked(ar)
+		return nil
+	}
+	var didUnmapAS bool
+	for pseg := mm.pmas.LowerBoundSegment(ar.Start); pseg.Ok() && pseg.Start() < ar.End; pseg = pseg.NextSegment() {
+		pseg = mm.pmas.Isolate(pseg, ar)
+		pseg.ValuePtr().uffdWP = true
+		if !didUnmapAS {
+			// Remove writable AddressSpace mappings.
+			mm.unmapASLocked(ar)
+			didUnmapAS = true
+		}
+	}
+	mm.pmas.MergeInsideRange(ar)
+	mm.pmas.MergeOutsideRange(ar)
+	return nil
+}
+
+// userfaultfdsLocked returns userfaultfds with feature that vmas in ar are
+// registered with.
+//
+// Preconditions: mm.mappingMu must be locked.
+func (mm *MemoryManager) userfaultfdsLocked(ar hostarch.AddrRange, feature uint64) []Userfaultfd {
+	var uffds []Userfaultfd
+	for vseg := mm.vmas.LowerBoundSegment(ar.Start); vseg.Ok() && vseg.Start() < ar.End; vseg = vseg.NextSegment() {
+		uffd := vseg.ValuePtr().uffd
+		if uffd == nil || uffd.Features()&feature == 0 {
+			continue
+		}
+		if n := len(uffds); n == 0 || uffds[n-1] != uffd {
+			uffds = append(uffds, uffd)
+		}
+	}
+	return uffds
+}
+
+// userfaultfdUnmap is a UFFD_EVENT_UNMAP of ar for uffd.
+type userfaultfdUnmap struct {
+	uffd Userfaultfd
+	ar   hostarch.AddrRange
+}
+
+// userfaultfdUnmaps collects UFFD_EVENT_UNMAPs for ranges that are unmapped
+// while mm.mappingMu is locked. They are reported by notify after
+// mm.mappingMu is unlocked, since reporting blocks until the event is read.
+type userfaultfdUnmaps []userfaultfdUnmap
+
+// addLocked adds UFFD_EVENT_UNMAPs of ar for userfaultfds that vmas in ar are
+// registered with. It must be called before ar is unmapped.
+//
+// Preconditions: mm.mappingMu must be locked.
+func (u *userfaultfdUnmaps) addLocked(mm *MemoryManager, ar hostarch.AddrRange) {
+	for _, uffd := range mm.userfaultfdsLocked(ar, linux.UFFD_FEATURE_EVENT_UNMAP) {
+		*u = append(*u, userfaultfdUnmap{uffd: uffd, ar: ar})
+	}
+}
+
+// notify reports the collected UFFD_EVENT_UNMAPs.
+//
+// Preconditions: mm locks are not held.
+func (u userfaultfdUnmaps) notify(ctx context.Context) {
+	for _, unmap := range u {
+		unmap.uffd.NotifyUnmap(ctx, unmap.ar)
+	}
+}
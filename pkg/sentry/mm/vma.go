@@ -455,6 +455,7 @@ func (vmaSetFunctions) ClearValue(vma *vma) {
 	vma.mappable = nil
 	vma.id = nil
 	vma.hint = ""
+	vma.uffd = nil
 	atomic.StoreUintptr(&vma.lastFault, 0)
 }
 
@@ -470,7 +471,9 @@ func (vmaSetFunctions) Merge(ar1 hostarch.AddrRange, vma1 vma, ar2 hostarch.Addr
 		vma1.numaNodemask != vma2.numaNodemask ||
 		vma1.dontfork != vma2.dontfork ||
 		vma1.id != vma2.id ||
-		vma1.hint != vma2.hint {
+		vma1.hint != vma2.hint ||
+		vma1.uffd != vma2.uffd ||
+		vma1.uffdMode != vma2.uffdMode {
 		return vma{}, false
 	}
 
@@ -30,6 +30,7 @@ const (
 	PointExecve
 	PointExitNotifyParent
 	PointTaskExit
+	PointJsCallback
 
 	// Add new Points above this line.
 	pointLengthBeforeSyscalls
@@ -286,6 +287,11 @@ func genericInit() {
 		Name:          "sentry/task_exit",
 		ContextFields: defaultContextFields,
 	})
+	registerPoint(PointDesc{
+		ID:            PointJsCallback,
+		Name:          "sentry/js_callback",
+		ContextFields: defaultContextFields,
+	})
 }
 
 var initOnce sync.Once
@@ -108,6 +108,7 @@ type Sink interface {
 	Execve(ctx context.Context, fields FieldSet, info *pb.ExecveInfo) error
 	ExitNotifyParent(ctx context.Context, fields FieldSet, info *pb.ExitNotifyParentInfo) error
 	TaskExit(context.Context, FieldSet, *pb.TaskExit) error
+	JsCallback(context.Context, FieldSet, *pb.JsCallback) error
 
 	ContainerStart(context.Context, FieldSet, *pb.Start) error
 
@@ -169,6 +170,11 @@ func (SinkDefaults) TaskExit(context.Context, FieldSet, *pb.TaskExit) error {
 	return nil
 }
 
+// JsCallback implements Sink.JsCallback.
+func (SinkDefaults) JsCallback(context.Context, FieldSet, *pb.JsCallback) error {
+	return nil
+}
+
 // RawSyscall implements Sink.RawSyscall.
 func (SinkDefaults) RawSyscall(context.Context, FieldSet, *pb.Syscall) error {
 	return nil
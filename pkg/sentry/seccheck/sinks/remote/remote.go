@@ -265,6 +265,12 @@ func (r *remote) TaskExit(_ context.Context, _ seccheck.FieldSet, info *pb.TaskE
 	return nil
 }
 
+// JsCallback implements seccheck.Sink.
+func (r *remote) JsCallback(_ context.Context, _ seccheck.FieldSet, info *pb.JsCallback) error {
+	r.write(info, pb.MessageType_MESSAGE_SENTRY_JS_CALLBACK)
+	return nil
+}
+
 // ContainerStart implements seccheck.Sink.
 func (r *remote) ContainerStart(_ context.Context, _ seccheck.FieldSet, info *pb.Start) error {
 	r.write(info, pb.MessageType_MESSAGE_CONTAINER_START)
@@ -704,6 +704,23 @@ func (s *Socket) sendResponse(ctx context.Context, ms *MessageSet) *syserr.Error
 	return nil
 }
 
+// SendKernelMessage sends buf from the kernel to userspace without netlink
+// headers. It's used to deliver mq_notify(2) cookies to the socket.
+func (s *Socket) SendKernelMessage(ctx context.Context, buf []byte) *syserr.Error {
+	cms := transport.ControlMessages{
+		Credentials: kernelCreds,
+	}
+	_, notify, err := s.connection.Send(ctx, [][]byte{buf}, cms, transport.Address{})
+	// If the buffer is full, the message is dropped, like in sendResponse.
+	if err != nil && err != syserr.ErrWouldBlock {
+		return err
+	}
+	if notify {
+		s.connection.SendNotify()
+	}
+	return nil
+}
+
 func dumpErrorMessage(hdr linux.NetlinkMessageHeader, ms *MessageSet, err *syserr.Error) {
 	m := ms.AddMessage(linux.NetlinkMessageHeader{
 		Type: linux.NLMSG_ERROR,
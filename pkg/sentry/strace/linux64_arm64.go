@@ -315,6 +315,10 @@ var linuxARM64 = SyscallMap{
 	435: makeSyscallInfo("clone3", Hex, Hex),
 	436: makeSyscallInfo("close_range", FD, FD, CloseRangeFlags),
 	441: makeSyscallInfo("epoll_pwait2", FD, EpollEvents, Hex, Timespec, SigSet),
+	442: makeSyscallInfo("mount_setattr", FD, Path, Hex, Hex, Hex),
+	444: makeSyscallInfo("landlock_create_ruleset", Hex, Hex, Hex),
+	445: makeSyscallInfo("landlock_add_rule", FD, Hex, Hex, Hex),
+	446: makeSyscallInfo("landlock_restrict_self", FD, Hex),
 }
 
 func init() {
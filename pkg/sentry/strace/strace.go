@@ -559,7 +559,10 @@ func (i *SyscallInfo) printEnter(t *kernel.Task, args arch.SyscallArguments) []s
 		Syscallname: i.name,
 		Output:      output, //toJSONEnum(output),
 	}
-	t.JSONInfof(straceLog.ToString())
+	if regs, err := t.Arch().RegisterMap(); err == nil {
+		straceLog.Regs = regs
+	}
+	t.JSONInfo(straceLog.ToString())
 	t.Infof(straceLog.GVisorString())
 	return output
 }
@@ -581,7 +584,7 @@ func (i *SyscallInfo) printExit(t *kernel.Task, elapsed time.Duration, output []
 	straceLog.Rval.Err = fmt.Sprintf("%s", err)
 	straceLog.Rval.Errno = fmt.Sprintf("%d", errno)
 	straceLog.Rval.Elapsed = fmt.Sprintf("%v", elapsed)
-	t.JSONInfof(straceLog.ToString())
+	t.JSONInfo(straceLog.ToString())
 	t.Infof(straceLog.GVisorString())
 }
 
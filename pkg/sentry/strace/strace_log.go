@@ -19,6 +19,9 @@ type straceJSONLog struct {
 	Syscallname string
 	Output      []string
 	Rval        RvalJSONLog
+
+	// Regs are user registers of the task on syscall entry
+	Regs map[string]uintptr `json:",omitempty"`
 }
 
 func (s *straceJSONLog) ToString() string {
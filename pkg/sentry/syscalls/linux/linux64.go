@@ -108,7 +108,7 @@ var AMD64 = &kernel.SyscallTable{
 		53:  syscalls.SupportedPoint("socketpair", SocketPair, PointSocketpair),
 		54:  syscalls.Supported("setsockopt", SetSockOpt),
 		55:  syscalls.Supported("getsockopt", GetSockOpt),
-		56:  syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_PARENT, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
+		56:  syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PARENT, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
 		57:  syscalls.SupportedPoint("fork", Fork, PointFork),
 		58:  syscalls.SupportedPoint("vfork", Vfork, PointVfork),
 		59:  syscalls.SupportedPoint("execve", Execve, PointExecve),
@@ -294,10 +294,10 @@ var AMD64 = &kernel.SyscallTable{
 		239: syscalls.PartiallySupported("get_mempolicy", GetMempolicy, "Stub implementation.", nil),
 		240: syscalls.Supported("mq_open", MqOpen),
 		241: syscalls.Supported("mq_unlink", MqUnlink),
-		242: syscalls.ErrorWithEvent("mq_timedsend", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}),    // TODO(b/29354921)
-		243: syscalls.ErrorWithEvent("mq_timedreceive", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}), // TODO(b/29354921)
-		244: syscalls.ErrorWithEvent("mq_notify", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}),       // TODO(b/29354921)
-		245: syscalls.ErrorWithEvent("mq_getsetattr", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}),   // TODO(b/29354921)
+		242: syscalls.Supported("mq_timedsend", MqTimedsend),
+		243: syscalls.Supported("mq_timedreceive", MqTimedreceive),
+		244: syscalls.Supported("mq_notify", MqNotify),
+		245: syscalls.Supported("mq_getsetattr", MqGetsetattr),
 		246: syscalls.CapError("kexec_load", linux.CAP_SYS_BOOT, "", nil),
 		247: syscalls.Supported("waitid", Waitid),
 		248: syscalls.Error("add_key", linuxerr.EACCES, "Not available to user.", nil),
@@ -324,7 +324,7 @@ var AMD64 = &kernel.SyscallTable{
 		269: syscalls.Supported("faccessat", Faccessat),
 		270: syscalls.Supported("pselect6", Pselect6),
 		271: syscalls.Supported("ppoll", Ppoll),
-		272: syscalls.PartiallySupported("unshare", Unshare, "Mount, cgroup namespaces not supported. Network namespaces supported but must be empty.", nil),
+		272: syscalls.PartiallySupported("unshare", Unshare, "Network namespaces supported but must be empty.", nil),
 		273: syscalls.Supported("set_robust_list", SetRobustList),
 		274: syscalls.Supported("get_robust_list", GetRobustList),
 		275: syscalls.Supported("splice", Splice),
@@ -352,8 +352,8 @@ var AMD64 = &kernel.SyscallTable{
 		297: syscalls.Supported("rt_tgsigqueueinfo", RtTgsigqueueinfo),
 		298: syscalls.ErrorWithEvent("perf_event_open", linuxerr.ENODEV, "No support for perf counters", nil),
 		299: syscalls.Supported("recvmmsg", RecvMMsg),
-		300: syscalls.ErrorWithEvent("fanotify_init", linuxerr.ENOSYS, "Needs CONFIG_FANOTIFY", nil),
-		301: syscalls.ErrorWithEvent("fanotify_mark", linuxerr.ENOSYS, "Needs CONFIG_FANOTIFY", nil),
+		300: syscalls.PartiallySupported("fanotify_init", FanotifyInit, "Reporting file handles (FAN_REPORT_FID) is not supported.", nil),
+		301: syscalls.PartiallySupported("fanotify_mark", FanotifyMark, "Only access, modify, close and open events are supported. FAN_EVENT_ON_CHILD has no effect.", nil),
 		302: syscalls.SupportedPoint("prlimit64", Prlimit64, PointPrlimit64),
 		303: syscalls.Error("name_to_handle_at", linuxerr.EOPNOTSUPP, "Not supported by gVisor filesystems", nil),
 		304: syscalls.Error("open_by_handle_at", linuxerr.EOPNOTSUPP, "Not supported by gVisor filesystems", nil),
@@ -375,7 +375,7 @@ var AMD64 = &kernel.SyscallTable{
 		320: syscalls.CapError("kexec_file_load", linux.CAP_SYS_BOOT, "", nil),
 		321: syscalls.CapError("bpf", linux.CAP_SYS_ADMIN, "", nil),
 		322: syscalls.SupportedPoint("execveat", Execveat, PointExecveat),
-		323: syscalls.ErrorWithEvent("userfaultfd", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/266"}), // TODO(b/118906345)
+		323: syscalls.PartiallySupported("userfaultfd", Userfaultfd, "Only private anonymous memory can be registered. Small anonymous mappings are populated when they are created, so their pages are never missing.", nil),
 		324: syscalls.PartiallySupported("membarrier", Membarrier, "Not supported on all platforms.", nil),
 		325: syscalls.PartiallySupported("mlock2", Mlock2, "Stub implementation. The sandbox lacks appropriate permissions.", nil),
 
@@ -392,21 +392,26 @@ var AMD64 = &kernel.SyscallTable{
 		334: syscalls.PartiallySupported("rseq", RSeq, "Not supported on all platforms.", nil),
 
 		// Linux skips ahead to syscall 424 to sync numbers between arches.
-		424: syscalls.ErrorWithEvent("pidfd_send_signal", linuxerr.ENOSYS, "", nil),
+		424: syscalls.Supported("pidfd_send_signal", PidfdSendSignal),
 		425: syscalls.PartiallySupported("io_uring_setup", IOUringSetup, "Not all flags and functionality supported.", nil),
 		426: syscalls.PartiallySupported("io_uring_enter", IOUringEnter, "Not all flags and functionality supported.", nil),
 		427: syscalls.ErrorWithEvent("io_uring_register", linuxerr.ENOSYS, "", nil),
-		428: syscalls.ErrorWithEvent("open_tree", linuxerr.ENOSYS, "", nil),
-		429: syscalls.ErrorWithEvent("move_mount", linuxerr.ENOSYS, "", nil),
-		430: syscalls.ErrorWithEvent("fsopen", linuxerr.ENOSYS, "", nil),
-		431: syscalls.ErrorWithEvent("fsconfig", linuxerr.ENOSYS, "", nil),
-		432: syscalls.ErrorWithEvent("fsmount", linuxerr.ENOSYS, "", nil),
-		433: syscalls.ErrorWithEvent("fspick", linuxerr.ENOSYS, "", nil),
-		434: syscalls.ErrorWithEvent("pidfd_open", linuxerr.ENOSYS, "", nil),
-		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_INTO_CGROUP, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and, SetTid are not supported.", nil),
+		428: syscalls.Supported("open_tree", OpenTree),
+		429: syscalls.PartiallySupported("move_mount", MoveMount, "MOVE_MOUNT_SET_GROUP and MOVE_MOUNT_BENEATH are not supported.", nil),
+		430: syscalls.Supported("fsopen", Fsopen),
+		431: syscalls.PartiallySupported("fsconfig", Fsconfig, "Only flag and string parameters are supported.", nil),
+		432: syscalls.PartiallySupported("fsmount", Fsmount, "MOUNT_ATTR_NODIRATIME is not supported.", nil),
+		433: syscalls.Supported("fspick", Fspick),
+		434: syscalls.Supported("pidfd_open", PidfdOpen),
+		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and, SetTid are not supported.", nil),
 		436: syscalls.Supported("close_range", CloseRange),
+		438: syscalls.Supported("pidfd_getfd", PidfdGetfd),
 		439: syscalls.Supported("faccessat2", Faccessat2),
 		441: syscalls.Supported("epoll_pwait2", EpollPwait2),
+		442: syscalls.PartiallySupported("mount_setattr", MountSetattr, "MOUNT_ATTR_IDMAP, MOUNT_ATTR_NODIRATIME and MOUNT_ATTR_NOSYMFOLLOW are not supported.", nil),
+		444: syscalls.PartiallySupported("landlock_create_ruleset", LandlockCreateRuleset, "Restrictions on ptrace(2) between domains are not enforced.", nil),
+		445: syscalls.Supported("landlock_add_rule", LandlockAddRule),
+		446: syscalls.Supported("landlock_restrict_self", LandlockRestrictSelf),
 	},
 	Emulate: map[hostarch.Addr]uintptr{
 		0xffffffffff600000: 96,  // vsyscall gettimeofday(2)
@@ -528,7 +533,7 @@ var ARM64 = &kernel.SyscallTable{
 		94:  syscalls.Supported("exit_group", ExitGroup),
 		95:  syscalls.Supported("waitid", Waitid),
 		96:  syscalls.Supported("set_tid_address", SetTidAddress),
-		97:  syscalls.PartiallySupported("unshare", Unshare, "Mount, cgroup namespaces not supported. Network namespaces supported but must be empty.", nil),
+		97:  syscalls.PartiallySupported("unshare", Unshare, "Network namespaces supported but must be empty.", nil),
 		98:  syscalls.PartiallySupported("futex", Futex, "Robust futexes not supported.", nil),
 		99:  syscalls.Supported("set_robust_list", SetRobustList),
 		100: syscalls.Supported("get_robust_list", GetRobustList),
@@ -613,10 +618,10 @@ var ARM64 = &kernel.SyscallTable{
 		179: syscalls.PartiallySupported("sysinfo", Sysinfo, "Fields loads, sharedram, bufferram, totalswap, freeswap, totalhigh, freehigh not supported.", nil),
 		180: syscalls.Supported("mq_open", MqOpen),
 		181: syscalls.Supported("mq_unlink", MqUnlink),
-		182: syscalls.ErrorWithEvent("mq_timedsend", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}),    // TODO(b/29354921)
-		183: syscalls.ErrorWithEvent("mq_timedreceive", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}), // TODO(b/29354921)
-		184: syscalls.ErrorWithEvent("mq_notify", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}),       // TODO(b/29354921)
-		185: syscalls.ErrorWithEvent("mq_getsetattr", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/136"}),   // TODO(b/29354921)
+		182: syscalls.Supported("mq_timedsend", MqTimedsend),
+		183: syscalls.Supported("mq_timedreceive", MqTimedreceive),
+		184: syscalls.Supported("mq_notify", MqNotify),
+		185: syscalls.Supported("mq_getsetattr", MqGetsetattr),
 		186: syscalls.Supported("msgget", Msgget),
 		187: syscalls.Supported("msgctl", Msgctl),
 		188: syscalls.Supported("msgrcv", Msgrcv),
@@ -651,7 +656,7 @@ var ARM64 = &kernel.SyscallTable{
 		217: syscalls.Error("add_key", linuxerr.EACCES, "Not available to user.", nil),
 		218: syscalls.Error("request_key", linuxerr.EACCES, "Not available to user.", nil),
 		219: syscalls.PartiallySupported("keyctl", Keyctl, "Only supports session keyrings with zero keys in them.", nil),
-		220: syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_PARENT, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
+		220: syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PARENT, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
 		221: syscalls.SupportedPoint("execve", Execve, PointExecve),
 		222: syscalls.Supported("mmap", Mmap),
 		223: syscalls.PartiallySupported("fadvise64", Fadvise64, "Not all options are supported.", nil),
@@ -677,8 +682,8 @@ var ARM64 = &kernel.SyscallTable{
 		243: syscalls.Supported("recvmmsg", RecvMMsg),
 		260: syscalls.Supported("wait4", Wait4),
 		261: syscalls.SupportedPoint("prlimit64", Prlimit64, PointPrlimit64),
-		262: syscalls.ErrorWithEvent("fanotify_init", linuxerr.ENOSYS, "Needs CONFIG_FANOTIFY", nil),
-		263: syscalls.ErrorWithEvent("fanotify_mark", linuxerr.ENOSYS, "Needs CONFIG_FANOTIFY", nil),
+		262: syscalls.PartiallySupported("fanotify_init", FanotifyInit, "Reporting file handles (FAN_REPORT_FID) is not supported.", nil),
+		263: syscalls.PartiallySupported("fanotify_mark", FanotifyMark, "Only access, modify, close and open events are supported. FAN_EVENT_ON_CHILD has no effect.", nil),
 		264: syscalls.Error("name_to_handle_at", linuxerr.EOPNOTSUPP, "Not supported by gVisor filesystems", nil),
 		265: syscalls.Error("open_by_handle_at", linuxerr.EOPNOTSUPP, "Not supported by gVisor filesystems", nil),
 		266: syscalls.CapError("clock_adjtime", linux.CAP_SYS_TIME, "", nil),
@@ -697,7 +702,7 @@ var ARM64 = &kernel.SyscallTable{
 		279: syscalls.Supported("memfd_create", MemfdCreate),
 		280: syscalls.CapError("bpf", linux.CAP_SYS_ADMIN, "", nil),
 		281: syscalls.SupportedPoint("execveat", Execveat, PointExecveat),
-		282: syscalls.ErrorWithEvent("userfaultfd", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/266"}), // TODO(b/118906345)
+		282: syscalls.PartiallySupported("userfaultfd", Userfaultfd, "Only private anonymous memory can be registered. Small anonymous mappings are populated when they are created, so their pages are never missing.", nil),
 		283: syscalls.PartiallySupported("membarrier", Membarrier, "Not supported on all platforms.", nil),
 		284: syscalls.PartiallySupported("mlock2", Mlock2, "Stub implementation. The sandbox lacks appropriate permissions.", nil),
 
@@ -713,21 +718,26 @@ var ARM64 = &kernel.SyscallTable{
 		293: syscalls.PartiallySupported("rseq", RSeq, "Not supported on all platforms.", nil),
 
 		// Linux skips ahead to syscall 424 to sync numbers between arches.
-		424: syscalls.ErrorWithEvent("pidfd_send_signal", linuxerr.ENOSYS, "", nil),
+		424: syscalls.Supported("pidfd_send_signal", PidfdSendSignal),
 		425: syscalls.PartiallySupported("io_uring_setup", IOUringSetup, "Not all flags and functionality supported.", nil),
 		426: syscalls.PartiallySupported("io_uring_enter", IOUringEnter, "Not all flags and functionality supported.", nil),
 		427: syscalls.ErrorWithEvent("io_uring_register", linuxerr.ENOSYS, "", nil),
-		428: syscalls.ErrorWithEvent("open_tree", linuxerr.ENOSYS, "", nil),
-		429: syscalls.ErrorWithEvent("move_mount", linuxerr.ENOSYS, "", nil),
-		430: syscalls.ErrorWithEvent("fsopen", linuxerr.ENOSYS, "", nil),
-		431: syscalls.ErrorWithEvent("fsconfig", linuxerr.ENOSYS, "", nil),
-		432: syscalls.ErrorWithEvent("fsmount", linuxerr.ENOSYS, "", nil),
-		433: syscalls.ErrorWithEvent("fspick", linuxerr.ENOSYS, "", nil),
-		434: syscalls.ErrorWithEvent("pidfd_open", linuxerr.ENOSYS, "", nil),
-		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_INTO_CGROUP, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and clone_args.set_tid are not supported.", nil),
+		428: syscalls.Supported("open_tree", OpenTree),
+		429: syscalls.PartiallySupported("move_mount", MoveMount, "MOVE_MOUNT_SET_GROUP and MOVE_MOUNT_BENEATH are not supported.", nil),
+		430: syscalls.Supported("fsopen", Fsopen),
+		431: syscalls.PartiallySupported("fsconfig", Fsconfig, "Only flag and string parameters are supported.", nil),
+		432: syscalls.PartiallySupported("fsmount", Fsmount, "MOUNT_ATTR_NODIRATIME is not supported.", nil),
+		433: syscalls.Supported("fspick", Fspick),
+		434: syscalls.Supported("pidfd_open", PidfdOpen),
+		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and clone_args.set_tid are not supported.", nil),
 		436: syscalls.Supported("close_range", CloseRange),
+		438: syscalls.Supported("pidfd_getfd", PidfdGetfd),
 		439: syscalls.Supported("faccessat2", Faccessat2),
 		441: syscalls.Supported("epoll_pwait2", EpollPwait2),
+		442: syscalls.PartiallySupported("mount_setattr", MountSetattr, "MOUNT_ATTR_IDMAP, MOUNT_ATTR_NODIRATIME and MOUNT_ATTR_NOSYMFOLLOW are not supported.", nil),
+		444: syscalls.PartiallySupported("landlock_create_ruleset", LandlockCreateRuleset, "Restrictions on ptrace(2) between domains are not enforced.", nil),
+		445: syscalls.Supported("landlock_add_rule", LandlockAddRule),
+		446: syscalls.Supported("landlock_restrict_self", LandlockRestrictSelf),
 	},
 	Emulate: map[hostarch.Addr]uintptr{},
 	Missing: func(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, error) {
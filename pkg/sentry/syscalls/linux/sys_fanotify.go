@@ -0,0 +1,148 @@
+package linux
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/fanotify"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+)
+
+const (
+	// fanotifyInitFlags are supported flags of fanotify_init(2).
+	fanotifyInitFlags = linux.FAN_CLOEXEC | linux.FAN_NONBLOCK | linux.FAN_ALL_CLASS_BITS |
+		linux.FAN_UNLIMITED_QUEUE | linux.FAN_UNLIMITED_MARKS | linux.FAN_REPORT_TID
+
+	// fanotifyEventFlags are status flags allowed for files of events.
+	fanotifyEventFlags = linux.O_APPEND | linux.O_NONBLOCK | linux.O_SYNC | linux.O_DSYNC |
+		linux.O_CLOEXEC | linux.O_LARGEFILE | linux.O_NOATIME
+
+	// fanotifyMarkFlags are supported flags of fanotify_mark(2).
+	fanotifyMarkFlags = linux.FAN_MARK_ADD | linux.FAN_MARK_REMOVE | linux.FAN_MARK_FLUSH |
+		linux.FAN_MARK_DONT_FOLLOW | linux.FAN_MARK_ONLYDIR | linux.FAN_MARK_IGNORED_MASK |
+		linux.FAN_MARK_IGNORED_SURV_MODIFY | linux.FAN_MARK_TYPE_MASK
+)
+
+// FanotifyInit implements linux syscall fanotify_init(2).
+func FanotifyInit(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	flags := args[0].Uint()
+	eventFlags := args[1].Uint()
+
+	if !t.HasCapabilityIn(linux.CAP_SYS_ADMIN, t.Kernel().RootUserNamespace()) {
+		return 0, nil, linuxerr.EPERM
+	}
+	if flags&^fanotifyInitFlags != 0 || flags&linux.FAN_ALL_CLASS_BITS == linux.FAN_ALL_CLASS_BITS {
+		return 0, nil, linuxerr.EINVAL
+	}
+	switch eventFlags & linux.O_ACCMODE {
+	case linux.O_RDONLY, linux.O_WRONLY, linux.O_RDWR:
+	default:
+		return 0, nil, linuxerr.EINVAL
+	}
+	if eventFlags&^(linux.O_ACCMODE|fanotifyEventFlags) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	file, err := fanotify.New(t, t.Kernel().VFS(), flags, eventFlags)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{
+		CloseOnExec: flags&linux.FAN_CLOEXEC != 0,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// FanotifyMark implements linux syscall fanotify_mark(2).
+func FanotifyMark(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fd := args[0].Int()
+	flags := args[1].Uint()
+	mask := args[2].Uint64()
+	dirfd := args[3].Int()
+	addr := args[4].Pointer()
+
+	if flags&^fanotifyMarkFlags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	markType := flags & linux.FAN_MARK_TYPE_MASK
+	switch markType {
+	case linux.FAN_MARK_INODE, linux.FAN_MARK_MOUNT, linux.FAN_MARK_FILESYSTEM:
+	default:
+		return 0, nil, linuxerr.EINVAL
+	}
+	op := flags & (linux.FAN_MARK_ADD | linux.FAN_MARK_REMOVE | linux.FAN_MARK_FLUSH)
+	switch op {
+	case linux.FAN_MARK_ADD, linux.FAN_MARK_REMOVE:
+		if mask == 0 {
+			return 0, nil, linuxerr.EINVAL
+		}
+	case linux.FAN_MARK_FLUSH:
+		if flags&^(linux.FAN_MARK_FLUSH|linux.FAN_MARK_TYPE_MASK) != 0 {
+			return 0, nil, linuxerr.EINVAL
+		}
+	default:
+		return 0, nil, linuxerr.EINVAL
+	}
+	if mask&^uint64(fanotify.Events|fanotify.MarkFlags) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	file := t.GetFile(fd)
+	if file == nil {
+		return 0, nil, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+	g, ok := file.Impl().(*fanotify.Group)
+	if !ok {
+		return 0, nil, linuxerr.EINVAL
+	}
+	// Permission events are allowed only in FAN_CLASS_CONTENT and
+	// FAN_CLASS_PRE_CONTENT groups.
+	if mask&fanotify.PermEvents != 0 && !g.AllowsPermEvents() {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	if op == linux.FAN_MARK_FLUSH {
+		g.FlushMarks(t, markType)
+		return 0, nil, nil
+	}
+
+	// "If pathname is NULL, the filesystem object to be marked is determined
+	// by the file descriptor dirfd." - fanotify_mark(2)
+	var path fspath.Path
+	if addr != 0 {
+		var err error
+		if path, err = copyInPath(t, addr); err != nil {
+			return 0, nil, err
+		}
+	}
+	if flags&linux.FAN_MARK_ONLYDIR != 0 {
+		path.Dir = true
+	}
+	follow := followFinalSymlink
+	if flags&linux.FAN_MARK_DONT_FOLLOW != 0 {
+		follow = nofollowFinalSymlink
+	}
+	tpop, err := getTaskPathOperation(t, dirfd, path, shouldAllowEmptyPath(addr == 0), follow)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tpop.Release(t)
+	vd, err := t.Kernel().VFS().GetDentryAt(t, t.Credentials(), &tpop.pop, &vfs.GetDentryOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer vd.DecRef(t)
+
+	if op == linux.FAN_MARK_ADD {
+		return 0, nil, g.AddMark(vd, markType, flags, mask)
+	}
+	return 0, nil, g.RemoveMark(t, vd, markType, flags, mask)
+}
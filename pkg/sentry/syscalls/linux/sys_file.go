@@ -402,6 +402,9 @@ func PivotRoot(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintp
 	if !t.HasCapability(linux.CAP_SYS_ADMIN) {
 		return 0, nil, linuxerr.EPERM
 	}
+	if err := checkLandlockMount(t); err != nil {
+		return 0, nil, err
+	}
 
 	newRootPath, err := copyInPath(t, addr1)
 	if err != nil {
@@ -1229,6 +1232,9 @@ func Ftruncate(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintp
 	if !file.IsWritable() {
 		return 0, nil, linuxerr.EINVAL
 	}
+	if file.LandlockDeniesTruncate() {
+		return 0, nil, linuxerr.EACCES
+	}
 
 	err := file.SetStat(t, vfs.SetStatOptions{
 		Stat: linux.Statx{
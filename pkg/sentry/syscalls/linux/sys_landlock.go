@@ -0,0 +1,225 @@
+package linux
+
+import (
+	"encoding/binary"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/socket"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+)
+
+// landlockABIVersion is the version of the Landlock ABI that we implement,
+// reported by landlock_create_ruleset(2) with
+// LANDLOCK_CREATE_RULESET_VERSION.
+const landlockABIVersion = 4
+
+// LandlockCreateRuleset implements Linux syscall landlock_create_ruleset(2).
+func LandlockCreateRuleset(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	attrAddr := args[0].Pointer()
+	size := args[1].SizeT()
+	flags := args[2].Uint()
+
+	if flags != 0 {
+		if flags == linux.LANDLOCK_CREATE_RULESET_VERSION && attrAddr == 0 && size == 0 {
+			return landlockABIVersion, nil, nil
+		}
+		return 0, nil, linuxerr.EINVAL
+	}
+	if attrAddr == 0 {
+		return 0, nil, linuxerr.EFAULT
+	}
+	// The first version of struct landlock_ruleset_attr only had
+	// handled_access_fs.
+	if size < 8 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if size > hostarch.PageSize {
+		return 0, nil, linuxerr.E2BIG
+	}
+
+	// Copy in the known part of the struct, zero-extended if size is
+	// smaller; extensions that we don't know about must be zero.
+	var attr linux.LandlockRulesetAttr
+	buf := make([]byte, attr.SizeBytes())
+	n := min(size, uint(len(buf)))
+	if _, err := t.CopyInBytes(attrAddr, buf[:n]); err != nil {
+		return 0, nil, err
+	}
+	attr.UnmarshalUnsafe(buf)
+	if size > n {
+		rest := make([]byte, size-n)
+		if _, err := t.CopyInBytes(attrAddr+hostarch.Addr(n), rest); err != nil {
+			return 0, nil, err
+		}
+		for _, b := range rest {
+			if b != 0 {
+				return 0, nil, linuxerr.E2BIG
+			}
+		}
+	}
+
+	if attr.HandledAccessFS&^vfs.LandlockAccessFS != 0 || attr.HandledAccessNet&^vfs.LandlockAccessNet != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	// A ruleset that doesn't restrict anything is useless.
+	if attr.HandledAccessFS == 0 && attr.HandledAccessNet == 0 {
+		return 0, nil, linuxerr.ENOMSG
+	}
+
+	file, err := t.Kernel().VFS().NewLandlockRulesetFD(t, attr.HandledAccessFS, attr.HandledAccessNet)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{
+		CloseOnExec: true,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// getLandlockRuleset returns the Landlock ruleset represented by fd, which
+// must have been opened with the given access. A reference is taken on the
+// returned FileDescription.
+func getLandlockRuleset(t *kernel.Task, fd int32, read, write bool) (*vfs.FileDescription, *vfs.LandlockRuleset, error) {
+	file := t.GetFile(fd)
+	if file == nil {
+		return nil, nil, linuxerr.EBADF
+	}
+	rs, ok := file.Impl().(*vfs.LandlockRuleset)
+	if !ok {
+		file.DecRef(t)
+		return nil, nil, linuxerr.EBADFD
+	}
+	if (read && !file.IsReadable()) || (write && !file.IsWritable()) {
+		file.DecRef(t)
+		return nil, nil, linuxerr.EPERM
+	}
+	return file, rs, nil
+}
+
+// LandlockAddRule implements Linux syscall landlock_add_rule(2).
+func LandlockAddRule(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	rulesetFD := args[0].Int()
+	ruleType := args[1].Int()
+	attrAddr := args[2].Pointer()
+	flags := args[3].Uint()
+
+	if flags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	file, rs, err := getLandlockRuleset(t, rulesetFD, false /* read */, true /* write */)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	switch ruleType {
+	case linux.LANDLOCK_RULE_PATH_BENEATH:
+		var attr linux.LandlockPathBeneathAttr
+		if _, err := attr.CopyIn(t, attrAddr); err != nil {
+			return 0, nil, err
+		}
+		parent := t.GetFile(attr.ParentFD)
+		if parent == nil {
+			return 0, nil, linuxerr.EBADF
+		}
+		defer parent.DecRef(t)
+		stat, err := parent.Stat(t, vfs.StatOptions{Mask: linux.STATX_TYPE})
+		if err != nil {
+			return 0, nil, err
+		}
+		isDir := linux.FileMode(stat.Mode).IsDir()
+		return 0, nil, rs.AddPathBeneathRule(t, parent.VirtualDentry(), isDir, attr.AllowedAccess)
+	case linux.LANDLOCK_RULE_NET_PORT:
+		var attr linux.LandlockNetPortAttr
+		if _, err := attr.CopyIn(t, attrAddr); err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, rs.AddNetPortRule(attr.AllowedAccess, attr.Port)
+	default:
+		return 0, nil, linuxerr.EINVAL
+	}
+}
+
+// LandlockRestrictSelf implements Linux syscall landlock_restrict_self(2).
+func LandlockRestrictSelf(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	rulesetFD := args[0].Int()
+	flags := args[1].Uint()
+
+	// The no_new_privs bit, required unless the caller has CAP_SYS_ADMIN, is
+	// assumed to always be set.
+	if flags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	file, rs, err := getLandlockRuleset(t, rulesetFD, true /* read */, false /* write */)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+	return 0, nil, t.RestrictLandlock(rs)
+}
+
+// landlockInet6MinLen is SIN6_LEN_RFC2133, the minimum length of a
+// struct sockaddr_in6 accepted by the kernel.
+const landlockInet6MinLen = 24
+
+// checkLandlockNetPort checks that the Landlock domain of t permits the given
+// TCP access to the port in the socket address addr, if s is a TCP socket.
+func checkLandlockNetPort(t *kernel.Task, s socket.Socket, addr []byte, access uint64) error {
+	d := t.LandlockDomain()
+	if d == nil {
+		return nil
+	}
+	family, skType, protocol := s.Type()
+	if (family != linux.AF_INET && family != linux.AF_INET6) || skType != linux.SOCK_STREAM {
+		return nil
+	}
+	if protocol != 0 && protocol != linux.IPPROTO_TCP {
+		return nil
+	}
+
+	if len(addr) < 2 {
+		return linuxerr.EINVAL
+	}
+	switch hostarch.ByteOrder.Uint16(addr) {
+	case linux.AF_UNSPEC:
+		// Disconnecting a socket is always allowed. Binding an IPv4 socket
+		// to AF_UNSPEC is treated as binding to AF_INET.
+		if access == linux.LANDLOCK_ACCESS_NET_CONNECT_TCP || family != linux.AF_INET {
+			return nil
+		}
+		fallthrough
+	case linux.AF_INET:
+		if len(addr) < (*linux.SockAddrInet)(nil).SizeBytes() {
+			return linuxerr.EINVAL
+		}
+	case linux.AF_INET6:
+		if len(addr) < landlockInet6MinLen {
+			return linuxerr.EINVAL
+		}
+	default:
+		// Let the socket report the error.
+		return nil
+	}
+	// The port is at the same offset in struct sockaddr_in and struct
+	// sockaddr_in6, in network byte order.
+	return d.CheckNetPort(access, binary.BigEndian.Uint16(addr[2:4]))
+}
+
+// checkLandlockMount returns EPERM if t is restricted by a Landlock domain
+// that handles filesystem access, since changes to the mount topology could be
+// used to bypass its rules.
+func checkLandlockMount(t *kernel.Task) error {
+	if t.LandlockDomain().HandlesFS() {
+		return linuxerr.EPERM
+	}
+	return nil
+}
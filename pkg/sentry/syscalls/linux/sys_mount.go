@@ -37,6 +37,9 @@ func Mount(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 	if !creds.HasCapabilityIn(linux.CAP_SYS_ADMIN, t.MountNamespace().Owner) {
 		return 0, nil, linuxerr.EPERM
 	}
+	if err := checkLandlockMount(t); err != nil {
+		return 0, nil, err
+	}
 
 	// Ignore magic value that was required before Linux 2.4.
 	if flags&linux.MS_MGC_MSK == linux.MS_MGC_VAL {
@@ -141,6 +144,9 @@ func Umount2(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr
 	if !creds.HasCapabilityIn(linux.CAP_SYS_ADMIN, t.MountNamespace().Owner) {
 		return 0, nil, linuxerr.EPERM
 	}
+	if err := checkLandlockMount(t); err != nil {
+		return 0, nil, err
+	}
 
 	const unsupported = linux.MNT_FORCE | linux.MNT_EXPIRE
 	if flags&unsupported != 0 {
@@ -163,3 +169,393 @@ func Umount2(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr
 
 	return 0, nil, t.Kernel().VFS().UmountAt(t, creds, &tpop.pop, &opts)
 }
+
+const (
+	// mountAttrFlags are the MOUNT_ATTR_* flags supported by fsmount(2) and
+	// mount_setattr(2).
+	mountAttrFlags = linux.MOUNT_ATTR_RDONLY | linux.MOUNT_ATTR_NOSUID | linux.MOUNT_ATTR_NODEV |
+		linux.MOUNT_ATTR_NOEXEC | linux.MOUNT_ATTR__ATIME
+
+	// moveMountFlags are the supported flags of move_mount(2).
+	moveMountFlags = linux.MOVE_MOUNT_F_SYMLINKS | linux.MOVE_MOUNT_F_AUTOMOUNTS | linux.MOVE_MOUNT_F_EMPTY_PATH |
+		linux.MOVE_MOUNT_T_SYMLINKS | linux.MOVE_MOUNT_T_AUTOMOUNTS | linux.MOVE_MOUNT_T_EMPTY_PATH
+)
+
+// checkMountCapability returns EPERM if t doesn't have CAP_SYS_ADMIN in the
+// user namespace of its mount namespace.
+func checkMountCapability(t *kernel.Task) error {
+	if !t.Credentials().HasCapabilityIn(linux.CAP_SYS_ADMIN, t.MountNamespace().Owner) {
+		return linuxerr.EPERM
+	}
+	return nil
+}
+
+// checkAtimeAttr returns EINVAL if attrs don't hold a valid atime mode.
+func checkAtimeAttr(attrs uint64) error {
+	switch attrs & linux.MOUNT_ATTR__ATIME {
+	case linux.MOUNT_ATTR_RELATIME, linux.MOUNT_ATTR_NOATIME, linux.MOUNT_ATTR_STRICTATIME:
+		return nil
+	default:
+		return linuxerr.EINVAL
+	}
+}
+
+// OpenTree implements Linux syscall open_tree(2).
+func OpenTree(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	dirfd := args[0].Int()
+	pathAddr := args[1].Pointer()
+	flags := args[2].Uint()
+
+	if flags&^(linux.OPEN_TREE_CLONE|linux.OPEN_TREE_CLOEXEC|linux.AT_EMPTY_PATH|linux.AT_NO_AUTOMOUNT|linux.AT_RECURSIVE|linux.AT_SYMLINK_NOFOLLOW) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	clone := flags&linux.OPEN_TREE_CLONE != 0
+	if flags&linux.AT_RECURSIVE != 0 && !clone {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if clone {
+		if err := checkMountCapability(t); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	path, err := copyInPath(t, pathAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	tpop, err := getTaskPathOperation(t, dirfd, path, shouldAllowEmptyPath(flags&linux.AT_EMPTY_PATH != 0), shouldFollowFinalSymlink(flags&linux.AT_SYMLINK_NOFOLLOW == 0))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tpop.Release(t)
+
+	var file *vfs.FileDescription
+	if clone {
+		file, err = t.Kernel().VFS().CloneMountTreeAt(t, t.Credentials(), &tpop.pop, flags&linux.AT_RECURSIVE != 0)
+	} else {
+		file, err = t.Kernel().VFS().OpenAt(t, t.Credentials(), &tpop.pop, &vfs.OpenOptions{
+			Flags: linux.O_PATH,
+		})
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{
+		CloseOnExec: flags&linux.OPEN_TREE_CLOEXEC != 0,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// MoveMount implements Linux syscall move_mount(2).
+func MoveMount(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fromDirfd := args[0].Int()
+	fromPathAddr := args[1].Pointer()
+	toDirfd := args[2].Int()
+	toPathAddr := args[3].Pointer()
+	flags := args[4].Uint()
+
+	if err := checkMountCapability(t); err != nil {
+		return 0, nil, err
+	}
+	if err := checkLandlockMount(t); err != nil {
+		return 0, nil, err
+	}
+	if flags&^moveMountFlags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	fromPath, err := copyInPath(t, fromPathAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	from, err := getTaskPathOperation(t, fromDirfd, fromPath, shouldAllowEmptyPath(flags&linux.MOVE_MOUNT_F_EMPTY_PATH != 0), shouldFollowFinalSymlink(flags&linux.MOVE_MOUNT_F_SYMLINKS != 0))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer from.Release(t)
+	toPath, err := copyInPath(t, toPathAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	to, err := getTaskPathOperation(t, toDirfd, toPath, shouldAllowEmptyPath(flags&linux.MOVE_MOUNT_T_EMPTY_PATH != 0), shouldFollowFinalSymlink(flags&linux.MOVE_MOUNT_T_SYMLINKS != 0))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer to.Release(t)
+
+	return 0, nil, t.Kernel().VFS().MoveMountAt(t, t.Credentials(), &from.pop, &to.pop)
+}
+
+// Fsopen implements Linux syscall fsopen(2).
+func Fsopen(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fsTypeAddr := args[0].Pointer()
+	flags := args[1].Uint()
+
+	if err := checkMountCapability(t); err != nil {
+		return 0, nil, err
+	}
+	if flags&^linux.FSOPEN_CLOEXEC != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	fsType, err := t.CopyInString(fsTypeAddr, hostarch.PageSize)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	file, err := t.Kernel().VFS().NewFSContextFD(t, fsType)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{
+		CloseOnExec: flags&linux.FSOPEN_CLOEXEC != 0,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// Fsconfig implements Linux syscall fsconfig(2).
+func Fsconfig(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fd := args[0].Int()
+	cmd := args[1].Uint()
+	keyAddr := args[2].Pointer()
+	valueAddr := args[3].Pointer()
+	aux := args[4].Int()
+
+	if fd < 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	switch cmd {
+	case linux.FSCONFIG_SET_FLAG:
+		if keyAddr == 0 || valueAddr != 0 || aux != 0 {
+			return 0, nil, linuxerr.EINVAL
+		}
+	case linux.FSCONFIG_SET_STRING:
+		if keyAddr == 0 || valueAddr == 0 || aux != 0 {
+			return 0, nil, linuxerr.EINVAL
+		}
+	case linux.FSCONFIG_SET_BINARY, linux.FSCONFIG_SET_PATH, linux.FSCONFIG_SET_PATH_EMPTY, linux.FSCONFIG_SET_FD:
+		// None of our filesystems take parameters of these types.
+		return 0, nil, linuxerr.EINVAL
+	case linux.FSCONFIG_CMD_CREATE, linux.FSCONFIG_CMD_RECONFIGURE:
+		if keyAddr != 0 || valueAddr != 0 || aux != 0 {
+			return 0, nil, linuxerr.EINVAL
+		}
+	default:
+		return 0, nil, linuxerr.EOPNOTSUPP
+	}
+
+	file := t.GetFile(fd)
+	if file == nil {
+		return 0, nil, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+	fc, ok := file.Impl().(*vfs.FSContext)
+	if !ok {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	// Linux limits parameter keys and string values to 256 bytes.
+	const maxParamLen = 256
+	switch cmd {
+	case linux.FSCONFIG_SET_FLAG:
+		key, err := t.CopyInString(keyAddr, maxParamLen)
+		if err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, fc.SetFlag(key)
+	case linux.FSCONFIG_SET_STRING:
+		key, err := t.CopyInString(keyAddr, maxParamLen)
+		if err != nil {
+			return 0, nil, err
+		}
+		value, err := t.CopyInString(valueAddr, maxParamLen)
+		if err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, fc.SetString(key, value)
+	case linux.FSCONFIG_CMD_CREATE:
+		if err := checkMountCapability(t); err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, fc.Create(t, t.Credentials())
+	default: // FSCONFIG_CMD_RECONFIGURE
+		if err := checkMountCapability(t); err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, fc.Reconfigure(t)
+	}
+}
+
+// Fsmount implements Linux syscall fsmount(2).
+func Fsmount(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fsfd := args[0].Int()
+	flags := args[1].Uint()
+	attrs := args[2].Uint64()
+
+	if err := checkMountCapability(t); err != nil {
+		return 0, nil, err
+	}
+	if flags&^linux.FSMOUNT_CLOEXEC != 0 || attrs&^mountAttrFlags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if err := checkAtimeAttr(attrs); err != nil {
+		return 0, nil, err
+	}
+
+	file := t.GetFile(fsfd)
+	if file == nil {
+		return 0, nil, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+	fc, ok := file.Impl().(*vfs.FSContext)
+	if !ok {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	opts := vfs.MountOptions{
+		Flags: vfs.MountFlags{
+			NoATime: attrs&linux.MOUNT_ATTR__ATIME == linux.MOUNT_ATTR_NOATIME,
+			NoExec:  attrs&linux.MOUNT_ATTR_NOEXEC != 0,
+			NoDev:   attrs&linux.MOUNT_ATTR_NODEV != 0,
+			NoSUID:  attrs&linux.MOUNT_ATTR_NOSUID != 0,
+		},
+		ReadOnly: attrs&linux.MOUNT_ATTR_RDONLY != 0,
+	}
+	mntFile, err := fc.Mount(t, &opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer mntFile.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, mntFile, kernel.FDFlags{
+		CloseOnExec: flags&linux.FSMOUNT_CLOEXEC != 0,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// Fspick implements Linux syscall fspick(2).
+func Fspick(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	dirfd := args[0].Int()
+	pathAddr := args[1].Pointer()
+	flags := args[2].Uint()
+
+	if err := checkMountCapability(t); err != nil {
+		return 0, nil, err
+	}
+	if flags&^(linux.FSPICK_CLOEXEC|linux.FSPICK_SYMLINK_NOFOLLOW|linux.FSPICK_NO_AUTOMOUNT|linux.FSPICK_EMPTY_PATH) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	path, err := copyInPath(t, pathAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	tpop, err := getTaskPathOperation(t, dirfd, path, shouldAllowEmptyPath(flags&linux.FSPICK_EMPTY_PATH != 0), shouldFollowFinalSymlink(flags&linux.FSPICK_SYMLINK_NOFOLLOW == 0))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tpop.Release(t)
+
+	file, err := t.Kernel().VFS().PickFSContextAt(t, t.Credentials(), &tpop.pop)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{
+		CloseOnExec: flags&linux.FSPICK_CLOEXEC != 0,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// MountSetattr implements Linux syscall mount_setattr(2).
+func MountSetattr(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	dirfd := args[0].Int()
+	pathAddr := args[1].Pointer()
+	flags := args[2].Uint()
+	attrAddr := args[3].Pointer()
+	size := args[4].SizeT()
+
+	if flags&^(linux.AT_EMPTY_PATH|linux.AT_RECURSIVE|linux.AT_SYMLINK_NOFOLLOW|linux.AT_NO_AUTOMOUNT) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if size > hostarch.PageSize {
+		return 0, nil, linuxerr.E2BIG
+	}
+	if size < linux.MOUNT_ATTR_SIZE_VER0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if err := checkMountCapability(t); err != nil {
+		return 0, nil, err
+	}
+
+	var attr linux.MountAttr
+	if _, err := attr.CopyIn(t, attrAddr); err != nil {
+		return 0, nil, err
+	}
+	// Extensions of struct mount_attr that we don't know about must be zero.
+	if size > uint(attr.SizeBytes()) {
+		rest := make([]byte, size-uint(attr.SizeBytes()))
+		if _, err := t.CopyInBytes(attrAddr+hostarch.Addr(attr.SizeBytes()), rest); err != nil {
+			return 0, nil, err
+		}
+		for _, b := range rest {
+			if b != 0 {
+				return 0, nil, linuxerr.E2BIG
+			}
+		}
+	}
+	if attr.AttrSet == 0 && attr.AttrClr == 0 && attr.Propagation == 0 {
+		return 0, nil, nil
+	}
+	switch attr.Propagation {
+	case 0, linux.MS_SHARED, linux.MS_PRIVATE, linux.MS_SLAVE, linux.MS_UNBINDABLE:
+	default:
+		return 0, nil, linuxerr.EINVAL
+	}
+	if (attr.AttrSet|attr.AttrClr)&^mountAttrFlags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if attr.AttrClr&linux.MOUNT_ATTR__ATIME != 0 {
+		if attr.AttrClr&linux.MOUNT_ATTR__ATIME != linux.MOUNT_ATTR__ATIME {
+			return 0, nil, linuxerr.EINVAL
+		}
+		if err := checkAtimeAttr(attr.AttrSet); err != nil {
+			return 0, nil, err
+		}
+	} else if attr.AttrSet&linux.MOUNT_ATTR__ATIME != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	path, err := copyInPath(t, pathAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	tpop, err := getTaskPathOperation(t, dirfd, path, shouldAllowEmptyPath(flags&linux.AT_EMPTY_PATH != 0), shouldFollowFinalSymlink(flags&linux.AT_SYMLINK_NOFOLLOW == 0))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tpop.Release(t)
+
+	return 0, nil, t.Kernel().VFS().SetMountAttrAt(t, t.Credentials(), &tpop.pop, &vfs.MountAttrOptions{
+		Set:         attr.AttrSet,
+		Clear:       attr.AttrClr,
+		Propagation: uint32(attr.Propagation),
+		Recursive:   flags&linux.AT_RECURSIVE != 0,
+	})
+}
@@ -16,9 +16,20 @@ package linux
 
 import (
 	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/mqfs"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/mq"
+	ktime "gvisor.dev/gvisor/pkg/sentry/kernel/time"
+	"gvisor.dev/gvisor/pkg/sentry/socket"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
 )
 
 // MqOpen implements mq_open(2).
@@ -77,6 +88,332 @@ func MqUnlink(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintpt
 	return 0, nil, t.IPCNamespace().PosixQueues().Remove(t, name)
 }
 
+// MqTimedsend implements mq_timedsend(2).
+func MqTimedsend(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	mqdes := args[0].Int()
+	msgAddr := args[1].Pointer()
+	msgLen := args[2].SizeT()
+	msgPrio := args[3].Uint()
+	timeoutAddr := args[4].Pointer()
+
+	timeout, err := copyMqTimeoutIn(t, timeoutAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	if msgPrio >= linux.MQ_PRIO_MAX {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	file, view, err := getMqView(t, mqdes)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	if msgLen > uint(view.Attributes().MqMsgsize) {
+		return 0, nil, linuxerr.EMSGSIZE
+	}
+	buf := make([]byte, msgLen)
+	if _, err := t.CopyInBytes(msgAddr, buf); err != nil {
+		return 0, nil, err
+	}
+	msg := &mq.Message{
+		Text:     string(buf),
+		Size:     uint64(msgLen),
+		Priority: msgPrio,
+	}
+
+	tchan, stop := startMqTimer(t, timeout)
+	defer stop()
+	err = view.Send(t, msg, t, file.StatusFlags()&linux.O_NONBLOCK == 0, tchan)
+	return 0, nil, linuxerr.ConvertIntr(err, linuxerr.ERESTARTSYS)
+}
+
+// MqTimedreceive implements mq_timedreceive(2).
+func MqTimedreceive(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	mqdes := args[0].Int()
+	msgAddr := args[1].Pointer()
+	msgLen := args[2].SizeT()
+	prioAddr := args[3].Pointer()
+	timeoutAddr := args[4].Pointer()
+
+	timeout, err := copyMqTimeoutIn(t, timeoutAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	file, view, err := getMqView(t, mqdes)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	tchan, stop := startMqTimer(t, timeout)
+	defer stop()
+	msg, err := view.Receive(t, t, file.StatusFlags()&linux.O_NONBLOCK == 0, uint64(msgLen), tchan)
+	if err != nil {
+		return 0, nil, linuxerr.ConvertIntr(err, linuxerr.ERESTARTSYS)
+	}
+
+	// Like in Linux, the message is lost if it can't be copied out.
+	if _, err := t.CopyOutBytes(msgAddr, []byte(msg.Text)); err != nil {
+		return 0, nil, err
+	}
+	if prioAddr != 0 {
+		if _, err := primitive.CopyUint32Out(t, prioAddr, msg.Priority); err != nil {
+			return 0, nil, err
+		}
+	}
+	return uintptr(msg.Size), nil, nil
+}
+
+// MqNotify implements mq_notify(2).
+func MqNotify(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	mqdes := args[0].Int()
+	sevAddr := args[1].Pointer()
+
+	var (
+		sev      linux.Sigevent
+		notifier mq.Notifier
+	)
+	if sevAddr != 0 {
+		if _, err := sev.CopyIn(t, sevAddr); err != nil {
+			return 0, nil, err
+		}
+		switch sev.Notify {
+		case linux.SIGEV_NONE:
+			notifier = mqNoneNotifier{}
+		case linux.SIGEV_SIGNAL:
+			if !linux.Signal(sev.Signo).IsValid() {
+				return 0, nil, linuxerr.EINVAL
+			}
+			notifier = &mqSignalNotifier{
+				tg:     t.ThreadGroup(),
+				userNS: t.UserNamespace(),
+				signo:  linux.Signal(sev.Signo),
+				value:  sev.Value,
+			}
+		case linux.SIGEV_THREAD:
+			n, err := newMqNetlinkNotifier(t, sev.Signo, hostarch.Addr(sev.Value))
+			if err != nil {
+				return 0, nil, err
+			}
+			notifier = n
+		default:
+			return 0, nil, linuxerr.EINVAL
+		}
+	}
+
+	file, view, err := getMqView(t, mqdes)
+	if err == nil {
+		defer file.DecRef(t)
+		err = view.SetNotification(t, sev.Notify, sev.Signo, notifier)
+	}
+	if err != nil {
+		// The request wasn't registered, so the socket is released without
+		// sending the cookie.
+		if n, ok := notifier.(*mqNetlinkNotifier); ok {
+			n.sock.DecRef(t)
+		}
+		return 0, nil, err
+	}
+	return 0, nil, nil
+}
+
+// MqGetsetattr implements mq_getsetattr(2).
+func MqGetsetattr(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	mqdes := args[0].Int()
+	newAddr := args[1].Pointer()
+	oldAddr := args[2].Pointer()
+
+	var newAttr linux.MqAttr
+	if newAddr != 0 {
+		if _, err := newAttr.CopyIn(t, newAddr); err != nil {
+			return 0, nil, err
+		}
+		// Only O_NONBLOCK can be changed.
+		if newAttr.MqFlags&^linux.O_NONBLOCK != 0 {
+			return 0, nil, linuxerr.EINVAL
+		}
+	}
+
+	file, view, err := getMqView(t, mqdes)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	oldAttr := view.Attributes()
+	oldAttr.MqFlags = int64(file.StatusFlags() & linux.O_NONBLOCK)
+	if newAddr != 0 {
+		flags := file.StatusFlags()&^linux.O_NONBLOCK | uint32(newAttr.MqFlags)
+		if err := file.SetStatusFlags(t, t.Credentials(), flags); err != nil {
+			return 0, nil, err
+		}
+	}
+	if oldAddr != 0 {
+		if _, err := oldAttr.CopyOut(t, oldAddr); err != nil {
+			return 0, nil, err
+		}
+	}
+	return 0, nil, nil
+}
+
+// getMqView returns the file with the given fd and the message queue view of
+// the file. The caller must release the file.
+func getMqView(t *kernel.Task, fd int32) (*vfs.FileDescription, mq.View, error) {
+	file := t.GetFile(fd)
+	if file == nil {
+		return nil, nil, linuxerr.EBADF
+	}
+	view, ok := mqfs.QueueView(file)
+	if !ok {
+		file.DecRef(t)
+		return nil, nil, linuxerr.EBADF
+	}
+	return file, view, nil
+}
+
+// copyMqTimeoutIn copies in the absolute CLOCK_REALTIME timeout used by
+// mq_timedsend(2) and mq_timedreceive(2). It returns nil if addr is 0.
+func copyMqTimeoutIn(t *kernel.Task, addr hostarch.Addr) (*linux.Timespec, error) {
+	if addr == 0 {
+		return nil, nil
+	}
+	ts, err := copyTimespecIn(t, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !ts.Valid() {
+		return nil, linuxerr.EINVAL
+	}
+	return &ts, nil
+}
+
+// startMqTimer starts a CLOCK_REALTIME timer expiring at timeout, and returns
+// the channel notified by the timer and a function that stops it. The channel
+// is nil if timeout is nil.
+func startMqTimer(t *kernel.Task, timeout *linux.Timespec) (<-chan struct{}, func()) {
+	if timeout == nil {
+		return nil, func() {}
+	}
+	notifier, tchan := ktime.NewChannelNotifier()
+	timer := ktime.NewTimer(t.Kernel().RealtimeClock(), notifier)
+	timer.Swap(ktime.Setting{
+		Enabled: true,
+		Next:    ktime.FromTimespec(*timeout),
+	})
+	return tchan, timer.Destroy
+}
+
+// mqNoneNotifier implements mq.Notifier for SIGEV_NONE. The registration
+// only prevents other processes from registering.
+//
+// +stateify savable
+type mqNoneNotifier struct{}
+
+// Notify implements mq.Notifier.Notify.
+func (mqNoneNotifier) Notify(context.Context) {}
+
+// Remove implements mq.Notifier.Remove.
+func (mqNoneNotifier) Remove(context.Context) {}
+
+// mqSignalNotifier implements mq.Notifier for SIGEV_SIGNAL.
+//
+// +stateify savable
+type mqSignalNotifier struct {
+	// tg is the registered thread group, which receives the signal.
+	tg *kernel.ThreadGroup
+
+	// userNS is the user namespace of the registered task, used to translate
+	// the sender's UID.
+	userNS *auth.UserNamespace
+
+	// signo is the signal sent to tg.
+	signo linux.Signal
+
+	// value is passed to the signal handler in si_value.
+	value uint64
+}
+
+// Notify implements mq.Notifier.Notify. Compare Linux's
+// ipc/mqueue.c:__do_notify.
+func (n *mqSignalNotifier) Notify(ctx context.Context) {
+	info := &linux.SignalInfo{
+		Signo: int32(n.signo),
+		Code:  linux.SI_MESGQ,
+	}
+	if sender := kernel.TaskFromContext(ctx); sender != nil {
+		info.SetPID(int32(n.tg.PIDNamespace().IDOfThreadGroup(sender.ThreadGroup())))
+		info.SetUID(int32(sender.Credentials().RealKUID.In(n.userNS).OrOverflow()))
+	}
+	info.SetSigval(n.value)
+	if err := n.tg.SendSignal(info); err != nil {
+		log.Debugf("Failed to send mq_notify signal %v: %v", n.signo, err)
+	}
+}
+
+// Remove implements mq.Notifier.Remove.
+func (*mqSignalNotifier) Remove(context.Context) {}
+
+// mqNetlinkNotifier implements mq.Notifier for SIGEV_THREAD. Like Linux, it
+// delivers the cookie provided by the caller to a netlink socket, where the C
+// library's notification thread waits for it.
+//
+// +stateify savable
+type mqNetlinkNotifier struct {
+	// sock is the netlink socket receiving the cookie. The notifier holds a
+	// reference on sock until the cookie is delivered.
+	sock *vfs.FileDescription
+
+	// cookie is sent to sock with the last byte set to the notification
+	// code, see NOTIFY_WOKENUP and NOTIFY_REMOVED.
+	cookie [linux.NOTIFY_COOKIE_LEN]byte
+}
+
+// newMqNetlinkNotifier returns a notifier sending the cookie at cookieAddr to
+// the netlink socket fd.
+func newMqNetlinkNotifier(t *kernel.Task, fd int32, cookieAddr hostarch.Addr) (*mqNetlinkNotifier, error) {
+	n := &mqNetlinkNotifier{}
+	if _, err := t.CopyInBytes(cookieAddr, n.cookie[:]); err != nil {
+		return nil, err
+	}
+
+	file := t.GetFile(fd)
+	if file == nil {
+		return nil, linuxerr.EBADF
+	}
+	if _, ok := file.Impl().(socket.Socket); !ok {
+		file.DecRef(t)
+		return nil, linuxerr.ENOTSOCK
+	}
+	if _, ok := file.Impl().(*netlink.Socket); !ok {
+		file.DecRef(t)
+		return nil, linuxerr.EINVAL
+	}
+	n.sock = file
+	return n, nil
+}
+
+// Notify implements mq.Notifier.Notify.
+func (n *mqNetlinkNotifier) Notify(ctx context.Context) {
+	n.send(ctx, linux.NOTIFY_WOKENUP)
+}
+
+// Remove implements mq.Notifier.Remove.
+func (n *mqNetlinkNotifier) Remove(ctx context.Context) {
+	n.send(ctx, linux.NOTIFY_REMOVED)
+}
+
+func (n *mqNetlinkNotifier) send(ctx context.Context, code byte) {
+	defer n.sock.DecRef(ctx)
+
+	n.cookie[linux.NOTIFY_COOKIE_LEN-1] = code
+	if err := n.sock.Impl().(*netlink.Socket).SendKernelMessage(ctx, n.cookie[:]); err != nil {
+		log.Debugf("Failed to send mq_notify cookie: %v", err)
+	}
+}
+
 func openOpts(name string, rOnly, wOnly, readWrite, create, exclusive, block bool) mq.OpenOpts {
 	var access mq.AccessType
 	switch {
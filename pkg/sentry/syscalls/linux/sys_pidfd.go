@@ -0,0 +1,167 @@
+package linux
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+)
+
+// getPIDFD returns the thread group referred by the pidfd fd and the status
+// flags of the pidfd.
+func getPIDFD(t *kernel.Task, fd int32) (*kernel.ThreadGroup, uint32, error) {
+	file := t.GetFile(fd)
+	if file == nil {
+		return nil, 0, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+
+	pidfd, ok := file.Impl().(*kernel.PIDFD)
+	if !ok {
+		return nil, 0, linuxerr.EBADF
+	}
+	return pidfd.ThreadGroup(), file.StatusFlags(), nil
+}
+
+// pidfdThreadGroupID returns the ID of the thread group referred by the pidfd
+// fd in t's PID namespace for waitid(P_PIDFD).
+func pidfdThreadGroupID(t *kernel.Task, fd int32) (kernel.ThreadID, uint32, error) {
+	tg, flags, err := getPIDFD(t, fd)
+	if err != nil {
+		return 0, 0, err
+	}
+	tid := t.PIDNamespace().IDOfThreadGroup(tg)
+	if tid == 0 {
+		// The thread group has been reaped or isn't visible, so it can't be
+		// a waitable child.
+		return 0, 0, linuxerr.ECHILD
+	}
+	return tid, flags, nil
+}
+
+// PidfdOpen implements linux syscall pidfd_open(2).
+func PidfdOpen(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	pid := kernel.ThreadID(args[0].Int())
+	flags := args[1].Uint()
+
+	if flags&^linux.PIDFD_NONBLOCK != 0 || pid <= 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	target := t.PIDNamespace().TaskWithID(pid)
+	if target == nil {
+		return 0, nil, linuxerr.ESRCH
+	}
+	// pidfds refer to thread groups, so pid must be the ID of a thread group.
+	if target.ThreadGroup().Leader() != target {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	file, err := t.Kernel().NewPIDFD(t, target.ThreadGroup(), flags)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{CloseOnExec: true})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// PidfdSendSignal implements linux syscall pidfd_send_signal(2).
+func PidfdSendSignal(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fd := args[0].Int()
+	sig := linux.Signal(args[1].Int())
+	infoAddr := args[2].Pointer()
+	flags := args[3].Uint()
+
+	if flags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if sig != 0 && !sig.IsValid() {
+		return 0, nil, linuxerr.EINVAL
+	}
+	tg, _, err := getPIDFD(t, fd)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var info linux.SignalInfo
+	if infoAddr != 0 {
+		if _, err := info.CopyIn(t, infoAddr); err != nil {
+			return 0, nil, err
+		}
+		// Same checks as in rt_sigqueueinfo(2).
+		if info.Signo != int32(sig) {
+			return 0, nil, linuxerr.EINVAL
+		}
+		if (info.Code >= 0 || info.Code == linux.SI_TKILL) && tg != t.ThreadGroup() {
+			return 0, nil, linuxerr.EPERM
+		}
+	}
+
+	// This loops to handle races with execve, see Kill.
+	for {
+		target := tg.Leader()
+		if target == nil || t.PIDNamespace().IDOfThreadGroup(tg) == 0 {
+			return 0, nil, linuxerr.ESRCH
+		}
+		if !mayKill(t, target, sig) {
+			return 0, nil, linuxerr.EPERM
+		}
+		if infoAddr == 0 {
+			info = linux.SignalInfo{
+				Signo: int32(sig),
+				Code:  linux.SI_USER,
+			}
+			info.SetPID(int32(target.PIDNamespace().IDOfTask(t)))
+			info.SetUID(int32(t.Credentials().RealKUID.In(target.UserNamespace()).OrOverflow()))
+		}
+		if err := target.SendGroupSignal(&info); !linuxerr.Equals(linuxerr.ESRCH, err) {
+			return 0, nil, err
+		}
+	}
+}
+
+// PidfdGetfd implements linux syscall pidfd_getfd(2).
+func PidfdGetfd(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	pidfd := args[0].Int()
+	targetFD := args[1].Int()
+	flags := args[2].Uint()
+
+	if flags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	tg, _, err := getPIDFD(t, pidfd)
+	if err != nil {
+		return 0, nil, err
+	}
+	target := tg.Leader()
+	if target == nil || t.PIDNamespace().IDOfThreadGroup(tg) == 0 {
+		return 0, nil, linuxerr.ESRCH
+	}
+	// "Permission to duplicate another process's file descriptor is governed
+	// by a ptrace access mode PTRACE_MODE_ATTACH_REALCREDS check" - pidfd_getfd(2)
+	if !t.CanTrace(target, true /* attach */) {
+		return 0, nil, linuxerr.EPERM
+	}
+
+	var file *vfs.FileDescription
+	target.WithMuLocked(func(target *kernel.Task) {
+		if fdTable := target.FDTable(); fdTable != nil {
+			file, _ = fdTable.Get(targetFD)
+		}
+	})
+	if file == nil {
+		return 0, nil, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{CloseOnExec: true})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
@@ -39,29 +39,50 @@ type userSockFprog struct {
 	Filter uint64
 }
 
-// seccomp applies a seccomp policy to the current task.
-func seccomp(t *kernel.Task, mode, flags uint64, addr hostarch.Addr) error {
-	// We only support SECCOMP_SET_MODE_FILTER at the moment.
-	if mode != linux.SECCOMP_SET_MODE_FILTER {
+// seccomp applies a seccomp policy to the current task. If
+// SECCOMP_FILTER_FLAG_NEW_LISTENER is set, it returns the fd of the user
+// notification listener of the filter.
+func seccomp(t *kernel.Task, mode, flags uint64, addr hostarch.Addr) (uintptr, error) {
+	switch mode {
+	case linux.SECCOMP_SET_MODE_FILTER:
+	case linux.SECCOMP_GET_NOTIF_SIZES:
+		if flags != 0 {
+			return 0, linuxerr.EINVAL
+		}
+		sizes := linux.SeccompNotifSizes{
+			Notif:     linux.SizeOfSeccompNotif,
+			NotifResp: linux.SizeOfSeccompNotifResp,
+			Data:      uint16((*linux.SeccompData)(nil).SizeBytes()),
+		}
+		_, err := sizes.CopyOut(t, addr)
+		return 0, err
+	default:
 		// Unsupported mode.
-		return linuxerr.EINVAL
+		return 0, linuxerr.EINVAL
 	}
 
 	tsync := flags&linux.SECCOMP_FILTER_FLAG_TSYNC != 0
+	newListener := flags&linux.SECCOMP_FILTER_FLAG_NEW_LISTENER != 0
 
-	// The only flag we support now is SECCOMP_FILTER_FLAG_TSYNC.
-	if flags&^linux.SECCOMP_FILTER_FLAG_TSYNC != 0 {
+	// The only flags we support now are SECCOMP_FILTER_FLAG_TSYNC and
+	// SECCOMP_FILTER_FLAG_NEW_LISTENER.
+	if flags&^(linux.SECCOMP_FILTER_FLAG_TSYNC|linux.SECCOMP_FILTER_FLAG_NEW_LISTENER) != 0 {
 		// Unsupported flag.
-		return linuxerr.EINVAL
+		return 0, linuxerr.EINVAL
+	}
+	// TSYNC failures are reported by returning the ID of the thread that
+	// couldn't be synchronized, which is ambiguous with the listener fd.
+	if tsync && newListener {
+		return 0, linuxerr.EINVAL
 	}
 
 	var fprog userSockFprog
 	if _, err := fprog.CopyIn(t, addr); err != nil {
-		return err
+		return 0, err
 	}
 	filter := make([]linux.BPFInstruction, int(fprog.Len))
 	if _, err := linux.CopyBPFInstructionSliceIn(t, hostarch.Addr(fprog.Filter), filter); err != nil {
-		return err
+		return 0, err
 	}
 	bpfFilter := make([]bpf.Instruction, len(filter))
 	for i, ins := range filter {
@@ -70,13 +91,35 @@ func seccomp(t *kernel.Task, mode, flags uint64, addr hostarch.Addr) error {
 	compiledFilter, err := bpf.Compile(bpfFilter, true /* optimize */)
 	if err != nil {
 		t.Debugf("Invalid seccomp-bpf filter: %v", err)
-		return linuxerr.EINVAL
+		return 0, linuxerr.EINVAL
+	}
+
+	if !newListener {
+		return 0, t.AppendSyscallFilter(compiledFilter, tsync, nil)
 	}
 
-	return t.AppendSyscallFilter(compiledFilter, tsync)
+	file, listener, err := t.Kernel().NewSeccompListener(t)
+	if err != nil {
+		return 0, err
+	}
+	defer file.DecRef(t)
+
+	// Install the fd first, since the filter can't be removed afterwards.
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{CloseOnExec: true})
+	if err != nil {
+		return 0, err
+	}
+	if err := t.AppendSyscallFilter(compiledFilter, tsync, listener); err != nil {
+		if f := t.FDTable().Remove(t, fd); f != nil {
+			f.DecRef(t)
+		}
+		return 0, err
+	}
+	return uintptr(fd), nil
 }
 
 // Seccomp implements linux syscall seccomp(2).
 func Seccomp(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
-	return 0, nil, seccomp(t, args[0].Uint64(), args[1].Uint64(), args[2].Pointer())
+	ret, err := seccomp(t, args[0].Uint64(), args[1].Uint64(), args[2].Pointer())
+	return ret, nil, err
 }
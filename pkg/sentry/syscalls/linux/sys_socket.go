@@ -274,6 +274,9 @@ func Connect(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr
 	if err != nil {
 		return 0, nil, err
 	}
+	if err := checkLandlockNetPort(t, s, a, linux.LANDLOCK_ACCESS_NET_CONNECT_TCP); err != nil {
+		return 0, nil, err
+	}
 
 	blocking := (file.StatusFlags() & linux.SOCK_NONBLOCK) == 0
 	return 0, nil, linuxerr.ConvertIntr(s.Connect(t, a, blocking).ToError(), linuxerr.ERESTARTSYS)
@@ -364,6 +367,9 @@ func Bind(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *
 	if err != nil {
 		return 0, nil, err
 	}
+	if err := checkLandlockNetPort(t, s, a, linux.LANDLOCK_ACCESS_NET_BIND_TCP); err != nil {
+		return 0, nil, err
+	}
 
 	return 0, nil, s.Bind(t, a).ToError()
 }
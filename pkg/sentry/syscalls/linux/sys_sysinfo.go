@@ -42,7 +42,7 @@ func Sysinfo(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr
 	// Only a subset of the fields in sysinfo_t make sense to return.
 	si := linux.Sysinfo{
 		Procs:    uint16(t.Kernel().TaskSet().Root.NumTasks()),
-		Uptime:   t.Kernel().MonotonicClock().Now().Seconds(),
+		Uptime:   t.TimeNamespace().BoottimeClock().Now().Seconds(),
 		TotalRAM: totalSize,
 		FreeRAM:  memFree,
 		Unit:     1,
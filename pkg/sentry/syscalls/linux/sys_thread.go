@@ -212,6 +212,13 @@ func clone(t *kernel.Task, flags int, stack hostarch.Addr, parentTID hostarch.Ad
 		Stack:      uint64(stack),
 		TLS:        uint64(tls),
 	}
+	// clone(2) returns the pidfd in the location used for the parent's thread ID.
+	if args.Flags&linux.CLONE_PIDFD != 0 {
+		if args.Flags&linux.CLONE_PARENT_SETTID != 0 {
+			return 0, nil, linuxerr.EINVAL
+		}
+		args.Pidfd = uint64(parentTID)
+	}
 	ntid, ctrl, err := t.Clone(&args)
 	return uintptr(ntid), ctrl, err
 }
@@ -248,6 +255,9 @@ func Clone3(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 			return 0, nil, err
 		}
 	}
+	if cloneArgs.Flags&linux.CLONE_INTO_CGROUP != 0 && int(size) < linux.CLONE_ARGS_SIZE_VER2 {
+		return 0, nil, linuxerr.EINVAL
+	}
 
 	ntid, ctrl, err := t.Clone(&cloneArgs)
 	if err != nil {
@@ -375,12 +385,24 @@ func Waitid(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 		Events:       kernel.EventTraceeStop,
 		ConsumeEvent: options&linux.WNOWAIT == 0,
 	}
+	pidfdNonblock := false
 	switch idtype {
 	case linux.P_ALL:
 	case linux.P_PID:
 		wopts.SpecificTID = kernel.ThreadID(id)
 	case linux.P_PGID:
 		wopts.SpecificPGID = kernel.ProcessGroupID(id)
+	case linux.P_PIDFD:
+		tid, flags, err := pidfdThreadGroupID(t, id)
+		if err != nil {
+			return 0, nil, err
+		}
+		wopts.SpecificTID = tid
+		// Waiting on a nonblocking pidfd fails with EAGAIN instead of blocking.
+		if flags&linux.O_NONBLOCK != 0 && options&linux.WNOHANG == 0 {
+			options |= linux.WNOHANG
+			pidfdNonblock = true
+		}
 	default:
 		return 0, nil, linuxerr.EINVAL
 	}
@@ -397,6 +419,9 @@ func Waitid(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 
 	wr, err := t.Wait(&wopts)
 	if err != nil {
+		if err == kernel.ErrNoWaitableEvent && pidfdNonblock {
+			return 0, nil, linuxerr.EAGAIN
+		}
 		if err == kernel.ErrNoWaitableEvent {
 			err = nil
 			// "If WNOHANG was specified in options and there were no children
@@ -0,0 +1,37 @@
+package linux
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/userfaultfd"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+)
+
+// Userfaultfd implements linux syscall userfaultfd(2).
+func Userfaultfd(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	flags := args[0].Uint()
+
+	if flags&^(linux.O_CLOEXEC|linux.O_NONBLOCK|linux.UFFD_USER_MODE_ONLY) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	// Handling faults of the sentry accessing application memory requires
+	// CAP_SYS_PTRACE, like in Linux with vm.unprivileged_userfaultfd = 0.
+	if flags&linux.UFFD_USER_MODE_ONLY == 0 && !t.HasCapabilityIn(linux.CAP_SYS_PTRACE, t.Kernel().RootUserNamespace()) {
+		return 0, nil, linuxerr.EPERM
+	}
+
+	file, err := userfaultfd.New(t, t.Kernel().VFS(), t.MemoryManager(), flags)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFrom(0, file, kernel.FDFlags{
+		CloseOnExec: flags&linux.O_CLOEXEC != 0,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
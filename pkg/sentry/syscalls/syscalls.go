@@ -81,9 +81,10 @@ func Error(name string, err error, note string, urls []string) kernel.Syscall {
 			kernel.IncrementUnimplementedSyscallCounter(sysno)
 			return 0, nil, err
 		},
-		SupportLevel: kernel.SupportUnimplemented,
-		Note:         fmt.Sprintf("%sReturns %q.", note, err.Error()),
-		URLs:         urls,
+		SupportLevel:   kernel.SupportUnimplemented,
+		Note:           fmt.Sprintf("%sReturns %q.", note, err.Error()),
+		URLs:           urls,
+		NotImplemented: linuxerr.Equals(linuxerr.ENOSYS, err),
 	}
 }
 
@@ -99,9 +100,10 @@ func ErrorWithEvent(name string, err error, note string, urls []string) kernel.S
 			t.Kernel().EmitUnimplementedEvent(t, sysno)
 			return 0, nil, err
 		},
-		SupportLevel: kernel.SupportUnimplemented,
-		Note:         fmt.Sprintf("%sReturns %q.", note, err.Error()),
-		URLs:         urls,
+		SupportLevel:   kernel.SupportUnimplemented,
+		Note:           fmt.Sprintf("%sReturns %q.", note, err.Error()),
+		URLs:           urls,
+		NotImplemented: linuxerr.Equals(linuxerr.ENOSYS, err),
 	}
 }
 
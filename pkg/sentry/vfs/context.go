@@ -36,8 +36,21 @@ const (
 	// CtxFilesystemMemoryFileMap is a Context.Value key for mapping tmpfs unique
 	// IDs to private memory files. This is used for save/restore.
 	CtxFilesystemMemoryFileMap
+
+	// CtxLandlockDomain is a Context.Value key for a LandlockDomain.
+	CtxLandlockDomain
 )
 
+// LandlockDomainFromContext returns the LandlockDomain enforced on ctx, or nil
+// if ctx isn't restricted by Landlock. No reference is taken on the returned
+// LandlockDomain.
+func LandlockDomainFromContext(ctx context.Context) *LandlockDomain {
+	if v := ctx.Value(CtxLandlockDomain); v != nil {
+		return v.(*LandlockDomain)
+	}
+	return nil
+}
+
 // MountNamespaceFromContext returns the MountNamespace used by ctx. If ctx is
 // not associated with a MountNamespace, MountNamespaceFromContext returns nil.
 //
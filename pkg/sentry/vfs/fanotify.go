@@ -0,0 +1,77 @@
+package vfs
+
+import (
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// FanotifyListener receives file access events of a VirtualFilesystem. It's
+// implemented by fanotify groups, which live outside of vfs, because reading
+// their events installs file descriptors.
+type FanotifyListener interface {
+	// HandleFanotifyEvent is called when events in mask happen on fd. For
+	// permission events, HandleFanotifyEvent may block until the event is
+	// answered, and the returned error denies the operation.
+	HandleFanotifyEvent(ctx context.Context, fd *FileDescription, mask uint64) error
+}
+
+// fanotifyListeners is the set of FanotifyListeners of a VirtualFilesystem.
+//
+// +stateify savable
+type fanotifyListeners struct {
+	// count is the number of listeners, which allows to skip events without
+	// locking mu while there are no listeners.
+	count atomicbitops.Int32
+
+	// mu protects listeners.
+	mu sync.RWMutex `state:"nosave"`
+
+	listeners []FanotifyListener
+}
+
+// AddFanotifyListener registers l to receive events of all files in vfs.
+func (vfs *VirtualFilesystem) AddFanotifyListener(l FanotifyListener) {
+	fl := &vfs.fanotify
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.listeners = append(fl.listeners, l)
+	fl.count.Store(int32(len(fl.listeners)))
+}
+
+// RemoveFanotifyListener unregisters l.
+func (vfs *VirtualFilesystem) RemoveFanotifyListener(l FanotifyListener) {
+	fl := &vfs.fanotify
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	for i, other := range fl.listeners {
+		if other == l {
+			fl.listeners = append(fl.listeners[:i], fl.listeners[i+1:]...)
+			break
+		}
+	}
+	fl.count.Store(int32(len(fl.listeners)))
+}
+
+// fanotify sends events in mask on fd to all fanotify listeners. It returns
+// the first error returned by listeners, which denies permission events.
+func (fd *FileDescription) fanotify(ctx context.Context, mask uint64) error {
+	if fd.noNotify || fd.vd.mount == nil || fd.vd.mount.vfs == nil {
+		return nil
+	}
+	fl := &fd.vd.mount.vfs.fanotify
+	if fl.count.Load() == 0 {
+		return nil
+	}
+
+	fl.mu.RLock()
+	listeners := append([]FanotifyListener(nil), fl.listeners...)
+	fl.mu.RUnlock()
+
+	for _, l := range listeners {
+		if err := l.HandleFanotifyEvent(ctx, fd, mask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -84,6 +84,18 @@ type FileDescription struct {
 	// writable is analogous to Linux's FMODE_WRITE.
 	writable bool
 
+	// noNotify is true if fd doesn't generate fanotify events. It's set for
+	// files opened for fanotify listeners and O_PATH files. noNotify is
+	// immutable after the file is opened.
+	//
+	// noNotify is analogous to Linux's FMODE_NONOTIFY.
+	noNotify bool
+
+	// landlockDenyTruncate is true if the Landlock domain of the task that
+	// opened fd denied truncating the file at the time. landlockDenyTruncate
+	// is immutable after the file is opened.
+	landlockDenyTruncate bool
+
 	usedLockBSD atomicbitops.Uint32
 
 	// impl is the FileDescriptionImpl associated with this Filesystem. impl is
@@ -165,6 +177,11 @@ func (fd *FileDescription) DecRef(ctx context.Context) {
 			ev = linux.IN_CLOSE_WRITE
 		}
 		fd.Dentry().InotifyWithParent(ctx, ev, 0, PathEvent)
+		fanEv := uint64(linux.FAN_CLOSE_NOWRITE)
+		if fd.IsWritable() {
+			fanEv = linux.FAN_CLOSE_WRITE
+		}
+		fd.fanotify(ctx, fanEv)
 
 		// Unregister fd from all epoll instances.
 		fd.epollMu.Lock()
@@ -307,6 +324,12 @@ func (fd *FileDescription) IsWritable() bool {
 	return fd.writable
 }
 
+// LandlockDeniesTruncate returns true if truncating fd with ftruncate(2) is
+// denied by the Landlock domain of the task that opened it.
+func (fd *FileDescription) LandlockDeniesTruncate() bool {
+	return fd.landlockDenyTruncate
+}
+
 // Impl returns the FileDescriptionImpl associated with fd.
 func (fd *FileDescription) Impl() FileDescriptionImpl {
 	return fd.impl
@@ -590,6 +613,7 @@ func (fd *FileDescription) Allocate(ctx context.Context, mode, offset, length ui
 		return err
 	}
 	fd.Dentry().InotifyWithParent(ctx, linux.IN_MODIFY, 0, PathEvent)
+	fd.fanotify(ctx, linux.FAN_MODIFY)
 	return nil
 }
 
@@ -629,10 +653,14 @@ func (fd *FileDescription) PRead(ctx context.Context, dst usermem.IOSequence, of
 	if !fd.readable {
 		return 0, linuxerr.EBADF
 	}
+	if err := fd.fanotify(ctx, linux.FAN_ACCESS_PERM); err != nil {
+		return 0, err
+	}
 	start := fsmetric.StartReadWait()
 	n, err := fd.impl.PRead(ctx, dst, offset, opts)
 	if n > 0 {
 		fd.Dentry().InotifyWithParent(ctx, linux.IN_ACCESS, 0, PathEvent)
+		fd.fanotify(ctx, linux.FAN_ACCESS)
 	}
 	fsmetric.Reads.Increment()
 	fsmetric.FinishReadWait(fsmetric.ReadWait, start)
@@ -644,10 +672,14 @@ func (fd *FileDescription) Read(ctx context.Context, dst usermem.IOSequence, opt
 	if !fd.readable {
 		return 0, linuxerr.EBADF
 	}
+	if err := fd.fanotify(ctx, linux.FAN_ACCESS_PERM); err != nil {
+		return 0, err
+	}
 	start := fsmetric.StartReadWait()
 	n, err := fd.impl.Read(ctx, dst, opts)
 	if n > 0 {
 		fd.Dentry().InotifyWithParent(ctx, linux.IN_ACCESS, 0, PathEvent)
+		fd.fanotify(ctx, linux.FAN_ACCESS)
 	}
 	fsmetric.Reads.Increment()
 	fsmetric.FinishReadWait(fsmetric.ReadWait, start)
@@ -667,6 +699,7 @@ func (fd *FileDescription) PWrite(ctx context.Context, src usermem.IOSequence, o
 	n, err := fd.impl.PWrite(ctx, src, offset, opts)
 	if n > 0 {
 		fd.Dentry().InotifyWithParent(ctx, linux.IN_MODIFY, 0, PathEvent)
+		fd.fanotify(ctx, linux.FAN_MODIFY)
 	}
 	return n, err
 }
@@ -679,6 +712,7 @@ func (fd *FileDescription) Write(ctx context.Context, src usermem.IOSequence, op
 	n, err := fd.impl.Write(ctx, src, opts)
 	if n > 0 {
 		fd.Dentry().InotifyWithParent(ctx, linux.IN_MODIFY, 0, PathEvent)
+		fd.fanotify(ctx, linux.FAN_MODIFY)
 	}
 	return n, err
 }
@@ -687,6 +721,10 @@ func (fd *FileDescription) Write(ctx context.Context, src usermem.IOSequence, op
 // IterDirents has been called since the last call to Seek, it continues
 // iteration from the end of the last call.
 func (fd *FileDescription) IterDirents(ctx context.Context, cb IterDirentsCallback) error {
+	if err := fd.fanotify(ctx, linux.FAN_ACCESS_PERM); err != nil {
+		return err
+	}
+	defer fd.fanotify(ctx, linux.FAN_ACCESS)
 	defer fd.Dentry().InotifyWithParent(ctx, linux.IN_ACCESS, 0, PathEvent)
 	return fd.impl.IterDirents(ctx, cb)
 }
@@ -0,0 +1,298 @@
+package vfs
+
+import (
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+// fsContextPhase is the state of an FSContext, analogous to Linux's enum
+// fs_context_phase.
+type fsContextPhase int
+
+const (
+	// fsContextCreateParams is the phase of contexts created by fsopen(2),
+	// until FSCONFIG_CMD_CREATE.
+	fsContextCreateParams fsContextPhase = iota
+
+	// fsContextAwaitingMount is the phase of contexts whose filesystem has
+	// been created and can be mounted by fsmount(2).
+	fsContextAwaitingMount
+
+	// fsContextReconfParams is the phase of contexts created by fspick(2).
+	fsContextReconfParams
+
+	// fsContextFailed is the phase of contexts whose filesystem creation
+	// failed. They can't be used anymore.
+	fsContextFailed
+)
+
+// FSContext is a filesystem context, as created by fsopen(2) and fspick(2).
+// It accumulates the parameters set by fsconfig(2) into MountOptions, which
+// are used to create a new filesystem, or to reconfigure the mount it was
+// picked from.
+//
+// +stateify savable
+type FSContext struct {
+	vfsfd FileDescription
+	FileDescriptionDefaultImpl
+	DentryMetadataFileDescriptionImpl
+	NoLockFD
+
+	// fsTypeName is the name of the filesystem type. fsTypeName is immutable.
+	fsTypeName string
+
+	// mu protects the fields below.
+	mu sync.Mutex `state:"nosave"`
+
+	// phase is the state of the context.
+	phase fsContextPhase
+
+	// source is the value of the "source" parameter.
+	source string
+
+	// opts holds the mount options set through parameters. Filesystem
+	// specific parameters are kept in data until the filesystem is created.
+	opts MountOptions
+	data []string
+
+	// fs and root are the filesystem created by FSCONFIG_CMD_CREATE, with
+	// references held.
+	fs   *Filesystem
+	root *Dentry
+
+	// mnt is the mount picked by fspick(2), with a reference held. mnt is
+	// immutable, and nil for contexts created by fsopen(2).
+	mnt *Mount
+}
+
+// NewFSContextFD returns a FileDescription representing a new filesystem
+// context for the given filesystem type. A reference is taken on the returned
+// FileDescription.
+func (vfs *VirtualFilesystem) NewFSContextFD(ctx context.Context, fsTypeName string) (*FileDescription, error) {
+	rft := vfs.getFilesystemType(fsTypeName)
+	if rft == nil || !rft.opts.AllowUserMount {
+		return nil, linuxerr.ENODEV
+	}
+	return vfs.newFSContextFD(ctx, &FSContext{
+		fsTypeName: fsTypeName,
+		phase:      fsContextCreateParams,
+	})
+}
+
+// PickFSContextAt returns a FileDescription representing a filesystem context
+// for reconfiguring the mount at the given path. A reference is taken on the
+// returned FileDescription.
+func (vfs *VirtualFilesystem) PickFSContextAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation) (*FileDescription, error) {
+	vd, err := vfs.getMountpoint(ctx, creds, pop)
+	if err != nil {
+		return nil, err
+	}
+	defer vd.DecRef(ctx)
+	opts := vd.mount.Options()
+	vd.mount.IncRef()
+	return vfs.newFSContextFD(ctx, &FSContext{
+		fsTypeName: vd.mount.fs.FilesystemType().Name(),
+		phase:      fsContextReconfParams,
+		opts:       opts,
+		mnt:        vd.mount,
+	})
+}
+
+func (vfs *VirtualFilesystem) newFSContextFD(ctx context.Context, fc *FSContext) (*FileDescription, error) {
+	vd := vfs.NewAnonVirtualDentry("[fscontext]")
+	defer vd.DecRef(ctx)
+	if err := fc.vfsfd.Init(fc, linux.O_RDWR, vd.Mount(), vd.Dentry(), &FileDescriptionOptions{
+		DenyPRead:         true,
+		DenyPWrite:        true,
+		UseDentryMetadata: true,
+	}); err != nil {
+		if fc.mnt != nil {
+			fc.mnt.DecRef(ctx)
+		}
+		return nil, err
+	}
+	return &fc.vfsfd, nil
+}
+
+// Release implements FileDescriptionImpl.Release.
+func (fc *FSContext) Release(ctx context.Context) {
+	if fc.root != nil {
+		fc.root.DecRef(ctx)
+	}
+	if fc.fs != nil {
+		fc.fs.DecRef(ctx)
+	}
+	if fc.mnt != nil {
+		fc.mnt.DecRef(ctx)
+	}
+}
+
+// Read implements FileDescriptionImpl.Read. Linux queues error messages of
+// the context for reading; we don't log any.
+func (fc *FSContext) Read(ctx context.Context, dst usermem.IOSequence, opts ReadOptions) (int64, error) {
+	return 0, linuxerr.ENODATA
+}
+
+// SetFlag sets the flag parameter key, as for FSCONFIG_SET_FLAG.
+func (fc *FSContext) SetFlag(key string) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if err := fc.checkParamsPhaseLocked(); err != nil {
+		return err
+	}
+	switch key {
+	case "ro":
+		fc.opts.ReadOnly = true
+		return nil
+	case "rw":
+		fc.opts.ReadOnly = false
+		return nil
+	case "source":
+		return linuxerr.EINVAL
+	}
+	return fc.addDataLocked(key)
+}
+
+// SetString sets the parameter key to value, as for FSCONFIG_SET_STRING.
+func (fc *FSContext) SetString(key, value string) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if err := fc.checkParamsPhaseLocked(); err != nil {
+		return err
+	}
+	if key == "source" {
+		if fc.source != "" {
+			return linuxerr.EINVAL
+		}
+		fc.source = value
+		return nil
+	}
+	return fc.addDataLocked(key + "=" + value)
+}
+
+// +checklocks:fc.mu
+func (fc *FSContext) checkParamsPhaseLocked() error {
+	if fc.phase != fsContextCreateParams && fc.phase != fsContextReconfParams {
+		return linuxerr.EBUSY
+	}
+	return nil
+}
+
+// addDataLocked appends a filesystem-specific parameter to the data passed
+// to FilesystemType.GetFilesystem(), limited to a page like mount(2) data.
+//
+// +checklocks:fc.mu
+func (fc *FSContext) addDataLocked(param string) error {
+	if strings.Contains(param, ",") {
+		return linuxerr.EINVAL
+	}
+	size := len(param)
+	for _, p := range fc.data {
+		size += len(p) + 1
+	}
+	if size > hostarch.PageSize-2 {
+		return linuxerr.EINVAL
+	}
+	fc.data = append(fc.data, param)
+	return nil
+}
+
+// Create creates the filesystem configured by fc, as for
+// FSCONFIG_CMD_CREATE.
+func (fc *FSContext) Create(ctx context.Context, creds *auth.Credentials) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.phase != fsContextCreateParams {
+		return linuxerr.EBUSY
+	}
+	opts := fc.opts
+	opts.GetFilesystemOptions.Data = strings.Join(fc.data, ",")
+	fs, root, err := fc.vfsfd.vd.mount.vfs.NewFilesystem(ctx, creds, fc.source, fc.fsTypeName, &opts)
+	if err != nil {
+		fc.phase = fsContextFailed
+		return err
+	}
+	fc.fs = fs
+	fc.root = root
+	fc.phase = fsContextAwaitingMount
+	return nil
+}
+
+// Reconfigure applies the parameters of fc to the mount it was picked from,
+// as for FSCONFIG_CMD_RECONFIGURE. As with mount(2) and MS_REMOUNT,
+// filesystem-specific parameters are ignored.
+func (fc *FSContext) Reconfigure(ctx context.Context) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.phase != fsContextReconfParams {
+		return linuxerr.EBUSY
+	}
+	return fc.mnt.vfs.SetMountReadOnly(fc.mnt, fc.opts.ReadOnly)
+}
+
+// Mount returns an O_PATH file description of a detached mount of the
+// filesystem created by fc, as for fsmount(2). The filesystem is mounted
+// read-only if either fc or opts says so.
+func (fc *FSContext) Mount(ctx context.Context, opts *MountOptions) (*FileDescription, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.phase != fsContextAwaitingMount {
+		return nil, linuxerr.EBUSY
+	}
+	mopts := *opts
+	mopts.ReadOnly = mopts.ReadOnly || fc.opts.ReadOnly
+	vfs := fc.vfsfd.vd.mount.vfs
+	mnt := vfs.NewDisconnectedMount(fc.fs, fc.root, &mopts)
+	vfs.lockMounts()
+	defer vfs.unlockMounts(ctx)
+	return vfs.newDetachedMountFDLocked(ctx, mnt), nil
+}
+
+// detachedMountFD is an O_PATH file description of the root of a detached
+// mount tree, as returned by fsmount(2) and open_tree(2) with
+// OPEN_TREE_CLONE. Unless the tree is attached by move_mount(2), it's
+// dissolved when the file description is released.
+//
+// The submounts of a detached tree are only connected when the tree is
+// attached, so path resolution from the file description doesn't cross them.
+//
+// +stateify savable
+type detachedMountFD struct {
+	opathFD
+}
+
+// newDetachedMountFDLocked returns a file description owning the detached
+// mount tree rooted at mnt, consuming the reference on mnt returned by its
+// creation. A reference is taken on the returned FileDescription.
+//
+// +checklocks:vfs.mountMu
+func (vfs *VirtualFilesystem) newDetachedMountFDLocked(ctx context.Context, mnt *Mount) *FileDescription {
+	mnt.detached = true
+	fd := &detachedMountFD{}
+	// This can't fail for O_PATH file descriptions.
+	fd.vfsfd.Init(fd, linux.O_PATH, mnt, mnt.root, &FileDescriptionOptions{})
+	fd.vfsfd.noNotify = true
+	return &fd.vfsfd
+}
+
+// Release implements FileDescriptionImpl.Release.
+func (fd *detachedMountFD) Release(ctx context.Context) {
+	mnt := fd.vfsfd.vd.mount
+	vfs := mnt.vfs
+	vfs.lockMounts()
+	defer vfs.unlockMounts(ctx)
+	if !mnt.detached {
+		return
+	}
+	mnt.detached = false
+	vfs.abortUncomittedChildren(ctx, mnt)
+	vfs.setPropagation(mnt, linux.MS_PRIVATE)
+	vfs.delayDecRef(mnt)
+}
@@ -0,0 +1,777 @@
+package vfs
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+const (
+	// LandlockAccessFS is the mask of supported Landlock filesystem access
+	// rights.
+	LandlockAccessFS = linux.LANDLOCK_ACCESS_FS_TRUNCATE<<1 - 1
+
+	// LandlockAccessNet is the mask of supported Landlock network access
+	// rights.
+	LandlockAccessNet = linux.LANDLOCK_ACCESS_NET_BIND_TCP | linux.LANDLOCK_ACCESS_NET_CONNECT_TCP
+
+	// landlockAccessFile is the mask of filesystem access rights that apply
+	// to files that aren't directories.
+	landlockAccessFile = linux.LANDLOCK_ACCESS_FS_EXECUTE | linux.LANDLOCK_ACCESS_FS_WRITE_FILE | linux.LANDLOCK_ACCESS_FS_READ_FILE | linux.LANDLOCK_ACCESS_FS_TRUNCATE
+
+	// landlockMaxLayers is the maximum number of rulesets a domain can be
+	// made of. It is analogous to LANDLOCK_MAX_NUM_LAYERS in Linux.
+	landlockMaxLayers = 16
+)
+
+// LandlockRuleset is a set of Landlock rules, as created by
+// landlock_create_ruleset(2) and populated by landlock_add_rule(2). It is
+// enforced on tasks by landlock_restrict_self(2), which adds it to their
+// LandlockDomain.
+//
+// +stateify savable
+type LandlockRuleset struct {
+	vfsfd FileDescription
+	FileDescriptionDefaultImpl
+	DentryMetadataFileDescriptionImpl
+	NoLockFD
+
+	// handledFS and handledNet are the access rights restricted by the
+	// ruleset. They are immutable.
+	handledFS  uint64
+	handledNet uint64
+
+	// mu protects the fields below.
+	mu sync.Mutex `state:"nosave"`
+
+	// fsRules maps the dentries of rules to the access rights allowed beneath
+	// them.
+	fsRules map[*Dentry]landlockFSRule
+
+	// netRules maps TCP ports to the access rights allowed on them.
+	netRules map[uint16]uint64
+}
+
+// landlockFSRule allows access to the files beneath a dentry.
+//
+// +stateify savable
+type landlockFSRule struct {
+	// fs and dentry are the file the rule was added for. References are held
+	// on both.
+	fs     *Filesystem
+	dentry *Dentry
+
+	// access is the mask of allowed access rights.
+	access uint64
+}
+
+// NewLandlockRulesetFD returns a FileDescription representing a new empty
+// Landlock ruleset, restricting the given access rights. A reference is taken
+// on the returned FileDescription.
+func (vfs *VirtualFilesystem) NewLandlockRulesetFD(ctx context.Context, handledFS, handledNet uint64) (*FileDescription, error) {
+	rs := &LandlockRuleset{
+		handledFS:  handledFS,
+		handledNet: handledNet,
+		fsRules:    make(map[*Dentry]landlockFSRule),
+		netRules:   make(map[uint16]uint64),
+	}
+	vd := vfs.NewAnonVirtualDentry("[landlock-ruleset]")
+	defer vd.DecRef(ctx)
+	if err := rs.vfsfd.Init(rs, linux.O_RDWR, vd.Mount(), vd.Dentry(), &FileDescriptionOptions{
+		DenyPRead:         true,
+		DenyPWrite:        true,
+		UseDentryMetadata: true,
+	}); err != nil {
+		return nil, err
+	}
+	return &rs.vfsfd, nil
+}
+
+// Release implements FileDescriptionImpl.Release.
+func (rs *LandlockRuleset) Release(ctx context.Context) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for _, r := range rs.fsRules {
+		r.decRef(ctx)
+	}
+	rs.fsRules = nil
+}
+
+// Read implements FileDescriptionImpl.Read.
+func (rs *LandlockRuleset) Read(ctx context.Context, dst usermem.IOSequence, opts ReadOptions) (int64, error) {
+	return 0, linuxerr.EINVAL
+}
+
+// Write implements FileDescriptionImpl.Write.
+func (rs *LandlockRuleset) Write(ctx context.Context, src usermem.IOSequence, opts WriteOptions) (int64, error) {
+	return 0, linuxerr.EINVAL
+}
+
+// AddPathBeneathRule allows the given access rights beneath the file at vd,
+// as for LANDLOCK_RULE_PATH_BENEATH. isDir is true if the file is a
+// directory.
+func (rs *LandlockRuleset) AddPathBeneathRule(ctx context.Context, vd VirtualDentry, isDir bool, access uint64) error {
+	if access == 0 {
+		return linuxerr.ENOMSG
+	}
+	if access&^rs.handledFS != 0 {
+		return linuxerr.EINVAL
+	}
+	if !isDir && access&^landlockAccessFile != 0 {
+		return linuxerr.EINVAL
+	}
+	// Files of internal filesystems can't be reached by path.
+	if vd.mount.neverConnected() {
+		return linuxerr.EBADFD
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if r, ok := rs.fsRules[vd.dentry]; ok {
+		r.access |= access
+		rs.fsRules[vd.dentry] = r
+		return nil
+	}
+	r := landlockFSRule{
+		fs:     vd.mount.fs,
+		dentry: vd.dentry,
+		access: access,
+	}
+	r.incRef()
+	rs.fsRules[vd.dentry] = r
+	return nil
+}
+
+// AddNetPortRule allows the given access rights on a TCP port, as for
+// LANDLOCK_RULE_NET_PORT.
+func (rs *LandlockRuleset) AddNetPortRule(access, port uint64) error {
+	if access == 0 {
+		return linuxerr.ENOMSG
+	}
+	if access&^rs.handledNet != 0 || port > 0xffff {
+		return linuxerr.EINVAL
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.netRules[uint16(port)] |= access
+	return nil
+}
+
+func (r *landlockFSRule) incRef() {
+	r.fs.IncRef()
+	r.dentry.IncRef()
+}
+
+func (r *landlockFSRule) decRef(ctx context.Context) {
+	r.dentry.DecRef(ctx)
+	r.fs.DecRef(ctx)
+}
+
+// matchesLocked returns true if r applies to the file whose path crosses
+// mounts at path, as returned by VirtualFilesystem.landlockPathLocked().
+//
+// +checklocks:r.fs.vfs.mountMu
+func (r *landlockFSRule) matchesLocked(path []VirtualDentry) bool {
+	for _, vd := range path {
+		if vd.mount.fs != r.fs || vd.mount.root == nil {
+			continue
+		}
+		// The rule's dentry must be reachable from the root of the mount for
+		// the path to go through it.
+		rvd := VirtualDentry{vd.mount, r.dentry}
+		impl := r.fs.impl
+		if impl.IsDescendant(VirtualDentry{vd.mount, vd.mount.root}, rvd) && impl.IsDescendant(rvd, vd) {
+			return true
+		}
+	}
+	return false
+}
+
+// LandlockDomain is the stack of Landlock rulesets enforced on a task, each
+// added by a call to landlock_restrict_self(2). LandlockDomains are
+// immutable; restricting a task replaces its domain. A nil LandlockDomain
+// doesn't restrict anything.
+//
+// +stateify savable
+type LandlockDomain struct {
+	// refs is the reference count. refs is accessed using atomic memory
+	// operations.
+	refs atomicbitops.Int64
+
+	// layers are the enforced rulesets, oldest first. A reference is held on
+	// each of them.
+	layers []*landlockLayer
+}
+
+// landlockLayer is a ruleset enforced by a LandlockDomain. It may be shared
+// by nested domains. landlockLayers are immutable.
+//
+// +stateify savable
+type landlockLayer struct {
+	// refs is the reference count. refs is accessed using atomic memory
+	// operations.
+	refs atomicbitops.Int64
+
+	handledFS  uint64
+	handledNet uint64
+	fsRules    []landlockFSRule
+	netRules   map[uint16]uint64
+}
+
+// Restrict returns a new LandlockDomain enforcing rs in addition to the
+// rulesets of d. A reference is taken on the returned LandlockDomain.
+func (d *LandlockDomain) Restrict(rs *LandlockRuleset) (*LandlockDomain, error) {
+	var layers []*landlockLayer
+	if d != nil {
+		layers = d.layers
+	}
+	if len(layers) >= landlockMaxLayers {
+		return nil, linuxerr.E2BIG
+	}
+
+	rs.mu.Lock()
+	layer := &landlockLayer{
+		refs:       atomicbitops.FromInt64(1),
+		handledFS:  rs.handledFS,
+		handledNet: rs.handledNet,
+		fsRules:    make([]landlockFSRule, 0, len(rs.fsRules)),
+		netRules:   make(map[uint16]uint64, len(rs.netRules)),
+	}
+	for _, r := range rs.fsRules {
+		r.incRef()
+		layer.fsRules = append(layer.fsRules, r)
+	}
+	for port, access := range rs.netRules {
+		layer.netRules[port] = access
+	}
+	rs.mu.Unlock()
+
+	nd := &LandlockDomain{
+		refs:   atomicbitops.FromInt64(1),
+		layers: make([]*landlockLayer, 0, len(layers)+1),
+	}
+	for _, l := range layers {
+		l.refs.Add(1)
+		nd.layers = append(nd.layers, l)
+	}
+	nd.layers = append(nd.layers, layer)
+	return nd, nil
+}
+
+// IncRef increments d's reference count. d may be nil.
+func (d *LandlockDomain) IncRef() {
+	if d != nil {
+		d.refs.Add(1)
+	}
+}
+
+// DecRef decrements d's reference count. d may be nil.
+func (d *LandlockDomain) DecRef(ctx context.Context) {
+	if d == nil || d.refs.Add(-1) != 0 {
+		return
+	}
+	for _, l := range d.layers {
+		if l.refs.Add(-1) != 0 {
+			continue
+		}
+		for _, r := range l.fsRules {
+			r.decRef(ctx)
+		}
+	}
+}
+
+// CheckNetPort returns EACCES if d denies the given network access rights on
+// a TCP port.
+func (d *LandlockDomain) CheckNetPort(access uint64, port uint16) error {
+	if d == nil {
+		return nil
+	}
+	for _, l := range d.layers {
+		if access&l.handledNet&^l.netRules[port] != 0 {
+			return linuxerr.EACCES
+		}
+	}
+	return nil
+}
+
+// HandlesFS returns true if d restricts any filesystem access.
+func (d *LandlockDomain) HandlesFS() bool {
+	if d == nil {
+		return false
+	}
+	for _, l := range d.layers {
+		if l.handledFS != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// landlockPathLocked returns vd followed by the mountpoints that a walk from
+// vd to the root of its mount namespace goes through.
+//
+// +checklocks:vfs.mountMu
+func (vfs *VirtualFilesystem) landlockPathLocked(vd VirtualDentry) []VirtualDentry {
+	path := []VirtualDentry{vd}
+	for vd.mount.parent() != nil {
+		vd = vd.mount.getKey()
+		path = append(path, vd)
+	}
+	return path
+}
+
+// landlockGranted returns, for each layer of d, the filesystem access rights
+// it restricts that are allowed on the file at vd.
+func (vfs *VirtualFilesystem) landlockGranted(ctx context.Context, d *LandlockDomain, vd VirtualDentry) []uint64 {
+	granted := make([]uint64, len(d.layers))
+	// Files of internal filesystems, like pipes and sockets reached through
+	// /proc/[pid]/fd, aren't restricted.
+	if vd.mount.neverConnected() {
+		for i, l := range d.layers {
+			granted[i] = l.handledFS
+		}
+		return granted
+	}
+	vfs.lockMounts()
+	defer vfs.unlockMounts(ctx)
+	path := vfs.landlockPathLocked(vd)
+	for i, l := range d.layers {
+		for j := range l.fsRules {
+			r := &l.fsRules[j]
+			if r.access&^granted[i] != 0 && r.matchesLocked(path) {
+				granted[i] |= r.access
+			}
+		}
+		granted[i] &= l.handledFS
+	}
+	return granted
+}
+
+// checkLandlockAccess returns EACCES if the Landlock domain of ctx denies the
+// given access rights on the file at vd.
+func (vfs *VirtualFilesystem) checkLandlockAccess(ctx context.Context, vd VirtualDentry, access uint64) error {
+	d := LandlockDomainFromContext(ctx)
+	if d == nil || access == 0 {
+		return nil
+	}
+	for i, granted := range vfs.landlockGranted(ctx, d, vd) {
+		if access&d.layers[i].handledFS&^granted != 0 {
+			return linuxerr.EACCES
+		}
+	}
+	return nil
+}
+
+// landlockParentAt returns the parent directory of the file at pop, and the
+// PathOperation of the file relative to it. A reference is taken on the
+// returned parent, which is the Start of the returned PathOperation.
+//
+// Operations of tasks whose Landlock domain restricts filesystem access
+// resolve the dentry that Landlock checks first, and then only operate
+// relative to it, so that they can't be redirected to another file after the
+// check. Errors of these lookups fail the operation.
+//
+// Preconditions: pop.Path.Begin.Ok().
+func (vfs *VirtualFilesystem) landlockParentAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation) (VirtualDentry, *PathOperation, error) {
+	parent, name, err := vfs.getParentDirAndName(ctx, creds, pop)
+	if err != nil {
+		return VirtualDentry{}, nil, err
+	}
+	path := fspath.Parse(name)
+	path.Dir = pop.Path.Dir
+	return parent, &PathOperation{
+		Root:               pop.Root,
+		Start:              parent,
+		Path:               path,
+		FollowFinalSymlink: pop.FollowFinalSymlink,
+	}, nil
+}
+
+// landlockChildAt returns the file at pop, as returned by landlockParentAt, or
+// an invalid VirtualDentry if it doesn't exist. A reference is taken on the
+// returned VirtualDentry if it is valid.
+func (vfs *VirtualFilesystem) landlockChildAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation) (VirtualDentry, error) {
+	vd, err := vfs.GetDentryAt(ctx, creds, pop, &GetDentryOptions{})
+	if linuxerr.Equals(linuxerr.ENOENT, err) {
+		return VirtualDentry{}, nil
+	}
+	return vd, err
+}
+
+// landlockFileType returns the type of the file at vd.
+func (vfs *VirtualFilesystem) landlockFileType(ctx context.Context, creds *auth.Credentials, vd VirtualDentry) (linux.FileMode, error) {
+	stat, err := vfs.StatAt(ctx, creds, &PathOperation{Root: vd, Start: vd}, &StatOptions{Mask: linux.STATX_TYPE})
+	if err != nil {
+		return 0, err
+	}
+	if stat.Mask&linux.STATX_TYPE == 0 {
+		return 0, linuxerr.EACCES
+	}
+	return linux.FileMode(stat.Mode).FileType(), nil
+}
+
+// landlockMakeAccess returns the access right required to create a file of
+// the given type.
+func landlockMakeAccess(mode linux.FileMode) uint64 {
+	switch mode.FileType() {
+	case linux.ModeDirectory:
+		return linux.LANDLOCK_ACCESS_FS_MAKE_DIR
+	case linux.ModeCharacterDevice:
+		return linux.LANDLOCK_ACCESS_FS_MAKE_CHAR
+	case linux.ModeBlockDevice:
+		return linux.LANDLOCK_ACCESS_FS_MAKE_BLOCK
+	case linux.ModeNamedPipe:
+		return linux.LANDLOCK_ACCESS_FS_MAKE_FIFO
+	case linux.ModeSocket:
+		return linux.LANDLOCK_ACCESS_FS_MAKE_SOCK
+	case linux.ModeSymlink:
+		return linux.LANDLOCK_ACCESS_FS_MAKE_SYM
+	default:
+		return linux.LANDLOCK_ACCESS_FS_MAKE_REG
+	}
+}
+
+// landlockRemoveAccess returns the access right required to remove a file of
+// the given type.
+func landlockRemoveAccess(mode linux.FileMode) uint64 {
+	if mode.IsDir() {
+		return linux.LANDLOCK_ACCESS_FS_REMOVE_DIR
+	}
+	return linux.LANDLOCK_ACCESS_FS_REMOVE_FILE
+}
+
+// landlockOpenAccess returns the access rights required to open a file with
+// the given options. isDir is true if the file is a directory.
+func landlockOpenAccess(opts *OpenOptions, isDir bool) uint64 {
+	// Unlike AccessTypesForOpenFlags(), O_TRUNC doesn't require write access;
+	// it requires LANDLOCK_ACCESS_FS_TRUNCATE instead.
+	var access uint64
+	if MayReadFileWithOpenFlags(opts.Flags) {
+		if isDir {
+			access |= linux.LANDLOCK_ACCESS_FS_READ_DIR
+		} else {
+			access |= linux.LANDLOCK_ACCESS_FS_READ_FILE
+		}
+	}
+	if MayWriteFileWithOpenFlags(opts.Flags) {
+		access |= linux.LANDLOCK_ACCESS_FS_WRITE_FILE
+	}
+	if opts.FileExec {
+		access |= linux.LANDLOCK_ACCESS_FS_EXECUTE
+	}
+	if opts.Flags&linux.O_TRUNC != 0 {
+		access |= linux.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return access
+}
+
+// checkLandlockCreateAt checks that the Landlock domain of ctx allows
+// creating a file of the given type at pop. If the domain restricts
+// filesystem access, it returns the PathOperation that the creation must use
+// instead of pop, and the checked parent directory, on which a reference is
+// taken. Otherwise it returns pop.
+//
+// Preconditions: pop.Path.Begin.Ok().
+func (vfs *VirtualFilesystem) checkLandlockCreateAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation, mode linux.FileMode) (*PathOperation, VirtualDentry, error) {
+	if !LandlockDomainFromContext(ctx).HandlesFS() {
+		return pop, VirtualDentry{}, nil
+	}
+	parent, ppop, err := vfs.landlockParentAt(ctx, creds, pop)
+	if err != nil {
+		return nil, VirtualDentry{}, err
+	}
+	if err := vfs.checkLandlockAccess(ctx, parent, landlockMakeAccess(mode)); err != nil {
+		// Like in Linux, creating a file that already exists fails with
+		// EEXIST.
+		child, cerr := vfs.landlockChildAt(ctx, creds, ppop)
+		parent.DecRef(ctx)
+		if cerr != nil {
+			return nil, VirtualDentry{}, cerr
+		}
+		if child.Ok() {
+			child.DecRef(ctx)
+			return nil, VirtualDentry{}, linuxerr.EEXIST
+		}
+		return nil, VirtualDentry{}, err
+	}
+	return ppop, parent, nil
+}
+
+// checkLandlockRemoveAt checks that the Landlock domain of ctx allows
+// removing the file of the given type at pop. It returns the PathOperation
+// that the removal must use like checkLandlockCreateAt.
+//
+// Preconditions: pop.Path.Begin.Ok().
+func (vfs *VirtualFilesystem) checkLandlockRemoveAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation, mode linux.FileMode) (*PathOperation, VirtualDentry, error) {
+	if !LandlockDomainFromContext(ctx).HandlesFS() {
+		return pop, VirtualDentry{}, nil
+	}
+	parent, ppop, err := vfs.landlockParentAt(ctx, creds, pop)
+	if err != nil {
+		return nil, VirtualDentry{}, err
+	}
+	if err := vfs.checkLandlockAccess(ctx, parent, landlockRemoveAccess(mode)); err != nil {
+		// Like in Linux, removing a file that doesn't exist fails with
+		// ENOENT.
+		child, cerr := vfs.landlockChildAt(ctx, creds, ppop)
+		parent.DecRef(ctx)
+		if cerr != nil {
+			return nil, VirtualDentry{}, cerr
+		}
+		if !child.Ok() {
+			return nil, VirtualDentry{}, linuxerr.ENOENT
+		}
+		child.DecRef(ctx)
+		return nil, VirtualDentry{}, err
+	}
+	return ppop, parent, nil
+}
+
+// checkLandlockTruncateAt checks that the Landlock domain of ctx allows
+// truncating the file at pop. It returns the PathOperation that the
+// truncation must use like checkLandlockCreateAt, with the checked file
+// instead of its parent.
+func (vfs *VirtualFilesystem) checkLandlockTruncateAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation) (*PathOperation, VirtualDentry, error) {
+	if !LandlockDomainFromContext(ctx).HandlesFS() {
+		return pop, VirtualDentry{}, nil
+	}
+	vd, err := vfs.GetDentryAt(ctx, creds, pop, &GetDentryOptions{})
+	if err != nil {
+		return nil, VirtualDentry{}, err
+	}
+	if err := vfs.checkLandlockAccess(ctx, vd, linux.LANDLOCK_ACCESS_FS_TRUNCATE); err != nil {
+		vd.DecRef(ctx)
+		return nil, VirtualDentry{}, err
+	}
+	return &PathOperation{Root: pop.Root, Start: vd}, vd, nil
+}
+
+// landlockOpenAt implements OpenAt for tasks whose Landlock domain restricts
+// filesystem access. The file is resolved and checked before it is opened, so
+// that opening doesn't have side effects on files that are denied.
+func (vfs *VirtualFilesystem) landlockOpenAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation, opts *OpenOptions) (*FileDescription, error) {
+	if !pop.Path.Begin.Ok() || opts.Flags&linux.O_CREAT == 0 {
+		vd, err := vfs.GetDentryAt(ctx, creds, pop, &GetDentryOptions{})
+		if err != nil {
+			return nil, err
+		}
+		defer vd.DecRef(ctx)
+		return vfs.landlockOpenDentry(ctx, creds, pop.Root, vd, opts)
+	}
+
+	// Resolve the parent directory once, and check either the existing file
+	// in it or the creation of the file.
+	for i := 0; i <= linux.MaxSymlinkTraversals; i++ {
+		parent, ppop, err := vfs.landlockParentAt(ctx, creds, pop)
+		if err != nil {
+			return nil, err
+		}
+		defer parent.DecRef(ctx)
+		if opts.Flags&linux.O_EXCL != 0 {
+			// O_EXCL fails on existing files, including symlinks.
+			ppop.FollowFinalSymlink = false
+		}
+		vd, err := vfs.GetDentryAt(ctx, creds, ppop, &GetDentryOptions{})
+		if err == nil {
+			defer vd.DecRef(ctx)
+			if opts.Flags&linux.O_EXCL != 0 {
+				return nil, linuxerr.EEXIST
+			}
+			return vfs.landlockOpenDentry(ctx, creds, pop.Root, vd, opts)
+		}
+		if !linuxerr.Equals(linuxerr.ENOENT, err) {
+			return nil, err
+		}
+		if ppop.FollowFinalSymlink && opts.Flags&linux.O_EXCL == 0 {
+			// Like in Linux, the target of a dangling symlink is created.
+			target, err := vfs.ReadlinkAt(ctx, creds, &PathOperation{
+				Root:  pop.Root,
+				Start: parent,
+				Path:  ppop.Path,
+			})
+			if err == nil {
+				pop = &PathOperation{
+					Root:               pop.Root,
+					Start:              parent,
+					Path:               fspath.Parse(target),
+					FollowFinalSymlink: true,
+				}
+				continue
+			}
+		}
+		// The new file is only accessible through parent.
+		access := linux.LANDLOCK_ACCESS_FS_MAKE_REG | landlockOpenAccess(opts, false /* isDir */)
+		if err := vfs.checkLandlockAccess(ctx, parent, access); err != nil {
+			return nil, err
+		}
+		// With O_EXCL, a file created concurrently isn't opened without being
+		// checked. Retry to check it instead.
+		createOpts := *opts
+		createOpts.Flags |= linux.O_EXCL
+		fd, err := vfs.openAt(ctx, creds, ppop, &createOpts)
+		if err == nil || opts.Flags&linux.O_EXCL != 0 || !linuxerr.Equals(linuxerr.EEXIST, err) {
+			return fd, err
+		}
+	}
+	return nil, linuxerr.ELOOP
+}
+
+// landlockOpenDentry checks that the Landlock domain of ctx allows opening
+// the file at vd with the given options, and opens it.
+func (vfs *VirtualFilesystem) landlockOpenDentry(ctx context.Context, creds *auth.Credentials, root, vd VirtualDentry, opts *OpenOptions) (*FileDescription, error) {
+	mode, err := vfs.landlockFileType(ctx, creds, vd)
+	if err != nil {
+		return nil, err
+	}
+	// O_TMPFILE creates a regular file in the directory at vd.
+	isDir := opts.Flags&linux.O_TMPFILE == 0 && mode.IsDir()
+	if err := vfs.checkLandlockAccess(ctx, vd, landlockOpenAccess(opts, isDir)); err != nil {
+		return nil, err
+	}
+	return vfs.openAt(ctx, creds, &PathOperation{Root: root, Start: vd}, opts)
+}
+
+// checkLandlockLinkAt checks that the Landlock domain of ctx allows creating
+// a hard link at newpop to the file at old. oldParent is the directory that
+// old was resolved in, if it's known. It returns the PathOperation that the
+// link must use like checkLandlockCreateAt.
+//
+// Preconditions: newpop.Path.Begin.Ok().
+func (vfs *VirtualFilesystem) checkLandlockLinkAt(ctx context.Context, creds *auth.Credentials, oldParent, old VirtualDentry, newpop *PathOperation) (*PathOperation, VirtualDentry, error) {
+	if !LandlockDomainFromContext(ctx).HandlesFS() {
+		return newpop, VirtualDentry{}, nil
+	}
+	newParent, ppop, err := vfs.landlockParentAt(ctx, creds, newpop)
+	if err != nil {
+		return nil, VirtualDentry{}, err
+	}
+	if err := vfs.checkLandlockReferAt(ctx, creds, oldParent, old, newParent, ppop, false /* rename */, false /* exchange */); err != nil {
+		newParent.DecRef(ctx)
+		return nil, VirtualDentry{}, err
+	}
+	return ppop, newParent, nil
+}
+
+// checkLandlockRenameAt checks that the Landlock domain of ctx allows
+// renaming the file oldName in oldParent to newpop. It returns the
+// PathOperation that the rename must use like checkLandlockCreateAt.
+//
+// Preconditions: newpop.Path.Begin.Ok().
+func (vfs *VirtualFilesystem) checkLandlockRenameAt(ctx context.Context, creds *auth.Credentials, oldpop *PathOperation, oldParent VirtualDentry, oldName string, newpop *PathOperation, opts *RenameOptions) (*PathOperation, VirtualDentry, error) {
+	if !LandlockDomainFromContext(ctx).HandlesFS() {
+		return newpop, VirtualDentry{}, nil
+	}
+	old, err := vfs.GetDentryAt(ctx, creds, &PathOperation{
+		Root:  oldpop.Root,
+		Start: oldParent,
+		Path:  fspath.Parse(oldName),
+	}, &GetDentryOptions{})
+	if err != nil {
+		return nil, VirtualDentry{}, err
+	}
+	defer old.DecRef(ctx)
+	newParent, ppop, err := vfs.landlockParentAt(ctx, creds, newpop)
+	if err != nil {
+		return nil, VirtualDentry{}, err
+	}
+	if err := vfs.checkLandlockReferAt(ctx, creds, oldParent, old, newParent, ppop, true /* rename */, opts.Flags&linux.RENAME_EXCHANGE != 0); err != nil {
+		newParent.DecRef(ctx)
+		return nil, VirtualDentry{}, err
+	}
+	return ppop, newParent, nil
+}
+
+// checkLandlockReferAt checks that the Landlock domain of ctx allows
+// linking (if rename is false) or renaming (if rename is true) the file at
+// old, in oldParent, to newpop in newParent, as returned by landlockParentAt.
+// It is analogous to security/landlock/fs.c:current_check_refer_path() in
+// Linux: moving a file to another directory requires
+// LANDLOCK_ACCESS_FS_REFER on both directories, and must not grant it access
+// rights it doesn't have in its original location.
+func (vfs *VirtualFilesystem) checkLandlockReferAt(ctx context.Context, creds *auth.Credentials, oldParent, old, newParent VirtualDentry, newpop *PathOperation, rename, exchange bool) error {
+	d := LandlockDomainFromContext(ctx)
+	replaced, err := vfs.landlockChildAt(ctx, creds, newpop)
+	if err != nil {
+		return err
+	}
+	if replaced.Ok() {
+		defer replaced.DecRef(ctx)
+	}
+	// Like in Linux, links over existing files fail with EEXIST, and
+	// exchanges with missing files fail with ENOENT.
+	if !rename && replaced.Ok() {
+		return linuxerr.EEXIST
+	}
+	if exchange && !replaced.Ok() {
+		return linuxerr.ENOENT
+	}
+
+	oldMode, err := vfs.landlockFileType(ctx, creds, old)
+	if err != nil {
+		return err
+	}
+	oldAccess := uint64(0)
+	newAccess := landlockMakeAccess(oldMode)
+	if rename {
+		oldAccess |= landlockRemoveAccess(oldMode)
+	}
+	if replaced.Ok() {
+		replacedMode, err := vfs.landlockFileType(ctx, creds, replaced)
+		if err != nil {
+			return err
+		}
+		if rename || exchange {
+			newAccess |= landlockRemoveAccess(replacedMode)
+		}
+		if exchange {
+			oldAccess |= landlockMakeAccess(replacedMode)
+		}
+	}
+
+	if oldParent.Ok() && oldParent == newParent {
+		// No reparenting; LANDLOCK_ACCESS_FS_REFER isn't required.
+		return vfs.checkLandlockAccess(ctx, newParent, oldAccess|newAccess)
+	}
+	if err := vfs.checkLandlockAccess(ctx, newParent, newAccess); err != nil {
+		return err
+	}
+	if oldParent.Ok() {
+		if err := vfs.checkLandlockAccess(ctx, oldParent, oldAccess); err != nil {
+			return err
+		}
+	}
+
+	// Reparenting is denied with EXDEV, so that applications fall back to
+	// copying files.
+	const refer = linux.LANDLOCK_ACCESS_FS_REFER
+	newGranted := vfs.landlockGranted(ctx, d, newParent)
+	oldGranted := vfs.landlockGranted(ctx, d, old)
+	var oldParentGranted, replacedGranted []uint64
+	if oldParent.Ok() {
+		oldParentGranted = vfs.landlockGranted(ctx, d, oldParent)
+	}
+	if exchange {
+		replacedGranted = vfs.landlockGranted(ctx, d, replaced)
+	}
+	for i, l := range d.layers {
+		if l.handledFS == 0 {
+			continue
+		}
+		// LANDLOCK_ACCESS_FS_REFER is always restricted, so that rulesets
+		// that don't know about it keep denying reparenting.
+		if newGranted[i]&refer == 0 || oldParentGranted == nil || oldParentGranted[i]&refer == 0 {
+			return linuxerr.EXDEV
+		}
+		if newGranted[i]&^refer&^oldGranted[i] != 0 {
+			return linuxerr.EXDEV
+		}
+		if exchange && oldParentGranted[i]&^refer&^replacedGranted[i] != 0 {
+			return linuxerr.EXDEV
+		}
+	}
+	return nil
+}
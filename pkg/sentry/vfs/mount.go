@@ -118,6 +118,28 @@ type Mount struct {
 	// umounted is true. umounted is protected by VirtualFilesystem.mountMu.
 	umounted bool
 
+	// detached is true if this Mount is the root of a mount tree created by
+	// fsmount(2) or open_tree(2) with OPEN_TREE_CLONE that hasn't been
+	// attached by move_mount(2) yet. The tree is owned by a detachedMountFD,
+	// which holds the reference returned by the tree's creation. detached is
+	// protected by VirtualFilesystem.mountMu.
+	detached bool
+
+	// locked is true if this Mount was inherited by a mount namespace owned by
+	// a less privileged user namespace, in which case it can't be unmounted or
+	// moved away from its parent, since that would reveal what it covers. It
+	// is analogous to MNT_LOCKED in Linux. locked is protected by
+	// VirtualFilesystem.mountMu.
+	locked bool
+
+	// lockedOpts, if not nil, are the options this Mount had when it was
+	// inherited by a mount namespace owned by a less privileged user
+	// namespace. The read-only state and flags set in lockedOpts can't be
+	// cleared, and NoATime can't be changed. lockedOpts is analogous to the
+	// MNT_LOCK_* flags in Linux, and is protected by
+	// VirtualFilesystem.mountMu.
+	lockedOpts *MountOptions
+
 	// The lower 63 bits of writers is the number of calls to
 	// Mount.CheckBeginWrite() that have not yet been paired with a call to
 	// Mount.EndWrite(). The MSB of writers is set if MS_RDONLY is in effect.
@@ -325,6 +347,14 @@ func (vfs *VirtualFilesystem) attachTreeLocked(ctx context.Context, mnt *Mount,
 	mp.dentry.mu.Unlock()
 	vfs.commitChildren(ctx, mnt)
 	for pmnt := range propMnts {
+		// Mounts propagated into a namespace owned by another user namespace
+		// can't reveal what's under their submounts there. The propagated
+		// mount itself can be unmounted, since it only covers what was already
+		// visible.
+		if pmnt.parent().ns.Owner != mp.mount.ns.Owner {
+			vfs.lockTreeLocked(pmnt)
+			pmnt.locked = false
+		}
 		vfs.commitMount(ctx, pmnt)
 	}
 	return nil
@@ -453,9 +483,43 @@ func (vfs *VirtualFilesystem) cloneMount(mnt *Mount, root *Dentry, mopts *MountO
 	if cloneType&makeSharedClone != 0 {
 		clone.isShared = true
 	}
+	clone.locked = mnt.locked
+	clone.lockedOpts = mnt.lockedOpts
 	return clone, nil
 }
 
+// lockTreeLocked locks the options of all mounts in the tree rooted at mnt,
+// and prevents them from being unmounted or moved. It is used when mounts
+// are inherited by a mount namespace owned by a less privileged user
+// namespace, and is analogous to fs/namespace.c:lock_mnt_tree() in Linux.
+//
+// +checklocks:vfs.mountMu
+func (vfs *VirtualFilesystem) lockTreeLocked(mnt *Mount) {
+	for _, m := range mnt.submountsLocked() {
+		m.locked = true
+		m.lockedOpts = &MountOptions{
+			Flags:    m.flags,
+			ReadOnly: m.ReadOnlyLocked(),
+		}
+	}
+}
+
+// hasLockedChildrenLocked returns true if any mount beneath d on mnt is
+// locked. It is analogous to fs/namespace.c:has_locked_children() in Linux.
+//
+// +checklocks:vfs.mountMu
+func (vfs *VirtualFilesystem) hasLockedChildrenLocked(mnt *Mount, d *Dentry) bool {
+	for c := range mnt.children {
+		if !c.locked {
+			continue
+		}
+		if mnt.fs.Impl().IsDescendant(VirtualDentry{mnt, d}, c.getKey()) {
+			return true
+		}
+	}
+	return false
+}
+
 type cloneTreeNode struct {
 	prevMount   *Mount
 	parentMount *Mount
@@ -544,6 +608,11 @@ func (vfs *VirtualFilesystem) BindAt(ctx context.Context, creds *auth.Credential
 		return linuxerr.EINVAL
 	}
 
+	// A non-recursive bind mount would reveal what locked mounts cover.
+	if !recursive && vfs.hasLockedChildrenLocked(sourceVd.mount, sourceVd.dentry) {
+		return linuxerr.EINVAL
+	}
+
 	var clone *Mount
 	if recursive {
 		clone, err = vfs.cloneMountTree(ctx, sourceVd.mount, sourceVd.dentry, 0, nil)
@@ -553,6 +622,7 @@ func (vfs *VirtualFilesystem) BindAt(ctx context.Context, creds *auth.Credential
 	if err != nil {
 		return err
 	}
+	clone.locked = false
 	cleanup.Release()
 
 	vfs.delayDecRef(clone)
@@ -563,6 +633,115 @@ func (vfs *VirtualFilesystem) BindAt(ctx context.Context, creds *auth.Credential
 	return nil
 }
 
+// CloneMountTreeAt returns an O_PATH file description of a detached clone of
+// the mount at the source path, rooted at the source path's dentry. If
+// recursive is true, the mounts beneath it are cloned as well. It implements
+// open_tree(2) with OPEN_TREE_CLONE.
+func (vfs *VirtualFilesystem) CloneMountTreeAt(ctx context.Context, creds *auth.Credentials, source *PathOperation, recursive bool) (*FileDescription, error) {
+	vd, err := vfs.GetDentryAt(ctx, creds, source, &GetDentryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer vd.DecRef(ctx)
+
+	vfs.lockMounts()
+	defer vfs.unlockMounts(ctx)
+	fsName := vd.mount.Filesystem().FilesystemType().Name()
+	if !vfs.validInMountNS(ctx, vd.mount) && fsName != nsfsName && fsName != cgroupFsName {
+		return nil, linuxerr.EINVAL
+	}
+	if !recursive && vfs.hasLockedChildrenLocked(vd.mount, vd.dentry) {
+		return nil, linuxerr.EINVAL
+	}
+	var clone *Mount
+	if recursive {
+		clone, err = vfs.cloneMountTree(ctx, vd.mount, vd.dentry, 0, nil)
+	} else {
+		clone, err = vfs.cloneMount(vd.mount, vd.dentry, nil, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	clone.locked = false
+	return vfs.newDetachedMountFDLocked(ctx, clone), nil
+}
+
+// MoveMountAt moves the mount at the source path to the target path. If the
+// source is a detached mount tree, it's attached instead. It implements
+// move_mount(2).
+func (vfs *VirtualFilesystem) MoveMountAt(ctx context.Context, creds *auth.Credentials, source, target *PathOperation) error {
+	sourceVd, err := vfs.getMountpoint(ctx, creds, source)
+	if err != nil {
+		return err
+	}
+	defer sourceVd.DecRef(ctx)
+	targetVd, err := vfs.GetDentryAt(ctx, creds, target, &GetDentryOptions{})
+	if err != nil {
+		return err
+	}
+
+	vfs.lockMounts()
+	defer vfs.unlockMounts(ctx)
+	mp, err := vfs.lockMountpoint(targetVd)
+	if err != nil {
+		return err
+	}
+	cleanup := cleanup.Make(func() {
+		mp.dentry.mu.Unlock()
+		vfs.delayDecRef(mp) // +checklocksforce
+	})
+	defer cleanup.Clean()
+	if !vfs.validInMountNS(ctx, mp.mount) {
+		return linuxerr.EINVAL
+	}
+
+	mnt := sourceVd.mount
+	if mnt.detached {
+		cleanup.Release()
+		if err := vfs.attachTreeLocked(ctx, mnt, mp); err != nil {
+			return err
+		}
+		// The mount namespace now holds the tree.
+		mnt.detached = false
+		vfs.delayDecRef(mnt)
+		return nil
+	}
+
+	if !vfs.validInMountNS(ctx, mnt) || mnt.parent() == nil || mnt.locked {
+		return linuxerr.EINVAL
+	}
+	// Mounts can't be moved out of a shared parent, since the move couldn't
+	// be propagated to its peers.
+	if mnt.parent().isShared {
+		return linuxerr.EINVAL
+	}
+	// A mount can't be moved beneath itself.
+	for m := mp.mount; m != nil; m = m.parent() {
+		if m == mnt {
+			return linuxerr.ELOOP
+		}
+	}
+	cleanup.Release()
+
+	vfs.mounts.seq.BeginWrite()
+	oldMp := vfs.disconnectLocked(mnt)
+	vfs.mounts.seq.EndWrite()
+	if err := vfs.attachTreeLocked(ctx, mnt, mp); err != nil {
+		vfs.mounts.seq.BeginWrite()
+		oldMp.dentry.mu.Lock()
+		vfs.connectLocked(mnt, oldMp, oldMp.mount.ns)
+		oldMp.dentry.mu.Unlock()
+		vfs.mounts.seq.EndWrite()
+		vfs.delayDecRef(mnt)
+		return err
+	}
+	vfs.delayDecRef(oldMp)
+	// Drop the reference taken by the mount's original connection;
+	// attachTreeLocked() took a new one.
+	vfs.delayDecRef(mnt)
+	return nil
+}
+
 // RemountAt changes the mountflags and data of an existing mount without having to unmount and remount the filesystem.
 func (vfs *VirtualFilesystem) RemountAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation, opts *MountOptions) error {
 	vd, err := vfs.getMountpoint(ctx, creds, pop)
@@ -576,9 +755,70 @@ func (vfs *VirtualFilesystem) RemountAt(ctx context.Context, creds *auth.Credent
 	if !vfs.validInMountNS(ctx, mnt) {
 		return linuxerr.EINVAL
 	}
+	if opts != nil && !mnt.canChangeLockedOptionsLocked(opts.Flags, opts.ReadOnly) {
+		return linuxerr.EPERM
+	}
 	return mnt.setMountOptions(opts)
 }
 
+// SetMountAttrAt changes the attributes of the mount at the given path, and
+// of the mounts beneath it if opts.Recursive is true. It implements
+// mount_setattr(2).
+func (vfs *VirtualFilesystem) SetMountAttrAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation, opts *MountAttrOptions) error {
+	vd, err := vfs.getMountpoint(ctx, creds, pop)
+	if err != nil {
+		return err
+	}
+	defer vd.DecRef(ctx)
+	vfs.lockMounts()
+	defer vfs.unlockMounts(ctx)
+	mnt := vd.Mount()
+	if !vfs.validInMountNS(ctx, mnt) && !mnt.detached {
+		return linuxerr.EINVAL
+	}
+	mnts := []*Mount{mnt}
+	if opts.Recursive {
+		mnts = mnt.submountsLocked()
+	}
+	for _, m := range mnts {
+		ro := m.ReadOnlyLocked()
+		if (opts.Set|opts.Clear)&linux.MOUNT_ATTR_RDONLY != 0 {
+			ro = opts.Set&linux.MOUNT_ATTR_RDONLY != 0
+		}
+		if !m.canChangeLockedOptionsLocked(opts.apply(m.flags), ro) {
+			return linuxerr.EPERM
+		}
+	}
+
+	// Changing the read-only state is the only change that can fail, so do
+	// it first and roll it back on failure.
+	if (opts.Set|opts.Clear)&linux.MOUNT_ATTR_RDONLY != 0 {
+		ro := opts.Set&linux.MOUNT_ATTR_RDONLY != 0
+		wasRO := make([]bool, len(mnts))
+		for i, m := range mnts {
+			wasRO[i] = m.ReadOnlyLocked()
+			if err := m.setReadOnlyLocked(ro); err != nil {
+				for j, m := range mnts[:i] {
+					m.setReadOnlyLocked(wasRO[j])
+				}
+				return err
+			}
+		}
+	}
+	if opts.Propagation == linux.MS_SHARED {
+		if err := vfs.allocMountGroupIDs(mnt, opts.Recursive); err != nil {
+			return err
+		}
+	}
+	for _, m := range mnts {
+		m.flags = opts.apply(m.flags)
+		if opts.Propagation != 0 {
+			vfs.setPropagation(m, opts.Propagation)
+		}
+	}
+	return nil
+}
+
 // MountAt creates and mounts a Filesystem configured by the given arguments.
 // The VirtualFilesystem will hold a reference to the Mount until it is
 // unmounted.
@@ -621,7 +861,7 @@ func (vfs *VirtualFilesystem) UmountAt(ctx context.Context, creds *auth.Credenti
 	if !vfs.validInMountNS(ctx, vd.mount) {
 		return linuxerr.EINVAL
 	}
-	if vd.mount == vd.mount.ns.root {
+	if vd.mount == vd.mount.ns.root || vd.mount.locked {
 		return linuxerr.EINVAL
 	}
 
@@ -1148,6 +1388,10 @@ func (vfs *VirtualFilesystem) PivotRoot(ctx context.Context, creds *auth.Credent
 	if oldRoot.mount.parent() == nil || newRoot.mount.parent() == nil {
 		return newRoot, oldRoot, linuxerr.EINVAL
 	}
+	// The new root can't be moved away from what it covers if it's locked.
+	if newRoot.mount.locked {
+		return newRoot, oldRoot, linuxerr.EINVAL
+	}
 	// Either the mount point at new_root, or the parent mount of that mount
 	// point, has propagation type MS_SHARED.
 	if newRootParent := newRoot.mount.parent(); newRoot.mount.isShared || newRootParent.isShared {
@@ -1173,6 +1417,12 @@ func (vfs *VirtualFilesystem) PivotRoot(ctx context.Context, creds *auth.Credent
 	rootMp.dentry.mu.Unlock()
 	vfs.mounts.seq.EndWrite()
 
+	// The new root takes the place of the old one, including its lock.
+	if oldRoot.mount.locked {
+		newRoot.mount.locked = true
+		oldRoot.mount.locked = false
+	}
+
 	vfs.delayDecRef(newRoot.mount)
 	vfs.delayDecRef(oldRoot.mount)
 
@@ -1225,6 +1475,32 @@ func (mnt *Mount) setReadOnlyLocked(ro bool) error {
 	return nil
 }
 
+// canChangeLockedOptionsLocked returns true if mnt's flags and read-only
+// state can be changed to flags and ro without clearing options locked by
+// VirtualFilesystem.lockTreeLocked(). It is analogous to
+// fs/namespace.c:can_change_locked_flags() in Linux.
+//
+// Preconditions: VirtualFilesystem.mountMu must be locked.
+func (mnt *Mount) canChangeLockedOptionsLocked(flags MountFlags, ro bool) bool {
+	locked := mnt.lockedOpts
+	if locked == nil {
+		return true
+	}
+	switch {
+	case locked.ReadOnly && !ro:
+		return false
+	case locked.Flags.NoSUID && !flags.NoSUID:
+		return false
+	case locked.Flags.NoDev && !flags.NoDev:
+		return false
+	case locked.Flags.NoExec && !flags.NoExec:
+		return false
+	case locked.Flags.NoATime != flags.NoATime:
+		return false
+	}
+	return true
+}
+
 // ReadOnly returns true if mount is readonly.
 func (mnt *Mount) ReadOnly() bool {
 	mnt.vfs.lockMounts()
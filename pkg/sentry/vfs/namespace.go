@@ -187,6 +187,11 @@ func (vfs *VirtualFilesystem) CloneMountNamespace(
 	}
 	newns.root = newRoot
 	newns.root.ns = newns
+	// Mounts inherited from a more privileged namespace can't be used to
+	// reveal what they cover, or to drop restrictions on what they contain.
+	if ns.Owner != newns.Owner {
+		vfs.lockTreeLocked(newRoot)
+	}
 	vfs.commitChildren(ctx, newRoot)
 	return newns, nil
 }
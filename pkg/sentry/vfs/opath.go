@@ -164,5 +164,8 @@ func (vfs *VirtualFilesystem) openOPathFD(ctx context.Context, creds *auth.Crede
 	if err := fd.vfsfd.Init(fd, flags, vd.Mount(), vd.Dentry(), &FileDescriptionOptions{}); err != nil {
 		return nil, err
 	}
+	// O_PATH files aren't opened for I/O, so they don't generate fanotify
+	// events.
+	fd.vfsfd.noNotify = true
 	return &fd.vfsfd, err
 }
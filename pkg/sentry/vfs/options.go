@@ -114,6 +114,46 @@ type MountOptions struct {
 	GetFilesystemOptions GetFilesystemOptions
 }
 
+// MountAttrOptions contains options to VirtualFilesystem.SetMountAttrAt(), as
+// specified for mount_setattr(2).
+type MountAttrOptions struct {
+	// Set and Clear are the MOUNT_ATTR_* flags to set and clear. Setting
+	// MOUNT_ATTR_NOATIME requires clearing MOUNT_ATTR__ATIME.
+	Set   uint64
+	Clear uint64
+
+	// Propagation is the propagation type to set, i.e. one of MS_SHARED,
+	// MS_PRIVATE, MS_SLAVE and MS_UNBINDABLE, or 0 to leave it unchanged.
+	Propagation uint32
+
+	// If Recursive is true, the attributes are changed on all mounts beneath
+	// the mount as well.
+	Recursive bool
+}
+
+// apply returns flags with the attributes of opts applied.
+func (opts *MountAttrOptions) apply(flags MountFlags) MountFlags {
+	for _, attr := range []struct {
+		mask uint64
+		flag *bool
+	}{
+		{linux.MOUNT_ATTR_NOSUID, &flags.NoSUID},
+		{linux.MOUNT_ATTR_NODEV, &flags.NoDev},
+		{linux.MOUNT_ATTR_NOEXEC, &flags.NoExec},
+	} {
+		if opts.Clear&attr.mask != 0 {
+			*attr.flag = false
+		}
+		if opts.Set&attr.mask != 0 {
+			*attr.flag = true
+		}
+	}
+	if opts.Clear&linux.MOUNT_ATTR__ATIME != 0 {
+		flags.NoATime = opts.Set&linux.MOUNT_ATTR__ATIME == linux.MOUNT_ATTR_NOATIME
+	}
+	return flags
+}
+
 // OpenOptions contains options to VirtualFilesystem.OpenAt() and
 // FilesystemImpl.OpenAt().
 //
@@ -138,6 +178,10 @@ type OpenOptions struct {
 	// on the file, that the file is a regular file, and that the mount doesn't
 	// have MS_NOEXEC set.
 	FileExec bool
+
+	// NoNotify is set when the file is opened for a fanotify listener. Such
+	// files don't generate fanotify events.
+	NoNotify bool
 }
 
 // ReadOptions contains options to FileDescription.PRead(),
@@ -151,6 +151,9 @@ type VirtualFilesystem struct {
 	mountPromisesMu sync.RWMutex `state:"nosave"`
 	mountPromises   map[VirtualDentry]*waiter.Queue
 
+	// fanotify contains the listeners of fanotify events.
+	fanotify fanotifyListeners
+
 	// toDecRef contains all the reference counted objects that needed to be
 	// DecRefd while mountMu was held. It is cleared every time unlockMounts is
 	// called and protected by mountMu.
@@ -308,6 +311,18 @@ func (vfs *VirtualFilesystem) getParentDirAndName(ctx context.Context, creds *au
 // LinkAt creates a hard link at newpop representing the existing file at
 // oldpop.
 func (vfs *VirtualFilesystem) LinkAt(ctx context.Context, creds *auth.Credentials, oldpop, newpop *PathOperation) error {
+	// If the old path is empty (AT_EMPTY_PATH) or its final symlink is
+	// followed, its parent directory is unknown, and Landlock handles the link
+	// like a reparenting from a directory without LANDLOCK_ACCESS_FS_REFER.
+	var oldParentVD VirtualDentry
+	if LandlockDomainFromContext(ctx).HandlesFS() && oldpop.Path.Begin.Ok() && !oldpop.FollowFinalSymlink {
+		var err error
+		oldParentVD, oldpop, err = vfs.landlockParentAt(ctx, creds, oldpop)
+		if err != nil {
+			return err
+		}
+		defer oldParentVD.DecRef(ctx)
+	}
 	oldVD, err := vfs.GetDentryAt(ctx, creds, oldpop, &GetDentryOptions{})
 	if err != nil {
 		return err
@@ -325,6 +340,14 @@ func (vfs *VirtualFilesystem) LinkAt(ctx context.Context, creds *auth.Credential
 		ctx.Warningf("VirtualFilesystem.LinkAt: file creation paths can't follow final symlink")
 		return linuxerr.EINVAL
 	}
+	newpop, newParentVD, err := vfs.checkLandlockLinkAt(ctx, creds, oldParentVD, oldVD, newpop)
+	if err != nil {
+		oldVD.DecRef(ctx)
+		return err
+	}
+	if newParentVD.Ok() {
+		defer newParentVD.DecRef(ctx)
+	}
 
 	rp := vfs.getResolvingPath(creds, newpop)
 	for {
@@ -365,6 +388,13 @@ func (vfs *VirtualFilesystem) MkdirAt(ctx context.Context, creds *auth.Credentia
 	// "Under Linux, apart from the permission bits, the S_ISVTX mode bit is
 	// also honored." - mkdir(2)
 	opts.Mode &= 0777 | linux.S_ISVTX
+	pop, parentVD, err := vfs.checkLandlockCreateAt(ctx, creds, pop, linux.ModeDirectory)
+	if err != nil {
+		return err
+	}
+	if parentVD.Ok() {
+		defer parentVD.DecRef(ctx)
+	}
 
 	rp := vfs.getResolvingPath(creds, pop)
 	for {
@@ -401,6 +431,13 @@ func (vfs *VirtualFilesystem) MknodAt(ctx context.Context, creds *auth.Credentia
 		ctx.Warningf("VirtualFilesystem.MknodAt: file creation paths can't follow final symlink")
 		return linuxerr.EINVAL
 	}
+	pop, parentVD, err := vfs.checkLandlockCreateAt(ctx, creds, pop, opts.Mode)
+	if err != nil {
+		return err
+	}
+	if parentVD.Ok() {
+		defer parentVD.DecRef(ctx)
+	}
 
 	rp := vfs.getResolvingPath(creds, pop)
 	for {
@@ -466,6 +503,14 @@ func (vfs *VirtualFilesystem) OpenAt(ctx context.Context, creds *auth.Credential
 	if opts.Flags&linux.O_PATH != 0 {
 		return vfs.openOPathFD(ctx, creds, pop, opts.Flags)
 	}
+	if LandlockDomainFromContext(ctx).HandlesFS() {
+		return vfs.landlockOpenAt(ctx, creds, pop, opts)
+	}
+	return vfs.openAt(ctx, creds, pop, opts)
+}
+
+// openAt implements OpenAt after opts are validated.
+func (vfs *VirtualFilesystem) openAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation, opts *OpenOptions) (*FileDescription, error) {
 	rp := vfs.getResolvingPath(creds, pop)
 	if opts.Flags&linux.O_DIRECTORY != 0 {
 		rp.mustBeDir = true
@@ -494,7 +539,23 @@ func (vfs *VirtualFilesystem) OpenAt(ctx context.Context, creds *auth.Credential
 				}
 			}
 
+			fd.noNotify = opts.NoNotify
+			fd.landlockDenyTruncate = vfs.checkLandlockAccess(ctx, fd.vd, linux.LANDLOCK_ACCESS_FS_TRUNCATE) != nil
+			permEv, ev := uint64(linux.FAN_OPEN_PERM), uint64(linux.FAN_OPEN)
+			if opts.FileExec {
+				permEv |= linux.FAN_OPEN_EXEC_PERM
+				ev |= linux.FAN_OPEN_EXEC
+			}
+			if err := fd.fanotify(ctx, permEv); err != nil {
+				// Like in Linux, files that failed to open don't generate
+				// close events.
+				fd.noNotify = true
+				fd.DecRef(ctx)
+				return nil, err
+			}
+
 			fd.Dentry().InotifyWithParent(ctx, linux.IN_OPEN, 0, PathEvent)
+			fd.fanotify(ctx, ev)
 			return fd, nil
 		}
 		if !rp.handleError(ctx, err) {
@@ -559,6 +620,14 @@ func (vfs *VirtualFilesystem) RenameAt(ctx context.Context, creds *auth.Credenti
 		ctx.Warningf("VirtualFilesystem.RenameAt: destination path can't follow final symlink")
 		return linuxerr.EINVAL
 	}
+	newpop, newParentVD, err := vfs.checkLandlockRenameAt(ctx, creds, oldpop, oldParentVD, oldName, newpop, opts)
+	if err != nil {
+		oldParentVD.DecRef(ctx)
+		return err
+	}
+	if newParentVD.Ok() {
+		defer newParentVD.DecRef(ctx)
+	}
 
 	rp := vfs.getResolvingPath(creds, newpop)
 	renameOpts := *opts
@@ -600,6 +669,13 @@ func (vfs *VirtualFilesystem) RmdirAt(ctx context.Context, creds *auth.Credentia
 		ctx.Warningf("VirtualFilesystem.RmdirAt: file deletion paths can't follow final symlink")
 		return linuxerr.EINVAL
 	}
+	pop, parentVD, err := vfs.checkLandlockRemoveAt(ctx, creds, pop, linux.ModeDirectory)
+	if err != nil {
+		return err
+	}
+	if parentVD.Ok() {
+		defer parentVD.DecRef(ctx)
+	}
 
 	rp := vfs.getResolvingPath(creds, pop)
 	for {
@@ -623,6 +699,19 @@ func (vfs *VirtualFilesystem) RmdirAt(ctx context.Context, creds *auth.Credentia
 
 // SetStatAt changes metadata for the file at the given path.
 func (vfs *VirtualFilesystem) SetStatAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation, opts *SetStatOptions) error {
+	if opts.Stat.Mask&linux.STATX_SIZE != 0 {
+		var (
+			vd  VirtualDentry
+			err error
+		)
+		pop, vd, err = vfs.checkLandlockTruncateAt(ctx, creds, pop)
+		if err != nil {
+			return err
+		}
+		if vd.Ok() {
+			defer vd.DecRef(ctx)
+		}
+	}
 	rp := vfs.getResolvingPath(creds, pop)
 	for {
 		vfs.maybeBlockOnMountPromise(ctx, rp)
@@ -688,6 +777,13 @@ func (vfs *VirtualFilesystem) SymlinkAt(ctx context.Context, creds *auth.Credent
 		ctx.Warningf("VirtualFilesystem.SymlinkAt: file creation paths can't follow final symlink")
 		return linuxerr.EINVAL
 	}
+	pop, parentVD, err := vfs.checkLandlockCreateAt(ctx, creds, pop, linux.ModeSymlink)
+	if err != nil {
+		return err
+	}
+	if parentVD.Ok() {
+		defer parentVD.DecRef(ctx)
+	}
 
 	rp := vfs.getResolvingPath(creds, pop)
 	for {
@@ -723,6 +819,13 @@ func (vfs *VirtualFilesystem) UnlinkAt(ctx context.Context, creds *auth.Credenti
 		ctx.Warningf("VirtualFilesystem.UnlinkAt: file deletion paths can't follow final symlink")
 		return linuxerr.EINVAL
 	}
+	pop, parentVD, err := vfs.checkLandlockRemoveAt(ctx, creds, pop, linux.ModeRegular)
+	if err != nil {
+		return err
+	}
+	if parentVD.Ok() {
+		defer parentVD.DecRef(ctx)
+	}
 
 	rp := vfs.getResolvingPath(creds, pop)
 	for {
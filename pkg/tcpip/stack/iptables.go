@@ -336,6 +336,10 @@ func (it *IPTables) shouldSkipOrPopulateTables(tables []checkTable, pkt PacketBu
 // getConnAndUpdate) can allocate.
 // TODO(b/233951539): checkescape fails on arm sometimes. Fix and re-add.
 func (it *IPTables) CheckPrerouting(pkt PacketBufferPtr, addressEP AddressableEndpoint, inNicName string) bool {
+	if !filterPacket(Prerouting, pkt, nil /* route */, inNicName) {
+		return false
+	}
+
 	tables := [...]checkTable{
 		{
 			fn:      check,
@@ -374,6 +378,10 @@ func (it *IPTables) CheckPrerouting(pkt PacketBufferPtr, addressEP AddressableEn
 // getConnAndUpdate) can allocate.
 // TODO(b/233951539): checkescape fails on arm sometimes. Fix and re-add.
 func (it *IPTables) CheckInput(pkt PacketBufferPtr, inNicName string) bool {
+	if !filterPacket(Input, pkt, nil /* route */, inNicName) {
+		return false
+	}
+
 	tables := [...]checkTable{
 		{
 			fn:      checkNAT,
@@ -446,6 +454,10 @@ func (it *IPTables) CheckForward(pkt PacketBufferPtr, inNicName, outNicName stri
 // getConnAndUpdate) can allocate.
 // TODO(b/233951539): checkescape fails on arm sometimes. Fix and re-add.
 func (it *IPTables) CheckOutput(pkt PacketBufferPtr, r *Route, outNicName string) bool {
+	if !filterPacket(Output, pkt, r, outNicName) {
+		return false
+	}
+
 	tables := [...]checkTable{
 		{
 			fn:      check,
@@ -490,6 +502,10 @@ func (it *IPTables) CheckOutput(pkt PacketBufferPtr, r *Route, outNicName string
 // getConnAndUpdate) can allocate.
 // TODO(b/233951539): checkescape fails on arm sometimes. Fix and re-add.
 func (it *IPTables) CheckPostrouting(pkt PacketBufferPtr, r *Route, addressEP AddressableEndpoint, outNicName string) bool {
+	if !filterPacket(Postrouting, pkt, r, outNicName) {
+		return false
+	}
+
 	tables := [...]checkTable{
 		{
 			fn:      check,
@@ -0,0 +1,154 @@
+package stack
+
+import (
+	"fmt"
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/checksum"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"sync/atomic"
+)
+
+// PacketFilterVerdict is the decision of PacketFilter about the packet
+type PacketFilterVerdict int
+
+const (
+	// PacketFilterAccept lets the packet continue traversing the stack
+	PacketFilterAccept PacketFilterVerdict = iota
+
+	// PacketFilterDrop drops the packet
+	PacketFilterDrop
+)
+
+// PacketFilter inspects packets at Prerouting, Input, Output and Postrouting hooks
+// before iptables rules. It's used to pass packets to js callbacks
+type PacketFilter interface {
+	// FilterPacket returns the verdict for the packet. The payload of the packet
+	// may be changed with PacketView.SetPayload
+	FilterPacket(hook Hook, view *PacketView) PacketFilterVerdict
+}
+
+// packetFilter is shared by all stacks, because all network namespaces of the sandbox
+// are observed by the same js runtime
+var packetFilter atomic.Pointer[PacketFilter]
+
+// SetPacketFilter sets the filter for all stacks, nil removes the filter
+func SetPacketFilter(filter PacketFilter) {
+	if filter == nil {
+		packetFilter.Store(nil)
+		return
+	}
+	packetFilter.Store(&filter)
+}
+
+// PacketView is the parsed packet passed to PacketFilter
+type PacketView struct {
+	NetProto   tcpip.NetworkProtocolNumber
+	TransProto tcpip.TransportProtocolNumber
+	SrcAddr    tcpip.Address
+	DstAddr    tcpip.Address
+
+	// SrcPort and DstPort are set for TCP and UDP packets only
+	SrcPort uint16
+	DstPort uint16
+
+	// NICName is the name of the incoming NIC for Prerouting and Input hooks
+	// and the name of the outgoing NIC for Output and Postrouting hooks
+	NICName string
+
+	pkt PacketBufferPtr
+
+	// checksumOffload is true if the transport checksum is calculated by the NIC,
+	// so it must not be updated when the payload is changed
+	checksumOffload bool
+}
+
+func newPacketView(pkt PacketBufferPtr, r *Route, nicName string) *PacketView {
+	view := &PacketView{
+		NetProto:   pkt.NetworkProtocolNumber,
+		TransProto: pkt.TransportProtocolNumber,
+		NICName:    nicName,
+		pkt:        pkt,
+	}
+	switch pkt.NetworkProtocolNumber {
+	case header.IPv4ProtocolNumber, header.IPv6ProtocolNumber:
+		if len(pkt.NetworkHeader().Slice()) != 0 {
+			netHdr := pkt.Network()
+			view.SrcAddr = netHdr.SourceAddress()
+			view.DstAddr = netHdr.DestinationAddress()
+		}
+	}
+
+	switch pkt.TransportProtocolNumber {
+	case header.TCPProtocolNumber:
+		if tcp := header.TCP(pkt.TransportHeader().Slice()); len(tcp) >= header.TCPMinimumSize {
+			view.SrcPort, view.DstPort = tcp.SourcePort(), tcp.DestinationPort()
+		}
+	case header.UDPProtocolNumber:
+		if udp := header.UDP(pkt.TransportHeader().Slice()); len(udp) >= header.UDPMinimumSize {
+			view.SrcPort, view.DstPort = udp.SourcePort(), udp.DestinationPort()
+		}
+	}
+
+	view.checksumOffload = pkt.GSOOptions.Type != GSONone || (r != nil && !r.RequiresTXTransportChecksum())
+	return view
+}
+
+// Payload returns the copy of the data after transport header
+func (v *PacketView) Payload() []byte {
+	return v.pkt.Data().AsRange().ToSlice()
+}
+
+// SetPayload replaces the payload of the packet with data of the same length.
+// TCP and UDP checksums are updated unless they are calculated by the NIC
+func (v *PacketView) SetPayload(data []byte) error {
+	d := v.pkt.Data()
+	if len(data) != d.Size() {
+		return fmt.Errorf("new payload has %d bytes, but the packet has %d bytes", len(data), d.Size())
+	}
+
+	old := d.AsRange().ToSlice()
+	v.pkt.buf.Truncate(int64(v.pkt.dataOffset()))
+	v.pkt.buf.Append(buffer.NewViewWithData(append([]byte(nil), data...)))
+
+	if !v.checksumOffload {
+		v.updateChecksum(old, data)
+	}
+	return nil
+}
+
+// updateChecksum updates the transport checksum incrementally (see RFC 1624).
+// The payload starts at the even offset of transport header, so its sums can be combined
+func (v *PacketView) updateChecksum(old, data []byte) {
+	update := func(xsum uint16) uint16 {
+		xsum = checksum.Combine(^xsum, ^checksum.Checksum(old, 0))
+		return ^checksum.Combine(xsum, checksum.Checksum(data, 0))
+	}
+
+	switch v.TransProto {
+	case header.TCPProtocolNumber:
+		if tcp := header.TCP(v.pkt.TransportHeader().Slice()); len(tcp) >= header.TCPMinimumSize {
+			tcp.SetChecksum(update(tcp.Checksum()))
+		}
+	case header.UDPProtocolNumber:
+		udp := header.UDP(v.pkt.TransportHeader().Slice())
+		// Zero checksum means that UDP checksum isn't used.
+		if len(udp) >= header.UDPMinimumSize && udp.Checksum() != 0 {
+			xsum := update(udp.Checksum())
+			if xsum == 0 {
+				xsum = 0xffff
+			}
+			udp.SetChecksum(xsum)
+		}
+	}
+}
+
+// filterPacket passes the packet to PacketFilter. Returns true if the packet may continue
+// traversing the stack
+func filterPacket(hook Hook, pkt PacketBufferPtr, r *Route, nicName string) bool {
+	filter := packetFilter.Load()
+	if filter == nil {
+		return true
+	}
+	return (*filter).FilterPacket(hook, newPacketView(pkt, r, nicName)) != PacketFilterDrop
+}
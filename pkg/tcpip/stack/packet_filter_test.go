@@ -0,0 +1,85 @@
+package stack
+
+import (
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/checksum"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"testing"
+)
+
+func TestPacketViewSetPayload_UpdatesUDPChecksum(t *testing.T) {
+	src := tcpip.AddrFrom4Slice([]byte{10, 0, 0, 1})
+	dst := tcpip.AddrFrom4Slice([]byte{10, 0, 0, 2})
+
+	pkt := NewPacketBuffer(PacketBufferOptions{
+		ReserveHeaderBytes: header.UDPMinimumSize,
+		Payload:            buffer.MakeWithData([]byte("hello, world")),
+	})
+	defer pkt.DecRef()
+	pkt.TransportProtocolNumber = header.UDPProtocolNumber
+	udp := header.UDP(pkt.TransportHeader().Push(header.UDPMinimumSize))
+	length := uint16(pkt.Size())
+	udp.Encode(&header.UDPFields{SrcPort: 1, DstPort: 2, Length: length})
+
+	udpChecksum := func() uint16 {
+		current := udp.Checksum()
+		defer udp.SetChecksum(current)
+		udp.SetChecksum(0)
+
+		xsum := header.PseudoHeaderChecksum(header.UDPProtocolNumber, src, dst, length)
+		xsum = checksum.Combine(xsum, pkt.Data().Checksum())
+		return ^udp.CalculateChecksum(xsum)
+	}
+	udp.SetChecksum(udpChecksum())
+
+	view := &PacketView{TransProto: header.UDPProtocolNumber, pkt: pkt}
+	if err := view.SetPayload([]byte("HELLO, WORLD")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload := string(view.Payload()); payload != "HELLO, WORLD" {
+		t.Fatalf("payload is %q after SetPayload", payload)
+	}
+	if want := udpChecksum(); udp.Checksum() != want {
+		t.Fatalf("checksum is %#x, but %#x expected", udp.Checksum(), want)
+	}
+
+	if err := view.SetPayload([]byte("short")); err == nil {
+		t.Fatalf("no error for payload of different length")
+	}
+}
+
+type testPacketFilter struct {
+	hooks []Hook
+}
+
+func (f *testPacketFilter) FilterPacket(hook Hook, _ *PacketView) PacketFilterVerdict {
+	f.hooks = append(f.hooks, hook)
+	if hook == Input {
+		return PacketFilterDrop
+	}
+	return PacketFilterAccept
+}
+
+func TestFilterPacket(t *testing.T) {
+	pkt := NewPacketBuffer(PacketBufferOptions{})
+	defer pkt.DecRef()
+
+	if !filterPacket(Input, pkt, nil, "") {
+		t.Fatalf("packet is dropped without filter")
+	}
+
+	filter := &testPacketFilter{}
+	SetPacketFilter(filter)
+	defer SetPacketFilter(nil)
+
+	if !filterPacket(Prerouting, pkt, nil, "eth0") {
+		t.Fatalf("accepted packet is dropped")
+	}
+	if filterPacket(Input, pkt, nil, "eth0") {
+		t.Fatalf("dropped packet is accepted")
+	}
+	if len(filter.hooks) != 2 || filter.hooks[0] != Prerouting || filter.hooks[1] != Input {
+		t.Fatalf("filter is called for unexpected hooks: %v", filter.hooks)
+	}
+}
@@ -1051,7 +1051,7 @@ func (l *Loader) createContainerProcess(info *containerInfo) (*kernel.ThreadGrou
 
 			task := tg.Leader()
 			// NOTE: It seems Flags are ignored by runc so we ignore them too.
-			if err := task.AppendSyscallFilter(program, true); err != nil {
+			if err := task.AppendSyscallFilter(program, true, nil); err != nil {
 				return nil, nil, fmt.Errorf("appending seccomp filters: %w", err)
 			}
 		}
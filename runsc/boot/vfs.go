@@ -48,6 +48,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/erofs"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/fuse"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/gofer"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/jsfs"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/mqfs"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/overlay"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/proc"
@@ -487,6 +488,11 @@ func (c *containerMounter) mountAll(rootCtx context.Context, rootCreds *auth.Cre
 		return nil, fmt.Errorf("mounting submounts: %w", err)
 	}
 
+	// Mount files backed by js on top of submounts, so they can replace files in /proc too.
+	if err := jsfs.MountVirtualFiles(rootCtx, c.k.VFS(), rootCreds, mns); err != nil {
+		return nil, fmt.Errorf("mounting virtual files: %w", err)
+	}
+
 	return mns, nil
 }
 
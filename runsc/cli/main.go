@@ -60,9 +60,13 @@ var (
 	profilingMetricsFD = flag.Int("profiling-metrics-fd", -1, "file descriptor to write sentry profiling metrics.")
 
 	// logSocket is used for json logging
-	logSocket = flag.Int("web-log-socket-fd", -1, "...")
+	logSocket = flag.Int("web-log-socket-fd", -1, "file descriptor to write json log records to.")
 )
 
+// logSocketQueueSize is the count of json log records which may wait to be
+// written to the log socket. Further records are dropped.
+const logSocketQueueSize = 4096
+
 // Main is the main entrypoint.
 func Main() {
 	// Register all commands.
@@ -188,7 +192,9 @@ func Main() {
 	}
 
 	if *logSocket >= 0 {
-		log.SetJSONTarget(log.MoreJSONEmitter{&log.Writer{Next: os.NewFile(uintptr(*logSocket), "log socket file name")}})
+		// Records are queued, so a slow collector never blocks the syscall path.
+		socket := os.NewFile(uintptr(*logSocket), "log socket file name")
+		log.SetJSONTarget(log.MoreJSONEmitter{&log.Writer{Next: log.NewQueuedWriter(socket, logSocketQueueSize)}})
 	}
 
 	log.SetTarget(e)
@@ -295,6 +301,7 @@ func forEachCmd(cb func(cmd subcommands.Command, group string)) {
 	const internalGroup = "internal use only"
 	cb(new(cmd.Boot), internalGroup)
 	cb(new(cmd.Gofer), internalGroup)
+	cb(new(cmd.LogRelay), internalGroup)
 	cb(new(cmd.Umount), internalGroup)
 }
 
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+const (
+	// logRelayDialTimeout is the timeout of a single connection attempt.
+	logRelayDialTimeout = 5 * time.Second
+
+	// logRelayMaxBackoff is the maximum delay between connection attempts.
+	logRelayMaxBackoff = 5 * time.Second
+)
+
+// LogRelay implements subcommands.Command for the "log-relay" command.
+//
+// The relay reads newline-delimited json records written by the sandbox to
+// the log socket and sends them to the collector. When the collector is not
+// reachable, the relay reconnects, while records are kept in a bounded queue.
+// Records which don't fit into the queue are dropped, so the sandbox is never
+// blocked by a slow or restarting collector.
+type LogRelay struct {
+	addr      string
+	fd        int
+	queueSize int
+
+	// dropped is the count of records dropped since the last report to the
+	// collector.
+	dropped atomic.Uint64
+
+	// totalDropped is the count of records dropped because the queue was full.
+	totalDropped atomic.Uint64
+}
+
+// Name implements subcommands.Command.Name.
+func (*LogRelay) Name() string {
+	return "log-relay"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*LogRelay) Synopsis() string {
+	return "relays json log records of the sandbox to the log socket (internal use only)"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*LogRelay) Usage() string {
+	return `log-relay --addr=<host:port> --relay-fd=<fd>
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (r *LogRelay) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&r.addr, "addr", "", "TCP address of the log collector.")
+	f.IntVar(&r.fd, "relay-fd", -1, "file descriptor to read json log records from.")
+	f.IntVar(&r.queueSize, "queue-size", 4096, "count of records kept while the collector is not reachable.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (r *LogRelay) Execute(_ context.Context, f *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	if r.addr == "" || r.fd < 0 || r.queueSize <= 0 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	in := os.NewFile(uintptr(r.fd), "log relay")
+	defer in.Close()
+
+	records := make(chan []byte, r.queueSize)
+	go func() {
+		defer close(records)
+		reader := bufio.NewReader(in)
+		for {
+			record, err := reader.ReadBytes('\n')
+			if len(record) > 0 {
+				select {
+				case records <- record:
+				default:
+					if r.dropped.Add(1) == 1 {
+						log.Warningf("Log relay queue is full, dropping records")
+					}
+					r.totalDropped.Add(1)
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	r.send(records)
+	if total := r.totalDropped.Load(); total > 0 {
+		log.Warningf("Log relay dropped %d records in total", total)
+	}
+	return subcommands.ExitSuccess
+}
+
+// send writes records to the collector until records is closed, reconnecting
+// when the connection is lost. A record is retried until it is written. When
+// records were dropped, a {"dropped":N} record is sent before the next one, as
+// log.QueuedWriter does.
+func (r *LogRelay) send(records <-chan []byte) {
+	var conn net.Conn
+	backoff := 100 * time.Millisecond
+	for record := range records {
+		if dropped := r.dropped.Swap(0); dropped > 0 {
+			log.Warningf("Log relay dropped %d records", dropped)
+			record = append([]byte(fmt.Sprintf("{\"dropped\":%d}\n", dropped)), record...)
+		}
+		for {
+			if conn == nil {
+				var err error
+				if conn, err = net.DialTimeout("tcp", r.addr, logRelayDialTimeout); err != nil {
+					log.Debugf("Log relay failed to connect to %s: %v", r.addr, err)
+					time.Sleep(backoff)
+					backoff = min(2*backoff, logRelayMaxBackoff)
+					continue
+				}
+				backoff = 100 * time.Millisecond
+				log.Infof("Log relay connected to %s", r.addr)
+			}
+			if _, err := conn.Write(record); err != nil {
+				log.Infof("Log relay lost connection to %s: %v", r.addr, err)
+				conn.Close()
+				conn = nil
+				continue
+			}
+			break
+		}
+	}
+	if conn != nil {
+		conn.Close()
+	}
+}
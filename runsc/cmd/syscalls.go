@@ -21,12 +21,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
 	"sort"
 	"strconv"
 	"text/tabwriter"
 
 	"github.com/google/subcommands"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/callbacks"
 	"gvisor.dev/gvisor/runsc/cmd/util"
 	"gvisor.dev/gvisor/runsc/flag"
 )
@@ -37,6 +39,10 @@ type Syscalls struct {
 	os       string
 	arch     string
 	filename string
+
+	// callbacksConfig is the path to syscall callbacks config. Syscalls
+	// emulated by its callbacks are reported for the host architecture.
+	callbacksConfig string
 }
 
 // CompatibilityInfo is a map of system and architecture to compatibility doc.
@@ -53,6 +59,8 @@ type ArchInfo struct {
 type SyscallDoc struct {
 	Name string `json:"name"`
 	num  uintptr
+	// emulatable is true if the syscall may be emulated by callbacks.
+	emulatable bool
 
 	Support string   `json:"support"`
 	Note    string   `json:"note,omitempty"`
@@ -101,6 +109,7 @@ func (s *Syscalls) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&s.os, "os", osAll, "The OS (e.g. linux)")
 	f.StringVar(&s.arch, "arch", archAll, "The CPU architecture (e.g. amd64).")
 	f.StringVar(&s.filename, "filename", "", "Output filename (otherwise stdout).")
+	f.StringVar(&s.callbacksConfig, "callbacks-config", "", "Syscall callbacks config. Syscalls emulated by its callbacks are reported for the host architecture.")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -127,6 +136,14 @@ func (s *Syscalls) Execute(context.Context, *flag.FlagSet, ...any) subcommands.E
 		util.Fatalf("%v", err)
 	}
 
+	if s.callbacksConfig != "" {
+		emulated, err := emulatedSyscalls(s.callbacksConfig)
+		if err != nil {
+			util.Fatalf("Error reading callbacks config %q: %v", s.callbacksConfig, err)
+		}
+		addEmulatedSyscalls(info, emulated)
+	}
+
 	w := os.Stdout // Default.
 	if s.filename != "" {
 		w, err = os.OpenFile(s.filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
@@ -203,17 +220,70 @@ func getArchInfo(osName string, archName string) (ArchInfo, error) {
 
 	for num, sc := range t.Table {
 		info.Syscalls[num] = SyscallDoc{
-			Name:    sc.Name,
-			num:     num,
-			Support: sc.SupportLevel.String(),
-			Note:    sc.Note,
-			URLs:    sc.URLs,
+			Name:       sc.Name,
+			num:        num,
+			emulatable: sc.NotImplemented,
+			Support:    sc.SupportLevel.String(),
+			Note:       sc.Note,
+			URLs:       sc.URLs,
 		}
 	}
 
 	return info, nil
 }
 
+// emulatedSyscalls returns entry points of emulate callbacks from the callbacks
+// config by syscall numbers.
+func emulatedSyscalls(path string) (map[uintptr]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, err := callbacks.Parse(int(f.Fd()))
+	if err != nil {
+		return nil, err
+	}
+
+	emulated := make(map[uintptr]string)
+	for _, cb := range config.CallbackDtos {
+		if cb.Type == kernel.JsCallbackTypeEmulate {
+			emulated[uintptr(cb.Sysno)] = cb.EntryPoint
+		}
+	}
+	return emulated, nil
+}
+
+// addEmulatedSyscalls marks syscalls of the host architecture which are
+// emulated by callbacks. Emulate callbacks are used only for syscalls which are
+// missing in the syscall table or always fail with ENOSYS.
+func addEmulatedSyscalls(info CompatibilityInfo, emulated map[uintptr]string) {
+	archInfo, ok := info["linux"][runtime.GOARCH]
+	if !ok {
+		return
+	}
+
+	for num, entryPoint := range emulated {
+		doc, ok := archInfo.Syscalls[num]
+		if !ok {
+			doc = SyscallDoc{Name: "unknown", num: num}
+		}
+		var note string
+		if ok && !doc.emulatable {
+			note = fmt.Sprintf("Emulate callback %q is not used, the syscall doesn't fail with ENOSYS.", entryPoint)
+		} else {
+			doc.Support = "Emulated"
+			note = fmt.Sprintf("Emulated by callback %q.", entryPoint)
+		}
+		if doc.Note != "" {
+			note = note + " " + doc.Note
+		}
+		doc.Note = note
+		archInfo.Syscalls[num] = doc
+	}
+}
+
 // outputTable outputs the syscall info in tabular format.
 func outputTable(w io.Writer, info CompatibilityInfo) error {
 	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestAddEmulatedSyscalls(t *testing.T) {
+	info := CompatibilityInfo{"linux": {runtime.GOARCH: ArchInfo{Syscalls: map[uintptr]SyscallDoc{
+		1: {Name: "enosys", num: 1, emulatable: true, Support: "Unimplemented"},
+		2: {Name: "eperm", num: 2, Support: "Unimplemented"},
+		3: {Name: "implemented", num: 3, Support: "Full Support"},
+	}}}}
+	addEmulatedSyscalls(info, map[uintptr]string{1: "a", 2: "b", 3: "c", 4: "d"})
+
+	syscalls := info["linux"][runtime.GOARCH].Syscalls
+	for num, emulated := range map[uintptr]bool{1: true, 2: false, 3: false, 4: true} {
+		doc := syscalls[num]
+		if got := doc.Support == "Emulated"; got != emulated {
+			t.Errorf("syscall %d: emulated is %t, want %t (support %q)", num, got, emulated, doc.Support)
+		}
+		if !emulated && !strings.Contains(doc.Note, "is not used") {
+			t.Errorf("syscall %d: note %q doesn't say that the callback is not used", num, doc.Note)
+		}
+	}
+}
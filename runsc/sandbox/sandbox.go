@@ -646,6 +646,52 @@ func (s *Sandbox) connError(err error) error {
 	return fmt.Errorf("connecting to control server at PID %d: %v", s.Pid.load(), err)
 }
 
+// startLogRelay starts the log-relay process forwarding json log records to
+// addr. Returns the end of socket pair which the sandbox should write to.
+func startLogRelay(conf *config.Config, addr string) (*os.File, error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("creating log relay socket pair: %v", err)
+	}
+	sandboxEnd := os.NewFile(uintptr(fds[0]), "log relay sandbox end")
+	relayEnd := os.NewFile(uintptr(fds[1]), "log relay end")
+	defer relayEnd.Close()
+
+	cmd := exec.Command(specutils.ExePath, conf.ToFlags()...)
+	cmd.Args[0] = "runsc-log-relay"
+	cmd.Args = append(cmd.Args, "log-relay", "--addr="+addr, "--relay-fd=3")
+	cmd.ExtraFiles = []*os.File{relayEnd}
+	cmd.SysProcAttr = &unix.SysProcAttr{
+		// Detach from this session, so the relay outlives runsc create.
+		Setsid: true,
+	}
+	if err := cmd.Start(); err != nil {
+		sandboxEnd.Close()
+		return nil, fmt.Errorf("starting log relay: %v", err)
+	}
+	log.Infof("Log relay started, PID: %d", cmd.Process.Pid)
+	return sandboxEnd, nil
+}
+
+// inlineCallbacksConfig writes configDto with loaded WebAssembly modules to a
+// memfd, because the sandbox can't open module files on the host.
+func inlineCallbacksConfig(configDto *callbacks.CallbackConfigDto) (*os.File, error) {
+	data, err := json.Marshal(configDto)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := unix.MemfdCreate("syscall-init-config", unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("creating memfd for callbacks config: %v", err)
+	}
+	file := os.NewFile(uintptr(fd), "syscall-init-config")
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("writing callbacks config: %v", err)
+	}
+	return file, nil
+}
+
 // createSandboxProcess starts the sandbox as a subprocess by running the "boot"
 // command, passing in the bundle dir.
 func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyncFile *os.File) error {
@@ -685,6 +731,7 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 
 	var configFd int
 	var configDto *callbacks.CallbackConfigDto
+	var inlinedConfig *os.File
 
 	if conf.SyscallCallbacksConfig != "" {
 		var err error
@@ -697,25 +744,27 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 		if configDto, err = callbacks.Parse(configFd); err != nil {
 			return err
 		} else {
-			if configDto.LogSocket != "" {
-
-				// Here is created a socket to connect to the web interface
-				tcpAddr, err := net.ResolveTCPAddr("tcp", configDto.LogSocket)
-				if err != nil {
+			if loaded, err := callbacks.LoadModules(configDto); err != nil {
+				return err
+			} else if loaded {
+				if inlinedConfig, err = inlineCallbacksConfig(configDto); err != nil {
 					return err
 				}
+			}
 
-				brokerSockConnector, err := net.DialTCP("tcp", nil, tcpAddr)
-				if err != nil {
+			if configDto.LogSocket != "" {
+				if _, err := net.ResolveTCPAddr("tcp", configDto.LogSocket); err != nil {
 					return err
 				}
 
-				webFile, err := brokerSockConnector.File()
+				// The sandbox writes to one end of a socket pair, and the relay
+				// forwards records to the web interface, reconnecting if needed.
+				webFile, err := startLogRelay(conf, configDto.LogSocket)
 				if err != nil {
 					return err
 				}
 
-				donations.Donate("web-log-socket-fd", webFile)
+				donations.DonateAndClose("web-log-socket-fd", webFile)
 			}
 		}
 	}
@@ -824,7 +873,9 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 	}
 	donations.DonateAndClose("sink-fds", args.SinkFiles...)
 
-	if err := donations.OpenAndDonate("syscall-init-config-fd", conf.SyscallCallbacksConfig, os.O_RDONLY); err != nil {
+	if inlinedConfig != nil {
+		donations.DonateAndClose("syscall-init-config-fd", inlinedConfig)
+	} else if err := donations.OpenAndDonate("syscall-init-config-fd", conf.SyscallCallbacksConfig, os.O_RDONLY); err != nil {
 		return err
 	}
 
@@ -15,10 +15,15 @@
 // All tests in this file rely on being about to mount and unmount cgroupfs,
 // which isn't expected to work, or be safe on a general linux system.
 
+#include <fcntl.h>
 #include <limits.h>
 #include <linux/magic.h>
+#include <sched.h>
+#include <signal.h>
 #include <sys/mount.h>
 #include <sys/statfs.h>
+#include <sys/syscall.h>
+#include <sys/wait.h>
 #include <unistd.h>
 
 #include <cstdint>
@@ -31,7 +36,9 @@
 #include "test/util/cgroup_util.h"
 #include "test/util/cleanup.h"
 #include "test/util/linux_capability_util.h"
+#include "test/util/logging.h"
 #include "test/util/mount_util.h"
+#include "test/util/multiprocess_util.h"
 #include "test/util/posix_error.h"
 #include "test/util/temp_path.h"
 #include "test/util/test_util.h"
@@ -1521,6 +1528,102 @@ TEST(DevicesCgroup, IgnorePartialMatchRule) {
               IsPosixErrorOkAndHolds("c 7:* rw\n"));
 }
 
+#ifndef CLONE_NEWCGROUP
+#define CLONE_NEWCGROUP 0x02000000
+#endif
+
+#ifndef CLONE_INTO_CGROUP
+#define CLONE_INTO_CGROUP 0x200000000ULL
+#endif
+
+TEST(CgroupNamespace, PathsRelativeToNamespaceRoot) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Mounter m(ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir()));
+  Cgroup c = ASSERT_NO_ERRNO_AND_VALUE(m.MountCgroupfs("memory"));
+  Cgroup child = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("child"));
+  Cgroup sibling = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("sibling"));
+  ASSERT_NO_ERRNO(child.Enter(getpid()));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                TEST_PCHECK(unshare(CLONE_NEWCGROUP) == 0);
+                auto entries = ProcPIDCgroupEntries(getpid()).ValueOrDie();
+                TEST_CHECK(entries["memory"].path == "/");
+
+                // Cgroups outside of the namespace root are reached through
+                // "..".
+                TEST_CHECK(sibling.Enter(getpid()).ok());
+                entries = ProcPIDCgroupEntries(getpid()).ValueOrDie();
+                TEST_CHECK(entries["memory"].path == "/../sibling");
+              }),
+              IsPosixErrorOkAndHolds(0));
+
+  // The namespace of the test process is unchanged.
+  absl::flat_hash_map<std::string, PIDCgroupEntry> entries =
+      ASSERT_NO_ERRNO_AND_VALUE(ProcPIDCgroupEntries(getpid()));
+  EXPECT_EQ(entries["memory"].path, "/child");
+}
+
+// struct clone_args is a Linux clone struct. Old versions of glibc do not
+// expose it. See include/uapi/linux/sched.h
+struct clone_args {
+  uint64_t flags;
+  uint64_t pidfd;
+  uint64_t child_tid;
+  uint64_t parent_tid;
+  uint64_t exit_signal;
+  uint64_t stack;
+  uint64_t stack_size;
+  uint64_t tls;
+  uint64_t set_tid;
+  uint64_t set_tid_size;
+  uint64_t cgroup;
+};
+
+TEST(CgroupNamespace, CloneIntoCgroup) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Mounter m(ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir()));
+  Cgroup c = ASSERT_NO_ERRNO_AND_VALUE(m.MountCgroupfs("pids"));
+  Cgroup target = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("target"));
+  const FileDescriptor dirfd = ASSERT_NO_ERRNO_AND_VALUE(
+      Open(target.Path(), O_RDONLY | O_DIRECTORY));
+
+  clone_args ca = {};
+  ca.flags = CLONE_INTO_CGROUP;
+  ca.exit_signal = SIGCHLD;
+  ca.cgroup = dirfd.get();
+  pid_t child_pid;
+  ASSERT_THAT(child_pid = syscall(SYS_clone3, &ca, sizeof(ca)),
+              SyscallSucceeds());
+  if (child_pid == 0) {
+    auto entries = ProcPIDCgroupEntries(getpid()).ValueOrDie();
+    _exit(entries["pids"].path == "/target" ? 0 : 1);
+  }
+
+  int status;
+  ASSERT_THAT(RetryEINTR(waitpid)(child_pid, &status, 0),
+              SyscallSucceedsWithValue(child_pid));
+  EXPECT_TRUE(WIFEXITED(status) && WEXITSTATUS(status) == 0);
+
+  // The parent stays in its cgroup, and the child's charge is released.
+  EXPECT_NO_ERRNO(c.ContainsCallingProcess());
+  EXPECT_THAT(target.ReadIntegerControlFile("pids.current"),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(CgroupNamespace, CloneIntoCgroupRequiresCgroupFD) {
+  const FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open("/proc/self", O_RDONLY | O_DIRECTORY));
+
+  clone_args ca = {};
+  ca.flags = CLONE_INTO_CGROUP;
+  ca.exit_signal = SIGCHLD;
+  ca.cgroup = fd.get();
+  EXPECT_THAT(syscall(SYS_clone3, &ca, sizeof(ca)),
+              SyscallFailsWithErrno(EBADF));
+}
+
 }  // namespace
 }  // namespace testing
 }  // namespace gvisor
@@ -0,0 +1,220 @@
+#include <errno.h>
+#include <fcntl.h>
+#include <sys/fanotify.h>
+#include <unistd.h>
+
+#include <string>
+
+#include "gtest/gtest.h"
+#include "absl/strings/str_cat.h"
+#include "test/util/capability_util.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/fs_util.h"
+#include "test/util/posix_error.h"
+#include "test/util/temp_path.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+PosixErrorOr<FileDescriptor> FanotifyInit(unsigned int flags,
+                                          unsigned int event_flags) {
+  int fd = fanotify_init(flags, event_flags);
+  if (fd < 0) {
+    return PosixError(errno, "fanotify_init failed");
+  }
+  return FileDescriptor(fd);
+}
+
+// ReadEvent reads a single event from the fanotify group fd.
+PosixErrorOr<fanotify_event_metadata> ReadEvent(int fd) {
+  fanotify_event_metadata event = {};
+  int n = RetryEINTR(read)(fd, &event, sizeof(event));
+  if (n < 0) {
+    return PosixError(errno, "read failed");
+  }
+  if (n != sizeof(event)) {
+    return PosixError(EIO, absl::StrCat("short read: ", n));
+  }
+  return event;
+}
+
+TEST(FanotifyTest, InitInvalidFlags) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  EXPECT_THAT(fanotify_init(0x80000000, O_RDONLY),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(fanotify_init(FAN_CLASS_CONTENT | FAN_CLASS_PRE_CONTENT,
+                            O_RDONLY),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(fanotify_init(FAN_CLASS_NOTIF, O_RDONLY | O_CREAT),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(FanotifyTest, MarkInvalid) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  const FileDescriptor group =
+      ASSERT_NO_ERRNO_AND_VALUE(FanotifyInit(FAN_CLASS_NOTIF, O_RDONLY));
+  const FileDescriptor other =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(file.path(), O_RDONLY));
+
+  EXPECT_THAT(fanotify_mark(other.get(), FAN_MARK_ADD, FAN_OPEN, AT_FDCWD,
+                            file.path().c_str()),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(
+      fanotify_mark(group.get(), 0, FAN_OPEN, AT_FDCWD, file.path().c_str()),
+      SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(
+      fanotify_mark(group.get(), FAN_MARK_ADD, 0, AT_FDCWD,
+                    file.path().c_str()),
+      SyscallFailsWithErrno(EINVAL));
+  // Permission events need FAN_CLASS_CONTENT or FAN_CLASS_PRE_CONTENT.
+  EXPECT_THAT(fanotify_mark(group.get(), FAN_MARK_ADD, FAN_OPEN_PERM,
+                            AT_FDCWD, file.path().c_str()),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(fanotify_mark(group.get(), FAN_MARK_REMOVE, FAN_OPEN, AT_FDCWD,
+                            file.path().c_str()),
+              SyscallFailsWithErrno(ENOENT));
+}
+
+TEST(FanotifyTest, NonBlockingEmpty) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  const FileDescriptor group = ASSERT_NO_ERRNO_AND_VALUE(
+      FanotifyInit(FAN_CLASS_NOTIF | FAN_NONBLOCK, O_RDONLY));
+  fanotify_event_metadata event;
+  EXPECT_THAT(read(group.get(), &event, sizeof(event)),
+              SyscallFailsWithErrno(EAGAIN));
+  EXPECT_THAT(read(group.get(), &event, 1), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(FanotifyTest, OpenCloseEvents) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  const FileDescriptor group = ASSERT_NO_ERRNO_AND_VALUE(
+      FanotifyInit(FAN_CLASS_NOTIF | FAN_NONBLOCK, O_RDONLY | O_CLOEXEC));
+  ASSERT_THAT(fanotify_mark(group.get(), FAN_MARK_ADD,
+                            FAN_OPEN | FAN_CLOSE_NOWRITE, AT_FDCWD,
+                            file.path().c_str()),
+              SyscallSucceeds());
+
+  ASSERT_NO_ERRNO(Open(file.path(), O_RDONLY));
+
+  const fanotify_event_metadata event =
+      ASSERT_NO_ERRNO_AND_VALUE(ReadEvent(group.get()));
+  EXPECT_EQ(event.vers, FANOTIFY_METADATA_VERSION);
+  EXPECT_EQ(event.event_len, sizeof(event));
+  EXPECT_EQ(event.mask, FAN_OPEN | FAN_CLOSE_NOWRITE);
+  EXPECT_EQ(event.pid, getpid());
+  ASSERT_GE(event.fd, 0);
+  const FileDescriptor event_fd(event.fd);
+
+  EXPECT_THAT(fcntl(event_fd.get(), F_GETFD),
+              SyscallSucceedsWithValue(FD_CLOEXEC));
+  EXPECT_EQ(ASSERT_NO_ERRNO_AND_VALUE(
+                ReadLink(absl::StrCat("/proc/self/fd/", event_fd.get()))),
+            file.path());
+
+  // Opening and closing the event's file doesn't generate events.
+  fanotify_event_metadata next;
+  EXPECT_THAT(read(group.get(), &next, sizeof(next)),
+              SyscallFailsWithErrno(EAGAIN));
+}
+
+TEST(FanotifyTest, MountMarkModify) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  const FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(file.path(), O_WRONLY));
+  const FileDescriptor group = ASSERT_NO_ERRNO_AND_VALUE(
+      FanotifyInit(FAN_CLASS_NOTIF | FAN_NONBLOCK, O_RDONLY));
+  ASSERT_THAT(fanotify_mark(group.get(), FAN_MARK_ADD | FAN_MARK_MOUNT,
+                            FAN_MODIFY, AT_FDCWD, dir.path().c_str()),
+              SyscallSucceeds());
+
+  ASSERT_THAT(WriteFd(fd.get(), "x", 1), SyscallSucceedsWithValue(1));
+
+  const fanotify_event_metadata event =
+      ASSERT_NO_ERRNO_AND_VALUE(ReadEvent(group.get()));
+  EXPECT_EQ(event.mask, FAN_MODIFY);
+  ASSERT_GE(event.fd, 0);
+  const FileDescriptor event_fd(event.fd);
+  EXPECT_EQ(ASSERT_NO_ERRNO_AND_VALUE(
+                ReadLink(absl::StrCat("/proc/self/fd/", event_fd.get()))),
+            file.path());
+}
+
+TEST(FanotifyTest, IgnoredMask) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  const FileDescriptor group = ASSERT_NO_ERRNO_AND_VALUE(
+      FanotifyInit(FAN_CLASS_NOTIF | FAN_NONBLOCK, O_RDONLY));
+  ASSERT_THAT(fanotify_mark(group.get(), FAN_MARK_ADD | FAN_MARK_MOUNT,
+                            FAN_OPEN, AT_FDCWD, dir.path().c_str()),
+              SyscallSucceeds());
+  ASSERT_THAT(fanotify_mark(group.get(), FAN_MARK_ADD | FAN_MARK_IGNORED_MASK,
+                            FAN_OPEN, AT_FDCWD, file.path().c_str()),
+              SyscallSucceeds());
+
+  ASSERT_NO_ERRNO(Open(file.path(), O_RDONLY));
+
+  fanotify_event_metadata event;
+  EXPECT_THAT(read(group.get(), &event, sizeof(event)),
+              SyscallFailsWithErrno(EAGAIN));
+}
+
+TEST(FanotifyTest, PermissionEvents) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  const FileDescriptor group =
+      ASSERT_NO_ERRNO_AND_VALUE(FanotifyInit(FAN_CLASS_CONTENT, O_RDONLY));
+  ASSERT_THAT(fanotify_mark(group.get(), FAN_MARK_ADD, FAN_OPEN_PERM,
+                            AT_FDCWD, file.path().c_str()),
+              SyscallSucceeds());
+
+  for (uint32_t response : {FAN_DENY, FAN_ALLOW}) {
+    int open_errno = 0;
+    ScopedThread opener([&] {
+      int fd = open(file.path().c_str(), O_RDONLY);
+      if (fd < 0) {
+        open_errno = errno;
+      } else {
+        close(fd);
+      }
+    });
+
+    const fanotify_event_metadata event =
+        ASSERT_NO_ERRNO_AND_VALUE(ReadEvent(group.get()));
+    EXPECT_EQ(event.mask, FAN_OPEN_PERM);
+    ASSERT_GE(event.fd, 0);
+    const FileDescriptor event_fd(event.fd);
+
+    const fanotify_response resp = {.fd = event.fd, .response = response};
+    ASSERT_THAT(WriteFd(group.get(), &resp, sizeof(resp)),
+                SyscallSucceedsWithValue(sizeof(resp)));
+    // The event has been answered already.
+    EXPECT_THAT(WriteFd(group.get(), &resp, sizeof(resp)),
+                SyscallFailsWithErrno(ENOENT));
+
+    opener.Join();
+    EXPECT_EQ(open_errno, response == FAN_DENY ? EPERM : 0);
+  }
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
@@ -0,0 +1,552 @@
+#include <errno.h>
+#include <fcntl.h>
+#include <netinet/in.h>
+#include <sched.h>
+#include <stdint.h>
+#include <sys/mount.h>
+#include <sys/prctl.h>
+#include <sys/socket.h>
+#include <sys/stat.h>
+#include <sys/syscall.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#include <string>
+
+#include "gtest/gtest.h"
+#include "test/util/capability_util.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/fs_util.h"
+#include "test/util/logging.h"
+#include "test/util/multiprocess_util.h"
+#include "test/util/posix_error.h"
+#include "test/util/temp_path.h"
+#include "test/util/test_util.h"
+
+#ifndef SYS_landlock_create_ruleset
+#define SYS_landlock_create_ruleset 444
+#endif
+#ifndef SYS_landlock_add_rule
+#define SYS_landlock_add_rule 445
+#endif
+#ifndef SYS_landlock_restrict_self
+#define SYS_landlock_restrict_self 446
+#endif
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+// Definitions from uapi/linux/landlock.h, which may be older than the ABI
+// version tested here.
+struct RulesetAttr {
+  uint64_t handled_access_fs;
+  uint64_t handled_access_net;
+};
+
+struct PathBeneathAttr {
+  uint64_t allowed_access;
+  int32_t parent_fd;
+} __attribute__((packed));
+
+struct NetPortAttr {
+  uint64_t allowed_access;
+  uint64_t port;
+};
+
+constexpr uint32_t kCreateRulesetVersion = 1 << 0;
+
+constexpr int kRulePathBeneath = 1;
+constexpr int kRuleNetPort = 2;
+
+constexpr uint64_t kAccessFSExecute = 1 << 0;
+constexpr uint64_t kAccessFSWriteFile = 1 << 1;
+constexpr uint64_t kAccessFSReadFile = 1 << 2;
+constexpr uint64_t kAccessFSReadDir = 1 << 3;
+constexpr uint64_t kAccessFSRemoveFile = 1 << 5;
+constexpr uint64_t kAccessFSMakeDir = 1 << 7;
+constexpr uint64_t kAccessFSMakeReg = 1 << 8;
+constexpr uint64_t kAccessFSRefer = 1 << 13;
+constexpr uint64_t kAccessFSTruncate = 1 << 14;
+
+constexpr uint64_t kAccessNetBindTCP = 1 << 0;
+
+int LandlockCreateRuleset(const RulesetAttr* attr, size_t size,
+                          uint32_t flags) {
+  return syscall(SYS_landlock_create_ruleset, attr, size, flags);
+}
+
+int LandlockAddRule(int ruleset_fd, int rule_type, const void* attr,
+                    uint32_t flags) {
+  return syscall(SYS_landlock_add_rule, ruleset_fd, rule_type, attr, flags);
+}
+
+int LandlockRestrictSelf(int ruleset_fd, uint32_t flags) {
+  return syscall(SYS_landlock_restrict_self, ruleset_fd, flags);
+}
+
+// LandlockABIVersion returns the Landlock ABI version, or 0 if Landlock isn't
+// supported.
+int LandlockABIVersion() {
+  int version = LandlockCreateRuleset(nullptr, 0, kCreateRulesetVersion);
+  return version < 0 ? 0 : version;
+}
+
+PosixErrorOr<FileDescriptor> NewRuleset(uint64_t handled_fs,
+                                        uint64_t handled_net) {
+  RulesetAttr attr = {};
+  attr.handled_access_fs = handled_fs;
+  attr.handled_access_net = handled_net;
+  int fd = LandlockCreateRuleset(&attr, sizeof(attr), 0);
+  if (fd < 0) {
+    return PosixError(errno, "landlock_create_ruleset failed");
+  }
+  return FileDescriptor(fd);
+}
+
+PosixError AddPathRule(const FileDescriptor& ruleset, const std::string& path,
+                       uint64_t access) {
+  ASSIGN_OR_RETURN_ERRNO(FileDescriptor parent, Open(path, O_PATH));
+  PathBeneathAttr attr = {};
+  attr.allowed_access = access;
+  attr.parent_fd = parent.get();
+  if (LandlockAddRule(ruleset.get(), kRulePathBeneath, &attr, 0) < 0) {
+    return PosixError(errno, "landlock_add_rule failed");
+  }
+  return NoError();
+}
+
+// RestrictSelf enforces ruleset on the calling thread. It must only be called
+// in a forked child.
+void RestrictSelf(const FileDescriptor& ruleset) {
+  TEST_PCHECK(prctl(PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0) == 0);
+  TEST_PCHECK(LandlockRestrictSelf(ruleset.get(), 0) == 0);
+}
+
+// Returns a TCP port that is currently free on the IPv4 loopback address.
+PosixErrorOr<uint16_t> FreeTCPPort() {
+  int fd = socket(AF_INET, SOCK_STREAM, 0);
+  if (fd < 0) {
+    return PosixError(errno, "socket failed");
+  }
+  FileDescriptor s(fd);
+  sockaddr_in addr = {};
+  addr.sin_family = AF_INET;
+  addr.sin_addr.s_addr = htonl(INADDR_LOOPBACK);
+  RETURN_ERROR_IF_SYSCALL_FAIL(
+      bind(s.get(), reinterpret_cast<sockaddr*>(&addr), sizeof(addr)));
+  socklen_t len = sizeof(addr);
+  RETURN_ERROR_IF_SYSCALL_FAIL(
+      getsockname(s.get(), reinterpret_cast<sockaddr*>(&addr), &len));
+  return ntohs(addr.sin_port);
+}
+
+TEST(LandlockTest, ABIVersion) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  EXPECT_THAT(LandlockCreateRuleset(nullptr, 0, kCreateRulesetVersion),
+              SyscallSucceedsWithValue(::testing::Ge(1)));
+  RulesetAttr attr = {};
+  EXPECT_THAT(
+      LandlockCreateRuleset(&attr, sizeof(attr), kCreateRulesetVersion),
+      SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(LandlockCreateRuleset(nullptr, 0, 1 << 1),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(LandlockTest, CreateRulesetInvalid) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  RulesetAttr attr = {};
+  attr.handled_access_fs = kAccessFSReadFile;
+  EXPECT_THAT(LandlockCreateRuleset(&attr, 4, 0),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(LandlockCreateRuleset(nullptr, sizeof(attr), 0),
+              SyscallFailsWithErrno(EFAULT));
+
+  attr.handled_access_fs = 0;
+  EXPECT_THAT(LandlockCreateRuleset(&attr, sizeof(attr), 0),
+              SyscallFailsWithErrno(ENOMSG));
+
+  attr.handled_access_fs = uint64_t{1} << 63;
+  EXPECT_THAT(LandlockCreateRuleset(&attr, sizeof(attr), 0),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(LandlockTest, CreateRulesetExtendedAttr) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  struct {
+    RulesetAttr attr;
+    uint64_t extension;
+  } extended = {};
+  extended.attr.handled_access_fs = kAccessFSReadFile;
+
+  // Unknown trailing fields are accepted if they are zero.
+  int fd = LandlockCreateRuleset(&extended.attr, sizeof(extended), 0);
+  ASSERT_THAT(fd, SyscallSucceeds());
+  FileDescriptor ruleset(fd);
+  EXPECT_THAT(fcntl(ruleset.get(), F_GETFD),
+              SyscallSucceedsWithValue(FD_CLOEXEC));
+
+  extended.extension = 1;
+  EXPECT_THAT(LandlockCreateRuleset(&extended.attr, sizeof(extended), 0),
+              SyscallFailsWithErrno(E2BIG));
+}
+
+TEST(LandlockTest, AddRuleInvalid) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  const FileDescriptor ruleset = ASSERT_NO_ERRNO_AND_VALUE(
+      NewRuleset(kAccessFSReadFile | kAccessFSReadDir, 0));
+  const FileDescriptor dirfd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(dir.path(), O_PATH));
+  const FileDescriptor filefd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(file.path(), O_PATH));
+
+  PathBeneathAttr attr = {};
+  attr.allowed_access = kAccessFSReadFile;
+  attr.parent_fd = dirfd.get();
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), kRulePathBeneath, &attr, 1),
+              SyscallFailsWithErrno(EINVAL));
+  const FileDescriptor other =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(dir.path(), O_RDONLY));
+  EXPECT_THAT(LandlockAddRule(other.get(), kRulePathBeneath, &attr, 0),
+              SyscallFailsWithErrno(EBADFD));
+  EXPECT_THAT(LandlockAddRule(-1, kRulePathBeneath, &attr, 0),
+              SyscallFailsWithErrno(EBADF));
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), 0, &attr, 0),
+              SyscallFailsWithErrno(EINVAL));
+
+  // Access rights must be handled by the ruleset.
+  attr.allowed_access = kAccessFSWriteFile;
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), kRulePathBeneath, &attr, 0),
+              SyscallFailsWithErrno(EINVAL));
+  attr.allowed_access = 0;
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), kRulePathBeneath, &attr, 0),
+              SyscallFailsWithErrno(ENOMSG));
+
+  // Directory access rights can't be granted on a file.
+  attr.allowed_access = kAccessFSReadDir;
+  attr.parent_fd = filefd.get();
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), kRulePathBeneath, &attr, 0),
+              SyscallFailsWithErrno(EINVAL));
+  attr.allowed_access = kAccessFSReadFile;
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), kRulePathBeneath, &attr, 0),
+              SyscallSucceeds());
+
+  attr.parent_fd = -1;
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), kRulePathBeneath, &attr, 0),
+              SyscallFailsWithErrno(EBADF));
+}
+
+TEST(LandlockTest, RulesetFDIsNotReadable) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSReadFile, 0));
+  char buf;
+  EXPECT_THAT(read(ruleset.get(), &buf, 1), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(LandlockRestrictSelf(ruleset.get(), 1),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(LandlockTest, ReadWriteBeneath) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const TempPath allowed = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath denied = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath allowed_file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(allowed.path()));
+  const TempPath denied_file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(denied.path()));
+
+  const uint64_t handled = kAccessFSReadFile | kAccessFSWriteFile;
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(handled, 0));
+  ASSERT_NO_ERRNO(AddPathRule(ruleset, allowed.path(), handled));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                TEST_CHECK_SUCCESS(
+                    open(allowed_file.path().c_str(), O_RDWR));
+                TEST_CHECK_ERRNO(open(denied_file.path().c_str(), O_RDONLY),
+                                 EACCES);
+                TEST_CHECK_ERRNO(open(denied_file.path().c_str(), O_WRONLY),
+                                 EACCES);
+                // Directories aren't affected by file access rights.
+                TEST_CHECK_SUCCESS(
+                    open(denied.path().c_str(), O_RDONLY | O_DIRECTORY));
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, MakeDirDenied) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const std::string subdir = JoinPath(dir.path(), "subdir");
+  const std::string file = JoinPath(dir.path(), "file");
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSMakeDir, 0));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                TEST_CHECK_ERRNO(mkdir(subdir.c_str(), 0755), EACCES);
+                // Creating a regular file isn't handled by the ruleset.
+                TEST_CHECK_SUCCESS(
+                    open(file.c_str(), O_WRONLY | O_CREAT, 0644));
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, SymlinkToDeniedFile) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const TempPath allowed = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath denied = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath denied_file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(denied.path()));
+  const std::string link = JoinPath(allowed.path(), "link");
+  ASSERT_THAT(symlink(denied_file.path().c_str(), link.c_str()),
+              SyscallSucceeds());
+  const std::string dangling = JoinPath(allowed.path(), "dangling");
+  const std::string created = JoinPath(denied.path(), "created");
+  ASSERT_THAT(symlink(created.c_str(), dangling.c_str()), SyscallSucceeds());
+
+  const uint64_t handled =
+      kAccessFSReadFile | kAccessFSWriteFile | kAccessFSMakeReg;
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(handled, 0));
+  ASSERT_NO_ERRNO(AddPathRule(ruleset, allowed.path(), handled));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                // Access rights are checked on the file that is opened.
+                TEST_CHECK_ERRNO(open(link.c_str(), O_RDONLY), EACCES);
+                TEST_CHECK_ERRNO(
+                    open(dangling.c_str(), O_WRONLY | O_CREAT, 0644), EACCES);
+                TEST_CHECK_ERRNO(access(created.c_str(), F_OK), ENOENT);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, DeniedOperationErrors) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath subdir =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDirIn(dir.path()));
+  const std::string missing = JoinPath(dir.path(), "missing");
+  const uint64_t handled =
+      kAccessFSMakeDir | kAccessFSRemoveFile | kAccessFSReadFile;
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(handled, 0));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                // Errors of the operation take precedence over Landlock.
+                TEST_CHECK_ERRNO(mkdir(subdir.path().c_str(), 0755), EEXIST);
+                TEST_CHECK_ERRNO(unlink(missing.c_str()), ENOENT);
+                TEST_CHECK_ERRNO(open(missing.c_str(), O_RDONLY), ENOENT);
+                TEST_CHECK_ERRNO(
+                    mkdir(JoinPath(missing, "subdir").c_str(), 0755), ENOENT);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, NestedLayers) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath subdir =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDirIn(dir.path()));
+  const TempPath file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  const TempPath subfile =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(subdir.path()));
+
+  const FileDescriptor outer =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSReadFile, 0));
+  ASSERT_NO_ERRNO(AddPathRule(outer, dir.path(), kAccessFSReadFile));
+  const FileDescriptor inner =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSReadFile, 0));
+  ASSERT_NO_ERRNO(AddPathRule(inner, subdir.path(), kAccessFSReadFile));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(outer);
+                TEST_CHECK_SUCCESS(open(file.path().c_str(), O_RDONLY));
+                TEST_CHECK_SUCCESS(open(subfile.path().c_str(), O_RDONLY));
+
+                // Access must be granted by every layer.
+                RestrictSelf(inner);
+                TEST_CHECK_ERRNO(open(file.path().c_str(), O_RDONLY), EACCES);
+                TEST_CHECK_SUCCESS(open(subfile.path().c_str(), O_RDONLY));
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, NestedLayersAreInherited) {
+  SKIP_IF(LandlockABIVersion() == 0);
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSReadFile, 0));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                pid_t pid = fork();
+                if (pid == 0) {
+                  TEST_CHECK_ERRNO(open(file.path().c_str(), O_RDONLY),
+                                   EACCES);
+                  _exit(0);
+                }
+                TEST_PCHECK(pid > 0);
+                int status;
+                TEST_PCHECK(RetryEINTR(waitpid)(pid, &status, 0) == pid);
+                TEST_CHECK(WIFEXITED(status) && WEXITSTATUS(status) == 0);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, TruncateDenied) {
+  SKIP_IF(LandlockABIVersion() < 3);
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(
+      TempPath::CreateFileWith(GetAbsoluteTestTmpdir(), "data", 0644));
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSTruncate, 0));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                int fd = open(file.path().c_str(), O_RDWR);
+                TEST_CHECK_SUCCESS(fd);
+                TEST_CHECK_ERRNO(ftruncate(fd, 0), EACCES);
+                TEST_CHECK_ERRNO(truncate(file.path().c_str(), 0), EACCES);
+                TEST_CHECK_ERRNO(open(file.path().c_str(), O_RDWR | O_TRUNC),
+                                 EACCES);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, TruncateAllowedByOpenFD) {
+  SKIP_IF(LandlockABIVersion() < 3);
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(
+      TempPath::CreateFileWith(GetAbsoluteTestTmpdir(), "data", 0644));
+  const FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(file.path(), O_RDWR));
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSTruncate, 0));
+
+  // The truncate right is checked when the file is opened.
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                TEST_CHECK_SUCCESS(ftruncate(fd.get(), 0));
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, ReparentDenied) {
+  SKIP_IF(LandlockABIVersion() < 2);
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const TempPath from =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDirIn(dir.path()));
+  const TempPath to =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDirIn(dir.path()));
+  const TempPath file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(from.path()));
+  const std::string renamed = JoinPath(from.path(), "renamed");
+  const std::string moved = JoinPath(to.path(), "moved");
+
+  const uint64_t handled =
+      kAccessFSMakeReg | kAccessFSRemoveFile | kAccessFSRefer;
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(handled, 0));
+  ASSERT_NO_ERRNO(
+      AddPathRule(ruleset, dir.path(), kAccessFSMakeReg | kAccessFSRemoveFile));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                RestrictSelf(ruleset);
+                // Renaming within a directory doesn't need the refer right.
+                TEST_CHECK_SUCCESS(
+                    rename(file.path().c_str(), renamed.c_str()));
+                TEST_CHECK_ERRNO(rename(renamed.c_str(), moved.c_str()),
+                                 EXDEV);
+                TEST_CHECK_ERRNO(link(renamed.c_str(), moved.c_str()), EXDEV);
+                TEST_CHECK_SUCCESS(rename(renamed.c_str(),
+                                          file.path().c_str()));
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, MountDenied) {
+  SKIP_IF(LandlockABIVersion() == 0);
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(kAccessFSExecute, 0));
+
+  EXPECT_THAT(InForkedProcess([&] {
+                TEST_PCHECK(unshare(CLONE_NEWNS) == 0);
+                RestrictSelf(ruleset);
+                TEST_CHECK_ERRNO(
+                    mount("", dir.path().c_str(), "tmpfs", 0, nullptr), EPERM);
+                TEST_CHECK_ERRNO(umount2("/", MNT_DETACH), EPERM);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(LandlockTest, BindTCPPort) {
+  SKIP_IF(LandlockABIVersion() < 4);
+
+  const uint16_t allowed = ASSERT_NO_ERRNO_AND_VALUE(FreeTCPPort());
+  const uint16_t denied = ASSERT_NO_ERRNO_AND_VALUE(FreeTCPPort());
+  SKIP_IF(allowed == denied);
+
+  const FileDescriptor ruleset =
+      ASSERT_NO_ERRNO_AND_VALUE(NewRuleset(0, kAccessNetBindTCP));
+  NetPortAttr attr = {};
+  attr.allowed_access = kAccessNetBindTCP;
+  attr.port = allowed;
+  ASSERT_THAT(LandlockAddRule(ruleset.get(), kRuleNetPort, &attr, 0),
+              SyscallSucceeds());
+  attr.port = 1 << 16;
+  EXPECT_THAT(LandlockAddRule(ruleset.get(), kRuleNetPort, &attr, 0),
+              SyscallFailsWithErrno(EINVAL));
+
+  EXPECT_THAT(
+      InForkedProcess([&] {
+        RestrictSelf(ruleset);
+        auto bind_port = [](uint16_t port, int type) {
+          int s = socket(AF_INET, type, 0);
+          TEST_CHECK_SUCCESS(s);
+          sockaddr_in addr = {};
+          addr.sin_family = AF_INET;
+          addr.sin_addr.s_addr = htonl(INADDR_LOOPBACK);
+          addr.sin_port = htons(port);
+          int ret =
+              bind(s, reinterpret_cast<sockaddr*>(&addr), sizeof(addr));
+          int err = errno;
+          close(s);
+          errno = err;
+          return ret;
+        };
+        TEST_CHECK_SUCCESS(bind_port(allowed, SOCK_STREAM));
+        TEST_CHECK_ERRNO(bind_port(denied, SOCK_STREAM), EACCES);
+        // Only TCP sockets are restricted.
+        TEST_CHECK_SUCCESS(bind_port(denied, SOCK_DGRAM));
+      }),
+      IsPosixErrorOkAndHolds(0));
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
@@ -2230,6 +2230,107 @@ TEST(MountTest, MountNamespaceSlavesNewUserNamespace) {
   EXPECT_THAT(umount2(child_dir.c_str(), MNT_DETACH), SyscallSucceeds());
 }
 
+TEST(MountTest, MountNamespaceNewUserNamespaceLocksMounts) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const target = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const mnt = ASSERT_NO_ERRNO_AND_VALUE(
+      Mount("", dir.path(), kTmpfs, 0, "mode=0700", MNT_DETACH));
+  auto child_dir = JoinPath(dir.path(), "test");
+  ASSERT_THAT(mkdir(child_dir.c_str(), 0700), SyscallSucceeds());
+  ASSERT_THAT(mount("child", child_dir.c_str(), kTmpfs, 0, NULL),
+              SyscallSucceeds());
+  ASSERT_THAT(mount(NULL, dir.path().c_str(), NULL,
+                    MS_REMOUNT | MS_BIND | MS_RDONLY, NULL),
+              SyscallSucceeds());
+
+  int uid = geteuid();
+  int gid = getegid();
+  std::string umap_str = absl::StrFormat("0 %lu 1", uid);
+  std::string gmap_str = absl::StrFormat("0 %lu 1", gid);
+
+  pid_t child = fork();
+  if (child == 0) {
+    TEST_CHECK(unshare(CLONE_NEWNS | CLONE_NEWUSER) == 0);
+
+    int fd = open("/proc/self/uid_map", O_WRONLY);
+    TEST_CHECK(fd > 0);
+    TEST_CHECK(write(fd, umap_str.c_str(), umap_str.size()) > 0);
+    TEST_CHECK(close(fd) == 0);
+
+    // setgroups isn't implemented in gVisor but is necessary for native tests.
+    fd = open("/proc/self/setgroups", O_WRONLY);
+    if (fd > 0) {
+      TEST_CHECK(write(fd, "deny", 4) > 0);
+      TEST_CHECK(close(fd) == 0);
+    }
+
+    fd = open("/proc/self/gid_map", O_WRONLY);
+    TEST_CHECK(fd > 0);
+    TEST_CHECK(write(fd, gmap_str.c_str(), gmap_str.size()) > 0);
+    TEST_CHECK(close(fd) == 0);
+
+    TEST_CHECK(setuid(0) == 0);
+    TEST_CHECK(setgid(0) == 0);
+
+    // Inherited mounts can't be unmounted, since that would reveal what they
+    // cover.
+    TEST_CHECK(umount2(child_dir.c_str(), MNT_DETACH) == -1 &&
+               errno == EINVAL);
+    TEST_CHECK(umount2(dir.path().c_str(), MNT_DETACH) == -1 &&
+               errno == EINVAL);
+
+    // Inherited restrictions can't be dropped.
+    TEST_CHECK(mount(NULL, dir.path().c_str(), NULL, MS_REMOUNT | MS_BIND,
+                     NULL) == -1 &&
+               errno == EPERM);
+
+    // A non-recursive bind mount would reveal what the locked child mount
+    // covers, but a recursive one doesn't.
+    TEST_CHECK(mount(dir.path().c_str(), target.path().c_str(), NULL, MS_BIND,
+                     NULL) == -1 &&
+               errno == EINVAL);
+    TEST_CHECK(mount(dir.path().c_str(), target.path().c_str(), NULL,
+                     MS_BIND | MS_REC, NULL) == 0);
+    exit(0);
+  }
+  ASSERT_THAT(child, SyscallSucceeds());
+  int status;
+  ASSERT_THAT(waitpid(child, &status, 0), SyscallSucceedsWithValue(child));
+  ASSERT_TRUE(WIFEXITED(status) && WEXITSTATUS(status) == 0);
+
+  EXPECT_THAT(umount2(child_dir.c_str(), MNT_DETACH), SyscallSucceeds());
+}
+
+TEST(MountTest, MountNamespaceNewUserNamespaceReadOnlyLocked) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const mnt = ASSERT_NO_ERRNO_AND_VALUE(
+      Mount("", dir.path(), kTmpfs, MS_RDONLY | MS_NOSUID, "mode=0700",
+            MNT_DETACH));
+
+  pid_t child = fork();
+  if (child == 0) {
+    TEST_CHECK(unshare(CLONE_NEWNS | CLONE_NEWUSER) == 0);
+
+    // Clearing a locked flag fails, but locked flags can be kept and new
+    // ones can be added.
+    TEST_CHECK(mount(NULL, dir.path().c_str(), NULL,
+                     MS_REMOUNT | MS_BIND | MS_RDONLY, NULL) == -1 &&
+               errno == EPERM);
+    TEST_CHECK(mount(NULL, dir.path().c_str(), NULL,
+                     MS_REMOUNT | MS_BIND | MS_RDONLY | MS_NOSUID | MS_NODEV,
+                     NULL) == 0);
+    exit(0);
+  }
+  ASSERT_THAT(child, SyscallSucceeds());
+  int status;
+  ASSERT_THAT(waitpid(child, &status, 0), SyscallSucceedsWithValue(child));
+  ASSERT_TRUE(WIFEXITED(status) && WEXITSTATUS(status) == 0);
+}
+
 TEST(MountTest, MountFailsOnPseudoFilesystemMountpoint) {
   SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
   auto const fd = ASSERT_NO_ERRNO_AND_VALUE(NewEventFD(0, 0));
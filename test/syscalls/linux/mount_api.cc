@@ -0,0 +1,374 @@
+#include <errno.h>
+#include <fcntl.h>
+#include <sys/mount.h>
+#include <sys/stat.h>
+#include <sys/statfs.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+#include <cstdint>
+#include <string>
+
+#include "gtest/gtest.h"
+#include "test/util/cleanup.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/fs_util.h"
+#include "test/util/linux_capability_util.h"
+#include "test/util/mount_util.h"
+#include "test/util/posix_error.h"
+#include "test/util/temp_path.h"
+#include "test/util/test_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+// The new mount API syscalls have the same numbers on all architectures.
+#ifndef __NR_open_tree
+#define __NR_open_tree 428
+#endif
+#ifndef __NR_move_mount
+#define __NR_move_mount 429
+#endif
+#ifndef __NR_fsopen
+#define __NR_fsopen 430
+#endif
+#ifndef __NR_fsconfig
+#define __NR_fsconfig 431
+#endif
+#ifndef __NR_fsmount
+#define __NR_fsmount 432
+#endif
+#ifndef __NR_fspick
+#define __NR_fspick 433
+#endif
+#ifndef __NR_mount_setattr
+#define __NR_mount_setattr 442
+#endif
+
+#ifndef OPEN_TREE_CLONE
+#define OPEN_TREE_CLONE 1
+#endif
+#ifndef MOVE_MOUNT_F_EMPTY_PATH
+#define MOVE_MOUNT_F_EMPTY_PATH 0x4
+#endif
+#ifndef MOUNT_ATTR_RDONLY
+#define MOUNT_ATTR_RDONLY 0x1
+#endif
+#ifndef MOUNT_ATTR_NODEV
+#define MOUNT_ATTR_NODEV 0x4
+#endif
+#ifndef AT_RECURSIVE
+#define AT_RECURSIVE 0x8000
+#endif
+
+// fsconfig(2) commands, which newer C libraries define as an enum.
+constexpr int kFsconfigSetFlag = 0;
+constexpr int kFsconfigSetString = 1;
+constexpr int kFsconfigCmdCreate = 6;
+constexpr int kFsconfigCmdReconfigure = 7;
+
+// MountAttr is struct mount_attr.
+struct MountAttr {
+  uint64_t attr_set;
+  uint64_t attr_clr;
+  uint64_t propagation;
+  uint64_t userns_fd;
+};
+
+int FsOpen(const char* fs_name, unsigned int flags) {
+  return syscall(__NR_fsopen, fs_name, flags);
+}
+
+int FsConfig(int fd, int cmd, const char* key, const char* value, int aux) {
+  return syscall(__NR_fsconfig, fd, cmd, key, value, aux);
+}
+
+int FsMount(int fd, unsigned int flags, unsigned int attr_flags) {
+  return syscall(__NR_fsmount, fd, flags, attr_flags);
+}
+
+int FsPick(int dirfd, const char* path, unsigned int flags) {
+  return syscall(__NR_fspick, dirfd, path, flags);
+}
+
+int OpenTree(int dirfd, const char* path, unsigned int flags) {
+  return syscall(__NR_open_tree, dirfd, path, flags);
+}
+
+int MoveMount(int from_dirfd, const char* from_path, int to_dirfd,
+              const char* to_path, unsigned int flags) {
+  return syscall(__NR_move_mount, from_dirfd, from_path, to_dirfd, to_path,
+                 flags);
+}
+
+int MountSetattr(int dirfd, const char* path, unsigned int flags,
+                 MountAttr* attr) {
+  return syscall(__NR_mount_setattr, dirfd, path, flags, attr, sizeof(*attr));
+}
+
+// UmountCleanup returns a Cleanup that lazily unmounts path.
+Cleanup UmountCleanup(const std::string& path) {
+  return Cleanup([path] {
+    EXPECT_THAT(umount2(path.c_str(), MNT_DETACH), SyscallSucceeds());
+  });
+}
+
+PosixErrorOr<FileDescriptor> NewTmpfsMount(unsigned int attr_flags) {
+  int fd = FsOpen("tmpfs", 0);
+  if (fd < 0) {
+    return PosixError(errno, "fsopen");
+  }
+  const FileDescriptor fsfd(fd);
+  if (FsConfig(fsfd.get(), kFsconfigSetString, "mode", "0700", 0) < 0) {
+    return PosixError(errno, "fsconfig mode");
+  }
+  if (FsConfig(fsfd.get(), kFsconfigCmdCreate, nullptr, nullptr, 0) < 0) {
+    return PosixError(errno, "fsconfig create");
+  }
+  int mntfd = FsMount(fsfd.get(), 0, attr_flags);
+  if (mntfd < 0) {
+    return PosixError(errno, "fsmount");
+  }
+  return FileDescriptor(mntfd);
+}
+
+uint64_t MountFlags(const std::string& path) {
+  struct statfs st = {};
+  EXPECT_THAT(statfs(path.c_str(), &st), SyscallSucceeds());
+  return st.f_flags;
+}
+
+TEST(MountAPITest, FsmountAndMoveMount) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const mntfd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewTmpfsMount(MOUNT_ATTR_NODEV));
+
+  // The detached mount can be used before it's attached.
+  ASSERT_THAT(mkdirat(mntfd.get(), "sub", 0755), SyscallSucceeds());
+
+  ASSERT_THAT(MoveMount(mntfd.get(), "", AT_FDCWD, dir.path().c_str(),
+                        MOVE_MOUNT_F_EMPTY_PATH),
+              SyscallSucceeds());
+  auto const cleanup = UmountCleanup(dir.path());
+
+  const struct stat st = ASSERT_NO_ERRNO_AND_VALUE(Stat(dir.path()));
+  EXPECT_EQ(st.st_mode, S_IFDIR | 0700);
+  EXPECT_NO_ERRNO(Stat(JoinPath(dir.path(), "sub")));
+  EXPECT_EQ(MountFlags(dir.path()) & ST_NODEV, ST_NODEV);
+}
+
+TEST(MountAPITest, FsopenUnknownFilesystem) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  EXPECT_THAT(FsOpen("nonexistentfs", 0), SyscallFailsWithErrno(ENODEV));
+}
+
+TEST(MountAPITest, FsconfigPhases) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  int fd;
+  ASSERT_THAT(fd = FsOpen("tmpfs", 0), SyscallSucceeds());
+  const FileDescriptor fsfd(fd);
+
+  // The filesystem must be created before it's mounted.
+  EXPECT_THAT(FsMount(fsfd.get(), 0, 0), SyscallFailsWithErrno(EBUSY));
+  EXPECT_THAT(
+      FsConfig(fsfd.get(), kFsconfigCmdReconfigure, nullptr, nullptr, 0),
+      SyscallFailsWithErrno(EBUSY));
+  EXPECT_THAT(FsConfig(fsfd.get(), kFsconfigSetFlag, "ro", "x", 0),
+              SyscallFailsWithErrno(EINVAL));
+
+  ASSERT_THAT(FsConfig(fsfd.get(), kFsconfigCmdCreate, nullptr, nullptr, 0),
+              SyscallSucceeds());
+  EXPECT_THAT(FsConfig(fsfd.get(), kFsconfigCmdCreate, nullptr, nullptr, 0),
+              SyscallFailsWithErrno(EBUSY));
+  EXPECT_THAT(FsConfig(fsfd.get(), kFsconfigSetString, "mode", "0700", 0),
+              SyscallFailsWithErrno(EBUSY));
+
+  // Only filesystem contexts can be configured.
+  const auto dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const FileDescriptor dirfd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(dir.path(), O_RDONLY | O_DIRECTORY));
+  EXPECT_THAT(FsConfig(dirfd.get(), kFsconfigCmdCreate, nullptr, nullptr, 0),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(MountAPITest, FsmountReadOnlyFlag) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const FileDescriptor fsfd(FsOpen("tmpfs", 0));
+  ASSERT_GE(fsfd.get(), 0);
+  ASSERT_THAT(FsConfig(fsfd.get(), kFsconfigSetFlag, "ro", nullptr, 0),
+              SyscallSucceeds());
+  ASSERT_THAT(FsConfig(fsfd.get(), kFsconfigCmdCreate, nullptr, nullptr, 0),
+              SyscallSucceeds());
+  const FileDescriptor mntfd(FsMount(fsfd.get(), 0, 0));
+  ASSERT_GE(mntfd.get(), 0);
+  ASSERT_THAT(MoveMount(mntfd.get(), "", AT_FDCWD, dir.path().c_str(),
+                        MOVE_MOUNT_F_EMPTY_PATH),
+              SyscallSucceeds());
+  auto const cleanup = UmountCleanup(dir.path());
+
+  EXPECT_EQ(MountFlags(dir.path()) & ST_RDONLY, ST_RDONLY);
+  EXPECT_THAT(mkdir(JoinPath(dir.path(), "sub").c_str(), 0755),
+              SyscallFailsWithErrno(EROFS));
+}
+
+TEST(MountAPITest, OpenTreeClone) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const src = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const dst = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const mount = ASSERT_NO_ERRNO_AND_VALUE(
+      Mount("", src.path(), "tmpfs", 0, "mode=0700", 0));
+  ASSERT_THAT(mkdir(JoinPath(src.path(), "sub").c_str(), 0755),
+              SyscallSucceeds());
+
+  const FileDescriptor treefd(
+      OpenTree(AT_FDCWD, src.path().c_str(), OPEN_TREE_CLONE | O_CLOEXEC));
+  ASSERT_GE(treefd.get(), 0);
+  struct stat st;
+  ASSERT_THAT(fstatat(treefd.get(), "sub", &st, 0), SyscallSucceeds());
+
+  ASSERT_THAT(MoveMount(treefd.get(), "", AT_FDCWD, dst.path().c_str(),
+                        MOVE_MOUNT_F_EMPTY_PATH),
+              SyscallSucceeds());
+  auto const cleanup = UmountCleanup(dst.path());
+  EXPECT_NO_ERRNO(Stat(JoinPath(dst.path(), "sub")));
+}
+
+TEST(MountAPITest, OpenTreeWithoutClone) {
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+
+  const FileDescriptor fd(OpenTree(AT_FDCWD, dir.path().c_str(), 0));
+  ASSERT_GE(fd.get(), 0);
+  int flags;
+  ASSERT_THAT(flags = fcntl(fd.get(), F_GETFL), SyscallSucceeds());
+  EXPECT_EQ(flags & O_PATH, O_PATH);
+
+  EXPECT_THAT(OpenTree(AT_FDCWD, dir.path().c_str(), AT_RECURSIVE),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(MountAPITest, MoveAttachedMount) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  // Mounts can't be moved out of shared parents, so use a private one.
+  auto const base = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const base_mount =
+      ASSERT_NO_ERRNO_AND_VALUE(Mount("", base.path(), "tmpfs", 0, "", 0));
+  ASSERT_THAT(mount("", base.path().c_str(), "", MS_PRIVATE, nullptr),
+              SyscallSucceeds());
+  const std::string from = JoinPath(base.path(), "from");
+  const std::string to = JoinPath(base.path(), "to");
+  ASSERT_THAT(mkdir(from.c_str(), 0755), SyscallSucceeds());
+  ASSERT_THAT(mkdir(to.c_str(), 0755), SyscallSucceeds());
+  ASSERT_THAT(mount("", from.c_str(), "tmpfs", 0, "mode=0700"),
+              SyscallSucceeds());
+
+  ASSERT_THAT(MoveMount(AT_FDCWD, from.c_str(), AT_FDCWD, to.c_str(), 0),
+              SyscallSucceeds());
+  auto const cleanup = UmountCleanup(to);
+  EXPECT_EQ(ASSERT_NO_ERRNO_AND_VALUE(Stat(to)).st_mode, S_IFDIR | 0700);
+  EXPECT_EQ(ASSERT_NO_ERRNO_AND_VALUE(Stat(from)).st_mode, S_IFDIR | 0755);
+
+  // A mount can't be moved beneath itself.
+  const std::string sub = JoinPath(to, "sub");
+  ASSERT_THAT(mkdir(sub.c_str(), 0755), SyscallSucceeds());
+  EXPECT_THAT(MoveMount(AT_FDCWD, to.c_str(), AT_FDCWD, sub.c_str(), 0),
+              SyscallFailsWithErrno(ELOOP));
+}
+
+TEST(MountAPITest, MountSetattrRecursive) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const mount =
+      ASSERT_NO_ERRNO_AND_VALUE(Mount("", dir.path(), "tmpfs", 0, "", 0));
+  const std::string sub = JoinPath(dir.path(), "sub");
+  ASSERT_THAT(mkdir(sub.c_str(), 0755), SyscallSucceeds());
+  auto const submount =
+      ASSERT_NO_ERRNO_AND_VALUE(Mount("", sub, "tmpfs", 0, "", 0));
+
+  MountAttr attr = {};
+  attr.attr_set = MOUNT_ATTR_RDONLY | MOUNT_ATTR_NODEV;
+  ASSERT_THAT(MountSetattr(AT_FDCWD, dir.path().c_str(), AT_RECURSIVE, &attr),
+              SyscallSucceeds());
+  EXPECT_EQ(MountFlags(dir.path()) & (ST_RDONLY | ST_NODEV),
+            ST_RDONLY | ST_NODEV);
+  EXPECT_EQ(MountFlags(sub) & (ST_RDONLY | ST_NODEV), ST_RDONLY | ST_NODEV);
+  EXPECT_THAT(mkdir(JoinPath(sub, "dir").c_str(), 0755),
+              SyscallFailsWithErrno(EROFS));
+
+  // Without AT_RECURSIVE, only the mount itself is changed.
+  attr = {};
+  attr.attr_clr = MOUNT_ATTR_RDONLY;
+  ASSERT_THAT(MountSetattr(AT_FDCWD, dir.path().c_str(), 0, &attr),
+              SyscallSucceeds());
+  EXPECT_EQ(MountFlags(dir.path()) & ST_RDONLY, 0);
+  EXPECT_EQ(MountFlags(sub) & ST_RDONLY, ST_RDONLY);
+  attr.attr_clr = MOUNT_ATTR_RDONLY | MOUNT_ATTR_NODEV;
+  ASSERT_THAT(MountSetattr(AT_FDCWD, sub.c_str(), 0, &attr),
+              SyscallSucceeds());
+  EXPECT_EQ(MountFlags(sub) & (ST_RDONLY | ST_NODEV), 0);
+}
+
+TEST(MountAPITest, MountSetattrInvalid) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const mount =
+      ASSERT_NO_ERRNO_AND_VALUE(Mount("", dir.path(), "tmpfs", 0, "", 0));
+  const std::string sub = JoinPath(dir.path(), "sub");
+  ASSERT_THAT(mkdir(sub.c_str(), 0755), SyscallSucceeds());
+
+  // The path must be the root of a mount.
+  MountAttr attr = {};
+  attr.attr_set = MOUNT_ATTR_RDONLY;
+  EXPECT_THAT(MountSetattr(AT_FDCWD, sub.c_str(), 0, &attr),
+              SyscallFailsWithErrno(EINVAL));
+
+  attr.propagation = MS_SHARED | MS_PRIVATE;
+  EXPECT_THAT(MountSetattr(AT_FDCWD, dir.path().c_str(), 0, &attr),
+              SyscallFailsWithErrno(EINVAL));
+
+  EXPECT_THAT(syscall(__NR_mount_setattr, AT_FDCWD, dir.path().c_str(), 0,
+                      &attr, sizeof(attr) - 1),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(MountAPITest, FspickReconfigure) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  auto const dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  auto const mount =
+      ASSERT_NO_ERRNO_AND_VALUE(Mount("", dir.path(), "tmpfs", 0, "", 0));
+
+  const FileDescriptor fsfd(FsPick(AT_FDCWD, dir.path().c_str(), 0));
+  ASSERT_GE(fsfd.get(), 0);
+  EXPECT_THAT(FsConfig(fsfd.get(), kFsconfigCmdCreate, nullptr, nullptr, 0),
+              SyscallFailsWithErrno(EBUSY));
+  ASSERT_THAT(FsConfig(fsfd.get(), kFsconfigSetFlag, "ro", nullptr, 0),
+              SyscallSucceeds());
+  ASSERT_THAT(
+      FsConfig(fsfd.get(), kFsconfigCmdReconfigure, nullptr, nullptr, 0),
+      SyscallSucceeds());
+  EXPECT_EQ(MountFlags(dir.path()) & ST_RDONLY, ST_RDONLY);
+
+  ASSERT_THAT(FsConfig(fsfd.get(), kFsconfigSetFlag, "rw", nullptr, 0),
+              SyscallSucceeds());
+  ASSERT_THAT(
+      FsConfig(fsfd.get(), kFsconfigCmdReconfigure, nullptr, nullptr, 0),
+      SyscallSucceeds());
+  EXPECT_EQ(MountFlags(dir.path()) & ST_RDONLY, 0);
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
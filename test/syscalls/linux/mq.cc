@@ -15,19 +15,29 @@
 #include <fcntl.h>
 #include <mqueue.h>
 #include <sched.h>
+#include <signal.h>
 #include <sys/poll.h>
 #include <sys/stat.h>
+#include <time.h>
 #include <unistd.h>
 
 #include <string>
+#include <utility>
+#include <vector>
 
+#include "absl/strings/str_format.h"
+#include "absl/synchronization/notification.h"
+#include "absl/time/clock.h"
+#include "absl/time/time.h"
 #include "test/util/capability_util.h"
 #include "test/util/cleanup.h"
 #include "test/util/fs_util.h"
 #include "test/util/mount_util.h"
 #include "test/util/posix_error.h"
+#include "test/util/signal_util.h"
 #include "test/util/temp_path.h"
 #include "test/util/test_util.h"
+#include "test/util/thread_util.h"
 
 #define NAME_MAX 255
 
@@ -365,6 +375,218 @@ TEST(MqTest, PollEmpty) {
   ASSERT_EQ(pfd.revents, POLLOUT | POLLWRNORM);
 }
 
+// Test that messages are received in priority order, and in FIFO order within
+// the same priority.
+TEST(MqTest, SendReceivePriority) {
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL, 0777, nullptr));
+
+  ASSERT_THAT(mq_send(queue.fd(), "low", 3, 1), SyscallSucceeds());
+  ASSERT_THAT(mq_send(queue.fd(), "high1", 5, 10), SyscallSucceeds());
+  ASSERT_THAT(mq_send(queue.fd(), "high2", 5, 10), SyscallSucceeds());
+
+  struct mq_attr attr;
+  ASSERT_THAT(mq_getattr(queue.fd(), &attr), SyscallSucceeds());
+  EXPECT_EQ(attr.mq_curmsgs, 3);
+
+  std::vector<char> buf(attr.mq_msgsize);
+  unsigned int prio;
+  for (auto const& want : {std::make_pair("high1", 10u),
+                           std::make_pair("high2", 10u),
+                           std::make_pair("low", 1u)}) {
+    ssize_t n;
+    ASSERT_THAT(n = mq_receive(queue.fd(), buf.data(), buf.size(), &prio),
+                SyscallSucceeds());
+    EXPECT_EQ(std::string(buf.data(), n), want.first);
+    EXPECT_EQ(prio, want.second);
+  }
+}
+
+// Test invalid arguments of mq_send(3) and mq_receive(3).
+TEST(MqTest, SendReceiveInvalidArgs) {
+  struct mq_attr attr = {};
+  attr.mq_maxmsg = 1;
+  attr.mq_msgsize = 8;
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL, 0777, &attr));
+
+  char buf[16] = {};
+  EXPECT_THAT(mq_send(queue.fd(), buf, 9, 0), SyscallFailsWithErrno(EMSGSIZE));
+  EXPECT_THAT(mq_send(queue.fd(), buf, 1, MQ_PRIO_MAX),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(mq_receive(queue.fd(), buf, 7, nullptr),
+              SyscallFailsWithErrno(EMSGSIZE));
+  EXPECT_THAT(mq_send(STDIN_FILENO, buf, 1, 0), SyscallFailsWithErrno(EBADF));
+}
+
+// Test mq_send(3) and mq_receive(3) with file descriptors opened without the
+// required access.
+TEST(MqTest, SendReceiveAccess) {
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_WRONLY | O_CREAT | O_EXCL, 0777, nullptr));
+  mqd_t reader;
+  ASSERT_THAT(reader = mq_open(queue.name(), O_RDONLY), SyscallSucceeds());
+  auto cleanup = Cleanup([reader] { mq_close(reader); });
+
+  struct mq_attr attr;
+  ASSERT_THAT(mq_getattr(queue.fd(), &attr), SyscallSucceeds());
+  std::vector<char> buf(attr.mq_msgsize);
+
+  EXPECT_THAT(mq_receive(queue.fd(), buf.data(), buf.size(), nullptr),
+              SyscallFailsWithErrno(EBADF));
+  EXPECT_THAT(mq_send(reader, "msg", 3, 0), SyscallFailsWithErrno(EBADF));
+  ASSERT_THAT(mq_send(queue.fd(), "msg", 3, 0), SyscallSucceeds());
+  EXPECT_THAT(mq_receive(reader, buf.data(), buf.size(), nullptr),
+              SyscallSucceedsWithValue(3));
+}
+
+// Test O_NONBLOCK set by mq_open(3) and mq_setattr(3).
+TEST(MqTest, NonBlocking) {
+  struct mq_attr attr = {};
+  attr.mq_maxmsg = 1;
+  attr.mq_msgsize = 8;
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL | O_NONBLOCK, 0777, &attr));
+
+  char buf[8];
+  EXPECT_THAT(mq_receive(queue.fd(), buf, sizeof(buf), nullptr),
+              SyscallFailsWithErrno(EAGAIN));
+  ASSERT_THAT(mq_send(queue.fd(), "a", 1, 0), SyscallSucceeds());
+  EXPECT_THAT(mq_send(queue.fd(), "b", 1, 0), SyscallFailsWithErrno(EAGAIN));
+
+  struct mq_attr newAttr = {};
+  struct mq_attr oldAttr;
+  ASSERT_THAT(mq_setattr(queue.fd(), &newAttr, &oldAttr), SyscallSucceeds());
+  EXPECT_EQ(oldAttr.mq_flags, O_NONBLOCK);
+  EXPECT_EQ(oldAttr.mq_maxmsg, 1);
+  EXPECT_EQ(oldAttr.mq_msgsize, 8);
+  EXPECT_EQ(oldAttr.mq_curmsgs, 1);
+
+  ASSERT_THAT(mq_getattr(queue.fd(), &attr), SyscallSucceeds());
+  EXPECT_EQ(attr.mq_flags, 0);
+
+  newAttr.mq_flags = O_NONBLOCK | O_APPEND;
+  EXPECT_THAT(mq_setattr(queue.fd(), &newAttr, nullptr),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+// Test that mq_timedsend(3) and mq_timedreceive(3) time out.
+TEST(MqTest, TimedOut) {
+  struct mq_attr attr = {};
+  attr.mq_maxmsg = 1;
+  attr.mq_msgsize = 8;
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL, 0777, &attr));
+
+  struct timespec deadline = absl::ToTimespec(absl::Now() +
+                                              absl::Milliseconds(100));
+  char buf[8];
+  EXPECT_THAT(mq_timedreceive(queue.fd(), buf, sizeof(buf), nullptr, &deadline),
+              SyscallFailsWithErrno(ETIMEDOUT));
+
+  ASSERT_THAT(mq_send(queue.fd(), "a", 1, 0), SyscallSucceeds());
+  deadline = absl::ToTimespec(absl::Now() + absl::Milliseconds(100));
+  EXPECT_THAT(mq_timedsend(queue.fd(), "b", 1, 0, &deadline),
+              SyscallFailsWithErrno(ETIMEDOUT));
+
+  deadline.tv_nsec = -1;
+  EXPECT_THAT(mq_timedsend(queue.fd(), "b", 1, 0, &deadline),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+// Test that a blocked mq_receive(3) is woken up by mq_send(3).
+TEST(MqTest, BlockingReceive) {
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL, 0777, nullptr));
+  mqd_t fd = queue.fd();
+
+  ScopedThread t([fd] {
+    absl::SleepFor(absl::Milliseconds(100));
+    TEST_PCHECK(mq_send(fd, "msg", 3, 5) == 0);
+  });
+
+  struct mq_attr attr;
+  ASSERT_THAT(mq_getattr(fd, &attr), SyscallSucceeds());
+  std::vector<char> buf(attr.mq_msgsize);
+  unsigned int prio;
+  EXPECT_THAT(RetryEINTR(mq_receive)(fd, buf.data(), buf.size(), &prio),
+              SyscallSucceedsWithValue(3));
+  EXPECT_EQ(prio, 5);
+}
+
+// Test mq_notify(3) with SIGEV_SIGNAL.
+TEST(MqTest, NotifySignal) {
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL, 0777, nullptr));
+  auto mask = ASSERT_NO_ERRNO_AND_VALUE(ScopedSignalMask(SIG_BLOCK, SIGUSR1));
+
+  struct sigevent sev = {};
+  sev.sigev_notify = SIGEV_SIGNAL;
+  sev.sigev_signo = SIGUSR1;
+  sev.sigev_value.sival_int = 42;
+  ASSERT_THAT(mq_notify(queue.fd(), &sev), SyscallSucceeds());
+
+  // Only one process can be registered.
+  EXPECT_THAT(mq_notify(queue.fd(), &sev), SyscallFailsWithErrno(EBUSY));
+
+  ASSERT_THAT(mq_send(queue.fd(), "msg", 3, 0), SyscallSucceeds());
+
+  sigset_t set;
+  sigemptyset(&set);
+  sigaddset(&set, SIGUSR1);
+  struct timespec timeout = absl::ToTimespec(absl::Seconds(10));
+  siginfo_t info = {};
+  ASSERT_THAT(RetryEINTR(sigtimedwait)(&set, &info, &timeout),
+              SyscallSucceedsWithValue(SIGUSR1));
+  EXPECT_EQ(info.si_code, SI_MESGQ);
+  EXPECT_EQ(info.si_pid, getpid());
+  EXPECT_EQ(info.si_value.sival_int, 42);
+
+  // The registration is removed after the notification.
+  ASSERT_THAT(mq_notify(queue.fd(), &sev), SyscallSucceeds());
+  ASSERT_THAT(mq_notify(queue.fd(), nullptr), SyscallSucceeds());
+}
+
+// Test mq_notify(3) with SIGEV_THREAD.
+TEST(MqTest, NotifyThread) {
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL, 0777, nullptr));
+
+  static absl::Notification* notified;
+  absl::Notification n;
+  notified = &n;
+
+  struct sigevent sev = {};
+  sev.sigev_notify = SIGEV_THREAD;
+  sev.sigev_notify_function = [](union sigval) { notified->Notify(); };
+  ASSERT_THAT(mq_notify(queue.fd(), &sev), SyscallSucceeds());
+  ASSERT_THAT(mq_send(queue.fd(), "msg", 3, 0), SyscallSucceeds());
+  EXPECT_TRUE(n.WaitForNotificationWithTimeout(absl::Seconds(10)));
+}
+
+// Test that the queue's file shows the queue state.
+TEST(MqTest, ReadState) {
+  PosixQueue queue = ASSERT_NO_ERRNO_AND_VALUE(
+      MqOpen(O_RDWR | O_CREAT | O_EXCL, 0777, nullptr));
+  auto mask = ASSERT_NO_ERRNO_AND_VALUE(ScopedSignalMask(SIG_BLOCK, SIGUSR1));
+
+  ASSERT_THAT(mq_send(queue.fd(), "msg", 3, 0), SyscallSucceeds());
+  ASSERT_THAT(mq_send(queue.fd(), "message", 7, 0), SyscallSucceeds());
+  struct sigevent sev = {};
+  sev.sigev_notify = SIGEV_SIGNAL;
+  sev.sigev_signo = SIGUSR1;
+  ASSERT_THAT(mq_notify(queue.fd(), &sev), SyscallSucceeds());
+
+  char queueRead[128] = {};
+  ASSERT_THAT(pread(queue.fd(), queueRead, sizeof(queueRead) - 1, 0),
+              SyscallSucceeds());
+
+  std::string want = absl::StrFormat(
+      "QSIZE:%-10d NOTIFY:%-5d SIGNO:%-5d NOTIFY_PID:%-6d\n", 10, SIGEV_SIGNAL,
+      SIGUSR1, getpid());
+  EXPECT_EQ(std::string(queueRead), want);
+}
+
 }  // namespace
 }  // namespace testing
 }  // namespace gvisor
@@ -0,0 +1,190 @@
+#include <errno.h>
+#include <fcntl.h>
+#include <poll.h>
+#include <signal.h>
+#include <sys/syscall.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#include <string>
+
+#include "gtest/gtest.h"
+#include "absl/strings/str_cat.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/fs_util.h"
+#include "test/util/test_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+#ifndef SYS_pidfd_open
+#define SYS_pidfd_open 434
+#endif
+#ifndef SYS_pidfd_send_signal
+#define SYS_pidfd_send_signal 424
+#endif
+#ifndef SYS_pidfd_getfd
+#define SYS_pidfd_getfd 438
+#endif
+#ifndef P_PIDFD
+#define P_PIDFD 3
+#endif
+#ifndef CLONE_PIDFD
+#define CLONE_PIDFD 0x1000
+#endif
+
+int pidfd_open(pid_t pid, unsigned int flags) {
+  return syscall(SYS_pidfd_open, pid, flags);
+}
+
+int pidfd_send_signal(int pidfd, int sig, siginfo_t* info,
+                      unsigned int flags) {
+  return syscall(SYS_pidfd_send_signal, pidfd, sig, info, flags);
+}
+
+int pidfd_getfd(int pidfd, int targetfd, unsigned int flags) {
+  return syscall(SYS_pidfd_getfd, pidfd, targetfd, flags);
+}
+
+// ForkSleeper returns the PID of a child that sleeps until it's killed.
+pid_t ForkSleeper() {
+  pid_t child = fork();
+  if (child == 0) {
+    while (true) {
+      pause();
+    }
+  }
+  return child;
+}
+
+TEST(PidfdTest, OpenInvalid) {
+  EXPECT_THAT(pidfd_open(0, 0), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_open(getpid(), O_CLOEXEC), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_open(-1, 0), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PidfdTest, OpenIsCloexec) {
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(getpid(), 0), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+  EXPECT_THAT(fcntl(pidfd.get(), F_GETFD),
+              SyscallSucceedsWithValue(FD_CLOEXEC));
+}
+
+TEST(PidfdTest, PollReadableOnExit) {
+  pid_t child;
+  ASSERT_THAT(child = ForkSleeper(), SyscallSucceeds());
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(child, 0), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+
+  struct pollfd pfd = {.fd = pidfd.get(), .events = POLLIN};
+  EXPECT_THAT(poll(&pfd, 1, 0), SyscallSucceedsWithValue(0));
+
+  ASSERT_THAT(pidfd_send_signal(pidfd.get(), SIGKILL, nullptr, 0),
+              SyscallSucceeds());
+  EXPECT_THAT(RetryEINTR(poll)(&pfd, 1, -1), SyscallSucceedsWithValue(1));
+  EXPECT_EQ(pfd.revents & POLLIN, POLLIN);
+
+  siginfo_t info = {};
+  ASSERT_THAT(waitid(static_cast<idtype_t>(P_PIDFD), pidfd.get(), &info,
+                     WEXITED),
+              SyscallSucceeds());
+  EXPECT_EQ(info.si_pid, child);
+  EXPECT_EQ(info.si_code, CLD_KILLED);
+  EXPECT_EQ(info.si_status, SIGKILL);
+}
+
+TEST(PidfdTest, SendSignalInvalidFlags) {
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(getpid(), 0), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), 0, nullptr, 1),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), 0, nullptr, 0),
+              SyscallSucceeds());
+}
+
+TEST(PidfdTest, SendSignalNotPidfd) {
+  EXPECT_THAT(pidfd_send_signal(STDIN_FILENO, 0, nullptr, 0),
+              SyscallFailsWithErrno(EBADF));
+}
+
+TEST(PidfdTest, WaitidNonblocking) {
+  pid_t child;
+  ASSERT_THAT(child = ForkSleeper(), SyscallSucceeds());
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(child, O_NONBLOCK), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+
+  siginfo_t info = {};
+  EXPECT_THAT(waitid(static_cast<idtype_t>(P_PIDFD), pidfd.get(), &info,
+                     WEXITED),
+              SyscallFailsWithErrno(EAGAIN));
+
+  ASSERT_THAT(kill(child, SIGKILL), SyscallSucceeds());
+  int status;
+  ASSERT_THAT(RetryEINTR(waitpid)(child, &status, 0),
+              SyscallSucceedsWithValue(child));
+}
+
+TEST(PidfdTest, GetfdOfSelf) {
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(getpid(), 0), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+
+  int dup;
+  ASSERT_THAT(dup = pidfd_getfd(pidfd.get(), pidfd.get(), 0),
+              SyscallSucceeds());
+  FileDescriptor dupfd(dup);
+  EXPECT_THAT(fcntl(dupfd.get(), F_GETFD),
+              SyscallSucceedsWithValue(FD_CLOEXEC));
+  EXPECT_THAT(pidfd_getfd(pidfd.get(), -1, 0), SyscallFailsWithErrno(EBADF));
+  EXPECT_THAT(pidfd_getfd(pidfd.get(), pidfd.get(), 1),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PidfdTest, FdinfoPid) {
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(getpid(), 0), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+
+  std::string fdinfo = ASSERT_NO_ERRNO_AND_VALUE(
+      GetContents(absl::StrCat("/proc/self/fdinfo/", pidfd.get())));
+  EXPECT_NE(fdinfo.find(absl::StrCat("Pid:\t", getpid(), "\n")),
+            std::string::npos);
+}
+
+TEST(PidfdTest, CloneWithPidfd) {
+  int fd = -1;
+  pid_t child =
+      syscall(__NR_clone, SIGCHLD | CLONE_PIDFD, nullptr, &fd, nullptr, 0);
+  if (child == 0) {
+    _exit(0);
+  }
+  ASSERT_THAT(child, SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+  ASSERT_GE(pidfd.get(), 0);
+
+  siginfo_t info = {};
+  ASSERT_THAT(RetryEINTR(waitid)(static_cast<idtype_t>(P_PIDFD), pidfd.get(),
+                                 &info, WEXITED),
+              SyscallSucceeds());
+  EXPECT_EQ(info.si_pid, child);
+  EXPECT_EQ(info.si_code, CLD_EXITED);
+}
+
+TEST(PidfdTest, CloneWithPidfdAndParentSettid) {
+  int fd = -1;
+  EXPECT_THAT(syscall(__NR_clone, SIGCHLD | CLONE_PIDFD | CLONE_PARENT_SETTID,
+                      nullptr, &fd, nullptr, 0),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
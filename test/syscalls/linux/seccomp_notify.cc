@@ -0,0 +1,344 @@
+#include <errno.h>
+#include <fcntl.h>
+#include <linux/audit.h>
+#include <linux/filter.h>
+#include <linux/seccomp.h>
+#include <poll.h>
+#include <signal.h>
+#include <sys/ioctl.h>
+#include <sys/prctl.h>
+#include <sys/stat.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+#include <atomic>
+
+#include "gtest/gtest.h"
+#include "absl/base/macros.h"
+#include "absl/synchronization/notification.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/logging.h"
+#include "test/util/posix_error.h"
+#include "test/util/signal_util.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+// The filtered syscall. It is cheap and its result is easy to check when the
+// syscall is continued.
+constexpr uint32_t kFilteredSyscall = SYS_getppid;
+
+// NewListener applies a seccomp-bpf filter that returns
+// SECCOMP_RET_USER_NOTIF for kFilteredSyscall to the calling thread, and
+// returns the listener fd. Filters apply to the calling thread only, so tests
+// call it on a separate thread.
+int NewListener(uint32_t flags = SECCOMP_FILTER_FLAG_NEW_LISTENER) {
+  TEST_PCHECK(prctl(PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0) == 0);
+
+  struct sock_filter filter[] = {
+    // A = seccomp_data.arch
+    BPF_STMT(BPF_LD | BPF_ABS | BPF_W, 4),
+#if defined(__x86_64__)
+    // if (A != AUDIT_ARCH_X86_64) goto kill
+    BPF_JUMP(BPF_JMP | BPF_JEQ | BPF_K, AUDIT_ARCH_X86_64, 0, 4),
+#elif defined(__aarch64__)
+    // if (A != AUDIT_ARCH_AARCH64) goto kill
+    BPF_JUMP(BPF_JMP | BPF_JEQ | BPF_K, AUDIT_ARCH_AARCH64, 0, 4),
+#else
+#error "Unknown architecture"
+#endif
+    // A = seccomp_data.nr
+    BPF_STMT(BPF_LD | BPF_ABS | BPF_W, 0),
+    // if (A != kFilteredSyscall) goto allow
+    BPF_JUMP(BPF_JMP | BPF_JEQ | BPF_K, kFilteredSyscall, 0, 1),
+    // return SECCOMP_RET_USER_NOTIF
+    BPF_STMT(BPF_RET | BPF_K, SECCOMP_RET_USER_NOTIF),
+    // allow: return SECCOMP_RET_ALLOW
+    BPF_STMT(BPF_RET | BPF_K, SECCOMP_RET_ALLOW),
+    // kill: return SECCOMP_RET_KILL
+    BPF_STMT(BPF_RET | BPF_K, SECCOMP_RET_KILL),
+  };
+  struct sock_fprog prog;
+  prog.len = ABSL_ARRAYSIZE(filter);
+  prog.filter = filter;
+  return syscall(__NR_seccomp, SECCOMP_SET_MODE_FILTER, flags, &prog);
+}
+
+// NotifiedSyscall runs kFilteredSyscall on a thread with a listener and
+// stores its result and errno.
+class NotifiedSyscall {
+ public:
+  NotifiedSyscall()
+      : thread_([this] {
+          tid_ = syscall(SYS_gettid);
+          int fd = NewListener();
+          listener_ = fd;
+          listener_errno_ = errno;
+          listening_.Notify();
+          if (fd < 0) {
+            return;
+          }
+          ret_ = syscall(kFilteredSyscall, 1, 2, 3);
+          errno_ = errno;
+        }) {
+    listening_.WaitForNotification();
+  }
+
+  // Listener returns the listener fd.
+  int Listener() const { return listener_; }
+
+  // ListenerErrno returns the errno of the listener creation.
+  int ListenerErrno() const { return listener_errno_; }
+
+  // Tid returns the thread ID of the thread running the syscall.
+  pid_t Tid() const { return tid_; }
+
+  // Join waits for the syscall to return.
+  void Join() { thread_.Join(); }
+
+  long Ret() const { return ret_; }
+  int Errno() const { return errno_; }
+
+ private:
+  absl::Notification listening_;
+  std::atomic<int> listener_{-1};
+  std::atomic<int> listener_errno_{0};
+  std::atomic<pid_t> tid_{0};
+  long ret_ = 0;
+  int errno_ = 0;
+  ScopedThread thread_;
+};
+
+PosixErrorOr<struct seccomp_notif> Recv(int fd) {
+  struct seccomp_notif notif = {};
+  if (ioctl(fd, SECCOMP_IOCTL_NOTIF_RECV, &notif) < 0) {
+    return PosixError(errno, "SECCOMP_IOCTL_NOTIF_RECV");
+  }
+  return notif;
+}
+
+TEST(SeccompNotifyTest, GetNotifSizes) {
+  struct seccomp_notif_sizes sizes = {};
+  ASSERT_THAT(syscall(__NR_seccomp, SECCOMP_GET_NOTIF_SIZES, 0, &sizes),
+              SyscallSucceeds());
+  EXPECT_EQ(sizes.seccomp_notif, sizeof(struct seccomp_notif));
+  EXPECT_EQ(sizes.seccomp_notif_resp, sizeof(struct seccomp_notif_resp));
+  EXPECT_EQ(sizes.seccomp_data, sizeof(struct seccomp_data));
+}
+
+TEST(SeccompNotifyTest, ListenerWithTsync) {
+  ScopedThread([] {
+    EXPECT_THAT(NewListener(SECCOMP_FILTER_FLAG_NEW_LISTENER |
+                            SECCOMP_FILTER_FLAG_TSYNC),
+                SyscallFailsWithErrno(EINVAL));
+  });
+}
+
+TEST(SeccompNotifyTest, SecondListener) {
+  ScopedThread([] {
+    int fd = NewListener();
+    ASSERT_THAT(fd, SyscallSucceeds());
+    FileDescriptor listener(fd);
+    EXPECT_THAT(NewListener(), SyscallFailsWithErrno(EBUSY));
+  });
+}
+
+TEST(SeccompNotifyTest, RespondWithError) {
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+  FileDescriptor listener(s.Listener());
+
+  struct seccomp_notif notif =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  EXPECT_EQ(notif.data.nr, kFilteredSyscall);
+  EXPECT_EQ(notif.data.args[0], 1);
+  EXPECT_EQ(notif.data.args[1], 2);
+  EXPECT_EQ(notif.data.args[2], 3);
+
+  // The notification is pending until the response.
+  EXPECT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_ID_VALID, &notif.id),
+              SyscallSucceeds());
+
+  struct seccomp_notif_resp resp = {};
+  resp.id = notif.id;
+  resp.error = -EPERM;
+  ASSERT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_SEND, &resp),
+              SyscallSucceeds());
+  s.Join();
+  EXPECT_EQ(s.Ret(), -1);
+  EXPECT_EQ(s.Errno(), EPERM);
+
+  EXPECT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_ID_VALID, &notif.id),
+              SyscallFailsWithErrno(ENOENT));
+  EXPECT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_SEND, &resp),
+              SyscallFailsWithErrno(ENOENT));
+}
+
+TEST(SeccompNotifyTest, RespondWithValue) {
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+  FileDescriptor listener(s.Listener());
+
+  struct seccomp_notif notif =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  struct seccomp_notif_resp resp = {};
+  resp.id = notif.id;
+  resp.val = 42;
+  ASSERT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_SEND, &resp),
+              SyscallSucceeds());
+  s.Join();
+  EXPECT_EQ(s.Ret(), 42);
+}
+
+TEST(SeccompNotifyTest, Continue) {
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+  FileDescriptor listener(s.Listener());
+
+  struct seccomp_notif notif =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  struct seccomp_notif_resp resp = {};
+  resp.id = notif.id;
+  resp.flags = SECCOMP_USER_NOTIF_FLAG_CONTINUE;
+
+  // Continued syscalls can't have a return value.
+  resp.error = -EPERM;
+  EXPECT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_SEND, &resp),
+              SyscallFailsWithErrno(EINVAL));
+
+  resp.error = 0;
+  ASSERT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_SEND, &resp),
+              SyscallSucceeds());
+  s.Join();
+  EXPECT_EQ(s.Ret(), getppid());
+}
+
+TEST(SeccompNotifyTest, AddFd) {
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+  FileDescriptor listener(s.Listener());
+
+  int pipe_fds[2];
+  ASSERT_THAT(pipe(pipe_fds), SyscallSucceeds());
+  FileDescriptor rfd(pipe_fds[0]);
+  FileDescriptor wfd(pipe_fds[1]);
+
+  struct seccomp_notif notif =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  struct seccomp_notif_addfd addfd = {};
+  addfd.id = notif.id;
+  addfd.flags = SECCOMP_ADDFD_FLAG_SEND;
+  addfd.srcfd = rfd.get();
+  int fd;
+  ASSERT_THAT(fd = ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_ADDFD, &addfd),
+              SyscallSucceeds());
+  FileDescriptor added(fd);
+  s.Join();
+
+  // The fd is returned by the syscall, and refers to the same pipe.
+  EXPECT_EQ(s.Ret(), fd);
+  struct stat st1, st2;
+  ASSERT_THAT(fstat(rfd.get(), &st1), SyscallSucceeds());
+  ASSERT_THAT(fstat(added.get(), &st2), SyscallSucceeds());
+  EXPECT_EQ(st1.st_ino, st2.st_ino);
+}
+
+TEST(SeccompNotifyTest, PollListener) {
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+  FileDescriptor listener(s.Listener());
+
+  struct pollfd pfd = {.fd = listener.get(), .events = POLLIN};
+  ASSERT_THAT(RetryEINTR(poll)(&pfd, 1, -1), SyscallSucceedsWithValue(1));
+  EXPECT_EQ(pfd.revents, POLLIN);
+
+  struct seccomp_notif notif =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  pfd = {.fd = listener.get(), .events = POLLIN | POLLOUT};
+  ASSERT_THAT(RetryEINTR(poll)(&pfd, 1, 0), SyscallSucceedsWithValue(1));
+  EXPECT_EQ(pfd.revents, POLLOUT);
+
+  struct seccomp_notif_resp resp = {};
+  resp.id = notif.id;
+  ASSERT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_SEND, &resp),
+              SyscallSucceeds());
+}
+
+void NoopSignalHandler(int sig, siginfo_t* info, void* context) {}
+
+// A syscall interrupted by a signal handled with SA_RESTART is restarted,
+// which sends a new notification.
+TEST(SeccompNotifyTest, InterruptedRestart) {
+  struct sigaction sa = {};
+  sa.sa_sigaction = NoopSignalHandler;
+  sa.sa_flags = SA_SIGINFO | SA_RESTART;
+  auto cleanup = ASSERT_NO_ERRNO_AND_VALUE(ScopedSigaction(SIGUSR1, sa));
+
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+  FileDescriptor listener(s.Listener());
+
+  struct seccomp_notif notif =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  ASSERT_THAT(syscall(SYS_tgkill, getpid(), s.Tid(), SIGUSR1),
+              SyscallSucceeds());
+
+  struct seccomp_notif restarted =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  EXPECT_NE(restarted.id, notif.id);
+  EXPECT_EQ(restarted.data.nr, kFilteredSyscall);
+  EXPECT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_ID_VALID, &notif.id),
+              SyscallFailsWithErrno(ENOENT));
+
+  struct seccomp_notif_resp resp = {};
+  resp.id = restarted.id;
+  resp.val = 42;
+  ASSERT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_SEND, &resp),
+              SyscallSucceeds());
+  s.Join();
+  EXPECT_EQ(s.Ret(), 42);
+}
+
+// A syscall interrupted by a signal handled without SA_RESTART fails with
+// EINTR.
+TEST(SeccompNotifyTest, InterruptedNoRestart) {
+  struct sigaction sa = {};
+  sa.sa_sigaction = NoopSignalHandler;
+  sa.sa_flags = SA_SIGINFO;
+  auto cleanup = ASSERT_NO_ERRNO_AND_VALUE(ScopedSigaction(SIGUSR1, sa));
+
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+  FileDescriptor listener(s.Listener());
+
+  struct seccomp_notif notif =
+      ASSERT_NO_ERRNO_AND_VALUE(Recv(listener.get()));
+  ASSERT_THAT(syscall(SYS_tgkill, getpid(), s.Tid(), SIGUSR1),
+              SyscallSucceeds());
+  s.Join();
+  EXPECT_EQ(s.Ret(), -1);
+  EXPECT_EQ(s.Errno(), EINTR);
+
+  EXPECT_THAT(ioctl(listener.get(), SECCOMP_IOCTL_NOTIF_ID_VALID, &notif.id),
+              SyscallFailsWithErrno(ENOENT));
+}
+
+TEST(SeccompNotifyTest, ListenerClosed) {
+  NotifiedSyscall s;
+  ASSERT_THAT(s.Listener(), SyscallSucceeds()) << s.ListenerErrno();
+
+  // Pending and future notifications fail with ENOSYS.
+  ASSERT_THAT(close(s.Listener()), SyscallSucceeds());
+  s.Join();
+  EXPECT_EQ(s.Ret(), -1);
+  EXPECT_EQ(s.Errno(), ENOSYS);
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
@@ -0,0 +1,168 @@
+#include <errno.h>
+#include <fcntl.h>
+#include <sched.h>
+#include <sys/stat.h>
+#include <sys/syscall.h>
+#include <sys/wait.h>
+#include <time.h>
+#include <unistd.h>
+
+#include <string>
+
+#include "gtest/gtest.h"
+#include "absl/strings/string_view.h"
+#include "test/util/linux_capability_util.h"
+#include "test/util/logging.h"
+#include "test/util/multiprocess_util.h"
+#include "test/util/posix_error.h"
+#include "test/util/test_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+#ifndef CLONE_NEWTIME
+#define CLONE_NEWTIME 0x80
+#endif
+
+constexpr char kOffsetsPath[] = "/proc/self/timens_offsets";
+
+// Offsets written to the time namespace, in seconds.
+constexpr int64_t kMonotonicOffset = 1000;
+constexpr int64_t kBoottimeOffset = 2000;
+
+// Slack for the time elapsed between measurements, in seconds.
+constexpr int64_t kSlack = 60;
+
+void SkipIfNoTimeNamespaces() {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_TIME)));
+  SKIP_IF(access(kOffsetsPath, F_OK) != 0);
+}
+
+// WriteOffsets writes s to /proc/self/timens_offsets, returning -1 with errno
+// set on failure.
+int WriteOffsets(absl::string_view s) {
+  int fd = open(kOffsetsPath, O_WRONLY);
+  if (fd < 0) {
+    return -1;
+  }
+  int ret = write(fd, s.data(), s.size());
+  int saved_errno = errno;
+  close(fd);
+  errno = saved_errno;
+  return ret;
+}
+
+int64_t Seconds(clockid_t clock, bool vdso) {
+  struct timespec ts;
+  if (vdso) {
+    TEST_PCHECK(clock_gettime(clock, &ts) == 0);
+  } else {
+    TEST_PCHECK(syscall(SYS_clock_gettime, clock, &ts) == 0);
+  }
+  return ts.tv_sec;
+}
+
+ino_t NamespaceInode(const char* path) {
+  struct stat st;
+  TEST_PCHECK(stat(path, &st) == 0);
+  return st.st_ino;
+}
+
+TEST(TimeNamespaceTest, UnshareOnlyAffectsChildren) {
+  SkipIfNoTimeNamespaces();
+
+  EXPECT_THAT(InForkedProcess([] {
+                ino_t before = NamespaceInode("/proc/self/ns/time");
+                TEST_PCHECK(unshare(CLONE_NEWTIME) == 0);
+                TEST_CHECK(NamespaceInode("/proc/self/ns/time") == before);
+                TEST_CHECK(NamespaceInode(
+                               "/proc/self/ns/time_for_children") != before);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(TimeNamespaceTest, OffsetsApplyToChildren) {
+  SkipIfNoTimeNamespaces();
+
+  EXPECT_THAT(
+      InForkedProcess([] {
+        TEST_PCHECK(unshare(CLONE_NEWTIME) == 0);
+        TEST_PCHECK(WriteOffsets("monotonic 1000 0\nboottime 2000 0\n") >= 0);
+
+        int64_t monotonic = Seconds(CLOCK_MONOTONIC, false);
+        int64_t boottime = Seconds(CLOCK_BOOTTIME, false);
+        pid_t child = fork();
+        if (child == 0) {
+          for (bool vdso : {true, false}) {
+            int64_t m = Seconds(CLOCK_MONOTONIC, vdso) - kMonotonicOffset;
+            TEST_CHECK(m >= monotonic && m < monotonic + kSlack);
+            int64_t b = Seconds(CLOCK_BOOTTIME, vdso) - kBoottimeOffset;
+            TEST_CHECK(b >= boottime && b < boottime + kSlack);
+          }
+          _exit(0);
+        }
+        TEST_PCHECK(child > 0);
+        int status;
+        TEST_PCHECK(RetryEINTR(waitpid)(child, &status, 0) == child);
+        TEST_CHECK(WIFEXITED(status) && WEXITSTATUS(status) == 0);
+
+        // The parent remains in its time namespace.
+        TEST_CHECK(Seconds(CLOCK_MONOTONIC, true) < monotonic + kSlack);
+
+        // Offsets can't be changed once a task entered the namespace.
+        TEST_CHECK(WriteOffsets("monotonic 1 0\n") < 0 && errno == EACCES);
+      }),
+      IsPosixErrorOkAndHolds(0));
+}
+
+TEST(TimeNamespaceTest, NoSharedAddressSpaceAcrossNamespaces) {
+  SkipIfNoTimeNamespaces();
+
+  EXPECT_THAT(InForkedProcess([] {
+                TEST_PCHECK(unshare(CLONE_NEWTIME) == 0);
+                TEST_CHECK(vfork() < 0 && errno == EINVAL);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(TimeNamespaceTest, InvalidOffsets) {
+  SkipIfNoTimeNamespaces();
+
+  EXPECT_THAT(InForkedProcess([] {
+                TEST_PCHECK(unshare(CLONE_NEWTIME) == 0);
+                TEST_CHECK(WriteOffsets("realtime 1 0\n") < 0 &&
+                           errno == EINVAL);
+                TEST_CHECK(WriteOffsets("monotonic 1 1000000000\n") < 0 &&
+                           errno == EINVAL);
+                TEST_CHECK(WriteOffsets("monotonic 1\n") < 0 &&
+                           errno == EINVAL);
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+TEST(TimeNamespaceTest, ReadOffsets) {
+  SkipIfNoTimeNamespaces();
+
+  EXPECT_THAT(InForkedProcess([] {
+                TEST_PCHECK(unshare(CLONE_NEWTIME) == 0);
+                TEST_PCHECK(WriteOffsets("1 -1 500000000\n") >= 0);
+
+                char buf[128] = {};
+                int fd = open(kOffsetsPath, O_RDONLY);
+                TEST_PCHECK(fd >= 0);
+                TEST_PCHECK(read(fd, buf, sizeof(buf) - 1) > 0);
+                close(fd);
+                TEST_CHECK(std::string(buf) ==
+                           "monotonic          -1 500000000\n"
+                           "boottime            0         0\n");
+              }),
+              IsPosixErrorOkAndHolds(0));
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
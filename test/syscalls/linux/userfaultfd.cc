@@ -0,0 +1,346 @@
+#include <errno.h>
+#include <fcntl.h>
+#include <linux/userfaultfd.h>
+#include <poll.h>
+#include <sys/ioctl.h>
+#include <sys/mman.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+#include <vector>
+
+#include "gtest/gtest.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/memory_util.h"
+#include "test/util/posix_error.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+#ifndef UFFD_USER_MODE_ONLY
+#define UFFD_USER_MODE_ONLY 1
+#endif
+
+// gVisor populates small anonymous mappings when they are created, and such
+// pages aren't missing anymore. Tests use mappings that are large enough to be
+// populated on demand.
+constexpr size_t kMappingSize = 4 << 20;
+
+PosixErrorOr<Mapping> MmapRegion() {
+  return MmapAnon(kMappingSize, PROT_READ | PROT_WRITE, MAP_PRIVATE);
+}
+
+// NewUserfaultfd returns a userfaultfd which handles faults of the
+// application only, so that it can be created without privileges.
+PosixErrorOr<FileDescriptor> NewUserfaultfd(int flags) {
+  int fd = syscall(SYS_userfaultfd, flags | UFFD_USER_MODE_ONLY);
+  MaybeSave();
+  if (fd < 0) {
+    return PosixError(errno, "userfaultfd");
+  }
+  return FileDescriptor(fd);
+}
+
+// InitUserfaultfd returns a userfaultfd after UFFDIO_API.
+PosixErrorOr<FileDescriptor> InitUserfaultfd(uint64_t features) {
+  ASSIGN_OR_RETURN_ERRNO(FileDescriptor fd,
+                         NewUserfaultfd(O_CLOEXEC | O_NONBLOCK));
+  struct uffdio_api api = {.api = UFFD_API, .features = features};
+  if (ioctl(fd.get(), UFFDIO_API, &api) < 0) {
+    return PosixError(errno, "UFFDIO_API");
+  }
+  return std::move(fd);
+}
+
+PosixError Register(int fd, const Mapping& m, uint64_t mode) {
+  struct uffdio_register reg = {};
+  reg.range.start = m.addr();
+  reg.range.len = m.len();
+  reg.mode = mode;
+  if (ioctl(fd, UFFDIO_REGISTER, &reg) < 0) {
+    return PosixError(errno, "UFFDIO_REGISTER");
+  }
+  return NoError();
+}
+
+// ReadMsg waits for a message of the userfaultfd fd.
+PosixErrorOr<struct uffd_msg> ReadMsg(int fd) {
+  struct pollfd pfd = {.fd = fd, .events = POLLIN};
+  if (RetryEINTR(poll)(&pfd, 1, -1) < 0) {
+    return PosixError(errno, "poll");
+  }
+  struct uffd_msg msg;
+  int n = read(fd, &msg, sizeof(msg));
+  if (n < 0) {
+    return PosixError(errno, "read");
+  }
+  if (n != sizeof(msg)) {
+    return PosixError(EIO, "short read");
+  }
+  return msg;
+}
+
+PosixError Unregister(int fd, const Mapping& m) {
+  struct uffdio_range range = {.start = m.addr(), .len = m.len()};
+  if (ioctl(fd, UFFDIO_UNREGISTER, &range) < 0) {
+    return PosixError(errno, "UFFDIO_UNREGISTER");
+  }
+  return NoError();
+}
+
+// ExpectUnmapEvent expects an UFFD_EVENT_UNMAP of [start, end) to be read
+// from the userfaultfd fd.
+void ExpectUnmapEvent(int fd, uintptr_t start, uintptr_t end) {
+  struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadMsg(fd));
+  ASSERT_EQ(msg.event, UFFD_EVENT_UNMAP);
+  EXPECT_EQ(msg.arg.remove.start, start);
+  EXPECT_EQ(msg.arg.remove.end, end);
+}
+
+TEST(UserfaultfdTest, InvalidFlags) {
+  EXPECT_THAT(syscall(SYS_userfaultfd, ~(O_CLOEXEC | O_NONBLOCK)),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(UserfaultfdTest, Api) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(NewUserfaultfd(O_NONBLOCK));
+
+  // Messages can't be read before UFFDIO_API.
+  struct uffd_msg msg;
+  EXPECT_THAT(read(fd.get(), &msg, sizeof(msg)),
+              SyscallFailsWithErrno(EINVAL));
+
+  struct uffdio_api api = {.api = 0};
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_API, &api),
+              SyscallFailsWithErrno(EINVAL));
+
+  api = {.api = UFFD_API};
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_API, &api), SyscallSucceeds());
+  EXPECT_EQ(api.api, UFFD_API);
+  EXPECT_NE(api.ioctls & (1 << _UFFDIO_REGISTER), 0);
+  EXPECT_NE(api.ioctls & (1 << _UFFDIO_UNREGISTER), 0);
+  // All supported features are reported, even if none were requested.
+  EXPECT_NE(api.features & UFFD_FEATURE_SIGBUS, 0);
+  EXPECT_NE(api.features & UFFD_FEATURE_EVENT_UNMAP, 0);
+
+  EXPECT_THAT(read(fd.get(), &msg, sizeof(msg)),
+              SyscallFailsWithErrno(EAGAIN));
+  EXPECT_THAT(read(fd.get(), &msg, sizeof(msg) - 1),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(UserfaultfdTest, RegisterInvalid) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(InitUserfaultfd(0));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+
+  EXPECT_THAT(Register(fd.get(), m, 0), PosixErrorIs(EINVAL, ::testing::_));
+
+  struct uffdio_register reg = {};
+  reg.range.start = m.addr() + 1;
+  reg.range.len = kPageSize;
+  reg.mode = UFFDIO_REGISTER_MODE_MISSING;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_REGISTER, &reg),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(UserfaultfdTest, Register) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(InitUserfaultfd(0));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+
+  struct uffdio_register reg = {};
+  reg.range.start = m.addr();
+  reg.range.len = m.len();
+  reg.mode = UFFDIO_REGISTER_MODE_MISSING;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_REGISTER, &reg), SyscallSucceeds());
+  EXPECT_NE(reg.ioctls & (1 << _UFFDIO_COPY), 0);
+  EXPECT_NE(reg.ioctls & (1 << _UFFDIO_ZEROPAGE), 0);
+  EXPECT_NE(reg.ioctls & (1 << _UFFDIO_WAKE), 0);
+
+  // The range can't be registered with another userfaultfd.
+  FileDescriptor fd2 = ASSERT_NO_ERRNO_AND_VALUE(InitUserfaultfd(0));
+  EXPECT_THAT(Register(fd2.get(), m, UFFDIO_REGISTER_MODE_MISSING),
+              PosixErrorIs(EBUSY, ::testing::_));
+
+  struct uffdio_range range = {.start = m.addr(), .len = m.len()};
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_UNREGISTER, &range), SyscallSucceeds());
+  EXPECT_NO_ERRNO(Register(fd2.get(), m, UFFDIO_REGISTER_MODE_MISSING));
+}
+
+TEST(UserfaultfdTest, MissingFaultCopy) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(InitUserfaultfd(0));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+  ASSERT_NO_ERRNO(Register(fd.get(), m, UFFDIO_REGISTER_MODE_MISSING));
+
+  ScopedThread handler([&] {
+    struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadMsg(fd.get()));
+    ASSERT_EQ(msg.event, UFFD_EVENT_PAGEFAULT);
+    EXPECT_EQ(msg.arg.pagefault.address, m.addr());
+    EXPECT_EQ(msg.arg.pagefault.flags & UFFD_PAGEFAULT_FLAG_WRITE, 0);
+
+    std::vector<char> page(kPageSize, 'a');
+    struct uffdio_copy copy = {};
+    copy.dst = m.addr();
+    copy.src = reinterpret_cast<uintptr_t>(page.data());
+    copy.len = kPageSize;
+    ASSERT_THAT(ioctl(fd.get(), UFFDIO_COPY, &copy), SyscallSucceeds());
+    EXPECT_EQ(copy.copy, static_cast<int64_t>(kPageSize));
+  });
+
+  EXPECT_EQ(*static_cast<volatile char*>(m.ptr()), 'a');
+  handler.Join();
+
+  // The page exists now.
+  std::vector<char> page(kPageSize, 'b');
+  struct uffdio_copy copy = {};
+  copy.dst = m.addr();
+  copy.src = reinterpret_cast<uintptr_t>(page.data());
+  copy.len = kPageSize;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_COPY, &copy),
+              SyscallFailsWithErrno(EEXIST));
+  EXPECT_EQ(copy.copy, -EEXIST);
+}
+
+// Features that weren't requested aren't enabled: faults block instead of
+// raising SIGBUS and unmapping doesn't generate events.
+TEST(UserfaultfdTest, NoFeatures) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(InitUserfaultfd(0));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+  ASSERT_NO_ERRNO(Register(fd.get(), m, UFFDIO_REGISTER_MODE_MISSING));
+
+  char* p = static_cast<char*>(m.ptr());
+  ASSERT_THAT(munmap(p + m.len() - kPageSize, kPageSize), SyscallSucceeds());
+  struct uffd_msg msg;
+  EXPECT_THAT(read(fd.get(), &msg, sizeof(msg)),
+              SyscallFailsWithErrno(EAGAIN));
+
+  ScopedThread handler([&] {
+    struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadMsg(fd.get()));
+    ASSERT_EQ(msg.event, UFFD_EVENT_PAGEFAULT);
+    EXPECT_EQ(msg.arg.pagefault.address, m.addr());
+
+    std::vector<char> page(kPageSize, 'a');
+    struct uffdio_copy copy = {};
+    copy.dst = m.addr();
+    copy.src = reinterpret_cast<uintptr_t>(page.data());
+    copy.len = kPageSize;
+    ASSERT_THAT(ioctl(fd.get(), UFFDIO_COPY, &copy), SyscallSucceeds());
+  });
+
+  EXPECT_EQ(*static_cast<volatile char*>(p), 'a');
+  handler.Join();
+}
+
+TEST(UserfaultfdTest, UnmapEventMunmap) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(
+      InitUserfaultfd(UFFD_FEATURE_EVENT_UNMAP));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+  ASSERT_NO_ERRNO(Register(fd.get(), m, UFFDIO_REGISTER_MODE_MISSING));
+
+  uintptr_t start = m.addr() + kPageSize;
+  ScopedThread handler(
+      [&] { ExpectUnmapEvent(fd.get(), start, start + kPageSize); });
+  ASSERT_THAT(munmap(reinterpret_cast<void*>(start), kPageSize),
+              SyscallSucceeds());
+  handler.Join();
+
+  EXPECT_NO_ERRNO(Unregister(fd.get(), m));
+}
+
+TEST(UserfaultfdTest, UnmapEventMapFixed) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(
+      InitUserfaultfd(UFFD_FEATURE_EVENT_UNMAP));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+  ASSERT_NO_ERRNO(Register(fd.get(), m, UFFDIO_REGISTER_MODE_MISSING));
+
+  uintptr_t start = m.addr() + kPageSize;
+  ScopedThread handler(
+      [&] { ExpectUnmapEvent(fd.get(), start, start + kPageSize); });
+  ASSERT_EQ(reinterpret_cast<uintptr_t>(
+                mmap(reinterpret_cast<void*>(start), kPageSize,
+                     PROT_READ | PROT_WRITE,
+                     MAP_PRIVATE | MAP_ANONYMOUS | MAP_FIXED, -1, 0)),
+            start);
+  handler.Join();
+
+  EXPECT_NO_ERRNO(Unregister(fd.get(), m));
+}
+
+TEST(UserfaultfdTest, UnmapEventMremapShrink) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(
+      InitUserfaultfd(UFFD_FEATURE_EVENT_UNMAP));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+  ASSERT_NO_ERRNO(Register(fd.get(), m, UFFDIO_REGISTER_MODE_MISSING));
+
+  size_t new_len = m.len() - kPageSize;
+  ScopedThread handler(
+      [&] { ExpectUnmapEvent(fd.get(), m.addr() + new_len, m.endaddr()); });
+  ASSERT_EQ(mremap(m.ptr(), m.len(), new_len, 0), m.ptr());
+  handler.Join();
+
+  EXPECT_NO_ERRNO(Unregister(fd.get(), m));
+}
+
+TEST(UserfaultfdTest, Zeropage) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(InitUserfaultfd(0));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+  ASSERT_NO_ERRNO(Register(fd.get(), m, UFFDIO_REGISTER_MODE_MISSING));
+
+  struct uffdio_zeropage zero = {};
+  zero.range.start = m.addr();
+  zero.range.len = m.len();
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_ZEROPAGE, &zero), SyscallSucceeds());
+  EXPECT_EQ(zero.zeropage, static_cast<int64_t>(m.len()));
+
+  // Populated pages don't fault.
+  char* p = static_cast<char*>(m.ptr());
+  EXPECT_EQ(p[0], 0);
+  p[kPageSize] = 'a';
+  EXPECT_EQ(p[kPageSize], 'a');
+}
+
+TEST(UserfaultfdTest, WriteProtectFault) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(
+      InitUserfaultfd(UFFD_FEATURE_PAGEFAULT_FLAG_WP));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(MmapRegion());
+  ASSERT_NO_ERRNO(Register(
+      fd.get(), m, UFFDIO_REGISTER_MODE_MISSING | UFFDIO_REGISTER_MODE_WP));
+
+  struct uffdio_zeropage zero = {};
+  zero.range.start = m.addr();
+  zero.range.len = m.len();
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_ZEROPAGE, &zero), SyscallSucceeds());
+
+  struct uffdio_writeprotect wp = {};
+  wp.range.start = m.addr();
+  wp.range.len = m.len();
+  wp.mode = UFFDIO_WRITEPROTECT_MODE_WP;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_WRITEPROTECT, &wp), SyscallSucceeds());
+
+  ScopedThread handler([&] {
+    struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadMsg(fd.get()));
+    ASSERT_EQ(msg.event, UFFD_EVENT_PAGEFAULT);
+    EXPECT_EQ(msg.arg.pagefault.address, m.addr());
+    EXPECT_NE(msg.arg.pagefault.flags & UFFD_PAGEFAULT_FLAG_WP, 0);
+
+    struct uffdio_writeprotect wp = {};
+    wp.range.start = m.addr();
+    wp.range.len = m.len();
+    ASSERT_THAT(ioctl(fd.get(), UFFDIO_WRITEPROTECT, &wp), SyscallSucceeds());
+  });
+
+  // Reads don't fault.
+  volatile char* p = static_cast<volatile char*>(m.ptr());
+  EXPECT_EQ(*p, 0);
+  *p = 'a';
+  handler.Join();
+  EXPECT_EQ(*p, 'a');
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
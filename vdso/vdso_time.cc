@@ -41,6 +41,10 @@ struct params {
   int64_t realtime_base_cycles;
   int64_t realtime_base_ref;
   uint64_t realtime_frequency;
+
+  // boottime_offset is the offset of CLOCK_BOOTTIME from CLOCK_MONOTONIC in
+  // the time namespace of the task.
+  int64_t boottime_offset;
 };
 
 // Returns a pointer to the global parameter page.
@@ -123,9 +127,9 @@ int ClockRealtime(struct timespec* ts) {
   return 0;
 }
 
-// ClockMonotonic() is the VDSO implementation of
-// clock_gettime(CLOCK_MONOTONIC).
-int ClockMonotonic(struct timespec* ts) {
+// ClockMonotonicOffset() returns CLOCK_MONOTONIC plus the offset of the
+// given clock from CLOCK_MONOTONIC in the parameter page.
+inline int ClockMonotonicOffset(clockid_t clock, struct timespec* ts) {
   struct params* params = get_params();
   uint64_t seq;
   uint64_t ready;
@@ -133,6 +137,7 @@ int ClockMonotonic(struct timespec* ts) {
   int64_t base_cycles;
   uint64_t frequency;
   int64_t now_cycles;
+  int64_t offset;
 
   do {
     seq = read_seqcount_begin(&params->seq_count);
@@ -140,20 +145,33 @@ int ClockMonotonic(struct timespec* ts) {
     base_ref = params->monotonic_base_ref;
     base_cycles = params->monotonic_base_cycles;
     frequency = params->monotonic_frequency;
+    offset = clock == CLOCK_BOOTTIME ? params->boottime_offset : 0;
     now_cycles = cycle_clock();
   } while (read_seqcount_retry(&params->seq_count, seq));
 
   if (!ready) {
     // The sandbox kernel ensures that we won't compute a time later than this
     // once the params are ready.
-    return sys_clock_gettime(CLOCK_MONOTONIC, ts);
+    return sys_clock_gettime(clock, ts);
   }
 
   int64_t delta_cycles =
       (now_cycles < base_cycles) ? 0 : now_cycles - base_cycles;
-  int64_t now_ns = base_ref + cycles_to_ns(frequency, delta_cycles);
+  int64_t now_ns = base_ref + offset + cycles_to_ns(frequency, delta_cycles);
   *ts = ns_to_timespec(now_ns);
   return 0;
 }
 
+// ClockMonotonic() is the VDSO implementation of
+// clock_gettime(CLOCK_MONOTONIC).
+int ClockMonotonic(struct timespec* ts) {
+  return ClockMonotonicOffset(CLOCK_MONOTONIC, ts);
+}
+
+// ClockBoottime() is the VDSO implementation of
+// clock_gettime(CLOCK_BOOTTIME).
+int ClockBoottime(struct timespec* ts) {
+  return ClockMonotonicOffset(CLOCK_BOOTTIME, ts);
+}
+
 }  // namespace vdso